@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/pkg/archive"
+	"github.com/glide-im/glide/pkg/media"
+	"github.com/glide-im/glide/pkg/store"
+	"time"
+)
+
+// initArchiver creates the archiver of message history, returns nil if archive is disabled.
+func initArchiver(c *config.ArchiveConf, s store.MessageStore) (*archive.Archiver, error) {
+	if c == nil || !c.Enable {
+		return nil, nil
+	}
+	if _, ok := store.Unwrap(s).(store.ArchiveStore); !ok {
+		return nil, errors.New("message store does not support archive")
+	}
+	// the outermost store is used, such as the cache invalidated when messages are removed or restored.
+	archiveStore, _ := store.As[store.ArchiveStore](s)
+	storage, err := media.NewS3Storage(&media.S3Options{
+		Endpoint:  c.Endpoint,
+		Region:    c.Region,
+		Bucket:    c.Bucket,
+		AccessKey: c.AccessKey,
+		SecretKey: c.SecretKey,
+		PathStyle: c.PathStyle,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return archive.New(&archive.Options{
+		Store:     archiveStore,
+		Storage:   storage,
+		Age:       time.Duration(c.Days) * time.Hour * 24,
+		Interval:  time.Duration(c.Interval) * time.Second,
+		BatchSize: c.BatchSize,
+		Prefix:    c.Prefix,
+	})
+}
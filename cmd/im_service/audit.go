@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/pkg/audit"
+)
+
+// initAuditLogger creates the audit logger with sinks configured, returns nil if no sink configured.
+func initAuditLogger(c *config.AuditConf, kafka *config.KafkaConf) (*audit.Logger, error) {
+	if c == nil {
+		return nil, nil
+	}
+	var sinks []audit.Sink
+	if c.File != "" {
+		s, err := audit.NewFileSink(c.File)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if c.Syslog {
+		s, err := audit.NewSyslogSink(c.SyslogNetwork, c.SyslogAddr, "glide")
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if c.KafkaTopic != "" && kafka != nil && len(kafka.Address) != 0 {
+		s, err := audit.NewKafkaSink(kafka.Address, c.KafkaTopic)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return audit.New(sinks...)
+}
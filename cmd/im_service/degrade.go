@@ -0,0 +1,47 @@
+package main
+
+import (
+	"time"
+
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/internal/pkg/db"
+	"github.com/glide-im/glide/pkg/degrade"
+	"github.com/glide-im/glide/pkg/store"
+)
+
+// dependencyChecks returns checks of the store, redis and kafka configured by name.
+func dependencyChecks(s store.MessageStore) map[string]func() error {
+	checks := map[string]func() error{}
+	if p, ok := store.As[store.Pinger](s); ok {
+		checks["store"] = p.Ping
+	}
+	if config.Redis != nil && config.Redis.Host != "" {
+		checks["redis"] = func() error {
+			return db.Redis.Ping().Err()
+		}
+	}
+	if config.Kafka != nil && len(config.Kafka.Address) != 0 {
+		checks["kafka"] = degrade.DialCheck(config.Kafka.Address...)
+	}
+	return checks
+}
+
+// initDegradation creates the degradation controller checks the store, redis and kafka, returns nil if disabled.
+func initDegradation(c *config.DegradeConf, s store.MessageStore) (*degrade.Controller, error) {
+	if c == nil || !c.Enable {
+		return nil, nil
+	}
+	disable, err := degrade.ParseFeatures(c.Disable)
+	if err != nil {
+		return nil, err
+	}
+	ctl := degrade.New(degrade.Options{
+		Interval: time.Duration(c.Interval) * time.Second,
+		Failures: c.Failures,
+		Disable:  disable,
+	})
+	for name, check := range dependencyChecks(s) {
+		ctl.Register(name, check)
+	}
+	return ctl, nil
+}
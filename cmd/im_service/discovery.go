@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/pkg/discovery"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/logger"
+	"net/http"
+	"time"
+)
+
+func initDiscovery(c *config.DiscoveryConf) (discovery.Backend, error) {
+	if c == nil || c.Backend == "" {
+		return nil, nil
+	}
+	switch c.Backend {
+	case "etcd":
+		return discovery.NewEtcdBackend(c.Endpoints, c.Prefix)
+	case "consul":
+		var addr string
+		if len(c.Endpoints) > 0 {
+			addr = c.Endpoints[0]
+		}
+		return discovery.NewConsulBackend(addr)
+	}
+	return nil, errors.New("unknown discovery backend: " + c.Backend)
+}
+
+// registerGateway registers this gateway with the count of online clients as the load.
+func registerGateway(backend discovery.Backend, c *config.DiscoveryConf, gateway gate.DefaultGateway) (*discovery.Registrar, error) {
+	addr := c.Advertise
+	if addr == "" {
+		addr = fmt.Sprintf("ws://%s:%d/ws", config.WsServer.Addr, config.WsServer.Port)
+	}
+	r := discovery.NewRegistrar(backend, discovery.ServiceGateway, &discovery.Node{
+		ID:       config.WsServer.ID,
+		Addr:     addr,
+		Capacity: c.Capacity,
+		Meta:     map[string]string{discovery.MetaRegion: c.Region},
+	}, &discovery.RegistrarOptions{
+		TTL: time.Duration(c.TTL) * time.Second,
+		Load: func() int64 {
+			return int64(len(gateway.GetAll()))
+		},
+	})
+	return r, r.Start()
+}
+
+// watchGateways watches gateways registered, the membership is shared by the allocator and quota redirect.
+func watchGateways(backend discovery.Backend) (*discovery.Membership, error) {
+	gateways := discovery.NewMembership(backend, discovery.ServiceGateway)
+	return gateways, gateways.Start(context.Background())
+}
+
+// runAllocator serves the connect-address allocation endpoint.
+func runAllocator(gateways *discovery.Membership, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/connect", discovery.NewAllocator(gateways))
+	go func() {
+		logger.D("gateway allocator listening on %s", addr)
+		err := http.ListenAndServe(addr, mux)
+		if err != nil {
+			logger.E("gateway allocator error: %v", err)
+		}
+	}()
+}
+
+// redirectGateway returns the address of another gateway in region for clients rejected by quota of this gateway.
+func redirectGateway(gateways *discovery.Membership, region string) func() string {
+	allocator := discovery.NewAllocator(gateways)
+	return func() string {
+		allocation, err := allocator.Allocate(region)
+		if err != nil {
+			return ""
+		}
+		if allocation.Node != config.WsServer.ID {
+			return allocation.Addr
+		}
+		if len(allocation.Backups) > 0 {
+			return allocation.Backups[0]
+		}
+		return ""
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/pkg/federation"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messaging"
+	"net/http"
+)
+
+// initFederation enables forwarding messages to users homed in other regions, and serves messages forwarded by
+// other regions on the Addr, it's no-op if federation is disabled.
+func initFederation(c *config.FederationConf, handler *messaging.MessageHandlerImpl) error {
+	if c == nil || !c.Enable {
+		return nil
+	}
+	var registry federation.Registry = federation.NewMemRegistry(c.Region)
+	if c.RegistryURL != "" {
+		registry = federation.NewHTTPRegistry(c.RegistryURL, 0, 0)
+	}
+	peers := map[string]federation.Peer{}
+	for _, p := range c.Peers {
+		peers[p.Region] = federation.NewHTTPPeer(p.URL, c.Secret, 0)
+	}
+	f, err := federation.New(&federation.Options{
+		Region:   c.Region,
+		Registry: registry,
+		Peers:    peers,
+		Addr:     c.Addr,
+		Secret:   c.Secret,
+		MaxHops:  c.MaxHops,
+	})
+	if err != nil {
+		return err
+	}
+	handler.EnableFederation(f)
+
+	if c.Addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/federation", f)
+		go func() {
+			logger.D("federation listening on %s", c.Addr)
+			err := http.ListenAndServe(c.Addr, mux)
+			if err != nil {
+				logger.E("federation server error: %v", err)
+			}
+		}()
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/glide-im/glide/pkg/degrade"
+	"github.com/glide-im/glide/pkg/discovery"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/health"
+	"github.com/glide-im/glide/pkg/store"
+)
+
+const discoveryCheckTimeout = time.Second * 2
+
+// initHealth creates the health server checks dependencies configured. The store, redis and kafka are required unless
+// the degraded mode is enabled, the discovery backend is optional as connected clients are served without it.
+func initHealth(addr string, gateway gate.DefaultGateway, s store.MessageStore, degradation *degrade.Controller, backend discovery.Backend) *health.Server {
+	srv := health.NewServer(&health.Options{
+		Addr: addr,
+		Connections: func() int {
+			return len(gateway.GetAll())
+		},
+	})
+	for name, check := range dependencyChecks(s) {
+		srv.Register(name, check, degradation == nil)
+	}
+	if backend != nil {
+		srv.Register("discovery", func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), discoveryCheckTimeout)
+			defer cancel()
+			_, err := backend.Nodes(ctx, discovery.ServiceGateway)
+			return err
+		}, false)
+	}
+	return srv
+}
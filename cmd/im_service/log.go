@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/pkg/logger"
+	"io"
+	"os"
+)
+
+func initLogger(c *config.LogConf) error {
+	if c == nil {
+		return nil
+	}
+	setLogLevel(c.Level)
+
+	var w io.Writer = os.Stdout
+	if c.File != "" {
+		rw, err := logger.NewRotateWriter(c.File, c.MaxSize<<20, c.MaxBackups)
+		if err != nil {
+			return err
+		}
+		w = rw
+	}
+	if c.Format == "json" || c.File != "" {
+		logger.SetSink(logger.NewJSONSink(w))
+	}
+	return nil
+}
+
+func setLogLevel(level string) {
+	switch level {
+	case "info":
+		logger.SetLevel(logger.LevelInfo)
+	case "warn":
+		logger.SetLevel(logger.LevelWarn)
+	case "error":
+		logger.SetLevel(logger.LevelError)
+	default:
+		logger.SetLevel(logger.LevelDebug)
+	}
+}
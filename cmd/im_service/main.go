@@ -4,22 +4,77 @@ import (
 	"github.com/glide-im/glide/config"
 	"github.com/glide-im/glide/im_service/server"
 	"github.com/glide-im/glide/internal/message_store_db"
+	"github.com/glide-im/glide/internal/message_store_mongo"
 	"github.com/glide-im/glide/internal/pkg/db"
 	"github.com/glide-im/glide/internal/world_channel"
+	"github.com/glide-im/glide/pkg/admin"
+	"github.com/glide-im/glide/pkg/audit"
+	"github.com/glide-im/glide/pkg/bot"
+	"github.com/glide-im/glide/pkg/broadcast"
+	"github.com/glide-im/glide/pkg/conn"
+	"github.com/glide-im/glide/pkg/degrade"
+	"github.com/glide-im/glide/pkg/diag"
 	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/health"
+	"github.com/glide-im/glide/pkg/i18n"
 	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
 	"github.com/glide-im/glide/pkg/messaging"
+	"github.com/glide-im/glide/pkg/metering"
+	"github.com/glide-im/glide/pkg/metrics"
+	"github.com/glide-im/glide/pkg/moderation"
+	"github.com/glide-im/glide/pkg/relation"
 	"github.com/glide-im/glide/pkg/rpc"
+	"github.com/glide-im/glide/pkg/schedule"
+	"github.com/glide-im/glide/pkg/sequence"
+	"github.com/glide-im/glide/pkg/service"
+	"github.com/glide-im/glide/pkg/snowflake"
 	"github.com/glide-im/glide/pkg/store"
 	"github.com/glide-im/glide/pkg/subscription/subscription_impl"
+	"github.com/glide-im/glide/pkg/tap"
+	"github.com/glide-im/glide/pkg/webhook"
+	"time"
 )
 
 func main() {
 
 	config.MustLoad()
 
-	err := db.Init(nil, &db.RedisConfig{
+	err := initLogger(config.Log)
+	if err != nil {
+		panic(err)
+	}
+
+	auditLogger, err := initAuditLogger(config.Audit, config.Kafka)
+	if err != nil {
+		panic(err)
+	}
+	if auditLogger != nil {
+		audit.SetDefault(auditLogger)
+		defer auditLogger.Close()
+	}
+
+	meter, err := initMeter(config.Metering)
+	if err != nil {
+		panic(err)
+	}
+	if meter != nil {
+		metering.SetDefault(meter)
+		meter.Start()
+		defer meter.Stop()
+	}
+
+	mirror, err := initTap(config.Tap, config.Kafka)
+	if err != nil {
+		panic(err)
+	}
+	if mirror != nil {
+		tap.SetDefault(mirror)
+		mirror.Start()
+		defer mirror.Stop()
+	}
+
+	err = db.Init(nil, &db.RedisConfig{
 		Host:     config.Redis.Host,
 		Port:     config.Redis.Port,
 		Password: config.Redis.Password,
@@ -29,12 +84,178 @@ func main() {
 		panic(err)
 	}
 
+	var registry *snowflake.RedisWorkerRegistry
+	workerID := config.Common.WorkerID
+	if workerID < 0 {
+		registry = snowflake.NewRedisWorkerRegistry(db.Redis, 0)
+		workerID, err = registry.Acquire(config.WsServer.ID)
+		if err != nil {
+			panic(err)
+		}
+	}
+	node, err := snowflake.NewNode(workerID)
+	if err != nil {
+		panic(err)
+	}
+	if registry != nil {
+		registry.Bind(node, config.WsServer.ID)
+	}
+	snowflake.SetDefault(node)
+	logger.D("snowflake worker id: %d", workerID)
+
+	if config.Common.MetricsAddr != "" {
+		go func() {
+			logger.D("metrics listening on %s", config.Common.MetricsAddr)
+			err := metrics.Serve(config.Common.MetricsAddr)
+			if err != nil {
+				logger.E("metrics server error: %v", err)
+			}
+		}()
+	}
+
 	gateway := gate.NewWebsocketServer(
 		config.WsServer.ID,
 		config.WsServer.Addr,
 		config.WsServer.Port,
 		config.Common.SecretKey,
 	)
+	if keyRing := gateway.KeyRing(); keyRing != nil {
+		for kid, secret := range config.Common.SecretKeys {
+			if err := keyRing.Add(kid, secret, false); err != nil {
+				panic(err)
+			}
+		}
+		keyRing.SetRejectLegacy(config.Common.RejectLegacyCredentials)
+	}
+	if config.Common.TicketProviderURL != "" {
+		gateway.SetTicketProvider(gate.NewHTTPTicketProvider(config.Common.TicketProviderURL, 0))
+	}
+	gateway.SetMessageSize(config.WsServer.MaxMessageSize, config.WsServer.MaxChunkedMessageSize)
+	gateway.SetRejectUnknownAction(config.WsServer.RejectUnknownAction)
+	if config.WsServer.Netpoll {
+		err = gateway.UseNetpoll(&conn.NetpollServerOptions{
+			WriteTimeout:   time.Minute * 3,
+			Pollers:        config.WsServer.NetpollPollers,
+			Workers:        config.WsServer.NetpollWorkers,
+			MaxMessageSize: config.WsServer.MaxMessageSize,
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+	if config.WsServer.TLSCert != "" {
+		tlsConfig, err := conn.NewTLSConfig(&conn.TLSOptions{
+			CertFile:          config.WsServer.TLSCert,
+			KeyFile:           config.WsServer.TLSKey,
+			ClientCAFile:      config.WsServer.TLSClientCA,
+			RequireClientCert: config.WsServer.TLSRequireClientCert,
+		})
+		if err != nil {
+			panic(err)
+		}
+		if err = gateway.UseTLS(tlsConfig); err != nil {
+			panic(err)
+		}
+		if config.WsServer.TLSCertAuth {
+			resolver := gate.CommonNameResolver
+			if len(config.WsServer.TLSCertUsers) > 0 {
+				resolver = gate.MappedCommonNameResolver(config.WsServer.TLSCertUsers)
+			}
+			gateway.SetCertResolver(resolver)
+		}
+	}
+	if config.WsServer.LongPolling {
+		gateway.UseLongPolling(&conn.PollServerOptions{
+			PollTimeout:    time.Second * time.Duration(config.WsServer.LongPollingTimeout),
+			MaxMessageSize: config.WsServer.MaxMessageSize,
+		})
+	}
+	if len(config.WsServer.AllowedOrigins) > 0 || config.WsServer.RequireSubprotocol {
+		gateway.SetUpgradePolicy(&conn.UpgradePolicy{
+			AllowedOrigins:     config.WsServer.AllowedOrigins,
+			RequireSubprotocol: config.WsServer.RequireSubprotocol,
+		})
+	}
+	admission, err := gate.NewAdmission(&gate.AdmissionRules{
+		Allow:          config.WsServer.AllowIPs,
+		Deny:           config.WsServer.DenyIPs,
+		AllowCountries: config.WsServer.AllowCountries,
+		DenyCountries:  config.WsServer.DenyCountries,
+		DenyASNs:       config.WsServer.DenyASNs,
+	})
+	if err != nil {
+		panic(err)
+	}
+	gateway.SetAdmission(admission)
+	if config.WsServer.TicketReplayWindow > 0 {
+		var nonces gate.NonceStore
+		if config.Redis != nil && config.Redis.Host != "" {
+			nonces = gate.NewRedisNonceStore(db.Redis)
+		}
+		gateway.SetTicketReplayProtection(time.Second*time.Duration(config.WsServer.TicketReplayWindow), config.WsServer.TicketRequireSigned, nonces)
+	}
+	if config.WsServer.MessageSign != "" {
+		gateway.SetSignVerification(&gate.SignVerifierOptions{Required: config.WsServer.MessageSign == "required"})
+	}
+	switch config.WsServer.Challenge {
+	case "":
+	case messages.ChallengeTypePoW:
+		gateway.SetChallenger(gate.NewPoWChallenger(config.WsServer.ChallengeDifficulty), time.Second*time.Duration(config.WsServer.ChallengeTTL))
+	case messages.ChallengeTypeCaptcha:
+		verifier := gate.SiteVerify(config.WsServer.CaptchaVerifyURL, config.WsServer.CaptchaSecret)
+		gateway.SetChallenger(gate.NewCaptchaChallenger(config.WsServer.CaptchaSiteKey, verifier), time.Second*time.Duration(config.WsServer.ChallengeTTL))
+	default:
+		panic("unknown challenge type: " + config.WsServer.Challenge)
+	}
+	if config.WsServer.AnomalyDetectorURL != "" {
+		gateway.SetAnomalyDetector(gate.NewHTTPAnomalyDetector(config.WsServer.AnomalyDetectorURL, 0))
+	}
+	var watchdog *gate.Watchdog
+	if config.WsServer.SlowClientStall > 0 {
+		watchdog = gate.NewWatchdog(gateway, &gate.WatchdogOptions{
+			StallThreshold:     time.Millisecond * time.Duration(config.WsServer.SlowClientStall),
+			ResidenceThreshold: time.Millisecond * time.Duration(config.WsServer.SlowClientResidence),
+			Evict:              config.WsServer.SlowClientEvict,
+		})
+		watchdog.Start()
+	}
+
+	if config.WsServer.ResumeWindow > 0 {
+		resumeOpts := &gate.ResumeOptions{
+			Window:     time.Second * time.Duration(config.WsServer.ResumeWindow),
+			BufferSize: config.WsServer.ResumeBufferSize,
+		}
+		if config.WsServer.ResumeReplica {
+			if config.Redis == nil || config.Redis.Host == "" {
+				panic("resume replica requires redis")
+			}
+			resumeOpts.Replica = store.NewRedisCache(db.Redis)
+		}
+		gateway.EnableResume(resumeOpts)
+	}
+
+	gateway.SetLoginNotify(config.WsServer.LoginNotify, nil)
+	if config.WsServer.LocaleDir != "" {
+		if err = i18n.Default().LoadDir(config.WsServer.LocaleDir); err != nil {
+			panic(err)
+		}
+	}
+
+	var quota *gate.Quota
+	if config.WsServer.MaxConnections > 0 || config.WsServer.MaxConnectionsPerUID > 0 || config.WsServer.MaxOutboundBytes > 0 {
+		quota = gate.NewQuota(&gate.QuotaOptions{
+			MaxConnections:       config.WsServer.MaxConnections,
+			MaxConnectionsPerUID: config.WsServer.MaxConnectionsPerUID,
+			MaxOutboundBytes:     config.WsServer.MaxOutboundBytes,
+			RetryAfter:           time.Second * time.Duration(config.WsServer.QuotaRetryAfter),
+		})
+		gateway.SetQuota(quota)
+	}
+
+	var seqAllocator sequence.Allocator = sequence.NewMemAllocator()
+	if config.Redis != nil && config.Redis.Host != "" {
+		seqAllocator = sequence.NewRedisAllocator(db.Redis)
+	}
 
 	var cStore store.MessageStore = &message_store_db.IdleChatMessageStore{}
 	var sStore store.SubscriptionStore = &message_store_db.IdleSubscriptionStore{}
@@ -49,34 +270,239 @@ func main() {
 			sStore = producer
 			logger.D("Kafka is configured, all message will push to kafka: %v", config.Kafka.Address)
 		} else {
-			dbStore, err := message_store_db.New(config.MySql)
+			var dbStore interface {
+				store.MessageHistoryStore
+				store.SubscriptionStore
+				sequence.SegmentStore
+			}
+			if config.Common.MessageStoreDriver == "mongodb" {
+				dbStore, err = message_store_mongo.New(config.MongoDB)
+			} else {
+				dbStore, err = message_store_db.New(config.MySql)
+			}
 			if err != nil {
 				panic(err)
 			}
-			cStore = dbStore
-			sStore = &message_store_db.SubscriptionMessageStore{}
+			err = dbStore.Migrate()
+			if err != nil {
+				panic(err)
+			}
+			var hStore store.MessageHistoryStore = dbStore
+			sStore = dbStore
+			if config.Common.StoreCache != "" {
+				var cache store.Cache = store.NewMemCache(config.Common.StoreCacheSize)
+				if config.Common.StoreCache == "redis" {
+					cache = store.NewRedisCache(db.Redis)
+				}
+				cached := store.NewCacheStore(dbStore, store.CacheOptions{
+					Cache: cache,
+					TTL:   time.Second * time.Duration(config.Common.StoreCacheTTL),
+				})
+				hStore = cached
+				sStore = cached
+			}
+			cStore = store.NewResilientHistoryStore(hStore, store.ResilientOptions{
+				Retries:         config.Common.StoreRetries,
+				Timeout:         time.Millisecond * time.Duration(config.Common.StoreTimeout),
+				BreakerFailures: config.Common.StoreBreakerFailures,
+				BreakerOpen:     time.Second * time.Duration(config.Common.StoreBreakerOpen),
+			})
+			if config.Common.StoreWriteBehind {
+				cStore, err = store.NewWriteBehindStore(cStore, store.WriteBehindOptions{
+					WALDir: config.Common.StoreWALDir,
+				})
+				if err != nil {
+					panic(err)
+				}
+			}
+			if config.Redis == nil || config.Redis.Host == "" {
+				seqAllocator = sequence.NewSegmentAllocator(dbStore, 0)
+			}
 		}
 
 	} else {
 		logger.D("Common.StoreMessageHistory is false, message history will not be stored")
 	}
 
+	degradation, err := initDegradation(config.Degrade, cStore)
+	if err != nil {
+		panic(err)
+	}
+	if degradation != nil {
+		cStore = degrade.NewStore(cStore, degradation)
+		if ms, ok := sStore.(store.MessageStore); ok {
+			sStore = degrade.NewStore(ms, degradation)
+		}
+		degradation.Start()
+		defer degradation.Stop()
+	}
+
+	if config.Webhook != nil && len(config.Webhook.URLs) != 0 {
+		dispatcher, err := webhook.NewDispatcher(&webhook.Options{
+			URLs:       config.Webhook.URLs,
+			Secret:     config.Webhook.Secret,
+			Events:     config.Webhook.Events,
+			MaxRetries: config.Webhook.MaxRetries,
+		})
+		if err != nil {
+			panic(err)
+		}
+		webhook.SetDefault(dispatcher)
+	}
+
+	pushBridge, err := initPushBridge(config.Push)
+	if err != nil {
+		panic(err)
+	}
+
+	uploader, err := initUploader(config.Media)
+	if err != nil {
+		panic(err)
+	}
+
+	var moderator moderation.Moderator
+	var moderationMode = moderation.ModeSync
+	if config.Moderation != nil && config.Moderation.URL != "" {
+		failPolicy := moderation.FailOpen
+		if config.Moderation.FailClosed {
+			failPolicy = moderation.FailClosed
+		}
+		moderator, err = moderation.NewHTTPModerator(&moderation.HTTPOptions{
+			URL:        config.Moderation.URL,
+			Timeout:    time.Duration(config.Moderation.Timeout) * time.Millisecond,
+			FailPolicy: failPolicy,
+		})
+		if err != nil {
+			panic(err)
+		}
+		if config.Moderation.Async {
+			moderationMode = moderation.ModeAsync
+		}
+	}
+
+	var deadLetters store.DeadLetterSink
+	switch config.Common.DeadLetter {
+	case "":
+	case "memory":
+		deadLetters = store.NewMemDeadLetterStore(0)
+	case "store":
+		ds, ok := store.As[store.DeadLetterSink](cStore)
+		if !ok {
+			panic("message store does not support dead letters")
+		}
+		deadLetters = ds
+	default:
+		panic("unknown dead letter sink: " + config.Common.DeadLetter)
+	}
+
+	var relations relation.RelationProvider
+	if config.Common.BlockList || config.Common.RequireContact {
+		if config.Redis == nil || config.Redis.Host == "" {
+			panic("block list and contacts require redis")
+		}
+		relations = relation.NewRedisProvider(db.Redis)
+	}
+	var transformer messaging.Transformer
+	if config.Common.TransformerURL != "" {
+		transformer = messaging.NewHTTPTransformer(config.Common.TransformerURL, 0)
+	}
+
 	handler, err := messaging.NewHandlerWithOptions(gateway, &messaging.MessageHandlerOptions{
 		MessageStore:           cStore,
 		DontInitDefaultHandler: false,
 		NotifyOnErr:            true,
+		SequenceAllocator:      seqAllocator,
+		PushBridge:             pushBridge,
+		Uploader:               uploader,
+		CallRingTimeout:        time.Duration(config.Common.CallRingTimeout) * time.Second,
+		NotifyExpired:          config.Common.NotifyExpired,
+		DeadLetters:            deadLetters,
+		Relations:              relations,
+		DropBlocked:            config.Common.DropBlocked,
+		RequireContact:         config.Common.RequireContact,
+		Moderator:              moderator,
+		ModerationMode:         moderationMode,
+		Workers:                config.Common.MessageWorkers,
+		WorkerQueueSize:        config.Common.MessageWorkerQueueSize,
+		ConversationQueueSize:  config.Common.ConversationQueueSize,
+		Degradation:            degradation,
+		ReadCountInterval:      time.Duration(config.Common.ReadCountInterval) * time.Millisecond,
+		MaxReaderListMembers:   config.Common.MaxReaderListMembers,
+		Transformer:            transformer,
+		RouteRule: &messaging.RouteRule{
+			Policy:  config.Common.DeviceRoute,
+			Devices: config.Common.DeviceRouteDevices,
+		},
 	})
 	if err != nil {
 		panic(err)
 	}
+	if config.Common.QuotaProviderURL != "" {
+		handler.SetQuotaProvider(messaging.NewHTTPQuotaProvider(config.Common.QuotaProviderURL, 0))
+	}
 	messaging.StoreOfflineMessage = config.Common.StoreOfflineMessage
 
+	var filterRules []messaging.FilterRule
+	for _, r := range config.FilterRules {
+		filterRules = append(filterRules, messaging.FilterRule{
+			Name:        r.Name,
+			Keywords:    r.Keywords,
+			Pattern:     r.Pattern,
+			MaxSize:     r.MaxSize,
+			Action:      r.Action,
+			Tag:         r.Tag,
+			Replacement: r.Replacement,
+		})
+	}
+	err = handler.MessageFilter().SetRules(filterRules)
+	if err != nil {
+		panic(err)
+	}
+
+	err = applyRuntimeConfig(gateway, handler)
+	if err != nil {
+		panic(err)
+	}
+
 	subscription := subscription_impl.NewSubscription(sStore, sStore)
 	subscription.SetGateInterface(gateway)
 
 	handler.SetSubscription(subscription)
 	handler.SetGate(gateway)
 
+	if len(config.Services) > 0 {
+		services := service.NewManager()
+		for _, sc := range config.Services {
+			pool, err := services.Register(sc.ID, &service.Options{
+				Strategy:    service.StrategyOf(sc.Strategy),
+				MaxSessions: sc.MaxSessions,
+			})
+			if err != nil {
+				panic(err)
+			}
+			for _, agent := range sc.Agents {
+				pool.AddAgent(agent)
+			}
+		}
+		handler.EnableServices(services)
+	}
+
+	if err = initFederation(config.Federation, handler); err != nil {
+		panic(err)
+	}
+
+	var scheduler *schedule.Scheduler
+	if config.Common.ScheduleMessage {
+		scheduleStore, _ := store.Unwrap(cStore).(store.ScheduleStore)
+		scheduler, err = handler.EnableSchedule(&schedule.Options{
+			Store:    scheduleStore,
+			MaxDelay: time.Duration(config.Common.ScheduleMaxDelay) * time.Second,
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	go func() {
 		logger.D("websocket listening on %s:%d", config.WsServer.Addr, config.WsServer.Port)
 
@@ -87,12 +513,169 @@ func main() {
 			}
 		})
 
+		for _, bc := range config.Bots {
+			b, err := bot.New(bc.UID, &bot.Options{
+				Handler: bot.NewWebhook(&bot.WebhookOptions{URL: bc.URL, Secret: bc.Secret}),
+				Send: func(cliInfo *gate.Info, message *messages.GlideMessage) {
+					if e := handler.Handle(cliInfo, message); e != nil {
+						logger.E("bot error: %v", e)
+					}
+				},
+				Subscription: subscription_impl.NewSubscribeWrap(subscription),
+			})
+			if err != nil {
+				panic(err)
+			}
+			gateway.AddClient(b)
+			go b.Run()
+		}
+
 		err = gateway.Run()
 		if err != nil {
 			panic(err)
 		}
 	}()
 
+	discoveryBackend, err := initDiscovery(config.Discovery)
+	if err != nil {
+		panic(err)
+	}
+	if discoveryBackend != nil {
+		_, err = registerGateway(discoveryBackend, config.Discovery, gateway)
+		if err != nil {
+			panic(err)
+		}
+		if config.Discovery.AllocatorAddr != "" || quota != nil {
+			gateways, err := watchGateways(discoveryBackend)
+			if err != nil {
+				panic(err)
+			}
+			if config.Discovery.AllocatorAddr != "" {
+				runAllocator(gateways, config.Discovery.AllocatorAddr)
+			}
+			if quota != nil {
+				quota.SetRedirect(redirectGateway(gateways, config.Discovery.Region))
+			}
+		}
+	}
+
+	var healthServer *health.Server
+	if config.Common.HealthAddr != "" {
+		healthServer = initHealth(config.Common.HealthAddr, gateway, cStore, degradation, discoveryBackend)
+		go func() {
+			logger.D("health listening on %s", config.Common.HealthAddr)
+			err := healthServer.Run()
+			if err != nil {
+				logger.E("health server error: %v", err)
+			}
+		}()
+	}
+
+	if config.Common.DiagnosticsAddr != "" {
+		diagServer := diag.NewServer(&diag.Options{
+			Addr:           config.Common.DiagnosticsAddr,
+			Token:          config.Common.DiagnosticsToken,
+			QueueThreshold: config.Common.DiagnosticsQueueThreshold,
+		})
+		diagServer.SetGateway(gateway)
+		diagServer.SetSubscription(subscription)
+		if watchdog != nil {
+			diagServer.SetSlowClients(watchdog)
+		}
+		go func() {
+			logger.D("diagnostics listening on %s", config.Common.DiagnosticsAddr)
+			err := diagServer.Run()
+			if err != nil {
+				logger.E("diagnostics server error: %v", err)
+			}
+		}()
+	}
+
+	archiver, err := initArchiver(config.Archive, cStore)
+	if err != nil {
+		panic(err)
+	}
+	if archiver != nil {
+		archiver.Start()
+		handler.AddUserPurger(archiver)
+	}
+	retentionManager, err := initRetention(config.Retention, cStore)
+	if err != nil {
+		panic(err)
+	}
+	if retentionManager != nil {
+		retentionManager.Start()
+	}
+
+	broadcaster := broadcast.NewBroadcaster(gateway, &broadcast.Options{
+		Rate: config.Common.BroadcastRate,
+	})
+
+	if config.Admin != nil && config.Admin.Addr != "" {
+		adminServer, err := admin.NewServer(gateway, &admin.Options{
+			Addr:  config.Admin.Addr,
+			Token: config.Admin.Token,
+		})
+		if err != nil {
+			panic(err)
+		}
+		adminServer.SetSubscription(subscription)
+		adminServer.SetRateLimiter(handler)
+		adminServer.SetFilterManager(handler.MessageFilter())
+		adminServer.SetAdmissionManager(admission)
+		adminServer.SetBroadcaster(broadcaster)
+		adminServer.SetUserPurger(handler)
+		adminServer.SetDeadLetterManager(handler)
+		if healthServer != nil {
+			adminServer.SetDrainer(healthServer)
+		}
+		if scheduler != nil {
+			adminServer.SetScheduler(scheduler)
+		}
+		if archiver != nil {
+			adminServer.SetArchiver(archiver)
+		}
+		if keyRing := gateway.KeyRing(); keyRing != nil {
+			adminServer.SetKeyManager(keyRing)
+		}
+		go func() {
+			logger.D("admin listening on %s", config.Admin.Addr)
+			err := adminServer.Run()
+			if err != nil {
+				logger.E("admin server error: %v", err)
+			}
+		}()
+	}
+
+	if config.Business != nil {
+		businessServer := server.NewBusinessServer(gateway, subscription)
+		if config.Business.Addr != "" {
+			go func() {
+				logger.D("business grpc listening on %s", config.Business.Addr)
+				err := server.RunGrpcService(config.Business.Addr, config.Business.Token, businessServer)
+				if err != nil {
+					logger.E("business grpc server error: %v", err)
+				}
+			}()
+		}
+		if config.Business.RestAddr != "" {
+			restServer, err := server.NewRestServer(businessServer, &server.RestOptions{
+				Addr:    config.Business.RestAddr,
+				APIKeys: config.Business.APIKeys,
+			})
+			if err != nil {
+				panic(err)
+			}
+			go func() {
+				logger.D("business rest listening on %s", config.Business.RestAddr)
+				err := restServer.Run()
+				if err != nil {
+					logger.E("business rest server error: %v", err)
+				}
+			}()
+		}
+	}
+
 	err = world_channel.EnableWorldChannel(subscription_impl.NewSubscribeWrap(subscription))
 	if err != nil {
 		panic(err)
@@ -105,7 +688,7 @@ func main() {
 		Port:    config.IMService.Port,
 	}
 	logger.D("rpc %s listening on %s %s:%d", rpcOpts.Name, rpcOpts.Network, rpcOpts.Addr, rpcOpts.Port)
-	err = server.RunRpcService(&rpcOpts, gateway, subscription)
+	err = server.RunRpcService(&rpcOpts, gateway, subscription, broadcaster)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/pkg/media"
+	"time"
+)
+
+func initUploader(c *config.MediaConf) (*media.Uploader, error) {
+	if c == nil || !c.Enable {
+		return nil, nil
+	}
+	storage, err := media.NewS3Storage(&media.S3Options{
+		Endpoint:  c.Endpoint,
+		Region:    c.Region,
+		Bucket:    c.Bucket,
+		AccessKey: c.AccessKey,
+		SecretKey: c.SecretKey,
+		PathStyle: c.PathStyle,
+		PublicURL: c.PublicURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return media.NewUploader(storage, &media.Options{
+		MaxSize:      c.MaxSize,
+		AllowedMimes: c.AllowedMimes,
+		Expires:      time.Duration(c.Expires) * time.Second,
+	}), nil
+}
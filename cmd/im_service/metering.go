@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/pkg/metering"
+	"github.com/glide-im/glide/pkg/metrics"
+	"time"
+)
+
+// initMeter creates the usage meter with sinks configured, returns nil if metering is disabled or no sink configured.
+func initMeter(c *config.MeteringConf) (*metering.Meter, error) {
+	if c == nil || !c.Enable {
+		return nil, nil
+	}
+	var sinks []metering.Sink
+	if c.Prometheus {
+		s, err := metering.NewPrometheusSink(metrics.Registry)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if c.ClickHouseURL != "" {
+		s, err := metering.NewClickHouseSink(c.ClickHouseURL, c.ClickHouseTable)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if c.Webhook {
+		sinks = append(sinks, metering.WebhookSink{})
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	opts := &metering.Options{
+		Interval: time.Duration(c.Interval) * time.Second,
+		Sinks:    sinks,
+	}
+	if c.TenantSeparator != "" {
+		opts.Tenant = metering.PrefixTenant(c.TenantSeparator)
+	}
+	return metering.New(opts)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/internal/pkg/db"
+	"github.com/glide-im/glide/pkg/push"
+	"os"
+)
+
+// initPushBridge creates the push bridge with providers configured, returns nil if push is disabled.
+func initPushBridge(c *config.PushConf) (*push.Bridge, error) {
+	if c == nil || !c.Enable {
+		return nil, nil
+	}
+	bridge, err := push.NewBridge(&push.Options{
+		Devices:       push.NewRedisDeviceStore(db.Redis),
+		Settings:      push.NewRedisSettingsStore(db.Redis),
+		Badges:        push.NewRedisBadgeStore(db.Redis),
+		TitleTemplate: c.TitleTemplate,
+		BodyTemplate:  c.BodyTemplate,
+		Sound:         c.Sound,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.APNsKeyFile != "" {
+		key, err := os.ReadFile(c.APNsKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		p, err := push.NewAPNsProvider(&push.APNsOptions{
+			KeyID:      c.APNsKeyID,
+			TeamID:     c.APNsTeamID,
+			Topic:      c.APNsTopic,
+			PrivateKey: key,
+			Sandbox:    c.APNsSandbox,
+		})
+		if err != nil {
+			return nil, err
+		}
+		bridge.AddProvider(p)
+	}
+	if c.FCMCredentialsFile != "" {
+		credentials, err := os.ReadFile(c.FCMCredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		p, err := push.NewFCMProvider(&push.FCMOptions{Credentials: credentials})
+		if err != nil {
+			return nil, err
+		}
+		bridge.AddProvider(p)
+	}
+	return bridge, nil
+}
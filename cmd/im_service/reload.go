@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/messaging"
+	"time"
+)
+
+// applyRuntimeConfig applies the runtime tunable values, and applies them again when the config is reloaded.
+func applyRuntimeConfig(gateway *gate.WebsocketGatewayServer, handler *messaging.MessageHandlerImpl) error {
+	if err := setSendQueue(gateway, config.WsServer); err != nil {
+		return err
+	}
+	gateway.SetHeartbeat(time.Second * time.Duration(config.WsServer.HeartbeatInterval))
+	if err := setEgressRates(gateway, config.WsServer); err != nil {
+		return err
+	}
+	for name, value := range config.Common.RateLimits {
+		if err := handler.SetRateLimit(name, value); err != nil {
+			return err
+		}
+	}
+	messages.SetDisabledFeatures(config.Common.DisabledFeatures)
+	gateway.SetRemoteConfig(remoteConfig(config.RemoteConfig))
+
+	config.Subscribe("Log", func(e config.ChangeEvent) {
+		if c := e.New.(*config.LogConf); c != nil {
+			setLogLevel(c.Level)
+		}
+	})
+	config.Subscribe("WsServer", func(e config.ChangeEvent) {
+		c := e.New.(*config.WsServerConf)
+		if err := setSendQueue(gateway, c); err != nil {
+			logger.E("reload send queue error: %v", err)
+		}
+		gateway.SetHeartbeat(time.Second * time.Duration(c.HeartbeatInterval))
+		if err := setEgressRates(gateway, c); err != nil {
+			logger.E("reload egress rates error: %v", err)
+		}
+	})
+	config.Subscribe("CommonConf", func(e config.ChangeEvent) {
+		for name, value := range e.New.(*config.CommonConf).RateLimits {
+			if err := handler.SetRateLimit(name, value); err != nil {
+				logger.E("reload rate limit %s error: %v", name, err)
+			}
+		}
+		messages.SetDisabledFeatures(e.New.(*config.CommonConf).DisabledFeatures)
+	})
+	config.Subscribe("RemoteConfig", func(e config.ChangeEvent) {
+		gateway.SetRemoteConfig(remoteConfig(e.New.(*config.RemoteConfigConf)))
+	})
+	config.Watch()
+	return nil
+}
+
+func setSendQueue(gateway *gate.WebsocketGatewayServer, c *config.WsServerConf) error {
+	overflow, err := gate.ParseOverflowPolicy(c.SendQueueOverflow)
+	if err != nil {
+		return err
+	}
+	gateway.SetSendQueue(c.SendQueueSize, overflow, time.Millisecond*time.Duration(c.SendQueueTimeout))
+	return nil
+}
+
+func setEgressRates(gateway *gate.WebsocketGatewayServer, c *config.WsServerConf) error {
+	rates := make(map[string]int64, len(c.EgressRates))
+	for device, kb := range c.EgressRates {
+		rates[device] = kb * 1024
+	}
+	return gateway.SetEgressRates(rates)
+}
+
+// remoteConfig returns the config pushed to clients, nil to push nothing.
+func remoteConfig(c *config.RemoteConfigConf) *messages.RemoteConfig {
+	if c == nil {
+		return nil
+	}
+	return &messages.RemoteConfig{
+		HeartbeatInterval: c.HeartbeatInterval,
+		MinBackoff:        c.MinBackoff,
+		MaxBackoff:        c.MaxBackoff,
+		MediaUploadURL:    c.MediaUploadURL,
+		Features:          c.Features,
+		Extra:             c.Extra,
+	}
+}
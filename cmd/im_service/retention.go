@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/pkg/metering"
+	"github.com/glide-im/glide/pkg/retention"
+	"github.com/glide-im/glide/pkg/store"
+	"time"
+)
+
+// initRetention creates the retention manager of message history, returns nil if retention is disabled. Policies are
+// applied again when the config is reloaded.
+func initRetention(c *config.RetentionConf, s store.MessageStore) (*retention.Manager, error) {
+	if c == nil || !c.Enable {
+		return nil, nil
+	}
+	if _, ok := store.Unwrap(s).(store.RetentionStore); !ok {
+		return nil, errors.New("message store does not support retention")
+	}
+	// the outermost store is used, such as the cache invalidated when messages are purged.
+	retentionStore, _ := store.As[store.RetentionStore](s)
+	opts := &retention.Options{
+		Store:     retentionStore,
+		Interval:  time.Duration(c.Interval) * time.Second,
+		BatchSize: c.BatchSize,
+		RateLimit: c.RateLimit,
+	}
+	if c.TenantSeparator != "" {
+		opts.Tenant = metering.PrefixTenant(c.TenantSeparator)
+	}
+	opts.Default, opts.Channels, opts.Tenants = retentionPolicies(c)
+	m, err := retention.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	config.Subscribe("Retention", func(e config.ChangeEvent) {
+		if c := e.New.(*config.RetentionConf); c != nil {
+			m.SetPolicies(retentionPolicies(c))
+		}
+	})
+	return m, nil
+}
+
+func retentionPolicies(c *config.RetentionConf) (*retention.Policy, map[string]*retention.Policy, map[string]*retention.Policy) {
+	channels := map[string]*retention.Policy{}
+	tenants := map[string]*retention.Policy{}
+	for _, p := range c.Policies {
+		policy := &retention.Policy{Age: time.Duration(p.Days) * time.Hour * 24, Messages: p.Messages}
+		if p.Channel != "" {
+			channels[p.Channel] = policy
+		} else {
+			tenants[p.Tenant] = policy
+		}
+	}
+	def := &retention.Policy{Age: time.Duration(c.Days) * time.Hour * 24, Messages: c.Messages}
+	return def, channels, tenants
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/tap"
+)
+
+// initTap creates the tap mirrors messages delivered to sinks configured, returns nil if disabled or no sink configured.
+func initTap(c *config.TapConf, kafka *config.KafkaConf) (*tap.Tap, error) {
+	if c == nil || !c.Enable {
+		return nil, nil
+	}
+	var sinks []tap.Sink
+	if c.KafkaTopic != "" && kafka != nil && len(kafka.Address) != 0 {
+		s, err := tap.NewKafkaSink(kafka.Address, c.KafkaTopic)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if c.ClickHouseURL != "" {
+		s, err := tap.NewClickHouseSink(c.ClickHouseURL, c.ClickHouseTable)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if c.File != "" {
+		s, err := tap.NewFileSink(c.File)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	opts := &tap.Options{
+		SampleRate: c.SampleRate,
+		Sinks:      sinks,
+	}
+	for _, a := range c.Actions {
+		opts.Actions = append(opts.Actions, messages.Action(a))
+	}
+	if len(c.RedactFields) != 0 {
+		opts.Scrubbers = append(opts.Scrubbers, tap.RedactFields(c.RedactFields...))
+	}
+	if c.HashSecret != "" {
+		opts.Scrubbers = append(opts.Scrubbers, tap.HashFields(c.HashSecret, c.HashFields...))
+	}
+	return tap.New(opts)
+}
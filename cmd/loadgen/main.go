@@ -0,0 +1,345 @@
+// Command loadgen simulates clients connecting to the gateway with the client SDK for capacity planning, it reports the
+// connect, ack and delivery latency percentiles and error rates periodically.
+//
+// The credentials are encrypted with the secret of the gateway, the channels are created and subscribed by the rpc of
+// the im service if the channels is greater than 0.
+//
+//	go run ./cmd/loadgen -url ws://127.0.0.1:8083/ws -secret xxx -clients 20000 -connect-rate 500 -interval 10s
+//	go run ./cmd/loadgen -secret xxx -rpc 127.0.0.1:8092 -channels 100 -memberships 3 -dist zipf -group-ratio 0.2
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/glide-im/glide/im_service/client"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/rpc"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/glide-im/glide/pkg/subscription/subscription_impl"
+
+	sdk "github.com/glide-im/glide/pkg/client"
+)
+
+type options struct {
+	url         string
+	secret      string
+	gateway     string
+	clients     int
+	connectRate int
+	interval    time.Duration
+	duration    time.Duration
+	report      time.Duration
+	uidPrefix   string
+	channels    int
+	memberships int
+	dist        string
+	groupRatio  float64
+	rpcAddr     string
+	rpcName     string
+}
+
+type loadgen struct {
+	opts  options
+	keys  *gate.KeyRing
+	rpc   *client.Client
+	count counters
+
+	connect  latency
+	ack      latency
+	delivery latency
+	groupLat latency
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.url, "url", "ws://127.0.0.1:8083/ws", "websocket address of the gateway")
+	flag.StringVar(&opts.secret, "secret", "", "secret of the gateway to encrypt credentials")
+	flag.StringVar(&opts.gateway, "gateway", "node1", "id of the gateway credentials are bound to")
+	flag.IntVar(&opts.clients, "clients", 1000, "number of simulated clients")
+	flag.IntVar(&opts.connectRate, "connect-rate", 100, "clients connected per second")
+	flag.DurationVar(&opts.interval, "interval", time.Second*10, "interval of messages sent by each client, 0 to not send")
+	flag.DurationVar(&opts.duration, "duration", time.Minute*5, "duration of the load test")
+	flag.DurationVar(&opts.report, "report", time.Second*10, "interval of reports")
+	flag.StringVar(&opts.uidPrefix, "uid-prefix", "load", "prefix of uid of simulated clients")
+	flag.IntVar(&opts.channels, "channels", 0, "number of channels, 0 to send chat messages only")
+	flag.IntVar(&opts.memberships, "memberships", 1, "number of channels joined by each client")
+	flag.StringVar(&opts.dist, "dist", "uniform", "distribution of channel memberships, uniform or zipf")
+	flag.Float64Var(&opts.groupRatio, "group-ratio", 0.1, "ratio of messages sent to channels")
+	flag.StringVar(&opts.rpcAddr, "rpc", "127.0.0.1:8092", "rpc address of the im service to set up channels")
+	flag.StringVar(&opts.rpcName, "rpc-name", "im_rpc_server", "rpc name of the im service")
+	flag.Parse()
+
+	if opts.secret == "" {
+		fmt.Println("secret is required")
+		os.Exit(2)
+	}
+	if opts.dist != "uniform" && opts.dist != "zipf" {
+		fmt.Println("unknown distribution:", opts.dist)
+		os.Exit(2)
+	}
+	l := &loadgen{
+		opts: opts,
+		keys: gate.NewKeyRing(gate.DefaultKeyID, opts.secret),
+	}
+	l.keys.SetGateway(opts.gateway)
+	if opts.channels > 0 {
+		if err := l.setupChannels(); err != nil {
+			fmt.Println("set up channels failed:", err)
+			os.Exit(2)
+		}
+	}
+	l.run()
+}
+
+func (l *loadgen) uid(i int) string {
+	return l.opts.uidPrefix + strconv.Itoa(i)
+}
+
+func (l *loadgen) channel(i int) subscription.ChanID {
+	return subscription.ChanID(l.opts.uidPrefix + "_ch" + strconv.Itoa(i))
+}
+
+// memberships returns the channels joined by the client, the channels are picked by the distribution.
+func (l *loadgen) memberships(rnd *rand.Rand, zipf *rand.Zipf) []int {
+	n := l.opts.memberships
+	if n > l.opts.channels {
+		n = l.opts.channels
+	}
+	picked := map[int]bool{}
+	var chs []int
+	for len(chs) < n {
+		var ch int
+		if zipf != nil {
+			ch = int(zipf.Uint64())
+		} else {
+			ch = rnd.Intn(l.opts.channels)
+		}
+		if !picked[ch] {
+			picked[ch] = true
+			chs = append(chs, ch)
+		}
+	}
+	return chs
+}
+
+func (l *loadgen) setupChannels() error {
+	host, portStr, err := net.SplitHostPort(l.opts.rpcAddr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+	l.rpc, err = client.NewClient(&rpc.ClientOptions{Addr: host, Port: port, Name: l.opts.rpcName})
+	if err != nil {
+		return err
+	}
+	for i := 0; i < l.opts.channels; i++ {
+		ch := l.channel(i)
+		err = l.rpc.CreateChannel(ch, &subscription.ChanInfo{ID: ch})
+		if err != nil {
+			fmt.Printf("create channel %s: %v\n", ch, err)
+		}
+	}
+	return nil
+}
+
+func (l *loadgen) subscribe(i int, chs []int) {
+	perm := &subscription_impl.SubscriberOptions{Perm: subscription_impl.PermRead | subscription_impl.PermWrite}
+	for _, ch := range chs {
+		err := l.rpc.Subscribe(l.channel(ch), subscription.SubscriberID(l.uid(i)), perm)
+		if err != nil {
+			fmt.Printf("subscribe %s to %s: %v\n", l.uid(i), l.channel(ch), err)
+		}
+	}
+}
+
+func (l *loadgen) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), l.opts.duration)
+	defer cancel()
+
+	done := make(chan struct{})
+	go l.reportLoop(done)
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var zipf *rand.Zipf
+	if l.opts.dist == "zipf" && l.opts.channels > 1 {
+		zipf = rand.NewZipf(rnd, 1.1, 1, uint64(l.opts.channels-1))
+	}
+
+	connectRate := l.opts.connectRate
+	if connectRate <= 0 {
+		connectRate = 100
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(connectRate))
+	defer ticker.Stop()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < l.opts.clients; i++ {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		var chs []int
+		if l.opts.channels > 0 {
+			chs = l.memberships(rnd, zipf)
+			l.subscribe(i, chs)
+		}
+		wg.Add(1)
+		go func(i int, chs []int) {
+			defer wg.Done()
+			l.runClient(ctx, i, chs)
+		}(i, chs)
+	}
+	wg.Wait()
+	close(done)
+	l.printReport(true)
+}
+
+func (l *loadgen) runClient(ctx context.Context, i int, chs []int) {
+	uid := l.uid(i)
+	deliverSecret := "loadgen_" + uid
+	c, err := sdk.New(&sdk.Options{
+		URL: l.opts.url,
+		Credential: func() (*gate.EncryptedCredential, error) {
+			return l.keys.Encrypt(&gate.ClientAuthCredentials{
+				UserID:    uid,
+				DeviceID:  "1",
+				Timestamp: time.Now().UnixMilli(),
+				Secrets:   &gate.ClientSecrets{MessageDeliverSecret: deliverSecret},
+			})
+		},
+	})
+	if err != nil {
+		fmt.Println("create client failed:", err)
+		return
+	}
+	defer c.Close()
+
+	c.OnChatMessage(func(action messages.Action, m *messages.ChatMessage) {
+		sentAt, err := strconv.ParseInt(m.Content, 10, 64)
+		if err != nil {
+			return
+		}
+		d := time.Since(time.Unix(0, sentAt))
+		if action == messages.ActionGroupMessage {
+			atomic.AddInt64(&l.count.groupRecv, 1)
+			l.groupLat.add(d)
+		} else {
+			atomic.AddInt64(&l.count.received, 1)
+			l.delivery.add(d)
+		}
+	})
+	c.OnStateChange(func(s sdk.State, err error) {
+		if s == sdk.StateDisconnected {
+			atomic.AddInt64(&l.count.disconnects, 1)
+		}
+	})
+
+	start := time.Now()
+	if err = c.Connect(ctx); err != nil {
+		atomic.AddInt64(&l.count.connectFails, 1)
+		return
+	}
+	l.connect.add(time.Since(start))
+	atomic.AddInt64(&l.count.connected, 1)
+
+	if l.opts.interval <= 0 {
+		<-ctx.Done()
+		return
+	}
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano() + int64(i)))
+	// spread the messages of clients over the interval
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(time.Duration(rnd.Int63n(int64(l.opts.interval)))):
+	}
+	ticker := time.NewTicker(l.opts.interval)
+	defer ticker.Stop()
+	for {
+		content := strconv.FormatInt(time.Now().UnixNano(), 10)
+		if len(chs) > 0 && rnd.Float64() < l.opts.groupRatio {
+			ch := string(l.channel(chs[rnd.Intn(len(chs))]))
+			err = c.SendGroup(ch, &messages.ChatMessage{Type: 1, Content: content}, gate.TicketKey(deliverSecret, uid, ch))
+			if err != nil {
+				atomic.AddInt64(&l.count.sendFails, 1)
+			} else {
+				atomic.AddInt64(&l.count.groupSent, 1)
+			}
+		} else if l.opts.clients > 1 {
+			peer := rnd.Intn(l.opts.clients - 1)
+			if peer >= i {
+				peer++
+			}
+			to := l.uid(peer)
+			atomic.AddInt64(&l.count.sent, 1)
+			sendAt := time.Now()
+			_, err = c.SendChat(ctx, to, &messages.ChatMessage{Type: 1, Content: content}, gate.TicketKey(deliverSecret, uid, to))
+			if err == nil {
+				atomic.AddInt64(&l.count.acked, 1)
+				l.ack.add(time.Since(sendAt))
+			} else if ctx.Err() == nil {
+				atomic.AddInt64(&l.count.sendFails, 1)
+			} else {
+				atomic.AddInt64(&l.count.sent, -1)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *loadgen) reportLoop(done chan struct{}) {
+	ticker := time.NewTicker(l.opts.report)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			l.printReport(false)
+		}
+	}
+}
+
+func (l *loadgen) printReport(final bool) {
+	c := l.count.load()
+	title := "report"
+	if final {
+		title = "final report"
+	}
+	fmt.Printf("--- %s %s ---\n", title, time.Now().Format(time.RFC3339))
+	fmt.Printf("connected: %d, connect errors: %d (%.4f), disconnects: %d\n",
+		c.connected, c.connectFails, rate(c.connectFails, c.connected+c.connectFails), c.disconnects)
+	fmt.Printf("chat sent: %d, acked: %d, received: %d, send errors: %d (%.4f)\n",
+		c.sent, c.acked, c.received, c.sendFails, rate(c.sendFails, c.sent+c.groupSent))
+	if l.opts.channels > 0 {
+		fmt.Printf("group sent: %d, received: %d\n", c.groupSent, c.groupRecv)
+	}
+	// latency percentiles of the samples since the last report, or all samples of the final report
+	fmt.Println(formatLatency("connect", l.connect.snapshot(final)))
+	fmt.Println(formatLatency("ack", l.ack.snapshot(final)))
+	fmt.Println(formatLatency("delivery", l.delivery.snapshot(final)))
+	if l.opts.channels > 0 {
+		fmt.Println(formatLatency("group delivery", l.groupLat.snapshot(final)))
+	}
+}
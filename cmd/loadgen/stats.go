@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latency records the samples of latency to report percentiles.
+type latency struct {
+	mu     sync.Mutex
+	window []time.Duration
+	all    []time.Duration
+}
+
+func (l *latency) add(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.window = append(l.window, d)
+	l.all = append(l.all, d)
+}
+
+// snapshot returns the sorted samples since the last snapshot, or all samples recorded if all is true.
+func (l *latency) snapshot(all bool) []time.Duration {
+	l.mu.Lock()
+	var s []time.Duration
+	if all {
+		s = make([]time.Duration, len(l.all))
+		copy(s, l.all)
+	} else {
+		s = l.window
+	}
+	l.window = nil
+	l.mu.Unlock()
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func formatLatency(name string, sorted []time.Duration) string {
+	if len(sorted) == 0 {
+		return fmt.Sprintf("%s: -", name)
+	}
+	return fmt.Sprintf("%s: n=%d p50=%s p90=%s p99=%s max=%s", name, len(sorted),
+		percentile(sorted, 0.5), percentile(sorted, 0.9), percentile(sorted, 0.99), sorted[len(sorted)-1])
+}
+
+// counters the counts of the load test.
+type counters struct {
+	connected    int64
+	connectFails int64
+	disconnects  int64
+	sent         int64
+	acked        int64
+	sendFails    int64
+	groupSent    int64
+	received     int64
+	groupRecv    int64
+}
+
+func (c *counters) load() counters {
+	return counters{
+		connected:    atomic.LoadInt64(&c.connected),
+		connectFails: atomic.LoadInt64(&c.connectFails),
+		disconnects:  atomic.LoadInt64(&c.disconnects),
+		sent:         atomic.LoadInt64(&c.sent),
+		acked:        atomic.LoadInt64(&c.acked),
+		sendFails:    atomic.LoadInt64(&c.sendFails),
+		groupSent:    atomic.LoadInt64(&c.groupSent),
+		received:     atomic.LoadInt64(&c.received),
+		groupRecv:    atomic.LoadInt64(&c.groupRecv),
+	}
+}
+
+func rate(n int64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
@@ -0,0 +1,205 @@
+// Command soak runs simulated clients against a gateway with faults injected, to validate the resilience of the
+// gateway before releases, it exits with 1 if the ratio of messages acked is lower than expected.
+//
+//	go run ./cmd/soak -clients 200 -duration 5m -drop 0.001 -corrupt 0.001 -store-error 0.01
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/internal/message_store_db"
+	"github.com/glide-im/glide/internal/world_channel"
+	"github.com/glide-im/glide/pkg/chaos"
+	"github.com/glide-im/glide/pkg/client"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/messaging"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription/subscription_impl"
+)
+
+type result struct {
+	sent     int64
+	acked    int64
+	failed   int64
+	received int64
+}
+
+func main() {
+	clients := flag.Int("clients", 100, "number of simulated clients")
+	duration := flag.Duration("duration", time.Minute, "duration of the soak test")
+	rate := flag.Duration("rate", time.Second, "interval of messages sent by each client")
+	port := flag.Int("port", 18083, "port of the gateway")
+	secret := flag.String("secret", "soak_test_secret", "secret of the gateway credentials")
+	drop := flag.Float64("drop", 0, "probability of dropping the connection on each read or write")
+	corrupt := flag.Float64("corrupt", 0, "probability of corrupting the frame read or written")
+	writeDelay := flag.Duration("write-delay", 0, "max random delay of connection writes")
+	storeDelay := flag.Duration("store-delay", 0, "max random delay of store operations")
+	storeError := flag.Float64("store-error", 0, "probability of failing store operations")
+	seed := flag.Int64("seed", 0, "seed of faults, 0 to use the current time")
+	minAck := flag.Float64("min-ack", 0.95, "min ratio of messages acked to pass")
+	flag.Parse()
+
+	injector := chaos.NewInjector(chaos.Faults{
+		DropRate:       *drop,
+		CorruptRate:    *corrupt,
+		WriteDelay:     *writeDelay,
+		StoreDelay:     *storeDelay,
+		StoreErrorRate: *storeError,
+		Seed:           *seed,
+	})
+
+	gateway, err := runGateway(*port, *secret, injector)
+	if err != nil {
+		fmt.Println("start gateway failed:", err)
+		os.Exit(2)
+	}
+
+	res := &result{}
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	url := fmt.Sprintf("ws://127.0.0.1:%d/ws", *port)
+	wg := sync.WaitGroup{}
+	for i := 0; i < *clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			runClient(ctx, gateway, url, i, *rate, res)
+		}(i)
+	}
+	wg.Wait()
+
+	stats := injector.Stats()
+	sent := atomic.LoadInt64(&res.sent)
+	acked := atomic.LoadInt64(&res.acked)
+	ratio := 1.0
+	if sent > 0 {
+		ratio = float64(acked) / float64(sent)
+	}
+	fmt.Printf("clients: %d, duration: %s\n", *clients, *duration)
+	fmt.Printf("sent: %d, acked: %d, failed: %d, received: %d, ack ratio: %.4f\n",
+		sent, acked, atomic.LoadInt64(&res.failed), atomic.LoadInt64(&res.received), ratio)
+	fmt.Printf("faults: drops=%d corruptions=%d delays=%d store_errors=%d\n",
+		stats.Drops, stats.Corruptions, stats.Delays, stats.StoreErrors)
+	if ratio < *minAck {
+		fmt.Printf("FAIL: ack ratio %.4f is lower than %.4f\n", ratio, *minAck)
+		os.Exit(1)
+	}
+	fmt.Println("PASS")
+}
+
+func runGateway(port int, secret string, injector *chaos.Injector) (*gate.WebsocketGatewayServer, error) {
+	config.Common = &config.CommonConf{}
+	gateway := gate.NewWebsocketServer("soak", "127.0.0.1", port, secret)
+	gateway.SetConnWrapper(injector.WrapConnection)
+
+	handler, err := messaging.NewHandlerWithOptions(gateway, &messaging.MessageHandlerOptions{
+		MessageStore: injector.WrapStore(&store.IdleMessageStore{}),
+		NotifyOnErr:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sStore := &message_store_db.IdleSubscriptionStore{}
+	subscription := subscription_impl.NewSubscription(sStore, sStore)
+	subscription.SetGateInterface(gateway)
+	handler.SetSubscription(subscription)
+	handler.SetGate(gateway)
+	if err = world_channel.EnableWorldChannel(subscription_impl.NewSubscribeWrap(subscription)); err != nil {
+		return nil, err
+	}
+	gateway.SetMessageHandler(func(cliInfo *gate.Info, message *messages.GlideMessage) {
+		_ = handler.Handle(cliInfo, message)
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- gateway.Run()
+	}()
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	for i := 0; i < 50; i++ {
+		select {
+		case err = <-errCh:
+			return nil, err
+		default:
+		}
+		c, err := net.Dial("tcp", addr)
+		if err == nil {
+			_ = c.Close()
+			return gateway, nil
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	return nil, fmt.Errorf("gateway is not listening on %s", addr)
+}
+
+func runClient(ctx context.Context, gateway *gate.WebsocketGatewayServer, url string, i int, rate time.Duration, res *result) {
+	uid := "soak" + strconv.Itoa(i)
+	peer := "soak" + strconv.Itoa(i^1)
+	deliverSecret := "soak_deliver" + strconv.Itoa(i)
+
+	c, err := client.New(&client.Options{
+		URL: url,
+		Credential: func() (*gate.EncryptedCredential, error) {
+			return gateway.KeyRing().Encrypt(&gate.ClientAuthCredentials{
+				UserID:    uid,
+				DeviceID:  "1",
+				Timestamp: time.Now().UnixMilli(),
+				Secrets:   &gate.ClientSecrets{MessageDeliverSecret: deliverSecret},
+			})
+		},
+		MinBackoff: time.Millisecond * 100,
+		MaxBackoff: time.Second * 2,
+	})
+	if err != nil {
+		fmt.Println("create client failed:", err)
+		return
+	}
+	defer c.Close()
+	c.OnChatMessage(func(action messages.Action, m *messages.ChatMessage) {
+		atomic.AddInt64(&res.received, 1)
+	})
+
+	// the connection may be dropped by faults injected during handshake
+	for {
+		if err = c.Connect(ctx); err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Millisecond * 200):
+		}
+	}
+
+	ticker := time.NewTicker(rate)
+	defer ticker.Stop()
+	ticket := gate.TicketKey(deliverSecret, uid, peer)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		atomic.AddInt64(&res.sent, 1)
+		_, err = c.SendChat(ctx, peer, &messages.ChatMessage{Type: 1, Content: "soak"}, ticket)
+		if err == nil {
+			atomic.AddInt64(&res.acked, 1)
+		} else if ctx.Err() == nil {
+			atomic.AddInt64(&res.failed, 1)
+		} else {
+			// interrupted by the end of test, not counted
+			atomic.AddInt64(&res.sent, -1)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/spf13/viper"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+)
+
+var log = logger.Named("config")
+
+// ChangeEvent the section of config changed by reloading, Old and New are the section before and after, such as
+// *LogConf.
+type ChangeEvent struct {
+	Section string
+	Old     interface{}
+	New     interface{}
+}
+
+var (
+	current *Config
+
+	reloadMu    sync.Mutex
+	subscribers = map[string][]func(e ChangeEvent){}
+)
+
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("Log.Level", "debug")
+	v.SetDefault("WsServer.SendQueueSize", 100)
+	v.SetDefault("WsServer.HeartbeatInterval", 30)
+}
+
+// Validate returns error if the required sections are missing or values are invalid.
+func (c *Config) Validate() error {
+	if c.CommonConf == nil {
+		return errors.New("CommonConf is nil")
+	}
+	if c.MySql == nil {
+		return errors.New("mysql config is nil")
+	}
+	if c.WsServer == nil {
+		return errors.New("ws server config is nil")
+	}
+	if c.IMRpcServer == nil {
+		return errors.New("im rpc server config is nil")
+	}
+	if c.WsServer.SendQueueSize < 0 || c.WsServer.HeartbeatInterval < 0 {
+		return errors.New("WsServer.SendQueueSize and WsServer.HeartbeatInterval must not be negative")
+	}
+	switch c.WsServer.SendQueueOverflow {
+	case "", "drop_new", "drop_oldest", "block", "disconnect":
+	default:
+		return errors.New("unknown WsServer.SendQueueOverflow: " + c.WsServer.SendQueueOverflow)
+	}
+	switch c.WsServer.MessageSign {
+	case "", "optional", "required":
+	default:
+		return errors.New("unknown WsServer.MessageSign: " + c.WsServer.MessageSign)
+	}
+	for name, value := range c.CommonConf.RateLimits {
+		if value < 0 {
+			return fmt.Errorf("rate limit %s must not be negative", name)
+		}
+	}
+	for _, svc := range c.Services {
+		switch svc.Strategy {
+		case "", "round_robin", "least_active", "sticky":
+		default:
+			return errors.New("unknown strategy of service " + svc.ID + ": " + svc.Strategy)
+		}
+	}
+	if c.Degrade != nil {
+		for _, f := range c.Degrade.Disable {
+			switch f {
+			case "offline", "persistence", "history":
+			default:
+				return errors.New("unknown Degrade.Disable feature: " + f)
+			}
+		}
+	}
+	if c.Retention != nil {
+		for _, p := range c.Retention.Policies {
+			if (p.Tenant == "") == (p.Channel == "") {
+				return errors.New("either Tenant or Channel of retention policy is required")
+			}
+			if p.Tenant != "" && c.Retention.TenantSeparator == "" {
+				return errors.New("Retention.TenantSeparator is required by tenant policy " + p.Tenant)
+			}
+		}
+	}
+	for _, b := range c.Bots {
+		if b.UID == "" || b.URL == "" {
+			return errors.New("UID and URL of bot are required")
+		}
+	}
+	if c.RemoteConfig != nil && c.RemoteConfig.MaxBackoff < c.RemoteConfig.MinBackoff {
+		return errors.New("RemoteConfig.MaxBackoff must not be less than MinBackoff")
+	}
+	if c.Federation != nil && c.Federation.Enable {
+		if c.Federation.Region == "" {
+			return errors.New("Federation.Region is required")
+		}
+		for _, p := range c.Federation.Peers {
+			if p.Region == "" || p.URL == "" {
+				return errors.New("Federation.Peers requires Region and URL")
+			}
+		}
+	}
+	if c.Log != nil {
+		switch c.Log.Level {
+		case "", "debug", "info", "warn", "error":
+		default:
+			return errors.New("unknown Log.Level: " + c.Log.Level)
+		}
+	}
+	return nil
+}
+
+// Subscribe registers fn called when the section of config is changed by reloading, the section is the field name of
+// Config, such as Log, WsServer and CommonConf.
+func Subscribe(section string, fn func(e ChangeEvent)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	subscribers[section] = append(subscribers[section], fn)
+}
+
+// Watch reloads the config when the config file is changed or the process receives SIGHUP. Only sections with
+// subscribers take effect at runtime, others require restarting. The invalid config is ignored and the current
+// config is kept.
+func Watch() {
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		log.I("config file %s changed, reloading", e.Name)
+		if err := reload(viper.GetViper()); err != nil {
+			log.E("reload config error: %v", err)
+		}
+	})
+	viper.WatchConfig()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	go func() {
+		for range sig {
+			log.I("SIGHUP received, reloading config")
+			err := viper.ReadInConfig()
+			if err == nil {
+				err = reload(viper.GetViper())
+			}
+			if err != nil {
+				log.E("reload config error: %v", err)
+			}
+		}
+	}()
+}
+
+// reload loads the config and notifies subscribers of changed sections.
+func reload(v *viper.Viper) error {
+	c, err := load(v)
+	if err != nil {
+		return err
+	}
+
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	events := diff(current, c)
+	apply(c)
+	for _, e := range events {
+		log.I("config section %s changed", e.Section)
+		for _, fn := range subscribers[e.Section] {
+			fn(e)
+		}
+	}
+	return nil
+}
+
+// diff returns the sections changed from old to new.
+func diff(old *Config, new_ *Config) []ChangeEvent {
+	if old == nil {
+		old = &Config{}
+	}
+	var events []ChangeEvent
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(new_).Elem()
+	for i := 0; i < ov.NumField(); i++ {
+		o, n := ov.Field(i).Interface(), nv.Field(i).Interface()
+		if !reflect.DeepEqual(o, n) {
+			events = append(events, ChangeEvent{Section: ov.Type().Field(i).Name, Old: o, New: n})
+		}
+	}
+	return events
+}
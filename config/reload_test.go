@@ -0,0 +1,61 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+[CommonConf]
+SecretKey = "secret"
+[CommonConf.RateLimits]
+state_message_interval_ms = 1000
+[MySql]
+Host = "localhost"
+[WsServer]
+Port = 8083
+[IMRpcServer]
+Port = 8092
+[Log]
+Level = "%s"
+`
+
+func newTestViper(t *testing.T, level string) *viper.Viper {
+	v := viper.New()
+	v.SetConfigType("toml")
+	setDefaults(v)
+	assert.NoError(t, v.ReadConfig(strings.NewReader(strings.Replace(testConfig, "%s", level, 1))))
+	return v
+}
+
+func TestLoad(t *testing.T) {
+	c, err := load(newTestViper(t, "info"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(30), c.WsServer.HeartbeatInterval)
+	assert.Equal(t, 100, c.WsServer.SendQueueSize)
+	assert.Equal(t, int64(1000), c.CommonConf.RateLimits["state_message_interval_ms"])
+
+	_, err = load(newTestViper(t, "verbose"))
+	assert.Error(t, err)
+}
+
+func TestReload(t *testing.T) {
+	assert.NoError(t, reload(newTestViper(t, "info")))
+
+	var events []ChangeEvent
+	Subscribe("Log", func(e ChangeEvent) { events = append(events, e) })
+	Subscribe("WsServer", func(e ChangeEvent) { events = append(events, e) })
+
+	assert.NoError(t, reload(newTestViper(t, "warn")))
+	assert.Len(t, events, 1)
+	assert.Equal(t, "info", events[0].Old.(*LogConf).Level)
+	assert.Equal(t, "warn", events[0].New.(*LogConf).Level)
+	assert.Equal(t, "warn", Log.Level)
+
+	// the invalid config is ignored.
+	assert.Error(t, reload(newTestViper(t, "verbose")))
+	assert.Len(t, events, 1)
+	assert.Equal(t, "warn", Log.Level)
+}
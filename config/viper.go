@@ -1,20 +1,132 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"github.com/spf13/viper"
+	"strings"
+)
 
 var (
-	Common    *CommonConf
-	MySql     *MySqlConf
-	WsServer  *WsServerConf
-	IMService *IMRpcServerConf
-	Redis     *RedisConf
-	Kafka     *KafkaConf
+	Common     *CommonConf
+	MySql      *MySqlConf
+	WsServer   *WsServerConf
+	IMService  *IMRpcServerConf
+	Business   *BusinessConf
+	Discovery  *DiscoveryConf
+	Redis      *RedisConf
+	Kafka      *KafkaConf
+	MongoDB    *MongoDBConf
+	Log        *LogConf
+	Admin      *AdminConf
+	Push       *PushConf
+	Media      *MediaConf
+	Webhook    *WebhookConf
+	Moderation *ModerationConf
+	Audit      *AuditConf
+	Archive    *ArchiveConf
+	Retention  *RetentionConf
+	Metering   *MeteringConf
+	Tap        *TapConf
+	Degrade    *DegradeConf
+	// RemoteConfig the client config pushed by gateways.
+	RemoteConfig *RemoteConfigConf
+	// Federation the exchange of messages with clusters of other regions.
+	Federation *FederationConf
+	// FilterRules the message filter rules loaded at startup.
+	FilterRules []FilterRuleConf
+	// Services the service accounts routed to agents.
+	Services []ServiceConf
+	// Bots the bots run in process.
+	Bots []BotConf
 )
 
 type CommonConf struct {
 	StoreOfflineMessage bool
 	StoreMessageHistory bool
 	SecretKey           string
+	// SecretKeys the additional keys by key id to decrypt client credentials, used to rotate SecretKey.
+	SecretKeys map[string]string
+	// RejectLegacyCredentials rejects the AES-CBC credentials, the AES-GCM credentials are accepted only.
+	RejectLegacyCredentials bool
+	// TicketProviderURL the url to fetch the ticket secret of clients authenticating without one, see
+	// gate.NewHTTPTicketProvider, empty to not fetch.
+	TicketProviderURL string
+	// MessageStoreDriver the database to store message history, mysql or mongodb, default mysql.
+	MessageStoreDriver string
+	// StoreWriteBehind true to write message history asynchronously in batch.
+	StoreWriteBehind bool
+	// StoreWALDir the write ahead log directory of write behind store, empty to disable.
+	StoreWALDir string
+	// StoreRetries the max count of retries of failed store calls, default 2, -1 to disable.
+	StoreRetries int
+	// StoreTimeout the max milliseconds of a store call, default 3000.
+	StoreTimeout int
+	// StoreBreakerFailures the count of consecutive failed store calls opens the circuit breaker, default 5.
+	StoreBreakerFailures int
+	// StoreBreakerOpen the seconds the circuit breaker keeps open, default 10.
+	StoreBreakerOpen int
+	// StoreCache the read-through cache of recent history and read cursors: memory or redis, empty to disable.
+	StoreCache string
+	// StoreCacheSize the max count of keys cached in memory, default 10000.
+	StoreCacheSize int
+	// StoreCacheTTL the seconds a value is cached, default 60.
+	StoreCacheTTL int
+	// MetricsAddr the address serves prometheus metrics at /metrics, empty to disable.
+	MetricsAddr string
+	// HealthAddr the address serves health endpoints /healthz and /readyz, empty to disable.
+	HealthAddr string
+	// DiagnosticsAddr the address serves pprof and the diagnostic dump at /debug/, empty to disable.
+	DiagnosticsAddr string
+	// DiagnosticsToken the bearer token required by the diagnostics server, empty to not authenticate.
+	DiagnosticsToken string
+	// DiagnosticsQueueThreshold the min send queue depth of clients in the dump, default 100.
+	DiagnosticsQueueThreshold int64
+	// WorkerID snowflake node id of this node, -1 to acquire from redis.
+	WorkerID int64
+	// MessageWorkers the count of shared workers handle messages in order of each conversation, 0 to disable.
+	MessageWorkers int
+	// MessageWorkerQueueSize the capacity of task queue of each message worker.
+	MessageWorkerQueueSize int
+	// ConversationQueueSize the max count of messages of a conversation waiting to be handled when workers disabled.
+	ConversationQueueSize int
+	// DeviceRoute the default policy to deliver chat messages to devices of receiver: all or last_active, default all.
+	DeviceRoute string
+	// DeviceRouteDevices the device types to deliver chat messages, empty for all device types.
+	DeviceRouteDevices []string
+	// CallRingTimeout the seconds the invited call is ended if not answered.
+	CallRingTimeout int64
+	// BroadcastRate the max count of broadcast messages delivered per second.
+	BroadcastRate int
+	// DisabledFeatures the features of protocol and capabilities of clients disabled cluster-wide, such as batch and
+	// reactions, see messages.SetDisabledFeatures, reloadable.
+	DisabledFeatures []string
+	// RateLimits the rate limits of message handler by name, such as state_message_interval_ms, reloadable.
+	RateLimits map[string]int64
+	// QuotaProviderURL the url to fetch quotas of users and channels, see messaging.NewHTTPQuotaProvider, empty to
+	// apply quotas in RateLimits only.
+	QuotaProviderURL string
+	// TransformerURL the url to transform chat messages for each recipient device by the locale of device before
+	// delivery, such as machine translation, see messaging.NewHTTPTransformer, empty to disable.
+	TransformerURL string
+	// NotifyExpired notifies the sender when the message with TTL expires in the offline queue.
+	NotifyExpired bool
+	// DeadLetter the sink of undeliverable messages: memory, or store for the message store, such as the database or
+	// the Kafka topic, empty to disable.
+	DeadLetter string
+	// ScheduleMessage true to deliver messages with deliver_at in the future at the time.
+	ScheduleMessage bool
+	// ScheduleMaxDelay the max seconds a message can be scheduled ahead.
+	ScheduleMaxDelay int64
+	// BlockList true to consult the block list in redis before delivering P2P messages.
+	BlockList bool
+	// DropBlocked drops messages to the receiver blocked the sender silently instead of rejecting.
+	DropBlocked bool
+	// RequireContact rejects P2P messages between non-contacts in redis unless sent with the bypass ticket.
+	RequireContact bool
+	// ReadCountInterval the milliseconds read counts of the latest channel messages are pushed to senders, 0 to
+	// disable.
+	ReadCountInterval int64
+	// MaxReaderListMembers the max count of subscribers of channels whose reader list can be queried.
+	MaxReaderListMembers int
 }
 
 type WsServerConf struct {
@@ -22,6 +134,107 @@ type WsServerConf struct {
 	Addr      string
 	Port      int
 	JwtSecret string
+	// SendQueueSize the capacity of each client send queue, default 100, reloadable for new clients.
+	SendQueueSize int
+	// SendQueueOverflow the policy when send queue is full: drop_new, drop_oldest, block or disconnect.
+	SendQueueOverflow string
+	// SendQueueTimeout the max milliseconds to block when SendQueueOverflow is block.
+	SendQueueTimeout int64
+	// HeartbeatInterval the seconds of client and server heartbeat, default 30, reloadable for new clients.
+	HeartbeatInterval int64
+	// SlowClientStall the milliseconds a write blocked to flag the client slow, 0 to disable the slow client watchdog.
+	SlowClientStall int64
+	// SlowClientResidence the milliseconds a message waited in send queue to flag the client slow, 0 to disable.
+	SlowClientResidence int64
+	// SlowClientEvict true to disconnect the slow client.
+	SlowClientEvict bool
+	// MaxMessageSize the max size in bytes of a message from client, default 1MB, the larger message must be sent in
+	// chunks.
+	MaxMessageSize int64
+	// MaxChunkedMessageSize the max size in bytes of a message sent in chunks, default 16MB.
+	MaxChunkedMessageSize int64
+	// RejectUnknownAction true to reject messages of actions not registered instead of passing to handlers.
+	RejectUnknownAction bool
+	// Netpoll true to serve connections by epoll instead of goroutines of each connection, linux only.
+	Netpoll bool
+	// NetpollPollers the count of epoll instances, default the count of CPU.
+	NetpollPollers int
+	// NetpollWorkers the count of goroutines read messages from readable connections, default 1024.
+	NetpollWorkers int
+	// TLSCert and TLSKey the certificate and private key files to serve over TLS, empty to disable TLS.
+	TLSCert string
+	TLSKey  string
+	// TLSClientCA the CA file to verify client certificates, empty to disable mutual TLS.
+	TLSClientCA string
+	// TLSRequireClientCert true to reject clients without a valid certificate.
+	TLSRequireClientCert bool
+	// TLSCertAuth true to log in clients by the common name of client certificate.
+	TLSCertAuth bool
+	// TLSCertUsers maps common name of client certificate to uid, the common name is used as uid if empty.
+	TLSCertUsers map[string]string
+	// LongPolling true to serve clients by HTTP long-polling or server-sent events on the websocket port, for clients
+	// behind proxies which break websocket.
+	LongPolling bool
+	// LongPollingTimeout the seconds a receive request waits for messages, default 25.
+	LongPollingTimeout int64
+	// AllowedOrigins the Origin of browsers allowed to upgrade, such as https://*.example.com, all are allowed if empty.
+	AllowedOrigins []string
+	// RequireSubprotocol true to reject upgrade requests without a supported subprotocol, such as glide.v1.json.
+	RequireSubprotocol bool
+	// AllowIPs the ip or CIDR list to admit connections, connections from others are rejected if not empty.
+	AllowIPs []string
+	// DenyIPs the ip or CIDR list to reject connections.
+	DenyIPs []string
+	// AllowCountries and DenyCountries the country codes to admit or reject connections, require a GeoIP lookup.
+	AllowCountries []string
+	DenyCountries  []string
+	// DenyASNs the autonomous system numbers to reject connections, require a GeoIP lookup.
+	DenyASNs []uint
+	// Challenge the anti-abuse challenge clients must solve before authenticate: pow or captcha, empty to disable.
+	Challenge string
+	// ChallengeDifficulty the leading zero bits of proof-of-work challenge, default 20.
+	ChallengeDifficulty int
+	// ChallengeTTL the seconds a challenge expires after issued, default 60.
+	ChallengeTTL int64
+	// CaptchaVerifyURL the siteverify api of CAPTCHA provider, such as https://hcaptcha.com/siteverify.
+	CaptchaVerifyURL string
+	CaptchaSiteKey   string
+	CaptchaSecret    string
+	// AnomalyDetectorURL the url to score connections by fingerprint, see gate.NewHTTPAnomalyDetector, suspicious
+	// connections are stepped up by the Challenge or disconnected, empty to disable.
+	AnomalyDetectorURL string
+	// TicketReplayWindow the seconds of clock difference allowed by the signed message ticket with nonce and
+	// timestamp, 0 to accept the legacy ticket only. Used nonces are shared by nodes in redis if configured.
+	TicketReplayWindow int64
+	// TicketRequireSigned true to reject the legacy message ticket which can be replayed.
+	TicketRequireSigned bool
+	// MessageSign the verification of message sign, see gate.SignMessage: optional verifies signed messages only,
+	// required rejects messages without sign, empty to not verify.
+	MessageSign string
+	// MaxConnections the max count of concurrent connections of the gateway, 0 is unlimited.
+	MaxConnections int
+	// MaxConnectionsPerUID the max count of authenticated connections of a user, 0 is unlimited.
+	MaxConnectionsPerUID int
+	// MaxOutboundBytes the max outbound bytes per second of the gateway to admit new connections, 0 is unlimited.
+	MaxOutboundBytes int64
+	// QuotaRetryAfter the seconds the client rejected by quota should retry after, default 30.
+	QuotaRetryAfter int64
+	// ResumeWindow the seconds the session of the client disconnected is kept to resume, 0 to disable resume.
+	ResumeWindow int64
+	// ResumeBufferSize the max count of messages buffered for the session disconnected, default 100.
+	ResumeBufferSize int
+	// ResumeReplica true to replicate resumable sessions to Redis, the client resumes on another gateway with the token
+	// after the gateway it connected crashed, Redis is required.
+	ResumeReplica bool
+	// LoginNotify true to notify devices of the user with the device type, ip and time when a device logs in, so
+	// users can detect the account compromised.
+	LoginNotify bool
+	// EgressRates the egress KB per second of clients by device type, "*" for devices not listed, 0 is uncapped,
+	// reloadable.
+	EgressRates map[string]int64
+	// LocaleDir the directory of translations of texts generated by the server, files are named by the locale, such as
+	// zh-CN.json, the content is the object of code to translation, builtin translations are used if it's empty.
+	LocaleDir string
 }
 
 type ApiHttpConf struct {
@@ -37,6 +250,37 @@ type IMRpcServerConf struct {
 	Name    string
 }
 
+type BusinessConf struct {
+	// Addr the address of business gRPC service, such as "0.0.0.0:8093", empty to disable.
+	Addr string
+	// Token the bearer token business services must carry in metadata `authorization`, it can be empty only if Addr is
+	// a loopback address, such as "127.0.0.1:8093".
+	Token string
+	// RestAddr the address of the HTTP/JSON facade of business service, such as "0.0.0.0:8094", empty to disable.
+	RestAddr string
+	// APIKeys the keys to access the HTTP/JSON facade in header `X-API-Key`, required if RestAddr is set.
+	APIKeys []string
+}
+
+type DiscoveryConf struct {
+	// Backend etcd or consul, empty to disable.
+	Backend string
+	// Endpoints the etcd endpoints, or the Consul agent address.
+	Endpoints []string
+	// Prefix the key prefix in etcd, default /glide/discovery/.
+	Prefix string
+	// TTL the seconds the gateway is removed after it stops renewing, default 15.
+	TTL int64
+	// Advertise the address clients connect to this gateway, default ws://WsServer.Addr:WsServer.Port/ws.
+	Advertise string
+	// Capacity the max count of connections of this gateway, used to select less loaded gateways.
+	Capacity int64
+	// Region the region this gateway is deployed in, clients with the region hint prefer gateways in the region.
+	Region string
+	// AllocatorAddr the address serves GET /connect allocating gateways to clients, empty to disable.
+	AllocatorAddr string
+}
+
 type KafkaConf struct {
 	Address []string
 }
@@ -48,6 +292,283 @@ type MySqlConf struct {
 	Password string
 	Db       string
 	Charset  string
+	// MaxOpenConns the max count of open connections, 0 for unlimited.
+	MaxOpenConns int
+	// MaxIdleConns the max count of idle connections, default 2.
+	MaxIdleConns int
+	// ConnMaxLifetime the max seconds a connection is reused, 0 for unlimited.
+	ConnMaxLifetime int
+	// Timeout the seconds of dial, read and write timeout of connections, 0 for the default of driver.
+	Timeout int
+}
+
+type LogConf struct {
+	// Level debug, info, warn or error, default debug.
+	Level string
+	// Format console or json, default console.
+	Format string
+	// File the log file path, logs are written to stdout if empty.
+	File string
+	// MaxSize the max size in MB of log file before rotated.
+	MaxSize int64
+	// MaxBackups the max count of rotated log files to keep.
+	MaxBackups int
+}
+
+type AdminConf struct {
+	// Addr the address of admin http server, empty to disable.
+	Addr string
+	// Token the bearer token to access admin api.
+	Token string
+}
+
+type PushConf struct {
+	// Enable true to push chat messages to devices of offline receivers.
+	Enable bool
+	// TitleTemplate and BodyTemplate the text/template of notification, see push.TemplateData.
+	TitleTemplate string
+	BodyTemplate  string
+	Sound         string
+
+	// APNsKeyFile the path of .p8 APNs auth key, empty to disable APNs.
+	APNsKeyFile string
+	APNsKeyID   string
+	APNsTeamID  string
+	APNsTopic   string
+	APNsSandbox bool
+
+	// FCMCredentialsFile the path of firebase service account json file, empty to disable FCM.
+	FCMCredentialsFile string
+}
+
+type MediaConf struct {
+	// Enable true to issue upload urls of media messages by api.upload.token.
+	Enable bool
+	// Endpoint the url of S3 compatible storage, such as AWS S3, MinIO or OSS.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle true to address the bucket by path, MinIO usually requires.
+	PathStyle bool
+	// PublicURL the url prefix to download media, such as the CDN domain, empty to use the bucket url.
+	PublicURL string
+	// MaxSize the max size in bytes of a media.
+	MaxSize int64
+	// AllowedMimes the allowed mime types, `image/` matches all image types, empty to allow all.
+	AllowedMimes []string
+	// Expires the seconds the upload url is valid.
+	Expires int64
+}
+
+type WebhookConf struct {
+	// URLs the urls events are posted to, empty to disable.
+	URLs []string
+	// Secret the key to sign events.
+	Secret string
+	// Events the event types to deliver, empty to deliver all.
+	Events []string
+	// MaxRetries the max retry times of failed delivery.
+	MaxRetries int
+}
+
+type AuditConf struct {
+	// File the path of audit log file, empty to disable.
+	File string
+	// Syslog true to write audit log to syslog.
+	Syslog bool
+	// SyslogNetwork and SyslogAddr the syslog daemon, empty for the local daemon.
+	SyslogNetwork string
+	SyslogAddr    string
+	// KafkaTopic the topic of audit log produced to Kafka.Address, empty to disable.
+	KafkaTopic string
+}
+
+type ArchiveConf struct {
+	// Enable true to archive message history older than Days to the S3 compatible storage, run on one node only.
+	Enable bool
+	// Days messages sent before Days ago are archived.
+	Days int
+	// Interval the seconds between two runs of archive.
+	Interval int64
+	// BatchSize the max count of messages exported at a time.
+	BatchSize int
+	// Prefix the prefix of archive object keys.
+	Prefix    string
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+}
+
+type RetentionConf struct {
+	// Enable true to purge messages exceeding retention policies, run on one node only.
+	Enable bool
+	// Days and Messages the default policy, messages sent before Days ago and messages except the latest Messages
+	// messages of a conversation are purged, 0 for unlimited, reloadable.
+	Days     int
+	Messages int64
+	// Interval the seconds between two sweeps of all conversations.
+	Interval int64
+	// BatchSize the count of conversations listed and the max count of messages purged at a time.
+	BatchSize int
+	// RateLimit the max count of messages purged per second, -1 for unlimited.
+	RateLimit int
+	// TenantSeparator the tenant of conversation is the part of uid or channel id before the separator, empty to
+	// ignore tenant policies.
+	TenantSeparator string
+	// Policies the policies of tenants and channels, reloadable.
+	Policies []RetentionPolicyConf
+}
+
+// RetentionPolicyConf the retention policy of a tenant or a channel, see retention.Policy.
+type RetentionPolicyConf struct {
+	// Tenant or Channel the policy applies to, the channel policy takes precedence.
+	Tenant   string
+	Channel  string
+	Days     int
+	Messages int64
+}
+
+// RemoteConfigConf the config pushed to clients on authenticate and on change, see messages.RemoteConfig, reloadable.
+type RemoteConfigConf struct {
+	// HeartbeatInterval the seconds between two heartbeats of clients, 0 to keep the client default.
+	HeartbeatInterval int
+	// MinBackoff and MaxBackoff the range of reconnect backoff in milliseconds, 0 to keep the client default.
+	MinBackoff int64
+	MaxBackoff int64
+	// MediaUploadURL the url clients upload media files to.
+	MediaUploadURL string
+	// Features the client side feature flags, keys are lowercased by viper.
+	Features map[string]bool
+	// Extra the custom values of the application, keys are lowercased by viper.
+	Extra map[string]string
+}
+
+type FederationConf struct {
+	// Enable true to forward messages to users homed in other regions.
+	Enable bool
+	// Region the region of this cluster.
+	Region string
+	// Addr the address to receive messages forwarded by other regions.
+	Addr string
+	// Secret the secret shared by regions to sign messages forwarded, required if Addr or Peers is set.
+	Secret string
+	// MaxHops the max count of regions a message goes through.
+	MaxHops int
+	// RegistryURL the url to look up home regions of users from the business service, see
+	// federation.NewHTTPRegistry, empty to home all users in this region.
+	RegistryURL string
+	// Peers the federation endpoints of other regions.
+	Peers []FederationPeerConf
+}
+
+type FederationPeerConf struct {
+	Region string
+	URL    string
+}
+
+type MeteringConf struct {
+	// Enable true to meter usages of users.
+	Enable bool
+	// Interval the seconds between two flushes of usages.
+	Interval int64
+	// TenantSeparator the tenant is the part of uid before the separator, empty to meter without tenant.
+	TenantSeparator string
+	// Prometheus true to add usages to prometheus counters labeled by tenant.
+	Prometheus bool
+	// ClickHouseURL the address of ClickHouse HTTP interface, empty to disable.
+	ClickHouseURL   string
+	ClickHouseTable string
+	// Webhook true to emit usage reports as webhook events.
+	Webhook bool
+}
+
+type TapConf struct {
+	// Enable true to mirror messages delivered to clients to analytics sinks.
+	Enable bool
+	// Actions the actions of messages mirrored, empty to mirror all actions.
+	Actions []string
+	// SampleRate the ratio of messages mirrored in (0, 1], default 1.
+	SampleRate float64
+	// RedactFields the dot separated paths of message fields redacted, such as data.content.
+	RedactFields []string
+	// HashFields the paths of message fields replaced by the HMAC of HashSecret, the uid is hashed too, empty
+	// HashSecret to disable.
+	HashFields []string
+	HashSecret string
+	// KafkaTopic the topic of records produced to Kafka.Address, empty to disable.
+	KafkaTopic string
+	// ClickHouseURL the address of ClickHouse HTTP interface, empty to disable.
+	ClickHouseURL   string
+	ClickHouseTable string
+	// File the path of json lines file records appended to, empty to disable.
+	File string
+}
+
+type ModerationConf struct {
+	// URL the external moderation api, empty to disable.
+	URL string
+	// Timeout the api timeout in milliseconds.
+	Timeout int64
+	// FailClosed true to reject messages when the api failed, otherwise allow them.
+	FailClosed bool
+	// Async true to moderate messages after delivery and recall the rejected, otherwise before delivery.
+	Async bool
+}
+
+// FilterRuleConf the message filter rule, see messaging.FilterRule.
+type FilterRuleConf struct {
+	Name        string
+	Keywords    []string
+	Pattern     string
+	MaxSize     int
+	Action      string
+	Tag         string
+	Replacement string
+}
+
+// ServiceConf the service account routed to agents, see service.Pool.
+type ServiceConf struct {
+	// ID the uid of the service account.
+	ID     string
+	Agents []string
+	// Strategy picks the agent of new sessions: round_robin, least_active or sticky, default least_active.
+	Strategy string
+	// MaxSessions the max count of open sessions of an agent, 0 is unlimited.
+	MaxSessions int
+}
+
+// BotConf the bot whose messages are posted to the webhook, see bot.NewWebhook.
+type BotConf struct {
+	// UID the uid of the bot.
+	UID string
+	URL string
+	// Secret signs the events posted to the webhook.
+	Secret string
+}
+
+type MongoDBConf struct {
+	Uri string
+	Db  string
+	// MaxPoolSize the max count of connections to each server, 0 for the default of driver.
+	MaxPoolSize uint64
+	// Timeout the seconds of connect and socket timeout, 0 for the default of driver.
+	Timeout int
+}
+
+type DegradeConf struct {
+	// Enable true to check dependencies and switch to degraded mode when any is unhealthy.
+	Enable bool
+	// Interval the seconds between two checks, default 5.
+	Interval int
+	// Failures the count of consecutive failed checks marks the dependency unhealthy, default 3.
+	Failures int
+	// Disable the features disabled in degraded mode: offline, persistence and history, default all.
+	Disable []string
 }
 
 type RedisConf struct {
@@ -57,50 +578,100 @@ type RedisConf struct {
 	Db       int
 }
 
+// Config the sections of the config file.
+type Config struct {
+	MySql        *MySqlConf
+	Redis        *RedisConf
+	WsServer     *WsServerConf
+	IMRpcServer  *IMRpcServerConf
+	Business     *BusinessConf
+	Discovery    *DiscoveryConf
+	CommonConf   *CommonConf
+	Kafka        *KafkaConf
+	MongoDB      *MongoDBConf
+	Log          *LogConf
+	Admin        *AdminConf
+	Push         *PushConf
+	Media        *MediaConf
+	Webhook      *WebhookConf
+	Moderation   *ModerationConf
+	Audit        *AuditConf
+	Archive      *ArchiveConf
+	Retention    *RetentionConf
+	Metering     *MeteringConf
+	Tap          *TapConf
+	Degrade      *DegradeConf
+	RemoteConfig *RemoteConfigConf
+	Federation   *FederationConf
+	FilterRules  []FilterRuleConf
+	Services     []ServiceConf
+	Bots         []BotConf
+}
+
+// MustLoad loads the config file named config with extension toml, yaml or json, values can be overridden by
+// environment variables with prefix GLIDE_, such as GLIDE_WSSERVER_PORT.
 func MustLoad() {
 
-	viper.SetConfigName("config.toml")
-	viper.SetConfigType("toml")
+	viper.SetConfigName("config")
 	viper.AddConfigPath(".")
 	viper.AddConfigPath("./_config_local")
 	viper.AddConfigPath("./config")
 	viper.AddConfigPath("/etc/")
 	viper.AddConfigPath("$HOME/.config/")
+	viper.SetEnvPrefix("GLIDE")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+	setDefaults(viper.GetViper())
 
 	err := viper.ReadInConfig()
 	if err != nil {
 		panic(err)
 	}
-	c := struct {
-		MySql       *MySqlConf
-		Redis       *RedisConf
-		WsServer    *WsServerConf
-		IMRpcServer *IMRpcServerConf
-		CommonConf  *CommonConf
-		Kafka       *KafkaConf
-	}{}
-
-	err = viper.Unmarshal(&c)
+	c, err := load(viper.GetViper())
 	if err != nil {
 		panic(err)
 	}
+	apply(c)
+}
+
+func load(v *viper.Viper) (*Config, error) {
+	c := &Config{}
+	err := v.Unmarshal(c)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func apply(c *Config) {
+	current = c
 	MySql = c.MySql
 	WsServer = c.WsServer
 	IMService = c.IMRpcServer
+	Business = c.Business
+	Discovery = c.Discovery
 	Common = c.CommonConf
 	Redis = c.Redis
 	Kafka = c.Kafka
-
-	if Common == nil {
-		panic("CommonConf is nil")
-	}
-	if c.MySql == nil {
-		panic("mysql config is nil")
-	}
-	if c.WsServer == nil {
-		panic("ws server config is nil")
-	}
-	if c.IMRpcServer == nil {
-		panic("im rpc server config is nil")
-	}
+	MongoDB = c.MongoDB
+	Log = c.Log
+	Admin = c.Admin
+	Push = c.Push
+	Media = c.Media
+	Webhook = c.Webhook
+	Moderation = c.Moderation
+	Audit = c.Audit
+	Archive = c.Archive
+	Retention = c.Retention
+	Metering = c.Metering
+	Tap = c.Tap
+	Degrade = c.Degrade
+	RemoteConfig = c.RemoteConfig
+	Federation = c.Federation
+	FilterRules = c.FilterRules
+	Services = c.Services
+	Bots = c.Bots
 }
@@ -20,3 +20,15 @@ func (I *GatewayRpcClient) UpdateClient(ctx context.Context, request *proto.Upda
 func (I *GatewayRpcClient) EnqueueMessage(ctx context.Context, request *proto.EnqueueMessageRequest, response *proto.Response) error {
 	return I.cli.Call(ctx, "EnqueueMessage", request, response)
 }
+
+func (I *GatewayRpcClient) Broadcast(ctx context.Context, request *proto.BroadcastRequest, response *proto.Response) error {
+	return I.cli.Call(ctx, "Broadcast", request, response)
+}
+
+func (I *GatewayRpcClient) SetClientAttributes(ctx context.Context, request *proto.SetClientAttributesRequest, response *proto.Response) error {
+	return I.cli.Call(ctx, "SetClientAttributes", request, response)
+}
+
+func (I *GatewayRpcClient) UpdateClientTicket(ctx context.Context, request *proto.UpdateClientTicketRequest, response *proto.Response) error {
+	return I.cli.Call(ctx, "UpdateClientTicket", request, response)
+}
@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/glide-im/glide/im_service/proto"
+	"github.com/glide-im/glide/pkg/broadcast"
 	"github.com/glide-im/glide/pkg/gate"
 	"github.com/glide-im/glide/pkg/messages"
 	"github.com/glide-im/glide/pkg/rpc"
+	"github.com/glide-im/glide/pkg/tracing"
+	"strconv"
 	"strings"
 )
 
@@ -31,6 +34,9 @@ func IsRpcInvocationError(err error) bool {
 	return err != nil && strings.HasPrefix(err.Error(), errRpcInvocation)
 }
 
+var _ gate.AttributeUpdater = (*GatewayRpcImpl)(nil)
+var _ gate.TicketUpdater = (*GatewayRpcImpl)(nil)
+
 type GatewayRpcImpl struct {
 	gate *GatewayRpcClient
 }
@@ -85,11 +91,13 @@ func (i *GatewayRpcImpl) ExitClient(id gate.ID) error {
 
 func (i *GatewayRpcImpl) EnqueueMessage(id gate.ID, message *messages.GlideMessage) error {
 
-	marshal, err := json.Marshal(message)
+	ctx, span := tracing.Start(message, "rpc.client.EnqueueMessage")
+	defer span.End()
+
+	marshal, err := json.Marshal(tracing.WithTrace(ctx, message))
 	if err != nil {
 		return err
 	}
-	ctx := context.TODO()
 	request := proto.EnqueueMessageRequest{
 		Id:  string(id),
 		Msg: marshal,
@@ -97,11 +105,70 @@ func (i *GatewayRpcImpl) EnqueueMessage(id gate.ID, message *messages.GlideMessa
 	response := proto.Response{}
 	err = i.gate.EnqueueMessage(ctx, &request, &response)
 	if err != nil {
+		tracing.RecordError(span, err)
 		return errors.New(errRpcInvocation + err.Error())
 	}
 	return getResponseError(&response)
 }
 
+// SetClientAttributes sets attributes of the client, see gate.AttributeUpdater.
+func (i *GatewayRpcImpl) SetClientAttributes(id gate.ID, attributes map[string]string, replace bool) error {
+	request := proto.SetClientAttributesRequest{
+		Id:         string(id),
+		Attributes: attributes,
+		Replace:    replace,
+	}
+	response := proto.Response{}
+	err := i.gate.SetClientAttributes(context.TODO(), &request, &response)
+	if err != nil {
+		return errors.New(errRpcInvocation + err.Error())
+	}
+	return getResponseError(&response)
+}
+
+// UpdateClientTicket sets the message deliver secret of online clients of the uid, see gate.TicketUpdater.
+func (i *GatewayRpcImpl) UpdateClientTicket(uid string, device string, secret string) (int, error) {
+	request := proto.UpdateClientTicketRequest{
+		Uid:    uid,
+		Device: device,
+		Secret: secret,
+	}
+	response := proto.Response{}
+	err := i.gate.UpdateClientTicket(context.TODO(), &request, &response)
+	if err != nil {
+		return 0, errors.New(errRpcInvocation + err.Error())
+	}
+	if err = getResponseError(&response); err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(response.GetMsg())
+}
+
+// Broadcast queues the message to deliver to online clients matching the segment, returns the task id.
+func (i *GatewayRpcImpl) Broadcast(message *messages.GlideMessage, segment *broadcast.Segment) (string, error) {
+	marshal, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	request := proto.BroadcastRequest{
+		Msg:           marshal,
+		Devices:       segment.Devices,
+		Gateways:      segment.Gateways,
+		Tags:          segment.Tags,
+		Authenticated: segment.Authenticated,
+		Selector:      segment.Selector,
+	}
+	response := proto.Response{}
+	err = i.gate.Broadcast(context.TODO(), &request, &response)
+	if err != nil {
+		return "", errors.New(errRpcInvocation + err.Error())
+	}
+	if err = getResponseError(&response); err != nil {
+		return "", err
+	}
+	return response.GetMsg(), nil
+}
+
 func (i *GatewayRpcImpl) Close() error {
 	return i.gate.cli.Close()
 }
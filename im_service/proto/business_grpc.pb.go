@@ -0,0 +1,332 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v4.23.1
+// source: business.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// BusinessServiceClient is the client API for BusinessService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BusinessServiceClient interface {
+	// SendMessage sends the message to the online clients of the user on this node.
+	SendMessage(ctx context.Context, in *SendMessageRequest, opts ...grpc.CallOption) (*SendMessageResponse, error)
+	// PublishMessage publishes the message to the channel.
+	PublishMessage(ctx context.Context, in *PublishMessageRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// QueryOnline returns the online devices of users on this node.
+	QueryOnline(ctx context.Context, in *QueryOnlineRequest, opts ...grpc.CallOption) (*QueryOnlineResponse, error)
+	// KickClient notifies the client kicked out and closes the connection.
+	KickClient(ctx context.Context, in *KickClientRequest, opts ...grpc.CallOption) (*KickClientResponse, error)
+	// UpdateTicketSecret updates the secret to verify tickets of the client.
+	UpdateTicketSecret(ctx context.Context, in *UpdateTicketSecretRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	CreateChannel(ctx context.Context, in *ChannelCreateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	DeleteChannel(ctx context.Context, in *ChannelDeleteRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type businessServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBusinessServiceClient(cc grpc.ClientConnInterface) BusinessServiceClient {
+	return &businessServiceClient{cc}
+}
+
+func (c *businessServiceClient) SendMessage(ctx context.Context, in *SendMessageRequest, opts ...grpc.CallOption) (*SendMessageResponse, error) {
+	out := new(SendMessageResponse)
+	err := c.cc.Invoke(ctx, "/im_service.glide_im.github.com.BusinessService/SendMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *businessServiceClient) PublishMessage(ctx context.Context, in *PublishMessageRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/im_service.glide_im.github.com.BusinessService/PublishMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *businessServiceClient) QueryOnline(ctx context.Context, in *QueryOnlineRequest, opts ...grpc.CallOption) (*QueryOnlineResponse, error) {
+	out := new(QueryOnlineResponse)
+	err := c.cc.Invoke(ctx, "/im_service.glide_im.github.com.BusinessService/QueryOnline", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *businessServiceClient) KickClient(ctx context.Context, in *KickClientRequest, opts ...grpc.CallOption) (*KickClientResponse, error) {
+	out := new(KickClientResponse)
+	err := c.cc.Invoke(ctx, "/im_service.glide_im.github.com.BusinessService/KickClient", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *businessServiceClient) UpdateTicketSecret(ctx context.Context, in *UpdateTicketSecretRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/im_service.glide_im.github.com.BusinessService/UpdateTicketSecret", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *businessServiceClient) CreateChannel(ctx context.Context, in *ChannelCreateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/im_service.glide_im.github.com.BusinessService/CreateChannel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *businessServiceClient) DeleteChannel(ctx context.Context, in *ChannelDeleteRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/im_service.glide_im.github.com.BusinessService/DeleteChannel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BusinessServiceServer is the server API for BusinessService service.
+// All implementations must embed UnimplementedBusinessServiceServer
+// for forward compatibility
+type BusinessServiceServer interface {
+	// SendMessage sends the message to the online clients of the user on this node.
+	SendMessage(context.Context, *SendMessageRequest) (*SendMessageResponse, error)
+	// PublishMessage publishes the message to the channel.
+	PublishMessage(context.Context, *PublishMessageRequest) (*emptypb.Empty, error)
+	// QueryOnline returns the online devices of users on this node.
+	QueryOnline(context.Context, *QueryOnlineRequest) (*QueryOnlineResponse, error)
+	// KickClient notifies the client kicked out and closes the connection.
+	KickClient(context.Context, *KickClientRequest) (*KickClientResponse, error)
+	// UpdateTicketSecret updates the secret to verify tickets of the client.
+	UpdateTicketSecret(context.Context, *UpdateTicketSecretRequest) (*emptypb.Empty, error)
+	CreateChannel(context.Context, *ChannelCreateRequest) (*emptypb.Empty, error)
+	DeleteChannel(context.Context, *ChannelDeleteRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedBusinessServiceServer()
+}
+
+// UnimplementedBusinessServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedBusinessServiceServer struct {
+}
+
+func (UnimplementedBusinessServiceServer) SendMessage(context.Context, *SendMessageRequest) (*SendMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendMessage not implemented")
+}
+func (UnimplementedBusinessServiceServer) PublishMessage(context.Context, *PublishMessageRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublishMessage not implemented")
+}
+func (UnimplementedBusinessServiceServer) QueryOnline(context.Context, *QueryOnlineRequest) (*QueryOnlineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryOnline not implemented")
+}
+func (UnimplementedBusinessServiceServer) KickClient(context.Context, *KickClientRequest) (*KickClientResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method KickClient not implemented")
+}
+func (UnimplementedBusinessServiceServer) UpdateTicketSecret(context.Context, *UpdateTicketSecretRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateTicketSecret not implemented")
+}
+func (UnimplementedBusinessServiceServer) CreateChannel(context.Context, *ChannelCreateRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateChannel not implemented")
+}
+func (UnimplementedBusinessServiceServer) DeleteChannel(context.Context, *ChannelDeleteRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteChannel not implemented")
+}
+func (UnimplementedBusinessServiceServer) mustEmbedUnimplementedBusinessServiceServer() {}
+
+// UnsafeBusinessServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BusinessServiceServer will
+// result in compilation errors.
+type UnsafeBusinessServiceServer interface {
+	mustEmbedUnimplementedBusinessServiceServer()
+}
+
+func RegisterBusinessServiceServer(s grpc.ServiceRegistrar, srv BusinessServiceServer) {
+	s.RegisterService(&BusinessService_ServiceDesc, srv)
+}
+
+func _BusinessService_SendMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BusinessServiceServer).SendMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/im_service.glide_im.github.com.BusinessService/SendMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BusinessServiceServer).SendMessage(ctx, req.(*SendMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BusinessService_PublishMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublishMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BusinessServiceServer).PublishMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/im_service.glide_im.github.com.BusinessService/PublishMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BusinessServiceServer).PublishMessage(ctx, req.(*PublishMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BusinessService_QueryOnline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryOnlineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BusinessServiceServer).QueryOnline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/im_service.glide_im.github.com.BusinessService/QueryOnline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BusinessServiceServer).QueryOnline(ctx, req.(*QueryOnlineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BusinessService_KickClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KickClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BusinessServiceServer).KickClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/im_service.glide_im.github.com.BusinessService/KickClient",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BusinessServiceServer).KickClient(ctx, req.(*KickClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BusinessService_UpdateTicketSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateTicketSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BusinessServiceServer).UpdateTicketSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/im_service.glide_im.github.com.BusinessService/UpdateTicketSecret",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BusinessServiceServer).UpdateTicketSecret(ctx, req.(*UpdateTicketSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BusinessService_CreateChannel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChannelCreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BusinessServiceServer).CreateChannel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/im_service.glide_im.github.com.BusinessService/CreateChannel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BusinessServiceServer).CreateChannel(ctx, req.(*ChannelCreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BusinessService_DeleteChannel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChannelDeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BusinessServiceServer).DeleteChannel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/im_service.glide_im.github.com.BusinessService/DeleteChannel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BusinessServiceServer).DeleteChannel(ctx, req.(*ChannelDeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// BusinessService_ServiceDesc is the grpc.ServiceDesc for BusinessService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BusinessService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "im_service.glide_im.github.com.BusinessService",
+	HandlerType: (*BusinessServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendMessage",
+			Handler:    _BusinessService_SendMessage_Handler,
+		},
+		{
+			MethodName: "PublishMessage",
+			Handler:    _BusinessService_PublishMessage_Handler,
+		},
+		{
+			MethodName: "QueryOnline",
+			Handler:    _BusinessService_QueryOnline_Handler,
+		},
+		{
+			MethodName: "KickClient",
+			Handler:    _BusinessService_KickClient_Handler,
+		},
+		{
+			MethodName: "UpdateTicketSecret",
+			Handler:    _BusinessService_UpdateTicketSecret_Handler,
+		},
+		{
+			MethodName: "CreateChannel",
+			Handler:    _BusinessService_CreateChannel_Handler,
+		},
+		{
+			MethodName: "DeleteChannel",
+			Handler:    _BusinessService_DeleteChannel_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "business.proto",
+}
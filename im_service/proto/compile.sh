@@ -1,3 +1,3 @@
 #!/usr/bin/env bash
 
-protoc --proto_path=./ --go_out=./../../ ./*.proto
\ No newline at end of file
+protoc --proto_path=./ --go_out=./../../ --go-grpc_out=./../../ ./*.proto
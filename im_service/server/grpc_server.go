@@ -0,0 +1,264 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/glide-im/glide/im_service/proto"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/glide-im/glide/pkg/subscription/subscription_impl"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"net"
+	"strings"
+)
+
+var _ proto.BusinessServiceServer = (*BusinessServer)(nil)
+
+// BusinessServer the gRPC service for business backends to send messages, query and kick clients, and manage
+// channels, clients are looked up in this node only.
+type BusinessServer struct {
+	proto.UnimplementedBusinessServiceServer
+
+	gateway gate.DefaultGateway
+	sub     subscription_impl.SubscribeWrap
+}
+
+// NewBusinessServer creates the BusinessServer, channel apis return Unimplemented if subscribe is nil.
+func NewBusinessServer(gateway gate.DefaultGateway, subscribe subscription.Subscribe) *BusinessServer {
+	s := &BusinessServer{gateway: gateway}
+	if subscribe != nil {
+		s.sub = subscription_impl.NewSubscribeWrap(subscribe)
+	}
+	return s
+}
+
+const errTokenRequired = "token of business service is required unless listening on loopback"
+
+// RunGrpcService serves the BusinessServer at addr, requests must carry the metadata `authorization: Bearer <token>`.
+// The token can be empty only if addr is a loopback address, such as "127.0.0.1:8093".
+func RunGrpcService(addr string, token string, srv *BusinessServer) error {
+	if token == "" && !isLoopback(addr) {
+		return errors.New(errTokenRequired)
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return NewGrpcServer(token, srv).Serve(lis)
+}
+
+// NewGrpcServer creates the grpc.Server with the BusinessServer registered, unary and stream calls are authorized by
+// the token if not empty.
+func NewGrpcServer(token string, srv *BusinessServer) *grpc.Server {
+	var opts []grpc.ServerOption
+	if token != "" {
+		opts = append(opts,
+			grpc.UnaryInterceptor(unaryTokenInterceptor(token)),
+			grpc.StreamInterceptor(streamTokenInterceptor(token)),
+		)
+	}
+	s := grpc.NewServer(opts...)
+	proto.RegisterBusinessServiceServer(s, srv)
+	return s
+}
+
+func unaryTokenInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !hasToken(ctx, token) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamTokenInterceptor(token string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !hasToken(ss.Context(), token) {
+			return status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return handler(srv, ss)
+	}
+}
+
+// hasToken returns true if the metadata `authorization` of ctx is `Bearer <token>`.
+func hasToken(ctx context.Context, token string) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if !strings.HasPrefix(v, "Bearer ") {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(v, "Bearer ")), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopback returns true if the host of addr is localhost or a loopback ip.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (b *BusinessServer) SendMessage(ctx context.Context, request *proto.SendMessageRequest) (*proto.SendMessageResponse, error) {
+	if request.GetUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "uid is required")
+	}
+	msg, err := unmarshalMessage(request.GetMessage())
+	if err != nil {
+		return nil, err
+	}
+	var delivered int32
+	for _, id := range b.clientsOf(request.GetUid(), request.GetDevice()) {
+		if b.gateway.EnqueueMessage(id, msg) == nil {
+			delivered++
+		}
+	}
+	return &proto.SendMessageResponse{Delivered: delivered}, nil
+}
+
+func (b *BusinessServer) PublishMessage(ctx context.Context, request *proto.PublishMessageRequest) (*emptypb.Empty, error) {
+	if b.sub == nil {
+		return nil, status.Error(codes.Unimplemented, "subscription is not enabled")
+	}
+	msg, err := unmarshalMessage(request.GetMessage())
+	if err != nil {
+		return nil, err
+	}
+	typ := int(request.GetType())
+	if typ == 0 {
+		typ = subscription_impl.TypeMessage
+	}
+	err = b.sub.Publish(subscription.ChanID(request.GetChannel()), &subscription_impl.PublishMessage{
+		From:    subscription.SubscriberID(request.GetFrom()),
+		Type:    typ,
+		Message: msg,
+	})
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (b *BusinessServer) QueryOnline(ctx context.Context, request *proto.QueryOnlineRequest) (*proto.QueryOnlineResponse, error) {
+	devices := map[string][]string{}
+	for _, uid := range request.GetUids() {
+		devices[uid] = nil
+	}
+	for id := range b.gateway.GetAll() {
+		uid := id.UID()
+		if d, ok := devices[uid]; ok && !id.IsTemp() {
+			devices[uid] = append(d, id.Device())
+		}
+	}
+	response := &proto.QueryOnlineResponse{}
+	for _, uid := range request.GetUids() {
+		if d := devices[uid]; len(d) > 0 {
+			response.Users = append(response.Users, &proto.OnlineUser{Uid: uid, Devices: d})
+			delete(devices, uid)
+		}
+	}
+	return response, nil
+}
+
+func (b *BusinessServer) KickClient(ctx context.Context, request *proto.KickClientRequest) (*proto.KickClientResponse, error) {
+	if request.GetUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "uid is required")
+	}
+	var kicked int32
+	for _, id := range b.clientsOf(request.GetUid(), request.GetDevice()) {
+		_ = b.gateway.EnqueueMessage(id, messages.NewMessage(0, messages.ActionNotifyKickOut, &messages.KickOutNotify{}))
+		if b.gateway.ExitClient(id) == nil {
+			kicked++
+		}
+	}
+	return &proto.KickClientResponse{Kicked: kicked}, nil
+}
+
+func (b *BusinessServer) UpdateTicketSecret(ctx context.Context, request *proto.UpdateTicketSecretRequest) (*emptypb.Empty, error) {
+	if request.GetUid() == "" || request.GetSecret() == "" {
+		return nil, status.Error(codes.InvalidArgument, "uid and secret are required")
+	}
+	if updater, ok := b.gateway.(gate.TicketUpdater); ok {
+		_, err := updater.UpdateClientTicket(request.GetUid(), request.GetDevice(), request.GetSecret())
+		if gate.IsClientNotExist(err) {
+			return nil, status.Error(codes.NotFound, "client is not online")
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return &emptypb.Empty{}, nil
+	}
+	ids := b.clientsOf(request.GetUid(), request.GetDevice())
+	if len(ids) == 0 {
+		return nil, status.Error(codes.NotFound, "client is not online")
+	}
+	for _, id := range ids {
+		err := b.gateway.UpdateClient(id, &gate.ClientSecrets{MessageDeliverSecret: request.GetSecret()})
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (b *BusinessServer) CreateChannel(ctx context.Context, request *proto.ChannelCreateRequest) (*emptypb.Empty, error) {
+	if b.sub == nil {
+		return nil, status.Error(codes.Unimplemented, "subscription is not enabled")
+	}
+	id := subscription.ChanID(request.GetChannel())
+	err := b.sub.CreateChannel(id, &subscription.ChanInfo{
+		ID:      id,
+		Type:    subscription.ChanType(request.GetType()),
+		Muted:   request.GetMuted(),
+		Blocked: request.GetBlocked(),
+	})
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (b *BusinessServer) DeleteChannel(ctx context.Context, request *proto.ChannelDeleteRequest) (*emptypb.Empty, error) {
+	if b.sub == nil {
+		return nil, status.Error(codes.Unimplemented, "subscription is not enabled")
+	}
+	err := b.sub.RemoveChannel(subscription.ChanID(request.GetChannel()))
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// clientsOf returns the online clients of uid, all devices if device is empty.
+func (b *BusinessServer) clientsOf(uid string, device string) []gate.ID {
+	var ids []gate.ID
+	for id := range b.gateway.GetAll() {
+		if id.UID() == uid && !id.IsTemp() && (device == "" || id.Device() == device) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func unmarshalMessage(b []byte) (*messages.GlideMessage, error) {
+	msg := &messages.GlideMessage{}
+	if err := json.Unmarshal(b, msg); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid message: "+err.Error())
+	}
+	return msg, nil
+}
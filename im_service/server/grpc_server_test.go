@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/glide-im/glide/im_service/proto"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+)
+
+type mockGateway struct {
+	clients  map[gate.ID]gate.Info
+	enqueued map[gate.ID][]*messages.GlideMessage
+	secrets  map[gate.ID]string
+}
+
+func newMockGateway(ids ...gate.ID) *mockGateway {
+	m := &mockGateway{
+		clients:  map[gate.ID]gate.Info{},
+		enqueued: map[gate.ID][]*messages.GlideMessage{},
+		secrets:  map[gate.ID]string{},
+	}
+	for _, id := range ids {
+		m.clients[id] = gate.Info{ID: id}
+	}
+	return m
+}
+
+func (m *mockGateway) SetClientID(old gate.ID, new_ gate.ID) error { return nil }
+
+func (m *mockGateway) UpdateClient(id gate.ID, info *gate.ClientSecrets) error {
+	m.secrets[id] = info.MessageDeliverSecret
+	return nil
+}
+
+func (m *mockGateway) ExitClient(id gate.ID) error {
+	if _, ok := m.clients[id]; !ok {
+		return errors.New("client does not exist")
+	}
+	delete(m.clients, id)
+	return nil
+}
+
+func (m *mockGateway) EnqueueMessage(id gate.ID, message *messages.GlideMessage) error {
+	m.enqueued[id] = append(m.enqueued[id], message)
+	return nil
+}
+
+func (m *mockGateway) GetClient(id gate.ID) gate.Client { return nil }
+
+func (m *mockGateway) GetAll() map[gate.ID]gate.Info { return m.clients }
+
+func (m *mockGateway) SetMessageHandler(h gate.MessageHandler) {}
+
+func (m *mockGateway) AddClient(cs gate.Client) {}
+
+func dialBusiness(t *testing.T, g gate.DefaultGateway, token string) proto.BusinessServiceClient {
+	lis := bufconn.Listen(1 << 16)
+	s := NewGrpcServer(token, NewBusinessServer(g, nil))
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	cc, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+	return proto.NewBusinessServiceClient(cc)
+}
+
+func authorized() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+}
+
+func TestBusinessServer_Token(t *testing.T) {
+	c := dialBusiness(t, newMockGateway(), "secret")
+
+	_, err := c.QueryOnline(context.Background(), &proto.QueryOnlineRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = c.QueryOnline(authorized(), &proto.QueryOnlineRequest{})
+	assert.NoError(t, err)
+
+	// the token without the scheme is rejected.
+	raw := metadata.AppendToOutgoingContext(context.Background(), "authorization", "secret")
+	_, err = c.QueryOnline(raw, &proto.QueryOnlineRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	wrong := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret2")
+	_, err = c.QueryOnline(wrong, &proto.QueryOnlineRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+type mockServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (m *mockServerStream) Context() context.Context {
+	return m.ctx
+}
+
+func TestStreamTokenInterceptor(t *testing.T) {
+	interceptor := streamTokenInterceptor("secret")
+	called := false
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		called = true
+		return nil
+	}
+	ss := &mockServerStream{ctx: context.Background()}
+	err := interceptor(nil, ss, &grpc.StreamServerInfo{}, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.False(t, called)
+
+	ss.ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+	assert.NoError(t, interceptor(nil, ss, &grpc.StreamServerInfo{}, handler))
+	assert.True(t, called)
+}
+
+func TestRunGrpcService_TokenRequired(t *testing.T) {
+	err := RunGrpcService("0.0.0.0:0", "", NewBusinessServer(newMockGateway(), nil))
+	assert.EqualError(t, err, errTokenRequired)
+
+	assert.True(t, isLoopback("127.0.0.1:8093"))
+	assert.True(t, isLoopback("localhost:8093"))
+	assert.True(t, isLoopback("[::1]:8093"))
+	assert.False(t, isLoopback(":8093"))
+	assert.False(t, isLoopback("10.0.0.1:8093"))
+}
+
+func TestBusinessServer_SendMessage(t *testing.T) {
+	g := newMockGateway(gate.NewID("", "1", "1"), gate.NewID("", "1", "2"), gate.NewID("", "2", "1"))
+	c := dialBusiness(t, g, "secret")
+
+	resp, err := c.SendMessage(authorized(), &proto.SendMessageRequest{Uid: "1", Message: []byte(`{"action":"message.chat"}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), resp.GetDelivered())
+	assert.Len(t, g.enqueued[gate.NewID("", "1", "2")], 1)
+	assert.Empty(t, g.enqueued[gate.NewID("", "2", "1")])
+
+	resp, err = c.SendMessage(authorized(), &proto.SendMessageRequest{Uid: "1", Device: "2", Message: []byte(`{}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), resp.GetDelivered())
+
+	_, err = c.SendMessage(authorized(), &proto.SendMessageRequest{Uid: "1", Message: []byte(`{`)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestBusinessServer_Clients(t *testing.T) {
+	g := newMockGateway(gate.NewID("", "1", "1"), gate.NewID("", "1", "2"), gate.NewID("", "2", "1"))
+	c := dialBusiness(t, g, "")
+
+	online, err := c.QueryOnline(context.Background(), &proto.QueryOnlineRequest{Uids: []string{"1", "3"}})
+	assert.NoError(t, err)
+	assert.Len(t, online.GetUsers(), 1)
+	assert.ElementsMatch(t, []string{"1", "2"}, online.GetUsers()[0].GetDevices())
+
+	_, err = c.UpdateTicketSecret(context.Background(), &proto.UpdateTicketSecretRequest{Uid: "2", Secret: "s"})
+	assert.NoError(t, err)
+	assert.Equal(t, "s", g.secrets[gate.NewID("", "2", "1")])
+	_, err = c.UpdateTicketSecret(context.Background(), &proto.UpdateTicketSecretRequest{Uid: "3", Secret: "s"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	kicked, err := c.KickClient(context.Background(), &proto.KickClientRequest{Uid: "1", Device: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), kicked.GetKicked())
+	assert.Equal(t, messages.ActionNotifyKickOut, g.enqueued[gate.NewID("", "1", "1")][0].GetAction())
+	assert.Len(t, g.clients, 2)
+
+	_, err = c.CreateChannel(context.Background(), &proto.ChannelCreateRequest{Channel: "c"})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
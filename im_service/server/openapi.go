@@ -0,0 +1,134 @@
+package server
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"net/http"
+	"strings"
+)
+
+// openAPISpec generates the OpenAPI 3 spec of routes, schemas are generated from the message descriptors by the
+// protobuf JSON mapping, so the spec never goes out of date with business.proto.
+func openAPISpec(routes []restRoute) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+	for _, r := range routes {
+		req := r.request().ProtoReflect().Descriptor()
+		resp := r.response.ProtoReflect().Descriptor()
+		addSchema(schemas, req)
+		addSchema(schemas, resp)
+		paths[r.path] = map[string]interface{}{
+			strings.ToLower(http.MethodPost): map[string]interface{}{
+				"summary":     r.summary,
+				"operationId": string(req.Name()),
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content":  jsonContent(schemaRef(req)),
+				},
+				"responses": map[string]interface{}{
+					"200":     map[string]interface{}{"description": "OK", "content": jsonContent(schemaRef(resp))},
+					"default": map[string]interface{}{"description": "Error", "content": jsonContent(schemaRef(nil))},
+				},
+			},
+		}
+	}
+	schemas["Error"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"code":  map[string]interface{}{"type": "string", "example": "INVALID_ARGUMENT"},
+			"error": map[string]interface{}{"type": "string"},
+		},
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Glide Business API",
+			"version": "v1",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"apiKey": []string{}}},
+	}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// schemaRef returns the reference to the schema of the message, the Error schema if md is nil.
+func schemaRef(md protoreflect.MessageDescriptor) map[string]interface{} {
+	name := "Error"
+	if md != nil {
+		name = schemaName(md)
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func schemaName(md protoreflect.MessageDescriptor) string {
+	return strings.ReplaceAll(string(md.FullName()), ".", "_")
+}
+
+func addSchema(schemas map[string]interface{}, md protoreflect.MessageDescriptor) {
+	name := schemaName(md)
+	if _, ok := schemas[name]; ok {
+		return
+	}
+	properties := map[string]interface{}{}
+	schemas[name] = map[string]interface{}{"type": "object", "properties": properties}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		properties[fd.JSONName()] = fieldSchema(schemas, fd)
+	}
+}
+
+func fieldSchema(schemas map[string]interface{}, fd protoreflect.FieldDescriptor) map[string]interface{} {
+	if fd.IsMap() {
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": kindSchema(schemas, fd.MapValue()),
+		}
+	}
+	s := kindSchema(schemas, fd)
+	if fd.IsList() {
+		return map[string]interface{}{"type": "array", "items": s}
+	}
+	return s
+}
+
+// kindSchema returns the schema of the field kind by the protobuf JSON mapping, 64-bit integers are strings and bytes
+// are base64 strings.
+func kindSchema(schemas map[string]interface{}, fd protoreflect.FieldDescriptor) map[string]interface{} {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]interface{}{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]interface{}{"type": "string", "format": "int64"}
+	case protoreflect.FloatKind:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		var values []string
+		ev := fd.Enum().Values()
+		for i := 0; i < ev.Len(); i++ {
+			values = append(values, string(ev.Get(i).Name()))
+		}
+		return map[string]interface{}{"type": "string", "enum": values}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		addSchema(schemas, fd.Message())
+		return schemaRef(fd.Message())
+	}
+	return map[string]interface{}{"type": "string"}
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/glide-im/glide/im_service/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const maxRestBodySize = 4 << 20
+
+type restRoute struct {
+	path    string
+	summary string
+	request func() protoreflect.ProtoMessage
+	call    func(ctx context.Context, req protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error)
+	// response the empty response message, used to describe the response schema.
+	response protoreflect.ProtoMessage
+}
+
+type RestOptions struct {
+	// Addr the address of rest http server listen on.
+	Addr string
+	// APIKeys the keys business services must carry in the header `X-API-Key` or `Authorization: Bearer <key>`, must
+	// not be empty.
+	APIKeys []string
+}
+
+// RestServer the HTTP/JSON facade of BusinessServer for services can't adopt gRPC, requests and responses are the
+// JSON mapping of messages in business.proto, all apis are POST.
+//
+//	POST /v1/messages          SendMessageRequest
+//	POST /v1/channels/messages PublishMessageRequest
+//	POST /v1/presence          QueryOnlineRequest
+//	POST /v1/clients/kick      KickClientRequest
+//	POST /v1/clients/secret    UpdateTicketSecretRequest
+//	POST /v1/channels          ChannelCreateRequest
+//	POST /v1/channels/delete   ChannelDeleteRequest
+//	GET  /openapi.json         the OpenAPI 3 spec of apis above, no api key required
+type RestServer struct {
+	addr     string
+	keys     []string
+	business *BusinessServer
+	routes   []restRoute
+	mux      *http.ServeMux
+}
+
+func NewRestServer(business *BusinessServer, opts *RestOptions) (*RestServer, error) {
+	if len(opts.APIKeys) == 0 {
+		return nil, errors.New("rest api keys must not be empty")
+	}
+	s := &RestServer{
+		addr:     opts.Addr,
+		keys:     opts.APIKeys,
+		business: business,
+		mux:      http.NewServeMux(),
+	}
+	s.routes = []restRoute{
+		{
+			path: "/v1/messages", summary: "Send the message to the online clients of the user",
+			request:  func() protoreflect.ProtoMessage { return &proto.SendMessageRequest{} },
+			response: &proto.SendMessageResponse{},
+			call: func(ctx context.Context, req protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+				return business.SendMessage(ctx, req.(*proto.SendMessageRequest))
+			},
+		},
+		{
+			path: "/v1/channels/messages", summary: "Publish the message to the channel",
+			request:  func() protoreflect.ProtoMessage { return &proto.PublishMessageRequest{} },
+			response: &emptypb.Empty{},
+			call: func(ctx context.Context, req protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+				return business.PublishMessage(ctx, req.(*proto.PublishMessageRequest))
+			},
+		},
+		{
+			path: "/v1/presence", summary: "Query the online devices of users",
+			request:  func() protoreflect.ProtoMessage { return &proto.QueryOnlineRequest{} },
+			response: &proto.QueryOnlineResponse{},
+			call: func(ctx context.Context, req protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+				return business.QueryOnline(ctx, req.(*proto.QueryOnlineRequest))
+			},
+		},
+		{
+			path: "/v1/clients/kick", summary: "Kick out the clients of the user",
+			request:  func() protoreflect.ProtoMessage { return &proto.KickClientRequest{} },
+			response: &proto.KickClientResponse{},
+			call: func(ctx context.Context, req protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+				return business.KickClient(ctx, req.(*proto.KickClientRequest))
+			},
+		},
+		{
+			path: "/v1/clients/secret", summary: "Update the secret to verify message tickets of the clients",
+			request:  func() protoreflect.ProtoMessage { return &proto.UpdateTicketSecretRequest{} },
+			response: &emptypb.Empty{},
+			call: func(ctx context.Context, req protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+				return business.UpdateTicketSecret(ctx, req.(*proto.UpdateTicketSecretRequest))
+			},
+		},
+		{
+			path: "/v1/channels", summary: "Create the channel",
+			request:  func() protoreflect.ProtoMessage { return &proto.ChannelCreateRequest{} },
+			response: &emptypb.Empty{},
+			call: func(ctx context.Context, req protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+				return business.CreateChannel(ctx, req.(*proto.ChannelCreateRequest))
+			},
+		},
+		{
+			path: "/v1/channels/delete", summary: "Delete the channel",
+			request:  func() protoreflect.ProtoMessage { return &proto.ChannelDeleteRequest{} },
+			response: &emptypb.Empty{},
+			call: func(ctx context.Context, req protoreflect.ProtoMessage) (protoreflect.ProtoMessage, error) {
+				return business.DeleteChannel(ctx, req.(*proto.ChannelDeleteRequest))
+			},
+		},
+	}
+	for _, r := range s.routes {
+		s.mux.Handle(r.path, s.handler(r))
+	}
+	spec, err := json.Marshal(openAPISpec(s.routes))
+	if err != nil {
+		return nil, err
+	}
+	s.mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(spec)
+	})
+	return s, nil
+}
+
+func (s *RestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Run starts the rest http server, it blocks until server stopped.
+func (s *RestServer) Run() error {
+	return http.ListenAndServe(s.addr, s)
+}
+
+func (s *RestServer) authorized(r *http.Request) bool {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if key == "" {
+		return false
+	}
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *RestServer) handler(route restRoute) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			writeRestError(w, status.Error(codes.Unauthenticated, "invalid api key"))
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeRestBody(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "method not allowed")
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRestBodySize))
+		if err != nil {
+			writeRestError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+		req := route.request()
+		if len(body) > 0 {
+			if err = protojson.Unmarshal(body, req); err != nil {
+				writeRestError(w, status.Error(codes.InvalidArgument, err.Error()))
+				return
+			}
+		}
+		resp, err := route.call(r.Context(), req)
+		if err != nil {
+			writeRestError(w, err)
+			return
+		}
+		b, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(resp)
+		if err != nil {
+			writeRestError(w, status.Error(codes.Internal, err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+	}
+}
+
+// writeRestError writes the error as `{"code": "NOT_FOUND", "error": ""}` with the http status of the gRPC code.
+func writeRestError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeRestBody(w, httpStatus(st.Code()), codeName(st.Code()), st.Message())
+}
+
+func writeRestBody(w http.ResponseWriter, status int, code string, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"code": code, "error": msg})
+}
+
+func httpStatus(c codes.Code) int {
+	switch c {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// codeName returns the upper snake case name of the code, such as NOT_FOUND.
+func codeName(c codes.Code) string {
+	var b strings.Builder
+	for i, r := range c.String() {
+		if r >= 'A' && r <= 'Z' && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToUpper(b.String())
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func restRequest(s *RestServer, target, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRestServer(t *testing.T) {
+	g := newMockGateway(gate.NewID("", "1", "1"), gate.NewID("", "1", "2"))
+	s, err := NewRestServer(NewBusinessServer(g, nil), &RestOptions{APIKeys: []string{"k1", "k2"}})
+	assert.NoError(t, err)
+
+	rec := restRequest(s, "/v1/presence", "", `{"uids": ["1"]}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "UNAUTHENTICATED")
+
+	rec = restRequest(s, "/v1/presence", "k2", `{"uids": ["1", "2"]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	presence := struct {
+		Users []struct {
+			Uid     string
+			Devices []string
+		}
+	}{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &presence))
+	assert.Len(t, presence.Users, 1)
+	assert.Len(t, presence.Users[0].Devices, 2)
+
+	// message is the base64 of `{"action":"message.chat"}`.
+	rec = restRequest(s, "/v1/messages", "k1", `{"uid": "1", "device": "2", "message": "eyJhY3Rpb24iOiJtZXNzYWdlLmNoYXQifQ=="}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"delivered": 1}`, rec.Body.String())
+
+	rec = restRequest(s, "/v1/clients/secret", "k1", `{"uid": "3", "secret": "s"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "NOT_FOUND")
+
+	rec = restRequest(s, "/v1/messages", "k1", `{"unknown": 1}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = restRequest(s, "/v1/channels", "k1", `{"channel": "c"}`)
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+}
+
+func TestRestServer_OpenAPI(t *testing.T) {
+	s, err := NewRestServer(NewBusinessServer(newMockGateway(), nil), &RestOptions{APIKeys: []string{"k"}})
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	spec := struct {
+		Paths      map[string]interface{}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{}
+			}
+		}
+	}{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &spec))
+	assert.Len(t, spec.Paths, 7)
+	online := spec.Components.Schemas["im_service_glide_im_github_com_QueryOnlineResponse"]
+	assert.Equal(t, "array", online.Properties["users"]["type"])
+	send := spec.Components.Schemas["im_service_glide_im_github_com_SendMessageRequest"]
+	assert.Equal(t, "byte", send.Properties["message"]["format"])
+}
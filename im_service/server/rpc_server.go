@@ -5,17 +5,26 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/glide-im/glide/im_service/proto"
+	"github.com/glide-im/glide/pkg/broadcast"
 	"github.com/glide-im/glide/pkg/gate"
 	"github.com/glide-im/glide/pkg/messages"
 	"github.com/glide-im/glide/pkg/rpc"
 	"github.com/glide-im/glide/pkg/subscription"
 	"github.com/glide-im/glide/pkg/subscription/subscription_impl"
+	"github.com/glide-im/glide/pkg/tracing"
+	"strconv"
 )
 
 type GatewayRpcServer interface {
 	UpdateClient(ctx context.Context, request *proto.UpdateClient, response *proto.Response) error
 
 	EnqueueMessage(ctx context.Context, request *proto.EnqueueMessageRequest, response *proto.Response) error
+
+	Broadcast(ctx context.Context, request *proto.BroadcastRequest, response *proto.Response) error
+
+	SetClientAttributes(ctx context.Context, request *proto.SetClientAttributesRequest, response *proto.Response) error
+
+	UpdateClientTicket(ctx context.Context, request *proto.UpdateClientTicketRequest, response *proto.Response) error
 }
 
 type SubscriptionRpcServer interface {
@@ -35,15 +44,18 @@ type SubscriptionRpcServer interface {
 }
 
 type IMRpcService struct {
-	gateway gate.Server
-	sub     subscription_impl.SubscribeWrap
+	gateway     gate.Server
+	sub         subscription_impl.SubscribeWrap
+	broadcaster *broadcast.Broadcaster
 }
 
-func RunRpcService(options *rpc.ServerOptions, gate gate.Server, subscribe subscription.Subscribe) error {
+// RunRpcService runs the rpc service, the broadcaster is optional, Broadcast fails if it is nil.
+func RunRpcService(options *rpc.ServerOptions, gate gate.Server, subscribe subscription.Subscribe, broadcaster *broadcast.Broadcaster) error {
 	server := rpc.NewBaseServer(options)
 	rpcServer := IMRpcService{
-		gateway: gate,
-		sub:     subscription_impl.NewSubscribeWrap(subscribe),
+		gateway:     gate,
+		sub:         subscription_impl.NewSubscribeWrap(subscribe),
+		broadcaster: broadcaster,
 	}
 	server.Register(options.Name, &rpcServer)
 	return server.Run()
@@ -92,6 +104,10 @@ func (r *IMRpcService) EnqueueMessage(ctx context.Context, request *proto.Enqueu
 		return nil
 	}
 
+	spanCtx, span := tracing.Start(&msg, "rpc.server.EnqueueMessage")
+	defer span.End()
+	tracing.Inject(spanCtx, &msg)
+
 	err = r.gateway.EnqueueMessage(gate.ID(request.Id), &msg)
 	if err != nil {
 		response.Code = int32(proto.Response_ERROR)
@@ -100,6 +116,72 @@ func (r *IMRpcService) EnqueueMessage(ctx context.Context, request *proto.Enqueu
 	return err
 }
 
+// Broadcast queues the message to deliver to online clients matching the segment, the response msg is the task id.
+func (r *IMRpcService) Broadcast(ctx context.Context, request *proto.BroadcastRequest, response *proto.Response) error {
+	if r.broadcaster == nil {
+		response.Code = int32(proto.Response_ERROR)
+		response.Msg = "broadcast is not enabled"
+		return nil
+	}
+	msg := messages.GlideMessage{}
+	err := json.Unmarshal(request.Msg, &msg)
+	if err != nil {
+		response.Code = int32(proto.Response_ERROR)
+		response.Msg = err.Error()
+		return nil
+	}
+	task, err := r.broadcaster.Broadcast(&msg, &broadcast.Segment{
+		Devices:       request.GetDevices(),
+		Gateways:      request.GetGateways(),
+		Tags:          request.GetTags(),
+		Authenticated: request.GetAuthenticated(),
+		Selector:      request.GetSelector(),
+	})
+	if err != nil {
+		response.Code = int32(proto.Response_ERROR)
+		response.Msg = err.Error()
+		return nil
+	}
+	response.Code = int32(proto.Response_OK)
+	response.Msg = task.ID()
+	return nil
+}
+
+func (r *IMRpcService) SetClientAttributes(ctx context.Context, request *proto.SetClientAttributesRequest, response *proto.Response) error {
+	updater, ok := r.gateway.(gate.AttributeUpdater)
+	if !ok {
+		response.Code = int32(proto.Response_ERROR)
+		response.Msg = "client attributes is not supported"
+		return nil
+	}
+	err := updater.SetClientAttributes(gate.ID(request.GetId()), request.GetAttributes(), request.GetReplace())
+	if err != nil {
+		response.Code = int32(proto.Response_ERROR)
+		response.Msg = err.Error()
+	}
+	return nil
+}
+
+// UpdateClientTicket sets the message deliver secret of online clients of the uid, the response msg is the count of
+// clients updated.
+func (r *IMRpcService) UpdateClientTicket(ctx context.Context, request *proto.UpdateClientTicketRequest, response *proto.Response) error {
+	updater, ok := r.gateway.(gate.TicketUpdater)
+	if !ok {
+		response.Code = int32(proto.Response_ERROR)
+		response.Msg = "ticket update is not supported"
+		return nil
+	}
+	n, err := updater.UpdateClientTicket(request.GetUid(), request.GetDevice(), request.GetSecret())
+	if err != nil {
+		response.Code = int32(proto.Response_ERROR)
+		response.Msg = err.Error()
+		return nil
+	}
+	response.Code = int32(proto.Response_OK)
+	response.Msg = strconv.Itoa(n)
+	return nil
+}
+
 ////////////////////////////////////// Subscription //////////////////////////////////////////////
 
 func (r *IMRpcService) Subscribe(ctx context.Context, request *proto.SubscribeRequest, response *proto.Response) error {
@@ -0,0 +1,114 @@
+package message_store_db
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"sort"
+	"strings"
+)
+
+var _ store.ArchiveStore = &ChatMessageStore{}
+
+// GetArchivable returns messages of im_chat_message and im_channel_message sent before, the content of recalled
+// message is kept with Recalled set.
+func (D *ChatMessageStore) GetArchivable(before int64, limit int) ([]*store.ArchivedMessage, error) {
+	chat, err := D.queryArchivable(conversation.TypeP2P,
+		"SELECT `m_id`, `session_id`, `seq`, `from`, `to`, `type`, `content`, `send_at`, `status` FROM im_chat_message WHERE `send_at` < ? ORDER BY `send_at` LIMIT ?",
+		before, limit)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := D.queryArchivable(conversation.TypeChannel,
+		"SELECT `m_id`, `channel_id`, `seq`, `from`, `channel_id`, `type`, `content`, `send_at`, `status` FROM im_channel_message WHERE `send_at` < ? ORDER BY `send_at` LIMIT ?",
+		before, limit)
+	if err != nil {
+		return nil, err
+	}
+	ret := append(chat, channel...)
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Message.SendAt < ret[j].Message.SendAt
+	})
+	if len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
+
+func (D *ChatMessageStore) queryArchivable(t conversation.Type, query string, args ...interface{}) ([]*store.ArchivedMessage, error) {
+	rows, err := D.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []*store.ArchivedMessage
+	for rows.Next() {
+		m := &messages.ChatMessage{}
+		var target string
+		var status int
+		err = rows.Scan(&m.Mid, &target, &m.Seq, &m.From, &m.To, &m.Type, &m.Content, &m.SendAt, &status)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &store.ArchivedMessage{
+			Conversation: conversation.NewID(t, target),
+			Recalled:     status == messageStatusRecalled,
+			Message:      m,
+		})
+	}
+	return ret, rows.Err()
+}
+
+// RemoveArchived deletes archived messages by id, the edit history in im_chat_message_edit is kept.
+func (D *ChatMessageStore) RemoveArchived(ms []*store.ArchivedMessage) error {
+	var chat, channel []interface{}
+	for _, m := range ms {
+		if m.Conversation.Type() == conversation.TypeChannel {
+			channel = append(channel, m.Message.Mid)
+		} else {
+			chat = append(chat, m.Message.Mid)
+		}
+	}
+	if len(chat) > 0 {
+		_, err := D.db.Exec("DELETE FROM im_chat_message WHERE `m_id` IN ("+placeholders(len(chat))+")", chat...)
+		if err != nil {
+			return err
+		}
+	}
+	if len(channel) > 0 {
+		_, err := D.db.Exec("DELETE FROM im_channel_message WHERE `m_id` IN ("+placeholders(len(channel))+")", channel...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (D *ChatMessageStore) RestoreArchived(ms []*store.ArchivedMessage) error {
+	for _, am := range ms {
+		m := am.Message
+		status := 0
+		if am.Recalled {
+			status = messageStatusRecalled
+		}
+		var err error
+		if am.Conversation.Type() == conversation.TypeChannel {
+			_, err = D.db.Exec(
+				"INSERT IGNORE INTO im_channel_message (`m_id`, `channel_id`, `seq`, `from`, `type`, `content`, `send_at`, `status`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				m.Mid, am.Conversation.Target(), m.Seq, m.From, m.Type, m.Content, m.SendAt, status)
+		} else {
+			_, err = D.db.Exec(
+				"INSERT IGNORE INTO im_chat_message (`m_id`, `session_id`, `seq`, `from`, `to`, `type`, `content`, `send_at`, `create_at`, `cli_seq`, `status`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				m.Mid, am.Conversation.Target(), m.Seq, m.From, m.To, m.Type, m.Content, m.SendAt, m.SendAt, 0, status)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,54 @@
+package message_store_db
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/store"
+	"sort"
+	"strings"
+)
+
+var _ store.ConversationStore = &ChatMessageStore{}
+
+// GetRecentConversations returns the latest messages of P2P sessions of uid and the channels, the content of recalled
+// message is empty.
+func (D *ChatMessageStore) GetRecentConversations(uid string, channels []string, limit int) ([]*store.ConversationSummary, error) {
+	p2p, err := D.queryMessages(
+		"SELECT m.`m_id`, m.`seq`, m.`from`, m.`to`, m.`type`, IF(m.`status` = ?, '', m.`content`), m.`send_at`, m.`parent_id`, m.`thread_id` FROM im_chat_message m "+
+			"JOIN (SELECT `session_id`, MAX(`seq`) AS `seq` FROM im_chat_message WHERE `from` = ? OR `to` = ? GROUP BY `session_id`) l "+
+			"ON m.`session_id` = l.`session_id` AND m.`seq` = l.`seq` ORDER BY m.`send_at` DESC LIMIT ?",
+		messageStatusRecalled, uid, uid, limit)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*store.ConversationSummary
+	for _, m := range p2p {
+		ret = append(ret, &store.ConversationSummary{Conversation: conversation.NewP2P(m.From, m.To).ID, Last: m})
+	}
+
+	if len(channels) > 0 {
+		args := []interface{}{messageStatusRecalled}
+		for _, ch := range channels {
+			args = append(args, ch)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(channels)), ", ")
+		ms, err := D.queryMessages(
+			"SELECT m.`m_id`, m.`seq`, m.`from`, m.`channel_id`, m.`type`, IF(m.`status` = ?, '', m.`content`), m.`send_at`, m.`parent_id`, m.`thread_id` FROM im_channel_message m "+
+				"JOIN (SELECT `channel_id`, MAX(`seq`) AS `seq` FROM im_channel_message WHERE `channel_id` IN ("+placeholders+") GROUP BY `channel_id`) l "+
+				"ON m.`channel_id` = l.`channel_id` AND m.`seq` = l.`seq`",
+			args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range ms {
+			ret = append(ret, &store.ConversationSummary{Conversation: conversation.NewChannel(m.To).ID, Last: m})
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Last.SendAt > ret[j].Last.SendAt
+	})
+	if len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
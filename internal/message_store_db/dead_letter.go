@@ -0,0 +1,72 @@
+package message_store_db
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+)
+
+var _ store.DeadLetterStore = &ChatMessageStore{}
+
+// StoreDeadLetter stores the dead letter in im_dead_letter, the message is encoded in json.
+func (D *ChatMessageStore) StoreDeadLetter(l *store.DeadLetter) error {
+	b, err := json.Marshal(l.Message)
+	if err != nil {
+		return err
+	}
+	_, err = D.db.Exec("INSERT INTO im_dead_letter (`id`, `reason`, `error`, `failed_at`, `message`) VALUES (?, ?, ?, ?, ?)",
+		l.ID, l.Reason, l.Error, l.FailedAt, string(b))
+	return err
+}
+
+func (D *ChatMessageStore) GetDeadLetters(after int64, limit int) ([]*store.DeadLetter, error) {
+	rows, err := D.db.Query("SELECT `id`, `reason`, `error`, `failed_at`, `message` FROM im_dead_letter WHERE `id` > ? ORDER BY `id` LIMIT ?", after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []*store.DeadLetter
+	for rows.Next() {
+		l, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, l)
+	}
+	return ret, rows.Err()
+}
+
+func (D *ChatMessageStore) RemoveDeadLetter(id int64) (*store.DeadLetter, error) {
+	row := D.db.QueryRow("SELECT `id`, `reason`, `error`, `failed_at`, `message` FROM im_dead_letter WHERE `id` = ?", id)
+	l, err := scanDeadLetter(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r, err := D.db.Exec("DELETE FROM im_dead_letter WHERE `id` = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	// removed by others concurrently.
+	if affected, err := r.RowsAffected(); err != nil || affected == 0 {
+		return nil, err
+	}
+	return l, nil
+}
+
+func scanDeadLetter(row interface{ Scan(dest ...any) error }) (*store.DeadLetter, error) {
+	l := &store.DeadLetter{Message: &messages.ChatMessage{}}
+	var b string
+	if err := row.Scan(&l.ID, &l.Reason, &l.Error, &l.FailedAt, &b); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(b), l.Message); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
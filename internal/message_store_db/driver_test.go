@@ -0,0 +1,158 @@
+package message_store_db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeStmt is a statement executed by the store.
+type fakeStmt struct {
+	query string
+	args  []driver.Value
+	tx    bool
+}
+
+// fakeResult is the response of statements contain the key.
+type fakeResult struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+// fakeDB records statements executed and responds with results of the first key contained in the statement, the
+// statement without result affects 1 row.
+type fakeDB struct {
+	mu        sync.Mutex
+	stmts     []fakeStmt
+	results   map[string]*fakeResult
+	committed int
+	rollback  int
+	inTx      bool
+}
+
+func newFakeStore() (*ChatMessageStore, *fakeDB) {
+	f := &fakeDB{results: map[string]*fakeResult{}}
+	return &ChatMessageStore{db: sql.OpenDB(f)}, f
+}
+
+func (f *fakeDB) respond(key string, r *fakeResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[key] = r
+}
+
+// executed returns statements contain the key.
+func (f *fakeDB) executed(key string) []fakeStmt {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ret []fakeStmt
+	for _, s := range f.stmts {
+		if strings.Contains(s.query, key) {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func (f *fakeDB) record(query string, args []driver.NamedValue) *fakeResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := fakeStmt{query: query, tx: f.inTx}
+	for _, a := range args {
+		s.args = append(s.args, a.Value)
+	}
+	f.stmts = append(f.stmts, s)
+	for key, r := range f.results {
+		if strings.Contains(query, key) {
+			return r
+		}
+	}
+	return &fakeResult{affected: 1}
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{f: f}, nil
+}
+
+func (f *fakeDB) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	f *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.inTx = true
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.inTx = false
+	c.f.committed++
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.inTx = false
+	c.f.rollback++
+	return nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	r := c.f.record(query, args)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return driver.RowsAffected(r.affected), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r := c.f.record(query, args)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &fakeRows{columns: r.columns, rows: r.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,68 @@
+package message_store_db
+
+import (
+	"database/sql"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+)
+
+var _ store.JoinRequestStore = &ChatMessageStore{}
+
+// AddJoinRequest upserts the pending request in im_channel_join_request.
+func (D *ChatMessageStore) AddJoinRequest(r *messages.JoinRequest) error {
+	_, err := D.db.Exec(
+		"INSERT INTO im_channel_join_request (`channel_id`, `uid`, `reason`, `request_at`) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE `reason` = VALUES(`reason`), `request_at` = VALUES(`request_at`)",
+		r.Channel, r.Uid, r.Reason, r.RequestAt)
+	return err
+}
+
+func (D *ChatMessageStore) RemoveJoinRequest(channel string, uid string) (*messages.JoinRequest, error) {
+	tx, err := D.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	r := &messages.JoinRequest{Channel: channel, Uid: uid, Status: messages.JoinStatusPending}
+	row := tx.QueryRow("SELECT `reason`, `request_at` FROM im_channel_join_request WHERE `channel_id` = ? AND `uid` = ? FOR UPDATE", channel, uid)
+	err = row.Scan(&r.Reason, &r.RequestAt)
+	if err == sql.ErrNoRows {
+		return nil, tx.Rollback()
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	_, err = tx.Exec("DELETE FROM im_channel_join_request WHERE `channel_id` = ? AND `uid` = ?", channel, uid)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return r, tx.Commit()
+}
+
+func (D *ChatMessageStore) GetJoinRequests(channel string) ([]*messages.JoinRequest, error) {
+	rows, err := D.db.Query("SELECT `uid`, `reason`, `request_at` FROM im_channel_join_request WHERE `channel_id` = ? ORDER BY `request_at`", channel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []*messages.JoinRequest
+	for rows.Next() {
+		r := &messages.JoinRequest{Channel: channel, Status: messages.JoinStatusPending}
+		err = rows.Scan(&r.Uid, &r.Reason, &r.RequestAt)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, r)
+	}
+	return ret, rows.Err()
+}
+
+func (D *ChatMessageStore) RemoveJoinRequestsBefore(before int64) (int64, error) {
+	r, err := D.db.Exec("DELETE FROM im_channel_join_request WHERE `request_at` < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return r.RowsAffected()
+}
@@ -2,16 +2,47 @@ package message_store_db
 
 import (
 	"database/sql"
+	_ "embed"
+	"errors"
 	"fmt"
 	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/pkg/conversation"
 	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/snowflake"
 	"github.com/glide-im/glide/pkg/store"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/go-sql-driver/mysql"
 	"strconv"
+	"strings"
 	"time"
 )
 
-var _ store.MessageStore = &ChatMessageStore{}
+var _ store.MessageHistoryStore = &ChatMessageStore{}
+var _ store.BatchMessageStore = &ChatMessageStore{}
+var _ store.OfflineRemoveStore = &ChatMessageStore{}
+var _ store.ThreadStore = &ChatMessageStore{}
+var _ store.Pinger = &ChatMessageStore{}
+
+const (
+	messageStatusRecalled = 2
+)
+
+//go:embed schema.sql
+var schema string
+
+// columns added after tables created by schema.sql, duplicate column and key errors are ignored by Migrate.
+var alters = []string{
+	"ALTER TABLE im_chat_message ADD COLUMN `parent_id` BIGINT NOT NULL DEFAULT 0, ADD COLUMN `thread_id` BIGINT NOT NULL DEFAULT 0",
+	"ALTER TABLE im_chat_message ADD KEY `idx_session_thread` (`session_id`, `thread_id`)",
+	"ALTER TABLE im_channel_message ADD COLUMN `parent_id` BIGINT NOT NULL DEFAULT 0, ADD COLUMN `thread_id` BIGINT NOT NULL DEFAULT 0",
+	"ALTER TABLE im_channel_message ADD KEY `idx_channel_thread` (`channel_id`, `thread_id`)",
+	"ALTER TABLE im_chat_message_edit ADD COLUMN `channel_id` VARCHAR(64) NOT NULL DEFAULT '' AFTER `m_id`",
+}
+
+const (
+	errDupFieldName = 1060
+	errDupKeyName   = 1061
+)
 
 type ChatMessageStore struct {
 	db *sql.DB
@@ -19,10 +50,22 @@ type ChatMessageStore struct {
 
 func New(conf *config.MySqlConf) (*ChatMessageStore, error) {
 	mysqlUrl := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", conf.Username, conf.Password, conf.Host, conf.Port, conf.Db)
+	if conf.Timeout > 0 {
+		mysqlUrl = fmt.Sprintf("%s?timeout=%ds&readTimeout=%ds&writeTimeout=%ds", mysqlUrl, conf.Timeout, conf.Timeout, conf.Timeout)
+	}
 	db, err := sql.Open("mysql", mysqlUrl)
 	if err != nil {
 		return nil, err
 	}
+	if conf.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(conf.MaxOpenConns)
+	}
+	if conf.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(conf.MaxIdleConns)
+	}
+	if conf.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(time.Duration(conf.ConnMaxLifetime) * time.Second)
+	}
 	err = db.Ping()
 	if err != nil {
 		return nil, err
@@ -33,9 +76,18 @@ func New(conf *config.MySqlConf) (*ChatMessageStore, error) {
 	return m, nil
 }
 
+func (D *ChatMessageStore) Ping() error {
+	return D.db.Ping()
+}
+
 func (D *ChatMessageStore) StoreOffline(message *messages.ChatMessage) error {
-	//TODO implement me
-	panic("implement me")
+	_, err := D.db.Exec("INSERT IGNORE INTO im_offline_message (`uid`, `m_id`) VALUES (?, ?)", message.To, message.Mid)
+	return err
+}
+
+func (D *ChatMessageStore) RemoveOffline(uid string, mid int64) error {
+	_, err := D.db.Exec("DELETE FROM im_offline_message WHERE `uid` = ? AND `m_id` = ?", uid, mid)
+	return err
 }
 
 func (D *ChatMessageStore) StoreMessage(m *messages.ChatMessage) error {
@@ -49,18 +101,14 @@ func (D *ChatMessageStore) StoreMessage(m *messages.ChatMessage) error {
 		return nil
 	}
 
-	lg := from
-	sm := to
-	if lg < sm {
-		lg, sm = sm, lg
-	}
-	sid := fmt.Sprintf("%d_%d", lg, sm)
+	sid := conversation.NewP2P(m.From, m.To).ID.Target()
 
 	// todo update the type of user id to string
 	//mysql only
+	// m_id is auto increment if the message id is not assigned yet.
 	s, e := D.db.Exec(
-		"INSERT INTO im_chat_message (`session_id`, `from`, `to`, `type`, `content`, `send_at`, `create_at`, `cli_seq`, `status`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)ON DUPLICATE KEY UPDATE send_at=?",
-		sid, from, to, m.Type, m.Content, m.SendAt, time.Now().Unix(), 0, 0, m.SendAt)
+		"INSERT INTO im_chat_message (`m_id`, `session_id`, `seq`, `from`, `to`, `type`, `content`, `send_at`, `create_at`, `cli_seq`, `status`, `parent_id`, `thread_id`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)ON DUPLICATE KEY UPDATE send_at=?",
+		m.Mid, sid, m.Seq, from, to, m.Type, m.Content, m.SendAt, time.Now().Unix(), 0, 0, m.Parent, m.Thread, m.SendAt)
 	if e != nil {
 		return e
 	}
@@ -68,6 +116,204 @@ func (D *ChatMessageStore) StoreMessage(m *messages.ChatMessage) error {
 	return nil
 }
 
+// StoreMessages stores messages in one statement, the message id must be assigned.
+func (D *ChatMessageStore) StoreMessages(ms []*messages.ChatMessage) error {
+	if len(ms) == 0 {
+		return nil
+	}
+	now := time.Now().Unix()
+	values := make([]string, 0, len(ms))
+	args := make([]interface{}, 0, len(ms)*13)
+	for _, m := range ms {
+		from, err := strconv.ParseInt(m.From, 10, 64)
+		if err != nil {
+			continue
+		}
+		to, err := strconv.ParseInt(m.To, 10, 64)
+		if err != nil {
+			continue
+		}
+		sid := conversation.NewP2P(m.From, m.To).ID.Target()
+		values = append(values, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, m.Mid, sid, m.Seq, from, to, m.Type, m.Content, m.SendAt, now, 0, 0, m.Parent, m.Thread)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	_, err := D.db.Exec(
+		"INSERT INTO im_chat_message (`m_id`, `session_id`, `seq`, `from`, `to`, `type`, `content`, `send_at`, `create_at`, `cli_seq`, `status`, `parent_id`, `thread_id`) VALUES "+
+			strings.Join(values, ", ")+" ON DUPLICATE KEY UPDATE send_at=VALUES(send_at)", args...)
+	return err
+}
+
+// GetMessage returns the message of the conversation, P2P messages and channel messages are stored in different tables,
+// whose ids are auto incremented separately.
+func (D *ChatMessageStore) GetMessage(c conversation.ID, mid int64) (*messages.ChatMessage, error) {
+	m := &messages.ChatMessage{Mid: mid}
+	switch c.Type() {
+	case conversation.TypeP2P:
+		var from, to int64
+		row := D.db.QueryRow("SELECT `from`, `to`, `type`, `content`, `send_at`, `parent_id`, `thread_id` FROM im_chat_message WHERE `m_id` = ? AND `session_id` = ?",
+			mid, c.Target())
+		err := row.Scan(&from, &to, &m.Type, &m.Content, &m.SendAt, &m.Parent, &m.Thread)
+		if err != nil {
+			return nil, err
+		}
+		m.From = strconv.FormatInt(from, 10)
+		m.To = strconv.FormatInt(to, 10)
+	case conversation.TypeChannel:
+		row := D.db.QueryRow("SELECT `from`, `channel_id`, `type`, `content`, `send_at`, `parent_id`, `thread_id` FROM im_channel_message WHERE `m_id` = ? AND `channel_id` = ?",
+			mid, c.Target())
+		err := row.Scan(&m.From, &m.To, &m.Type, &m.Content, &m.SendAt, &m.Parent, &m.Thread)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, conversation.ErrInvalidID
+	}
+	return m, nil
+}
+
+func (D *ChatMessageStore) MarkRecalled(c conversation.ID, mid int64) error {
+	var err error
+	switch c.Type() {
+	case conversation.TypeP2P:
+		_, err = D.db.Exec("UPDATE im_chat_message SET `status` = ? WHERE `m_id` = ? AND `session_id` = ?", messageStatusRecalled, mid, c.Target())
+	case conversation.TypeChannel:
+		_, err = D.db.Exec("UPDATE im_channel_message SET `status` = ? WHERE `m_id` = ? AND `channel_id` = ?", messageStatusRecalled, mid, c.Target())
+	default:
+		err = conversation.ErrInvalidID
+	}
+	return err
+}
+
+// EditMessage updates message content, and saves the previous content to im_chat_message_edit as edit history, the
+// channel_id of the history is empty for P2P messages. The recalled message can not be edited.
+func (D *ChatMessageStore) EditMessage(c conversation.ID, mid int64, content string, editAt int64) error {
+	var table, key, channel string
+	switch c.Type() {
+	case conversation.TypeP2P:
+		table, key = "im_chat_message", "session_id"
+	case conversation.TypeChannel:
+		table, key, channel = "im_channel_message", "channel_id", c.Target()
+	default:
+		return conversation.ErrInvalidID
+	}
+	tx, err := D.db.Begin()
+	if err != nil {
+		return err
+	}
+	// the row is locked until committed, so it's not recalled meanwhile.
+	var status int
+	err = tx.QueryRow("SELECT `status` FROM "+table+" WHERE `m_id` = ? AND `"+key+"` = ? FOR UPDATE", mid, c.Target()).Scan(&status)
+	if err == nil && status == messageStatusRecalled {
+		err = store.ErrMessageRecalled
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO im_chat_message_edit (`m_id`, `channel_id`, `content`, `edit_at`) SELECT `m_id`, ?, `content`, ? FROM "+table+" WHERE `m_id` = ?",
+		channel, editAt, mid)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("UPDATE "+table+" SET `content` = ? WHERE `m_id` = ?", content, mid)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (D *ChatMessageStore) StoreChannelMessage(ch subscription.ChanID, m *messages.ChatMessage) error {
+	s, err := D.db.Exec(
+		"INSERT INTO im_channel_message (`channel_id`, `seq`, `from`, `type`, `content`, `send_at`, `status`, `parent_id`, `thread_id`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE send_at=?",
+		string(ch), m.Seq, m.From, m.Type, m.Content, m.SendAt, 0, m.Parent, m.Thread, m.SendAt)
+	if err != nil {
+		return err
+	}
+	m.Mid, _ = s.LastInsertId()
+	return nil
+}
+
+// GetBySeqRange returns messages of the conversation, the content of recalled message is empty.
+func (D *ChatMessageStore) GetBySeqRange(c conversation.ID, start int64, end int64) ([]*messages.ChatMessage, error) {
+	switch c.Type() {
+	case conversation.TypeP2P:
+		return D.queryMessages(
+			"SELECT `m_id`, `seq`, `from`, `to`, `type`, IF(`status` = ?, '', `content`), `send_at`, `parent_id`, `thread_id` FROM im_chat_message WHERE `session_id` = ? AND `seq` BETWEEN ? AND ? ORDER BY `seq`",
+			messageStatusRecalled, c.Target(), start, end)
+	case conversation.TypeChannel:
+		return D.queryMessages(
+			"SELECT `m_id`, `seq`, `from`, `channel_id`, `type`, IF(`status` = ?, '', `content`), `send_at`, `parent_id`, `thread_id` FROM im_channel_message WHERE `channel_id` = ? AND `seq` BETWEEN ? AND ? ORDER BY `seq`",
+			messageStatusRecalled, c.Target(), start, end)
+	default:
+		return nil, conversation.ErrInvalidID
+	}
+}
+
+// GetThread returns the root message and replies of the thread, the content of recalled message is empty.
+func (D *ChatMessageStore) GetThread(c conversation.ID, thread int64, start int64, limit int) ([]*messages.ChatMessage, error) {
+	switch c.Type() {
+	case conversation.TypeP2P:
+		return D.queryMessages(
+			"SELECT `m_id`, `seq`, `from`, `to`, `type`, IF(`status` = ?, '', `content`), `send_at`, `parent_id`, `thread_id` FROM im_chat_message WHERE `session_id` = ? AND (`m_id` = ? OR `thread_id` = ?) AND `seq` >= ? ORDER BY `seq` LIMIT ?",
+			messageStatusRecalled, c.Target(), thread, thread, start, limit)
+	case conversation.TypeChannel:
+		return D.queryMessages(
+			"SELECT `m_id`, `seq`, `from`, `channel_id`, `type`, IF(`status` = ?, '', `content`), `send_at`, `parent_id`, `thread_id` FROM im_channel_message WHERE `channel_id` = ? AND (`m_id` = ? OR `thread_id` = ?) AND `seq` >= ? ORDER BY `seq` LIMIT ?",
+			messageStatusRecalled, c.Target(), thread, thread, start, limit)
+	default:
+		return nil, conversation.ErrInvalidID
+	}
+}
+
+func (D *ChatMessageStore) queryMessages(query string, args ...interface{}) ([]*messages.ChatMessage, error) {
+	rows, err := D.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []*messages.ChatMessage
+	for rows.Next() {
+		m := &messages.ChatMessage{}
+		err = rows.Scan(&m.Mid, &m.Seq, &m.From, &m.To, &m.Type, &m.Content, &m.SendAt, &m.Parent, &m.Thread)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, m)
+	}
+	return ret, rows.Err()
+}
+
+// Migrate creates tables in schema.sql if not exists, and adds columns missing in tables created before.
+func (D *ChatMessageStore) Migrate() error {
+	for _, stmt := range strings.Split(schema, ";") {
+		stmt = strings.TrimSpace(stmt)
+		if stmt == "" {
+			continue
+		}
+		_, err := D.db.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+	for _, stmt := range alters {
+		_, err := D.db.Exec(stmt)
+		var me *mysql.MySQLError
+		if errors.As(err, &me) && (me.Number == errDupFieldName || me.Number == errDupKeyName) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var _ store.MessageStore = &IdleChatMessageStore{}
 
 type IdleChatMessageStore struct {
@@ -78,6 +324,6 @@ func (i *IdleChatMessageStore) StoreOffline(message *messages.ChatMessage) error
 }
 
 func (i *IdleChatMessageStore) StoreMessage(message *messages.ChatMessage) error {
-	message.Mid = time.Now().Unix()
+	message.Mid = snowflake.Generate()
 	return nil
 }
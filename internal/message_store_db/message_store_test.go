@@ -0,0 +1,143 @@
+package message_store_db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+var messageColumns = []string{"m_id", "seq", "from", "to", "type", "content", "send_at", "parent_id", "thread_id"}
+
+func TestChatMessageStore_GetBySeqRange(t *testing.T) {
+	s, f := newFakeStore()
+	f.respond("FROM im_chat_message", &fakeResult{columns: messageColumns, rows: [][]driver.Value{
+		{int64(10), int64(1), int64(1), int64(2), int64(1), "hi", int64(100), int64(0), int64(0)},
+	}})
+	f.respond("FROM im_channel_message", &fakeResult{columns: messageColumns, rows: [][]driver.Value{
+		{int64(20), int64(5), int64(1), "g", int64(1), "hello", int64(100), int64(0), int64(0)},
+	}})
+
+	p2p := conversation.NewP2P("2", "1").ID
+	ms, err := s.GetBySeqRange(p2p, 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, ms, 1)
+	assert.Equal(t, "2", ms[0].To)
+	stmt := f.executed("FROM im_chat_message")[0]
+	assert.Equal(t, []driver.Value{int64(messageStatusRecalled), p2p.Target(), int64(1), int64(10)}, stmt.args)
+
+	ms, err = s.GetBySeqRange(conversation.NewChannel("g").ID, 5, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, "g", ms[0].To)
+	assert.Equal(t, int64(5), ms[0].Seq)
+	stmt = f.executed("FROM im_channel_message")[0]
+	assert.Equal(t, []driver.Value{int64(messageStatusRecalled), "g", int64(5), int64(6)}, stmt.args)
+
+	_, err = s.GetBySeqRange("invalid", 1, 2)
+	assert.Error(t, err)
+}
+
+func TestChatMessageStore_GetThread(t *testing.T) {
+	s, f := newFakeStore()
+	f.respond("FROM im_channel_message", &fakeResult{columns: messageColumns, rows: [][]driver.Value{
+		{int64(20), int64(5), int64(1), "g", int64(1), "root", int64(100), int64(0), int64(0)},
+		{int64(21), int64(6), int64(2), "g", int64(1), "reply", int64(101), int64(20), int64(20)},
+	}})
+
+	ms, err := s.GetThread(conversation.NewChannel("g").ID, 20, 5, 50)
+	assert.NoError(t, err)
+	assert.Len(t, ms, 2)
+	assert.Equal(t, int64(20), ms[1].Thread)
+	stmt := f.executed("FROM im_channel_message")[0]
+	assert.Equal(t, []driver.Value{int64(messageStatusRecalled), "g", int64(20), int64(20), int64(5), int64(50)}, stmt.args)
+}
+
+func TestChatMessageStore_GetMessage(t *testing.T) {
+	s, f := newFakeStore()
+	f.respond("FROM im_chat_message", &fakeResult{columns: messageColumns[2:], rows: [][]driver.Value{
+		{int64(1), int64(2), int64(1), "hi", int64(100), int64(0), int64(0)},
+	}})
+	f.respond("FROM im_channel_message", &fakeResult{columns: messageColumns[2:], rows: [][]driver.Value{
+		{"1", "g", int64(1), "hello", int64(100), int64(0), int64(0)},
+	}})
+
+	p2p := conversation.NewP2P("1", "2").ID
+	m, err := s.GetMessage(p2p, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", m.Content)
+	assert.Equal(t, "2", m.To)
+	assert.Equal(t, []driver.Value{int64(10), p2p.Target()}, f.executed("FROM im_chat_message")[0].args)
+
+	// the channel message is queried in the channel table, whose id may be same as a P2P message.
+	m, err = s.GetMessage(conversation.NewChannel("g").ID, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", m.Content)
+	assert.Equal(t, "g", m.To)
+	assert.Equal(t, []driver.Value{int64(10), "g"}, f.executed("FROM im_channel_message")[0].args)
+	assert.Len(t, f.executed("FROM im_chat_message"), 1)
+
+	_, err = s.GetMessage("invalid", 10)
+	assert.Error(t, err)
+}
+
+func TestChatMessageStore_MarkRecalled(t *testing.T) {
+	s, f := newFakeStore()
+
+	p2p := conversation.NewP2P("1", "2").ID
+	assert.NoError(t, s.MarkRecalled(p2p, 10))
+	stmts := f.executed("UPDATE im_chat_message")
+	assert.Len(t, stmts, 1)
+	assert.Equal(t, []driver.Value{int64(messageStatusRecalled), int64(10), p2p.Target()}, stmts[0].args)
+
+	assert.NoError(t, s.MarkRecalled(conversation.NewChannel("g").ID, 10))
+	stmts = f.executed("UPDATE im_channel_message")
+	assert.Len(t, stmts, 1)
+	assert.Equal(t, []driver.Value{int64(messageStatusRecalled), int64(10), "g"}, stmts[0].args)
+	// the P2P message of the same id is not recalled.
+	assert.Len(t, f.executed("UPDATE im_chat_message"), 1)
+}
+
+func TestChatMessageStore_EditMessage(t *testing.T) {
+	s, f := newFakeStore()
+	f.respond("SELECT `status` FROM im_chat_message", &fakeResult{columns: []string{"status"}, rows: [][]driver.Value{{int64(0)}}})
+	f.respond("SELECT `status` FROM im_channel_message", &fakeResult{columns: []string{"status"}, rows: [][]driver.Value{{int64(0)}}})
+
+	p2p := conversation.NewP2P("1", "2").ID
+	assert.NoError(t, s.EditMessage(p2p, 10, "edited", 100))
+	assert.Equal(t, []driver.Value{int64(10), p2p.Target()}, f.executed("SELECT `status` FROM im_chat_message")[0].args)
+	history := f.executed("INSERT INTO im_chat_message_edit")
+	assert.Len(t, history, 1)
+	assert.Contains(t, history[0].query, "FROM im_chat_message WHERE")
+	assert.Equal(t, []driver.Value{"", int64(100), int64(10)}, history[0].args)
+	assert.Len(t, f.executed("UPDATE im_chat_message SET `content`"), 1)
+	assert.Equal(t, 1, f.committed)
+
+	assert.NoError(t, s.EditMessage(conversation.NewChannel("g").ID, 10, "edited", 100))
+	history = f.executed("INSERT INTO im_chat_message_edit")
+	assert.Contains(t, history[1].query, "FROM im_channel_message WHERE")
+	assert.Equal(t, []driver.Value{"g", int64(100), int64(10)}, history[1].args)
+	stmts := f.executed("UPDATE im_channel_message SET `content`")
+	assert.Len(t, stmts, 1)
+	assert.True(t, stmts[0].tx)
+	assert.Len(t, f.executed("UPDATE im_chat_message SET `content`"), 1)
+}
+
+func TestChatMessageStore_EditMessage_Recalled(t *testing.T) {
+	s, f := newFakeStore()
+	f.respond("SELECT `status`", &fakeResult{columns: []string{"status"}, rows: [][]driver.Value{{int64(messageStatusRecalled)}}})
+
+	err := s.EditMessage(conversation.NewChannel("g").ID, 10, "edited", 100)
+	assert.ErrorIs(t, err, store.ErrMessageRecalled)
+	assert.Empty(t, f.executed("INSERT INTO im_chat_message_edit"))
+	assert.Empty(t, f.executed("UPDATE im_"))
+	assert.Equal(t, 1, f.rollback)
+
+	// the message is not found in the conversation.
+	f.respond("SELECT `status`", &fakeResult{columns: []string{"status"}})
+	err = s.EditMessage(conversation.NewP2P("1", "2").ID, 10, "edited", 100)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.Empty(t, f.executed("UPDATE im_"))
+}
@@ -0,0 +1,81 @@
+package message_store_db
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"strings"
+)
+
+var _ store.UserPurgeStore = &ChatMessageStore{}
+
+// purgeStatements deletes data of the user in order, every placeholder is the uid, the edit history and offline queue
+// entries are deleted before the messages they refer to.
+var purgeStatements = []string{
+	"DELETE e FROM im_chat_message_edit e JOIN im_chat_message m ON e.`m_id` = m.`m_id` AND e.`channel_id` = '' WHERE m.`from` = ? OR m.`to` = ?",
+	"DELETE e FROM im_chat_message_edit e JOIN im_channel_message m ON e.`m_id` = m.`m_id` AND e.`channel_id` = m.`channel_id` WHERE m.`from` = ?",
+	"DELETE o FROM im_offline_message o JOIN im_chat_message m ON o.`m_id` = m.`m_id` WHERE m.`from` = ? OR m.`to` = ?",
+	"DELETE FROM im_offline_message WHERE `uid` = ?",
+	"DELETE FROM im_chat_message WHERE `from` = ? OR `to` = ?",
+	"DELETE FROM im_channel_message WHERE `from` = ?",
+	"DELETE FROM im_read_cursor WHERE `uid` = ?",
+	"DELETE FROM im_scheduled_message WHERE `from` = ?",
+	"DELETE FROM im_dead_letter WHERE JSON_UNQUOTE(JSON_EXTRACT(`message`, '$.from')) = ? OR JSON_UNQUOTE(JSON_EXTRACT(`message`, '$.to')) = ?",
+}
+
+// PurgeUser deletes P2P messages sent or received by uid and channel messages sent by uid with the edit history, the
+// offline queue, read cursors, scheduled messages and dead letters of uid in a transaction.
+func (D *ChatMessageStore) PurgeUser(uid string) error {
+	tx, err := D.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range purgeStatements {
+		args := make([]interface{}, strings.Count(stmt, "?"))
+		for i := range args {
+			args[i] = uid
+		}
+		_, err = tx.Exec(stmt, args...)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+var _ store.ChannelPurgeStore = &ChatMessageStore{}
+
+// channelPurgeStatements deletes data of the channel, the placeholder is the channel id or the conversation id of the
+// channel as the column.
+var channelPurgeStatements = []struct {
+	stmt         string
+	conversation bool
+}{
+	{"DELETE FROM im_chat_message_edit WHERE `channel_id` = ?", false},
+	{"DELETE FROM im_channel_message WHERE `channel_id` = ?", false},
+	{"DELETE FROM im_channel_join_request WHERE `channel_id` = ?", false},
+	{"DELETE FROM im_read_cursor WHERE `conversation` = ?", true},
+	{"DELETE FROM im_sequence WHERE `conversation` = ?", true},
+}
+
+// PurgeChannel deletes messages with the edit history, join requests, read cursors and the sequence of the channel in a transaction.
+func (D *ChatMessageStore) PurgeChannel(ch subscription.ChanID) error {
+	conv := string(conversation.NewChannel(string(ch)).ID)
+	tx, err := D.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, s := range channelPurgeStatements {
+		arg := string(ch)
+		if s.conversation {
+			arg = conv
+		}
+		_, err = tx.Exec(s.stmt, arg)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
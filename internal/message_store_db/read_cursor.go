@@ -0,0 +1,79 @@
+package message_store_db
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"strings"
+)
+
+var _ store.ReadCursorStore = &ChatMessageStore{}
+var _ store.ReaderStore = &ChatMessageStore{}
+
+// UpdateReadCursor upserts the read cursor in im_read_cursor, the cursor never moves backward.
+func (D *ChatMessageStore) UpdateReadCursor(uid string, c conversation.ID, seq int64, readAt int64) (bool, error) {
+	r, err := D.db.Exec(
+		"INSERT INTO im_read_cursor (`uid`, `conversation`, `seq`, `read_at`) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE `read_at` = IF(`seq` < VALUES(`seq`), VALUES(`read_at`), `read_at`), `seq` = GREATEST(`seq`, VALUES(`seq`))",
+		uid, string(c), seq, readAt)
+	if err != nil {
+		return false, err
+	}
+	// mysql returns 1 for inserted, 2 for updated and 0 for unchanged.
+	affected, err := r.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (D *ChatMessageStore) GetReadCursors(uid string) ([]*messages.ReadCursor, error) {
+	rows, err := D.db.Query("SELECT `conversation`, `seq`, `read_at` FROM im_read_cursor WHERE `uid` = ?", uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []*messages.ReadCursor
+	for rows.Next() {
+		c := &messages.ReadCursor{}
+		err = rows.Scan(&c.Conversation, &c.Seq, &c.ReadAt)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, c)
+	}
+	return ret, rows.Err()
+}
+
+func (D *ChatMessageStore) GetReadCount(c conversation.ID, seq int64) (int64, error) {
+	var count int64
+	row := D.db.QueryRow("SELECT COUNT(*) FROM im_read_cursor WHERE `conversation` = ? AND `seq` >= ?", string(c), seq)
+	err := row.Scan(&count)
+	return count, err
+}
+
+func (D *ChatMessageStore) GetReaders(c conversation.ID, seq int64, uids []string) ([]string, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{string(c), seq}
+	for _, uid := range uids {
+		args = append(args, uid)
+	}
+	placeholders := strings.Repeat(", ?", len(uids))[2:]
+	rows, err := D.db.Query("SELECT `uid` FROM im_read_cursor WHERE `conversation` = ? AND `seq` >= ? AND `uid` IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []string
+	for rows.Next() {
+		var uid string
+		if err = rows.Scan(&uid); err != nil {
+			return nil, err
+		}
+		ret = append(ret, uid)
+	}
+	return ret, rows.Err()
+}
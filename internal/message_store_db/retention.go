@@ -0,0 +1,126 @@
+package message_store_db
+
+import (
+	"database/sql"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/store"
+	"math"
+)
+
+var _ store.RetentionStore = &ChatMessageStore{}
+
+// GetConversations returns conversations of im_chat_message followed by im_channel_message, the ID of P2P
+// conversations is ordered before channels.
+func (D *ChatMessageStore) GetConversations(after conversation.ID, limit int) ([]conversation.ID, error) {
+	var ret []conversation.ID
+	if after == "" || after.Type() == conversation.TypeP2P {
+		chat, err := D.queryConversations(conversation.TypeP2P,
+			"SELECT DISTINCT `session_id` FROM im_chat_message WHERE `session_id` > ? ORDER BY `session_id` LIMIT ?",
+			after.Target(), limit)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, chat...)
+		after = ""
+	}
+	if len(ret) < limit {
+		channel, err := D.queryConversations(conversation.TypeChannel,
+			"SELECT DISTINCT `channel_id` FROM im_channel_message WHERE `channel_id` > ? ORDER BY `channel_id` LIMIT ?",
+			after.Target(), limit-len(ret))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, channel...)
+	}
+	return ret, nil
+}
+
+func (D *ChatMessageStore) queryConversations(t conversation.Type, query string, args ...interface{}) ([]conversation.ID, error) {
+	rows, err := D.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []conversation.ID
+	for rows.Next() {
+		var target string
+		if err = rows.Scan(&target); err != nil {
+			return nil, err
+		}
+		ret = append(ret, conversation.NewID(t, target))
+	}
+	return ret, rows.Err()
+}
+
+// PurgeMessages deletes messages of the conversation with the edit history of them, and the offline queue entries
+// referring to them for P2P conversations, in a transaction.
+func (D *ChatMessageStore) PurgeMessages(c conversation.ID, before int64, keep int64, limit int) (int64, error) {
+	table, column, channel := "im_chat_message", "session_id", ""
+	if c.Type() == conversation.TypeChannel {
+		table, column, channel = "im_channel_message", "channel_id", c.Target()
+	}
+	// messages which sequence is not greater than the (keep+1)th latest message are purged.
+	seq := int64(math.MinInt64)
+	if keep > 0 {
+		err := D.db.QueryRow("SELECT `seq` FROM "+table+" WHERE `"+column+"` = ? ORDER BY `seq` DESC LIMIT 1 OFFSET ?",
+			c.Target(), keep).Scan(&seq)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, err
+		}
+	}
+	if before <= 0 {
+		before = math.MinInt64
+	}
+
+	tx, err := D.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	mids, err := queryMids(tx, "SELECT `m_id` FROM "+table+" WHERE `"+column+"` = ? AND (`send_at` < ? OR `seq` <= ?) ORDER BY `seq` LIMIT ? FOR UPDATE",
+		c.Target(), before, seq, limit)
+	if err != nil || len(mids) == 0 {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	in := "(" + placeholders(len(mids)) + ")"
+	statements := []string{"DELETE FROM im_chat_message_edit WHERE `channel_id` = ? AND `m_id` IN " + in}
+	args := [][]interface{}{append([]interface{}{channel}, mids...)}
+	// the offline queue refers to P2P messages only.
+	if channel == "" {
+		statements = append(statements, "DELETE FROM im_offline_message WHERE `m_id` IN "+in)
+		args = append(args, mids)
+	}
+	statements = append(statements, "DELETE FROM "+table+" WHERE `"+column+"` = ? AND `m_id` IN "+in)
+	args = append(args, append([]interface{}{c.Target()}, mids...))
+
+	var result sql.Result
+	for i, stmt := range statements {
+		if result, err = tx.Exec(stmt, args[i]...); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func queryMids(tx *sql.Tx, query string, args ...interface{}) ([]interface{}, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mids []interface{}
+	for rows.Next() {
+		var mid int64
+		if err = rows.Scan(&mid); err != nil {
+			return nil, err
+		}
+		mids = append(mids, mid)
+	}
+	return mids, rows.Err()
+}
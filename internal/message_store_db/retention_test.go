@@ -0,0 +1,46 @@
+package message_store_db
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatMessageStore_PurgeMessages(t *testing.T) {
+	s, f := newFakeStore()
+	f.respond("SELECT `m_id`", &fakeResult{columns: []string{"m_id"}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}})
+	f.respond("DELETE FROM im_chat_message WHERE", &fakeResult{affected: 2})
+
+	p2p := conversation.NewP2P("1", "2").ID
+	n, err := s.PurgeMessages(p2p, 100, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	edits := f.executed("DELETE FROM im_chat_message_edit")
+	assert.Len(t, edits, 1)
+	assert.Equal(t, []driver.Value{"", int64(1), int64(2)}, edits[0].args)
+	offline := f.executed("DELETE FROM im_offline_message")
+	assert.Len(t, offline, 1)
+	assert.Equal(t, []driver.Value{int64(1), int64(2)}, offline[0].args)
+	assert.True(t, offline[0].tx)
+	assert.Equal(t, []driver.Value{p2p.Target(), int64(1), int64(2)}, f.executed("DELETE FROM im_chat_message WHERE")[0].args)
+	assert.Equal(t, 1, f.committed)
+
+	// the offline queue does not refer to channel messages.
+	_, err = s.PurgeMessages(conversation.NewChannel("g").ID, 100, 0, 10)
+	assert.NoError(t, err)
+	edits = f.executed("DELETE FROM im_chat_message_edit")
+	assert.Equal(t, []driver.Value{"g", int64(1), int64(2)}, edits[1].args)
+	assert.Len(t, f.executed("DELETE FROM im_offline_message"), 1)
+	assert.Len(t, f.executed("DELETE FROM im_channel_message"), 1)
+	assert.Equal(t, 2, f.committed)
+
+	// nothing to purge.
+	f.respond("SELECT `m_id`", &fakeResult{columns: []string{"m_id"}})
+	n, err = s.PurgeMessages(p2p, 100, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.Len(t, f.executed("DELETE FROM im_chat_message_edit"), 2)
+	assert.Equal(t, 1, f.rollback)
+}
@@ -0,0 +1,60 @@
+package message_store_db
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+)
+
+var _ store.ScheduleStore = &ChatMessageStore{}
+
+// StoreScheduled stores the scheduled message in im_scheduled_message, the message is encoded in json.
+func (D *ChatMessageStore) StoreScheduled(m *store.ScheduledMessage) error {
+	b, err := messages.JsonCodec.Encode(m.Message)
+	if err != nil {
+		return err
+	}
+	_, err = D.db.Exec("INSERT INTO im_scheduled_message (`id`, `from`, `deliver_at`, `message`) VALUES (?, ?, ?, ?)",
+		m.ID, m.From, m.DeliverAt, string(b))
+	return err
+}
+
+func (D *ChatMessageStore) RemoveScheduled(id int64, from string) (bool, error) {
+	query := "DELETE FROM im_scheduled_message WHERE `id` = ?"
+	args := []interface{}{id}
+	if from != "" {
+		query += " AND `from` = ?"
+		args = append(args, from)
+	}
+	r, err := D.db.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := r.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (D *ChatMessageStore) GetScheduled(before int64) ([]*store.ScheduledMessage, error) {
+	rows, err := D.db.Query("SELECT `id`, `from`, `deliver_at`, `message` FROM im_scheduled_message WHERE `deliver_at` < ? ORDER BY `deliver_at`", before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []*store.ScheduledMessage
+	for rows.Next() {
+		m := &store.ScheduledMessage{Message: messages.NewEmptyMessage()}
+		var b string
+		err = rows.Scan(&m.ID, &m.From, &m.DeliverAt, &b)
+		if err != nil {
+			return nil, err
+		}
+		if err = messages.JsonCodec.Decode([]byte(b), m.Message); err != nil {
+			return nil, err
+		}
+		ret = append(ret, m)
+	}
+	return ret, rows.Err()
+}
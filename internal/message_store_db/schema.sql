@@ -0,0 +1,116 @@
+CREATE TABLE IF NOT EXISTS `im_chat_message`
+(
+    `m_id`       BIGINT       NOT NULL AUTO_INCREMENT,
+    `session_id` VARCHAR(64)  NOT NULL,
+    `seq`        BIGINT       NOT NULL DEFAULT 0,
+    `from`       BIGINT       NOT NULL,
+    `to`         BIGINT       NOT NULL,
+    `type`       INT          NOT NULL DEFAULT 0,
+    `content`    TEXT         NOT NULL,
+    `send_at`    BIGINT       NOT NULL DEFAULT 0,
+    `create_at`  BIGINT       NOT NULL DEFAULT 0,
+    `cli_seq`    BIGINT       NOT NULL DEFAULT 0,
+    `status`     INT          NOT NULL DEFAULT 0,
+    `parent_id`  BIGINT       NOT NULL DEFAULT 0,
+    `thread_id`  BIGINT       NOT NULL DEFAULT 0,
+    PRIMARY KEY (`m_id`),
+    UNIQUE KEY `uk_session_seq` (`session_id`, `seq`),
+    KEY `idx_session_thread` (`session_id`, `thread_id`),
+    KEY `idx_send_at` (`send_at`),
+    KEY `idx_from` (`from`),
+    KEY `idx_to` (`to`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `im_chat_message_edit`
+(
+    `id`         BIGINT      NOT NULL AUTO_INCREMENT,
+    `m_id`       BIGINT      NOT NULL,
+    `channel_id` VARCHAR(64) NOT NULL DEFAULT '',
+    `content`    TEXT        NOT NULL,
+    `edit_at`    BIGINT      NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    KEY `idx_m_id` (`m_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `im_channel_message`
+(
+    `m_id`       BIGINT      NOT NULL AUTO_INCREMENT,
+    `channel_id` VARCHAR(64) NOT NULL,
+    `seq`        BIGINT      NOT NULL DEFAULT 0,
+    `from`       VARCHAR(64) NOT NULL,
+    `type`       INT         NOT NULL DEFAULT 0,
+    `content`    TEXT        NOT NULL,
+    `send_at`    BIGINT      NOT NULL DEFAULT 0,
+    `status`     INT         NOT NULL DEFAULT 0,
+    `parent_id`  BIGINT      NOT NULL DEFAULT 0,
+    `thread_id`  BIGINT      NOT NULL DEFAULT 0,
+    PRIMARY KEY (`m_id`),
+    UNIQUE KEY `uk_channel_seq` (`channel_id`, `seq`),
+    KEY `idx_channel_thread` (`channel_id`, `thread_id`),
+    KEY `idx_send_at` (`send_at`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `im_offline_message`
+(
+    `id`   BIGINT      NOT NULL AUTO_INCREMENT,
+    `uid`  VARCHAR(64) NOT NULL,
+    `m_id` BIGINT      NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_uid_mid` (`uid`, `m_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `im_read_cursor`
+(
+    `uid`          VARCHAR(64) NOT NULL,
+    `conversation` VARCHAR(64) NOT NULL,
+    `seq`          BIGINT      NOT NULL DEFAULT 0,
+    `read_at`      BIGINT      NOT NULL DEFAULT 0,
+    PRIMARY KEY (`uid`, `conversation`),
+    KEY `idx_conversation_seq` (`conversation`, `seq`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `im_sequence`
+(
+    `conversation` VARCHAR(64) NOT NULL,
+    `seq`          BIGINT      NOT NULL DEFAULT 0,
+    PRIMARY KEY (`conversation`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `im_scheduled_message`
+(
+    `id`         BIGINT      NOT NULL,
+    `from`       VARCHAR(64) NOT NULL,
+    `deliver_at` BIGINT      NOT NULL DEFAULT 0,
+    `message`    TEXT        NOT NULL,
+    PRIMARY KEY (`id`),
+    KEY `idx_deliver_at` (`deliver_at`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `im_channel_join_request`
+(
+    `channel_id` VARCHAR(64)  NOT NULL,
+    `uid`        VARCHAR(64)  NOT NULL,
+    `reason`     VARCHAR(255) NOT NULL DEFAULT '',
+    `request_at` BIGINT       NOT NULL DEFAULT 0,
+    PRIMARY KEY (`channel_id`, `uid`),
+    KEY `idx_request_at` (`request_at`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `im_dead_letter`
+(
+    `id`        BIGINT       NOT NULL,
+    `reason`    VARCHAR(32)  NOT NULL,
+    `error`     VARCHAR(255) NOT NULL DEFAULT '',
+    `failed_at` BIGINT       NOT NULL DEFAULT 0,
+    `message`   TEXT         NOT NULL,
+    PRIMARY KEY (`id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
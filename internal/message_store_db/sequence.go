@@ -0,0 +1,37 @@
+package message_store_db
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/sequence"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+)
+
+const channelSegmentLength = 1000
+
+var _ sequence.SegmentStore = &ChatMessageStore{}
+var _ store.SubscriptionStore = &ChatMessageStore{}
+
+// NextSegment increases the max sequence in im_sequence, LAST_INSERT_ID(expr) returns the updated value atomically.
+func (D *ChatMessageStore) NextSegment(conversation string, length int64) (int64, error) {
+	r, err := D.db.Exec(
+		"INSERT INTO im_sequence (`conversation`, `seq`) VALUES (?, LAST_INSERT_ID(?)) ON DUPLICATE KEY UPDATE `seq` = LAST_INSERT_ID(`seq` + ?)",
+		conversation, length, length)
+	if err != nil {
+		return 0, err
+	}
+	max, err := r.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return max - length + 1, nil
+}
+
+// NextSegmentSequence allocates channel message sequence segment from im_sequence.
+func (D *ChatMessageStore) NextSegmentSequence(id subscription.ChanID, _ subscription.ChanInfo) (int64, int64, error) {
+	seq, err := D.NextSegment(string(conversation.NewChannel(string(id)).ID), channelSegmentLength)
+	if err != nil {
+		return 0, 0, err
+	}
+	return seq, channelSegmentLength, nil
+}
@@ -0,0 +1,448 @@
+package message_store_file
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/sequence"
+	"github.com/glide-im/glide/pkg/snowflake"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var _ store.MessageHistoryStore = &MessageStore{}
+var _ store.SubscriptionStore = &MessageStore{}
+var _ store.ReaderStore = &MessageStore{}
+var _ store.OfflineRemoveStore = &MessageStore{}
+var _ store.RetentionStore = &MessageStore{}
+var _ sequence.SegmentStore = &MessageStore{}
+
+const (
+	logFileName          = "messages.log"
+	channelSegmentLength = 1000
+)
+
+const errMessageNotFound = "message not found"
+
+var ErrMessageNotFound = errors.New(errMessageNotFound)
+
+const (
+	opMessage        = "msg"
+	opChannelMessage = "chan"
+	opOffline        = "offline"
+	opRemoveOffline  = "offline_rm"
+	opRecall         = "recall"
+	opEdit           = "edit"
+	opReadCursor     = "cursor"
+	opSegment        = "seg"
+	opPurge          = "purge"
+)
+
+// record is a line of the log file, each mutation of the store is appended as a record and replayed on open.
+type record struct {
+	Op           string                `json:"op"`
+	Conversation string                `json:"conv,omitempty"`
+	Uid          string                `json:"uid,omitempty"`
+	Mid          int64                 `json:"mid,omitempty"`
+	Seq          int64                 `json:"seq,omitempty"`
+	At           int64                 `json:"at,omitempty"`
+	Content      string                `json:"content,omitempty"`
+	Message      *messages.ChatMessage `json:"m,omitempty"`
+	Mids         []int64               `json:"mids,omitempty"`
+}
+
+type storedMessage struct {
+	conversation conversation.ID
+	recalled     bool
+	m            *messages.ChatMessage
+}
+
+// MessageStore keeps messages in memory and appends mutations to a log file in the directory, the log is replayed
+// when the store is opened. It is intended for the embedded deployment and tests, the whole history is loaded into
+// memory and the log is never compacted, use the database stores for production.
+type MessageStore struct {
+	mu sync.RWMutex
+	f  *os.File
+	w  *bufio.Writer
+
+	messages map[int64]*storedMessage
+	// conversation => messages ordered by seq
+	conversations map[conversation.ID][]*storedMessage
+	offline       map[string]map[int64]struct{}
+	cursors       *store.MemReadCursorStore
+	segments      map[string]int64
+}
+
+// New opens the store in dir, the directory is created if not exists, empty dir keeps messages in memory only.
+func New(dir string) (*MessageStore, error) {
+	s := &MessageStore{
+		messages:      map[int64]*storedMessage{},
+		conversations: map[conversation.ID][]*storedMessage{},
+		offline:       map[string]map[int64]struct{}{},
+		cursors:       store.NewMemReadCursorStore(),
+		segments:      map[string]int64{},
+	}
+	if dir == "" {
+		return s, nil
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, logFileName)
+	err = s.replay(path)
+	if err != nil {
+		return nil, err
+	}
+	s.f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.w = bufio.NewWriter(s.f)
+	return s, nil
+}
+
+// replay applies records of the log, the log is truncated to the end of the last record applied if the process
+// crashed while writing, the following records are appended after it.
+func (s *MessageStore) replay(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReaderSize(f, 64*1024)
+	// offset is the end of lines read, applied is the end of the last record applied.
+	var offset, applied int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		offset += int64(len(line))
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			r := &record{}
+			if json.Unmarshal(line, r) == nil {
+				s.apply(r)
+				applied = offset
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if applied < offset {
+		return os.Truncate(path, applied)
+	}
+	return nil
+}
+
+// apply updates the memory state by the record, the caller must hold the lock.
+func (s *MessageStore) apply(r *record) {
+	switch r.Op {
+	case opMessage, opChannelMessage:
+		if r.Message == nil {
+			return
+		}
+		if old, ok := s.messages[r.Message.Mid]; ok {
+			old.m = r.Message
+			return
+		}
+		sm := &storedMessage{conversation: conversation.ID(r.Conversation), m: r.Message}
+		s.messages[r.Message.Mid] = sm
+		ms := s.conversations[sm.conversation]
+		i := sort.Search(len(ms), func(i int) bool { return ms[i].m.Seq > r.Message.Seq })
+		ms = append(ms, nil)
+		copy(ms[i+1:], ms[i:])
+		ms[i] = sm
+		s.conversations[sm.conversation] = ms
+	case opOffline:
+		q, ok := s.offline[r.Uid]
+		if !ok {
+			q = map[int64]struct{}{}
+			s.offline[r.Uid] = q
+		}
+		q[r.Mid] = struct{}{}
+	case opRemoveOffline:
+		delete(s.offline[r.Uid], r.Mid)
+	case opRecall:
+		if sm, ok := s.messages[r.Mid]; ok {
+			sm.recalled = true
+		}
+	case opEdit:
+		if sm, ok := s.messages[r.Mid]; ok {
+			m := *sm.m
+			m.Content = r.Content
+			sm.m = &m
+		}
+	case opReadCursor:
+		_, _ = s.cursors.UpdateReadCursor(r.Uid, conversation.ID(r.Conversation), r.Seq, r.At)
+	case opPurge:
+		purged := map[int64]bool{}
+		for _, mid := range r.Mids {
+			purged[mid] = true
+			delete(s.messages, mid)
+		}
+		c := conversation.ID(r.Conversation)
+		ms := s.conversations[c][:0]
+		for _, sm := range s.conversations[c] {
+			if !purged[sm.m.Mid] {
+				ms = append(ms, sm)
+			}
+		}
+		if len(ms) == 0 {
+			delete(s.conversations, c)
+		} else {
+			s.conversations[c] = ms
+		}
+	case opSegment:
+		if r.Seq > s.segments[r.Conversation] {
+			s.segments[r.Conversation] = r.Seq
+		}
+	}
+}
+
+// append applies and writes the record to the log, the caller must hold the lock.
+func (s *MessageStore) append(r *record) error {
+	if s.w != nil {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, _ = s.w.Write(b)
+		_ = s.w.WriteByte('\n')
+		if err = s.w.Flush(); err != nil {
+			return err
+		}
+	}
+	s.apply(r)
+	return nil
+}
+
+func (s *MessageStore) StoreMessage(message *messages.ChatMessage) error {
+	if message.Mid == 0 {
+		message.Mid = snowflake.Generate()
+	}
+	m := *message
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.append(&record{
+		Op:           opMessage,
+		Conversation: string(conversation.NewP2P(message.From, message.To).ID),
+		Message:      &m,
+	})
+}
+
+func (s *MessageStore) StoreChannelMessage(ch subscription.ChanID, message *messages.ChatMessage) error {
+	if message.Mid == 0 {
+		message.Mid = snowflake.Generate()
+	}
+	m := *message
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.append(&record{
+		Op:           opChannelMessage,
+		Conversation: string(conversation.NewChannel(string(ch)).ID),
+		Message:      &m,
+	})
+}
+
+func (s *MessageStore) StoreOffline(message *messages.ChatMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.append(&record{Op: opOffline, Uid: message.To, Mid: message.Mid})
+}
+
+func (s *MessageStore) RemoveOffline(uid string, mid int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.offline[uid][mid]; !ok {
+		return nil
+	}
+	return s.append(&record{Op: opRemoveOffline, Uid: uid, Mid: mid})
+}
+
+// GetOffline returns messages in the offline queue of uid, ordered by the time sent.
+func (s *MessageStore) GetOffline(uid string) ([]*messages.ChatMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ret []*messages.ChatMessage
+	for mid := range s.offline[uid] {
+		if sm, ok := s.messages[mid]; ok {
+			ret = append(ret, s.copyOf(sm))
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].SendAt < ret[j].SendAt })
+	return ret, nil
+}
+
+func (s *MessageStore) GetMessage(c conversation.ID, mid int64) (*messages.ChatMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sm, ok := s.messages[mid]
+	if !ok || sm.conversation != c {
+		return nil, ErrMessageNotFound
+	}
+	return s.copyOf(sm), nil
+}
+
+func (s *MessageStore) MarkRecalled(c conversation.ID, mid int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sm, ok := s.messages[mid]; !ok || sm.conversation != c {
+		return ErrMessageNotFound
+	}
+	return s.append(&record{Op: opRecall, Mid: mid})
+}
+
+func (s *MessageStore) EditMessage(c conversation.ID, mid int64, content string, editAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sm, ok := s.messages[mid]
+	if !ok || sm.conversation != c {
+		return ErrMessageNotFound
+	}
+	if sm.recalled {
+		return store.ErrMessageRecalled
+	}
+	return s.append(&record{Op: opEdit, Mid: mid, Content: content, At: editAt})
+}
+
+// GetBySeqRange returns messages of the conversation, the content of recalled message is empty.
+func (s *MessageStore) GetBySeqRange(c conversation.ID, start int64, end int64) ([]*messages.ChatMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ms := s.conversations[c]
+	i := sort.Search(len(ms), func(i int) bool { return ms[i].m.Seq >= start })
+	var ret []*messages.ChatMessage
+	for ; i < len(ms) && ms[i].m.Seq <= end; i++ {
+		ret = append(ret, s.copyOf(ms[i]))
+	}
+	return ret, nil
+}
+
+func (s *MessageStore) GetConversations(after conversation.ID, limit int) ([]conversation.ID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ret []conversation.ID
+	for c := range s.conversations {
+		if c > after {
+			ret = append(ret, c)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	if len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
+
+func (s *MessageStore) PurgeMessages(c conversation.ID, before int64, keep int64, limit int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms := s.conversations[c]
+	var mids []int64
+	for i, sm := range ms {
+		if len(mids) >= limit {
+			break
+		}
+		if before > 0 && sm.m.SendAt < before || keep > 0 && int64(len(ms)-i) > keep {
+			mids = append(mids, sm.m.Mid)
+		}
+	}
+	if len(mids) == 0 {
+		return 0, nil
+	}
+	err := s.append(&record{Op: opPurge, Conversation: string(c), Mids: mids})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(mids)), nil
+}
+
+func (s *MessageStore) UpdateReadCursor(uid string, c conversation.ID, seq int64, readAt int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated, err := s.cursors.UpdateReadCursor(uid, c, seq, readAt)
+	if err != nil || !updated {
+		return updated, err
+	}
+	// the cursor is updated already, applying the record again is a no-op.
+	return true, s.append(&record{Op: opReadCursor, Uid: uid, Conversation: string(c), Seq: seq, At: readAt})
+}
+
+func (s *MessageStore) GetReadCursors(uid string) ([]*messages.ReadCursor, error) {
+	return s.cursors.GetReadCursors(uid)
+}
+
+func (s *MessageStore) GetReadCount(c conversation.ID, seq int64) (int64, error) {
+	return s.cursors.GetReadCount(c, seq)
+}
+
+func (s *MessageStore) GetReaders(c conversation.ID, seq int64, uids []string) ([]string, error) {
+	return s.cursors.GetReaders(c, seq, uids)
+}
+
+func (s *MessageStore) NextSegment(conversation string, length int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	max := s.segments[conversation] + length
+	err := s.append(&record{Op: opSegment, Conversation: conversation, Seq: max})
+	if err != nil {
+		return 0, err
+	}
+	return max - length + 1, nil
+}
+
+func (s *MessageStore) NextSegmentSequence(id subscription.ChanID, _ subscription.ChanInfo) (int64, int64, error) {
+	seq, err := s.NextSegment(string(conversation.NewChannel(string(id)).ID), channelSegmentLength)
+	if err != nil {
+		return 0, 0, err
+	}
+	return seq, channelSegmentLength, nil
+}
+
+// Migrate does nothing, the log file has no schema.
+func (s *MessageStore) Migrate() error {
+	return nil
+}
+
+// Close flushes and closes the log file.
+func (s *MessageStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.w.Flush()
+	if e := s.f.Close(); err == nil {
+		err = e
+	}
+	s.f, s.w = nil, nil
+	return err
+}
+
+func (s *MessageStore) copyOf(sm *storedMessage) *messages.ChatMessage {
+	m := *sm.m
+	if sm.recalled {
+		m.Content = ""
+	}
+	return &m
+}
@@ -0,0 +1,72 @@
+package message_store_file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageStore_Replay(t *testing.T) {
+	dir := t.TempDir()
+	conv := conversation.NewP2P("1", "2").ID
+
+	s, err := New(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, s.StoreMessage(&messages.ChatMessage{Mid: 1, Seq: 1, From: "1", To: "2", Content: "hi"}))
+	_, err = s.UpdateReadCursor("2", conv, 1, 100)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Close())
+
+	s, err = New(dir)
+	assert.NoError(t, err)
+	defer s.Close()
+	ms, err := s.GetBySeqRange(conv, 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, ms, 1)
+	assert.Equal(t, "hi", ms[0].Content)
+	cursors, err := s.GetReadCursors("2")
+	assert.NoError(t, err)
+	assert.Len(t, cursors, 1)
+}
+
+func TestMessageStore_ReplayTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, logFileName)
+	conv := conversation.NewP2P("1", "2").ID
+
+	s, err := New(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, s.StoreMessage(&messages.ChatMessage{Mid: 1, Seq: 1, From: "1", To: "2", Content: "hi"}))
+	assert.NoError(t, s.Close())
+	good, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	// the process crashed while writing the record.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"op":"msg","conv":"1:1_2","m":{"mid":2,`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	s, err = New(dir)
+	assert.NoError(t, err)
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, good, b)
+
+	// the record appended after the torn write is replayed.
+	assert.NoError(t, s.StoreMessage(&messages.ChatMessage{Mid: 3, Seq: 2, From: "2", To: "1", Content: "hello"}))
+	assert.NoError(t, s.Close())
+
+	s, err = New(dir)
+	assert.NoError(t, err)
+	defer s.Close()
+	ms, err := s.GetBySeqRange(conv, 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, ms, 2)
+	assert.Equal(t, int64(3), ms[1].Mid)
+}
@@ -0,0 +1,94 @@
+package message_store_mongo
+
+import (
+	"context"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ store.ArchiveStore = &MessageStore{}
+
+// GetArchivable returns messages sent before, the content of recalled message is kept with Recalled set.
+func (s *MessageStore) GetArchivable(before int64, limit int) ([]*store.ArchivedMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"send_at": 1}).SetLimit(int64(limit))
+	cursor, err := s.db.Collection(collectionMessage).Find(ctx, bson.M{"send_at": bson.M{"$lt": before}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []message
+	err = cursor.All(ctx, &docs)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*store.ArchivedMessage, 0, len(docs))
+	for _, doc := range docs {
+		ret = append(ret, &store.ArchivedMessage{
+			Conversation: conversation.ID(doc.Conversation),
+			Recalled:     doc.Status == messageStatusRecalled,
+			Message: &messages.ChatMessage{
+				Mid:     doc.Mid,
+				Seq:     doc.Seq,
+				From:    doc.From,
+				To:      doc.To,
+				Type:    doc.Type,
+				Content: doc.Content,
+				SendAt:  doc.SendAt,
+			},
+		})
+	}
+	return ret, nil
+}
+
+func (s *MessageStore) RemoveArchived(ms []*store.ArchivedMessage) error {
+	if len(ms) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ids := make([]int64, 0, len(ms))
+	for _, m := range ms {
+		ids = append(ids, m.Message.Mid)
+	}
+	_, err := s.db.Collection(collectionMessage).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return err
+}
+
+func (s *MessageStore) RestoreArchived(ms []*store.ArchivedMessage) error {
+	if len(ms) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	docs := make([]interface{}, 0, len(ms))
+	for _, am := range ms {
+		m := am.Message
+		doc := message{
+			Mid:          m.Mid,
+			Conversation: string(am.Conversation),
+			Seq:          m.Seq,
+			From:         m.From,
+			To:           m.To,
+			Type:         m.Type,
+			Content:      m.Content,
+			SendAt:       m.SendAt,
+		}
+		if am.Recalled {
+			doc.Status = messageStatusRecalled
+		}
+		docs = append(docs, doc)
+	}
+	_, err := s.db.Collection(collectionMessage).InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
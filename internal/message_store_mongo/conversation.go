@@ -0,0 +1,58 @@
+package message_store_mongo
+
+import (
+	"context"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"regexp"
+)
+
+var _ store.ConversationStore = &MessageStore{}
+
+// GetRecentConversations groups messages of P2P conversations of uid and the channels by conversation, and returns the
+// latest message of each group.
+func (s *MessageStore) GetRecentConversations(uid string, channels []string, limit int) ([]*store.ConversationSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	p2p := "^" + regexp.QuoteMeta(string(conversation.NewID(conversation.TypeP2P, "")))
+	or := bson.A{
+		bson.M{"from": uid, "conversation": bson.M{"$regex": p2p}},
+		bson.M{"to": uid, "conversation": bson.M{"$regex": p2p}},
+	}
+	if len(channels) > 0 {
+		ids := bson.A{}
+		for _, ch := range channels {
+			ids = append(ids, string(conversation.NewChannel(ch).ID))
+		}
+		or = append(or, bson.M{"conversation": bson.M{"$in": ids}})
+	}
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"$or": or}},
+		bson.M{"$sort": bson.D{{Key: "conversation", Value: 1}, {Key: "seq", Value: -1}}},
+		bson.M{"$group": bson.M{"_id": "$conversation", "last": bson.M{"$first": "$$ROOT"}}},
+		bson.M{"$sort": bson.M{"last.send_at": -1}},
+		bson.M{"$limit": limit},
+	}
+	cursor, err := s.db.Collection(collectionMessage).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var ret []*store.ConversationSummary
+	for cursor.Next(ctx) {
+		doc := struct {
+			Last message `bson:"last"`
+		}{}
+		if err = cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		ret = append(ret, &store.ConversationSummary{
+			Conversation: conversation.ID(doc.Last.Conversation),
+			Last:         doc.Last.toChatMessage(),
+		})
+	}
+	return ret, cursor.Err()
+}
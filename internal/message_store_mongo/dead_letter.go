@@ -0,0 +1,73 @@
+package message_store_mongo
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (s *MessageStore) StoreDeadLetter(l *store.DeadLetter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	b, err := json.Marshal(l.Message)
+	if err != nil {
+		return err
+	}
+	doc := deadLetter{ID: l.ID, Reason: l.Reason, Error: l.Error, FailedAt: l.FailedAt, Message: string(b)}
+	_, err = s.db.Collection(collectionDeadLetter).InsertOne(ctx, doc)
+	return err
+}
+
+func (s *MessageStore) GetDeadLetters(after int64, limit int) ([]*store.DeadLetter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	filter := bson.M{"_id": bson.M{"$gt": after}}
+	opts := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(int64(limit))
+	cursor, err := s.db.Collection(collectionDeadLetter).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []deadLetter
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ret := make([]*store.DeadLetter, 0, len(docs))
+	for _, doc := range docs {
+		l, err := doc.toDeadLetter()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, l)
+	}
+	return ret, nil
+}
+
+func (s *MessageStore) RemoveDeadLetter(id int64) (*store.DeadLetter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var doc deadLetter
+	err := s.db.Collection(collectionDeadLetter).FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toDeadLetter()
+}
+
+func (d *deadLetter) toDeadLetter() (*store.DeadLetter, error) {
+	l := &store.DeadLetter{ID: d.ID, Reason: d.Reason, Error: d.Error, FailedAt: d.FailedAt, Message: &messages.ChatMessage{}}
+	if err := json.Unmarshal([]byte(d.Message), l.Message); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
@@ -0,0 +1,86 @@
+package message_store_mongo
+
+import (
+	"context"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ store.JoinRequestStore = &MessageStore{}
+
+type joinRequest struct {
+	Channel   string `bson:"channel"`
+	Uid       string `bson:"uid"`
+	Reason    string `bson:"reason"`
+	RequestAt int64  `bson:"request_at"`
+}
+
+func (r *joinRequest) toJoinRequest() *messages.JoinRequest {
+	return &messages.JoinRequest{
+		Channel:   r.Channel,
+		Uid:       r.Uid,
+		Reason:    r.Reason,
+		Status:    messages.JoinStatusPending,
+		RequestAt: r.RequestAt,
+	}
+}
+
+func (s *MessageStore) AddJoinRequest(r *messages.JoinRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	doc := joinRequest{Channel: r.Channel, Uid: r.Uid, Reason: r.Reason, RequestAt: r.RequestAt}
+	filter := bson.M{"channel": r.Channel, "uid": r.Uid}
+	_, err := s.db.Collection(collectionJoinRequest).ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MessageStore) RemoveJoinRequest(channel string, uid string) (*messages.JoinRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	doc := joinRequest{}
+	err := s.db.Collection(collectionJoinRequest).FindOneAndDelete(ctx, bson.M{"channel": channel, "uid": uid}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toJoinRequest(), nil
+}
+
+func (s *MessageStore) GetJoinRequests(channel string) ([]*messages.JoinRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"request_at": 1})
+	cursor, err := s.db.Collection(collectionJoinRequest).Find(ctx, bson.M{"channel": channel}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []joinRequest
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ret := make([]*messages.JoinRequest, 0, len(docs))
+	for _, doc := range docs {
+		ret = append(ret, doc.toJoinRequest())
+	}
+	return ret, nil
+}
+
+func (s *MessageStore) RemoveJoinRequestsBefore(before int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	r, err := s.db.Collection(collectionJoinRequest).DeleteMany(ctx, bson.M{"request_at": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+	return r.DeletedCount, nil
+}
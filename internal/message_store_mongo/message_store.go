@@ -0,0 +1,480 @@
+package message_store_mongo
+
+import (
+	"context"
+	"errors"
+	"github.com/glide-im/glide/config"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/sequence"
+	"github.com/glide-im/glide/pkg/snowflake"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	collectionMessage     = "im_message"
+	collectionOffline     = "im_offline_message"
+	collectionReadCursor  = "im_read_cursor"
+	collectionSequence    = "im_sequence"
+	collectionScheduled   = "im_scheduled_message"
+	collectionJoinRequest = "im_channel_join_request"
+	collectionDeadLetter  = "im_dead_letter"
+)
+
+const (
+	messageStatusRecalled = 2
+)
+
+const timeout = time.Second * 5
+
+var _ store.MessageHistoryStore = &MessageStore{}
+var _ store.SubscriptionStore = &MessageStore{}
+var _ store.BatchMessageStore = &MessageStore{}
+var _ store.OfflineRemoveStore = &MessageStore{}
+var _ store.ThreadStore = &MessageStore{}
+var _ store.ScheduleStore = &MessageStore{}
+var _ store.DeadLetterStore = &MessageStore{}
+var _ store.Pinger = &MessageStore{}
+var _ store.ReaderStore = &MessageStore{}
+var _ sequence.SegmentStore = &MessageStore{}
+
+// message is the document of chat and channel message, the id is generated by snowflake.
+type message struct {
+	Mid          int64         `bson:"_id"`
+	Conversation string        `bson:"conversation"`
+	Seq          int64         `bson:"seq"`
+	From         string        `bson:"from"`
+	To           string        `bson:"to"`
+	Type         int32         `bson:"type"`
+	Content      string        `bson:"content"`
+	SendAt       int64         `bson:"send_at"`
+	Status       int32         `bson:"status"`
+	Parent       int64         `bson:"parent,omitempty"`
+	Thread       int64         `bson:"thread,omitempty"`
+	Edits        []messageEdit `bson:"edits,omitempty"`
+}
+
+type messageEdit struct {
+	Content string `bson:"content"`
+	EditAt  int64  `bson:"edit_at"`
+}
+
+// scheduledMessage is the document of scheduled message, the message is encoded in json.
+type scheduledMessage struct {
+	ID        int64  `bson:"_id"`
+	From      string `bson:"from"`
+	DeliverAt int64  `bson:"deliver_at"`
+	Message   string `bson:"message"`
+}
+
+type deadLetter struct {
+	ID       int64  `bson:"_id"`
+	Reason   string `bson:"reason"`
+	Error    string `bson:"error,omitempty"`
+	FailedAt int64  `bson:"failed_at"`
+	Message  string `bson:"message"`
+}
+
+type readCursor struct {
+	Uid          string `bson:"uid"`
+	Conversation string `bson:"conversation"`
+	Seq          int64  `bson:"seq"`
+	ReadAt       int64  `bson:"read_at"`
+}
+
+// MessageStore is a store.MessageHistoryStore implemented by MongoDB.
+type MessageStore struct {
+	client *mongo.Client
+	db     *mongo.Database
+}
+
+func New(conf *config.MongoDBConf) (*MessageStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	opts := options.Client().ApplyURI(conf.Uri)
+	if conf.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(conf.MaxPoolSize)
+	}
+	if conf.Timeout > 0 {
+		opts.SetConnectTimeout(time.Duration(conf.Timeout) * time.Second)
+		opts.SetSocketTimeout(time.Duration(conf.Timeout) * time.Second)
+	}
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = client.Ping(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageStore{
+		client: client,
+		db:     client.Database(conf.Db),
+	}, nil
+}
+
+func (s *MessageStore) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.client.Ping(ctx, nil)
+}
+
+func (s *MessageStore) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.client.Disconnect(ctx)
+}
+
+// Migrate creates indexes of collections.
+func (s *MessageStore) Migrate() error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	indexes := map[string][]mongo.IndexModel{
+		collectionMessage: {
+			{Keys: bson.D{{Key: "conversation", Value: 1}, {Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "send_at", Value: 1}}},
+			{Keys: bson.D{{Key: "from", Value: 1}}},
+			{Keys: bson.D{{Key: "to", Value: 1}}},
+			{Keys: bson.D{{Key: "conversation", Value: 1}, {Key: "thread", Value: 1}}},
+		},
+		collectionOffline: {
+			{Keys: bson.D{{Key: "uid", Value: 1}, {Key: "m_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		collectionReadCursor: {
+			{Keys: bson.D{{Key: "uid", Value: 1}, {Key: "conversation", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "conversation", Value: 1}, {Key: "seq", Value: 1}}},
+		},
+		collectionScheduled: {
+			{Keys: bson.D{{Key: "deliver_at", Value: 1}}},
+		},
+		collectionJoinRequest: {
+			{Keys: bson.D{{Key: "channel", Value: 1}, {Key: "uid", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "request_at", Value: 1}}},
+		},
+	}
+	for c, models := range indexes {
+		_, err := s.db.Collection(c).Indexes().CreateMany(ctx, models)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MessageStore) StoreMessage(m *messages.ChatMessage) error {
+	return s.insert(conversation.NewP2P(m.From, m.To).ID, m)
+}
+
+func (s *MessageStore) StoreChannelMessage(ch subscription.ChanID, m *messages.ChatMessage) error {
+	return s.insert(conversation.NewChannel(string(ch)).ID, m)
+}
+
+func (s *MessageStore) insert(c conversation.ID, m *messages.ChatMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if m.Mid == 0 {
+		m.Mid = snowflake.Generate()
+	}
+	doc := message{
+		Mid:          m.Mid,
+		Conversation: string(c),
+		Seq:          m.Seq,
+		From:         m.From,
+		To:           m.To,
+		Type:         m.Type,
+		Content:      m.Content,
+		SendAt:       m.SendAt,
+		Parent:       m.Parent,
+		Thread:       m.Thread,
+	}
+	_, err := s.db.Collection(collectionMessage).InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// StoreMessages stores chat messages in batch, the message id must be assigned.
+func (s *MessageStore) StoreMessages(ms []*messages.ChatMessage) error {
+	if len(ms) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	docs := make([]interface{}, 0, len(ms))
+	for _, m := range ms {
+		docs = append(docs, message{
+			Mid:          m.Mid,
+			Conversation: string(conversation.NewP2P(m.From, m.To).ID),
+			Seq:          m.Seq,
+			From:         m.From,
+			To:           m.To,
+			Type:         m.Type,
+			Content:      m.Content,
+			SendAt:       m.SendAt,
+			Parent:       m.Parent,
+			Thread:       m.Thread,
+		})
+	}
+	// unordered insert continues after duplicate key error of retried messages.
+	_, err := s.db.Collection(collectionMessage).InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if onlyDuplicateKey(err) {
+		return nil
+	}
+	return err
+}
+
+// onlyDuplicateKey returns true if all write errors of the bulk write are duplicate key errors.
+func onlyDuplicateKey(err error) bool {
+	var bwe mongo.BulkWriteException
+	if !errors.As(err, &bwe) || bwe.WriteConcernError != nil || len(bwe.WriteErrors) == 0 {
+		return false
+	}
+	for _, we := range bwe.WriteErrors {
+		if !mongo.IsDuplicateKeyError(we.WriteError) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MessageStore) StoreOffline(m *messages.ChatMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := s.db.Collection(collectionOffline).InsertOne(ctx, bson.M{"uid": m.To, "m_id": m.Mid})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *MessageStore) RemoveOffline(uid string, mid int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := s.db.Collection(collectionOffline).DeleteOne(ctx, bson.M{"uid": uid, "m_id": mid})
+	return err
+}
+
+func (s *MessageStore) GetMessage(c conversation.ID, mid int64) (*messages.ChatMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	doc := message{}
+	err := s.db.Collection(collectionMessage).FindOne(ctx, bson.M{"_id": mid, "conversation": string(c)}).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	return doc.toChatMessage(), nil
+}
+
+func (s *MessageStore) MarkRecalled(c conversation.ID, mid int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	filter := bson.M{"_id": mid, "conversation": string(c)}
+	_, err := s.db.Collection(collectionMessage).UpdateOne(ctx, filter, bson.M{"$set": bson.M{"status": messageStatusRecalled}})
+	return err
+}
+
+// EditMessage updates message content, and pushes the previous content to edits as edit history, the recalled message
+// can not be edited.
+func (s *MessageStore) EditMessage(c conversation.ID, mid int64, content string, editAt int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	filter := bson.M{"_id": mid, "conversation": string(c)}
+	doc := message{}
+	err := s.db.Collection(collectionMessage).FindOne(ctx, filter).Decode(&doc)
+	if err != nil {
+		return err
+	}
+	if doc.Status == messageStatusRecalled {
+		return store.ErrMessageRecalled
+	}
+	// the message may be recalled after found.
+	filter["status"] = bson.M{"$ne": messageStatusRecalled}
+	ret, err := s.db.Collection(collectionMessage).UpdateOne(ctx, filter, bson.M{
+		"$set":  bson.M{"content": content},
+		"$push": bson.M{"edits": messageEdit{Content: doc.Content, EditAt: editAt}},
+	})
+	if err != nil {
+		return err
+	}
+	if ret.MatchedCount == 0 {
+		return store.ErrMessageRecalled
+	}
+	return nil
+}
+
+// GetBySeqRange returns messages of the conversation, the content of recalled message is empty.
+func (s *MessageStore) GetBySeqRange(c conversation.ID, start int64, end int64) ([]*messages.ChatMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	filter := bson.M{"conversation": string(c), "seq": bson.M{"$gte": start, "$lte": end}}
+	cursor, err := s.db.Collection(collectionMessage).Find(ctx, filter, options.Find().SetSort(bson.M{"seq": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []message
+	err = cursor.All(ctx, &docs)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*messages.ChatMessage, 0, len(docs))
+	for _, doc := range docs {
+		ret = append(ret, doc.toChatMessage())
+	}
+	return ret, nil
+}
+
+// GetThread returns the root message and replies of the thread, the content of recalled message is empty.
+func (s *MessageStore) GetThread(c conversation.ID, thread int64, start int64, limit int) ([]*messages.ChatMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	filter := bson.M{
+		"conversation": string(c),
+		"$or":          bson.A{bson.M{"_id": thread}, bson.M{"thread": thread}},
+		"seq":          bson.M{"$gte": start},
+	}
+	opts := options.Find().SetSort(bson.M{"seq": 1}).SetLimit(int64(limit))
+	cursor, err := s.db.Collection(collectionMessage).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []message
+	err = cursor.All(ctx, &docs)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*messages.ChatMessage, 0, len(docs))
+	for _, doc := range docs {
+		ret = append(ret, doc.toChatMessage())
+	}
+	return ret, nil
+}
+
+func (s *MessageStore) UpdateReadCursor(uid string, c conversation.ID, seq int64, readAt int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// upsert fails with duplicate key error when the cursor is already at or beyond seq.
+	filter := bson.M{"uid": uid, "conversation": string(c), "seq": bson.M{"$lt": seq}}
+	update := bson.M{"$set": bson.M{"seq": seq, "read_at": readAt}}
+	_, err := s.db.Collection(collectionReadCursor).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *MessageStore) GetReadCursors(uid string) ([]*messages.ReadCursor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cursor, err := s.db.Collection(collectionReadCursor).Find(ctx, bson.M{"uid": uid})
+	if err != nil {
+		return nil, err
+	}
+	var docs []readCursor
+	err = cursor.All(ctx, &docs)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*messages.ReadCursor, 0, len(docs))
+	for _, doc := range docs {
+		ret = append(ret, &messages.ReadCursor{Conversation: doc.Conversation, Seq: doc.Seq, ReadAt: doc.ReadAt})
+	}
+	return ret, nil
+}
+
+func (s *MessageStore) GetReadCount(c conversation.ID, seq int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return s.db.Collection(collectionReadCursor).CountDocuments(ctx, bson.M{"conversation": string(c), "seq": bson.M{"$gte": seq}})
+}
+
+func (s *MessageStore) GetReaders(c conversation.ID, seq int64, uids []string) ([]string, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	filter := bson.M{"conversation": string(c), "seq": bson.M{"$gte": seq}, "uid": bson.M{"$in": uids}}
+	cursor, err := s.db.Collection(collectionReadCursor).Find(ctx, filter, options.Find().SetProjection(bson.M{"uid": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []readCursor
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ret = append(ret, doc.Uid)
+	}
+	return ret, nil
+}
+
+// NextSegment increases the max sequence of conversation in im_sequence atomically.
+func (s *MessageStore) NextSegment(c string, length int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	doc := struct {
+		Seq int64 `bson:"seq"`
+	}{}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := s.db.Collection(collectionSequence).
+		FindOneAndUpdate(ctx, bson.M{"_id": c}, bson.M{"$inc": bson.M{"seq": length}}, opts).
+		Decode(&doc)
+	if err != nil {
+		return 0, err
+	}
+	return doc.Seq - length + 1, nil
+}
+
+const channelSegmentLength = 1000
+
+func (s *MessageStore) NextSegmentSequence(id subscription.ChanID, _ subscription.ChanInfo) (int64, int64, error) {
+	seq, err := s.NextSegment(string(conversation.NewChannel(string(id)).ID), channelSegmentLength)
+	if err != nil {
+		return 0, 0, err
+	}
+	return seq, channelSegmentLength, nil
+}
+
+func (m *message) toChatMessage() *messages.ChatMessage {
+	cm := &messages.ChatMessage{
+		Mid:     m.Mid,
+		Seq:     m.Seq,
+		From:    m.From,
+		To:      m.To,
+		Type:    m.Type,
+		Content: m.Content,
+		SendAt:  m.SendAt,
+		Parent:  m.Parent,
+		Thread:  m.Thread,
+	}
+	if m.Status == messageStatusRecalled {
+		cm.Content = ""
+	}
+	return cm
+}
@@ -0,0 +1,134 @@
+package message_store_mongo
+
+import (
+	"testing"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func newMockStore(mt *mtest.T) *MessageStore {
+	return &MessageStore{client: mt.Client, db: mt.DB}
+}
+
+func messageDoc(mid int64, seq int64, status int32) bson.D {
+	return bson.D{
+		{Key: "_id", Value: mid},
+		{Key: "conversation", Value: string(conversation.NewChannel("g").ID)},
+		{Key: "seq", Value: seq},
+		{Key: "from", Value: "1"},
+		{Key: "to", Value: "g"},
+		{Key: "content", Value: "hello"},
+		{Key: "status", Value: status},
+	}
+}
+
+func TestMessageStore_GetBySeqRange(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("range", func(mt *mtest.T) {
+		s := newMockStore(mt)
+		ns := mt.DB.Name() + "." + collectionMessage
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch,
+			messageDoc(10, 1, 0), messageDoc(11, 2, messageStatusRecalled)))
+
+		ms, err := s.GetBySeqRange(conversation.NewChannel("g").ID, 1, 2)
+		assert.NoError(mt, err)
+		assert.Len(mt, ms, 2)
+		assert.Equal(mt, "hello", ms[0].Content)
+		// the content of recalled message is empty.
+		assert.Empty(mt, ms[1].Content)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(mt, string(conversation.NewChannel("g").ID), filter.Lookup("conversation").StringValue())
+		assert.Equal(mt, int64(1), filter.Lookup("seq", "$gte").Int64())
+		assert.Equal(mt, int64(2), filter.Lookup("seq", "$lte").Int64())
+	})
+
+	mt.Run("thread", func(mt *mtest.T) {
+		s := newMockStore(mt)
+		ns := mt.DB.Name() + "." + collectionMessage
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, messageDoc(10, 1, 0)))
+
+		ms, err := s.GetThread(conversation.NewChannel("g").ID, 10, 1, 20)
+		assert.NoError(mt, err)
+		assert.Len(mt, ms, 1)
+
+		cmd := mt.GetStartedEvent().Command
+		assert.Equal(mt, int64(20), cmd.Lookup("limit").Int64())
+		or, _ := cmd.Lookup("filter", "$or").Array().Values()
+		assert.Len(mt, or, 2)
+	})
+}
+
+func TestMessageStore_StoreMessages(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	ms := []*messages.ChatMessage{{Mid: 1, From: "1", To: "2"}, {Mid: 2, From: "1", To: "2"}}
+
+	mt.Run("duplicate", func(mt *mtest.T) {
+		s := newMockStore(mt)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(
+			mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"},
+			mtest.WriteError{Index: 1, Code: 11000, Message: "E11000 duplicate key error"},
+		))
+		// messages stored by a retried batch are ignored.
+		assert.NoError(mt, s.StoreMessages(ms))
+	})
+
+	mt.Run("mixed", func(mt *mtest.T) {
+		s := newMockStore(mt)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(
+			mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"},
+			mtest.WriteError{Index: 1, Code: 121, Message: "document failed validation"},
+		))
+		assert.Error(mt, s.StoreMessages(ms))
+	})
+}
+
+func TestMessageStore_EditMessage(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	c := conversation.NewChannel("g").ID
+
+	mt.Run("edit", func(mt *mtest.T) {
+		s := newMockStore(mt)
+		ns := mt.DB.Name() + "." + collectionMessage
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, messageDoc(10, 1, 0)),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+		assert.NoError(mt, s.EditMessage(c, 10, "edited", 100))
+		find := mt.GetStartedEvent()
+		assert.Equal(mt, string(c), find.Command.Lookup("filter", "conversation").StringValue())
+		update := mt.GetStartedEvent()
+		assert.Equal(mt, "update", update.CommandName)
+		q := update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("q").Document()
+		assert.Equal(mt, string(c), q.Lookup("conversation").StringValue())
+		assert.Equal(mt, int32(messageStatusRecalled), q.Lookup("status", "$ne").Int32())
+	})
+
+	mt.Run("recalled", func(mt *mtest.T) {
+		s := newMockStore(mt)
+		ns := mt.DB.Name() + "." + collectionMessage
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, messageDoc(10, 1, messageStatusRecalled)))
+		assert.ErrorIs(mt, s.EditMessage(c, 10, "edited", 100), store.ErrMessageRecalled)
+	})
+
+	mt.Run("recalled meanwhile", func(mt *mtest.T) {
+		s := newMockStore(mt)
+		ns := mt.DB.Name() + "." + collectionMessage
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, messageDoc(10, 1, 0)),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+		)
+		assert.ErrorIs(mt, s.EditMessage(c, 10, "edited", 100), store.ErrMessageRecalled)
+	})
+}
@@ -0,0 +1,86 @@
+package message_store_mongo
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"go.mongodb.org/mongo-driver/bson"
+	"regexp"
+)
+
+var _ store.UserPurgeStore = &MessageStore{}
+
+// PurgeUser deletes P2P messages sent or received by uid, channel messages sent by uid, the offline queue, read
+// cursors, scheduled messages and dead letters of uid. Offline queue entries of other users referring to deleted
+// messages are deleted as well.
+func (s *MessageStore) PurgeUser(uid string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	p2p := "^" + regexp.QuoteMeta(string(conversation.NewID(conversation.TypeP2P, "")))
+	filter := bson.M{"$or": bson.A{
+		bson.M{"from": uid},
+		bson.M{"to": uid, "conversation": bson.M{"$regex": p2p}},
+	}}
+	mids, err := s.db.Collection(collectionMessage).Distinct(ctx, "_id", filter)
+	if err != nil {
+		return err
+	}
+	if len(mids) > 0 {
+		_, err = s.db.Collection(collectionOffline).DeleteMany(ctx, bson.M{"m_id": bson.M{"$in": mids}})
+		if err != nil {
+			return err
+		}
+	}
+	deletes := []struct {
+		collection string
+		filter     bson.M
+	}{
+		{collectionOffline, bson.M{"uid": uid}},
+		{collectionMessage, filter},
+		{collectionReadCursor, bson.M{"uid": uid}},
+		{collectionScheduled, bson.M{"from": uid}},
+		{collectionDeadLetter, bson.M{"message": bson.M{"$regex": deadLetterPattern(uid)}}},
+	}
+	for _, d := range deletes {
+		_, err = s.db.Collection(d.collection).DeleteMany(ctx, d.filter)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deadLetterPattern matches the json of messages sent or received by uid in dead letters.
+func deadLetterPattern(uid string) string {
+	b, _ := json.Marshal(uid)
+	return `"(from|to)":` + regexp.QuoteMeta(string(b))
+}
+
+var _ store.ChannelPurgeStore = &MessageStore{}
+
+// PurgeChannel deletes messages, join requests, read cursors and the sequence of the channel.
+func (s *MessageStore) PurgeChannel(ch subscription.ChanID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conv := string(conversation.NewChannel(string(ch)).ID)
+	deletes := []struct {
+		collection string
+		filter     bson.M
+	}{
+		{collectionMessage, bson.M{"conversation": conv}},
+		{collectionJoinRequest, bson.M{"channel": string(ch)}},
+		{collectionReadCursor, bson.M{"conversation": conv}},
+		{collectionSequence, bson.M{"_id": conv}},
+	}
+	for _, d := range deletes {
+		_, err := s.db.Collection(d.collection).DeleteMany(ctx, d.filter)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
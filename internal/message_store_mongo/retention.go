@@ -0,0 +1,85 @@
+package message_store_mongo
+
+import (
+	"context"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ store.RetentionStore = &MessageStore{}
+
+func (s *MessageStore) GetConversations(after conversation.ID, limit int) ([]conversation.ID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"conversation": bson.M{"$gt": string(after)}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$conversation"}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$limit", Value: limit}},
+	}
+	cursor, err := s.db.Collection(collectionMessage).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		ID string `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ret := make([]conversation.ID, 0, len(docs))
+	for _, doc := range docs {
+		ret = append(ret, conversation.ID(doc.ID))
+	}
+	return ret, nil
+}
+
+func (s *MessageStore) PurgeMessages(c conversation.ID, before int64, keep int64, limit int) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var conditions bson.A
+	if before > 0 {
+		conditions = append(conditions, bson.M{"send_at": bson.M{"$lt": before}})
+	}
+	if keep > 0 {
+		// messages which sequence is not greater than the (keep+1)th latest message are purged.
+		opts := options.FindOne().SetSort(bson.M{"seq": -1}).SetSkip(keep).SetProjection(bson.M{"seq": 1})
+		doc := message{}
+		err := s.db.Collection(collectionMessage).FindOne(ctx, bson.M{"conversation": string(c)}, opts).Decode(&doc)
+		if err == nil {
+			conditions = append(conditions, bson.M{"seq": bson.M{"$lte": doc.Seq}})
+		} else if err != mongo.ErrNoDocuments {
+			return 0, err
+		}
+	}
+	if len(conditions) == 0 {
+		return 0, nil
+	}
+
+	opts := options.Find().SetSort(bson.M{"seq": 1}).SetLimit(int64(limit)).SetProjection(bson.M{"_id": 1})
+	cursor, err := s.db.Collection(collectionMessage).Find(ctx, bson.M{"conversation": string(c), "$or": conditions}, opts)
+	if err != nil {
+		return 0, err
+	}
+	var docs []message
+	if err = cursor.All(ctx, &docs); err != nil {
+		return 0, err
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+	ids := make([]int64, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.Mid)
+	}
+	result, err := s.db.Collection(collectionMessage).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
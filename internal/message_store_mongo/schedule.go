@@ -0,0 +1,61 @@
+package message_store_mongo
+
+import (
+	"context"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (s *MessageStore) StoreScheduled(m *store.ScheduledMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	b, err := messages.JsonCodec.Encode(m.Message)
+	if err != nil {
+		return err
+	}
+	doc := scheduledMessage{ID: m.ID, From: m.From, DeliverAt: m.DeliverAt, Message: string(b)}
+	_, err = s.db.Collection(collectionScheduled).InsertOne(ctx, doc)
+	return err
+}
+
+func (s *MessageStore) RemoveScheduled(id int64, from string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id}
+	if from != "" {
+		filter["from"] = from
+	}
+	r, err := s.db.Collection(collectionScheduled).DeleteOne(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return r.DeletedCount > 0, nil
+}
+
+func (s *MessageStore) GetScheduled(before int64) ([]*store.ScheduledMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	filter := bson.M{"deliver_at": bson.M{"$lt": before}}
+	cursor, err := s.db.Collection(collectionScheduled).Find(ctx, filter, options.Find().SetSort(bson.M{"deliver_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []scheduledMessage
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ret := make([]*store.ScheduledMessage, 0, len(docs))
+	for _, doc := range docs {
+		m := &store.ScheduledMessage{ID: doc.ID, From: doc.From, DeliverAt: doc.DeliverAt, Message: messages.NewEmptyMessage()}
+		if err = messages.JsonCodec.Decode([]byte(doc.Message), m.Message); err != nil {
+			return nil, err
+		}
+		ret = append(ret, m)
+	}
+	return ret, nil
+}
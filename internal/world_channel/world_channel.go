@@ -5,11 +5,14 @@ import (
 	"github.com/glide-im/glide/pkg/gate"
 	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/snowflake"
 	"github.com/glide-im/glide/pkg/subscription"
 	"github.com/glide-im/glide/pkg/subscription/subscription_impl"
 	"time"
 )
 
+var log = logger.Named("world_channel")
+
 var sub subscription_impl.SubscribeWrap
 var chanId = subscription.ChanID("the_world_channel")
 
@@ -26,7 +29,8 @@ func EnableWorldChannel(subscribe subscription_impl.SubscribeWrap) error {
 }
 
 func OnUserOnline(id gate.ID) {
-	if id.IsTemp() {
+	// the world channel is not enabled, such as the embedded server.
+	if sub == nil || id.IsTemp() {
 		return
 	}
 	myId := subscription.SubscriberID(id.UID())
@@ -36,7 +40,7 @@ func OnUserOnline(id gate.ID) {
 
 		time.Sleep(time.Second)
 		b := &messages.ChatMessage{
-			Mid:     time.Now().UnixNano(),
+			Mid:     snowflake.Generate(),
 			Seq:     0,
 			From:    "system",
 			To:      string(chanId),
@@ -58,20 +62,20 @@ func OnUserOnline(id gate.ID) {
 			Message: messages.NewMessage(0, messages.ActionGroupMessage, b),
 		})
 	} else {
-		logger.E("$v", err)
+		log.E("$v", err)
 	}
 }
 
 func OnUserOffline(id gate.ID) {
-	if id.IsTemp() {
+	if sub == nil || id.IsTemp() {
 		return
 	}
 	err := sub.UnSubscribe(chanId, subscription.SubscriberID(id.UID()))
 	if err != nil {
-		logger.E("$v", err)
+		log.E("$v", err)
 	}
 	b, _ := json.Marshal(&messages.ChatMessage{
-		Mid:     time.Now().UnixNano(),
+		Mid:     snowflake.Generate(),
 		Seq:     0,
 		From:    "system",
 		To:      string(chanId),
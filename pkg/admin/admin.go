@@ -0,0 +1,827 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/glide-im/glide/pkg/archive"
+	"github.com/glide-im/glide/pkg/audit"
+	"github.com/glide-im/glide/pkg/broadcast"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/messaging"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var log = logger.Named("admin")
+
+const (
+	errUnauthorized      = "unauthorized"
+	errMethodNotAllowed  = "method not allowed"
+	errMissingClientID   = "missing client id"
+	errEmptyContent      = "content is empty"
+	errNotSupported      = "not supported"
+	errRuleNotExist      = "rule does not exist"
+	errTaskNotExist      = "broadcast task does not exist"
+	errInvalidMessage    = "invalid message"
+	errScheduledMissing  = "scheduled message does not exist"
+	errInvalidTime       = "invalid time"
+	errMissingUid        = "missing uid"
+	errEmptySecret       = "secret is empty"
+	errDeadLetterMissing = "dead letter does not exist"
+)
+
+// RateLimiter is the component whose rate limits can be adjusted at runtime, such as messaging.MessageHandlerImpl.
+type RateLimiter interface {
+
+	// RateLimits returns current rate limits by name.
+	RateLimits() map[string]int64
+
+	// SetRateLimit updates the rate limit with the name.
+	SetRateLimit(name string, value int64) error
+}
+
+// FilterManager manages message filter rules at runtime, such as messaging.MessageFilter.
+type FilterManager interface {
+	Rules() []messaging.FilterRule
+
+	AddRule(r messaging.FilterRule) error
+
+	RemoveRule(name string) bool
+}
+
+// AdmissionManager manages the connection admission rules at runtime, such as gate.Admission.
+type AdmissionManager interface {
+	Rules() gate.AdmissionRules
+
+	SetRules(rules *gate.AdmissionRules) error
+}
+
+// KeyManager manages the keys to decrypt client credentials at runtime, such as gate.KeyRing.
+type KeyManager interface {
+	Keys() []gate.CredentialKeyInfo
+
+	Add(kid string, secret string, primary bool) error
+
+	SetPrimary(kid string) error
+
+	Retire(kid string) error
+}
+
+// Broadcaster delivers broadcasts asynchronously with segmentation and throttling, such as broadcast.Broadcaster.
+type Broadcaster interface {
+	Broadcast(m *messages.GlideMessage, segment *broadcast.Segment) (*broadcast.Task, error)
+
+	Task(id string) (*broadcast.Task, bool)
+}
+
+// Scheduler delivers messages at the time scheduled, such as schedule.Scheduler.
+type Scheduler interface {
+	Schedule(from string, at time.Time, m *messages.GlideMessage) (int64, error)
+
+	Cancel(id int64, from string) (bool, error)
+}
+
+// Archiver reads and restores messages archived to the object storage, such as archive.Archiver.
+type Archiver interface {
+	Query(q *archive.Query) ([]*store.ArchivedMessage, error)
+
+	Restore(q *archive.Query) (int, error)
+}
+
+// UserPurger deletes all data of users, such as messaging.MessageHandlerImpl.
+type UserPurger interface {
+	PurgeUser(uid string) (*messaging.PurgeResult, error)
+}
+
+// DeadLetterManager inspects and redelivers undeliverable messages, such as messaging.MessageHandlerImpl.
+type DeadLetterManager interface {
+	DeadLetters(after int64, limit int) ([]*store.DeadLetter, error)
+
+	Redeliver(id int64) (bool, error)
+}
+
+// Drainer marks the node draining, the node is not ready for new connections while draining, such as health.Server.
+type Drainer interface {
+	SetDraining(draining bool)
+
+	Draining() bool
+}
+
+type Options struct {
+	// Addr the address of admin http server listen on.
+	Addr string
+	// Token is the bearer token required in the Authorization header of every request, must not be empty.
+	Token string
+}
+
+// Server is an authenticated http server for inspecting and controlling the gateway at runtime.
+//
+//	GET  /clients?selector=   list online clients, filtered by the attribute selector if present, see gate.Selector
+//	POST /clients/kick?id=    kick the client by id
+//	POST /clients/attributes?id=&replace=  set attributes of the client, body: {"key": "value"}
+//	POST /clients/ticket?uid=&device=      set the ticket secret of clients of the user, body: {"secret": ""}
+//	POST /broadcast           broadcast a system message to online clients, body: {"content": ""} and broadcast.Segment
+//	GET  /broadcast?id=       the progress of the broadcast by id
+//	GET  /channels            member count of each channel
+//	GET  /ratelimits          current rate limits
+//	POST /ratelimits          adjust a rate limit, body: {"name": "", "value": 0}
+//	GET  /filters             message filter rules
+//	POST /filters             add or replace a message filter rule, body: messaging.FilterRule
+//	DELETE /filters?name=     remove the message filter rule by name
+//	GET  /admission           connection admission rules
+//	POST /admission           replace the connection admission rules, body: gate.AdmissionRules
+//	GET  /keys                credential keys, secrets are not included
+//	POST /keys                add a credential key or set the primary key, body: {"id": "", "secret": "", "primary": false}
+//	DELETE /keys?id=          retire the credential key by id
+//	POST /schedule            schedule a message sent by the user, body: {"from": "", "deliver_at": 0, "message": {}}
+//	DELETE /schedule?id=      cancel the scheduled message by id
+//	GET  /archive?conversation=&from=&to=   archived messages, from and to are unix seconds
+//	POST /archive?conversation=&from=&to=   restore archived messages to the message history
+//	DELETE /users?uid=        delete all data of the user, see messaging.MessageHandlerImpl.PurgeUser
+//	GET  /deadletters?after=&limit=   undeliverable messages of id greater than after, ordered by id
+//	POST /deadletters/redeliver?id=   redeliver the dead letter by id
+//	GET  /drain               the drain state, {"draining": false}
+//	POST /drain               mark the node draining, the readiness probe fails
+//	DELETE /drain             cancel draining
+type Server struct {
+	token string
+	addr  string
+	mux   *http.ServeMux
+
+	gateway      gate.DefaultGateway
+	subscription subscription.Interface
+	rateLimiter  RateLimiter
+	filters      FilterManager
+	admission    AdmissionManager
+	keys         KeyManager
+	broadcaster  Broadcaster
+	scheduler    Scheduler
+	archiver     Archiver
+	purger       UserPurger
+	deadLetters  DeadLetterManager
+	drainer      Drainer
+}
+
+func NewServer(gateway gate.DefaultGateway, opts *Options) (*Server, error) {
+	if opts.Token == "" {
+		return nil, errors.New("admin token must not be empty")
+	}
+	ret := &Server{
+		token:   opts.Token,
+		addr:    opts.Addr,
+		mux:     http.NewServeMux(),
+		gateway: gateway,
+	}
+	ret.mux.HandleFunc("/clients", ret.handleClients)
+	ret.mux.HandleFunc("/clients/kick", ret.handleKick)
+	ret.mux.HandleFunc("/clients/attributes", ret.handleClientAttributes)
+	ret.mux.HandleFunc("/clients/ticket", ret.handleClientTicket)
+	ret.mux.HandleFunc("/broadcast", ret.handleBroadcast)
+	ret.mux.HandleFunc("/channels", ret.handleChannels)
+	ret.mux.HandleFunc("/ratelimits", ret.handleRateLimits)
+	ret.mux.HandleFunc("/filters", ret.handleFilters)
+	ret.mux.HandleFunc("/admission", ret.handleAdmission)
+	ret.mux.HandleFunc("/keys", ret.handleKeys)
+	ret.mux.HandleFunc("/schedule", ret.handleSchedule)
+	ret.mux.HandleFunc("/archive", ret.handleArchive)
+	ret.mux.HandleFunc("/users", ret.handleUsers)
+	ret.mux.HandleFunc("/deadletters", ret.handleDeadLetters)
+	ret.mux.HandleFunc("/deadletters/redeliver", ret.handleRedeliver)
+	ret.mux.HandleFunc("/drain", ret.handleDrain)
+	return ret, nil
+}
+
+// SetSubscription sets the subscription to inspect channels, it should implement subscription.Inspector.
+func (s *Server) SetSubscription(sub subscription.Interface) {
+	s.subscription = sub
+}
+
+// SetRateLimiter sets the component whose rate limits can be adjusted.
+func (s *Server) SetRateLimiter(r RateLimiter) {
+	s.rateLimiter = r
+}
+
+// SetFilterManager sets the message filter whose rules can be managed.
+func (s *Server) SetFilterManager(f FilterManager) {
+	s.filters = f
+}
+
+// SetAdmissionManager sets the connection admission whose rules can be managed.
+func (s *Server) SetAdmissionManager(a AdmissionManager) {
+	s.admission = a
+}
+
+// SetKeyManager sets the credential keys which can be rotated.
+func (s *Server) SetKeyManager(k KeyManager) {
+	s.keys = k
+}
+
+// SetBroadcaster sets the broadcaster to deliver broadcasts asynchronously, broadcasts are delivered to all online
+// clients synchronously without segmentation if not set.
+func (s *Server) SetBroadcaster(b Broadcaster) {
+	s.broadcaster = b
+}
+
+// SetScheduler sets the scheduler to deliver messages at the time scheduled.
+func (s *Server) SetScheduler(sc Scheduler) {
+	s.scheduler = sc
+}
+
+// SetArchiver sets the archiver to read and restore archived messages.
+func (s *Server) SetArchiver(a Archiver) {
+	s.archiver = a
+}
+
+// SetUserPurger sets the purger to delete all data of users.
+func (s *Server) SetUserPurger(p UserPurger) {
+	s.purger = p
+}
+
+// SetDeadLetterManager sets the manager to inspect and redeliver dead letters.
+func (s *Server) SetDeadLetterManager(m DeadLetterManager) {
+	s.deadLetters = m
+}
+
+// SetDrainer sets the drainer to mark the node draining.
+func (s *Server) SetDrainer(d Drainer) {
+	s.drainer = d
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		audit.Record(audit.EventAdminCall, r.RemoteAddr, r.URL.Path, map[string]string{
+			"method": r.Method,
+			"query":  r.URL.RawQuery,
+			"status": strconv.Itoa(rw.status),
+		})
+	}()
+	if !s.authorized(r) {
+		writeError(rw, http.StatusUnauthorized, errors.New(errUnauthorized))
+		return
+	}
+	s.mux.ServeHTTP(rw, r)
+}
+
+// statusRecorder records the status code of response for audit.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Run starts the admin http server, it blocks until server stopped.
+func (s *Server) Run() error {
+	return http.ListenAndServe(s.addr, s)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *Server) handleClients(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	selector, err := gate.ParseSelector(r.URL.Query().Get("selector"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var clients []gate.Info
+	for _, info := range s.gateway.GetAll() {
+		if selector.Match(info.Attributes) {
+			clients = append(clients, info)
+		}
+	}
+	writeJSON(w, http.StatusOK, clients)
+}
+
+func (s *Server) handleClientAttributes(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	updater, ok := s.gateway.(gate.AttributeUpdater)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	id := gate.ID(r.URL.Query().Get("id"))
+	if id == "" {
+		writeError(w, http.StatusBadRequest, errors.New(errMissingClientID))
+		return
+	}
+	var attributes map[string]string
+	err := json.NewDecoder(r.Body).Decode(&attributes)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = updater.SetClientAttributes(id, attributes, r.URL.Query().Get("replace") == "true")
+	if err != nil {
+		status := http.StatusInternalServerError
+		if gate.IsClientNotExist(err) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nil)
+}
+
+func (s *Server) handleClientTicket(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	updater, ok := s.gateway.(gate.TicketUpdater)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	uid := r.URL.Query().Get("uid")
+	if uid == "" {
+		writeError(w, http.StatusBadRequest, errors.New(errMissingUid))
+		return
+	}
+	req := struct {
+		Secret string `json:"secret"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Secret == "" {
+		writeError(w, http.StatusBadRequest, errors.New(errEmptySecret))
+		return
+	}
+	n, err := updater.UpdateClientTicket(uid, r.URL.Query().Get("device"), req.Secret)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if gate.IsClientNotExist(err) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return
+	}
+	log.I("ticket secret of %d clients of %s updated by admin", n, uid)
+	writeJSON(w, http.StatusOK, map[string]int{"updated": n})
+}
+
+func (s *Server) handleKick(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	id := gate.ID(r.URL.Query().Get("id"))
+	if id == "" {
+		writeError(w, http.StatusBadRequest, errors.New(errMissingClientID))
+		return
+	}
+	_ = s.gateway.EnqueueMessage(id, messages.NewMessage(0, messages.ActionNotifyKickOut, &messages.KickOutNotify{}))
+	err := s.gateway.ExitClient(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if gate.IsClientNotExist(err) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return
+	}
+	log.I("client %s kicked by admin", id)
+	audit.Record(audit.EventKick, r.RemoteAddr, string(id), map[string]string{"reason": "kicked by admin"})
+	writeJSON(w, http.StatusOK, nil)
+}
+
+func (s *Server) handleBroadcast(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		s.handleBroadcastTask(w, r)
+		return
+	}
+	req := struct {
+		messages.SystemNotify
+		broadcast.Segment
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	notify := req.SystemNotify
+	if notify.Content == "" {
+		writeError(w, http.StatusBadRequest, errors.New(errEmptyContent))
+		return
+	}
+	notify.SendAt = time.Now().Unix()
+
+	m := messages.Serialize(messages.NewMessage(0, messages.ActionNotifySystem, &notify))
+	if s.broadcaster != nil {
+		task, err := s.broadcaster.Broadcast(m, &req.Segment)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		log.I("system message broadcast %s queued", task.ID())
+		writeJSON(w, http.StatusOK, task.Status())
+		return
+	}
+
+	delivered := 0
+	for id := range s.gateway.GetAll() {
+		if s.gateway.EnqueueMessage(id, m) == nil {
+			delivered++
+		}
+	}
+	log.I("system message broadcast to %d clients", delivered)
+	writeJSON(w, http.StatusOK, map[string]int{"delivered": delivered})
+}
+
+func (s *Server) handleBroadcastTask(w http.ResponseWriter, r *http.Request) {
+	if s.broadcaster == nil {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	task, ok := s.broadcaster.Task(r.URL.Query().Get("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New(errTaskNotExist))
+		return
+	}
+	writeJSON(w, http.StatusOK, task.Status())
+}
+
+func (s *Server) handleChannels(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	inspector, ok := s.subscription.(subscription.Inspector)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	writeJSON(w, http.StatusOK, inspector.ChannelMemberCounts())
+}
+
+func (s *Server) handleRateLimits(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if s.rateLimiter == nil {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, s.rateLimiter.RateLimits())
+		return
+	}
+
+	req := struct {
+		Name  string `json:"name"`
+		Value int64  `json:"value"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = s.rateLimiter.SetRateLimit(req.Name, req.Value)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	log.I("rate limit %s set to %d by admin", req.Name, req.Value)
+	writeJSON(w, http.StatusOK, s.rateLimiter.RateLimits())
+}
+
+func (s *Server) handleFilters(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	if s.filters == nil {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		rule := messaging.FilterRule{}
+		err := json.NewDecoder(r.Body).Decode(&rule)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = s.filters.AddRule(rule)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		log.I("filter rule %s added by admin", rule.Name)
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if !s.filters.RemoveRule(name) {
+			writeError(w, http.StatusNotFound, errors.New(errRuleNotExist))
+			return
+		}
+		log.I("filter rule %s removed by admin", name)
+	}
+	writeJSON(w, http.StatusOK, s.filters.Rules())
+}
+
+func (s *Server) handleAdmission(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if s.admission == nil {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	if r.Method == http.MethodPost {
+		rules := gate.AdmissionRules{}
+		err := json.NewDecoder(r.Body).Decode(&rules)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = s.admission.SetRules(&rules)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		log.I("admission rules updated by admin")
+	}
+	writeJSON(w, http.StatusOK, s.admission.Rules())
+}
+
+func (s *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	if s.keys == nil {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		req := struct {
+			ID      string `json:"id"`
+			Secret  string `json:"secret"`
+			Primary bool   `json:"primary"`
+		}{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		// set primary key only if secret is empty
+		if req.Secret == "" {
+			err = s.keys.SetPrimary(req.ID)
+		} else {
+			err = s.keys.Add(req.ID, req.Secret, req.Primary)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		log.I("credential key %s updated by admin, primary=%v", req.ID, req.Primary)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		err := s.keys.Retire(id)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		log.I("credential key %s retired by admin", id)
+	}
+	writeJSON(w, http.StatusOK, s.keys.Keys())
+}
+
+func (s *Server) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	if s.scheduler == nil {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	if r.Method == http.MethodDelete {
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		ok, err := s.scheduler.Cancel(id, "")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusNotFound, errors.New(errScheduledMissing))
+			return
+		}
+		log.I("scheduled message %d canceled by admin", id)
+		writeJSON(w, http.StatusOK, nil)
+		return
+	}
+
+	req := struct {
+		From      string                 `json:"from"`
+		DeliverAt int64                  `json:"deliver_at"`
+		Message   *messages.GlideMessage `json:"message"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.From == "" || req.Message == nil || req.Message.Action == "" {
+		writeError(w, http.StatusBadRequest, errors.New(errInvalidMessage))
+		return
+	}
+	id, err := s.scheduler.Schedule(req.From, time.Unix(req.DeliverAt, 0), req.Message)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &messages.Scheduled{ID: id, DeliverAt: req.DeliverAt})
+}
+
+func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if s.archiver == nil {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	q := &archive.Query{Conversation: conversation.ID(r.URL.Query().Get("conversation"))}
+	for _, t := range []struct {
+		param string
+		time  *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		v := r.URL.Query().Get(t.param)
+		if v == "" {
+			continue
+		}
+		sec, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New(errInvalidTime))
+			return
+		}
+		*t.time = time.Unix(sec, 0)
+	}
+
+	if r.Method == http.MethodPost {
+		n, err := s.archiver.Restore(q)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		log.I("%d archived messages restored by admin", n)
+		writeJSON(w, http.StatusOK, map[string]int{"restored": n})
+		return
+	}
+	ms, err := s.archiver.Query(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ms)
+}
+
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodDelete) {
+		return
+	}
+	if s.purger == nil {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	uid := r.URL.Query().Get("uid")
+	if uid == "" {
+		writeError(w, http.StatusBadRequest, errors.New(errMissingUid))
+		return
+	}
+	result, err := s.purger.PurgeUser(uid)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	log.I("user %s purged by admin", uid)
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	if s.deadLetters == nil {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	var after int64
+	var limit int
+	var err error
+	if v := r.URL.Query().Get("after"); v != "" {
+		if after, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	ls, err := s.deadLetters.DeadLetters(after, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ls)
+}
+
+func (s *Server) handleRedeliver(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	if s.deadLetters == nil {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ok, err := s.deadLetters.Redeliver(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New(errDeadLetterMissing))
+		return
+	}
+	log.I("dead letter %d redelivered by admin", id)
+	writeJSON(w, http.StatusOK, nil)
+}
+
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	if s.drainer == nil {
+		writeError(w, http.StatusNotImplemented, errors.New(errNotSupported))
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		s.drainer.SetDraining(true)
+		log.I("node draining by admin")
+	case http.MethodDelete:
+		s.drainer.SetDraining(false)
+		log.I("node draining canceled by admin")
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"draining": s.drainer.Draining()})
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	writeError(w, http.StatusMethodNotAllowed, errors.New(errMethodNotAllowed))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if data == nil {
+		data = struct{}{}
+	}
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
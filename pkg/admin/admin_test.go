@@ -0,0 +1,314 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/glide-im/glide/pkg/archive"
+	"github.com/glide-im/glide/pkg/broadcast"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/messaging"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type mockGateway struct {
+	clients  map[gate.ID]gate.Info
+	enqueued map[gate.ID][]*messages.GlideMessage
+	tickets  map[string]string
+}
+
+func newMockGateway(ids ...gate.ID) *mockGateway {
+	m := &mockGateway{clients: map[gate.ID]gate.Info{}, enqueued: map[gate.ID][]*messages.GlideMessage{}}
+	for _, id := range ids {
+		m.clients[id] = gate.Info{ID: id}
+	}
+	return m
+}
+
+func (m *mockGateway) SetClientID(old gate.ID, new_ gate.ID) error { return nil }
+
+func (m *mockGateway) UpdateClient(id gate.ID, info *gate.ClientSecrets) error { return nil }
+
+func (m *mockGateway) ExitClient(id gate.ID) error {
+	if _, ok := m.clients[id]; !ok {
+		return errors.New("client does not exist")
+	}
+	delete(m.clients, id)
+	return nil
+}
+
+func (m *mockGateway) EnqueueMessage(id gate.ID, message *messages.GlideMessage) error {
+	m.enqueued[id] = append(m.enqueued[id], message)
+	return nil
+}
+
+func (m *mockGateway) SetClientAttributes(id gate.ID, attributes map[string]string, replace bool) error {
+	info, ok := m.clients[id]
+	if !ok {
+		return errors.New("client does not exist")
+	}
+	info.Attributes = attributes
+	m.clients[id] = info
+	return nil
+}
+
+func (m *mockGateway) UpdateClientTicket(uid string, device string, secret string) (int, error) {
+	n := 0
+	for id := range m.clients {
+		if id.UID() == uid {
+			if m.tickets == nil {
+				m.tickets = map[string]string{}
+			}
+			m.tickets[uid] = secret
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, errors.New("client does not exist")
+	}
+	return n, nil
+}
+
+func (m *mockGateway) GetClient(id gate.ID) gate.Client { return nil }
+
+func (m *mockGateway) GetAll() map[gate.ID]gate.Info { return m.clients }
+
+func (m *mockGateway) SetMessageHandler(h gate.MessageHandler) {}
+
+func (m *mockGateway) AddClient(cs gate.Client) {}
+
+type mockRateLimiter struct {
+	limits map[string]int64
+}
+
+func (m *mockRateLimiter) RateLimits() map[string]int64 { return m.limits }
+
+func (m *mockRateLimiter) SetRateLimit(name string, value int64) error {
+	m.limits[name] = value
+	return nil
+}
+
+func request(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_Unauthorized(t *testing.T) {
+	s, err := NewServer(newMockGateway(), &Options{Token: "secret"})
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/clients", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	_, err = NewServer(newMockGateway(), &Options{})
+	assert.Error(t, err)
+}
+
+func TestServer_ClientsAndKick(t *testing.T) {
+	g := newMockGateway("1_gw_1", "2_gw_1")
+	s, _ := NewServer(g, &Options{Token: "secret"})
+
+	rec := request(s, http.MethodGet, "/clients", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var clients []gate.Info
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &clients))
+	assert.Len(t, clients, 2)
+
+	rec = request(s, http.MethodPost, "/clients/kick?id=1_gw_1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, g.clients, 1)
+	assert.Equal(t, messages.ActionNotifyKickOut, g.enqueued["1_gw_1"][0].GetAction())
+
+	rec = request(s, http.MethodPost, "/clients/kick?id=1_gw_1", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_ClientTicket(t *testing.T) {
+	g := newMockGateway(gate.NewID("gw", "1", "phone"), gate.NewID("gw", "1", "pc"))
+	s, _ := NewServer(g, &Options{Token: "secret"})
+
+	rec := request(s, http.MethodPost, "/clients/ticket?uid=1", `{"secret":"new"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"updated":2}`, rec.Body.String())
+	assert.Equal(t, "new", g.tickets["1"])
+
+	rec = request(s, http.MethodPost, "/clients/ticket?uid=1", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = request(s, http.MethodPost, "/clients/ticket?uid=2", `{"secret":"new"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_ClientAttributes(t *testing.T) {
+	g := newMockGateway("1_gw_1", "2_gw_1")
+	s, _ := NewServer(g, &Options{Token: "secret"})
+
+	rec := request(s, http.MethodPost, "/clients/attributes?id=1_gw_1", `{"region":"eu","app_version":"3.10"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = request(s, http.MethodPost, "/clients/attributes?id=3_gw_1", `{"region":"eu"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = request(s, http.MethodGet, "/clients?selector="+url.QueryEscape("region=eu and app_version>=3.2"), "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var clients []gate.Info
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &clients))
+	assert.Len(t, clients, 1)
+	assert.Equal(t, gate.ID("1_gw_1"), clients[0].ID)
+
+	rec = request(s, http.MethodGet, "/clients?selector=region", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServer_Broadcast(t *testing.T) {
+	g := newMockGateway("1_gw_1", "2_gw_1")
+	s, _ := NewServer(g, &Options{Token: "secret"})
+
+	rec := request(s, http.MethodPost, "/broadcast", `{"content":"maintenance"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, g.enqueued["2_gw_1"], 1)
+	assert.Equal(t, messages.ActionNotifySystem, g.enqueued["2_gw_1"][0].GetAction())
+
+	rec = request(s, http.MethodPost, "/broadcast", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServer_BroadcastSegment(t *testing.T) {
+	g := newMockGateway("gw_1_1", "gw_2_2")
+	s, _ := NewServer(g, &Options{Token: "secret"})
+
+	rec := request(s, http.MethodGet, "/broadcast?id=1", "")
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+
+	b := broadcast.NewBroadcaster(g, &broadcast.Options{})
+	defer b.Close()
+	s.SetBroadcaster(b)
+
+	rec = request(s, http.MethodPost, "/broadcast", `{"content":"maintenance","devices":["2"]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	status := broadcast.TaskStatus{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+
+	task, ok := b.Task(status.ID)
+	assert.True(t, ok)
+	task.Wait()
+	assert.Len(t, g.enqueued["gw_2_2"], 1)
+	assert.Empty(t, g.enqueued["gw_1_1"])
+
+	rec = request(s, http.MethodGet, "/broadcast?id="+status.ID, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.True(t, status.Done)
+	assert.Equal(t, int64(1), status.Delivered)
+
+	rec = request(s, http.MethodGet, "/broadcast?id=unknown", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_RateLimits(t *testing.T) {
+	s, _ := NewServer(newMockGateway(), &Options{Token: "secret"})
+
+	rec := request(s, http.MethodGet, "/ratelimits", "")
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+
+	r := &mockRateLimiter{limits: map[string]int64{"a": 1}}
+	s.SetRateLimiter(r)
+	rec = request(s, http.MethodPost, "/ratelimits", `{"name":"a","value":10}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(10), r.limits["a"])
+}
+
+func TestServer_Filters(t *testing.T) {
+	s, _ := NewServer(newMockGateway(), &Options{Token: "secret"})
+	s.SetFilterManager(messaging.NewMessageFilter())
+
+	rec := request(s, http.MethodPost, "/filters", `{"name":"spam","keywords":["spam"],"action":"block"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var rules []messaging.FilterRule
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rules))
+	assert.Equal(t, "spam", rules[0].Name)
+
+	rec = request(s, http.MethodPost, "/filters", `{"name":"bad","action":"drop"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = request(s, http.MethodDelete, "/filters?name=spam", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = request(s, http.MethodDelete, "/filters?name=spam", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_Admission(t *testing.T) {
+	s, _ := NewServer(newMockGateway(), &Options{Token: "secret"})
+	admission, _ := gate.NewAdmission(nil)
+	s.SetAdmissionManager(admission)
+
+	rec := request(s, http.MethodPost, "/admission", `{"deny":["10.0.0.0/8"]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	ok, _ := admission.Admit("10.1.2.3")
+	assert.False(t, ok)
+
+	rec = request(s, http.MethodPost, "/admission", `{"deny":["10.0.0.0/33"]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, []string{"10.0.0.0/8"}, admission.Rules().Deny)
+}
+
+func TestServer_Keys(t *testing.T) {
+	s, _ := NewServer(newMockGateway(), &Options{Token: "secret"})
+	ring := gate.NewKeyRing(gate.DefaultKeyID, "old")
+	s.SetKeyManager(ring)
+
+	rec := request(s, http.MethodPost, "/keys", `{"id":"k2","secret":"new","primary":true}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "new")
+
+	rec = request(s, http.MethodDelete, "/keys?id=k2", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = request(s, http.MethodDelete, "/keys?id="+gate.DefaultKeyID, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var keys []gate.CredentialKeyInfo
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &keys))
+	assert.Equal(t, []gate.CredentialKeyInfo{{ID: "k2", Primary: true, AddedAt: keys[0].AddedAt}}, keys)
+}
+
+type mockArchiver struct {
+	query *archive.Query
+}
+
+func (m *mockArchiver) Query(q *archive.Query) ([]*store.ArchivedMessage, error) {
+	m.query = q
+	return []*store.ArchivedMessage{{Conversation: q.Conversation, Message: &messages.ChatMessage{Mid: 1}}}, nil
+}
+
+func (m *mockArchiver) Restore(q *archive.Query) (int, error) {
+	m.query = q
+	return 1, nil
+}
+
+func TestServer_Archive(t *testing.T) {
+	s, _ := NewServer(newMockGateway(), &Options{Token: "secret"})
+	a := &mockArchiver{}
+	s.SetArchiver(a)
+
+	rec := request(s, http.MethodGet, "/archive?conversation=p2p:1_2&from=100", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(100), a.query.From.Unix())
+	assert.True(t, a.query.To.IsZero())
+	var ms []*store.ArchivedMessage
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ms))
+	assert.Equal(t, int64(1), ms[0].Message.Mid)
+
+	rec = request(s, http.MethodPost, "/archive?to=abc", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = request(s, http.MethodPost, "/archive?to=200", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"restored":1}`, rec.Body.String())
+}
@@ -0,0 +1,371 @@
+// Package archive exports message history older than the retention to the S3 compatible object storage as gzip
+// compressed JSON lines, prunes exported messages from the message history store, and reads or restores them back
+// for compliance queries.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/media"
+	"github.com/glide-im/glide/pkg/store"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var log = logger.Named("archive")
+
+const (
+	defaultAge       = time.Hour * 24 * 90
+	defaultInterval  = time.Hour
+	defaultBatchSize = 10000
+	defaultPrefix    = "archive/"
+
+	// dayLayout the layout of the day part of object keys, messages are grouped by the day sent in UTC.
+	dayLayout   = "2006-01-02"
+	objectMime  = "application/gzip"
+	objectExt   = ".jsonl.gz"
+	maxScanLine = 1024 * 1024
+)
+
+// ObjectStorage stores archive objects, it's implemented by media.S3Storage.
+type ObjectStorage interface {
+	PutObject(key string, mime string, data []byte) error
+
+	GetObject(key string) ([]byte, error)
+
+	DeleteObject(key string) error
+
+	// ListObjects returns keys of objects with the prefix in lexicographical order.
+	ListObjects(prefix string) ([]string, error)
+}
+
+var _ ObjectStorage = (*media.S3Storage)(nil)
+var _ store.UserPurgeStore = (*Archiver)(nil)
+
+type Options struct {
+	// Store the message history to archive, required.
+	Store store.ArchiveStore
+	// Storage the object storage to export messages to, required.
+	Storage ObjectStorage
+	// Age messages sent before Age ago are archived, default 90 days.
+	Age time.Duration
+	// Interval the interval to run the archive, default 1 hour.
+	Interval time.Duration
+	// BatchSize the max count of messages exported at a time, default 10000.
+	BatchSize int
+	// Prefix the prefix of object keys, default "archive/".
+	Prefix string
+}
+
+// Query selects archived messages.
+type Query struct {
+	// Conversation the conversation of messages, empty for all conversations.
+	Conversation conversation.ID
+	// From and To the range of time messages sent, [From, To), zero for unbounded.
+	From time.Time
+	To   time.Time
+}
+
+func (q *Query) match(m *store.ArchivedMessage) bool {
+	if q.Conversation != "" && q.Conversation != m.Conversation {
+		return false
+	}
+	if !q.From.IsZero() && m.Message.SendAt < q.From.Unix() {
+		return false
+	}
+	if !q.To.IsZero() && m.Message.SendAt >= q.To.Unix() {
+		return false
+	}
+	return true
+}
+
+// Archiver exports messages older than the age to objects keyed by `<prefix><day>/<hash>.jsonl.gz` periodically,
+// each line of the object is a json of store.ArchivedMessage. Only one archiver should run for a message store.
+type Archiver struct {
+	store     store.ArchiveStore
+	storage   ObjectStorage
+	age       time.Duration
+	interval  time.Duration
+	batchSize int
+	prefix    string
+
+	// mu serializes archive runs.
+	mu   sync.Mutex
+	quit chan struct{}
+}
+
+func New(opts *Options) (*Archiver, error) {
+	if opts.Store == nil || opts.Storage == nil {
+		return nil, errors.New("store and storage are required")
+	}
+	a := &Archiver{
+		store:     opts.Store,
+		storage:   opts.Storage,
+		age:       opts.Age,
+		interval:  opts.Interval,
+		batchSize: opts.BatchSize,
+		prefix:    opts.Prefix,
+		quit:      make(chan struct{}),
+	}
+	if a.age <= 0 {
+		a.age = defaultAge
+	}
+	if a.interval <= 0 {
+		a.interval = defaultInterval
+	}
+	if a.batchSize <= 0 {
+		a.batchSize = defaultBatchSize
+	}
+	if a.prefix == "" {
+		a.prefix = defaultPrefix
+	}
+	return a, nil
+}
+
+// Start runs the archive periodically until Stop.
+func (a *Archiver) Start() {
+	go func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			n, err := a.Archive(time.Now().Add(-a.age))
+			if err != nil {
+				log.E("archive messages error: %v", err)
+			} else if n > 0 {
+				log.I("%d messages archived", n)
+			}
+			select {
+			case <-ticker.C:
+			case <-a.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (a *Archiver) Stop() {
+	close(a.quit)
+}
+
+// Archive exports and prunes all messages sent before, returns the count of messages archived.
+func (a *Archiver) Archive(before time.Time) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	total := 0
+	var last *store.ArchivedMessage
+	for {
+		ms, err := a.store.GetArchivable(before.Unix(), a.batchSize)
+		if err != nil || len(ms) == 0 {
+			return total, err
+		}
+		if last != nil && ms[0].Conversation == last.Conversation && ms[0].Message.Mid == last.Message.Mid {
+			return total, errors.New("archived messages are not removed from store")
+		}
+		last = ms[0]
+		if err = a.export(ms); err != nil {
+			return total, err
+		}
+		if err = a.store.RemoveArchived(ms); err != nil {
+			return total, err
+		}
+		total += len(ms)
+		if len(ms) < a.batchSize {
+			return total, nil
+		}
+	}
+}
+
+// export uploads messages grouped by the day sent.
+func (a *Archiver) export(ms []*store.ArchivedMessage) error {
+	days := map[string][]*store.ArchivedMessage{}
+	for _, m := range ms {
+		day := time.Unix(m.Message.SendAt, 0).UTC().Format(dayLayout)
+		days[day] = append(days[day], m)
+	}
+	for day, dms := range days {
+		data, err := encode(dms)
+		if err != nil {
+			return err
+		}
+		// objects are named by the hash of content, the same batch exported twice is the same object.
+		sum := sha256.Sum256(data)
+		key := a.prefix + day + "/" + hex.EncodeToString(sum[:8]) + objectExt
+		if err = a.storage.PutObject(key, objectMime, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query reads archived messages matching the query, ordered by the time sent.
+func (a *Archiver) Query(q *Query) ([]*store.ArchivedMessage, error) {
+	keys, err := a.objects(q)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*store.ArchivedMessage
+	for _, key := range keys {
+		data, err := a.storage.GetObject(key)
+		if err != nil {
+			return nil, err
+		}
+		ms, err := decode(data)
+		if err != nil {
+			return nil, errors.New("decode " + key + ": " + err.Error())
+		}
+		for _, m := range ms {
+			if q.match(m) {
+				ret = append(ret, m)
+			}
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Message.SendAt < ret[j].Message.SendAt
+	})
+	return ret, nil
+}
+
+// Restore stores archived messages matching the query back to the message history, returns the count of messages.
+// Restored messages are archived again when the archive runs if they are still older than the age.
+func (a *Archiver) Restore(q *Query) (int, error) {
+	ms, err := a.Query(q)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(ms); i += a.batchSize {
+		end := i + a.batchSize
+		if end > len(ms) {
+			end = len(ms)
+		}
+		if err = a.store.RestoreArchived(ms[i:end]); err != nil {
+			return i, err
+		}
+	}
+	return len(ms), nil
+}
+
+// PurgeUser removes archived P2P messages sent or received by uid and channel messages sent by uid, objects containing
+// them are rewritten with the rest messages in place or deleted if nothing left.
+func (a *Archiver) PurgeUser(uid string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	keys, err := a.objects(&Query{})
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, key := range keys {
+		data, err := a.storage.GetObject(key)
+		if err != nil {
+			return err
+		}
+		ms, err := decode(data)
+		if err != nil {
+			return errors.New("decode " + key + ": " + err.Error())
+		}
+		rest := make([]*store.ArchivedMessage, 0, len(ms))
+		for _, m := range ms {
+			if !ofUser(m, uid) {
+				rest = append(rest, m)
+			}
+		}
+		if len(rest) == len(ms) {
+			continue
+		}
+		if len(rest) == 0 {
+			err = a.storage.DeleteObject(key)
+		} else if data, err = encode(rest); err == nil {
+			err = a.storage.PutObject(key, objectMime, data)
+		}
+		if err != nil {
+			return err
+		}
+		total += len(ms) - len(rest)
+	}
+	log.I("%d archived messages of user %s purged", total, uid)
+	return nil
+}
+
+// ofUser returns true if the message is sent by uid, or the P2P message is received by uid.
+func ofUser(m *store.ArchivedMessage, uid string) bool {
+	if m.Message.From == uid {
+		return true
+	}
+	return m.Conversation.Type() == conversation.TypeP2P && m.Message.To == uid
+}
+
+// objects returns keys of objects may contain messages in the time range of query.
+func (a *Archiver) objects(q *Query) ([]string, error) {
+	keys, err := a.storage.ListObjects(a.prefix)
+	if err != nil {
+		return nil, err
+	}
+	var from, to string
+	if !q.From.IsZero() {
+		from = q.From.UTC().Format(dayLayout)
+	}
+	if !q.To.IsZero() {
+		to = q.To.UTC().Format(dayLayout)
+	}
+	var ret []string
+	for _, key := range keys {
+		if !strings.HasSuffix(key, objectExt) {
+			continue
+		}
+		day := strings.SplitN(strings.TrimPrefix(key, a.prefix), "/", 2)[0]
+		if (from != "" && day < from) || (to != "" && day > to) {
+			continue
+		}
+		ret = append(ret, key)
+	}
+	return ret, nil
+}
+
+func encode(ms []*store.ArchivedMessage) ([]byte, error) {
+	buf := bytes.Buffer{}
+	w := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(w)
+	for _, m := range ms {
+		if err := enc.Encode(m); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decode(data []byte) ([]*store.ArchivedMessage, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var ret []*store.ArchivedMessage
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxScanLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		m := &store.ArchivedMessage{}
+		if err = json.Unmarshal(scanner.Bytes(), m); err != nil {
+			return nil, errors.New("line " + strconv.Itoa(line) + ": " + err.Error())
+		}
+		ret = append(ret, m)
+	}
+	return ret, scanner.Err()
+}
@@ -0,0 +1,179 @@
+package archive
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{objects: map[string][]byte{}}
+}
+
+func (m *memStorage) PutObject(key string, _ string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = data
+	return nil
+}
+
+func (m *memStorage) GetObject(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, errors.New("not exist")
+	}
+	return data, nil
+}
+
+func (m *memStorage) DeleteObject(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memStorage) ListObjects(prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for k := range m.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+type memArchiveStore struct {
+	ms []*store.ArchivedMessage
+}
+
+func (s *memArchiveStore) GetArchivable(before int64, limit int) ([]*store.ArchivedMessage, error) {
+	var ret []*store.ArchivedMessage
+	for _, m := range s.ms {
+		if m.Message.SendAt < before && len(ret) < limit {
+			ret = append(ret, m)
+		}
+	}
+	return ret, nil
+}
+
+func (s *memArchiveStore) RemoveArchived(ms []*store.ArchivedMessage) error {
+	removed := map[int64]bool{}
+	for _, m := range ms {
+		removed[m.Message.Mid] = true
+	}
+	var remain []*store.ArchivedMessage
+	for _, m := range s.ms {
+		if !removed[m.Message.Mid] {
+			remain = append(remain, m)
+		}
+	}
+	s.ms = remain
+	return nil
+}
+
+func (s *memArchiveStore) RestoreArchived(ms []*store.ArchivedMessage) error {
+	s.ms = append(s.ms, ms...)
+	return nil
+}
+
+func newArchivedMessage(mid int64, conv string, sendAt time.Time) *store.ArchivedMessage {
+	return &store.ArchivedMessage{
+		Conversation: conversation.ID("p2p:" + conv),
+		Message:      &messages.ChatMessage{Mid: mid, From: "1", To: conv, Content: "hello", SendAt: sendAt.Unix()},
+	}
+}
+
+func TestArchiver_ArchiveAndQuery(t *testing.T) {
+	day := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := &memArchiveStore{}
+	for i := 0; i < 5; i++ {
+		s.ms = append(s.ms, newArchivedMessage(int64(i+1), "a", day.Add(time.Duration(i)*time.Hour*12)))
+	}
+	s.ms = append(s.ms, newArchivedMessage(6, "b", day))
+	s.ms = append(s.ms, newArchivedMessage(7, "a", day.AddDate(1, 0, 0)))
+
+	storage := newMemStorage()
+	a, err := New(&Options{Store: s, Storage: storage, BatchSize: 2})
+	assert.NoError(t, err)
+
+	n, err := a.Archive(day.AddDate(0, 1, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.Len(t, s.ms, 1)
+	for k := range storage.objects {
+		assert.True(t, strings.HasPrefix(k, "archive/2020-01-0"), k)
+	}
+
+	ms, err := a.Query(&Query{Conversation: "p2p:a", From: day, To: day.AddDate(0, 0, 2)})
+	assert.NoError(t, err)
+	assert.Len(t, ms, 4)
+	for i, m := range ms {
+		assert.Equal(t, int64(i+1), m.Message.Mid)
+	}
+
+	n, err = a.Restore(&Query{Conversation: "p2p:b"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Len(t, s.ms, 2)
+}
+
+func TestArchiver_ArchiveNotRemoved(t *testing.T) {
+	s := &stuckStore{memArchiveStore{ms: []*store.ArchivedMessage{newArchivedMessage(1, "a", time.Unix(100, 0))}}}
+	a, err := New(&Options{Store: s, Storage: newMemStorage(), BatchSize: 1})
+	assert.NoError(t, err)
+
+	_, err = a.Archive(time.Unix(200, 0))
+	assert.Error(t, err)
+}
+
+type stuckStore struct {
+	memArchiveStore
+}
+
+func (s *stuckStore) RemoveArchived(_ []*store.ArchivedMessage) error {
+	return nil
+}
+
+func TestArchiver_PurgeUser(t *testing.T) {
+	day := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	p2p := conversation.NewP2P("1", "2").ID
+	channel := conversation.NewChannel("g").ID
+	s := &memArchiveStore{ms: []*store.ArchivedMessage{
+		{Conversation: p2p, Message: &messages.ChatMessage{Mid: 1, From: "1", To: "2", SendAt: day.Unix()}},
+		{Conversation: p2p, Message: &messages.ChatMessage{Mid: 2, From: "2", To: "1", SendAt: day.Unix()}},
+		{Conversation: channel, Message: &messages.ChatMessage{Mid: 3, From: "3", To: "g", SendAt: day.Unix()}},
+		{Conversation: channel, Message: &messages.ChatMessage{Mid: 4, From: "2", To: "g", SendAt: day.AddDate(0, 0, 1).Unix()}},
+	}}
+	storage := newMemStorage()
+	a, err := New(&Options{Store: s, Storage: storage})
+	assert.NoError(t, err)
+	n, err := a.Archive(day.AddDate(0, 1, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Len(t, storage.objects, 2)
+
+	assert.NoError(t, a.PurgeUser("2"))
+	ms, err := a.Query(&Query{})
+	assert.NoError(t, err)
+	assert.Len(t, ms, 1)
+	assert.Equal(t, int64(3), ms[0].Message.Mid)
+	// the object of the second day contains messages of the user only.
+	assert.Len(t, storage.objects, 1)
+}
@@ -0,0 +1,168 @@
+// Package audit records administrative and security-relevant events to an append-only log, entries are chained by
+// hash so that modification, insertion and deletion of entries can be detected by Verify.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/glide-im/glide/pkg/logger"
+	"sync"
+	"time"
+)
+
+var log = logger.Named("audit")
+
+const (
+	EventAuthFailure = "auth.failure"
+	EventKick        = "client.kick"
+	EventKeyRotation = "key.rotation"
+	EventAdminCall   = "admin.call"
+	EventModeration  = "moderation"
+)
+
+// Entry is an audit log entry, Hash is the hex of SHA256(Prev + "\n" + json of the entry without Hash), Prev is the
+// hash of the previous entry, empty for the first entry.
+type Entry struct {
+	Seq  int64  `json:"seq"`
+	Time int64  `json:"time"`
+	Type string `json:"type"`
+	// Actor who did it, the user id, the admin address, or empty for the server.
+	Actor string `json:"actor,omitempty"`
+	// Target what it's done to, the client id, key id, etc.
+	Target string            `json:"target,omitempty"`
+	Detail map[string]string `json:"detail,omitempty"`
+	Prev   string            `json:"prev,omitempty"`
+	Hash   string            `json:"hash"`
+}
+
+// Sink writes audit entries in order.
+type Sink interface {
+	Write(e *Entry) error
+	Close() error
+}
+
+// Tailer is the optional interface of Sink returns the last entry written, the chain is continued from it on start.
+type Tailer interface {
+
+	// Last returns the last entry written, nil if nothing written.
+	Last() (*Entry, error)
+}
+
+// Logger chains and writes audit entries to sinks.
+type Logger struct {
+	sinks []Sink
+
+	mu   sync.Mutex
+	seq  int64
+	prev string
+}
+
+// New creates the audit logger writes to sinks, the chain is continued from the last entry of the first Tailer sink.
+func New(sinks ...Sink) (*Logger, error) {
+	if len(sinks) == 0 {
+		return nil, errors.New("no audit sink")
+	}
+	l := &Logger{sinks: sinks}
+	for _, s := range sinks {
+		t, ok := s.(Tailer)
+		if !ok {
+			continue
+		}
+		last, err := t.Last()
+		if err != nil {
+			return nil, err
+		}
+		if last != nil {
+			l.seq = last.Seq
+			l.prev = last.Hash
+		}
+		break
+	}
+	return l, nil
+}
+
+// Record chains and writes the entry to all sinks, errors of sinks are logged.
+func (l *Logger) Record(eventType string, actor string, target string, detail map[string]string) *Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := &Entry{
+		Seq:    l.seq + 1,
+		Time:   time.Now().UnixMilli(),
+		Type:   eventType,
+		Actor:  actor,
+		Target: target,
+		Detail: detail,
+		Prev:   l.prev,
+	}
+	e.Hash = hash(e)
+	l.seq = e.Seq
+	l.prev = e.Hash
+
+	for _, s := range l.sinks {
+		if err := s.Write(e); err != nil {
+			log.E("write audit entry %d error: %v", e.Seq, err)
+		}
+	}
+	return e
+}
+
+// Close closes all sinks.
+func (l *Logger) Close() error {
+	var err error
+	for _, s := range l.sinks {
+		if e := s.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Verify checks the hash chain of entries in order, returns the error of the first broken entry.
+func Verify(entries []*Entry) error {
+	for i, e := range entries {
+		if i > 0 {
+			prev := entries[i-1]
+			if e.Seq != prev.Seq+1 {
+				return fmt.Errorf("audit entry %d: missing entries after %d", e.Seq, prev.Seq)
+			}
+			if e.Prev != prev.Hash {
+				return fmt.Errorf("audit entry %d: previous hash mismatch", e.Seq)
+			}
+		}
+		if hash(e) != e.Hash {
+			return fmt.Errorf("audit entry %d: hash mismatch", e.Seq)
+		}
+	}
+	return nil
+}
+
+func hash(e *Entry) string {
+	c := *e
+	c.Hash = ""
+	// the marshaling is deterministic as keys of map are sorted.
+	b, _ := json.Marshal(&c)
+	h := sha256.New()
+	h.Write([]byte(e.Prev))
+	h.Write([]byte{'\n'})
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+var defaultLogger *Logger
+
+// SetDefault sets the logger used by package level Record, nil to disable.
+func SetDefault(l *Logger) {
+	defaultLogger = l
+}
+
+// Record records the entry with the default logger, it's no-op if the default logger is not set.
+func Record(eventType string, actor string, target string, detail map[string]string) {
+	if defaultLogger == nil {
+		return
+	}
+	defaultLogger.Record(eventType, actor, target, detail)
+}
@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogger_Chain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(path)
+	assert.NoError(t, err)
+	l, err := New(sink)
+	assert.NoError(t, err)
+	l.Record(EventAuthFailure, "1", "", map[string]string{"reason": "invalid ticket"})
+	l.Record(EventKick, "admin", "uid_1", nil)
+	assert.NoError(t, l.Close())
+
+	// the chain is continued after restart.
+	sink, err = NewFileSink(path)
+	assert.NoError(t, err)
+	l, err = New(sink)
+	assert.NoError(t, err)
+	e := l.Record(EventKeyRotation, "", "k2", nil)
+	assert.Equal(t, int64(3), e.Seq)
+	assert.NoError(t, l.Close())
+
+	entries, err := ReadFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.NoError(t, Verify(entries))
+
+	entries[0].Detail["reason"] = "modified"
+	assert.Error(t, Verify(entries))
+	entries, _ = ReadFile(path)
+	assert.Error(t, Verify([]*Entry{entries[0], entries[2]}))
+}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"encoding/json"
+	"github.com/Shopify/sarama"
+	"strconv"
+)
+
+// KafkaAuditTopic the default topic of KafkaSink.
+const KafkaAuditTopic = "glide_audit"
+
+var _ Sink = (*KafkaSink)(nil)
+
+// KafkaSink produces entries as json to the topic synchronously, all entries are sent to the same partition to keep
+// the order of the chain.
+type KafkaSink struct {
+	topic    string
+	producer sarama.SyncProducer
+}
+
+// NewKafkaSink connects to the kafka brokers, topic is KafkaAuditTopic if empty.
+func NewKafkaSink(address []string, topic string) (*KafkaSink, error) {
+	if topic == "" {
+		topic = KafkaAuditTopic
+	}
+	config := sarama.NewConfig()
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Partitioner = sarama.NewManualPartitioner
+	config.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer(address, config)
+	if err != nil {
+		return nil, err
+	}
+	return &KafkaSink{topic: topic, producer: producer}, nil
+}
+
+func (k *KafkaSink) Write(e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, _, err = k.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:     k.topic,
+		Key:       sarama.StringEncoder(strconv.FormatInt(e.Seq, 10)),
+		Value:     sarama.ByteEncoder(b),
+		Partition: 0,
+	})
+	return err
+}
+
+func (k *KafkaSink) Close() error {
+	return k.producer.Close()
+}
@@ -0,0 +1,36 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"encoding/json"
+	"log/syslog"
+)
+
+var _ Sink = (*SyslogSink)(nil)
+
+// SyslogSink writes entries as json to the syslog daemon with the facility LOG_AUTH.
+type SyslogSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogSink connects to the syslog daemon at raddr by network, the local daemon is used if network is empty.
+func NewSyslogSink(network string, raddr string, tag string) (*SyslogSink, error) {
+	w, err := syslog.Dial(network, raddr, syslog.LOG_NOTICE|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{w: w}, nil
+}
+
+func (s *SyslogSink) Write(e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.w.Notice(string(b))
+}
+
+func (s *SyslogSink) Close() error {
+	return s.w.Close()
+}
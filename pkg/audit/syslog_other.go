@@ -0,0 +1,12 @@
+//go:build windows || plan9
+
+package audit
+
+import (
+	"errors"
+)
+
+// NewSyslogSink returns error as syslog is not supported on this platform.
+func NewSyslogSink(network string, raddr string, tag string) (Sink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
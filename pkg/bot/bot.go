@@ -0,0 +1,251 @@
+// Package bot runs bots in the process of the gateway. A bot is a user whose messages are handled by a Handler, an
+// in-process Go handler or an HTTP webhook, instead of a client connection. The Bot is added to the gateway as a
+// virtual client, so the messages to the bot are delivered like to other users, and the messages of the bot are
+// handled by the same message handler as messages of clients.
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/glide-im/glide/pkg/subscription/subscription_impl"
+)
+
+var log = logger.Named("bot")
+
+const defaultQueueSize = 100
+
+const (
+	errNoHandler      = "bot handler is nil"
+	errNoSend         = "bot send handler is nil"
+	errBotClosed      = "bot is closed"
+	errQueueFull      = "bot message queue is full"
+	errNoSubscription = "bot subscription is not set"
+	errNotChat        = "not a chat message"
+)
+
+var (
+	// ErrBotClosed returned when the message is sent to or by the bot exited.
+	ErrBotClosed = errors.New(errBotClosed)
+	// ErrQueueFull returned by EnqueueMessage when the bot handles messages slower than received.
+	ErrQueueFull = errors.New(errQueueFull)
+)
+
+var _ gate.Client = (*Bot)(nil)
+
+// Handler handles messages received by the bot, messages of a bot are handled in the order received.
+type Handler interface {
+	HandleMessage(b *Bot, m *messages.GlideMessage) error
+}
+
+// HandlerFunc adapts the function to Handler.
+type HandlerFunc func(b *Bot, m *messages.GlideMessage) error
+
+func (f HandlerFunc) HandleMessage(b *Bot, m *messages.GlideMessage) error {
+	return f(b, m)
+}
+
+// Options of Bot.
+type Options struct {
+	// Handler handles the chat and group messages received by the bot, the messages sent by the bot itself are
+	// ignored.
+	Handler Handler
+	// Send handles the messages sent by the bot, usually the message handler of the gateway.
+	Send gate.MessageHandler
+	// Subscription used by Join and Leave, optional.
+	Subscription subscription_impl.SubscribeWrap
+	// QueueSize the max count of messages waiting to be handled, default 100.
+	QueueSize int
+}
+
+// Bot the virtual client of the bot user, add it to the gateway by gate.Gateway.AddClient and run it by Run.
+type Bot struct {
+	uid  string
+	opts Options
+	seq  int64
+
+	mu      sync.Mutex
+	info    gate.Info
+	running bool
+	queue   chan *messages.GlideMessage
+	done    chan struct{}
+}
+
+// New creates the bot of uid.
+func New(uid string, opts *Options) (*Bot, error) {
+	if opts == nil || opts.Handler == nil {
+		return nil, errors.New(errNoHandler)
+	}
+	if opts.Send == nil {
+		return nil, errors.New(errNoSend)
+	}
+	o := *opts
+	if o.QueueSize <= 0 {
+		o.QueueSize = defaultQueueSize
+	}
+	now := time.Now().UnixMilli()
+	return &Bot{
+		uid:  uid,
+		opts: o,
+		info: gate.Info{
+			ID:           gate.NewID2(uid),
+			Protocol:     messages.ProtocolCurrent,
+			AliveAt:      now,
+			ConnectionAt: now,
+		},
+		running: true,
+		queue:   make(chan *messages.GlideMessage, o.QueueSize),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// UID returns the uid of the bot.
+func (b *Bot) UID() string {
+	return b.uid
+}
+
+func (b *Bot) SetID(id gate.ID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.info.ID = id
+}
+
+func (b *Bot) IsRunning() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.running
+}
+
+// EnqueueMessage enqueues the message to be handled by the Handler, ErrQueueFull is returned if the queue is full.
+func (b *Bot) EnqueueMessage(message *messages.GlideMessage) error {
+	if !b.IsRunning() {
+		return ErrBotClosed
+	}
+	select {
+	case b.queue <- message:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (b *Bot) Exit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.running {
+		return
+	}
+	b.running = false
+	close(b.done)
+}
+
+// Run handles messages received until the bot exited.
+func (b *Bot) Run() {
+	for {
+		select {
+		case <-b.done:
+			return
+		case m := <-b.queue:
+			b.handle(m)
+		}
+	}
+}
+
+func (b *Bot) GetInfo() gate.Info {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.info.AliveAt = time.Now().UnixMilli()
+	return b.info
+}
+
+func (b *Bot) handle(m *messages.GlideMessage) {
+	switch m.GetAction().Group() {
+	case messages.GroupChat, messages.GroupChannel:
+	default:
+		return
+	}
+	if m.From == b.uid {
+		return
+	}
+	if err := b.opts.Handler.HandleMessage(b, m); err != nil {
+		log.E("bot %s handle message %s error: %v", b.uid, m.GetAction(), err)
+	}
+}
+
+// Send sends the chat message to the user to.
+func (b *Bot) Send(to string, c *messages.ChatMessage) error {
+	return b.send(messages.ActionChatMessage, to, c)
+}
+
+// SendGroup sends the chat message to the channel, the bot must be a member of the channel, see Join.
+func (b *Bot) SendGroup(channel string, c *messages.ChatMessage) error {
+	return b.send(messages.ActionGroupMessage, channel, c)
+}
+
+// Reply replies the text content to the message received, the reply of a group message is sent to the channel.
+func (b *Bot) Reply(m *messages.GlideMessage, content string) error {
+	return b.ReplyMessage(m, &messages.ChatMessage{Type: messages.MessageTypeText, Content: content})
+}
+
+// ReplyMessage replies the chat message to the message received, the reply of a group message is sent to the channel.
+func (b *Bot) ReplyMessage(m *messages.GlideMessage, c *messages.ChatMessage) error {
+	switch m.GetAction().Group() {
+	case messages.GroupChannel:
+		return b.SendGroup(m.To, c)
+	case messages.GroupChat:
+		return b.Send(m.From, c)
+	}
+	return errors.New(errNotChat)
+}
+
+// Join subscribes the bot to the channel with the permission to read and write.
+func (b *Bot) Join(channel string) error {
+	if b.opts.Subscription == nil {
+		return errors.New(errNoSubscription)
+	}
+	return b.opts.Subscription.Subscribe(subscription.ChanID(channel), subscription.SubscriberID(b.uid),
+		&subscription_impl.SubscriberOptions{Perm: subscription_impl.PermRead | subscription_impl.PermWrite})
+}
+
+// Leave unsubscribes the bot from the channel.
+func (b *Bot) Leave(channel string) error {
+	if b.opts.Subscription == nil {
+		return errors.New(errNoSubscription)
+	}
+	return b.opts.Subscription.UnSubscribe(subscription.ChanID(channel), subscription.SubscriberID(b.uid))
+}
+
+func (b *Bot) send(action messages.Action, to string, c *messages.ChatMessage) error {
+	if !b.IsRunning() {
+		return ErrBotClosed
+	}
+	cm := *c
+	cm.From = b.uid
+	cm.To = to
+	if cm.SendAt == 0 {
+		cm.SendAt = time.Now().Unix()
+	}
+	m := messages.NewMessage(atomic.AddInt64(&b.seq, 1), action, &cm)
+	m.From = b.uid
+	m.To = to
+	info := b.GetInfo()
+	b.opts.Send(&info, m)
+	return nil
+}
+
+// Rich returns the chat message of type with the content encoded in JSON, such as cards and buttons defined by the
+// application.
+func Rich(typ int32, content interface{}) (*messages.ChatMessage, error) {
+	bytes, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return &messages.ChatMessage{Type: typ, Content: string(bytes)}, nil
+}
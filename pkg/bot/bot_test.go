@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+type sent struct {
+	mu   sync.Mutex
+	info []gate.Info
+	msgs []*messages.GlideMessage
+}
+
+func (s *sent) handle(info *gate.Info, m *messages.GlideMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info = append(s.info, *info)
+	s.msgs = append(s.msgs, m)
+}
+
+func (s *sent) messages() []*messages.GlideMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*messages.GlideMessage{}, s.msgs...)
+}
+
+func chatFrom(from string, to string, action messages.Action, content string) *messages.GlideMessage {
+	m := messages.NewMessage(1, action, &messages.ChatMessage{From: from, To: to, Content: content})
+	m.From = from
+	m.To = to
+	return m
+}
+
+func TestBot_Reply(t *testing.T) {
+	s := &sent{}
+	b, err := New("bot", &Options{
+		Handler: HandlerFunc(func(b *Bot, m *messages.GlideMessage) error {
+			return b.Reply(m, "pong")
+		}),
+		Send: s.handle,
+	})
+	assert.NoError(t, err)
+	go b.Run()
+	defer b.Exit()
+
+	assert.NoError(t, b.EnqueueMessage(chatFrom("u1", "bot", messages.ActionChatMessage, "ping")))
+	assert.NoError(t, b.EnqueueMessage(chatFrom("u1", "g1", messages.ActionGroupMessage, "ping")))
+	// the message sent by the bot and not chat messages are ignored.
+	assert.NoError(t, b.EnqueueMessage(chatFrom("bot", "g1", messages.ActionGroupMessage, "pong")))
+	assert.NoError(t, b.EnqueueMessage(messages.NewMessage(1, messages.ActionAckMessage, nil)))
+
+	assert.Eventually(t, func() bool { return len(s.messages()) == 2 }, time.Second, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 20)
+	got := s.messages()
+	assert.Len(t, got, 2)
+	assert.Equal(t, messages.ActionChatMessage, got[0].GetAction())
+	assert.Equal(t, "u1", got[0].To)
+	assert.Equal(t, messages.ActionGroupMessage, got[1].GetAction())
+	assert.Equal(t, "g1", got[1].To)
+	cm := got[1].Data.GetData().(*messages.ChatMessage)
+	assert.Equal(t, "bot", cm.From)
+	assert.Equal(t, "pong", cm.Content)
+	assert.Equal(t, "bot", s.info[0].ID.UID())
+
+	b.Exit()
+	assert.ErrorIs(t, b.EnqueueMessage(chatFrom("u1", "bot", messages.ActionChatMessage, "ping")), ErrBotClosed)
+	assert.ErrorIs(t, b.Send("u1", &messages.ChatMessage{}), ErrBotClosed)
+}
+
+func TestRich(t *testing.T) {
+	c, err := Rich(10, map[string]string{"title": "card"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(10), c.Type)
+	assert.JSONEq(t, `{"title":"card"}`, c.Content)
+}
+
+func TestNewWebhook(t *testing.T) {
+	var event WebhookEvent
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(webhook.HeaderSignature)
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		_ = json.NewEncoder(w).Encode(&WebhookResponse{Replies: []*messages.ChatMessage{{Content: "hi"}}})
+	}))
+	defer srv.Close()
+
+	s := &sent{}
+	b, err := New("bot", &Options{Handler: NewWebhook(&WebhookOptions{URL: srv.URL, Secret: "secret"}), Send: s.handle})
+	assert.NoError(t, err)
+
+	b.handle(chatFrom("u1", "bot", messages.ActionChatMessage, "hello"))
+	assert.Equal(t, "bot", event.Bot)
+	assert.Equal(t, "hello", event.Message.Content)
+	assert.NotEmpty(t, signature)
+	got := s.messages()
+	assert.Len(t, got, 1)
+	assert.Equal(t, "u1", got[0].To)
+	assert.Equal(t, "hi", got[0].Data.GetData().(*messages.ChatMessage).Content)
+}
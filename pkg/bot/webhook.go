@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/webhook"
+)
+
+const defaultWebhookTimeout = time.Second * 5
+
+// EventBotMessage the event of the message received by the bot posted to the webhook.
+const EventBotMessage = "bot.message"
+
+// WebhookEvent the body posted to the webhook, signed as webhook events, see webhook.Sign.
+type WebhookEvent struct {
+	Bot     string                `json:"bot"`
+	Action  string                `json:"action"`
+	Message *messages.ChatMessage `json:"message"`
+}
+
+// WebhookResponse the optional response of the webhook, the replies are sent to the conversation of the message.
+type WebhookResponse struct {
+	Replies []*messages.ChatMessage `json:"replies,omitempty"`
+}
+
+// WebhookOptions of the webhook Handler.
+type WebhookOptions struct {
+	URL string
+	// Secret signs the events posted, empty to not sign.
+	Secret string
+	// Timeout the http request timeout, default 5s.
+	Timeout time.Duration
+}
+
+type webhookHandler struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhook returns the Handler posts messages received by the bot to the webhook url, and replies the messages of the
+// response.
+func NewWebhook(opts *WebhookOptions) Handler {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &webhookHandler{
+		url:    opts.URL,
+		secret: []byte(opts.Secret),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (w *webhookHandler) HandleMessage(b *Bot, m *messages.GlideMessage) error {
+	cm := new(messages.ChatMessage)
+	if err := m.Data.Deserialize(cm); err != nil {
+		return err
+	}
+	body, err := json.Marshal(&WebhookEvent{Bot: b.UID(), Action: m.Action, Message: cm})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.HeaderEvent, EventBotMessage)
+	req.Header.Set(webhook.HeaderTimestamp, ts)
+	if len(w.secret) > 0 {
+		req.Header.Set(webhook.HeaderSignature, "sha256="+webhook.Sign(w.secret, ts, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	r := WebhookResponse{}
+	// the response without body replies nothing.
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil && err != io.EOF {
+		return err
+	}
+	for _, reply := range r.Replies {
+		if err = b.ReplyMessage(m, reply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package broadcast
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var log = logger.Named("broadcast")
+
+const (
+	defaultRate      = 5000
+	defaultQueueSize = 16
+	maxRecentTasks   = 64
+	tickInterval     = time.Millisecond * 10
+)
+
+var (
+	ErrQueueFull = errors.New("too many broadcasts in queue")
+	ErrClosed    = errors.New("broadcaster closed")
+)
+
+// Gateway the gateway clients are broadcast in.
+type Gateway interface {
+	GetAll() map[gate.ID]gate.Info
+
+	EnqueueMessage(id gate.ID, message *messages.GlideMessage) error
+}
+
+// TagResolver returns tags of the user, used to segment broadcast by user tags.
+type TagResolver interface {
+	Tags(uid string) []string
+}
+
+// Segment selects the clients a broadcast is delivered to, the client must match all non-empty conditions, the empty
+// Segment selects all online clients.
+type Segment struct {
+	// Devices the device types of client.
+	Devices []string `json:"devices,omitempty"`
+	// Gateways the gateway names the client is connected to.
+	Gateways []string `json:"gateways,omitempty"`
+	// Tags the user tags, the user must have all the tags.
+	Tags []string `json:"tags,omitempty"`
+	// Authenticated true to skip temporary clients not authenticated.
+	Authenticated bool `json:"authenticated,omitempty"`
+	// Selector the expression selects clients by attributes, see gate.Selector.
+	Selector string `json:"selector,omitempty"`
+}
+
+// TaskStatus the progress of a broadcast.
+type TaskStatus struct {
+	ID        string `json:"id"`
+	Targets   int64  `json:"targets"`
+	Delivered int64  `json:"delivered"`
+	Failed    int64  `json:"failed"`
+	Done      bool   `json:"done"`
+	CreateAt  int64  `json:"create_at"`
+}
+
+// Task a broadcast queued or delivering.
+type Task struct {
+	id       string
+	message  *messages.GlideMessage
+	segment  Segment
+	selector *gate.Selector
+	createAt int64
+
+	targets   int64
+	delivered int64
+	failed    int64
+	done      chan struct{}
+}
+
+// ID returns the id of task.
+func (t *Task) ID() string {
+	return t.id
+}
+
+// Wait blocks until all targets are delivered.
+func (t *Task) Wait() {
+	<-t.done
+}
+
+// Status returns the progress of task.
+func (t *Task) Status() TaskStatus {
+	s := TaskStatus{
+		ID:        t.id,
+		Targets:   atomic.LoadInt64(&t.targets),
+		Delivered: atomic.LoadInt64(&t.delivered),
+		Failed:    atomic.LoadInt64(&t.failed),
+		CreateAt:  t.createAt,
+	}
+	select {
+	case <-t.done:
+		s.Done = true
+	default:
+	}
+	return s
+}
+
+type Options struct {
+	// Rate the max count of messages enqueued per second, default 5000, broadcasts are delivered one by one so the
+	// rate is shared by all broadcasts.
+	Rate int
+	// QueueSize the max count of broadcasts waiting to deliver, default 16.
+	QueueSize int
+	// Tags resolves user tags to segment by tags, Segment.Tags selects nothing if nil.
+	Tags TagResolver
+}
+
+// Broadcaster delivers messages to all online clients matching the segment, the delivery is throttled to avoid
+// flooding the write queue of clients and the network at once.
+type Broadcaster struct {
+	gateway Gateway
+	rate    int
+	tags    TagResolver
+	queue   chan *Task
+
+	mu     sync.Mutex
+	recent []*Task
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewBroadcaster creates the Broadcaster and starts delivery.
+func NewBroadcaster(gateway Gateway, opts *Options) *Broadcaster {
+	b := &Broadcaster{
+		gateway: gateway,
+		rate:    opts.Rate,
+		tags:    opts.Tags,
+		closed:  make(chan struct{}),
+	}
+	if b.rate <= 0 {
+		b.rate = defaultRate
+	}
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	b.queue = make(chan *Task, queueSize)
+	go b.run()
+	return b
+}
+
+// Broadcast queues the message to deliver to clients matching the segment, nil segment to all clients.
+func (b *Broadcaster) Broadcast(m *messages.GlideMessage, segment *Segment) (*Task, error) {
+	if segment == nil {
+		segment = &Segment{}
+	}
+	selector, err := gate.ParseSelector(segment.Selector)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	t := &Task{
+		id:       hex.EncodeToString(id),
+		message:  messages.Serialize(m),
+		segment:  *segment,
+		selector: selector,
+		createAt: time.Now().Unix(),
+		done:     make(chan struct{}),
+	}
+
+	select {
+	case <-b.closed:
+		return nil, ErrClosed
+	default:
+	}
+	select {
+	case b.queue <- t:
+	default:
+		return nil, ErrQueueFull
+	}
+
+	b.mu.Lock()
+	b.recent = append(b.recent, t)
+	if len(b.recent) > maxRecentTasks {
+		b.recent = b.recent[len(b.recent)-maxRecentTasks:]
+	}
+	b.mu.Unlock()
+	return t, nil
+}
+
+// Task returns the recent task by id.
+func (b *Broadcaster) Task(id string) (*Task, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, t := range b.recent {
+		if t.id == id {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Close stops delivery, the task delivering is interrupted.
+func (b *Broadcaster) Close() {
+	b.once.Do(func() {
+		close(b.closed)
+	})
+}
+
+func (b *Broadcaster) run() {
+	for {
+		select {
+		case <-b.closed:
+			return
+		case t := <-b.queue:
+			b.deliver(t)
+		}
+	}
+}
+
+func (b *Broadcaster) deliver(t *Task) {
+	defer close(t.done)
+
+	targets := b.selectTargets(&t.segment, t.selector)
+	atomic.StoreInt64(&t.targets, int64(len(targets)))
+
+	batch := b.rate * int(tickInterval) / int(time.Second)
+	if batch <= 0 {
+		batch = 1
+	}
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for i, id := range targets {
+		if i > 0 && i%batch == 0 {
+			select {
+			case <-b.closed:
+				return
+			case <-ticker.C:
+			}
+		}
+		if b.gateway.EnqueueMessage(id, t.message) == nil {
+			atomic.AddInt64(&t.delivered, 1)
+		} else {
+			atomic.AddInt64(&t.failed, 1)
+		}
+	}
+	log.I("broadcast %s delivered to %d clients, failed %d", t.id, t.delivered, t.failed)
+}
+
+func (b *Broadcaster) selectTargets(s *Segment, selector *gate.Selector) []gate.ID {
+	var targets []gate.ID
+	tags := map[string]bool{}
+	for id, info := range b.gateway.GetAll() {
+		if s.Authenticated && id.IsTemp() {
+			continue
+		}
+		if len(s.Devices) > 0 && !contains(s.Devices, id.Device()) {
+			continue
+		}
+		if len(s.Gateways) > 0 && !contains(s.Gateways, id.Gateway()) {
+			continue
+		}
+		if !selector.Empty() && !selector.Match(info.Attributes) {
+			continue
+		}
+		if len(s.Tags) > 0 {
+			uid := id.UID()
+			matched, ok := tags[uid]
+			if !ok {
+				matched = b.hasTags(uid, s.Tags)
+				tags[uid] = matched
+			}
+			if !matched {
+				continue
+			}
+		}
+		targets = append(targets, id)
+	}
+	return targets
+}
+
+func (b *Broadcaster) hasTags(uid string, required []string) bool {
+	if b.tags == nil || uid == "" {
+		return false
+	}
+	tags := b.tags.Tags(uid)
+	for _, t := range required {
+		if !contains(tags, t) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(s []string, v string) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
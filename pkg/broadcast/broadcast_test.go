@@ -0,0 +1,106 @@
+package broadcast
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockGateway struct {
+	mu       sync.Mutex
+	clients  map[gate.ID]gate.Info
+	enqueued []gate.ID
+}
+
+func newMockGateway(ids ...gate.ID) *mockGateway {
+	m := &mockGateway{clients: map[gate.ID]gate.Info{}}
+	for _, id := range ids {
+		m.clients[id] = gate.Info{ID: id}
+	}
+	return m
+}
+
+func (m *mockGateway) GetAll() map[gate.ID]gate.Info {
+	return m.clients
+}
+
+func (m *mockGateway) EnqueueMessage(id gate.ID, message *messages.GlideMessage) error {
+	if id.UID() == "fail" {
+		return errors.New("client does not exist")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enqueued = append(m.enqueued, id)
+	return nil
+}
+
+type mockTags map[string][]string
+
+func (m mockTags) Tags(uid string) []string {
+	return m[uid]
+}
+
+func TestBroadcaster_Segment(t *testing.T) {
+	g := newMockGateway(
+		gate.NewID("gw1", "1", "1"),
+		gate.NewID("gw1", "1", "2"),
+		gate.NewID("gw2", "2", "1"),
+		gate.NewID("gw1", "fail", "1"),
+		gate.NewID2("tmp@1"),
+	)
+	g.clients[gate.NewID("gw2", "2", "1")] = gate.Info{Attributes: map[string]string{"region": "eu", "app_version": "3.2"}}
+	b := NewBroadcaster(g, &Options{Tags: mockTags{"1": {"vip", "eu"}, "2": {"eu"}}})
+	defer b.Close()
+
+	_, err := b.Broadcast(messages.NewMessage(0, messages.ActionNotifySystem, "hi"), &Segment{Selector: "region"})
+	assert.Error(t, err)
+
+	cases := []struct {
+		segment   *Segment
+		targets   int64
+		delivered int64
+	}{
+		{nil, 5, 4},
+		{&Segment{Devices: []string{"1"}}, 3, 2},
+		{&Segment{Gateways: []string{"gw2"}}, 1, 1},
+		{&Segment{Tags: []string{"eu"}}, 3, 3},
+		{&Segment{Tags: []string{"eu", "vip"}}, 2, 2},
+		{&Segment{Authenticated: true}, 4, 3},
+		{&Segment{Selector: "region=eu and app_version>=3.1"}, 1, 1},
+	}
+	for _, c := range cases {
+		task, err := b.Broadcast(messages.NewMessage(0, messages.ActionNotifySystem, "hi"), c.segment)
+		assert.NoError(t, err)
+		task.Wait()
+		status := task.Status()
+		assert.True(t, status.Done)
+		assert.Equal(t, c.targets, status.Targets)
+		assert.Equal(t, c.delivered, status.Delivered)
+		assert.Equal(t, c.targets-c.delivered, status.Failed)
+
+		found, ok := b.Task(task.ID())
+		assert.True(t, ok)
+		assert.Equal(t, task, found)
+	}
+}
+
+func TestBroadcaster_Throttle(t *testing.T) {
+	var ids []gate.ID
+	for i := 0; i < 50; i++ {
+		ids = append(ids, gate.NewID2(string(rune('a'+i))))
+	}
+	b := NewBroadcaster(newMockGateway(ids...), &Options{Rate: 1000})
+	defer b.Close()
+
+	start := time.Now()
+	task, err := b.Broadcast(messages.NewMessage(0, messages.ActionNotifySystem, "hi"), nil)
+	assert.NoError(t, err)
+	task.Wait()
+	// 10 messages per 10ms
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*40)
+	assert.Equal(t, int64(50), task.Status().Delivered)
+}
@@ -0,0 +1,193 @@
+// Package chaos injects faults into connections and stores of the gateway, it's for resilience tests only and must not
+// be used in production, see cmd/soak.
+package chaos
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/glide-im/glide/pkg/conn"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+)
+
+var log = logger.Named("chaos")
+
+const errInjected = "chaos: injected fault"
+
+// ErrInjected the error returned by the store operation failed by injection.
+var ErrInjected = errors.New(errInjected)
+
+// Faults the probability and latency of faults injected, zero value injects nothing.
+type Faults struct {
+	// DropRate the probability of closing the connection on each read or write.
+	DropRate float64
+	// CorruptRate the probability of flipping a random byte of the frame read or written.
+	CorruptRate float64
+	// WriteDelay the max random delay before each write of connections.
+	WriteDelay time.Duration
+	// StoreDelay the max random delay before each store operation.
+	StoreDelay time.Duration
+	// StoreErrorRate the probability of failing the store operation with ErrInjected.
+	StoreErrorRate float64
+	// Seed the seed of random source, 0 to use the current time.
+	Seed int64
+}
+
+// Stats the count of faults injected.
+type Stats struct {
+	Drops       int64
+	Corruptions int64
+	Delays      int64
+	StoreErrors int64
+}
+
+// Injector wraps connections and stores to inject Faults, it's safe for concurrent use.
+type Injector struct {
+	faults Faults
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+
+	drops       int64
+	corruptions int64
+	delays      int64
+	storeErrors int64
+}
+
+func NewInjector(faults Faults) *Injector {
+	seed := faults.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{
+		faults: faults,
+		rnd:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Stats returns the count of faults injected so far.
+func (i *Injector) Stats() Stats {
+	return Stats{
+		Drops:       atomic.LoadInt64(&i.drops),
+		Corruptions: atomic.LoadInt64(&i.corruptions),
+		Delays:      atomic.LoadInt64(&i.delays),
+		StoreErrors: atomic.LoadInt64(&i.storeErrors),
+	}
+}
+
+// WrapConnection returns the connection injects faults to reads and writes, the conn.PolledConnection is wrapped as a
+// plain connection, the gateway reads it with a goroutine.
+func (i *Injector) WrapConnection(c conn.Connection) conn.Connection {
+	return &faultyConn{Connection: c, i: i}
+}
+
+// WrapStore returns the store injects latency and errors to store operations, the optional interfaces of the store
+// are available by store.Unwrap.
+func (i *Injector) WrapStore(s store.MessageStore) store.MessageStore {
+	return &faultyStore{store: s, i: i}
+}
+
+func (i *Injector) hit(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rnd.Float64() < rate
+}
+
+func (i *Injector) intn(n int) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rnd.Intn(n)
+}
+
+func (i *Injector) delay(max time.Duration) {
+	if max <= 0 {
+		return
+	}
+	atomic.AddInt64(&i.delays, 1)
+	time.Sleep(time.Duration(i.intn(int(max)) + 1))
+}
+
+func (i *Injector) corrupt(data []byte) []byte {
+	if len(data) == 0 || !i.hit(i.faults.CorruptRate) {
+		return data
+	}
+	atomic.AddInt64(&i.corruptions, 1)
+	c := make([]byte, len(data))
+	copy(c, data)
+	c[i.intn(len(c))] ^= 0xFF
+	return c
+}
+
+type faultyConn struct {
+	conn.Connection
+	i *Injector
+}
+
+func (f *faultyConn) drop() bool {
+	if !f.i.hit(f.i.faults.DropRate) {
+		return false
+	}
+	atomic.AddInt64(&f.i.drops, 1)
+	log.D("drop connection %s", f.GetConnInfo().Addr)
+	_ = f.Connection.Close()
+	return true
+}
+
+func (f *faultyConn) Write(data []byte) error {
+	f.i.delay(f.i.faults.WriteDelay)
+	if f.drop() {
+		return conn.ErrConnectionClosed
+	}
+	return f.Connection.Write(f.i.corrupt(data))
+}
+
+func (f *faultyConn) Read() ([]byte, error) {
+	data, err := f.Connection.Read()
+	if err != nil {
+		return data, err
+	}
+	if f.drop() {
+		return nil, conn.ErrConnectionClosed
+	}
+	return f.i.corrupt(data), nil
+}
+
+type faultyStore struct {
+	store store.MessageStore
+	i     *Injector
+}
+
+func (f *faultyStore) Unwrap() store.MessageStore {
+	return f.store
+}
+
+func (f *faultyStore) fault() error {
+	f.i.delay(f.i.faults.StoreDelay)
+	if f.i.hit(f.i.faults.StoreErrorRate) {
+		atomic.AddInt64(&f.i.storeErrors, 1)
+		return ErrInjected
+	}
+	return nil
+}
+
+func (f *faultyStore) StoreMessage(message *messages.ChatMessage) error {
+	if err := f.fault(); err != nil {
+		return err
+	}
+	return f.store.StoreMessage(message)
+}
+
+func (f *faultyStore) StoreOffline(message *messages.ChatMessage) error {
+	if err := f.fault(); err != nil {
+		return err
+	}
+	return f.store.StoreOffline(message)
+}
@@ -0,0 +1,78 @@
+package chaos
+
+import (
+	"testing"
+
+	"github.com/glide-im/glide/pkg/conn"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockConn struct {
+	written [][]byte
+	closed  bool
+}
+
+func (m *mockConn) Write(data []byte) error {
+	m.written = append(m.written, data)
+	return nil
+}
+
+func (m *mockConn) Read() ([]byte, error) {
+	return []byte("hello"), nil
+}
+
+func (m *mockConn) Close() error {
+	m.closed = true
+	return nil
+}
+
+func (m *mockConn) GetConnInfo() *conn.ConnectionInfo {
+	return &conn.ConnectionInfo{Addr: "mock"}
+}
+
+func TestInjector_WrapConnection(t *testing.T) {
+	i := NewInjector(Faults{})
+	m := &mockConn{}
+	c := i.WrapConnection(m)
+	assert.NoError(t, c.Write([]byte("hi")))
+	data, err := c.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), data)
+	assert.Equal(t, Stats{}, i.Stats())
+
+	i = NewInjector(Faults{CorruptRate: 1, Seed: 1})
+	m = &mockConn{}
+	c = i.WrapConnection(m)
+	frame := []byte("hi")
+	assert.NoError(t, c.Write(frame))
+	assert.Equal(t, []byte("hi"), frame)
+	assert.NotEqual(t, frame, m.written[0])
+	assert.Equal(t, int64(1), i.Stats().Corruptions)
+
+	i = NewInjector(Faults{DropRate: 1, Seed: 1})
+	m = &mockConn{}
+	c = i.WrapConnection(m)
+	assert.ErrorIs(t, c.Write([]byte("hi")), conn.ErrConnectionClosed)
+	_, err = c.Read()
+	assert.ErrorIs(t, err, conn.ErrConnectionClosed)
+	assert.True(t, m.closed)
+	assert.Empty(t, m.written)
+	assert.Equal(t, int64(2), i.Stats().Drops)
+}
+
+func TestInjector_WrapStore(t *testing.T) {
+	inner := &store.IdleMessageStore{}
+	i := NewInjector(Faults{StoreErrorRate: 1, Seed: 1})
+	s := i.WrapStore(inner)
+	assert.ErrorIs(t, s.StoreMessage(&messages.ChatMessage{}), ErrInjected)
+	assert.ErrorIs(t, s.StoreOffline(&messages.ChatMessage{}), ErrInjected)
+	assert.Equal(t, int64(2), i.Stats().StoreErrors)
+	assert.Same(t, inner, store.Unwrap(s))
+
+	i = NewInjector(Faults{StoreDelay: 1})
+	s = i.WrapStore(inner)
+	assert.NoError(t, s.StoreMessage(&messages.ChatMessage{}))
+	assert.Equal(t, int64(1), i.Stats().Delays)
+}
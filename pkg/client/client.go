@@ -0,0 +1,695 @@
+// Package client is the Go client of glide gateway, it connects and authenticates to the gateway, keeps the heartbeat,
+// reconnects with exponential backoff when the connection is lost, tracks the ack of messages sent, and delivers the
+// messages received to handlers. The session is resumed after reconnect if the gateway enables resume, messages to the
+// client when disconnected are replayed.
+//
+//	c, _ := client.New(&client.Options{
+//		URL:        "ws://127.0.0.1:8083/ws",
+//		Credential: func() (*gate.EncryptedCredential, error) { return fetchCredential() },
+//	})
+//	c.OnChatMessage(func(action messages.Action, m *messages.ChatMessage) {})
+//	_ = c.Connect(context.Background())
+//	ack, err := c.SendChat(ctx, "2", &messages.ChatMessage{Content: "hi"}, ticket)
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/glide-im/glide/pkg/conn"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/gorilla/websocket"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var log = logger.Named("client")
+
+var (
+	// ErrClosed the client is closed by Close or kicked out.
+	ErrClosed = errors.New("client closed")
+	// ErrNotConnected the message is sent while the client is not connected.
+	ErrNotConnected = errors.New("not connected")
+	// ErrKickedOut the user logged in at another device with the same device id, the client is closed.
+	ErrKickedOut = errors.New("kicked out")
+	// ErrChallengeRequired the gateway requires a challenge solved before authenticate, see Options.Challenge.
+	ErrChallengeRequired = errors.New("challenge required")
+)
+
+// AuthError the gateway rejects the authenticate.
+type AuthError struct {
+	Reason string
+	// Code the code of the error, see messages.ErrorCode.
+	Code int
+}
+
+func (a *AuthError) Error() string {
+	return "authenticate failed: " + a.Reason
+}
+
+// BusyError the gateway rejects the connection as it reaches quotas, the client reconnects to RetryAt if present.
+type BusyError struct {
+	*messages.ServerBusy
+}
+
+func (b *BusyError) Error() string {
+	return fmt.Sprintf("server busy: %s, retry at '%s' after %ds", b.Reason, b.RetryAt, b.RetryAfter)
+}
+
+// State the connection state of the client.
+type State int
+
+const (
+	StateConnecting State = iota + 1
+	StateConnected
+	StateDisconnected
+	StateClosed
+)
+
+const (
+	defaultRequestTimeout    = time.Second * 10
+	defaultHeartbeatInterval = time.Second * 30
+	defaultMinBackoff        = time.Second
+	defaultMaxBackoff        = time.Minute
+	// heartbeatLostLimit the connection is considered dead if nothing is read in the count of heartbeat interval.
+	heartbeatLostLimit = 3
+	// chunkOverhead the max size of a chunk message except the data.
+	chunkOverhead = 256
+)
+
+// Options of Client.
+type Options struct {
+	// URL the websocket address of the gateway, such as ws://127.0.0.1:8083/ws.
+	URL string
+	// Credential returns the credential encrypted by the business service to authenticate, it's called on each
+	// connect as the credential expires, nil to connect as a guest without authenticate.
+	Credential func() (*gate.EncryptedCredential, error)
+	// Capabilities the capabilities declared on authenticate, such as messages.CapabilityReactions, nil to declare
+	// nothing, which is treated as supporting all capabilities by the gateway.
+	Capabilities []string
+	// Hello sent to the gateway once connected, optional, the Protocol is ProtocolCurrent if it's not set.
+	Hello *messages.Hello
+	// Challenge answers the anti-abuse challenge, the client waits for the challenge before authenticate if it's
+	// set, see PoWSolver.
+	Challenge func(c *messages.Challenge) (*messages.ChallengeAnswer, error)
+	// Dialer dials the gateway, default websocket.DefaultDialer requesting the conn.SubprotocolJsonV1.
+	Dialer *websocket.Dialer
+	// RequestTimeout the timeout of handshake, requests and ack of messages sent, default 10 seconds.
+	RequestTimeout time.Duration
+	// MinBackoff and MaxBackoff the range of exponential backoff of reconnect, default 1 second and 1 minute, they
+	// are overridden by the messages.RemoteConfig pushed by the gateway.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// DisableAutoAck true to not ack chat messages received, the handler should call Ack.
+	DisableAutoAck bool
+	// MaxMessageSize the max message size of the gateway, the larger message is sent in chunks, 0 to not split.
+	MaxMessageSize int
+}
+
+// Handler handles the message received.
+type Handler func(m *messages.GlideMessage)
+
+// pendingChat the chat message waiting for the ack of server.
+type pendingChat struct {
+	m  *messages.GlideMessage
+	ch chan *messages.AckMessage
+}
+
+// Client the client of glide gateway, it's safe for concurrent use.
+type Client struct {
+	opts      Options
+	requester *messages.Requester
+	midPrefix string
+	midSeq    int64
+
+	mu    sync.Mutex
+	conn  *websocket.Conn
+	hello *messages.ServerHello
+	// resumeToken the token to resume the session after reconnect, empty if the gateway disables resume.
+	resumeToken string
+	// syncState the state of the last sync done, see Sync.
+	syncState messages.SyncPhase
+	// remoteConfig the config pushed by the gateway, see messages.RemoteConfig.
+	remoteConfig *messages.RemoteConfig
+	state        State
+	closed       bool
+	closeCh      chan struct{}
+
+	writeMu sync.Mutex
+
+	handlersMu    sync.RWMutex
+	handlers      map[messages.Action][]Handler
+	stateHandlers []func(s State, err error)
+
+	pendingMu sync.Mutex
+	pending   map[string]*pendingChat
+}
+
+func New(opts *Options) (*Client, error) {
+	if opts.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	c := &Client{
+		opts:      *opts,
+		midPrefix: strconv.FormatInt(time.Now().UnixNano(), 36) + strconv.FormatInt(rand.Int63(), 36),
+		closeCh:   make(chan struct{}),
+		handlers:  map[messages.Action][]Handler{},
+		pending:   map[string]*pendingChat{},
+	}
+	if c.opts.Dialer == nil {
+		d := *websocket.DefaultDialer
+		d.Subprotocols = []string{conn.SubprotocolJsonV1}
+		c.opts.Dialer = &d
+	}
+	if c.opts.RequestTimeout <= 0 {
+		c.opts.RequestTimeout = defaultRequestTimeout
+	}
+	if c.opts.MinBackoff <= 0 {
+		c.opts.MinBackoff = defaultMinBackoff
+	}
+	if c.opts.MaxBackoff < c.opts.MinBackoff {
+		c.opts.MaxBackoff = defaultMaxBackoff
+	}
+	c.requester = messages.NewRequester(c.Send, c.opts.RequestTimeout)
+	return c, nil
+}
+
+// Connect connects and authenticates to the gateway, the client reconnects in background once connected until Close.
+func (c *Client) Connect(ctx context.Context) error {
+	conn, err := c.connect(ctx, c.opts.URL)
+	if err != nil {
+		c.setState(StateDisconnected, err)
+		return err
+	}
+	go c.serve(conn)
+	return nil
+}
+
+// Close closes the connection and stops reconnecting, messages waiting for ack fail with ErrClosed.
+func (c *Client) Close() error {
+	return c.close(ErrClosed)
+}
+
+// ServerHello returns the hello of the gateway of the current connection.
+func (c *Client) ServerHello() *messages.ServerHello {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hello
+}
+
+// RemoteConfig returns the config pushed by the gateway, nil if not pushed, register the handler of
+// messages.ActionNotifyConfig to be notified when it's changed.
+func (c *Client) RemoteConfig() *messages.RemoteConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.remoteConfig
+}
+
+// State returns the connection state.
+func (c *Client) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Handle adds the handler of messages of action, the handler of empty action receives all messages. Handlers run in
+// the read loop, they should not block.
+func (c *Client) Handle(action messages.Action, h Handler) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.handlers[action] = append(c.handlers[action], h)
+}
+
+// OnChatMessage adds the handler of chat and group messages received.
+func (c *Client) OnChatMessage(fn func(action messages.Action, m *messages.ChatMessage)) {
+	h := func(m *messages.GlideMessage) {
+		chat := &messages.ChatMessage{}
+		if err := m.Data.Deserialize(chat); err != nil {
+			log.W("invalid chat message: %v", err)
+			return
+		}
+		fn(m.GetAction(), chat)
+	}
+	c.Handle(messages.ActionChatMessage, h)
+	c.Handle(messages.ActionGroupMessage, h)
+}
+
+// OnStateChange adds the handler of the connection state, err is the reason of disconnected or closed.
+func (c *Client) OnStateChange(fn func(s State, err error)) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.stateHandlers = append(c.stateHandlers, fn)
+}
+
+// Send sends the message without waiting for any response.
+func (c *Client) Send(m *messages.GlideMessage) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+	return c.write(conn, m)
+}
+
+// Request sends the request and waits for the response, such as api actions, the *messages.Error is returned if
+// responded with api.failed.
+func (c *Client) Request(ctx context.Context, action messages.Action, data interface{}) (*messages.GlideMessage, error) {
+	resp, err := c.requester.RequestContext(ctx, messages.NewMessage(0, action, data))
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetAction() == messages.ActionApiFailed {
+		e, ok := messages.ErrorOf(resp)
+		if !ok {
+			e = messages.NewError("")
+		}
+		return resp, e
+	}
+	return resp, nil
+}
+
+// Sync runs the bootstrap sync after connected, the phases pushed are passed to handlers of messages.ActionNotifySync
+// in order, and the done phase is returned. The state synced is kept, so the sync after reconnect receives changes
+// after the last sync only, limit is the max count of recent conversations, 0 for default.
+func (c *Client) Sync(ctx context.Context, limit int) (*messages.SyncPhase, error) {
+	c.mu.Lock()
+	req := &messages.SyncRequest{
+		Version:      c.syncState.Version,
+		UnreadDigest: c.syncState.UnreadDigest,
+		Limit:        limit,
+	}
+	if c.remoteConfig != nil {
+		req.ConfigVersion = c.remoteConfig.Version
+	}
+	c.mu.Unlock()
+
+	resp, err := c.Request(ctx, messages.ActionApiSync, req)
+	if err != nil {
+		return nil, err
+	}
+	done := &messages.SyncPhase{}
+	if err = resp.Data.Deserialize(done); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.syncState = *done
+	c.mu.Unlock()
+	return done, nil
+}
+
+// SendBatch sends messages in a batch and waits for the result of each item, such as the messages queued while
+// offline. The seq of items is used to match the results, items are handled by the gateway as if they are sent alone.
+func (c *Client) SendBatch(ctx context.Context, ms []*messages.GlideMessage) (*messages.BatchResult, error) {
+	resp, err := c.Request(ctx, messages.ActionBatch, messages.NewBatch(ms))
+	if err != nil {
+		return nil, err
+	}
+	result := &messages.BatchResult{}
+	if err = resp.Data.Deserialize(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SendChat sends the chat message to the user and waits for the ack of server, the message is resent after reconnect
+// until acked, timeout or ctx done. The CliMid is generated if empty, the ticket is signed by the business service.
+func (c *Client) SendChat(ctx context.Context, to string, msg *messages.ChatMessage, ticket string) (*messages.AckMessage, error) {
+	if msg.CliMid == "" {
+		msg.CliMid = c.NewCliMid()
+	}
+	m := messages.NewMessage(c.requester.NextSeq(), messages.ActionChatMessage, msg)
+	m.To = to
+	m.Ticket = ticket
+	p := &pendingChat{m: m, ch: make(chan *messages.AckMessage, 1)}
+
+	c.pendingMu.Lock()
+	c.pending[msg.CliMid] = p
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, msg.CliMid)
+		c.pendingMu.Unlock()
+	}()
+
+	err := c.Send(m)
+	if err != nil && err != ErrNotConnected {
+		// resent after reconnect
+		log.D("send chat message %s failed: %v", msg.CliMid, err)
+	}
+
+	timer := time.NewTimer(c.opts.RequestTimeout)
+	defer timer.Stop()
+	select {
+	case ack := <-p.ch:
+		return ack, nil
+	case <-timer.C:
+		return nil, messages.ErrRequestTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.closeCh:
+		return nil, ErrClosed
+	}
+}
+
+// SendGroup sends the message to the channel, there is no ack of group messages.
+func (c *Client) SendGroup(to string, msg *messages.ChatMessage, ticket string) error {
+	if msg.CliMid == "" {
+		msg.CliMid = c.NewCliMid()
+	}
+	m := messages.NewMessage(c.requester.NextSeq(), messages.ActionGroupMessage, msg)
+	m.To = to
+	m.Ticket = ticket
+	return c.Send(m)
+}
+
+// Ack acks the chat message received to the sender.
+func (c *Client) Ack(msg *messages.ChatMessage) error {
+	return c.Send(messages.NewMessage(0, messages.ActionAckRequest, &messages.AckRequest{
+		CliMid: msg.CliMid,
+		Seq:    msg.Seq,
+		Mid:    msg.Mid,
+		From:   msg.To,
+		To:     msg.From,
+	}))
+}
+
+// NewCliMid returns a new client message id unique in the client.
+func (c *Client) NewCliMid() string {
+	return c.midPrefix + "-" + strconv.FormatInt(atomic.AddInt64(&c.midSeq, 1), 10)
+}
+
+// PoWSolver answers the proof-of-work challenge, other challenges are not supported.
+func PoWSolver(ch *messages.Challenge) (*messages.ChallengeAnswer, error) {
+	if ch.Type != messages.ChallengeTypePoW {
+		return nil, errors.New("unsupported challenge: " + ch.Type)
+	}
+	return &messages.ChallengeAnswer{Solution: gate.SolvePoW(ch.Nonce, ch.Difficulty)}, nil
+}
+
+// serve reads messages of conn, and reconnects when the connection is lost.
+func (c *Client) serve(conn *websocket.Conn) {
+	for {
+		err := c.readLoop(conn)
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		closed := c.closed
+		c.mu.Unlock()
+		_ = conn.Close()
+		if closed {
+			return
+		}
+		log.I("connection lost: %v", err)
+		c.setState(StateDisconnected, err)
+
+		conn = c.reconnect(err)
+		if conn == nil {
+			return
+		}
+		c.resendPending()
+	}
+}
+
+// reconnect connects with exponential backoff until connected or closed, the client connects to the gateway the busy
+// gateway refers to.
+func (c *Client) reconnect(cause error) *websocket.Conn {
+	url := c.opts.URL
+	for attempt := 0; ; attempt++ {
+		delay := c.backoff(attempt)
+		if busy, ok := cause.(*BusyError); ok {
+			if busy.RetryAt != "" {
+				url = busy.RetryAt
+				delay = 0
+			} else if after := time.Duration(busy.RetryAfter) * time.Second; after > delay {
+				delay = after
+			}
+		}
+		select {
+		case <-time.After(delay):
+		case <-c.closeCh:
+			return nil
+		}
+		conn, err := c.connect(context.Background(), url)
+		if err == nil {
+			return conn
+		}
+		log.I("reconnect %s failed: %v", url, err)
+		c.setState(StateDisconnected, err)
+		if _, ok := err.(*AuthError); ok {
+			// the credential is rejected, reconnect is meaningless.
+			_ = c.close(err)
+			return nil
+		}
+		if _, ok := cause.(*BusyError); ok && url != c.opts.URL {
+			url = c.opts.URL
+		}
+		cause = err
+	}
+}
+
+// backoff returns the delay of reconnect attempt, it's doubled each attempt in range with jitter.
+func (c *Client) backoff(attempt int) time.Duration {
+	min, max := c.opts.MinBackoff, c.opts.MaxBackoff
+	c.mu.Lock()
+	if cfg := c.remoteConfig; cfg != nil && cfg.MinBackoff > 0 && cfg.MaxBackoff >= cfg.MinBackoff {
+		min, max = time.Duration(cfg.MinBackoff)*time.Millisecond, time.Duration(cfg.MaxBackoff)*time.Millisecond
+	}
+	c.mu.Unlock()
+	d := min
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// resendPending resends chat messages not acked after reconnected, the server drops duplicates by CliMid.
+func (c *Client) resendPending() {
+	c.pendingMu.Lock()
+	var ms []*messages.GlideMessage
+	for _, p := range c.pending {
+		ms = append(ms, p.m)
+	}
+	c.pendingMu.Unlock()
+	for _, m := range ms {
+		if err := c.Send(m); err != nil {
+			log.D("resend message failed: %v", err)
+			return
+		}
+	}
+}
+
+func (c *Client) readLoop(conn *websocket.Conn) error {
+	c.mu.Lock()
+	interval := defaultHeartbeatInterval
+	if c.hello != nil && c.hello.HeartbeatInterval > 0 {
+		interval = time.Duration(c.hello.HeartbeatInterval) * time.Second
+	}
+	// the interval pushed is applied from the next connection.
+	if c.remoteConfig != nil && c.remoteConfig.HeartbeatInterval > 0 {
+		interval = time.Duration(c.remoteConfig.HeartbeatInterval) * time.Second
+	}
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	defer close(done)
+	go c.heartbeat(conn, interval, done)
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(interval * heartbeatLostLimit))
+		m, err := c.read(conn)
+		if err != nil {
+			return err
+		}
+		if err = c.dispatch(m); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) heartbeat(conn *websocket.Conn, interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.write(conn, messages.NewMessage(0, messages.ActionHeartbeat, nil)); err != nil {
+				_ = conn.Close()
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// dispatch handles the message received, the error returned closes the connection.
+func (c *Client) dispatch(m *messages.GlideMessage) error {
+	switch m.GetAction() {
+	case messages.ActionHeartbeat:
+		return nil
+	case messages.ActionAckMessage:
+		ack := &messages.AckMessage{}
+		if err := m.Data.Deserialize(ack); err == nil {
+			c.pendingMu.Lock()
+			p, ok := c.pending[ack.CliMid]
+			delete(c.pending, ack.CliMid)
+			c.pendingMu.Unlock()
+			if ok {
+				p.ch <- ack
+			}
+		}
+	case messages.ActionChatMessage:
+		if !c.opts.DisableAutoAck {
+			chat := &messages.ChatMessage{}
+			if err := m.Data.Deserialize(chat); err == nil {
+				_ = c.Ack(chat)
+			}
+		}
+	case messages.ActionBatch:
+		batch := messages.Batch{}
+		if err := m.Data.Deserialize(&batch); err != nil {
+			return nil
+		}
+		items, err := batch.Messages()
+		if err != nil {
+			return nil
+		}
+		for _, item := range items {
+			if err = c.dispatch(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case messages.ActionNotifyConfig:
+		cfg := &messages.RemoteConfig{}
+		if err := m.Data.Deserialize(cfg); err != nil {
+			return nil
+		}
+		if !c.applyRemoteConfig(cfg) {
+			return nil
+		}
+	case messages.ActionNotifySync:
+		phase := &messages.SyncPhase{}
+		if err := m.Data.Deserialize(phase); err == nil && phase.Config != nil {
+			c.applyRemoteConfig(phase.Config)
+		}
+	case messages.ActionNotifyKickOut:
+		c.notify(m)
+		_ = c.close(ErrKickedOut)
+		return ErrKickedOut
+	}
+	if c.requester.Dispatch(m) {
+		return nil
+	}
+	c.notify(m)
+	return nil
+}
+
+// applyRemoteConfig returns false if the config of the version is applied already.
+func (c *Client) applyRemoteConfig(cfg *messages.RemoteConfig) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.remoteConfig != nil && c.remoteConfig.Version == cfg.Version && cfg.Version != "" {
+		return false
+	}
+	c.remoteConfig = cfg
+	return true
+}
+
+func (c *Client) notify(m *messages.GlideMessage) {
+	c.handlersMu.RLock()
+	var handlers []Handler
+	handlers = append(handlers, c.handlers[m.GetAction()]...)
+	handlers = append(handlers, c.handlers[""]...)
+	c.handlersMu.RUnlock()
+	for _, h := range handlers {
+		h(m)
+	}
+}
+
+func (c *Client) setState(s State, err error) {
+	c.mu.Lock()
+	if c.state == StateClosed || c.state == s && err == nil {
+		c.mu.Unlock()
+		return
+	}
+	c.state = s
+	c.mu.Unlock()
+
+	c.handlersMu.RLock()
+	handlers := c.stateHandlers
+	c.handlersMu.RUnlock()
+	for _, h := range handlers {
+		h(s, err)
+	}
+}
+
+func (c *Client) close(reason error) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.closeCh)
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+	c.setState(StateClosed, reason)
+	return err
+}
+
+func (c *Client) write(conn *websocket.Conn, m *messages.GlideMessage) error {
+	b, err := messages.JsonCodec.Encode(m)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(c.opts.RequestTimeout))
+	if c.opts.MaxMessageSize <= 0 || len(b) <= c.opts.MaxMessageSize {
+		return conn.WriteMessage(websocket.TextMessage, b)
+	}
+	// the data of chunk is base64 encoded in json.
+	size := (c.opts.MaxMessageSize - chunkOverhead) / 4 * 3
+	if size <= 0 {
+		return errors.New("max message size is too small to send in chunks")
+	}
+	for _, chunk := range messages.SplitChunks(c.NewCliMid(), b, size) {
+		cb, err := messages.JsonCodec.Encode(messages.NewMessage(0, messages.ActionChunk, chunk))
+		if err != nil {
+			return err
+		}
+		if err = conn.WriteMessage(websocket.TextMessage, cb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) read(conn *websocket.Conn) (*messages.GlideMessage, error) {
+	_, b, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	m := messages.NewEmptyMessage()
+	if err = messages.JsonCodec.Decode(b, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
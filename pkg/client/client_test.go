@@ -0,0 +1,298 @@
+package client
+
+import (
+	"context"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeGateway accepts connections, sends hello and handles messages by handle, the connection is closed if handle
+// returns false.
+type fakeGateway struct {
+	*httptest.Server
+	connections int64
+	handle      func(conn *websocket.Conn, m *messages.GlideMessage) bool
+}
+
+func newFakeGateway(handle func(conn *websocket.Conn, m *messages.GlideMessage) bool) *fakeGateway {
+	g := &fakeGateway{handle: handle}
+	upgrader := websocket.Upgrader{}
+	g.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		atomic.AddInt64(&g.connections, 1)
+		reply(conn, messages.NewMessage(0, messages.ActionHello, &messages.ServerHello{TempID: "tmp@1", HeartbeatInterval: 1}))
+		for {
+			_, b, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			m := messages.NewEmptyMessage()
+			_ = messages.JsonCodec.Decode(b, m)
+			if m.GetAction() == messages.ActionHeartbeat || m.GetAction() == messages.ActionHello {
+				continue
+			}
+			if !g.handle(conn, m) {
+				return
+			}
+		}
+	}))
+	return g
+}
+
+func (g *fakeGateway) url() string {
+	return "ws" + strings.TrimPrefix(g.URL, "http")
+}
+
+func reply(conn *websocket.Conn, m *messages.GlideMessage) {
+	b, _ := messages.JsonCodec.Encode(m)
+	_ = conn.WriteMessage(websocket.TextMessage, b)
+}
+
+func authenticate(conn *websocket.Conn, m *messages.GlideMessage) {
+	credential := &gate.EncryptedCredential{}
+	_ = m.Data.Deserialize(credential)
+	if credential.Credential == "ok" {
+		reply(conn, messages.NewMessage(m.GetSeq(), messages.ActionNotifySuccess, nil))
+	} else {
+		reply(conn, messages.NewMessage(m.GetSeq(), messages.ActionNotifyError, "invalid authenticate message"))
+	}
+}
+
+func credential(c string) func() (*gate.EncryptedCredential, error) {
+	return func() (*gate.EncryptedCredential, error) {
+		return &gate.EncryptedCredential{Credential: c}, nil
+	}
+}
+
+func TestClient_SendChat(t *testing.T) {
+	acked := make(chan *messages.AckRequest, 1)
+	g := newFakeGateway(func(conn *websocket.Conn, m *messages.GlideMessage) bool {
+		switch m.GetAction() {
+		case messages.ActionAuthenticate:
+			authenticate(conn, m)
+			reply(conn, messages.NewMessage(0, messages.ActionChatMessage, &messages.ChatMessage{Mid: 2, From: "2", To: "1"}))
+		case messages.ActionChatMessage:
+			chat := &messages.ChatMessage{}
+			_ = m.Data.Deserialize(chat)
+			reply(conn, messages.NewMessage(0, messages.ActionAckMessage, &messages.AckMessage{CliMid: chat.CliMid, Mid: 1}))
+		case messages.ActionAckRequest:
+			ack := &messages.AckRequest{}
+			_ = m.Data.Deserialize(ack)
+			acked <- ack
+		}
+		return true
+	})
+	defer g.Close()
+
+	c, err := New(&Options{URL: g.url(), Credential: credential("ok")})
+	assert.NoError(t, err)
+	received := make(chan *messages.ChatMessage, 1)
+	c.OnChatMessage(func(action messages.Action, m *messages.ChatMessage) {
+		received <- m
+	})
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+	assert.Equal(t, StateConnected, c.State())
+	assert.Equal(t, "tmp@1", c.ServerHello().TempID)
+
+	ack, err := c.SendChat(context.Background(), "2", &messages.ChatMessage{Content: "hi"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), ack.Mid)
+
+	assert.Equal(t, int64(2), (<-received).Mid)
+	ackReq := <-acked
+	assert.Equal(t, "2", ackReq.To)
+	assert.Equal(t, int64(2), ackReq.Mid)
+}
+
+func TestClient_AuthFailed(t *testing.T) {
+	g := newFakeGateway(func(conn *websocket.Conn, m *messages.GlideMessage) bool {
+		if m.GetAction() == messages.ActionAuthenticate {
+			authenticate(conn, m)
+		}
+		return true
+	})
+	defer g.Close()
+
+	c, err := New(&Options{URL: g.url(), Credential: credential("bad")})
+	assert.NoError(t, err)
+	err = c.Connect(context.Background())
+	_, ok := err.(*AuthError)
+	assert.True(t, ok)
+}
+
+func TestClient_ResendAfterReconnect(t *testing.T) {
+	var dropped int64
+	g := newFakeGateway(func(conn *websocket.Conn, m *messages.GlideMessage) bool {
+		switch m.GetAction() {
+		case messages.ActionAuthenticate:
+			authenticate(conn, m)
+		case messages.ActionChatMessage:
+			// the first message is lost with the connection.
+			if atomic.CompareAndSwapInt64(&dropped, 0, 1) {
+				return false
+			}
+			chat := &messages.ChatMessage{}
+			_ = m.Data.Deserialize(chat)
+			reply(conn, messages.NewMessage(0, messages.ActionAckMessage, &messages.AckMessage{CliMid: chat.CliMid, Mid: 1}))
+		}
+		return true
+	})
+	defer g.Close()
+
+	c, err := New(&Options{
+		URL:        g.url(),
+		Credential: credential("ok"),
+		MinBackoff: time.Millisecond * 10,
+		MaxBackoff: time.Millisecond * 50,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	ack, err := c.SendChat(context.Background(), "2", &messages.ChatMessage{CliMid: "m1", Content: "hi"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "m1", ack.CliMid)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&g.connections))
+}
+
+func TestClient_Backoff(t *testing.T) {
+	c, err := New(&Options{URL: "ws://127.0.0.1", MinBackoff: time.Second, MaxBackoff: time.Second * 8})
+	assert.NoError(t, err)
+	for attempt, max := range []time.Duration{1, 2, 4, 8, 8} {
+		d := c.backoff(attempt)
+		assert.True(t, d >= max*time.Second/2 && d <= max*time.Second, "attempt %d: %s", attempt, d)
+	}
+}
+
+func TestClient_Resume(t *testing.T) {
+	var auths, resumes int64
+	resumed := make(chan struct{})
+	g := newFakeGateway(func(conn *websocket.Conn, m *messages.GlideMessage) bool {
+		switch m.GetAction() {
+		case messages.ActionAuthenticate:
+			atomic.AddInt64(&auths, 1)
+			reply(conn, messages.NewMessage(m.GetSeq(), messages.ActionNotifySuccess, &messages.AuthResult{ResumeToken: "t1"}))
+			// the connection lost after authenticated.
+			return false
+		case messages.ActionResume:
+			atomic.AddInt64(&resumes, 1)
+			r := &messages.Resume{}
+			_ = m.Data.Deserialize(r)
+			assert.Equal(t, "t1", r.Token)
+			reply(conn, messages.NewMessage(m.GetSeq(), messages.ActionNotifySuccess, &messages.AuthResult{ResumeToken: "t2", Replayed: 1}))
+			reply(conn, messages.NewMessage(0, messages.ActionNotifySystem, nil))
+		}
+		return true
+	})
+	defer g.Close()
+
+	c, err := New(&Options{URL: g.url(), Credential: credential("ok"), MinBackoff: time.Millisecond * 10})
+	assert.NoError(t, err)
+	c.Handle(messages.ActionNotifySystem, func(m *messages.GlideMessage) {
+		close(resumed)
+	})
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	select {
+	case <-resumed:
+	case <-time.After(time.Second * 3):
+		t.Fatal("session not resumed")
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&auths))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&resumes))
+}
+
+func TestClient_SendBatch(t *testing.T) {
+	g := newFakeGateway(func(conn *websocket.Conn, m *messages.GlideMessage) bool {
+		switch m.GetAction() {
+		case messages.ActionAuthenticate:
+			authenticate(conn, m)
+			batch, _ := messages.NewCompressedBatch([]*messages.GlideMessage{
+				messages.NewMessage(0, messages.ActionChatMessage, &messages.ChatMessage{Mid: 1}),
+				messages.NewMessage(0, messages.ActionChatMessage, &messages.ChatMessage{Mid: 2}),
+			})
+			reply(conn, messages.NewMessage(0, messages.ActionBatch, batch))
+		case messages.ActionBatch:
+			batch := messages.Batch{}
+			_ = m.Data.Deserialize(&batch)
+			items, _ := batch.Messages()
+			result := &messages.BatchResult{}
+			for _, item := range items {
+				result.Results = append(result.Results, &messages.BatchItemResult{Seq: item.GetSeq(), Ok: true})
+			}
+			reply(conn, messages.NewReply(m, messages.ActionBatchResult, result))
+		}
+		return true
+	})
+	defer g.Close()
+
+	c, err := New(&Options{URL: g.url(), Credential: credential("ok"), DisableAutoAck: true})
+	assert.NoError(t, err)
+	received := make(chan *messages.ChatMessage, 2)
+	c.OnChatMessage(func(action messages.Action, m *messages.ChatMessage) {
+		received <- m
+	})
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	// items of the batch received are handled in order.
+	assert.Equal(t, int64(1), (<-received).Mid)
+	assert.Equal(t, int64(2), (<-received).Mid)
+
+	result, err := c.SendBatch(context.Background(), []*messages.GlideMessage{
+		messages.NewMessage(1, messages.ActionChatMessage, &messages.ChatMessage{Content: "queued"}),
+		messages.NewMessage(2, messages.ActionChatMessage, &messages.ChatMessage{Content: "queued"}),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, result.Results, 2)
+	assert.Equal(t, int64(2), result.Results[1].Seq)
+}
+
+func TestClient_RemoteConfig(t *testing.T) {
+	g := newFakeGateway(func(conn *websocket.Conn, m *messages.GlideMessage) bool {
+		if m.GetAction() == messages.ActionAuthenticate {
+			authenticate(conn, m)
+			cfg := &messages.RemoteConfig{Version: "v1", MinBackoff: 100, MaxBackoff: 400}
+			reply(conn, messages.NewMessage(0, messages.ActionNotifyConfig, cfg))
+			reply(conn, messages.NewMessage(0, messages.ActionNotifyConfig, cfg))
+		}
+		return true
+	})
+	defer g.Close()
+
+	c, err := New(&Options{URL: g.url(), Credential: credential("ok")})
+	assert.NoError(t, err)
+	notified := make(chan *messages.GlideMessage, 2)
+	c.Handle(messages.ActionNotifyConfig, func(m *messages.GlideMessage) {
+		notified <- m
+	})
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	<-notified
+	// the config of the same version is skipped.
+	select {
+	case <-notified:
+		t.Fatal("config of the same version notified")
+	case <-time.After(time.Millisecond * 100):
+	}
+	assert.Equal(t, "v1", c.RemoteConfig().Version)
+	for attempt, max := range []time.Duration{100, 200, 400, 400} {
+		d := c.backoff(attempt)
+		assert.True(t, d >= max*time.Millisecond/2 && d <= max*time.Millisecond, "attempt %d: %s", attempt, d)
+	}
+}
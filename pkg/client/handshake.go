@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/gorilla/websocket"
+	"time"
+)
+
+// connect dials the gateway of url and handshakes, the connection is set as the current connection.
+func (c *Client) connect(ctx context.Context, url string) (*websocket.Conn, error) {
+	c.setState(StateConnecting, nil)
+	ctx, cancel := context.WithTimeout(ctx, c.opts.RequestTimeout)
+	defer cancel()
+
+	conn, _, err := c.opts.Dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.handshake(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		_ = conn.Close()
+		return nil, ErrClosed
+	}
+	c.conn = conn
+	c.mu.Unlock()
+	c.setState(StateConnected, nil)
+	return conn, nil
+}
+
+// handshake waits for the server hello, answers the challenge and authenticates in RequestTimeout.
+func (c *Client) handshake(conn *websocket.Conn) error {
+	_ = conn.SetReadDeadline(time.Now().Add(c.opts.RequestTimeout))
+
+	for {
+		m, err := c.read(conn)
+		if err != nil {
+			return err
+		}
+		if m.GetAction() == messages.ActionNotifyServerBusy {
+			return busyError(m)
+		}
+		if m.GetAction() != messages.ActionHello {
+			continue
+		}
+		hello := &messages.ServerHello{}
+		if err = m.Data.Deserialize(hello); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.hello = hello
+		c.mu.Unlock()
+		break
+	}
+
+	hello := messages.Hello{}
+	if c.opts.Hello != nil {
+		hello = *c.opts.Hello
+	}
+	if hello.Protocol == 0 {
+		hello.Protocol = messages.ProtocolCurrent
+	}
+	if err := c.write(conn, messages.NewMessage(0, messages.ActionHello, &hello)); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	token := c.resumeToken
+	c.mu.Unlock()
+	if token == "" && c.opts.Credential == nil && c.opts.Challenge == nil {
+		return nil
+	}
+
+	var authSeq, answerSeq, resumeSeq int64
+	// challenge the challenge received while resuming, it's answered if resume failed.
+	var challenge *messages.Challenge
+	authenticate := func() error {
+		if c.opts.Credential == nil {
+			return nil
+		}
+		credential, err := c.opts.Credential()
+		if err != nil {
+			return err
+		}
+		if c.opts.Capabilities != nil && credential.Capabilities == nil {
+			credential.Capabilities = c.opts.Capabilities
+		}
+		authSeq = c.requester.NextSeq()
+		return c.write(conn, messages.NewMessage(authSeq, messages.ActionAuthenticate, credential))
+	}
+	answer := func(ch *messages.Challenge) error {
+		if c.opts.Challenge == nil {
+			return ErrChallengeRequired
+		}
+		a, err := c.opts.Challenge(ch)
+		if err != nil {
+			return err
+		}
+		answerSeq = c.requester.NextSeq()
+		return c.write(conn, messages.NewMessage(answerSeq, messages.ActionChallengeAnswer, a))
+	}
+
+	if token != "" {
+		// the session is resumed without challenge and authenticate.
+		resumeSeq = c.requester.NextSeq()
+		if err := c.write(conn, messages.NewMessage(resumeSeq, messages.ActionResume, &messages.Resume{Token: token})); err != nil {
+			return err
+		}
+	} else if c.opts.Challenge == nil {
+		// the challenge is sent right after the hello, authenticate after it's solved.
+		if err := authenticate(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		m, err := c.read(conn)
+		if err != nil {
+			return err
+		}
+		seq := m.GetSeq()
+		switch m.GetAction() {
+		case messages.ActionChallenge:
+			ch := &messages.Challenge{}
+			if err = m.Data.Deserialize(ch); err != nil {
+				return err
+			}
+			if resumeSeq != 0 {
+				challenge = ch
+				continue
+			}
+			if err = answer(ch); err != nil {
+				return err
+			}
+		case messages.ActionNotifySuccess:
+			switch {
+			case resumeSeq != 0 && seq == resumeSeq, authSeq != 0 && seq == authSeq:
+				c.setAuthResult(m)
+				return nil
+			case answerSeq != 0 && seq == answerSeq:
+				if c.opts.Credential == nil {
+					return nil
+				}
+				if err = authenticate(); err != nil {
+					return err
+				}
+			}
+		case messages.ActionNotifyError, messages.ActionNotifyForbidden:
+			e, ok := messages.ErrorOf(m)
+			if !ok {
+				e = messages.NewError("")
+			}
+			reason := e.Message
+			switch {
+			case resumeSeq != 0 && seq == resumeSeq:
+				// the session expired, authenticate again.
+				log.D("resume failed: %s", reason)
+				resumeSeq = 0
+				c.mu.Lock()
+				c.resumeToken = ""
+				c.mu.Unlock()
+				if challenge != nil {
+					err = answer(challenge)
+				} else if c.opts.Challenge == nil {
+					if c.opts.Credential == nil {
+						return nil
+					}
+					err = authenticate()
+				}
+				if err != nil {
+					return err
+				}
+			case e.Code == messages.ErrCodeChallengeRequired.Code, answerSeq != 0 && seq == answerSeq:
+				// a new challenge is sent by the gateway.
+				if c.opts.Challenge == nil {
+					return ErrChallengeRequired
+				}
+			case authSeq != 0 && seq == authSeq:
+				return &AuthError{Reason: reason, Code: e.Code}
+			}
+		case messages.ActionNotifyServerBusy:
+			return busyError(m)
+		default:
+			c.notify(m)
+		}
+	}
+}
+
+// setAuthResult saves the resume token of the result of authenticate or resume.
+func (c *Client) setAuthResult(m *messages.GlideMessage) {
+	result := &messages.AuthResult{}
+	if m.Data != nil {
+		_ = m.Data.Deserialize(result)
+	}
+	c.mu.Lock()
+	c.resumeToken = result.ResumeToken
+	c.mu.Unlock()
+	if result.Replayed > 0 {
+		log.D("session resumed, %d messages replayed", result.Replayed)
+	}
+}
+
+func busyError(m *messages.GlideMessage) error {
+	busy := &messages.ServerBusy{}
+	if err := m.Data.Deserialize(busy); err != nil {
+		return err
+	}
+	return &BusyError{ServerBusy: busy}
+}
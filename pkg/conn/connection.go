@@ -1,6 +1,7 @@
 package conn
 
 import (
+	"crypto/x509"
 	"errors"
 )
 
@@ -10,12 +11,20 @@ var (
 	ErrConnectionClosed = errors.New("connection closed")
 	ErrBadPackage       = errors.New("bad package data")
 	ErrReadTimeout      = errors.New("i/o timeout")
+	// ErrMessageTooLarge the message read is larger than the max message size, the message is discarded.
+	ErrMessageTooLarge = errors.New("message too large")
 )
 
 type ConnectionInfo struct {
 	Ip   string
 	Port int
 	Addr string
+	// Subprotocol the negotiated application protocol, empty express the default.
+	Subprotocol string
+	// PeerCertificates the verified certificate chain of client when mutual TLS enabled, the first one is the leaf.
+	PeerCertificates []*x509.Certificate
+	// Fingerprint the fingerprint of the client observed while connecting, nil if not supported by the server.
+	Fingerprint *Fingerprint
 }
 
 // Connection expression a network keep-alive connection, WebSocket, tcp etc
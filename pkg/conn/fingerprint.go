@@ -0,0 +1,145 @@
+package conn
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fingerprint the characteristics of the client observed while connecting, used to tell automated or abusive
+// clients, see gate.AnomalyDetector.
+type Fingerprint struct {
+	// JA3 the md5 of the JA3 style string of TLS ClientHello: "version,ciphers,extensions,curves,points". The
+	// extensions are always empty since they are not exposed by crypto/tls, and GREASE values are excluded, so it's
+	// comparable between connections of this server only. Empty if not over TLS.
+	JA3 string `json:"ja3,omitempty"`
+	// ALPN the application protocol negotiated by TLS.
+	ALPN string `json:"alpn,omitempty"`
+	// TLSVersion and CipherSuite negotiated, 0 if not over TLS.
+	TLSVersion  uint16 `json:"tls_version,omitempty"`
+	CipherSuite uint16 `json:"cipher_suite,omitempty"`
+	// ServerName the SNI requested by the client.
+	ServerName string `json:"server_name,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	// HandshakeDuration the duration from the connection accepted to the upgrade request received, including TLS
+	// handshake.
+	HandshakeDuration time.Duration `json:"handshake_duration,omitempty"`
+}
+
+// JA3 returns the md5 hex of the JA3 style string of the ClientHello, see Fingerprint.JA3.
+func JA3(hello *tls.ClientHelloInfo) string {
+	var version uint16
+	for _, v := range hello.SupportedVersions {
+		if !isGREASE(v) && v > version {
+			version = v
+		}
+	}
+	points := make([]uint16, 0, len(hello.SupportedPoints))
+	for _, p := range hello.SupportedPoints {
+		points = append(points, uint16(p))
+	}
+	curves := make([]uint16, 0, len(hello.SupportedCurves))
+	for _, c := range hello.SupportedCurves {
+		curves = append(curves, uint16(c))
+	}
+	s := strings.Join([]string{
+		strconv.Itoa(int(version)),
+		joinUint16(hello.CipherSuites),
+		"",
+		joinUint16(curves),
+		joinUint16(points),
+	}, ",")
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func joinUint16(values []uint16) string {
+	sb := strings.Builder{}
+	for _, v := range values {
+		if isGREASE(v) {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte('-')
+		}
+		sb.WriteString(strconv.Itoa(int(v)))
+	}
+	return sb.String()
+}
+
+// isGREASE reports whether the value is reserved by RFC 8701, clients send random ones of them.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+type connRecord struct {
+	acceptedAt time.Time
+	ja3        string
+}
+
+// fingerprintRecorder records the accepted time and ClientHello of connections of the http server by remote address,
+// until the connection is hijacked by websocket upgrade or closed.
+type fingerprintRecorder struct {
+	mu    sync.Mutex
+	conns map[string]*connRecord
+}
+
+func newFingerprintRecorder() *fingerprintRecorder {
+	return &fingerprintRecorder{conns: map[string]*connRecord{}}
+}
+
+// connState is the http.Server.ConnState hook.
+func (r *fingerprintRecorder) connState(c net.Conn, state http.ConnState) {
+	addr := c.RemoteAddr().String()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch state {
+	case http.StateNew:
+		r.conns[addr] = &connRecord{acceptedAt: time.Now()}
+	case http.StateHijacked, http.StateClosed:
+		delete(r.conns, addr)
+	}
+}
+
+// tlsConfig returns the copy of cfg records the JA3 of ClientHello, the GetConfigForClient of cfg is kept.
+func (r *fingerprintRecorder) tlsConfig(cfg *tls.Config) *tls.Config {
+	cfg = cfg.Clone()
+	next := cfg.GetConfigForClient
+	cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		ja3 := JA3(hello)
+		r.mu.Lock()
+		if rec, ok := r.conns[hello.Conn.RemoteAddr().String()]; ok {
+			rec.ja3 = ja3
+		}
+		r.mu.Unlock()
+		if next != nil {
+			return next(hello)
+		}
+		return nil, nil
+	}
+	return cfg
+}
+
+// fingerprint returns the fingerprint of the connection of request, it must be called before the connection hijacked.
+func (r *fingerprintRecorder) fingerprint(req *http.Request) *Fingerprint {
+	fp := &Fingerprint{UserAgent: req.UserAgent()}
+	if req.TLS != nil {
+		fp.ALPN = req.TLS.NegotiatedProtocol
+		fp.TLSVersion = req.TLS.Version
+		fp.CipherSuite = req.TLS.CipherSuite
+		fp.ServerName = req.TLS.ServerName
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rec, ok := r.conns[req.RemoteAddr]; ok {
+		fp.JA3 = rec.ja3
+		fp.HandshakeDuration = time.Since(rec.acceptedAt)
+	}
+	return fp
+}
@@ -0,0 +1,78 @@
+package conn
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJA3(t *testing.T) {
+	hello := &tls.ClientHelloInfo{
+		CipherSuites:      []uint16{0x1a1a, tls.TLS_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		SupportedCurves:   []tls.CurveID{0x2a2a, tls.X25519, tls.CurveP256},
+		SupportedPoints:   []uint8{0},
+		SupportedVersions: []uint16{0xfafa, tls.VersionTLS13, tls.VersionTLS12},
+	}
+	withoutGREASE := &tls.ClientHelloInfo{
+		CipherSuites:      []uint16{tls.TLS_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		SupportedCurves:   []tls.CurveID{tls.X25519, tls.CurveP256},
+		SupportedPoints:   []uint8{0},
+		SupportedVersions: []uint16{tls.VersionTLS13, tls.VersionTLS12},
+	}
+	assert.Len(t, JA3(hello), 32)
+	assert.Equal(t, JA3(withoutGREASE), JA3(hello))
+
+	withoutGREASE.CipherSuites = withoutGREASE.CipherSuites[1:]
+	assert.NotEqual(t, JA3(withoutGREASE), JA3(hello))
+}
+
+func TestWsServer_Fingerprint(t *testing.T) {
+	ca, caKey := newTestCert(t, "ca", nil, nil)
+	server, serverKey := newTestCert(t, "server", ca, caKey)
+
+	ws := NewWsServer(nil).(*WsServer)
+	infos := make(chan *ConnectionInfo, 1)
+	ws.SetConnHandler(func(conn Connection) {
+		infos <- conn.GetConnInfo()
+	})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(ws.handleWebSocketRequest))
+	srv.Config.ConnState = ws.recorder.connState
+	srv.TLS = ws.recorder.tlsConfig(&tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.Raw}, PrivateKey: serverKey}},
+	})
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	dialer := websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"}}
+	header := http.Header{}
+	header.Set("User-Agent", "glide-test/1.0")
+	c, _, err := dialer.Dial("wss"+strings.TrimPrefix(srv.URL, "https"), header)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	select {
+	case info := <-infos:
+		fp := info.Fingerprint
+		assert.NotNil(t, fp)
+		assert.Equal(t, "glide-test/1.0", fp.UserAgent)
+		assert.Len(t, fp.JA3, 32)
+		assert.Equal(t, uint16(tls.VersionTLS13), fp.TLSVersion)
+		assert.NotZero(t, fp.CipherSuite)
+		assert.Positive(t, fp.HandshakeDuration)
+	case <-time.After(time.Second):
+		t.Fatal("connection not handled")
+	}
+
+	// the record is removed once the connection hijacked.
+	ws.recorder.mu.Lock()
+	assert.Empty(t, ws.recorder.conns)
+	ws.recorder.mu.Unlock()
+}
@@ -0,0 +1,44 @@
+package conn
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	defaultNetpollWorkers      = 1024
+	defaultNetpollFrameTimeout = time.Second * 5
+	defaultMaxMessageSize      = 1 << 20
+)
+
+// ErrPolled the connection is read by the netpoll server, the Read method is not available.
+var ErrPolled = errors.New("connection is read by poller")
+
+// ReadHandler handles the message read from the PolledConnection, err is not nil when the read failed, and no more
+// messages will be delivered after the error.
+type ReadHandler func(data []byte, err error)
+
+// PolledConnection is the connection served by the netpoll server, no goroutine blocks on reading it, messages are
+// read by the shared workers when the socket is readable and delivered to the ReadHandler.
+type PolledConnection interface {
+	Connection
+
+	// SetReadHandler sets the handler of messages, it must be set before the connection handler returns.
+	// The handler is called serially.
+	SetReadHandler(h ReadHandler)
+}
+
+type NetpollServerOptions struct {
+	// WriteTimeout the deadline of writing a message.
+	WriteTimeout time.Duration
+	// FrameTimeout the max duration to read a frame after the socket is readable, default 5s.
+	FrameTimeout time.Duration
+	// Pollers the count of epoll instances, default the count of CPU.
+	Pollers int
+	// Workers the count of goroutines read messages from readable connections, default 1024.
+	Workers int
+	// MaxMessageSize the max size of a message from client in bytes, default 1MB.
+	MaxMessageSize int64
+}
+
+var _ Server = (*NetpollServer)(nil)
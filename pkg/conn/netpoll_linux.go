@@ -0,0 +1,319 @@
+//go:build linux
+
+package conn
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/panjf2000/ants/v2"
+	"net"
+	"net/http"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const pollEvents = syscall.EPOLLIN | syscall.EPOLLRDHUP | syscall.EPOLLHUP | syscall.EPOLLERR | syscall.EPOLLONESHOT
+
+// NetpollServer serves websocket connections by epoll, the connections are not read by a goroutine each, instead the
+// pollers wait for readable sockets, and a shared worker pool reads one frame of each readable connection, so the
+// count of goroutines and stack memory are decoupled from the count of connections.
+type NetpollServer struct {
+	upgradeChecker
+	options  NetpollServerOptions
+	upgrader websocket.Upgrader
+	handler  ConnectionHandler
+
+	pollers []*poller
+	workers *ants.Pool
+}
+
+// NewNetpollServer options can be nil, use default value when nil.
+func NewNetpollServer(options *NetpollServerOptions) (*NetpollServer, error) {
+	opts := NetpollServerOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 8 * time.Minute
+	}
+	if opts.FrameTimeout <= 0 {
+		opts.FrameTimeout = defaultNetpollFrameTimeout
+	}
+	if opts.Pollers <= 0 {
+		opts.Pollers = runtime.NumCPU()
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = defaultNetpollWorkers
+	}
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = defaultMaxMessageSize
+	}
+
+	workers, err := ants.NewPool(opts.Workers, ants.WithPreAlloc(false))
+	if err != nil {
+		return nil, err
+	}
+	ret := &NetpollServer{
+		options:  opts,
+		upgrader: newUpgrader(),
+		workers:  workers,
+	}
+	for i := 0; i < opts.Pollers; i++ {
+		p, err := newPoller()
+		if err != nil {
+			return nil, err
+		}
+		ret.pollers = append(ret.pollers, p)
+		go p.run(ret.onReadable)
+	}
+	return ret, nil
+}
+
+func (s *NetpollServer) SetConnHandler(handler ConnectionHandler) {
+	s.handler = handler
+}
+
+func (s *NetpollServer) Run(host string, port int) error {
+	addr := fmt.Sprintf("%s:%d", host, port)
+	return http.ListenAndServe(addr, serveMux(s.handleWebSocketRequest))
+}
+
+func (s *NetpollServer) handleWebSocketRequest(writer http.ResponseWriter, request *http.Request) {
+	if s.reject(writer, request) {
+		return
+	}
+	ws, err := s.upgrader.Upgrade(writer, request, nil)
+	if err != nil {
+		return
+	}
+	fd, err := socketFD(ws.UnderlyingConn())
+	if err != nil {
+		_ = ws.Close()
+		return
+	}
+	c := &pollConn{
+		ws:        ws,
+		raw:       ws.UnderlyingConn(),
+		fd:        fd,
+		options:   &s.options,
+		poller:    s.pollers[fd%len(s.pollers)],
+		assembler: messageAssembler{maxSize: s.options.MaxMessageSize},
+	}
+	s.handler(c)
+	if c.handler == nil {
+		_ = c.Close()
+		return
+	}
+	if err = c.poller.add(c); err != nil {
+		c.handler(nil, err)
+		_ = c.Close()
+	}
+}
+
+// onReadable reads the connection in the worker pool, the connection is armed again after read.
+func (s *NetpollServer) onReadable(c *pollConn) {
+	err := s.workers.Submit(func() {
+		if c.read() {
+			if err := c.poller.rearm(c); err != nil && !c.isClosed() {
+				c.handler(nil, err)
+			}
+		}
+	})
+	if err != nil {
+		_ = c.poller.rearm(c)
+	}
+}
+
+func socketFD(c net.Conn) (int, error) {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return 0, errors.New("connection does not support syscall")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var fd int
+	err = raw.Control(func(f uintptr) {
+		fd = int(f)
+	})
+	return fd, err
+}
+
+var _ PolledConnection = (*pollConn)(nil)
+
+type pollConn struct {
+	ws      *websocket.Conn
+	raw     net.Conn
+	fd      int
+	options *NetpollServerOptions
+	poller  *poller
+	handler ReadHandler
+
+	assembler messageAssembler
+
+	closeOnce sync.Once
+}
+
+func (c *pollConn) SetReadHandler(h ReadHandler) {
+	c.handler = h
+}
+
+func (c *pollConn) Write(data []byte) error {
+	_ = c.ws.SetWriteDeadline(time.Now().Add(c.options.WriteTimeout))
+	msgType := websocket.TextMessage
+	if IsBinarySubprotocol(c.ws.Subprotocol()) {
+		msgType = websocket.BinaryMessage
+	}
+	err := c.ws.WriteMessage(msgType, data)
+	if err != nil && c.isClosed() {
+		return ErrClosed
+	}
+	return err
+}
+
+func (c *pollConn) Read() ([]byte, error) {
+	return nil, ErrPolled
+}
+
+func (c *pollConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.poller.remove(c)
+		err = c.ws.Close()
+	})
+	return err
+}
+
+func (c *pollConn) isClosed() bool {
+	c.poller.mu.RLock()
+	defer c.poller.mu.RUnlock()
+	return c.poller.conns[c.fd] != c
+}
+
+func (c *pollConn) GetConnInfo() *ConnectionInfo {
+	remoteAddr := c.ws.RemoteAddr().(*net.TCPAddr)
+	return &ConnectionInfo{
+		Ip:          remoteAddr.IP.String(),
+		Port:        remoteAddr.Port,
+		Addr:        c.ws.RemoteAddr().String(),
+		Subprotocol: c.ws.Subprotocol(),
+	}
+}
+
+// read reads one frame, delivers the message to handler when a message completed, returns false if the connection
+// is failed and must not be polled anymore.
+func (c *pollConn) read() bool {
+	_ = c.raw.SetReadDeadline(time.Now().Add(c.options.FrameTimeout))
+	f, err := readFrame(c.raw, c.options.MaxMessageSize)
+	if err != nil {
+		c.fail(err)
+		return false
+	}
+	switch f.opcode {
+	case opPing:
+		_ = c.ws.WriteControl(websocket.PongMessage, f.payload, time.Now().Add(c.options.WriteTimeout))
+		return true
+	case opPong:
+		return true
+	case opClose:
+		_ = c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(c.options.WriteTimeout))
+		c.fail(ErrClosed)
+		return false
+	}
+	data, ok, err := c.assembler.add(f)
+	if err != nil {
+		c.fail(err)
+		return false
+	}
+	if ok {
+		c.handler(data, nil)
+	}
+	return true
+}
+
+func (c *pollConn) fail(err error) {
+	if c.isClosed() {
+		return
+	}
+	c.poller.remove(c)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		err = ErrReadTimeout
+	}
+	c.handler(nil, err)
+}
+
+// poller waits for readable connections by epoll.
+type poller struct {
+	fd    int
+	mu    sync.RWMutex
+	conns map[int]*pollConn
+}
+
+func newPoller() (*poller, error) {
+	fd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	return &poller{fd: fd, conns: map[int]*pollConn{}}, nil
+}
+
+func (p *poller) add(c *pollConn) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := syscall.EpollCtl(p.fd, syscall.EPOLL_CTL_ADD, c.fd, &syscall.EpollEvent{Events: pollEvents, Fd: int32(c.fd)})
+	if err != nil {
+		return err
+	}
+	p.conns[c.fd] = c
+	return nil
+}
+
+func (p *poller) rearm(c *pollConn) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.conns[c.fd] != c {
+		return nil
+	}
+	return syscall.EpollCtl(p.fd, syscall.EPOLL_CTL_MOD, c.fd, &syscall.EpollEvent{Events: pollEvents, Fd: int32(c.fd)})
+}
+
+func (p *poller) remove(c *pollConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conns[c.fd] != c {
+		return
+	}
+	delete(p.conns, c.fd)
+	_ = syscall.EpollCtl(p.fd, syscall.EPOLL_CTL_DEL, c.fd, nil)
+}
+
+func (p *poller) run(onReadable func(c *pollConn)) {
+	events := make([]syscall.EpollEvent, 256)
+	readable := make([]*pollConn, 0, len(events))
+	for {
+		n, err := syscall.EpollWait(p.fd, events, -1)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			return
+		}
+		readable = readable[:0]
+		p.mu.RLock()
+		for i := 0; i < n; i++ {
+			if c, ok := p.conns[int(events[i].Fd)]; ok {
+				readable = append(readable, c)
+			}
+		}
+		p.mu.RUnlock()
+		// the lock is released, dispatching blocks when all workers are busy.
+		for _, c := range readable {
+			onReadable(c)
+		}
+	}
+}
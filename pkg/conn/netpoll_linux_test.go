@@ -0,0 +1,78 @@
+//go:build linux
+
+package conn
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNetpollServer(t *testing.T) {
+	server, err := NewNetpollServer(&NetpollServerOptions{Pollers: 1, Workers: 2})
+	assert.NoError(t, err)
+
+	received := make(chan string, 10)
+	closed := make(chan error, 1)
+	server.SetConnHandler(func(conn Connection) {
+		pc := conn.(PolledConnection)
+		pc.SetReadHandler(func(data []byte, err error) {
+			if err != nil {
+				closed <- err
+				return
+			}
+			received <- string(data)
+			_ = pc.Write(data)
+		})
+	})
+	srv := httptest.NewServer(http.HandlerFunc(server.handleWebSocketRequest))
+	defer srv.Close()
+
+	dialer := websocket.Dialer{WriteBufferSize: 1024}
+	client, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	assert.NoError(t, err)
+
+	pong := make(chan string, 1)
+	client.SetPongHandler(func(data string) error {
+		pong <- data
+		return nil
+	})
+
+	// the large message is fragmented by the client write buffer.
+	large := strings.Repeat("a", 4096)
+	for _, m := range []string{"hello", large} {
+		assert.NoError(t, client.WriteMessage(websocket.TextMessage, []byte(m)))
+		select {
+		case r := <-received:
+			assert.Equal(t, m, r)
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+		_, echo, err := client.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, m, string(echo))
+	}
+
+	assert.NoError(t, client.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(time.Second)))
+	go func() {
+		_, _, _ = client.ReadMessage()
+	}()
+	select {
+	case p := <-pong:
+		assert.Equal(t, "ping", p)
+	case <-time.After(time.Second):
+		t.Fatal("pong not received")
+	}
+
+	_ = client.Close()
+	select {
+	case err = <-closed:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("close not detected")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package conn
+
+import "errors"
+
+// NetpollServer is only supported on linux.
+type NetpollServer struct{}
+
+func NewNetpollServer(_ *NetpollServerOptions) (*NetpollServer, error) {
+	return nil, errors.New("netpoll server is only supported on linux")
+}
+
+func (s *NetpollServer) SetConnHandler(_ ConnectionHandler) {}
+
+func (s *NetpollServer) Run(_ string, _ int) error {
+	return errors.New("netpoll server is only supported on linux")
+}
+
+func (s *NetpollServer) SetUpgradePolicy(_ *UpgradePolicy) {}
@@ -0,0 +1,411 @@
+package conn
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PollPath the path prefix of the long-polling endpoints:
+//
+//	POST /poll/open                   opens the session, responds {"sid": "..."}
+//	GET  /poll/recv?sid=              waits for messages, responds the json array of messages, or streams messages as
+//	                                  server-sent events if the request accepts text/event-stream
+//	POST /poll/send?sid=              sends the message in the body
+//	POST /poll/close?sid=             closes the session
+//
+// Sessions live in memory of the gateway, the client opens a new session and resumes by the resume token if the sid
+// is unknown, such as routed to another gateway or expired, see gate.ResumeOptions.
+const PollPath = "/poll"
+
+const (
+	defaultPollTimeout     = time.Second * 25
+	defaultPollIdleTimeout = time.Minute
+	defaultPollQueueSize   = 256
+	// pollBatchSize the max count of messages responded by a receive request.
+	pollBatchSize = 64
+)
+
+type PollServerOptions struct {
+	// PollTimeout the max duration a receive request waits for messages, and the interval of keepalive comments of
+	// server-sent events, default 25s.
+	PollTimeout time.Duration
+	// IdleTimeout the session is closed if there is no receive request in it, default 1 minute.
+	IdleTimeout time.Duration
+	// WriteTimeout the max duration Write waits for the message queue not full, default IdleTimeout.
+	WriteTimeout time.Duration
+	// QueueSize the count of messages waiting for the client to receive, default 256.
+	QueueSize int
+	// MaxMessageSize the max size of a message sent by client in bytes, default 1MB.
+	MaxMessageSize int64
+}
+
+// PollServer serves clients behind proxies which break websocket by HTTP long-polling or server-sent events, each
+// session is a Connection of json messages. Messages responded to a receive request broken are lost, clients rely
+// on the resume and ack of messages as they do after websocket reconnected.
+type PollServer struct {
+	upgradeChecker
+	options PollServerOptions
+	handler ConnectionHandler
+
+	mu       sync.Mutex
+	sessions map[string]*PollConnection
+}
+
+var _ Server = (*PollServer)(nil)
+
+// NewPollServer options can be nil, use default value when nil.
+func NewPollServer(options *PollServerOptions) *PollServer {
+	opts := PollServerOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.PollTimeout <= 0 {
+		opts.PollTimeout = defaultPollTimeout
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = defaultPollIdleTimeout
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = opts.IdleTimeout
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultPollQueueSize
+	}
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = defaultMaxMessageSize
+	}
+	return &PollServer{
+		options:  opts,
+		sessions: map[string]*PollConnection{},
+	}
+}
+
+func (s *PollServer) SetConnHandler(handler ConnectionHandler) {
+	s.handler = handler
+}
+
+// Run serves the endpoints on the port, the server can also share the port of websocket server by registering to the
+// http.DefaultServeMux with PollPath.
+func (s *PollServer) Run(host string, port int) error {
+	mux := http.NewServeMux()
+	mux.Handle(PollPath+"/", s)
+	return http.ListenAndServe(fmt.Sprintf("%s:%d", host, port), mux)
+}
+
+// SessionCount returns the count of sessions opened.
+func (s *PollServer) SessionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+func (s *PollServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !s.allowOrigin(r) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Vary", "Origin")
+	}
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	action := strings.TrimPrefix(r.URL.Path, PollPath+"/")
+	if action == "open" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.open(w, r)
+		return
+	}
+	c := s.session(r.URL.Query().Get("sid"))
+	if c == nil {
+		http.Error(w, "session does not exist", http.StatusNotFound)
+		return
+	}
+	switch {
+	case action == "recv" && r.Method == http.MethodGet:
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			c.stream(w, r)
+		} else {
+			c.recv(w, r)
+		}
+	case action == "send" && r.Method == http.MethodPost:
+		c.send(w, r)
+	case action == "close" && r.Method == http.MethodPost:
+		_ = c.Close()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (s *PollServer) open(w http.ResponseWriter, r *http.Request) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id := hex.EncodeToString(b)
+	c := newPollConnection(id, r, &s.options, func() {
+		s.mu.Lock()
+		delete(s.sessions, id)
+		s.mu.Unlock()
+	})
+	s.mu.Lock()
+	s.sessions[c.id] = c
+	s.mu.Unlock()
+
+	s.handler(ConnectionProxy{conn: c})
+	if c.isClosed() {
+		// rejected by the handler, such as the admission.
+		http.Error(w, "connection rejected", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, `{"sid":"`+c.id+`"}`)
+}
+
+func (s *PollServer) session(sid string) *PollConnection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[sid]
+}
+
+// PollConnection the session of the long-polling client.
+type PollConnection struct {
+	id      string
+	info    ConnectionInfo
+	options *PollServerOptions
+
+	// in messages sent by the client, out messages waiting for the client to receive.
+	in  chan []byte
+	out chan []byte
+
+	// receiving the count of receive requests in progress, the idle timer runs when it's 0.
+	receiving int32
+	idleMu    sync.Mutex
+	idle      *time.Timer
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	onClose   func()
+}
+
+func newPollConnection(id string, r *http.Request, options *PollServerOptions, onClose func()) *PollConnection {
+	c := &PollConnection{
+		id:      id,
+		options: options,
+		onClose: onClose,
+		in:      make(chan []byte, 16),
+		out:     make(chan []byte, options.QueueSize),
+		closed:  make(chan struct{}),
+	}
+	c.info.Addr = r.RemoteAddr
+	if host, port, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		c.info.Ip = host
+		c.info.Port, _ = strconv.Atoi(port)
+	}
+	c.idleMu.Lock()
+	c.idle = time.AfterFunc(options.IdleTimeout, func() {
+		if atomic.LoadInt32(&c.receiving) == 0 {
+			_ = c.Close()
+		}
+	})
+	c.idleMu.Unlock()
+	return c
+}
+
+func (c *PollConnection) Write(data []byte) error {
+	select {
+	case c.out <- data:
+		return nil
+	default:
+	}
+	timer := time.NewTimer(c.options.WriteTimeout)
+	defer timer.Stop()
+	select {
+	case c.out <- data:
+		return nil
+	case <-c.closed:
+		return ErrClosed
+	case <-timer.C:
+		return ErrForciblyClosed
+	}
+}
+
+func (c *PollConnection) Read() ([]byte, error) {
+	select {
+	case data := <-c.in:
+		return data, nil
+	case <-c.closed:
+		return nil, ErrConnectionClosed
+	}
+}
+
+func (c *PollConnection) Close() error {
+	c.closeOnce.Do(func() {
+		c.resetIdle(false)
+		close(c.closed)
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+	return nil
+}
+
+func (c *PollConnection) GetConnInfo() *ConnectionInfo {
+	info := c.info
+	return &info
+}
+
+func (c *PollConnection) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// receiveStart stops the idle timer while the client is receiving, receiveEnd restarts it.
+func (c *PollConnection) receiveStart() {
+	if atomic.AddInt32(&c.receiving, 1) == 1 {
+		c.resetIdle(false)
+	}
+}
+
+func (c *PollConnection) receiveEnd() {
+	if atomic.AddInt32(&c.receiving, -1) == 0 && !c.isClosed() {
+		c.resetIdle(true)
+	}
+}
+
+// resetIdle restarts the idle timer if start, otherwise stops it.
+func (c *PollConnection) resetIdle(start bool) {
+	c.idleMu.Lock()
+	defer c.idleMu.Unlock()
+	if start {
+		c.idle.Reset(c.options.IdleTimeout)
+	} else {
+		c.idle.Stop()
+	}
+}
+
+// recv responds the json array of messages, it waits for PollTimeout if there is no message.
+func (c *PollConnection) recv(w http.ResponseWriter, r *http.Request) {
+	c.receiveStart()
+	defer c.receiveEnd()
+
+	timer := time.NewTimer(c.options.PollTimeout)
+	defer timer.Stop()
+	var batch [][]byte
+	select {
+	case data := <-c.out:
+		batch = append(batch, data)
+	case <-timer.C:
+	case <-c.closed:
+	case <-r.Context().Done():
+		return
+	}
+DRAIN:
+	for len(batch) < pollBatchSize {
+		select {
+		case data := <-c.out:
+			batch = append(batch, data)
+		default:
+			break DRAIN
+		}
+	}
+	if len(batch) == 0 && c.isClosed() {
+		http.Error(w, "session closed", http.StatusGone)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, "[")
+	for i, data := range batch {
+		if i > 0 {
+			_, _ = io.WriteString(w, ",")
+		}
+		_, _ = w.Write(data)
+	}
+	_, _ = io.WriteString(w, "]")
+}
+
+// stream sends messages as server-sent events until the session closed or the request done.
+func (c *PollConnection) stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		c.recv(w, r)
+		return
+	}
+	c.receiveStart()
+	defer c.receiveEnd()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(c.options.PollTimeout)
+	defer keepalive.Stop()
+	for {
+		select {
+		case data := <-c.out:
+			// json messages are in one line.
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			_, _ = io.WriteString(w, ": keepalive\n\n")
+		case <-c.closed:
+			for {
+				select {
+				case data := <-c.out:
+					_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+				default:
+					flusher.Flush()
+					return
+				}
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// send passes the message in the body to Read.
+func (c *PollConnection) send(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, c.options.MaxMessageSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) > c.options.MaxMessageSize {
+		http.Error(w, ErrMessageTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	select {
+	case c.in <- data:
+		w.WriteHeader(http.StatusNoContent)
+	case <-c.closed:
+		http.Error(w, "session closed", http.StatusGone)
+	case <-r.Context().Done():
+	}
+}
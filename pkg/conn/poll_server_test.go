@@ -0,0 +1,111 @@
+package conn
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openPollSession(t *testing.T, srv *httptest.Server) string {
+	resp, err := http.Post(srv.URL+PollPath+"/open", "application/json", nil)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body := struct {
+		Sid string `json:"sid"`
+	}{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body.Sid
+}
+
+func TestPollServer(t *testing.T) {
+	s := NewPollServer(&PollServerOptions{PollTimeout: time.Millisecond * 100, MaxMessageSize: 64})
+	connected := make(chan Connection, 1)
+	s.SetConnHandler(func(c Connection) {
+		connected <- c
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	sid := openPollSession(t, srv)
+	c := <-connected
+	assert.Equal(t, 1, s.SessionCount())
+
+	// upstream.
+	resp, err := http.Post(srv.URL+PollPath+"/send?sid="+sid, "application/json", strings.NewReader(`{"action":"heartbeat"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	data, err := c.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"action":"heartbeat"}`, string(data))
+
+	resp, err = http.Post(srv.URL+PollPath+"/send?sid="+sid, "application/json", strings.NewReader(strings.Repeat("a", 65)))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	// downstream by long-polling.
+	assert.NoError(t, c.Write([]byte(`{"seq":1}`)))
+	assert.NoError(t, c.Write([]byte(`{"seq":2}`)))
+	resp, err = http.Get(srv.URL + PollPath + "/recv?sid=" + sid)
+	assert.NoError(t, err)
+	b, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `[{"seq":1},{"seq":2}]`, string(b))
+
+	// timeout without messages.
+	resp, err = http.Get(srv.URL + PollPath + "/recv?sid=" + sid)
+	assert.NoError(t, err)
+	b, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, `[]`, string(b))
+
+	// downstream by server-sent events.
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+PollPath+"/recv?sid="+sid, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Write([]byte(`{"seq":3}`)))
+	r := bufio.NewReader(resp.Body)
+	line, err := r.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "data: {\"seq\":3}\n", line)
+
+	resp, err = http.Post(srv.URL+PollPath+"/close?sid="+sid, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	_, err = c.Read()
+	assert.Equal(t, ErrConnectionClosed, err)
+	assert.Zero(t, s.SessionCount())
+
+	resp, err = http.Get(srv.URL + PollPath + "/recv?sid=" + sid)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestPollServer_Idle(t *testing.T) {
+	s := NewPollServer(&PollServerOptions{IdleTimeout: time.Millisecond * 50})
+	connected := make(chan Connection, 1)
+	s.SetConnHandler(func(c Connection) {
+		connected <- c
+	})
+	s.SetUpgradePolicy(&UpgradePolicy{AllowedOrigins: []string{"https://app.example.com"}})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+PollPath+"/open", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	openPollSession(t, srv)
+	c := <-connected
+	_, err = c.Read()
+	assert.Equal(t, ErrConnectionClosed, err)
+	assert.Zero(t, s.SessionCount())
+}
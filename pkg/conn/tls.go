@@ -0,0 +1,68 @@
+package conn
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+)
+
+type TLSOptions struct {
+	// CertFile and KeyFile the PEM encoded certificate and private key of the server.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile the PEM encoded CA certificates to verify client certificates, empty to disable mutual TLS.
+	ClientCAFile string
+	// RequireClientCert true to reject connections without a valid client certificate, otherwise the client
+	// certificate is verified if given.
+	RequireClientCert bool
+	// NextProtos the ALPN protocols, default http/1.1, websocket is not supported over h2.
+	NextProtos []string
+}
+
+// NewTLSConfig creates the tls config of the server listener.
+func NewTLSConfig(opts *TLSOptions) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   opts.NextProtos,
+		MinVersion:   tls.VersionTLS12,
+	}
+	if len(cfg.NextProtos) == 0 {
+		cfg.NextProtos = []string{"http/1.1"}
+	}
+	if opts.ClientCAFile == "" {
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(opts.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no valid certificate in client ca file")
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	if opts.RequireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// verifiedCertificates returns the client certificate chain of the connection if it's verified.
+func verifiedCertificates(c interface{}) []*x509.Certificate {
+	tc, ok := c.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	state := tc.ConnectionState()
+	if len(state.VerifiedChains) == 0 {
+		return nil
+	}
+	return state.PeerCertificates
+}
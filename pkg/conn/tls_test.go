@@ -0,0 +1,107 @@
+package conn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTLSConfig_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newTestCert(t, "ca", nil, nil)
+	server, serverKey := newTestCert(t, "server", ca, caKey)
+	client, clientKey := newTestCert(t, "client-1", ca, caKey)
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.Raw)
+	writePEM(t, filepath.Join(dir, "server.pem"), "CERTIFICATE", server.Raw)
+	keyBytes, _ := x509.MarshalECPrivateKey(serverKey)
+	writePEM(t, filepath.Join(dir, "server.key"), "EC PRIVATE KEY", keyBytes)
+
+	cfg, err := NewTLSConfig(&TLSOptions{
+		CertFile:          filepath.Join(dir, "server.pem"),
+		KeyFile:           filepath.Join(dir, "server.key"),
+		ClientCAFile:      filepath.Join(dir, "ca.pem"),
+		RequireClientCert: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, cfg.ClientAuth)
+
+	ws := NewWsServer(nil).(*WsServer)
+	infos := make(chan *ConnectionInfo, 1)
+	ws.SetConnHandler(func(conn Connection) {
+		infos <- conn.GetConnInfo()
+	})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(ws.handleWebSocketRequest))
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	dialer := websocket.Dialer{TLSClientConfig: &tls.Config{
+		RootCAs: roots,
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{client.Raw},
+			PrivateKey:  clientKey,
+		}},
+	}}
+	url := "wss" + strings.TrimPrefix(srv.URL, "https")
+	c, _, err := dialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	select {
+	case info := <-infos:
+		assert.Equal(t, "client-1", info.PeerCertificates[0].Subject.CommonName)
+	case <-time.After(time.Second):
+		t.Fatal("connection not handled")
+	}
+
+	// client without certificate is rejected.
+	dialer.TLSClientConfig.Certificates = nil
+	_, _, err = dialer.Dial(url, nil)
+	assert.Error(t, err)
+}
+
+func newTestCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		tpl.IsCA = true
+		tpl.BasicConstraintsValid = true
+		parent, parentKey = tpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert, key
+}
+
+func writePEM(t *testing.T, path string, typ string, b []byte) {
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0600)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,146 @@
+package conn
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// SubprotocolJsonV1 the versioned json text subprotocol, same as SubprotocolJson.
+	SubprotocolJsonV1 = "glide.v1.json"
+	// SubprotocolProtoV1 the versioned binary subprotocol, same as SubprotocolBinary.
+	SubprotocolProtoV1 = "glide.v1.proto"
+)
+
+// Reasons of the upgrade request rejected by UpgradePolicy.
+const (
+	RejectReasonOrigin      = "origin"
+	RejectReasonSubprotocol = "subprotocol"
+)
+
+// subprotocols the subprotocols supported in the order of preference, binary first as it's compact.
+var subprotocols = []string{SubprotocolProtoV1, SubprotocolBinary, SubprotocolJsonV1, SubprotocolJson}
+
+// IsBinarySubprotocol returns true if messages of the subprotocol are binary encoded, see messages.BinaryCodec.
+func IsBinarySubprotocol(subprotocol string) bool {
+	return subprotocol == SubprotocolBinary || subprotocol == SubprotocolProtoV1
+}
+
+// UpgradePolicy validates websocket upgrade requests before upgraded, it protects browser deployments from cross-site
+// websocket hijacking by Origin, and rejects clients which speak no supported subprotocol.
+type UpgradePolicy struct {
+	// AllowedOrigins the origins allowed to connect, such as https://app.example.com, "https://*.example.com" matches
+	// subdomains, "*" matches all. Requests without Origin are not from browsers and always allowed, all origins are
+	// allowed if it's empty.
+	AllowedOrigins []string
+	// RequireSubprotocol rejects requests which do not request any supported subprotocol.
+	RequireSubprotocol bool
+	// OnReject called when the request is rejected with the reason, such as RejectReasonOrigin, optional.
+	OnReject func(r *http.Request, reason string)
+}
+
+// Check returns the reason and the http status if the request is rejected, the reason is empty if it's accepted.
+func (p *UpgradePolicy) Check(r *http.Request) (reason string, status int) {
+	if p == nil {
+		return "", 0
+	}
+	if !p.allowOrigin(r.Header.Get("Origin")) {
+		reason, status = RejectReasonOrigin, http.StatusForbidden
+	} else if p.RequireSubprotocol && selectSubprotocol(r) == "" {
+		reason, status = RejectReasonSubprotocol, http.StatusBadRequest
+	}
+	if reason != "" && p.OnReject != nil {
+		p.OnReject(r, reason)
+	}
+	return reason, status
+}
+
+func (p *UpgradePolicy) allowOrigin(origin string) bool {
+	if origin == "" || len(p.AllowedOrigins) == 0 {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, allowed := range p.AllowedOrigins {
+		if matchOrigin(allowed, u) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin returns true if the origin matches the pattern, the host of pattern starts with *. matches subdomains.
+func matchOrigin(pattern string, origin *url.URL) bool {
+	if pattern == "*" {
+		return true
+	}
+	p, err := url.Parse(pattern)
+	if err != nil || !strings.EqualFold(p.Scheme, origin.Scheme) {
+		return false
+	}
+	if strings.HasPrefix(p.Host, "*.") {
+		return strings.HasSuffix(strings.ToLower(origin.Host), strings.ToLower(p.Host[1:]))
+	}
+	return strings.EqualFold(p.Host, origin.Host)
+}
+
+// selectSubprotocol returns the supported subprotocol requested by the client, empty if none.
+func selectSubprotocol(r *http.Request) string {
+	requested := websocket.Subprotocols(r)
+	for _, s := range subprotocols {
+		for _, req := range requested {
+			if s == req {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// newUpgrader returns the upgrader of supported subprotocols, origins are checked by UpgradePolicy before upgrade.
+func newUpgrader() websocket.Upgrader {
+	return websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 65536,
+		Subprotocols:    subprotocols,
+		CheckOrigin: func(r *http.Request) bool {
+			return true
+		},
+	}
+}
+
+// upgradeChecker the server validates requests by UpgradePolicy, embedded by WsServer, NetpollServer and PollServer.
+type upgradeChecker struct {
+	policy *UpgradePolicy
+}
+
+// SetUpgradePolicy sets the policy to validate upgrade requests, it must be called before Run.
+func (u *upgradeChecker) SetUpgradePolicy(p *UpgradePolicy) {
+	u.policy = p
+}
+
+// allowOrigin returns true if the origin of the request is allowed by the policy, used by the servers without upgrade.
+func (u *upgradeChecker) allowOrigin(r *http.Request) bool {
+	if u.policy == nil || u.policy.allowOrigin(r.Header.Get("Origin")) {
+		return true
+	}
+	if u.policy.OnReject != nil {
+		u.policy.OnReject(r, RejectReasonOrigin)
+	}
+	return false
+}
+
+// reject responds the error if the request is rejected by the policy, returns true if rejected.
+func (u *upgradeChecker) reject(w http.ResponseWriter, r *http.Request) bool {
+	reason, status := u.policy.Check(r)
+	if reason == "" {
+		return false
+	}
+	http.Error(w, "upgrade rejected: "+reason, status)
+	return true
+}
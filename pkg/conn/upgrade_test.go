@@ -0,0 +1,82 @@
+package conn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func upgradeRequest(origin string, subprotocols ...string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	if len(subprotocols) > 0 {
+		r.Header.Set("Sec-WebSocket-Protocol", strings.Join(subprotocols, ", "))
+	}
+	return r
+}
+
+func TestUpgradePolicy_Check(t *testing.T) {
+	var rejected []string
+	p := &UpgradePolicy{
+		AllowedOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		RequireSubprotocol: true,
+		OnReject: func(r *http.Request, reason string) {
+			rejected = append(rejected, reason)
+		},
+	}
+
+	reason, _ := p.Check(upgradeRequest("https://app.example.com", SubprotocolJsonV1))
+	assert.Empty(t, reason)
+	reason, _ = p.Check(upgradeRequest("https://chat.Example.org", SubprotocolProtoV1))
+	assert.Empty(t, reason)
+	// not from browsers.
+	reason, _ = p.Check(upgradeRequest("", SubprotocolJson))
+	assert.Empty(t, reason)
+
+	reason, status := p.Check(upgradeRequest("https://evil.com", SubprotocolJsonV1))
+	assert.Equal(t, RejectReasonOrigin, reason)
+	assert.Equal(t, http.StatusForbidden, status)
+	reason, _ = p.Check(upgradeRequest("http://app.example.com", SubprotocolJsonV1))
+	assert.Equal(t, RejectReasonOrigin, reason)
+
+	reason, status = p.Check(upgradeRequest("https://app.example.com", "chat.v2"))
+	assert.Equal(t, RejectReasonSubprotocol, reason)
+	assert.Equal(t, http.StatusBadRequest, status)
+	reason, _ = p.Check(upgradeRequest("https://app.example.com"))
+	assert.Equal(t, RejectReasonSubprotocol, reason)
+
+	assert.Equal(t, []string{RejectReasonOrigin, RejectReasonOrigin, RejectReasonSubprotocol, RejectReasonSubprotocol}, rejected)
+
+	var nilPolicy *UpgradePolicy
+	reason, _ = nilPolicy.Check(upgradeRequest("https://evil.com"))
+	assert.Empty(t, reason)
+}
+
+func TestWsServer_Upgrade(t *testing.T) {
+	ws := NewWsServer(nil).(*WsServer)
+	ws.SetUpgradePolicy(&UpgradePolicy{AllowedOrigins: []string{"https://app.example.com"}})
+	connected := make(chan Connection, 1)
+	ws.SetConnHandler(func(c Connection) {
+		connected <- c
+	})
+	srv := httptest.NewServer(http.HandlerFunc(ws.handleWebSocketRequest))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolJsonV1, SubprotocolProtoV1}}
+	_, resp, err := dialer.Dial(url, http.Header{"Origin": {"https://evil.com"}})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	c, _, err := dialer.Dial(url, http.Header{"Origin": {"https://app.example.com"}})
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, SubprotocolProtoV1, c.Subprotocol())
+	assert.True(t, IsBinarySubprotocol((<-connected).GetConnInfo().Subprotocol))
+}
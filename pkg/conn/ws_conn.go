@@ -1,15 +1,18 @@
 package conn
 
 import (
+	"github.com/glide-im/glide/pkg/pool"
 	"github.com/gorilla/websocket"
+	"io"
 	"net"
 	"strings"
 	"time"
 )
 
 type WsConnection struct {
-	options *WsServerOptions
-	conn    *websocket.Conn
+	options     *WsServerOptions
+	conn        *websocket.Conn
+	fingerprint *Fingerprint
 }
 
 func NewWsConnection(conn *websocket.Conn, options *WsServerOptions) *WsConnection {
@@ -26,7 +29,11 @@ func (c *WsConnection) Write(data []byte) error {
 	deadLine := time.Now().Add(c.options.WriteTimeout)
 	_ = c.conn.SetWriteDeadline(deadLine)
 
-	err := c.conn.WriteMessage(websocket.TextMessage, data)
+	msgType := websocket.TextMessage
+	if IsBinarySubprotocol(c.conn.Subprotocol()) {
+		msgType = websocket.BinaryMessage
+	}
+	err := c.conn.WriteMessage(msgType, data)
 	return c.wrapError(err)
 }
 
@@ -35,7 +42,7 @@ func (c *WsConnection) Read() ([]byte, error) {
 	deadLine := time.Now().Add(c.options.ReadTimeout)
 	_ = c.conn.SetReadDeadline(deadLine)
 
-	msgType, bytes, err := c.conn.ReadMessage()
+	msgType, r, err := c.conn.NextReader()
 	if err != nil {
 		return nil, c.wrapError(err)
 	}
@@ -48,7 +55,21 @@ func (c *WsConnection) Read() ([]byte, error) {
 		return nil, ErrBadPackage
 	}
 
-	return bytes, err
+	if c.options.MaxMessageSize > 0 {
+		// the rest of the message too large is discarded by the next NextReader.
+		r = io.LimitReader(r, c.options.MaxMessageSize+1)
+	}
+	// read into the pooled buffer, only the exact size result is allocated.
+	buf := pool.GetBuffer()
+	defer pool.PutBuffer(buf)
+	_, err = buf.ReadFrom(r)
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	if c.options.MaxMessageSize > 0 && int64(buf.Len()) > c.options.MaxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+	return append(make([]byte, 0, buf.Len()), buf.Bytes()...), nil
 }
 
 func (c *WsConnection) Close() error {
@@ -56,12 +77,15 @@ func (c *WsConnection) Close() error {
 }
 
 func (c *WsConnection) GetConnInfo() *ConnectionInfo {
-	c.conn.UnderlyingConn()
 	remoteAddr := c.conn.RemoteAddr().(*net.TCPAddr)
 	info := ConnectionInfo{
 		Ip:   remoteAddr.IP.String(),
 		Port: remoteAddr.Port,
 		Addr: c.conn.RemoteAddr().String(),
+
+		Subprotocol:      c.conn.Subprotocol(),
+		PeerCertificates: verifiedCertificates(c.conn.UnderlyingConn()),
+		Fingerprint:      c.fingerprint,
 	}
 	return &info
 }
@@ -0,0 +1,121 @@
+package conn
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// opcodes of websocket frame, see RFC 6455 section 5.2.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+const maxControlPayload = 125
+
+var (
+	errFrameNotMasked   = errors.New("websocket frame from client is not masked")
+	errBadControlFrame  = errors.New("bad websocket control frame")
+	errUnexpectedOpcode = errors.New("unexpected websocket opcode")
+)
+
+type wsFrame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+func (f *wsFrame) isControl() bool {
+	return f.opcode >= opClose
+}
+
+// readFrame reads exactly one frame sent by client from r, the reader must not be buffered, so that the data not
+// read yet is left in the socket and notified by the poller. The payload larger than maxSize is rejected.
+func readFrame(r io.Reader, maxSize int64) (*wsFrame, error) {
+	var header [14]byte
+	if _, err := io.ReadFull(r, header[:2]); err != nil {
+		return nil, err
+	}
+	f := &wsFrame{
+		fin:    header[0]&0x80 != 0,
+		opcode: header[0] & 0x0F,
+	}
+	if header[1]&0x80 == 0 {
+		return nil, errFrameNotMasked
+	}
+	length := int64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		if _, err := io.ReadFull(r, header[2:4]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		if _, err := io.ReadFull(r, header[2:10]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(header[2:10]))
+	}
+	if f.isControl() && (length > maxControlPayload || !f.fin) {
+		return nil, errBadControlFrame
+	}
+	if length < 0 || length > maxSize {
+		return nil, ErrMessageTooLarge
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return nil, err
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return nil, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// messageAssembler joins fragmented data frames to a message.
+type messageAssembler struct {
+	maxSize int64
+	opcode  byte
+	data    []byte
+}
+
+// add adds the data frame, returns the message when the final frame added.
+func (a *messageAssembler) add(f *wsFrame) ([]byte, bool, error) {
+	switch f.opcode {
+	case opText, opBinary:
+		if a.data != nil {
+			return nil, false, errUnexpectedOpcode
+		}
+		if f.fin {
+			return f.payload, true, nil
+		}
+		a.opcode = f.opcode
+		a.data = f.payload
+	case opContinuation:
+		if a.data == nil {
+			return nil, false, errUnexpectedOpcode
+		}
+		if int64(len(a.data)+len(f.payload)) > a.maxSize {
+			return nil, false, ErrMessageTooLarge
+		}
+		a.data = append(a.data, f.payload...)
+		if f.fin {
+			data := a.data
+			a.data = nil
+			return data, true, nil
+		}
+	default:
+		return nil, false, errUnexpectedOpcode
+	}
+	return nil, false, nil
+}
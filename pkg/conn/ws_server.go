@@ -1,21 +1,35 @@
 package conn
 
 import (
+	"crypto/tls"
 	"fmt"
 	"github.com/gorilla/websocket"
 	"net/http"
 	"time"
 )
 
+const (
+	// SubprotocolJson the default json text subprotocol.
+	SubprotocolJson = "glide.json"
+	// SubprotocolBinary the compact binary subprotocol for constrained clients, see messages.BinaryCodec.
+	SubprotocolBinary = "glide.bin.v1"
+)
+
 type WsServerOptions struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+	// MaxMessageSize the max size of a message from client in bytes, the larger message is discarded and Read
+	// returns ErrMessageTooLarge, 0 for unlimited.
+	MaxMessageSize int64
 }
 
 type WsServer struct {
-	options  *WsServerOptions
-	upgrader websocket.Upgrader
-	handler  ConnectionHandler
+	upgradeChecker
+	options   *WsServerOptions
+	upgrader  websocket.Upgrader
+	handler   ConnectionHandler
+	tlsConfig *tls.Config
+	recorder  *fingerprintRecorder
 }
 
 // NewWsServer options can be nil, use default value when nil.
@@ -29,26 +43,29 @@ func NewWsServer(options *WsServerOptions) Server {
 	}
 	ws := new(WsServer)
 	ws.options = options
-	ws.upgrader = websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 65536,
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
-	}
+	ws.upgrader = newUpgrader()
+	ws.recorder = newFingerprintRecorder()
 	return ws
 }
 
 func (ws *WsServer) handleWebSocketRequest(writer http.ResponseWriter, request *http.Request) {
 
+	if ws.reject(writer, request) {
+		return
+	}
+
+	// the record of connection is removed once hijacked by upgrade.
+	fingerprint := ws.recorder.fingerprint(request)
 	conn, err := ws.upgrader.Upgrade(writer, request, nil)
 	if err != nil {
 		// logger.E("upgrade http to ws error", err)
 		return
 	}
 
+	wsConn := NewWsConnection(conn, ws.options)
+	wsConn.fingerprint = fingerprint
 	proxy := ConnectionProxy{
-		conn: NewWsConnection(conn, ws.options),
+		conn: wsConn,
 	}
 	ws.handler(proxy)
 }
@@ -57,13 +74,43 @@ func (ws *WsServer) SetConnHandler(handler ConnectionHandler) {
 	ws.handler = handler
 }
 
+// SetTLSConfig serves websocket over TLS with the config, see NewTLSConfig.
+func (ws *WsServer) SetTLSConfig(cfg *tls.Config) {
+	ws.tlsConfig = cfg
+}
+
 func (ws *WsServer) Run(host string, port int) error {
 
-	http.HandleFunc("/ws", ws.handleWebSocketRequest)
+	mux := serveMux(ws.handleWebSocketRequest)
 
 	addr := fmt.Sprintf("%s:%d", host, port)
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	if ws.tlsConfig != nil {
+		server := &http.Server{
+			Addr:      addr,
+			Handler:   mux,
+			TLSConfig: ws.recorder.tlsConfig(ws.tlsConfig),
+			ConnState: ws.recorder.connState,
+			// disable h2, websocket upgrade is not supported over it.
+			TLSNextProto: map[string]func(*http.Server, *tls.Conn, http.Handler){},
+		}
+		return server.ListenAndServeTLS("", "")
+	}
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   mux,
+		ConnState: ws.recorder.connState,
+	}
+	if err := server.ListenAndServe(); err != nil {
 		return err
 	}
 	return nil
 }
+
+// serveMux serves websocket at /ws, other paths fall back to http.DefaultServeMux, such as the long-polling endpoints.
+// The mux is created per server, so servers in the same process do not register /ws twice.
+func serveMux(ws http.HandlerFunc) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", ws)
+	mux.Handle("/", http.DefaultServeMux)
+	return mux
+}
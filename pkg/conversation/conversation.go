@@ -0,0 +1,159 @@
+package conversation
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/messages"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Type is the type of conversation.
+type Type int32
+
+const (
+	TypeUnknown Type = 0
+	// TypeP2P point-to-point conversation between two users.
+	TypeP2P Type = 1
+	// TypeChannel conversation of channel, the participants are subscribers of the channel.
+	TypeChannel Type = 2
+)
+
+// idSeparator separates the type and the id part of the conversation ID.
+const idSeparator = ":"
+
+var (
+	ErrInvalidID = errors.New("invalid conversation id")
+)
+
+// ID uniquely identifies a conversation, it's formatted as `<type>:<id>`.
+type ID string
+
+// Type returns the type part of the ID.
+func (i ID) Type() Type {
+	t, _, _ := i.split()
+	return t
+}
+
+// Target returns the id part of the ID, the channel id for channel conversation.
+func (i ID) Target() string {
+	_, s, _ := i.split()
+	return s
+}
+
+func (i ID) split() (Type, string, error) {
+	s := strings.SplitN(string(i), idSeparator, 2)
+	if len(s) != 2 {
+		return TypeUnknown, "", ErrInvalidID
+	}
+	t, err := strconv.ParseInt(s[0], 10, 32)
+	if err != nil {
+		return TypeUnknown, "", ErrInvalidID
+	}
+	return Type(t), s[1], nil
+}
+
+// Conversation is a conversation of participants, messages sent to conversation are delivered to all participants.
+type Conversation struct {
+	Type Type
+	ID   ID
+	// Participants of the conversation, empty for channel conversation, which participants are channel subscribers.
+	Participants []string
+}
+
+// NewID creates conversation ID with type and target.
+func NewID(t Type, target string) ID {
+	return ID(strconv.FormatInt(int64(t), 10) + idSeparator + target)
+}
+
+// NewP2P creates point-to-point conversation of two users, the ID is same regardless of the order of users.
+func NewP2P(uid1, uid2 string) *Conversation {
+	lg, sm := uid1, uid2
+	if less(lg, sm) {
+		lg, sm = sm, lg
+	}
+	return &Conversation{
+		Type:         TypeP2P,
+		ID:           NewID(TypeP2P, lg+"_"+sm),
+		Participants: []string{uid1, uid2},
+	}
+}
+
+// NewChannel creates channel conversation.
+func NewChannel(channelID string) *Conversation {
+	return &Conversation{
+		Type: TypeChannel,
+		ID:   NewID(TypeChannel, channelID),
+	}
+}
+
+// Peer returns the participant other than uid in point-to-point conversation.
+func (c *Conversation) Peer(uid string) string {
+	for _, p := range c.Participants {
+		if p != uid {
+			return p
+		}
+	}
+	return uid
+}
+
+// less compares uid numerically if both are numbers, otherwise compares as string.
+func less(a, b string) bool {
+	ia, err1 := strconv.ParseInt(a, 10, 64)
+	ib, err2 := strconv.ParseInt(b, 10, 64)
+	if err1 == nil && err2 == nil {
+		return ia < ib
+	}
+	return a < b
+}
+
+var (
+	mu          sync.RWMutex
+	actionTypes = map[messages.Action]Type{
+		messages.ActionChatMessage:       TypeP2P,
+		messages.ActionChatMessageResend: TypeP2P,
+		messages.ActionStateMessage:      TypeP2P,
+		messages.ActionMessageRecall:     TypeP2P,
+		messages.ActionMessageEdit:       TypeP2P,
+		messages.ActionMessageRead:       TypeP2P,
+
+		messages.ActionGroupMessage:      TypeChannel,
+		messages.ActionGroupStateMessage: TypeChannel,
+		messages.ActionGroupRecall:       TypeChannel,
+		messages.ActionGroupMessageEdit:  TypeChannel,
+		messages.ActionGroupMessageRead:  TypeChannel,
+
+		messages.ActionMessageReact:        TypeP2P,
+		messages.ActionMessageUnreact:      TypeP2P,
+		messages.ActionGroupMessageReact:   TypeChannel,
+		messages.ActionGroupMessageUnreact: TypeChannel,
+	}
+)
+
+// RegisterAction registers the conversation type of the message action, used to add new conversation type.
+func RegisterAction(action messages.Action, t Type) {
+	mu.Lock()
+	defer mu.Unlock()
+	actionTypes[action] = t
+}
+
+// TypeOf returns the conversation type of message action, TypeUnknown if action is not registered.
+func TypeOf(action messages.Action) Type {
+	mu.RLock()
+	defer mu.RUnlock()
+	return actionTypes[action]
+}
+
+// Of returns the conversation of the message sent from uid.
+func Of(from string, m *messages.GlideMessage) (*Conversation, error) {
+	switch TypeOf(m.GetAction()) {
+	case TypeP2P:
+		return NewP2P(from, m.To), nil
+	case TypeChannel:
+		return NewChannel(m.To), nil
+	case TypeUnknown:
+		return nil, errors.New("unknown conversation type of action: " + m.Action)
+	default:
+		return &Conversation{Type: TypeOf(m.GetAction()), ID: NewID(TypeOf(m.GetAction()), m.To)}, nil
+	}
+}
@@ -0,0 +1,26 @@
+package conversation
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewP2P(t *testing.T) {
+	c1 := NewP2P("2", "10")
+	c2 := NewP2P("10", "2")
+	assert.Equal(t, c1.ID, c2.ID)
+	assert.Equal(t, TypeP2P, c1.ID.Type())
+	assert.Equal(t, "10_2", c1.ID.Target())
+	assert.Equal(t, "10", c1.Peer("2"))
+}
+
+func TestOf(t *testing.T) {
+	c, err := Of("1", &messages.GlideMessage{Action: string(messages.ActionGroupMessage), To: "100"})
+	assert.NoError(t, err)
+	assert.Equal(t, TypeChannel, c.Type)
+	assert.Equal(t, "100", c.ID.Target())
+
+	_, err = Of("1", &messages.GlideMessage{Action: string(messages.ActionHeartbeat)})
+	assert.Error(t, err)
+}
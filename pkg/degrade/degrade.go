@@ -0,0 +1,270 @@
+// Package degrade switches the service to the degraded mode when dependencies such as the store, redis or the broker
+// are unhealthy, features depend on them are disabled instead of erroring every message, such as messages are
+// delivered to online users only without persisted.
+package degrade
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/metrics"
+	"github.com/glide-im/glide/pkg/webhook"
+)
+
+var log = logger.Named("degrade")
+
+const (
+	defaultInterval = time.Second * 5
+	defaultFailures = 3
+	dialTimeout     = time.Second * 3
+)
+
+// Feature the features disabled in degraded mode.
+type Feature int
+
+const (
+	// FeatureOffline stores offline messages, messages are delivered to online users only if disabled.
+	FeatureOffline Feature = 1 << iota
+	// FeaturePersistence stores message history.
+	FeaturePersistence
+	// FeatureHistory the APIs query message history.
+	FeatureHistory
+
+	FeatureAll = FeatureOffline | FeaturePersistence | FeatureHistory
+)
+
+var featureNames = map[string]Feature{
+	"offline":     FeatureOffline,
+	"persistence": FeaturePersistence,
+	"history":     FeatureHistory,
+}
+
+// ParseFeatures parses feature names: offline, persistence and history.
+func ParseFeatures(names []string) (Feature, error) {
+	var f Feature
+	for _, name := range names {
+		v, ok := featureNames[name]
+		if !ok {
+			return 0, errors.New("unknown degradable feature: " + name)
+		}
+		f |= v
+	}
+	return f, nil
+}
+
+// Check checks the health of a dependency, returns nil if healthy.
+type Check func() error
+
+// DialCheck returns the Check healthy if any of addresses accepts tcp connections, such as kafka brokers.
+func DialCheck(addresses ...string) Check {
+	return func() error {
+		var err error
+		for _, addr := range addresses {
+			var c net.Conn
+			c, err = net.DialTimeout("tcp", addr, dialTimeout)
+			if err == nil {
+				_ = c.Close()
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// Status the health status of a dependency.
+type Status struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+	// Since the unix seconds the status changed.
+	Since int64 `json:"since"`
+}
+
+type Options struct {
+	// Interval the interval of checking dependencies, default 5s.
+	Interval time.Duration
+	// Failures the count of consecutive failed checks marks the dependency unhealthy, default 3.
+	Failures int
+	// Disable the features disabled in degraded mode, default FeatureAll.
+	Disable Feature
+}
+
+type dependency struct {
+	check    Check
+	failures int
+	status   Status
+}
+
+// Controller checks dependencies periodically, the service is degraded if any dependency is unhealthy. Changes of
+// status are logged, emitted as webhook.EventHealthChanged and notified to listeners.
+type Controller struct {
+	opts Options
+
+	mu        sync.RWMutex
+	deps      map[string]*dependency
+	listeners []func(s Status, degraded bool)
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+func New(opts Options) *Controller {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	if opts.Failures <= 0 {
+		opts.Failures = defaultFailures
+	}
+	if opts.Disable == 0 {
+		opts.Disable = FeatureAll
+	}
+	return &Controller{
+		opts: opts,
+		deps: map[string]*dependency{},
+		stop: make(chan struct{}),
+	}
+}
+
+// Register adds the dependency checked by check, the dependency is healthy until checks failed.
+func (c *Controller) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deps[name] = &dependency{
+		check:  check,
+		status: Status{Name: name, Healthy: true, Since: time.Now().Unix()},
+	}
+}
+
+// OnChange adds the listener called when the health status of a dependency changed.
+func (c *Controller) OnChange(fn func(s Status, degraded bool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, fn)
+}
+
+// Start checks dependencies every Interval in background.
+func (c *Controller) Start() {
+	go func() {
+		ticker := time.NewTicker(c.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.CheckNow()
+			}
+		}
+	}()
+}
+
+func (c *Controller) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+// CheckNow checks all dependencies.
+func (c *Controller) CheckNow() {
+	c.mu.RLock()
+	deps := make(map[string]*dependency, len(c.deps))
+	for name, d := range c.deps {
+		deps[name] = d
+	}
+	c.mu.RUnlock()
+
+	for _, d := range deps {
+		c.update(d, d.check())
+	}
+}
+
+func (c *Controller) update(d *dependency, err error) {
+	c.mu.Lock()
+	if err == nil {
+		d.failures = 0
+	} else {
+		d.failures++
+	}
+	healthy := err == nil || (d.status.Healthy && d.failures < c.opts.Failures)
+	if !healthy {
+		d.status.Error = err.Error()
+	}
+	if healthy == d.status.Healthy {
+		c.mu.Unlock()
+		return
+	}
+	d.status.Healthy = healthy
+	d.status.Since = time.Now().Unix()
+	if healthy {
+		d.status.Error = ""
+	}
+	s := d.status
+	degraded := c.degraded()
+	listeners := c.listeners
+	c.mu.Unlock()
+
+	if healthy {
+		log.I("dependency %s recovered, degraded: %v", s.Name, degraded)
+	} else {
+		log.W("dependency %s is unhealthy: %s, degraded: %v", s.Name, s.Error, degraded)
+	}
+	if degraded {
+		metrics.Degraded.Set(1)
+	} else {
+		metrics.Degraded.Set(0)
+	}
+	webhook.Emit(webhook.EventHealthChanged, &webhook.HealthEventData{
+		Dependency: s.Name,
+		Healthy:    s.Healthy,
+		Error:      s.Error,
+		Degraded:   degraded,
+	})
+	for _, fn := range listeners {
+		fn(s, degraded)
+	}
+}
+
+func (c *Controller) degraded() bool {
+	for _, d := range c.deps {
+		if !d.status.Healthy {
+			return true
+		}
+	}
+	return false
+}
+
+// Degraded returns true if any dependency is unhealthy.
+func (c *Controller) Degraded() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.degraded()
+}
+
+// Disabled returns true if the feature is disabled by the degraded mode, it's safe to call on nil Controller.
+func (c *Controller) Disabled(f Feature) bool {
+	if c == nil {
+		return false
+	}
+	return c.opts.Disable&f != 0 && c.Degraded()
+}
+
+// Status returns status of all dependencies ordered by name.
+func (c *Controller) Status() []Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ret := make([]Status, 0, len(c.deps))
+	for _, d := range c.deps {
+		ret = append(ret, d.status)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
@@ -0,0 +1,68 @@
+package degrade
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestController(t *testing.T) {
+	var checkErr error
+	ctl := New(Options{Failures: 2, Disable: FeatureOffline | FeatureHistory})
+	ctl.Register("store", func() error { return checkErr })
+	var changes []Status
+	ctl.OnChange(func(s Status, degraded bool) {
+		assert.Equal(t, !s.Healthy, degraded)
+		changes = append(changes, s)
+	})
+
+	checkErr = errors.New("down")
+	ctl.CheckNow()
+	assert.False(t, ctl.Degraded())
+	ctl.CheckNow()
+	assert.True(t, ctl.Degraded())
+	assert.True(t, ctl.Disabled(FeatureOffline))
+	assert.False(t, ctl.Disabled(FeaturePersistence))
+	assert.Equal(t, []Status{{Name: "store", Healthy: false, Error: "down", Since: ctl.Status()[0].Since}}, ctl.Status())
+
+	checkErr = nil
+	ctl.CheckNow()
+	assert.False(t, ctl.Degraded())
+	assert.False(t, ctl.Disabled(FeatureOffline))
+	assert.Len(t, changes, 2)
+	assert.True(t, changes[1].Healthy)
+
+	var nilCtl *Controller
+	assert.False(t, nilCtl.Disabled(FeatureAll))
+}
+
+func TestParseFeatures(t *testing.T) {
+	f, err := ParseFeatures([]string{"offline", "history"})
+	assert.NoError(t, err)
+	assert.Equal(t, FeatureOffline|FeatureHistory, f)
+	_, err = ParseFeatures([]string{"unknown"})
+	assert.Error(t, err)
+}
+
+func TestStore(t *testing.T) {
+	var checkErr error
+	ctl := New(Options{Failures: 1})
+	ctl.Register("store", func() error { return checkErr })
+	ms := storetest.NewMessageStore()
+	s := NewStore(ms, ctl)
+
+	assert.NoError(t, s.StoreMessage(&messages.ChatMessage{From: "1", To: "2"}))
+	assert.Len(t, ms.Messages(), 1)
+
+	checkErr = errors.New("down")
+	ctl.CheckNow()
+	m := &messages.ChatMessage{From: "1", To: "2"}
+	assert.NoError(t, s.StoreMessage(m))
+	assert.NotZero(t, m.Mid)
+	assert.NoError(t, s.StoreOffline(m))
+	assert.Len(t, ms.Messages(), 1)
+	assert.Empty(t, ms.Offline("2"))
+}
@@ -0,0 +1,85 @@
+package degrade
+
+import (
+	"errors"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/snowflake"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+)
+
+var errSequenceNotSupported = errors.New("the store does not allocate channel sequences")
+
+var _ store.MessageStore = (*Store)(nil)
+var _ store.SubscriptionStore = (*Store)(nil)
+var _ store.BatchMessageStore = (*Store)(nil)
+
+// Store skips writing messages while FeaturePersistence or FeatureOffline is disabled by the degraded mode, messages
+// are assigned the id and acked as stored, and delivered to online receivers only.
+type Store struct {
+	store store.MessageStore
+	ctl   *Controller
+}
+
+func NewStore(s store.MessageStore, ctl *Controller) *Store {
+	return &Store{store: s, ctl: ctl}
+}
+
+func (s *Store) Unwrap() store.MessageStore {
+	return s.store
+}
+
+func (s *Store) StoreMessage(message *messages.ChatMessage) error {
+	if s.ctl.Disabled(FeaturePersistence) {
+		if message.Mid == 0 {
+			message.Mid = snowflake.Generate()
+		}
+		return nil
+	}
+	return s.store.StoreMessage(message)
+}
+
+func (s *Store) StoreMessages(ms []*messages.ChatMessage) error {
+	if s.ctl.Disabled(FeaturePersistence) {
+		for _, m := range ms {
+			_ = s.StoreMessage(m)
+		}
+		return nil
+	}
+	if bs, ok := s.store.(store.BatchMessageStore); ok {
+		return bs.StoreMessages(ms)
+	}
+	for _, m := range ms {
+		if err := s.store.StoreMessage(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) StoreOffline(message *messages.ChatMessage) error {
+	if s.ctl.Disabled(FeatureOffline) {
+		return nil
+	}
+	return s.store.StoreOffline(message)
+}
+
+func (s *Store) StoreChannelMessage(ch subscription.ChanID, msg *messages.ChatMessage) error {
+	ss, ok := s.store.(store.SubscriptionStore)
+	if !ok {
+		return nil
+	}
+	if s.ctl.Disabled(FeaturePersistence) {
+		return nil
+	}
+	return ss.StoreChannelMessage(ch, msg)
+}
+
+func (s *Store) NextSegmentSequence(id subscription.ChanID, info subscription.ChanInfo) (int64, int64, error) {
+	ss, ok := s.store.(store.SubscriptionStore)
+	if !ok {
+		return 0, 0, errSequenceNotSupported
+	}
+	return ss.NextSegmentSequence(id, info)
+}
@@ -0,0 +1,240 @@
+// Package diag serves the opt-in diagnostics endpoints for debugging production issues such as memory growth.
+//
+//	GET /debug/pprof/  the runtime profiles, see net/http/pprof
+//	GET /debug/dump    the glide specific dump, see Dump
+package diag
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/subscription"
+)
+
+const (
+	defaultQueueThreshold = 100
+	defaultTopChannels    = 20
+	modulePrefix          = "github.com/glide-im/glide/"
+	subsystemOther        = "other"
+)
+
+// SlowClients returns the slow clients flagged, such as gate.Watchdog.
+type SlowClients interface {
+	Slow() []gate.SlowClient
+}
+
+// ClientQueue the send queue depth of the client.
+type ClientQueue struct {
+	ID         gate.ID `json:"id"`
+	QueueDepth int64   `json:"queue_depth"`
+}
+
+// ChannelSize the subscriber count of the channel.
+type ChannelSize struct {
+	ID      subscription.ChanID `json:"id"`
+	Members int                 `json:"members"`
+}
+
+// Memory the memory statistics of the runtime, in bytes.
+type Memory struct {
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapInuse   uint64 `json:"heap_inuse"`
+	HeapObjects uint64 `json:"heap_objects"`
+	Sys         uint64 `json:"sys"`
+	NumGC       uint32 `json:"num_gc"`
+}
+
+// Dump the snapshot of the runtime and glide components.
+type Dump struct {
+	Goroutines int `json:"goroutines"`
+	// GoroutinesBySubsystem the count of goroutines by the glide package running or created them, goroutines not
+	// belong to glide are counted as "other".
+	GoroutinesBySubsystem map[string]int `json:"goroutines_by_subsystem"`
+	Memory                Memory         `json:"memory"`
+	Clients               int            `json:"clients"`
+	// QueuedClients the clients whose send queue depth is at or above the threshold, deepest first.
+	QueuedClients []ClientQueue     `json:"queued_clients"`
+	SlowClients   []gate.SlowClient `json:"slow_clients"`
+	Channels      int               `json:"channels"`
+	Subscriptions int               `json:"subscriptions"`
+	// LargestChannels the channels of most subscribers.
+	LargestChannels []ChannelSize `json:"largest_channels"`
+}
+
+type Options struct {
+	// Addr the address of the http server listen on.
+	Addr string
+	// Token the bearer token required in the Authorization header, empty to not authenticate, the server should
+	// listen on a private address then.
+	Token string
+	// QueueThreshold the min send queue depth of clients dumped, default 100.
+	QueueThreshold int64
+}
+
+// Server serves pprof and the dump.
+type Server struct {
+	addr           string
+	token          string
+	queueThreshold int64
+	mux            *http.ServeMux
+
+	gateway      gate.DefaultGateway
+	slowClients  SlowClients
+	subscription subscription.Interface
+}
+
+func NewServer(opts *Options) *Server {
+	threshold := opts.QueueThreshold
+	if threshold <= 0 {
+		threshold = defaultQueueThreshold
+	}
+	ret := &Server{
+		addr:           opts.Addr,
+		token:          opts.Token,
+		queueThreshold: threshold,
+		mux:            http.NewServeMux(),
+	}
+	ret.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	ret.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	ret.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	ret.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	ret.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	ret.mux.HandleFunc("/debug/dump", ret.handleDump)
+	return ret
+}
+
+// SetGateway sets the gateway to dump clients.
+func (s *Server) SetGateway(g gate.DefaultGateway) {
+	s.gateway = g
+}
+
+// SetSlowClients sets the source of slow clients, such as gate.Watchdog.
+func (s *Server) SetSlowClients(sc SlowClients) {
+	s.slowClients = sc
+}
+
+// SetSubscription sets the subscription to dump channels, it should implement subscription.Inspector.
+func (s *Server) SetSubscription(sub subscription.Interface) {
+	s.subscription = sub
+}
+
+// Dump returns the snapshot of the runtime and components set.
+func (s *Server) Dump() *Dump {
+	d := &Dump{
+		Goroutines:            runtime.NumGoroutine(),
+		GoroutinesBySubsystem: goroutinesBySubsystem(),
+		QueuedClients:         []ClientQueue{},
+		SlowClients:           []gate.SlowClient{},
+		LargestChannels:       []ChannelSize{},
+	}
+	ms := runtime.MemStats{}
+	runtime.ReadMemStats(&ms)
+	d.Memory = Memory{
+		HeapAlloc:   ms.HeapAlloc,
+		HeapInuse:   ms.HeapInuse,
+		HeapObjects: ms.HeapObjects,
+		Sys:         ms.Sys,
+		NumGC:       ms.NumGC,
+	}
+
+	if s.gateway != nil {
+		clients := s.gateway.GetAll()
+		d.Clients = len(clients)
+		for id, info := range clients {
+			if info.QueueDepth >= s.queueThreshold {
+				d.QueuedClients = append(d.QueuedClients, ClientQueue{ID: id, QueueDepth: info.QueueDepth})
+			}
+		}
+		sort.Slice(d.QueuedClients, func(i, j int) bool {
+			return d.QueuedClients[i].QueueDepth > d.QueuedClients[j].QueueDepth
+		})
+	}
+	if s.slowClients != nil {
+		d.SlowClients = append(d.SlowClients, s.slowClients.Slow()...)
+	}
+	if inspector, ok := s.subscription.(subscription.Inspector); ok {
+		counts := inspector.ChannelMemberCounts()
+		d.Channels = len(counts)
+		for id, n := range counts {
+			d.Subscriptions += n
+			d.LargestChannels = append(d.LargestChannels, ChannelSize{ID: id, Members: n})
+		}
+		sort.Slice(d.LargestChannels, func(i, j int) bool {
+			return d.LargestChannels[i].Members > d.LargestChannels[j].Members
+		})
+		if len(d.LargestChannels) > defaultTopChannels {
+			d.LargestChannels = d.LargestChannels[:defaultTopChannels]
+		}
+	}
+	return d
+}
+
+// goroutinesBySubsystem counts goroutines by the innermost glide package in the stack, or the glide package created
+// the goroutine.
+func goroutinesBySubsystem() map[string]int {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+	ret := map[string]int{}
+	for _, g := range bytes.Split(buf, []byte("\n\n")) {
+		if len(g) == 0 {
+			continue
+		}
+		ret[subsystemOf(string(g))]++
+	}
+	return ret
+}
+
+// subsystemOf returns the glide package of the goroutine stack, such as pkg/gate.
+func subsystemOf(stack string) string {
+	for _, line := range strings.Split(stack, "\n") {
+		line = strings.TrimPrefix(line, "created by ")
+		if !strings.HasPrefix(line, modulePrefix) {
+			continue
+		}
+		fn := strings.TrimPrefix(line, modulePrefix)
+		// the package path ends at the first dot after the last slash of the function name.
+		slash := strings.LastIndex(fn[:strings.IndexByte(fn+"(", '(')], "/")
+		dot := strings.Index(fn[slash+1:], ".")
+		if dot < 0 {
+			return fn
+		}
+		return fn[:slash+1+dot]
+	}
+	return subsystemOther
+}
+
+func (s *Server) handleDump(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(s.Dump())
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) Run() error {
+	return http.ListenAndServe(s.addr, s)
+}
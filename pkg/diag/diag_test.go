@@ -0,0 +1,80 @@
+package diag
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/gate/gatetest"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/stretchr/testify/assert"
+)
+
+type queueGateway struct {
+	*gatetest.Gateway
+	all map[gate.ID]gate.Info
+}
+
+func (q *queueGateway) GetAll() map[gate.ID]gate.Info {
+	return q.all
+}
+
+type mockInspector struct {
+	subscription.Interface
+}
+
+func (mockInspector) ChannelMemberCounts() map[subscription.ChanID]int {
+	return map[subscription.ChanID]int{"small": 2, "large": 10}
+}
+
+type mockSlow []gate.SlowClient
+
+func (m mockSlow) Slow() []gate.SlowClient {
+	return m
+}
+
+func TestSubsystemOf(t *testing.T) {
+	stack := `goroutine 7 [select]:
+github.com/glide-im/glide/pkg/gate.(*UserClient).writeMessage(0xc000)
+	/glide/pkg/gate/client_impl.go:300 +0x1
+created by github.com/glide-im/glide/pkg/gate.(*UserClient).Run in goroutine 6`
+	assert.Equal(t, "pkg/gate", subsystemOf(stack))
+	assert.Equal(t, "pkg/subscription/subscription_impl", subsystemOf("created by github.com/glide-im/glide/pkg/subscription/subscription_impl.NewChannel.func1"))
+	assert.Equal(t, subsystemOther, subsystemOf("goroutine 1 [running]:\nmain.main()"))
+}
+
+func TestServer_Dump(t *testing.T) {
+	s := NewServer(&Options{Token: "secret", QueueThreshold: 5})
+	s.SetGateway(&queueGateway{Gateway: gatetest.NewGateway(), all: map[gate.ID]gate.Info{
+		gate.NewID2("a"): {QueueDepth: 1},
+		gate.NewID2("b"): {QueueDepth: 5},
+		gate.NewID2("c"): {QueueDepth: 50},
+	}})
+	s.SetSubscription(mockInspector{})
+	s.SetSlowClients(mockSlow{{ID: gate.NewID2("c"), Reason: gate.SlowReasonStall}})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/dump", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() { <-done }()
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/dump", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	d := Dump{}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&d))
+	assert.Equal(t, 3, d.Clients)
+	assert.Equal(t, []ClientQueue{{ID: gate.NewID2("c"), QueueDepth: 50}, {ID: gate.NewID2("b"), QueueDepth: 5}}, d.QueuedClients)
+	assert.Len(t, d.SlowClients, 1)
+	assert.Equal(t, 2, d.Channels)
+	assert.Equal(t, 12, d.Subscriptions)
+	assert.Equal(t, ChannelSize{ID: "large", Members: 10}, d.LargestChannels[0])
+	assert.GreaterOrEqual(t, d.GoroutinesBySubsystem["pkg/diag"], 1)
+}
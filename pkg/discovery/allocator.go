@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// MetaRegion the key of node meta of the region the node is deployed in, such as eu-west.
+const MetaRegion = "region"
+
+const maxBackupNodes = 2
+
+// Allocation the gateway allocated to the client.
+type Allocation struct {
+	Addr   string `json:"addr"`
+	Node   string `json:"node"`
+	Region string `json:"region,omitempty"`
+	// Backups the addresses to connect if Addr is unreachable.
+	Backups []string `json:"backups,omitempty"`
+}
+
+// Allocator allocates the less loaded gateway for clients to connect, gateways in the region the client hints are
+// preferred, so clients don't need a hardcoded gateway list.
+//
+//	GET /connect?region=   the region hint can be the query or the header X-Region
+type Allocator struct {
+	gateways *Membership
+}
+
+func NewAllocator(gateways *Membership) *Allocator {
+	return &Allocator{gateways: gateways}
+}
+
+// Allocate selects the gateway in region, or in all regions if no gateway available in region.
+func (a *Allocator) Allocate(region string) (*Allocation, error) {
+	nodes := a.gateways.Nodes()
+	candidates := nodes
+	if region != "" {
+		var inRegion []*Node
+		for _, n := range nodes {
+			if strings.EqualFold(n.Meta[MetaRegion], region) && !n.Full() {
+				inRegion = append(inRegion, n)
+			}
+		}
+		if len(inRegion) > 0 {
+			candidates = inRegion
+		}
+	}
+	n, err := SelectNode(candidates)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Allocation{Addr: n.Addr, Node: n.ID, Region: n.Meta[MetaRegion]}
+	for _, b := range candidates {
+		if len(ret.Backups) >= maxBackupNodes {
+			break
+		}
+		if b.ID != n.ID && !b.Full() {
+			ret.Backups = append(ret.Backups, b.Addr)
+		}
+	}
+	return ret, nil
+}
+
+func (a *Allocator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	region := r.URL.Query().Get("region")
+	if region == "" {
+		region = r.Header.Get("X-Region")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	allocation, err := a.Allocate(region)
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(allocation)
+}
@@ -0,0 +1,40 @@
+package discovery
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllocator(t *testing.T) {
+	m := NewMembership(NewMemoryBackend(), ServiceGateway)
+	a := NewAllocator(m)
+
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/connect", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	m.update([]*Node{
+		{ID: "eu1", Addr: "wss://eu1", Capacity: 10, Load: 10, Meta: map[string]string{MetaRegion: "eu"}},
+		{ID: "us1", Addr: "wss://us1", Capacity: 10, Load: 5, Meta: map[string]string{MetaRegion: "us"}},
+		{ID: "us2", Addr: "wss://us2", Capacity: 10, Load: 1, Meta: map[string]string{MetaRegion: "us"}},
+	})
+
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/connect?region=us", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	allocation := Allocation{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &allocation))
+	assert.Equal(t, "wss://us2", allocation.Addr)
+	assert.Equal(t, []string{"wss://us1"}, allocation.Backups)
+
+	// the only gateway in eu is full.
+	req := httptest.NewRequest(http.MethodGet, "/connect", nil)
+	req.Header.Set("X-Region", "eu")
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &allocation))
+	assert.Equal(t, "us", allocation.Region)
+}
@@ -0,0 +1,105 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/hashicorp/consul/api"
+	"time"
+)
+
+var _ Backend = (*ConsulBackend)(nil)
+
+const consulNodeMeta = "glide_node"
+
+// ConsulBackend registers nodes as Consul services with TTL health checks, the node is stored in the service meta.
+type ConsulBackend struct {
+	cli *api.Client
+}
+
+// NewConsulBackend connects to the Consul agent at addr, such as 127.0.0.1:8500.
+func NewConsulBackend(addr string) (*ConsulBackend, error) {
+	conf := api.DefaultConfig()
+	if addr != "" {
+		conf.Address = addr
+	}
+	cli, err := api.NewClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &ConsulBackend{cli: cli}, nil
+}
+
+func checkID(service string, id string) string {
+	return "service:" + service + "-" + id
+}
+
+// Register registers the service and passes the TTL check, the service is removed after the check failed for ttl.
+func (c *ConsulBackend) Register(ctx context.Context, service string, node *Node, ttl time.Duration) error {
+	b, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+	reg := &api.AgentServiceRegistration{
+		ID:      service + "-" + node.ID,
+		Name:    service,
+		Address: node.Addr,
+		Meta:    map[string]string{consulNodeMeta: string(b)},
+		Check: &api.AgentServiceCheck{
+			CheckID:                        checkID(service, node.ID),
+			TTL:                            ttl.String(),
+			DeregisterCriticalServiceAfter: (ttl * 4).String(),
+		},
+	}
+	if err = c.cli.Agent().ServiceRegister(reg); err != nil {
+		return err
+	}
+	q := (&api.QueryOptions{}).WithContext(ctx)
+	return c.cli.Agent().UpdateTTLOpts(checkID(service, node.ID), "", api.HealthPassing, q)
+}
+
+func (c *ConsulBackend) Deregister(ctx context.Context, service string, id string) error {
+	return c.cli.Agent().ServiceDeregister(service + "-" + id)
+}
+
+func (c *ConsulBackend) Nodes(ctx context.Context, service string) ([]*Node, error) {
+	nodes, _, err := c.nodes(ctx, service, 0)
+	return nodes, err
+}
+
+func (c *ConsulBackend) nodes(ctx context.Context, service string, index uint64) ([]*Node, uint64, error) {
+	q := (&api.QueryOptions{WaitIndex: index, WaitTime: time.Minute}).WithContext(ctx)
+	entries, meta, err := c.cli.Health().Service(service, "", true, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	var nodes []*Node
+	for _, e := range entries {
+		n := &Node{}
+		if err := json.Unmarshal([]byte(e.Service.Meta[consulNodeMeta]), n); err != nil {
+			log.W("invalid node %s: %v", e.Service.ID, err)
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, meta.LastIndex, nil
+}
+
+// Watch watches passing services by blocking queries and calls fn with all nodes on every change.
+func (c *ConsulBackend) Watch(ctx context.Context, service string, fn func(nodes []*Node)) error {
+	var index uint64
+	for ctx.Err() == nil {
+		nodes, lastIndex, err := c.nodes(ctx, service, index)
+		if err != nil {
+			return err
+		}
+		// the index may go backwards after Consul restarted, reset to query again.
+		if lastIndex < index {
+			lastIndex = 0
+		}
+		if lastIndex != index {
+			fn(nodes)
+		}
+		index = lastIndex
+	}
+	return ctx.Err()
+}
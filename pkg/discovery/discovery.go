@@ -0,0 +1,265 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"github.com/glide-im/glide/pkg/logger"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+var log = logger.Named("discovery")
+
+const (
+	// ServiceGateway the service name gateways registered as.
+	ServiceGateway = "gateway"
+	// ServiceLogic the service name message logic servers registered as.
+	ServiceLogic = "logic"
+
+	defaultTTL = time.Second * 15
+	// defaultCapacity the capacity used to score the node without capacity.
+	defaultCapacity = 10000
+)
+
+var ErrNoAvailableNode = errors.New("no available node")
+
+// Node the instance of a service, such as a gateway.
+type Node struct {
+	ID string `json:"id"`
+	// Addr the address clients or services connect to, such as wss://gw1.example.com/ws.
+	Addr string `json:"addr"`
+	// Capacity the max count of connections or tasks of the node, 0 is unknown.
+	Capacity int64 `json:"capacity,omitempty"`
+	// Load the current count of connections or tasks.
+	Load      int64             `json:"load"`
+	Meta      map[string]string `json:"meta,omitempty"`
+	UpdatedAt int64             `json:"updated_at"`
+}
+
+// Full returns true if the load reaches the capacity.
+func (n *Node) Full() bool {
+	return n.Capacity > 0 && n.Load >= n.Capacity
+}
+
+// Score returns the load ratio of the node, the lower the less loaded.
+func (n *Node) Score() float64 {
+	c := n.Capacity
+	if c <= 0 {
+		c = defaultCapacity
+	}
+	return float64(n.Load) / float64(c)
+}
+
+// Backend stores nodes with leases, such as etcd or Consul.
+type Backend interface {
+
+	// Register registers or updates the node of service, the node is removed if it's not registered again in ttl.
+	Register(ctx context.Context, service string, node *Node, ttl time.Duration) error
+
+	// Deregister removes the node of service.
+	Deregister(ctx context.Context, service string, id string) error
+
+	// Nodes returns alive nodes of service.
+	Nodes(ctx context.Context, service string) ([]*Node, error)
+
+	// Watch calls fn with alive nodes of service on every change until ctx done.
+	Watch(ctx context.Context, service string, fn func(nodes []*Node)) error
+}
+
+type RegistrarOptions struct {
+	// TTL the node is removed if the registrar stops renewing for TTL, default 15 seconds, the node is renewed every
+	// third of TTL.
+	TTL time.Duration
+	// Load returns the current load of the node reported with renewing, such as online connections.
+	Load func() int64
+}
+
+// Registrar keeps the node registered with the current load until stopped.
+type Registrar struct {
+	backend Backend
+	service string
+	node    Node
+	ttl     time.Duration
+	load    func() int64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewRegistrar(backend Backend, service string, node *Node, opts *RegistrarOptions) *Registrar {
+	r := &Registrar{
+		backend: backend,
+		service: service,
+		node:    *node,
+		ttl:     opts.TTL,
+		load:    opts.Load,
+	}
+	if r.ttl <= 0 {
+		r.ttl = defaultTTL
+	}
+	return r
+}
+
+// Start registers the node and starts renewing, returns error if the first registration failed.
+func (r *Registrar) Start() error {
+	if err := r.register(); err != nil {
+		return err
+	}
+	var ctx context.Context
+	ctx, r.cancel = context.WithCancel(context.Background())
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.register(); err != nil {
+					log.E("renew %s node %s error: %v", r.service, r.node.ID, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops renewing and deregisters the node.
+func (r *Registrar) Stop() error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+	<-r.done
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	return r.backend.Deregister(ctx, r.service, r.node.ID)
+}
+
+func (r *Registrar) register() error {
+	n := r.node
+	if r.load != nil {
+		n.Load = r.load()
+	}
+	n.UpdatedAt = time.Now().Unix()
+	ctx, cancel := context.WithTimeout(context.Background(), r.ttl/3)
+	defer cancel()
+	return r.backend.Register(ctx, r.service, &n, r.ttl)
+}
+
+// Membership caches the alive nodes of service by watching the backend.
+type Membership struct {
+	backend Backend
+	service string
+
+	mu        sync.RWMutex
+	nodes     []*Node
+	listeners []func(added, removed []*Node)
+}
+
+func NewMembership(backend Backend, service string) *Membership {
+	return &Membership{backend: backend, service: service}
+}
+
+// OnChange registers fn called with nodes added and removed when membership changes, updated nodes are not reported.
+func (m *Membership) OnChange(fn func(added, removed []*Node)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// Start loads nodes and watches changes until ctx done, the watch is retried on error.
+func (m *Membership) Start(ctx context.Context) error {
+	nodes, err := m.backend.Nodes(ctx, m.service)
+	if err != nil {
+		return err
+	}
+	m.update(nodes)
+	go func() {
+		for ctx.Err() == nil {
+			err := m.backend.Watch(ctx, m.service, m.update)
+			if err != nil && ctx.Err() == nil {
+				log.E("watch %s nodes error: %v", m.service, err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second * 3):
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Nodes returns the alive nodes.
+func (m *Membership) Nodes() []*Node {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.nodes
+}
+
+func (m *Membership) update(nodes []*Node) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+
+	m.mu.Lock()
+	old := map[string]*Node{}
+	for _, n := range m.nodes {
+		old[n.ID] = n
+	}
+	var added, removed []*Node
+	for _, n := range nodes {
+		if _, ok := old[n.ID]; ok {
+			delete(old, n.ID)
+		} else {
+			added = append(added, n)
+		}
+	}
+	for _, n := range old {
+		removed = append(removed, n)
+	}
+	m.nodes = nodes
+	listeners := m.listeners
+	m.mu.Unlock()
+
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	for _, fn := range listeners {
+		fn(added, removed)
+	}
+}
+
+// Select selects the less loaded node by two random choices, which avoids all clients choosing the same least loaded
+// node before the load is reported. Full nodes are skipped.
+func (m *Membership) Select() (*Node, error) {
+	return SelectNode(m.Nodes())
+}
+
+// SelectNode selects the less loaded node by two random choices, full nodes are skipped.
+func SelectNode(nodes []*Node) (*Node, error) {
+	var available []*Node
+	for _, n := range nodes {
+		if !n.Full() {
+			available = append(available, n)
+		}
+	}
+	switch len(available) {
+	case 0:
+		return nil, ErrNoAvailableNode
+	case 1:
+		return available[0], nil
+	}
+	i := rand.Intn(len(available))
+	j := rand.Intn(len(available) - 1)
+	if j >= i {
+		j++
+	}
+	a, b := available[i], available[j]
+	if b.Score() < a.Score() {
+		return b, nil
+	}
+	return a, nil
+}
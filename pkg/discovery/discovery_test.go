@@ -0,0 +1,64 @@
+package discovery
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSelectNode(t *testing.T) {
+	nodes := []*Node{
+		{ID: "1", Capacity: 100, Load: 100},
+		{ID: "2", Capacity: 100, Load: 10},
+		{ID: "3", Capacity: 1000, Load: 900},
+	}
+	for i := 0; i < 20; i++ {
+		n, err := SelectNode(nodes)
+		assert.NoError(t, err)
+		assert.Equal(t, "2", n.ID)
+	}
+
+	_, err := SelectNode(nodes[:1])
+	assert.Equal(t, ErrNoAvailableNode, err)
+}
+
+func TestMembership(t *testing.T) {
+	backend := NewMemoryBackend()
+	m := NewMembership(backend, ServiceGateway)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, m.Start(ctx))
+
+	changes := make(chan []*Node, 4)
+	m.OnChange(func(added, removed []*Node) {
+		changes <- append(added, removed...)
+	})
+
+	var load int64 = 5
+	r := NewRegistrar(backend, ServiceGateway, &Node{ID: "gw1", Addr: "ws://gw1"}, &RegistrarOptions{
+		TTL:  time.Second,
+		Load: func() int64 { return atomic.LoadInt64(&load) },
+	})
+	assert.NoError(t, r.Start())
+
+	select {
+	case c := <-changes:
+		assert.Equal(t, "gw1", c[0].ID)
+	case <-time.After(time.Second):
+		t.Fatal("node added not notified")
+	}
+	n, err := m.Select()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n.Load)
+
+	assert.NoError(t, r.Stop())
+	select {
+	case c := <-changes:
+		assert.Equal(t, "gw1", c[0].ID)
+	case <-time.After(time.Second):
+		t.Fatal("node removed not notified")
+	}
+	assert.Empty(t, m.Nodes())
+}
@@ -0,0 +1,123 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"sync"
+	"time"
+)
+
+var _ Backend = (*EtcdBackend)(nil)
+
+const defaultEtcdPrefix = "/glide/discovery/"
+
+// EtcdBackend stores nodes in etcd at `prefix/service/id` with leases.
+type EtcdBackend struct {
+	cli    *clientv3.Client
+	prefix string
+
+	mu     sync.Mutex
+	leases map[string]clientv3.LeaseID
+}
+
+// NewEtcdBackend connects to the etcd endpoints, the prefix of keys default /glide/discovery/.
+func NewEtcdBackend(endpoints []string, prefix string) (*EtcdBackend, error) {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: time.Second * 5,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if prefix == "" {
+		prefix = defaultEtcdPrefix
+	}
+	return &EtcdBackend{cli: cli, prefix: prefix, leases: map[string]clientv3.LeaseID{}}, nil
+}
+
+func (e *EtcdBackend) key(service string, id string) string {
+	return e.prefix + service + "/" + id
+}
+
+// Register puts the node with the lease of ttl, the lease is renewed by every registration.
+func (e *EtcdBackend) Register(ctx context.Context, service string, node *Node, ttl time.Duration) error {
+	key := e.key(service, node.ID)
+	b, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	lease, ok := e.leases[key]
+	e.mu.Unlock()
+	if ok {
+		if _, err = e.cli.KeepAliveOnce(ctx, lease); err != nil {
+			// the lease is expired, grant a new one.
+			ok = false
+		}
+	}
+	if !ok {
+		l, err := e.cli.Grant(ctx, int64(ttl/time.Second))
+		if err != nil {
+			return err
+		}
+		lease = l.ID
+		e.mu.Lock()
+		e.leases[key] = lease
+		e.mu.Unlock()
+	}
+	_, err = e.cli.Put(ctx, key, string(b), clientv3.WithLease(lease))
+	return err
+}
+
+func (e *EtcdBackend) Deregister(ctx context.Context, service string, id string) error {
+	key := e.key(service, id)
+	e.mu.Lock()
+	lease, ok := e.leases[key]
+	delete(e.leases, key)
+	e.mu.Unlock()
+	if ok {
+		_, _ = e.cli.Revoke(ctx, lease)
+	}
+	_, err := e.cli.Delete(ctx, key)
+	return err
+}
+
+func (e *EtcdBackend) Nodes(ctx context.Context, service string) ([]*Node, error) {
+	resp, err := e.cli.Get(ctx, e.prefix+service+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*Node
+	for _, kv := range resp.Kvs {
+		n := &Node{}
+		if err := json.Unmarshal(kv.Value, n); err != nil {
+			log.W("invalid node %s: %v", kv.Key, err)
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// Watch watches the prefix of service and calls fn with all nodes on every change.
+func (e *EtcdBackend) Watch(ctx context.Context, service string, fn func(nodes []*Node)) error {
+	ch := e.cli.Watch(ctx, e.prefix+service+"/", clientv3.WithPrefix())
+	for resp := range ch {
+		if err := resp.Err(); err != nil {
+			return err
+		}
+		nodes, err := e.Nodes(ctx, service)
+		if err != nil {
+			return err
+		}
+		fn(nodes)
+	}
+	return ctx.Err()
+}
+
+// Close closes the etcd client.
+func (e *EtcdBackend) Close() error {
+	return e.cli.Close()
+}
@@ -0,0 +1,104 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Backend = (*MemoryBackend)(nil)
+
+type memoryNode struct {
+	node     Node
+	expireAt time.Time
+}
+
+// MemoryBackend stores nodes in memory, used by single node deployment and tests. Expired nodes are removed when
+// nodes are read, watchers are not notified of expiration.
+type MemoryBackend struct {
+	mu       sync.Mutex
+	services map[string]map[string]*memoryNode
+	watchers map[string][]chan struct{}
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		services: map[string]map[string]*memoryNode{},
+		watchers: map[string][]chan struct{}{},
+	}
+}
+
+func (m *MemoryBackend) Register(ctx context.Context, service string, node *Node, ttl time.Duration) error {
+	m.mu.Lock()
+	nodes, ok := m.services[service]
+	if !ok {
+		nodes = map[string]*memoryNode{}
+		m.services[service] = nodes
+	}
+	nodes[node.ID] = &memoryNode{node: *node, expireAt: time.Now().Add(ttl)}
+	m.mu.Unlock()
+	m.notify(service)
+	return nil
+}
+
+func (m *MemoryBackend) Deregister(ctx context.Context, service string, id string) error {
+	m.mu.Lock()
+	delete(m.services[service], id)
+	m.mu.Unlock()
+	m.notify(service)
+	return nil
+}
+
+func (m *MemoryBackend) Nodes(ctx context.Context, service string) ([]*Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var ret []*Node
+	for id, n := range m.services[service] {
+		if now.After(n.expireAt) {
+			delete(m.services[service], id)
+			continue
+		}
+		node := n.node
+		ret = append(ret, &node)
+	}
+	return ret, nil
+}
+
+func (m *MemoryBackend) Watch(ctx context.Context, service string, fn func(nodes []*Node)) error {
+	ch := make(chan struct{}, 1)
+	m.mu.Lock()
+	m.watchers[service] = append(m.watchers[service], ch)
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		ws := m.watchers[service]
+		for i, w := range ws {
+			if w == ch {
+				m.watchers[service] = append(ws[:i], ws[i+1:]...)
+				break
+			}
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ch:
+			nodes, _ := m.Nodes(ctx, service)
+			fn(nodes)
+		}
+	}
+}
+
+func (m *MemoryBackend) notify(service string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ch := range m.watchers[service] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Package embedded runs the gateway, messaging and subscription in one process, messages are dispatched from the
+// gateway to messaging by function call instead of the rpc or message queue, and stored by a file store in the data
+// directory. It suits small apps deployed as a single binary and integration tests:
+//
+//	srv, _ := embedded.New(&embedded.Options{DataDir: "./data", SecretKey: "secret"})
+//	_ = srv.Start()
+//	defer srv.Close()
+//	credential, _ := srv.Credential("1", "phone")
+//	ticket := srv.Ticket("1", "2")
+//
+// The built-in store keeps the whole history in memory and appends changes to a log file, set Options.Store to use
+// the database stores when the history grows.
+package embedded
+
+import (
+	"errors"
+	"fmt"
+	"github.com/glide-im/glide/internal/message_store_file"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/messaging"
+	"github.com/glide-im/glide/pkg/sequence"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/glide-im/glide/pkg/subscription/subscription_impl"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+var log = logger.Named("embedded")
+
+const (
+	defaultID        = "embedded"
+	defaultAddr      = "127.0.0.1"
+	defaultSecretKey = "embedded"
+	// startTimeout the max duration Start waits for the gateway listening.
+	startTimeout = time.Second * 5
+)
+
+const errStartTimeout = "gateway not listening in time"
+
+// Store the message store of the embedded server.
+type Store interface {
+	store.MessageHistoryStore
+	store.SubscriptionStore
+	sequence.SegmentStore
+}
+
+type Options struct {
+	// ID the id of gateway, default "embedded".
+	ID string
+	// Addr the address the websocket listens, default 127.0.0.1.
+	Addr string
+	// Port the port the websocket listens, default a free port.
+	Port int
+	// SecretKey the key to encrypt credentials of clients, default "embedded", set it except for tests.
+	SecretKey string
+	// DataDir the directory of the file store, messages are kept in memory only if empty.
+	DataDir string
+	// Store replaces the file store if set, such as the database stores.
+	Store Store
+	// StoreOfflineMessage stores messages to offline receivers, see messaging.StoreOfflineMessage.
+	StoreOfflineMessage bool
+}
+
+// Server the gateway, messaging and subscription assembled in one process.
+type Server struct {
+	Gateway      *gate.WebsocketGatewayServer
+	Messaging    *messaging.MessageHandlerImpl
+	Subscription subscription.Subscribe
+	Store        Store
+
+	addr   string
+	port   int
+	secret string
+	errs   chan error
+}
+
+// New assembles the server, options can be nil, use default value when nil.
+func New(options *Options) (*Server, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.ID == "" {
+		opts.ID = defaultID
+	}
+	if opts.Addr == "" {
+		opts.Addr = defaultAddr
+	}
+	if opts.SecretKey == "" {
+		opts.SecretKey = defaultSecretKey
+	}
+	if opts.Port == 0 {
+		port, err := freePort(opts.Addr)
+		if err != nil {
+			return nil, err
+		}
+		opts.Port = port
+	}
+	s := opts.Store
+	if s == nil {
+		fs, err := message_store_file.New(opts.DataDir)
+		if err != nil {
+			return nil, err
+		}
+		s = fs
+	}
+	if err := s.Migrate(); err != nil {
+		return nil, err
+	}
+
+	gateway := gate.NewWebsocketServer(opts.ID, opts.Addr, opts.Port, opts.SecretKey)
+	handler, err := messaging.NewHandlerWithOptions(gateway, &messaging.MessageHandlerOptions{
+		MessageStore:      s,
+		NotifyOnErr:       true,
+		SequenceAllocator: sequence.NewSegmentAllocator(s, 0),
+	})
+	if err != nil {
+		return nil, err
+	}
+	messaging.StoreOfflineMessage = opts.StoreOfflineMessage
+
+	sub := subscription_impl.NewSubscription(s, s)
+	sub.SetGateInterface(gateway)
+	handler.SetSubscription(sub)
+	handler.SetGate(gateway)
+	gateway.SetMessageHandler(func(cliInfo *gate.Info, message *messages.GlideMessage) {
+		if e := handler.Handle(cliInfo, message); e != nil {
+			log.E("error: %v", e)
+		}
+	})
+
+	return &Server{
+		Gateway:      gateway,
+		Messaging:    handler,
+		Subscription: sub,
+		Store:        s,
+		addr:         opts.Addr,
+		port:         opts.Port,
+		secret:       opts.SecretKey,
+		errs:         make(chan error, 1),
+	}, nil
+}
+
+// Run runs the gateway, blocks until the gateway stops.
+func (s *Server) Run() error {
+	return s.Gateway.Run()
+}
+
+// Start runs the gateway in background, returns when the gateway is listening.
+func (s *Server) Start() error {
+	go func() {
+		s.errs <- s.Run()
+	}()
+	addr := net.JoinHostPort(s.addr, strconv.Itoa(s.port))
+	deadline := time.Now().Add(startTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-s.errs:
+			return err
+		default:
+		}
+		c, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			_ = c.Close()
+			return nil
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return errors.New(errStartTimeout)
+}
+
+// URL returns the websocket url clients connect to.
+func (s *Server) URL() string {
+	return fmt.Sprintf("ws://%s/ws", net.JoinHostPort(s.addr, strconv.Itoa(s.port)))
+}
+
+// Credential issues the encrypted credential of the user device, the business service issues credentials to clients
+// in the standalone deployment. The message deliver secret of credential is SecretKey, see Ticket.
+func (s *Server) Credential(uid string, device string) (*gate.EncryptedCredential, error) {
+	return s.Gateway.KeyRing().Encrypt(&gate.ClientAuthCredentials{
+		UserID:    uid,
+		DeviceID:  device,
+		Secrets:   &gate.ClientSecrets{MessageDeliverSecret: s.secret},
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
+// Ticket returns the ticket of messages from the user to the user or channel, for credentials issued by Credential.
+func (s *Server) Ticket(from string, to string) string {
+	return gate.TicketKey(s.secret, from, to)
+}
+
+// Close closes the store, the gateway keeps listening until the process exits.
+func (s *Server) Close() error {
+	if c, ok := s.Store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func freePort(addr string) (int, error) {
+	l, err := net.Listen("tcp", net.JoinHostPort(addr, "0"))
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
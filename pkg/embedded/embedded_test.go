@@ -0,0 +1,75 @@
+package embedded
+
+import (
+	"context"
+	"github.com/glide-im/glide/internal/message_store_file"
+	"github.com/glide-im/glide/pkg/client"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func connect(t *testing.T, srv *Server, uid string) *client.Client {
+	c, err := client.New(&client.Options{
+		URL: srv.URL(),
+		Credential: func() (*gate.EncryptedCredential, error) {
+			return srv.Credential(uid, "test")
+		},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, c.Connect(context.Background()))
+	return c
+}
+
+func TestServer_Chat(t *testing.T) {
+	dir := t.TempDir()
+	srv, err := New(&Options{DataDir: dir})
+	assert.NoError(t, err)
+	assert.NoError(t, srv.Start())
+
+	c1 := connect(t, srv, "1")
+	defer c1.Close()
+	c2 := connect(t, srv, "2")
+	defer c2.Close()
+	received := make(chan *messages.ChatMessage, 1)
+	c2.OnChatMessage(func(action messages.Action, m *messages.ChatMessage) {
+		received <- m
+	})
+
+	ack, err := c1.SendChat(context.Background(), "2", &messages.ChatMessage{Content: "hi", Type: 1}, srv.Ticket("1", "2"))
+	assert.NoError(t, err)
+
+	select {
+	case m := <-received:
+		assert.Equal(t, "hi", m.Content)
+		assert.Equal(t, "1", m.From)
+	case <-time.After(time.Second * 5):
+		t.Fatal("message not received")
+	}
+
+	stored, err := srv.Store.GetMessage(conversation.NewP2P("1", "2").ID, ack.Mid)
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", stored.Content)
+	assert.NoError(t, srv.Close())
+
+	// the file store replays the history after restart.
+	fs, err := message_store_file.New(dir)
+	assert.NoError(t, err)
+	defer fs.Close()
+	ms, err := fs.GetBySeqRange(conversation.NewP2P("1", "2").ID, 0, 100)
+	assert.NoError(t, err)
+	assert.Len(t, ms, 1)
+	assert.Equal(t, ack.Mid, ms[0].Mid)
+}
+
+func TestNew_MultipleServers(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		srv, err := New(nil)
+		assert.NoError(t, err)
+		assert.NoError(t, srv.Start())
+		_ = srv.Close()
+	}
+}
@@ -0,0 +1,327 @@
+// Package federation exchanges messages between glide clusters of different regions. Each user is homed in a region
+// resolved by the Registry, the message to the user homed in another region is forwarded to the cluster of that region
+// and delivered there, so the user connects to and the data of the user resides in the home region only.
+package federation
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
+	"github.com/glide-im/glide/pkg/snowflake"
+	"github.com/glide-im/glide/pkg/webhook"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var log = logger.Named("federation")
+
+const (
+	defaultMaxHops     = 3
+	defaultDedupWindow = time.Minute * 5
+	maxClockSkew       = time.Minute * 5
+	maxEnvelopeSize    = 4 << 20
+)
+
+const (
+	errNoPeer         = "no peer of region"
+	errLoop           = "envelope loops back"
+	errTooManyHops    = "envelope exceeds max hops"
+	errInvalidEnvelop = "invalid envelope"
+	errNoDeliver      = "deliver is not set"
+	errSecretRequired = "secret is required to serve or forward envelopes"
+)
+
+const (
+	DirectionOut = "out"
+	DirectionIn  = "in"
+)
+
+// Envelope the message forwarded between regions.
+type Envelope struct {
+	// ID the unique id of the envelope assigned by the origin region, the envelope received twice is dropped.
+	ID string `json:"id"`
+	// Origin the region the message is sent from.
+	Origin string `json:"origin"`
+	// Path the regions the envelope has been through in order, the first is Origin.
+	Path []string `json:"path"`
+	// To the uid of receiver.
+	To string `json:"to"`
+	// Message the message delivered to the receiver.
+	Message *messages.GlideMessage `json:"message"`
+}
+
+// DeliverFunc delivers the message of envelope received to the receiver homed in this region.
+type DeliverFunc = func(e *Envelope) error
+
+type Options struct {
+	// Region the region of this cluster, required.
+	Region string
+	// Registry resolves home regions of users, required.
+	Registry Registry
+	// Peers the clusters of other regions by region.
+	Peers map[string]Peer
+	// Addr the address ServeHTTP is served on to receive envelopes from other regions, empty if not served.
+	Addr string
+	// Secret the secret shared by regions to verify envelopes received, required if Addr or Peers is set, envelopes
+	// received are rejected if empty.
+	Secret string
+	// MaxHops the max count of regions an envelope goes through, default 3.
+	MaxHops int
+	// DedupWindow the duration of remembering envelopes received to drop duplicates, default 5 minutes.
+	DedupWindow time.Duration
+}
+
+// Federation routes messages to users homed in other regions. Envelopes are deduplicated by id, and dropped if
+// they loop back to a region they have been through or exceed the max hops, the envelope to the user homed in
+// another region is relayed if the home region of the user is changed.
+type Federation struct {
+	region   string
+	registry Registry
+	secret   []byte
+	maxHops  int
+
+	mu      sync.RWMutex
+	peers   map[string]Peer
+	deliver DeliverFunc
+
+	seen *seenCache
+}
+
+func New(opts *Options) (*Federation, error) {
+	if opts.Region == "" {
+		return nil, errors.New("region is required")
+	}
+	if opts.Registry == nil {
+		return nil, errors.New("registry is required")
+	}
+	if opts.Secret == "" && (opts.Addr != "" || len(opts.Peers) > 0) {
+		return nil, errors.New(errSecretRequired)
+	}
+	f := &Federation{
+		region:   opts.Region,
+		registry: opts.Registry,
+		secret:   []byte(opts.Secret),
+		maxHops:  opts.MaxHops,
+		peers:    map[string]Peer{},
+		seen:     newSeenCache(opts.DedupWindow),
+	}
+	if f.maxHops <= 0 {
+		f.maxHops = defaultMaxHops
+	}
+	for region, p := range opts.Peers {
+		f.peers[region] = p
+	}
+	return f, nil
+}
+
+// Region returns the region of this cluster.
+func (f *Federation) Region() string {
+	return f.region
+}
+
+// SetPeer sets the peer of region, nil to remove.
+func (f *Federation) SetPeer(region string, p Peer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if p == nil {
+		delete(f.peers, region)
+	} else {
+		f.peers[region] = p
+	}
+}
+
+// SetDeliver sets the function delivers messages received to local users, such as
+// messaging.MessageHandlerImpl.EnableFederation.
+func (f *Federation) SetDeliver(fn DeliverFunc) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deliver = fn
+}
+
+// Remote returns the home region of the user and true if the user is homed in another region. The user is
+// considered local if the region is unknown or failed to resolve.
+func (f *Federation) Remote(uid string) (string, bool) {
+	region, err := f.registry.HomeRegion(uid)
+	if err != nil {
+		log.E("resolve home region of %s error: %v", uid, err)
+		return "", false
+	}
+	return region, region != "" && region != f.region
+}
+
+// Forward forwards the message to the user homed in region.
+func (f *Federation) Forward(region string, uid string, m *messages.GlideMessage) error {
+	e := &Envelope{
+		ID:      f.region + ":" + strconv.FormatInt(snowflake.Generate(), 10),
+		Origin:  f.region,
+		Path:    []string{f.region},
+		To:      uid,
+		Message: m,
+	}
+	return f.send(region, e)
+}
+
+func (f *Federation) send(region string, e *Envelope) error {
+	f.mu.RLock()
+	p, ok := f.peers[region]
+	f.mu.RUnlock()
+	if !ok {
+		metrics.FederationMessages.WithLabelValues(DirectionOut, "no_peer").Inc()
+		return errors.New(errNoPeer + " " + region)
+	}
+	if err := p.Forward(e); err != nil {
+		metrics.FederationMessages.WithLabelValues(DirectionOut, "error").Inc()
+		return err
+	}
+	metrics.FederationMessages.WithLabelValues(DirectionOut, "ok").Inc()
+	return nil
+}
+
+// Receive handles the envelope from another region, the message is delivered if the receiver is homed in this
+// region, otherwise relayed to the home region. The duplicate envelope is dropped without error.
+func (f *Federation) Receive(e *Envelope) error {
+	if e.ID == "" || e.To == "" || e.Message == nil || len(e.Path) == 0 {
+		metrics.FederationMessages.WithLabelValues(DirectionIn, "invalid").Inc()
+		return errors.New(errInvalidEnvelop)
+	}
+	for _, region := range e.Path {
+		if region == f.region {
+			metrics.FederationMessages.WithLabelValues(DirectionIn, "loop").Inc()
+			return errors.New(errLoop)
+		}
+	}
+	if !f.seen.add(e.ID) {
+		metrics.FederationMessages.WithLabelValues(DirectionIn, "duplicate").Inc()
+		return nil
+	}
+
+	if region, remote := f.Remote(e.To); remote {
+		if len(e.Path) >= f.maxHops {
+			metrics.FederationMessages.WithLabelValues(DirectionIn, "hops").Inc()
+			return errors.New(errTooManyHops)
+		}
+		relayed := *e
+		relayed.Path = append(append([]string{}, e.Path...), f.region)
+		if err := f.send(region, &relayed); err != nil {
+			// the envelope may be received again when retried by the sender.
+			f.seen.remove(e.ID)
+			return err
+		}
+		metrics.FederationMessages.WithLabelValues(DirectionIn, "relayed").Inc()
+		return nil
+	}
+
+	f.mu.RLock()
+	deliver := f.deliver
+	f.mu.RUnlock()
+	if deliver == nil {
+		return errors.New(errNoDeliver)
+	}
+	if err := deliver(e); err != nil {
+		f.seen.remove(e.ID)
+		return err
+	}
+	metrics.FederationMessages.WithLabelValues(DirectionIn, "ok").Inc()
+	return nil
+}
+
+// ServeHTTP receives envelopes posted by peers of other regions, see NewHTTPPeer.
+func (f *Federation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEnvelopeSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !f.verify(r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	e := &Envelope{}
+	if err = json.Unmarshal(body, e); err != nil {
+		http.Error(w, errInvalidEnvelop, http.StatusBadRequest)
+		return
+	}
+	if err = f.Receive(e); err != nil {
+		log.W("receive envelope %s from %s error: %v", e.ID, e.Origin, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *Federation) verify(timestamp string, signature string, body []byte) bool {
+	if len(f.secret) == 0 {
+		return false
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > maxClockSkew || d < -maxClockSkew {
+		return false
+	}
+	expected := "sha256=" + webhook.Sign(f.secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// seenCache remembers ids of envelopes received in a sliding window.
+type seenCache struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*list.Element
+	// order of entries by time, the front is the oldest.
+	order *list.List
+}
+
+type seenEntry struct {
+	id string
+	at time.Time
+}
+
+func newSeenCache(window time.Duration) *seenCache {
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+	return &seenCache{window: window, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// add returns false if the id has been seen in the window.
+func (c *seenCache) add(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for e := c.order.Front(); e != nil; e = c.order.Front() {
+		entry := e.Value.(*seenEntry)
+		if now.Sub(entry.at) < c.window {
+			break
+		}
+		c.order.Remove(e)
+		delete(c.entries, entry.id)
+	}
+	if _, ok := c.entries[id]; ok {
+		return false
+	}
+	c.entries[id] = c.order.PushBack(&seenEntry{id: id, at: now})
+	return true
+}
+
+// remove forgets the id, used when the envelope is failed to deliver and may be retried.
+func (c *seenCache) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[id]; ok {
+		c.order.Remove(e)
+		delete(c.entries, id)
+	}
+}
@@ -0,0 +1,161 @@
+package federation
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// localPeer forwards envelopes to the federation of another region in process.
+type localPeer struct {
+	f    *Federation
+	sent []*Envelope
+}
+
+func (p *localPeer) Forward(e *Envelope) error {
+	p.sent = append(p.sent, e)
+	return p.f.Receive(e)
+}
+
+func newRegions(t *testing.T, registry Registry) (*Federation, *Federation, *[]*Envelope) {
+	us, err := New(&Options{Region: "us", Registry: registry})
+	assert.NoError(t, err)
+	eu, err := New(&Options{Region: "eu", Registry: registry})
+	assert.NoError(t, err)
+	us.SetPeer("eu", &localPeer{f: eu})
+	eu.SetPeer("us", &localPeer{f: us})
+
+	delivered := &[]*Envelope{}
+	eu.SetDeliver(func(e *Envelope) error {
+		*delivered = append(*delivered, e)
+		return nil
+	})
+	return us, eu, delivered
+}
+
+func TestFederation_Forward(t *testing.T) {
+	registry := NewMemRegistry("us")
+	registry.Set("2", "eu")
+	us, eu, delivered := newRegions(t, registry)
+
+	_, remote := us.Remote("1")
+	assert.False(t, remote)
+	region, remote := us.Remote("2")
+	assert.True(t, remote)
+	assert.Equal(t, "eu", region)
+
+	m := messages.NewMessage(0, messages.ActionChatMessage, &messages.ChatMessage{Mid: 1})
+	assert.NoError(t, us.Forward("eu", "2", m))
+	assert.Len(t, *delivered, 1)
+	e := (*delivered)[0]
+	assert.Equal(t, "us", e.Origin)
+	assert.Equal(t, []string{"us"}, e.Path)
+
+	// the duplicate envelope is dropped.
+	assert.NoError(t, eu.Receive(e))
+	assert.Len(t, *delivered, 1)
+
+	assert.Error(t, us.Forward("ap", "2", m))
+}
+
+func TestFederation_Loop(t *testing.T) {
+	registry := NewMemRegistry("us")
+	us, eu, delivered := newRegions(t, registry)
+
+	// the user moved to us while the envelope is in flight, it's relayed to us and dropped as it loops back.
+	m := messages.NewMessage(0, messages.ActionChatMessage, &messages.ChatMessage{Mid: 1})
+	assert.EqualError(t, us.Forward("eu", "2", m), errLoop)
+	assert.Empty(t, *delivered)
+
+	// the envelope exceeds max hops is not relayed.
+	err := eu.Receive(&Envelope{ID: "ap:1", Origin: "ap", Path: []string{"ap", "sa", "af"}, To: "2", Message: m})
+	assert.EqualError(t, err, errTooManyHops)
+}
+
+func TestFederation_RelayFailed(t *testing.T) {
+	registry := NewMemRegistry("us")
+	registry.Set("3", "ap")
+	_, eu, _ := newRegions(t, registry)
+
+	m := messages.NewMessage(0, messages.ActionChatMessage, &messages.ChatMessage{Mid: 1})
+	e := &Envelope{ID: "us:1", Origin: "us", Path: []string{"us"}, To: "3", Message: m}
+	assert.Error(t, eu.Receive(e))
+
+	// the envelope failed to relay is not taken as duplicate when retried.
+	ap, err := New(&Options{Region: "ap", Registry: registry})
+	assert.NoError(t, err)
+	var delivered []*Envelope
+	ap.SetDeliver(func(e *Envelope) error {
+		delivered = append(delivered, e)
+		return nil
+	})
+	eu.SetPeer("ap", &localPeer{f: ap})
+	assert.NoError(t, eu.Receive(e))
+	assert.Len(t, delivered, 1)
+	assert.Equal(t, []string{"us", "eu"}, delivered[0].Path)
+}
+
+func TestFederation_ServeHTTP(t *testing.T) {
+	registry := NewMemRegistry("eu")
+	eu, err := New(&Options{Region: "eu", Registry: registry, Secret: "secret"})
+	assert.NoError(t, err)
+	var delivered []*Envelope
+	eu.SetDeliver(func(e *Envelope) error {
+		delivered = append(delivered, e)
+		return nil
+	})
+	server := httptest.NewServer(eu)
+	defer server.Close()
+
+	e := &Envelope{ID: "us:1", Origin: "us", Path: []string{"us"}, To: "2", Message: messages.NewEmptyMessage()}
+	assert.NoError(t, NewHTTPPeer(server.URL, "secret", 0).Forward(e))
+	assert.Len(t, delivered, 1)
+	assert.Equal(t, "us:1", delivered[0].ID)
+
+	assert.EqualError(t, NewHTTPPeer(server.URL, "wrong", 0).Forward(e), "unexpected status 403")
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestNew_SecretRequired(t *testing.T) {
+	registry := NewMemRegistry("eu")
+	_, err := New(&Options{Region: "eu", Registry: registry, Addr: ":8090"})
+	assert.EqualError(t, err, errSecretRequired)
+	_, err = New(&Options{Region: "eu", Registry: registry, Peers: map[string]Peer{"us": NewHTTPPeer("http://us", "", 0)}})
+	assert.EqualError(t, err, errSecretRequired)
+
+	// envelopes are rejected without the secret.
+	eu, err := New(&Options{Region: "eu", Registry: registry})
+	assert.NoError(t, err)
+	server := httptest.NewServer(eu)
+	defer server.Close()
+	e := &Envelope{ID: "us:1", Origin: "us", Path: []string{"us"}, To: "2", Message: messages.NewEmptyMessage()}
+	assert.EqualError(t, NewHTTPPeer(server.URL, "", 0).Forward(e), "unexpected status 403")
+}
+
+func TestHTTPRegistry(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("uid") == "unknown" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"region":"eu"}`))
+	}))
+	defer server.Close()
+
+	registry := NewHTTPRegistry(server.URL, 0, 0)
+	for i := 0; i < 2; i++ {
+		region, err := registry.HomeRegion("1")
+		assert.NoError(t, err)
+		assert.Equal(t, "eu", region)
+	}
+	assert.Equal(t, 1, requests)
+	region, err := registry.HomeRegion("unknown")
+	assert.NoError(t, err)
+	assert.Empty(t, region)
+}
@@ -0,0 +1,66 @@
+package federation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/glide-im/glide/pkg/webhook"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultPeerTimeout = time.Second * 5
+
+const (
+	HeaderTimestamp = "X-Glide-Timestamp"
+	// HeaderSignature the hex encoded HMAC-SHA256 of "timestamp.body" with the shared secret, prefixed by "sha256=",
+	// see webhook.Sign.
+	HeaderSignature = "X-Glide-Signature"
+)
+
+// Peer forwards envelopes to the cluster of another region.
+type Peer interface {
+	Forward(e *Envelope) error
+}
+
+type httpPeer struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewHTTPPeer returns the Peer posts envelopes in json to the federation endpoint of another region, see
+// Federation.ServeHTTP, envelopes are signed by the secret shared by regions. The timeout is 5s if not positive.
+func NewHTTPPeer(url string, secret string, timeout time.Duration) Peer {
+	if timeout <= 0 {
+		timeout = defaultPeerTimeout
+	}
+	return &httpPeer{url: url, secret: []byte(secret), client: &http.Client{Timeout: timeout}}
+}
+
+func (h *httpPeer) Forward(e *Envelope) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, ts)
+	if len(h.secret) > 0 {
+		req.Header.Set(HeaderSignature, "sha256="+webhook.Sign(h.secret, ts, body))
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
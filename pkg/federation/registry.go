@@ -0,0 +1,135 @@
+package federation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRegistryTimeout  = time.Second * 3
+	defaultRegistryCacheTTL = time.Minute * 5
+)
+
+// Registry resolves the home region of users, the region where the user connects to and the data of the user resides.
+type Registry interface {
+	// HomeRegion returns the home region of the user, empty if unknown, the message to the user of unknown region is
+	// delivered locally.
+	HomeRegion(uid string) (string, error)
+}
+
+// MemRegistry the registry in memory, users not registered are homed in the default region.
+type MemRegistry struct {
+	mu      sync.RWMutex
+	def     string
+	regions map[string]string
+}
+
+func NewMemRegistry(defaultRegion string) *MemRegistry {
+	return &MemRegistry{def: defaultRegion, regions: map[string]string{}}
+}
+
+// Set sets the home region of the user.
+func (r *MemRegistry) Set(uid string, region string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.regions[uid] = region
+}
+
+// Remove removes the user, the user is homed in the default region.
+func (r *MemRegistry) Remove(uid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.regions, uid)
+}
+
+func (r *MemRegistry) HomeRegion(uid string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if region, ok := r.regions[uid]; ok {
+		return region, nil
+	}
+	return r.def, nil
+}
+
+type cachedRegion struct {
+	region string
+	at     time.Time
+}
+
+type httpRegistry struct {
+	url    string
+	client *http.Client
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	cached map[string]*cachedRegion
+}
+
+// NewHTTPRegistry returns the Registry looks up the home region of users from the business service by GET url?uid=,
+// the response body is {"region": ""} in json, 404 for unknown users. Regions are cached for ttl, default 5 minutes,
+// the timeout is 3s if not positive.
+func NewHTTPRegistry(url string, timeout time.Duration, ttl time.Duration) Registry {
+	if timeout <= 0 {
+		timeout = defaultRegistryTimeout
+	}
+	if ttl <= 0 {
+		ttl = defaultRegistryCacheTTL
+	}
+	return &httpRegistry{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+		ttl:    ttl,
+		cached: map[string]*cachedRegion{},
+	}
+}
+
+func (h *httpRegistry) HomeRegion(uid string) (string, error) {
+	now := time.Now()
+	h.mu.Lock()
+	c, ok := h.cached[uid]
+	h.mu.Unlock()
+	if ok && now.Sub(c.at) < h.ttl {
+		return c.region, nil
+	}
+
+	region, err := h.fetch(uid)
+	if err != nil {
+		return "", err
+	}
+	h.mu.Lock()
+	for k, v := range h.cached {
+		if now.Sub(v.at) >= h.ttl {
+			delete(h.cached, k)
+		}
+	}
+	h.cached[uid] = &cachedRegion{region: region, at: now}
+	h.mu.Unlock()
+	return region, nil
+}
+
+func (h *httpRegistry) fetch(uid string) (string, error) {
+	q := url.Values{}
+	q.Set("uid", uid)
+	resp, err := h.client.Get(h.url + "?" + q.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	body := struct {
+		Region string `json:"region"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.Region, nil
+}
@@ -0,0 +1,198 @@
+package gate
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+)
+
+const (
+	AdmitReasonIPDenied          = "ip_denied"
+	AdmitReasonIPNotAllowed      = "ip_not_allowed"
+	AdmitReasonCountryDenied     = "country_denied"
+	AdmitReasonCountryNotAllowed = "country_not_allowed"
+	AdmitReasonASNDenied         = "asn_denied"
+)
+
+// GeoInfo the geographic information of an ip address.
+type GeoInfo struct {
+	// Country the ISO 3166-1 alpha-2 country code.
+	Country string
+	// ASN the autonomous system number.
+	ASN uint
+}
+
+// GeoIPLookup looks up the geographic information of the ip, such as a MaxMind database reader.
+type GeoIPLookup func(ip net.IP) (*GeoInfo, error)
+
+// AdmissionRules the rules to admit client connections by remote ip.
+type AdmissionRules struct {
+	// Allow the ip or CIDR list to admit, connections from others are rejected if not empty, matched connections skip
+	// the GeoIP rules.
+	Allow []string `json:"allow"`
+	// Deny the ip or CIDR list to reject, it takes precedence over Allow.
+	Deny []string `json:"deny"`
+	// AllowCountries the country codes to admit, connections from others are rejected if not empty.
+	AllowCountries []string `json:"allow_countries"`
+	// DenyCountries the country codes to reject.
+	DenyCountries []string `json:"deny_countries"`
+	// DenyASNs the autonomous system numbers to reject.
+	DenyASNs []uint `json:"deny_asns"`
+}
+
+type compiledRules struct {
+	rules          AdmissionRules
+	allow          []*net.IPNet
+	deny           []*net.IPNet
+	allowCountries map[string]struct{}
+	denyCountries  map[string]struct{}
+	denyASNs       map[uint]struct{}
+}
+
+// Admission decides whether to admit client connections by ip lists and the optional GeoIP lookup, rules can be
+// updated at runtime.
+type Admission struct {
+	mu     sync.RWMutex
+	rules  *compiledRules
+	lookup GeoIPLookup
+}
+
+// NewAdmission creates the Admission with the rules, rules can be nil to admit all connections.
+func NewAdmission(rules *AdmissionRules) (*Admission, error) {
+	a := &Admission{}
+	if rules == nil {
+		rules = &AdmissionRules{}
+	}
+	if err := a.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// SetGeoIPLookup sets the lookup used by country and ASN rules, the GeoIP rules are ignored without lookup.
+func (a *Admission) SetGeoIPLookup(lookup GeoIPLookup) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lookup = lookup
+}
+
+// Rules returns current rules.
+func (a *Admission) Rules() AdmissionRules {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.rules.rules
+}
+
+// SetRules replaces current rules, current rules are kept if any ip or CIDR is invalid.
+func (a *Admission) SetRules(rules *AdmissionRules) error {
+	allow, err := parseCIDRs(rules.Allow)
+	if err != nil {
+		return err
+	}
+	deny, err := parseCIDRs(rules.Deny)
+	if err != nil {
+		return err
+	}
+	c := &compiledRules{
+		rules:          *rules,
+		allow:          allow,
+		deny:           deny,
+		allowCountries: map[string]struct{}{},
+		denyCountries:  map[string]struct{}{},
+		denyASNs:       map[uint]struct{}{},
+	}
+	for _, country := range rules.AllowCountries {
+		c.allowCountries[strings.ToUpper(country)] = struct{}{}
+	}
+	for _, country := range rules.DenyCountries {
+		c.denyCountries[strings.ToUpper(country)] = struct{}{}
+	}
+	for _, asn := range rules.DenyASNs {
+		c.denyASNs[asn] = struct{}{}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rules = c
+	return nil
+}
+
+// Admit returns whether to admit the connection from ip, and the reason if rejected. Connections are admitted if
+// GeoIP lookup failed.
+func (a *Admission) Admit(ip string) (bool, string) {
+	a.mu.RLock()
+	rules, lookup := a.rules, a.lookup
+	a.mu.RUnlock()
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		if len(rules.allow) > 0 {
+			return false, AdmitReasonIPNotAllowed
+		}
+		return true, ""
+	}
+	if containsIP(rules.deny, addr) {
+		return false, AdmitReasonIPDenied
+	}
+	if len(rules.allow) > 0 {
+		if !containsIP(rules.allow, addr) {
+			return false, AdmitReasonIPNotAllowed
+		}
+		return true, ""
+	}
+
+	hasGeoRules := len(rules.allowCountries) > 0 || len(rules.denyCountries) > 0 || len(rules.denyASNs) > 0
+	if lookup == nil || !hasGeoRules {
+		return true, ""
+	}
+	geo, err := lookup(addr)
+	if err != nil || geo == nil {
+		log.W("[admission] lookup geo info of %s error: %v", ip, err)
+		return true, ""
+	}
+	country := strings.ToUpper(geo.Country)
+	if _, ok := rules.denyCountries[country]; ok {
+		return false, AdmitReasonCountryDenied
+	}
+	if _, ok := rules.allowCountries[country]; len(rules.allowCountries) > 0 && !ok {
+		return false, AdmitReasonCountryNotAllowed
+	}
+	if _, ok := rules.denyASNs[geo.ASN]; ok {
+		return false, AdmitReasonASNDenied
+	}
+	return true, ""
+}
+
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	var ret []*net.IPNet
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, errors.New("invalid ip: " + s)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			ret = append(ret, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, n)
+	}
+	return ret, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package gate
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestAdmission_IPLists(t *testing.T) {
+	a, err := NewAdmission(&AdmissionRules{
+		Allow: []string{"192.168.0.0/16", "::1"},
+		Deny:  []string{"192.168.1.1"},
+	})
+	assert.NoError(t, err)
+
+	cases := map[string]string{
+		"192.168.0.1": "",
+		"192.168.1.1": AdmitReasonIPDenied,
+		"10.0.0.1":    AdmitReasonIPNotAllowed,
+		"::1":         "",
+	}
+	for ip, reason := range cases {
+		ok, r := a.Admit(ip)
+		assert.Equal(t, reason == "", ok, ip)
+		assert.Equal(t, reason, r, ip)
+	}
+
+	_, err = NewAdmission(&AdmissionRules{Deny: []string{"not-an-ip"}})
+	assert.Error(t, err)
+}
+
+func TestAdmission_GeoIP(t *testing.T) {
+	a, _ := NewAdmission(&AdmissionRules{
+		DenyCountries: []string{"xx"},
+		DenyASNs:      []uint{64512},
+	})
+	geo := map[string]*GeoInfo{
+		"1.1.1.1": {Country: "XX"},
+		"2.2.2.2": {Country: "YY", ASN: 64512},
+		"3.3.3.3": {Country: "YY", ASN: 1},
+	}
+	a.SetGeoIPLookup(func(ip net.IP) (*GeoInfo, error) {
+		g, ok := geo[ip.String()]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return g, nil
+	})
+
+	ok, reason := a.Admit("1.1.1.1")
+	assert.False(t, ok)
+	assert.Equal(t, AdmitReasonCountryDenied, reason)
+	_, reason = a.Admit("2.2.2.2")
+	assert.Equal(t, AdmitReasonASNDenied, reason)
+	ok, _ = a.Admit("3.3.3.3")
+	assert.True(t, ok)
+	// lookup failed
+	ok, _ = a.Admit("4.4.4.4")
+	assert.True(t, ok)
+
+	_ = a.SetRules(&AdmissionRules{AllowCountries: []string{"XX"}})
+	_, reason = a.Admit("3.3.3.3")
+	assert.Equal(t, AdmitReasonCountryNotAllowed, reason)
+}
@@ -0,0 +1,185 @@
+package gate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/glide-im/glide/pkg/audit"
+	"github.com/glide-im/glide/pkg/conn"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
+	"net/http"
+	"time"
+)
+
+const defaultAnomalyDetectorTimeout = time.Second * 3
+
+// AnomalyStage the stage of the connection scored.
+type AnomalyStage string
+
+const (
+	// AnomalyStageConnect the connection is accepted, the client is not authenticated.
+	AnomalyStageConnect AnomalyStage = "connect"
+	// AnomalyStageAuthenticate the client is authenticated, the ID is the id of user.
+	AnomalyStageAuthenticate AnomalyStage = "authenticate"
+)
+
+// AnomalyAction the action applied to the connection scored.
+type AnomalyAction string
+
+const (
+	AnomalyAllow AnomalyAction = "allow"
+	// AnomalyStepUp requires the client to solve a challenge before sending messages, see ChallengeGuard.StepUp.
+	AnomalyStepUp AnomalyAction = "step_up"
+	// AnomalyDisconnect kicks out the client with messages.KickOutCodeAnomaly.
+	AnomalyDisconnect AnomalyAction = "disconnect"
+)
+
+// AnomalyVerdict the result of scoring a connection.
+type AnomalyVerdict struct {
+	// Score the risk score of the connection, it's only logged by the gateway.
+	Score  float64       `json:"score"`
+	Action AnomalyAction `json:"action"`
+	// Reason why the action is taken, it's recorded in the audit log and not sent to the client.
+	Reason string `json:"reason"`
+}
+
+// AnomalyDetector scores connections by the fingerprint and attributes of the client, implemented by fraud and abuse
+// teams to step up or disconnect suspicious connections. Nil verdict is allow.
+type AnomalyDetector interface {
+	Score(stage AnomalyStage, info *Info) (*AnomalyVerdict, error)
+}
+
+// AnomalyDetectorFunc the function implements AnomalyDetector.
+type AnomalyDetectorFunc func(stage AnomalyStage, info *Info) (*AnomalyVerdict, error)
+
+func (f AnomalyDetectorFunc) Score(stage AnomalyStage, info *Info) (*AnomalyVerdict, error) {
+	return f(stage, info)
+}
+
+// AnomalyGuard scores clients by the AnomalyDetector when connected and authenticated, and applies the verdicts.
+// Connections are scored asynchronously, so a slow detector never blocks the gateway, the connection is allowed if
+// the detector fails.
+type AnomalyGuard struct {
+	detector  AnomalyDetector
+	gateway   DefaultGateway
+	challenge *ChallengeGuard
+}
+
+// NewAnomalyGuard creates the AnomalyGuard, the step up verdict is ignored if challenge is nil.
+func NewAnomalyGuard(detector AnomalyDetector, gateway DefaultGateway, challenge *ChallengeGuard) *AnomalyGuard {
+	return &AnomalyGuard{
+		detector:  detector,
+		gateway:   gateway,
+		challenge: challenge,
+	}
+}
+
+// Subscribe scores clients connected and authenticated of the bus, it returns the function to cancel.
+func (a *AnomalyGuard) Subscribe(bus *EventBus) (unsubscribe func()) {
+	return bus.Subscribe(func(e *Event) {
+		stage := AnomalyStageConnect
+		if e.Type == EventClientAuthenticated {
+			stage = AnomalyStageAuthenticate
+		}
+		info := e.Client
+		go a.Check(stage, &info)
+	}, EventClientConnected, EventClientAuthenticated)
+}
+
+// Check scores the client and applies the verdict.
+func (a *AnomalyGuard) Check(stage AnomalyStage, info *Info) {
+	v, err := a.detector.Score(stage, info)
+	if err != nil {
+		log.W("[anomaly] score client %s error: %v", info.ID, err)
+		return
+	}
+	if v == nil || v.Action == "" {
+		v = &AnomalyVerdict{Action: AnomalyAllow}
+	}
+	metrics.AnomalyVerdicts.WithLabelValues(string(stage), string(v.Action)).Inc()
+
+	switch v.Action {
+	case AnomalyAllow:
+	case AnomalyStepUp:
+		if a.challenge == nil {
+			log.W("[anomaly] step up client %s is ignored, no challenger set", info.ID)
+			return
+		}
+		c := a.gateway.GetClient(info.ID)
+		if c == nil {
+			return
+		}
+		log.I("[anomaly] step up client %s, score=%v, reason=%s", info.ID, v.Score, v.Reason)
+		a.challenge.StepUp(c)
+	case AnomalyDisconnect:
+		log.I("[anomaly] disconnect client %s, score=%v, reason=%s", info.ID, v.Score, v.Reason)
+		kickOut := &messages.KickOutNotify{Code: messages.KickOutCodeAnomaly}
+		_ = a.gateway.EnqueueMessage(info.ID, messages.NewMessage(0, messages.ActionNotifyKickOut, kickOut))
+		audit.Record(audit.EventKick, info.ID.UID(), string(info.ID), map[string]string{"reason": "anomaly: " + v.Reason})
+		if err = a.gateway.ExitClient(info.ID); err != nil && err != ErrClientNotExist {
+			log.E("[anomaly] exit client %s error: %v", info.ID, err)
+		}
+	default:
+		log.W("[anomaly] unknown action %s of client %s", v.Action, info.ID)
+	}
+}
+
+// anomalyRequest the request body of the http anomaly detector.
+type anomalyRequest struct {
+	Stage        AnomalyStage      `json:"stage"`
+	UID          string            `json:"uid"`
+	Device       string            `json:"device"`
+	ConnectionID string            `json:"connection_id"`
+	Version      string            `json:"version"`
+	Addr         string            `json:"addr"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	Fingerprint  *conn.Fingerprint `json:"fingerprint,omitempty"`
+}
+
+type httpAnomalyDetector struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPAnomalyDetector returns the AnomalyDetector posts the stage, id, address and fingerprint of client in json to
+// the url of business service, the response body is AnomalyVerdict in json, 204 to allow. The uid is temporary at
+// the connect stage. The timeout is 3s if not positive.
+func NewHTTPAnomalyDetector(url string, timeout time.Duration) AnomalyDetector {
+	if timeout <= 0 {
+		timeout = defaultAnomalyDetectorTimeout
+	}
+	return &httpAnomalyDetector{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (h *httpAnomalyDetector) Score(stage AnomalyStage, info *Info) (*AnomalyVerdict, error) {
+	body, err := json.Marshal(&anomalyRequest{
+		Stage:        stage,
+		UID:          info.ID.UID(),
+		Device:       info.ID.Device(),
+		ConnectionID: info.ConnectionId,
+		Version:      info.Version,
+		Addr:         info.CliAddr,
+		Attributes:   info.Attributes,
+		Fingerprint:  info.Fingerprint,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	v := &AnomalyVerdict{}
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
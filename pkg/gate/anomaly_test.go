@@ -0,0 +1,117 @@
+package gate
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/glide-im/glide/pkg/conn"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAnomalyGuard_StepUp(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+	id := NewID("g1", "1", "1")
+	c := &recordClient{mockClient: mockClient{info: Info{ID: id, CliAddr: "1.2.3.4:5678"}, running: true}}
+	gateway.AddClient(c)
+
+	challenge := NewChallengeGuard(NewCaptchaChallenger("site", func(token string, ip string) error {
+		if token != "ok" {
+			return errors.New("invalid token")
+		}
+		return nil
+	}), time.Minute)
+	guard := NewAnomalyGuard(AnomalyDetectorFunc(func(stage AnomalyStage, info *Info) (*AnomalyVerdict, error) {
+		return &AnomalyVerdict{Score: 0.9, Action: AnomalyStepUp, Reason: "headless"}, nil
+	}), gateway, challenge)
+
+	guard.Check(AnomalyStageAuthenticate, &c.info)
+	assert.Equal(t, messages.ActionChallenge, c.last().GetAction())
+
+	chat := messages.NewMessage(1, messages.ActionChatMessage, nil)
+	handled, _ := challenge.Middleware(c, chat)
+	assert.True(t, handled)
+	assert.Equal(t, messages.ActionNotifyError, c.last().GetAction())
+	handled, _ = challenge.Middleware(c, messages.NewMessage(0, messages.ActionHeartbeat, nil))
+	assert.False(t, handled)
+
+	// the client is still stepped up after a wrong answer.
+	_, _ = challenge.Middleware(c, messages.NewMessage(2, messages.ActionChallengeAnswer, &messages.ChallengeAnswer{Token: "bad"}))
+	handled, _ = challenge.Middleware(c, chat)
+	assert.True(t, handled)
+
+	_, _ = challenge.Middleware(c, messages.NewMessage(3, messages.ActionChallengeAnswer, &messages.ChallengeAnswer{Token: "ok"}))
+	assert.Equal(t, messages.ActionNotifySuccess, c.last().GetAction())
+	handled, _ = challenge.Middleware(c, chat)
+	assert.False(t, handled)
+}
+
+func TestAnomalyGuard_Disconnect(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+	id := NewID("g1", "1", "1")
+	c := &recordClient{mockClient: mockClient{info: Info{ID: id}, running: true}}
+	gateway.AddClient(c)
+
+	action := AnomalyAllow
+	guard := NewAnomalyGuard(AnomalyDetectorFunc(func(stage AnomalyStage, info *Info) (*AnomalyVerdict, error) {
+		return &AnomalyVerdict{Action: action}, nil
+	}), gateway, nil)
+
+	guard.Check(AnomalyStageAuthenticate, &c.info)
+	assert.NotNil(t, gateway.GetClient(id))
+
+	// step up without challenge is ignored.
+	action = AnomalyStepUp
+	guard.Check(AnomalyStageAuthenticate, &c.info)
+	assert.NotNil(t, gateway.GetClient(id))
+	assert.Empty(t, c.received)
+
+	action = AnomalyDisconnect
+	guard.Check(AnomalyStageAuthenticate, &c.info)
+	assert.Nil(t, gateway.GetClient(id))
+	kickOut := &messages.KickOutNotify{}
+	assert.Equal(t, messages.ActionNotifyKickOut, c.last().GetAction())
+	assert.NoError(t, c.last().Data.Deserialize(kickOut))
+	assert.Equal(t, messages.KickOutCodeAnomaly, kickOut.Code)
+	assert.Empty(t, kickOut.Reason)
+}
+
+func TestHTTPAnomalyDetector(t *testing.T) {
+	var req anomalyRequest
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			_ = json.NewEncoder(w).Encode(&AnomalyVerdict{Score: 0.8, Action: AnomalyDisconnect, Reason: "bot"})
+		}
+	}))
+	defer srv.Close()
+
+	d := NewHTTPAnomalyDetector(srv.URL, 0)
+	info := &Info{ID: NewID("g1", "1", "2"), Fingerprint: &conn.Fingerprint{JA3: "abc", UserAgent: "ua"}}
+	v, err := d.Score(AnomalyStageAuthenticate, info)
+	assert.NoError(t, err)
+	assert.Equal(t, AnomalyDisconnect, v.Action)
+	assert.Equal(t, "bot", v.Reason)
+	assert.Equal(t, AnomalyStageAuthenticate, req.Stage)
+	assert.Equal(t, "1", req.UID)
+	assert.Equal(t, "2", req.Device)
+	assert.Equal(t, "abc", req.Fingerprint.JA3)
+
+	status = http.StatusNoContent
+	v, err = d.Score(AnomalyStageConnect, info)
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	status = http.StatusInternalServerError
+	_, err = d.Score(AnomalyStageConnect, info)
+	assert.Error(t, err)
+}
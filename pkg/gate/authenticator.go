@@ -5,13 +5,16 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"crypto/sha512"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
-	"github.com/glide-im/glide/pkg/hash"
-	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/audit"
 	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
+	"github.com/glide-im/glide/pkg/tracing"
+	"go.opentelemetry.io/otel/codes"
 	"strings"
 	"time"
 )
@@ -82,6 +85,9 @@ func (a *AesCBCCrypto) DecryptCredentials(src []byte) (*ClientAuthCredentials, e
 	if err != nil {
 		return nil, err
 	}
+	if len(encrypt) <= aes.BlockSize || len(encrypt)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid credentials length")
+	}
 	var iv []byte
 	iv = append(iv, encrypt[:aes.BlockSize]...)
 	var encryptBody []byte
@@ -163,26 +169,171 @@ func (a *AesCBCCrypto) cbcIVPending(iv []byte, blockSize int) []byte {
 	return iv
 }
 
+// credentialV2Prefix the prefix of credentials encrypted by AesGCMCrypto, credentials without it are the legacy
+// AesCBCCrypto credentials.
+const credentialV2Prefix = "v2."
+
+const errCredentialInvalid = "invalid credentials"
+
+// AesGCMCrypto encrypts credentials with AES-GCM, the credential is "v2." followed by the base64 of the timestamp,
+// the random nonce and the sealed json credentials. The gateway id and the timestamp are authenticated as additional
+// data, the credentials bound to a gateway are rejected by others.
+type AesGCMCrypto struct {
+	aead cipher.AEAD
+	// Gateway the id of gateway the credentials are bound to.
+	Gateway string
+}
+
+// NewAesGCMCrypto returns the AES-256-GCM crypto of the key derived from the secret.
+func NewAesGCMCrypto(secret string, gateway string) (*AesGCMCrypto, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AesGCMCrypto{aead: aead, Gateway: gateway}, nil
+}
+
+func (a *AesGCMCrypto) additionalData(timestamp []byte) []byte {
+	return append([]byte(a.Gateway+"|"), timestamp...)
+}
+
+func (a *AesGCMCrypto) EncryptCredentials(c *ClientAuthCredentials) ([]byte, error) {
+	jsonBytes, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 8+a.aead.NonceSize())
+	binary.BigEndian.PutUint64(header, uint64(c.Timestamp))
+	if _, err = rand.Read(header[8:]); err != nil {
+		return nil, err
+	}
+	sealed := a.aead.Seal(header, header[8:], jsonBytes, a.additionalData(header[:8]))
+
+	b64Bytes := make([]byte, len(credentialV2Prefix)+base64.RawStdEncoding.EncodedLen(len(sealed)))
+	copy(b64Bytes, credentialV2Prefix)
+	base64.RawStdEncoding.Encode(b64Bytes[len(credentialV2Prefix):], sealed)
+	return b64Bytes, nil
+}
+
+func (a *AesGCMCrypto) DecryptCredentials(src []byte) (*ClientAuthCredentials, error) {
+	if !bytes.HasPrefix(src, []byte(credentialV2Prefix)) {
+		return nil, errors.New(errCredentialInvalid)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(string(src[len(credentialV2Prefix):]))
+	if err != nil {
+		return nil, err
+	}
+	headerLen := 8 + a.aead.NonceSize()
+	if len(sealed) < headerLen+a.aead.Overhead() {
+		return nil, errors.New("invalid credentials length")
+	}
+	jsonBytes, err := a.aead.Open(nil, sealed[8:headerLen], sealed[headerLen:], a.additionalData(sealed[:8]))
+	if err != nil {
+		return nil, err
+	}
+	credentials := ClientAuthCredentials{}
+	if err = json.Unmarshal(jsonBytes, &credentials); err != nil {
+		return nil, err
+	}
+	if uint64(credentials.Timestamp) != binary.BigEndian.Uint64(sealed[:8]) {
+		return nil, errors.New(errCredentialInvalid)
+	}
+	return &credentials, nil
+}
+
 // Authenticator handle client authentication message
 type Authenticator struct {
-	credentialCrypto CredentialCrypto
-	gateway          DefaultGateway
+	keys    *KeyRing
+	gateway DefaultGateway
+
+	// replayGuard checks signed tickets, nil to accept the legacy ticket only.
+	replayGuard *ReplayGuard
+	// requireSigned rejects the legacy ticket which can be replayed.
+	requireSigned bool
+	// ticketProvider provides the message deliver secret of credentials without one, optional.
+	ticketProvider TicketProvider
 }
 
 func NewAuthenticator(gateway DefaultGateway, key string) *Authenticator {
-	k := sha512.New().Sum([]byte(key))
 	return &Authenticator{
-		credentialCrypto: NewAesCBCCrypto(k),
-		gateway:          gateway,
+		keys:    NewKeyRing(DefaultKeyID, key),
+		gateway: gateway,
 	}
 }
 
+// KeyRing returns the keys to decrypt client credentials.
+func (a *Authenticator) KeyRing() *KeyRing {
+	return a.keys
+}
+
+// SetReplayProtection accepts the signed ticket with nonce and timestamp in window, see SignTicket, and rejects the
+// legacy ticket if requireSigned. Used nonces are remembered in nonces shared by the cluster, nil to remember in memory.
+func (a *Authenticator) SetReplayProtection(window time.Duration, requireSigned bool, nonces NonceStore) {
+	a.replayGuard = NewReplayGuard(window, nonces)
+	a.requireSigned = requireSigned
+}
+
+// SetTicketProvider sets the provider of message deliver secret of credentials without one.
+func (a *Authenticator) SetTicketProvider(p TicketProvider) {
+	a.ticketProvider = p
+}
+
+// fetchTicketSecret sets the message deliver secret fetched from the TicketProvider to credentials without one.
+func (a *Authenticator) fetchTicketSecret(c *ClientAuthCredentials) {
+	if a.ticketProvider == nil || (c.Secrets != nil && c.Secrets.MessageDeliverSecret != "") {
+		return
+	}
+	secret, err := a.ticketProvider.TicketSecret(c.UserID, c.DeviceID)
+	if err != nil {
+		log.E("fetch ticket secret of %s error: %v", c.UserID, err)
+		return
+	}
+	secrets := ClientSecrets{}
+	if c.Secrets != nil {
+		secrets = *c.Secrets
+	}
+	secrets.MessageDeliverSecret = secret
+	c.Secrets = &secrets
+}
+
+// verifyTicket verifies the signed ticket if replay protection enabled, otherwise the legacy ticket, returns true if
+// the ticket is issued by BypassTicketKey.
+func (a *Authenticator) verifyTicket(secret string, from string, to string, ticket string) (bool, error) {
+	if a.replayGuard != nil && strings.Contains(ticket, ".") {
+		t, err := VerifyTicket(secret, from, to, ticket)
+		if err != nil {
+			return false, err
+		}
+		return t.Bypass, a.replayGuard.Check(from, t)
+	}
+	if a.requireSigned {
+		return false, errors.New(errTicketInvalid)
+	}
+	// sha1 hash
+	if len(ticket) != 40 {
+		return false, errors.New(errTicketInvalid)
+	}
+	ticket = strings.ToUpper(ticket)
+	if ticket == strings.ToUpper(TicketKey(secret, from, to)) {
+		return false, nil
+	}
+	if ticket == strings.ToUpper(BypassTicketKey(secret, from, to)) {
+		return true, nil
+	}
+	return false, errors.New(errTicketExpired)
+}
+
 func (a *Authenticator) MessageInterceptor(dc DefaultClient, msg *messages.GlideMessage) bool {
 
 	if dc.GetCredentials() == nil {
 		return false
 	}
-	switch msg.Action {
+	switch msg.GetAction() {
 	case messages.ActionGroupMessage, messages.ActionChatMessage, messages.ActionChatMessageResend:
 		break
 	default:
@@ -190,42 +341,40 @@ func (a *Authenticator) MessageInterceptor(dc DefaultClient, msg *messages.Glide
 	}
 
 	if dc.GetCredentials() == nil || dc.GetCredentials().Secrets == nil {
-		_ = a.gateway.EnqueueMessage(dc.GetInfo().ID, messages.NewMessage(msg.GetSeq(), messages.ActionNotifyForbidden, "no credentials"))
+		_ = a.gateway.EnqueueMessage(dc.GetInfo().ID, messages.NewErrorMessage(msg.GetSeq(), messages.ActionNotifyForbidden, errNoCredentials))
+		auditAuthFailure(dc.GetInfo().ID, errNoCredentials)
 		return true
 	}
 
 	secret := dc.GetCredentials().Secrets.MessageDeliverSecret
 	if secret == "" {
-		_ = a.gateway.EnqueueMessage(dc.GetInfo().ID, messages.NewMessage(msg.GetSeq(), messages.ActionNotifyForbidden, "no message deliver secret"))
+		_ = a.gateway.EnqueueMessage(dc.GetInfo().ID, messages.NewErrorMessage(msg.GetSeq(), messages.ActionNotifyForbidden, errSignNoSecret))
+		auditAuthFailure(dc.GetInfo().ID, errSignNoSecret)
 		return true
 	}
 
-	var ticket = msg.Ticket
-	// sha1 hash
-	if len(ticket) != 40 {
-		_ = a.gateway.EnqueueMessage(dc.GetInfo().ID, messages.NewMessage(msg.GetSeq(), messages.ActionNotifyForbidden, "invalid ticket"))
-		return true
-	}
-	sum1 := hash.SHA1(secret + msg.To)
 	id := dc.GetInfo().ID
-	expectTicket := hash.SHA1(secret + id.UID() + sum1)
-
-	if strings.ToUpper(ticket) != strings.ToUpper(expectTicket) {
-		logger.I("invalid ticket, expected=%s, actually=%s, secret=%s, to=%s, from=%s", expectTicket, ticket, secret, msg.To, id.UID())
-		// invalid ticket
-		_ = a.gateway.EnqueueMessage(dc.GetInfo().ID, messages.NewMessage(msg.GetSeq(), messages.ActionNotifyForbidden, "ticket expired"))
+	bypass, err := a.verifyTicket(secret, id.UID(), msg.To, msg.Ticket)
+	if err != nil {
+		log.I("invalid ticket %s, to=%s, from=%s: %v", msg.Ticket, msg.To, id.UID(), err)
+		_ = a.gateway.EnqueueMessage(dc.GetInfo().ID, messages.NewErrorMessage(msg.GetSeq(), messages.ActionNotifyForbidden, err.Error()))
+		auditAuthFailure(id, err.Error())
 		return true
 	}
+	msg.Bypass = bypass
 	return false
 }
 
 func (a *Authenticator) ClientAuthMessageInterceptor(dc DefaultClient, msg *messages.GlideMessage) (intercept bool) {
-	if msg.Action != messages.ActionAuthenticate {
+	if msg.GetAction() != messages.ActionAuthenticate {
 		return false
 	}
 
 	intercept = true
 
+	_, traceSpan := tracing.Start(msg, "gate.auth")
+	defer traceSpan.End()
+
 	var err error
 	var errMsg string
 	var newId ID
@@ -235,67 +384,137 @@ func (a *Authenticator) ClientAuthMessageInterceptor(dc DefaultClient, msg *mess
 	credential := EncryptedCredential{}
 	err = msg.Data.Deserialize(&credential)
 	if err != nil {
-		errMsg = "invalid authenticate message"
+		errMsg = errInvalidAuthMessage
 		goto DONE
 	}
 
 	if len(credential.Credential) < 5 {
-		errMsg = "invalid authenticate message"
+		errMsg = errInvalidAuthMessage
 		goto DONE
 	}
 
-	authCredentials, err = a.credentialCrypto.DecryptCredentials([]byte(credential.Credential))
+	authCredentials, err = a.keys.Decrypt(&credential)
 	if err != nil {
-		errMsg = "invalid authenticate message"
+		errMsg = errInvalidAuthMessage
 		goto DONE
 	}
 
 	span = time.Now().UnixMilli() - authCredentials.Timestamp
 	if span > 1500*1000 {
-		errMsg = "credential expired"
+		errMsg = errCredentialExpired
 		goto DONE
 	}
+	if credential.Capabilities != nil {
+		authCredentials.Capabilities = credential.Capabilities
+	}
 
 	newId, err = a.updateClient(dc, authCredentials)
 
 DONE:
 
 	ac, _ := json.Marshal(authCredentials)
-	logger.D("credential: %s", string(ac))
-
-	logger.D("client auth message intercepted %s, %v", dc.GetInfo().ID, err)
-
+	log.D("credential: %s", string(ac))
+
+	log.D("client auth message intercepted %s, %v", dc.GetInfo().ID, err)
+
+	if IsUserConnectionsExceeded(err) {
+		// the user reaches the max connections, rejects the connection.
+		metrics.ConnectionsRejected.WithLabelValues(QuotaReasonUserConnections).Inc()
+		traceSpan.SetStatus(codes.Error, err.Error())
+		id := dc.GetInfo().ID
+		busy := &messages.ServerBusy{Code: messages.ServerBusyUserConnections, Reason: err.Error()}
+		_ = a.gateway.EnqueueMessage(id, messages.NewMessage(msg.GetSeq(), messages.ActionNotifyServerBusy, busy))
+		_ = a.gateway.ExitClient(id)
+		return
+	}
 	if err != nil || errMsg != "" {
-		_ = a.gateway.EnqueueMessage(dc.GetInfo().ID, messages.NewMessage(msg.GetSeq(), messages.ActionNotifyError, errMsg))
+		reason := errMsg
+		if reason == "" {
+			reason = err.Error()
+		}
+		auditAuthFailure(dc.GetInfo().ID, reason)
+		traceSpan.SetStatus(codes.Error, reason)
+		_ = a.gateway.EnqueueMessage(dc.GetInfo().ID, messages.NewErrorMessage(msg.GetSeq(), messages.ActionNotifyError, reason))
 	} else {
-		_ = a.gateway.EnqueueMessage(newId, messages.NewMessage(msg.GetSeq(), messages.ActionNotifySuccess, nil))
+		var result *messages.AuthResult
+		if issuer, ok := a.gateway.(resumeTokenIssuer); ok {
+			result = issuer.issueResumeToken(newId, authCredentials)
+		}
+		_ = a.gateway.EnqueueMessage(newId, messages.NewMessage(msg.GetSeq(), messages.ActionNotifySuccess, result))
+		if pusher, ok := a.gateway.(remoteConfigPusher); ok {
+			pusher.pushRemoteConfig(dc)
+		}
 	}
 	return
 }
 
 func (a *Authenticator) updateClient(dc DefaultClient, authCredentials *ClientAuthCredentials) (ID, error) {
 
+	a.fetchTicketSecret(authCredentials)
+
+	var device, kicked *messages.DeviceInfo
+	notifier, notify := a.gateway.(loginNotifier)
+	if notify {
+		device, kicked = notifier.loginDevices(dc, authCredentials.UserID)
+	}
+	if device != nil {
+		// the credentials is not set to the client until bound.
+		device.DeviceId = authCredentials.DeviceID
+		device.DeviceName = authCredentials.DeviceName
+		device.DeviceType = authCredentials.Type
+	}
+	id, err := bindClientID(a.gateway, dc.GetInfo().ID, authCredentials.UserID, &messages.KickOutNotify{
+		DeviceName: authCredentials.DeviceName,
+		DeviceId:   authCredentials.DeviceID,
+		Device:     device,
+	})
+	if err != nil {
+		return id, err
+	}
 	dc.SetCredentials(authCredentials)
+	if notify {
+		notifier.notifyLogin(id, device, kicked)
+	}
+	if authCredentials.GuestID != "" {
+		if upgrader, ok := a.gateway.(guestUpgrader); ok {
+			upgrader.upgradeGuest(id, authCredentials.GuestID)
+		}
+	}
+	if len(authCredentials.Attributes) > 0 {
+		if updater, ok := a.gateway.(AttributeUpdater); ok {
+			err = updater.SetClientAttributes(id, authCredentials.Attributes, true)
+		}
+	}
+	return id, err
+}
 
-	oldID := dc.GetInfo().ID
-	newID := NewID2(authCredentials.UserID)
-	err := a.gateway.SetClientID(oldID, newID)
+// auditAuthFailure counts and records the authentication failure of the client.
+func auditAuthFailure(id ID, reason string) {
+	metrics.AuthFailures.Inc()
+	audit.Record(audit.EventAuthFailure, id.UID(), string(id), map[string]string{"reason": reason})
+}
+
+// bindClientID sets the id of authenticated user to the client of oldID, the client logged in with the same id is
+// kicked out with the notify, unless the conflict resolver of gateway rejects the client of oldID.
+func bindClientID(gateway Gateway, oldID ID, uid string, notify *messages.KickOutNotify) (ID, error) {
+	newID := NewID2(uid)
+	err := gateway.SetClientID(oldID, newID)
+	if IsAlreadyAuthenticated(err) {
+		return newID, nil
+	}
 	if IsIDAlreadyExist(err) {
-		if newID.Equals(oldID) {
-			// already authenticated
-			return newID, nil
+		if r, ok := gateway.(conflictResolver); ok && r.resolveConflict(newID, oldID) == ConflictRejectIncoming {
+			return "", ErrIDConflict
 		}
 		tempID, _ := GenTempID("")
-		err = a.gateway.SetClientID(newID, tempID)
+		err = gateway.SetClientID(newID, tempID)
 		if err != nil {
 			return "", err
 		}
-		kickOut := messages.NewMessage(0, messages.ActionNotifyKickOut, &messages.KickOutNotify{
-			DeviceName: authCredentials.DeviceName,
-			DeviceId:   authCredentials.DeviceID,
-		})
-		_ = a.gateway.EnqueueMessage(tempID, kickOut)
-		err = a.gateway.SetClientID(oldID, newID)
+		kickOut := messages.NewMessage(0, messages.ActionNotifyKickOut, notify)
+		_ = gateway.EnqueueMessage(tempID, kickOut)
+		audit.Record(audit.EventKick, uid, string(newID), map[string]string{"reason": "login on another connection"})
+		err = gateway.SetClientID(oldID, newID)
 		if err != nil {
 			return "", err
 		}
@@ -0,0 +1,105 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
+	"sync/atomic"
+)
+
+const (
+	errInvalidBatch        = "invalid batch"
+	errBatchUnsupported    = "batch is not supported by the protocol version"
+	errBatchItemNotAllowed = "action is not allowed in batch"
+	errBatchItemRejected   = "action is not accepted"
+)
+
+// batchWriter is implemented by clients support sending messages in a batch, see messages.Batch.
+type batchWriter interface {
+
+	// EnqueueBatch enqueues messages in compressed batches, messages are enqueued one by one if the client does not
+	// support batch.
+	EnqueueBatch(ms []*messages.GlideMessage) error
+}
+
+var _ batchWriter = (*UserClient)(nil)
+
+// handleBatch replies the result of each item of the batch, and dispatches items accepted in order. Batches are
+// accepted from authenticated clients only, items of the session actions such as authenticate are rejected.
+func (c *UserClient) handleBatch(m *messages.GlideMessage) {
+	if c.info.ID.IsTemp() {
+		_ = c.EnqueueMessage(messages.NewMessage(0, messages.ActionNotifyUnauthenticated, nil))
+		return
+	}
+	if !messages.HasFeature(atomic.LoadInt64(&c.protocol), messages.FeatureBatch) {
+		_ = c.EnqueueMessage(messages.NewErrorReply(m, errBatchUnsupported))
+		return
+	}
+	batch := messages.Batch{}
+	if err := m.Data.Deserialize(&batch); err != nil {
+		_ = c.EnqueueMessage(messages.NewErrorReply(m, errInvalidBatch))
+		return
+	}
+	items, err := batch.Messages()
+	if err != nil {
+		_ = c.EnqueueMessage(messages.NewErrorReply(m, err.Error()))
+		return
+	}
+
+	result := &messages.BatchResult{Results: make([]*messages.BatchItemResult, 0, len(items))}
+	for _, item := range items {
+		r := &messages.BatchItemResult{Seq: item.GetSeq(), Ok: true}
+		if item.GetAction().Group() == messages.GroupSession {
+			r.Ok, r.Error = false, messages.NewError(errBatchItemNotAllowed)
+		} else if reason := c.rejectReason(item.GetAction()); reason != "" {
+			metrics.ActionsRejected.WithLabelValues(reason).Inc()
+			r.Ok, r.Error = false, messages.NewError(errBatchItemRejected).WithDetail("reason", reason)
+		}
+		result.Results = append(result.Results, r)
+	}
+	// the result is sent before the responses of items.
+	_ = c.EnqueueMessage(messages.NewReply(m, messages.ActionBatchResult, result))
+	for i, item := range items {
+		if result.Results[i].Ok {
+			c.dispatch(item)
+		}
+	}
+}
+
+// EnqueueBatch enqueues messages in compressed batches if the client supports batch, the items are adapted and
+// translated as the messages written one by one.
+func (c *UserClient) EnqueueBatch(ms []*messages.GlideMessage) error {
+	p := atomic.LoadInt64(&c.protocol)
+	if !messages.HasFeature(p, messages.FeatureBatch) || len(ms) == 1 {
+		for _, m := range ms {
+			if err := c.EnqueueMessage(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, items := range messages.SplitBatches(ms) {
+		translated := make([]*messages.GlideMessage, 0, len(items))
+		for _, m := range items {
+			m, ok := c.adapt(m)
+			if !ok {
+				continue
+			}
+			m = c.localize(m)
+			if p < messages.ProtocolCurrent {
+				m = messages.Downgrade(m, p)
+			}
+			translated = append(translated, m)
+		}
+		if len(translated) == 0 {
+			continue
+		}
+		batch, err := messages.NewCompressedBatch(translated)
+		if err != nil {
+			return err
+		}
+		if err = c.EnqueueMessage(messages.NewMessage(0, messages.ActionBatch, batch)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newBatchClient(handler MessageHandler) *UserClient {
+	fn, _ := mockReadFn()
+	client := NewClient(&mockConnection{mockRead: fn}, mockGateway{}, handler).(*UserClient)
+	client.SetID(NewID2("1"))
+	client.dispatch(messages.NewMessage(0, messages.ActionHello, &messages.Hello{Protocol: messages.ProtocolCurrent}))
+	return client
+}
+
+func pollQueued(client *UserClient) []*messages.GlideMessage {
+	var ms []*messages.GlideMessage
+	for {
+		e, ok := client.poll(messages.PriorityAuto)
+		if !ok {
+			return ms
+		}
+		ms = append(ms, e.m)
+	}
+}
+
+func TestClient_HandleBatch(t *testing.T) {
+	var handled []*messages.GlideMessage
+	client := newBatchClient(func(cliInfo *Info, message *messages.GlideMessage) {
+		handled = append(handled, message)
+	})
+	_ = pollQueued(client)
+
+	batch := messages.NewBatch([]*messages.GlideMessage{
+		messages.NewMessage(1, messages.ActionChatMessage, &messages.ChatMessage{Content: "1"}),
+		messages.NewMessage(2, messages.ActionAuthenticate, nil),
+		messages.NewMessage(3, messages.ActionChatMessage, &messages.ChatMessage{Content: "3"}),
+	})
+	client.dispatch(messages.NewMessage(9, messages.ActionBatch, batch))
+
+	queued := pollQueued(client)
+	assert.NotEmpty(t, queued)
+	assert.Equal(t, messages.Action(messages.ActionBatchResult), queued[0].GetAction())
+	assert.Equal(t, int64(9), queued[0].GetSeq())
+	result := messages.BatchResult{}
+	assert.NoError(t, queued[0].Data.Deserialize(&result))
+	assert.Len(t, result.Results, 3)
+	assert.True(t, result.Results[0].Ok)
+	assert.False(t, result.Results[1].Ok)
+	assert.Equal(t, errBatchItemNotAllowed, result.Results[1].Error.Message)
+	assert.True(t, result.Results[2].Ok)
+
+	assert.Len(t, handled, 2)
+	assert.Equal(t, int64(1), handled[0].GetSeq())
+	assert.Equal(t, int64(3), handled[1].GetSeq())
+}
+
+func TestClient_HandleBatchUnsupported(t *testing.T) {
+	fn, _ := mockReadFn()
+	client := NewClient(&mockConnection{mockRead: fn}, mockGateway{}, func(cliInfo *Info, message *messages.GlideMessage) {
+		t.Fatal("batch item handled")
+	}).(*UserClient)
+	client.SetID(NewID2("1"))
+
+	batch := messages.NewBatch([]*messages.GlideMessage{messages.NewMessage(1, messages.ActionChatMessage, nil)})
+	client.dispatch(messages.NewMessage(9, messages.ActionBatch, batch))
+
+	queued := pollQueued(client)
+	assert.Len(t, queued, 1)
+	assert.Equal(t, messages.Action(messages.ActionApiFailed), queued[0].GetAction())
+}
+
+func TestClient_EnqueueBatch(t *testing.T) {
+	client := newBatchClient(func(cliInfo *Info, message *messages.GlideMessage) {})
+	_ = pollQueued(client)
+
+	var ms []*messages.GlideMessage
+	for i := 0; i < messages.MaxBatchItems+1; i++ {
+		ms = append(ms, messages.NewMessage(int64(i), messages.ActionChatMessage, &messages.ChatMessage{Content: "m"}))
+	}
+	assert.NoError(t, client.EnqueueBatch(ms))
+
+	queued := pollQueued(client)
+	assert.Len(t, queued, 2)
+	var items []*messages.GlideMessage
+	for _, m := range queued {
+		assert.Equal(t, messages.Action(messages.ActionBatch), m.GetAction())
+		batch := messages.Batch{}
+		assert.NoError(t, m.Data.Deserialize(&batch))
+		assert.NotEmpty(t, batch.Compressed)
+		ms, err := batch.Messages()
+		assert.NoError(t, err)
+		items = append(items, ms...)
+	}
+	assert.Len(t, items, messages.MaxBatchItems+1)
+	assert.Equal(t, int64(messages.MaxBatchItems), items[messages.MaxBatchItems].GetSeq())
+}
@@ -0,0 +1,56 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"sort"
+)
+
+// setCapabilities sets the capabilities declared by the client, nil if the client declares nothing, which is treated
+// as supporting all capabilities.
+func (c *UserClient) setCapabilities(capabilities []string) {
+	if capabilities == nil {
+		return
+	}
+	declared := make(map[string]bool, len(capabilities))
+	for _, capability := range capabilities {
+		declared[capability] = true
+	}
+	c.capabilities.Store(declared)
+	sorted := append([]string{}, capabilities...)
+	sort.Strings(sorted)
+	c.info.Capabilities = sorted
+}
+
+// hasCapability returns true if the capability is not disabled, and the client declares it or declares nothing.
+func (c *UserClient) hasCapability(capability string) bool {
+	if messages.IsDisabled(capability) {
+		return false
+	}
+	declared, ok := c.capabilities.Load().(map[string]bool)
+	return !ok || declared[capability]
+}
+
+// adapt returns the message adapted to capabilities of the client, false if the client can't handle the message.
+func (c *UserClient) adapt(m *messages.GlideMessage) (*messages.GlideMessage, bool) {
+	if capability := messages.CapabilityOf(m.GetAction()); capability != "" && !c.hasCapability(capability) {
+		return m, false
+	}
+	if !c.hasCapability(messages.CapabilityBinaryCodec) {
+		m = messages.StripBinary(m)
+	}
+	return m, true
+}
+
+// HasCapability returns true if the client declares the capability, the client declares nothing is treated as
+// supporting all capabilities.
+func (i *Info) HasCapability(capability string) bool {
+	if i.Capabilities == nil {
+		return true
+	}
+	for _, c := range i.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestClient_Adapt(t *testing.T) {
+	fn, _ := mockReadFn()
+	client := NewClient(&mockConnection{mockRead: fn}, mockGateway{}, nil).(*UserClient)
+	react := messages.NewMessage(0, messages.ActionMessageReact, &messages.Reaction{Mid: 1})
+	bin := messages.NewMessage(0, messages.ActionClientCustom, &messages.Binary{ContentType: "a/b", Data: []byte{1}})
+
+	// the client declares nothing supports all capabilities.
+	_, ok := client.adapt(react)
+	assert.True(t, ok)
+	m, _ := client.adapt(bin)
+	assert.Same(t, bin, m)
+
+	client.SetCredentials(&ClientAuthCredentials{Capabilities: []string{messages.CapabilityResume}})
+	assert.Equal(t, []string{messages.CapabilityResume}, client.GetInfo().Capabilities)
+	_, ok = client.adapt(react)
+	assert.False(t, ok)
+	m, ok = client.adapt(bin)
+	assert.True(t, ok)
+	b, err := messages.Encode(messages.JsonCodec, m)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"data":"AQ=="`)
+}
+
+func TestClient_DisabledFeatures(t *testing.T) {
+	messages.SetDisabledFeatures([]string{messages.CapabilityReactions})
+	defer messages.SetDisabledFeatures(nil)
+
+	fn, _ := mockReadFn()
+	client := NewClient(&mockConnection{mockRead: fn}, mockGateway{}, func(cliInfo *Info, message *messages.GlideMessage) {
+		t.Fatal("disabled action handled")
+	}).(*UserClient)
+	client.SetID(NewID2("1"))
+	_, ok := client.adapt(messages.NewMessage(0, messages.ActionGroupMessageReact, nil))
+	assert.False(t, ok)
+
+	client.dispatch(messages.NewMessage(1, messages.ActionMessageReact, &messages.Reaction{Mid: 1}))
+	e, ok := client.poll(messages.PriorityAuto)
+	assert.True(t, ok)
+	assert.Equal(t, messages.Action(messages.ActionNotifyUnknownAction), e.m.GetAction())
+}
+
+func TestAuthenticator_Capabilities(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(func(cliInfo *Info, message *messages.GlideMessage) {})
+	auth := NewAuthenticator(gateway, "secret")
+
+	tempID, _ := GenTempID("g1")
+	cli := &credClient{mockClient: mockClient{info: Info{ID: tempID}, running: true}}
+	gateway.AddClient(cli)
+	credential, err := auth.keys.Encrypt(&ClientAuthCredentials{
+		UserID:       "1",
+		Timestamp:    time.Now().UnixMilli(),
+		Capabilities: []string{messages.CapabilityReactions},
+	})
+	assert.NoError(t, err)
+	// the capabilities declared by the client override the capabilities in the credential.
+	credential.Capabilities = []string{messages.CapabilityBinaryCodec}
+	auth.ClientAuthMessageInterceptor(cli, messages.NewMessage(1, messages.ActionAuthenticate, credential))
+
+	assert.Equal(t, []string{messages.CapabilityBinaryCodec}, cli.cred.Capabilities)
+	assert.False(t, supportsResume(cli.cred))
+	assert.True(t, supportsResume(&ClientAuthCredentials{}))
+}
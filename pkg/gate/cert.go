@@ -0,0 +1,34 @@
+package gate
+
+import (
+	"crypto/x509"
+	"errors"
+	"strings"
+)
+
+// CertResolver resolves the uid of the client by the verified client certificate of mutual TLS.
+type CertResolver func(cert *x509.Certificate) (uid string, err error)
+
+// CommonNameResolver uses the common name of the certificate subject as uid.
+func CommonNameResolver(cert *x509.Certificate) (string, error) {
+	if cert.Subject.CommonName == "" {
+		return "", errors.New("certificate common name is empty")
+	}
+	return cert.Subject.CommonName, nil
+}
+
+// MappedCommonNameResolver resolves uid by the mapping of certificate common name to uid, the common name is case
+// insensitive, the certificates of common name not in mapping are rejected.
+func MappedCommonNameResolver(mapping map[string]string) CertResolver {
+	m := make(map[string]string, len(mapping))
+	for cn, uid := range mapping {
+		m[strings.ToLower(cn)] = uid
+	}
+	return func(cert *x509.Certificate) (string, error) {
+		uid, ok := m[strings.ToLower(cert.Subject.CommonName)]
+		if !ok {
+			return "", errors.New("unknown certificate common name: " + cert.Subject.CommonName)
+		}
+		return uid, nil
+	}
+}
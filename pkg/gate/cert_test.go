@@ -0,0 +1,22 @@
+package gate
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMappedCommonNameResolver(t *testing.T) {
+	resolver := MappedCommonNameResolver(map[string]string{"Device-1": "1001"})
+
+	uid, err := resolver(&x509.Certificate{Subject: pkix.Name{CommonName: "device-1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "1001", uid)
+
+	_, err = resolver(&x509.Certificate{Subject: pkix.Name{CommonName: "device-2"}})
+	assert.Error(t, err)
+
+	_, err = CommonNameResolver(&x509.Certificate{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,284 @@
+package gate
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
+	"math/bits"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriorityChallenge the priority of ChallengeGuard middleware, it runs before authenticate.
+const PriorityChallenge = -3000
+
+const (
+	errChallengeRequired = "challenge required"
+	errChallengeFailed   = "challenge failed"
+)
+
+// Challenger issues anti-abuse challenges and verifies answers of them.
+type Challenger interface {
+	Issue() *messages.Challenge
+
+	// Verify verifies the answer of the challenge from the client with ip.
+	Verify(c *messages.Challenge, answer *messages.ChallengeAnswer, ip string) error
+}
+
+// PoWChallenger issues proof-of-work challenges, each additional difficulty bit doubles the work of client.
+type PoWChallenger struct {
+	difficulty int
+}
+
+func NewPoWChallenger(difficulty int) *PoWChallenger {
+	if difficulty <= 0 {
+		difficulty = 20
+	}
+	return &PoWChallenger{difficulty: difficulty}
+}
+
+func (p *PoWChallenger) Issue() *messages.Challenge {
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	return &messages.Challenge{
+		Type:       messages.ChallengeTypePoW,
+		Nonce:      hex.EncodeToString(nonce),
+		Difficulty: p.difficulty,
+	}
+}
+
+func (p *PoWChallenger) Verify(c *messages.Challenge, answer *messages.ChallengeAnswer, _ string) error {
+	if answer.Solution == "" || leadingZeroBits(c.Nonce, answer.Solution) < c.Difficulty {
+		return errors.New(errChallengeFailed)
+	}
+	return nil
+}
+
+// SolvePoW finds the solution of the proof-of-work challenge, as the reference of clients.
+func SolvePoW(nonce string, difficulty int) string {
+	for i := 0; ; i++ {
+		solution := strconv.Itoa(i)
+		if leadingZeroBits(nonce, solution) >= difficulty {
+			return solution
+		}
+	}
+}
+
+func leadingZeroBits(nonce string, solution string) int {
+	sum := sha256.Sum256([]byte(nonce + solution))
+	n := 0
+	for _, b := range sum {
+		n += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return n
+}
+
+// CaptchaVerifier validates the CAPTCHA token solved by the client with ip.
+type CaptchaVerifier func(token string, ip string) error
+
+// CaptchaChallenger asks clients to solve a CAPTCHA, the token is validated by the CaptchaVerifier.
+type CaptchaChallenger struct {
+	siteKey string
+	verify  CaptchaVerifier
+}
+
+func NewCaptchaChallenger(siteKey string, verify CaptchaVerifier) *CaptchaChallenger {
+	return &CaptchaChallenger{siteKey: siteKey, verify: verify}
+}
+
+func (c *CaptchaChallenger) Issue() *messages.Challenge {
+	return &messages.Challenge{
+		Type:    messages.ChallengeTypeCaptcha,
+		SiteKey: c.siteKey,
+	}
+}
+
+func (c *CaptchaChallenger) Verify(_ *messages.Challenge, answer *messages.ChallengeAnswer, ip string) error {
+	if answer.Token == "" {
+		return errors.New(errChallengeFailed)
+	}
+	return c.verify(answer.Token, ip)
+}
+
+// SiteVerify returns the CaptchaVerifier validates token by the siteverify api of reCAPTCHA, hCaptcha or Turnstile.
+func SiteVerify(verifyURL string, secret string) CaptchaVerifier {
+	client := &http.Client{Timeout: time.Second * 5}
+	return func(token string, ip string) error {
+		form := url.Values{"secret": {secret}, "response": {token}}
+		if ip != "" {
+			form.Set("remoteip", ip)
+		}
+		resp, err := client.PostForm(verifyURL, form)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		result := struct {
+			Success    bool     `json:"success"`
+			ErrorCodes []string `json:"error-codes"`
+		}{}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			return err
+		}
+		if !result.Success {
+			return errors.New(errChallengeFailed + ": " + strings.Join(result.ErrorCodes, ","))
+		}
+		return nil
+	}
+}
+
+type challengeState struct {
+	challenge *messages.Challenge
+	issuedAt  time.Time
+	solved    bool
+	// stepUp the client is stepped up, messages of it are rejected until the challenge solved, see StepUp.
+	stepUp bool
+}
+
+// ChallengeGuard requires clients to solve a challenge before each authenticate, the challenge is sent to client once
+// connected, and a new one is sent when the answer is wrong, the challenge expired or consumed by an authenticate.
+type ChallengeGuard struct {
+	challenger Challenger
+	ttl        time.Duration
+
+	mu        sync.Mutex
+	pending   map[Client]*challengeState
+	lastSweep time.Time
+}
+
+// NewChallengeGuard creates the ChallengeGuard, the challenge expires after ttl, default 1 minute.
+func NewChallengeGuard(challenger Challenger, ttl time.Duration) *ChallengeGuard {
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	return &ChallengeGuard{
+		challenger: challenger,
+		ttl:        ttl,
+		pending:    map[Client]*challengeState{},
+	}
+}
+
+// Issue sends a new challenge to the client.
+func (g *ChallengeGuard) Issue(c Client) {
+	g.issue(c, false)
+}
+
+// StepUp requires the client to solve a new challenge, such as the connection is suspicious, see AnomalyGuard.
+// Messages of the client except heartbeat and the answer are rejected until the challenge solved, the client is not
+// required to authenticate again.
+func (g *ChallengeGuard) StepUp(c Client) {
+	g.issue(c, true)
+}
+
+func (g *ChallengeGuard) issue(c Client, stepUp bool) {
+	ch := g.challenger.Issue()
+	now := time.Now()
+
+	g.mu.Lock()
+	// clients disconnected without authenticate are swept, the stepped up clients are kept until solved.
+	if now.Sub(g.lastSweep) > g.ttl {
+		g.lastSweep = now
+		for cli, s := range g.pending {
+			if !cli.IsRunning() || (now.Sub(s.issuedAt) > g.ttl && !s.stepUp) {
+				delete(g.pending, cli)
+			}
+		}
+	}
+	if s, ok := g.pending[c]; ok && s.stepUp {
+		stepUp = true
+	}
+	g.pending[c] = &challengeState{challenge: ch, issuedAt: now, stepUp: stepUp}
+	g.mu.Unlock()
+
+	_ = c.EnqueueMessage(messages.NewMessage(0, messages.ActionChallenge, ch))
+}
+
+// Middleware handles answers of challenge and rejects authenticate of clients without solved challenge.
+func (g *ChallengeGuard) Middleware(c Client, m *messages.GlideMessage) (bool, error) {
+	switch m.GetAction() {
+	case messages.ActionChallengeAnswer:
+		g.answer(c, m)
+		return true, nil
+	case messages.ActionAuthenticate:
+		g.mu.Lock()
+		s, ok := g.pending[c]
+		solved := ok && s.solved && time.Since(s.issuedAt) <= g.ttl
+		if solved {
+			delete(g.pending, c)
+		}
+		g.mu.Unlock()
+		if !solved {
+			g.reject(c, m.GetSeq(), errChallengeRequired)
+			return true, nil
+		}
+	case messages.ActionHeartbeat:
+	default:
+		g.mu.Lock()
+		s, ok := g.pending[c]
+		locked := ok && s.stepUp
+		g.mu.Unlock()
+		if locked {
+			_ = c.EnqueueMessage(messages.NewErrorMessage(m.GetSeq(), messages.ActionNotifyError, errChallengeRequired))
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (g *ChallengeGuard) answer(c Client, m *messages.GlideMessage) {
+	g.mu.Lock()
+	s, ok := g.pending[c]
+	g.mu.Unlock()
+	if !ok || time.Since(s.issuedAt) > g.ttl {
+		g.reject(c, m.GetSeq(), errChallengeRequired)
+		return
+	}
+
+	answer := messages.ChallengeAnswer{}
+	err := m.Data.Deserialize(&answer)
+	if err == nil {
+		err = g.challenger.Verify(s.challenge, &answer, clientIP(c))
+	}
+	if err != nil {
+		metrics.Challenges.WithLabelValues(s.challenge.Type, "failed").Inc()
+		log.D("[challenge] client %s answer failed: %v", c.GetInfo().ID, err)
+		g.reject(c, m.GetSeq(), errChallengeFailed)
+		return
+	}
+
+	metrics.Challenges.WithLabelValues(s.challenge.Type, "solved").Inc()
+	g.mu.Lock()
+	s.solved = true
+	s.stepUp = false
+	g.mu.Unlock()
+	_ = c.EnqueueMessage(messages.NewMessage(m.GetSeq(), messages.ActionNotifySuccess, nil))
+}
+
+// reject notifies the client with error and sends a new challenge.
+func (g *ChallengeGuard) reject(c Client, seq int64, errMsg string) {
+	_ = c.EnqueueMessage(messages.NewErrorMessage(seq, messages.ActionNotifyError, errMsg))
+	g.Issue(c)
+}
+
+func clientIP(c Client) string {
+	addr := c.GetInfo().CliAddr
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
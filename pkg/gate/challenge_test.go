@@ -0,0 +1,69 @@
+package gate
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type recordClient struct {
+	mockClient
+	received []*messages.GlideMessage
+}
+
+func (r *recordClient) EnqueueMessage(m *messages.GlideMessage) error {
+	r.received = append(r.received, m)
+	return nil
+}
+
+func (r *recordClient) last() *messages.GlideMessage {
+	return r.received[len(r.received)-1]
+}
+
+func TestPoWChallenger(t *testing.T) {
+	p := NewPoWChallenger(8)
+	c := p.Issue()
+	assert.Equal(t, 8, c.Difficulty)
+
+	solution := SolvePoW(c.Nonce, c.Difficulty)
+	assert.NoError(t, p.Verify(c, &messages.ChallengeAnswer{Solution: solution}, ""))
+
+	wrong := "x"
+	for leadingZeroBits(c.Nonce, wrong) >= 8 {
+		wrong += "x"
+	}
+	assert.Error(t, p.Verify(c, &messages.ChallengeAnswer{Solution: wrong}, ""))
+}
+
+func TestChallengeGuard(t *testing.T) {
+	g := NewChallengeGuard(NewCaptchaChallenger("site", func(token string, ip string) error {
+		if token != "ok" || ip != "1.2.3.4" {
+			return errors.New("invalid token")
+		}
+		return nil
+	}), time.Minute)
+	c := &recordClient{mockClient: mockClient{info: Info{CliAddr: "1.2.3.4:5678"}, running: true}}
+	g.Issue(c)
+	assert.Equal(t, messages.ActionChallenge, c.last().GetAction())
+
+	auth := messages.NewMessage(1, messages.ActionAuthenticate, nil)
+	handled, _ := g.Middleware(c, auth)
+	assert.True(t, handled)
+	assert.Equal(t, messages.ActionChallenge, c.last().GetAction())
+
+	handled, _ = g.Middleware(c, messages.NewMessage(2, messages.ActionChallengeAnswer, &messages.ChallengeAnswer{Token: "bad"}))
+	assert.True(t, handled)
+	assert.Equal(t, messages.ActionChallenge, c.last().GetAction())
+
+	_, _ = g.Middleware(c, messages.NewMessage(3, messages.ActionChallengeAnswer, &messages.ChallengeAnswer{Token: "ok"}))
+	assert.Equal(t, messages.ActionNotifySuccess, c.last().GetAction())
+
+	handled, _ = g.Middleware(c, auth)
+	assert.False(t, handled)
+
+	// the solved challenge is consumed by authenticate
+	handled, _ = g.Middleware(c, auth)
+	assert.True(t, handled)
+}
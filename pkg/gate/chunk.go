@@ -0,0 +1,105 @@
+package gate
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/messages"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxAssembledSize = 16 << 20
+	// maxPendingChunked the max count of chunked messages being assembled at a time of a client.
+	maxPendingChunked = 4
+	// maxChunks the max count of chunks of a message.
+	maxChunks = 1024
+	// chunkTimeout the max duration to receive all chunks of a message.
+	chunkTimeout = time.Minute
+)
+
+var (
+	errInvalidChunk    = errors.New("invalid chunk")
+	errTooManyChunked  = errors.New("too many chunked messages in progress")
+	errChunkedTooLarge = errors.New("chunked message too large")
+	// errChunkUnsupported the chunk is sent by the client which negotiated the protocol without chunk feature.
+	errChunkUnsupported = errors.New("chunk is not supported by the protocol version")
+)
+
+// chunkedMessage the chunked message being assembled.
+type chunkedMessage struct {
+	parts    [][]byte
+	received int
+	size     int64
+	startAt  time.Time
+}
+
+// chunkAssembler reassembles messages sent in chunks of a client, see messages.Chunk.
+type chunkAssembler struct {
+	mu      sync.Mutex
+	maxSize int64
+	pending map[string]*chunkedMessage
+}
+
+func newChunkAssembler(maxSize int64) *chunkAssembler {
+	if maxSize <= 0 {
+		maxSize = defaultMaxAssembledSize
+	}
+	return &chunkAssembler{maxSize: maxSize, pending: map[string]*chunkedMessage{}}
+}
+
+// add adds the chunk, returns the encoded message when all chunks of it are added. The message is dropped if any
+// chunk is invalid or the assembled size exceeds the limit.
+func (a *chunkAssembler) add(c *messages.Chunk) ([]byte, error) {
+	if c.ID == "" || c.Total <= 0 || c.Total > maxChunks || c.Index < 0 || c.Index >= c.Total {
+		return nil, errInvalidChunk
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for id, m := range a.pending {
+		if now.Sub(m.startAt) > chunkTimeout {
+			delete(a.pending, id)
+		}
+	}
+	m, ok := a.pending[c.ID]
+	if !ok {
+		if len(a.pending) >= maxPendingChunked {
+			return nil, errTooManyChunked
+		}
+		m = &chunkedMessage{parts: make([][]byte, c.Total), startAt: now}
+		a.pending[c.ID] = m
+	}
+	if len(m.parts) != c.Total || m.parts[c.Index] != nil {
+		delete(a.pending, c.ID)
+		return nil, errInvalidChunk
+	}
+	m.size += int64(len(c.Data))
+	if m.size > a.maxSize {
+		delete(a.pending, c.ID)
+		return nil, errChunkedTooLarge
+	}
+	// an empty chunk is kept as non-nil to be counted as received.
+	m.parts[c.Index] = append([]byte{}, c.Data...)
+	m.received++
+	if m.received < c.Total {
+		return nil, nil
+	}
+	delete(a.pending, c.ID)
+	data := make([]byte, 0, m.size)
+	for _, p := range m.parts {
+		data = append(data, p...)
+	}
+	return data, nil
+}
+
+// tooLargeError the error notified to client when a message is larger than the max message size.
+func tooLargeError(maxSize int64) *messages.Error {
+	if maxSize <= 0 {
+		return messages.NewError(errMessageTooLarge)
+	}
+	text := "message too large, max size is " + strconv.FormatInt(maxSize, 10) + " bytes, send it in chunks"
+	return messages.ErrCodeMessageTooLarge.New(text).WithDetail("max_size", strconv.FormatInt(maxSize, 10))
+}
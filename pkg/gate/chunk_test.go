@@ -0,0 +1,59 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChunkAssembler_Add(t *testing.T) {
+	a := newChunkAssembler(8)
+
+	data, err := a.add(&messages.Chunk{ID: "1", Index: 1, Total: 2, Data: []byte("def")})
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+	data, err = a.add(&messages.Chunk{ID: "1", Index: 0, Total: 2, Data: []byte("abc")})
+	assert.NoError(t, err)
+	assert.Equal(t, "abcdef", string(data))
+
+	_, err = a.add(&messages.Chunk{ID: "2", Index: 2, Total: 2})
+	assert.Equal(t, errInvalidChunk, err)
+
+	_, _ = a.add(&messages.Chunk{ID: "3", Index: 0, Total: 2, Data: []byte("12345")})
+	_, err = a.add(&messages.Chunk{ID: "3", Index: 1, Total: 2, Data: []byte("6789")})
+	assert.Equal(t, errChunkedTooLarge, err)
+	assert.Empty(t, a.pending)
+
+	for i := 0; i < maxPendingChunked; i++ {
+		_, err = a.add(&messages.Chunk{ID: string(rune('a' + i)), Index: 0, Total: 2})
+		assert.NoError(t, err)
+	}
+	_, err = a.add(&messages.Chunk{ID: "z", Index: 0, Total: 2})
+	assert.Equal(t, errTooManyChunked, err)
+}
+
+func TestClient_HandleChunk(t *testing.T) {
+	fn, _ := mockReadFn()
+	received := make(chan *messages.GlideMessage, 1)
+	client := NewClient(&mockConnection{mockRead: fn}, mockGateway{}, func(cliInfo *Info, message *messages.GlideMessage) {
+		received <- message
+	}).(*UserClient)
+	client.SetID(NewID2("1"))
+	client.dispatch(messages.NewMessage(0, messages.ActionChunk, &messages.Chunk{ID: "m", Index: 0, Total: 2}))
+	assert.Equal(t, messages.ProtocolV1, client.GetInfo().Protocol)
+	client.dispatch(messages.NewMessage(0, messages.ActionHello, &messages.Hello{Protocol: messages.ProtocolCurrent}))
+	assert.Equal(t, messages.ProtocolCurrent, client.GetInfo().Protocol)
+
+	encoded, err := messages.DefaultCodec.Encode(messages.NewMessage(1, messages.ActionChatMessage, &messages.ChatMessage{Content: "large"}))
+	assert.NoError(t, err)
+	half := len(encoded) / 2
+	for i, part := range [][]byte{encoded[:half], encoded[half:]} {
+		client.dispatch(messages.NewMessage(0, messages.ActionChunk, &messages.Chunk{ID: "m", Index: i, Total: 2, Data: part}))
+	}
+
+	m := <-received
+	assert.Equal(t, messages.Action(messages.ActionChatMessage), m.GetAction())
+	chat := messages.ChatMessage{}
+	assert.NoError(t, m.Data.Deserialize(&chat))
+	assert.Equal(t, "large", chat.Content)
+}
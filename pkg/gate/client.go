@@ -1,6 +1,7 @@
 package gate
 
 import (
+	"github.com/glide-im/glide/pkg/conn"
 	"github.com/glide-im/glide/pkg/messages"
 	"strings"
 )
@@ -82,6 +83,11 @@ func (i *ID) IsTemp() bool {
 	return strings.HasPrefix(i.getPart(1), tempIdPrefix)
 }
 
+// IsTempUID returns true if the uid is the temp uid of a client not authenticated.
+func IsTempUID(uid string) bool {
+	return strings.HasPrefix(uid, tempIdPrefix)
+}
+
 func (i *ID) Equals(other ID) bool {
 	return i.UID()+i.Device() == other.UID()+other.Device()
 }
@@ -106,6 +112,9 @@ type Info struct {
 	// Version is the version of the client.
 	Version string
 
+	// Protocol is the protocol version negotiated with the client, see messages.NegotiateProtocol.
+	Protocol int64
+
 	// AliveAt is the time the client was last seen.
 	AliveAt int64
 
@@ -117,6 +126,23 @@ type Info struct {
 
 	// CliAddr is the address of the client.
 	CliAddr string
+
+	// QueueDepth is the count of messages waiting in the send queue.
+	QueueDepth int64
+
+	// Attributes the attributes of client set at authentication or by business service, see Selector.
+	Attributes map[string]string
+
+	// Locale is the language of the client set by the credentials or the hello message.
+	Locale string
+
+	// Capabilities the sorted capabilities declared by the client on authenticate, nil if the client declares
+	// nothing, see HasCapability.
+	Capabilities []string
+
+	// Fingerprint the fingerprint of the connection observed while connecting, nil if not supported by the
+	// connection server, see AnomalyDetector.
+	Fingerprint *conn.Fingerprint
 }
 
 // Client is a client connection abstraction.
@@ -158,8 +184,15 @@ type EncryptedCredential struct {
 	// Version is the version of the credential.
 	Version int `json:"version"`
 
+	// Kid is the id of the key encrypted the credential, see KeyRing.
+	Kid string `json:"kid,omitempty"`
+
 	// Credential is the encrypted credential string.
 	Credential string `json:"credential"`
+
+	// Capabilities the capabilities declared by the client, such as messages.CapabilityReactions, it's sent in plain
+	// as they are decided by the client instead of the business service.
+	Capabilities []string `json:"capabilities,omitempty"`
 }
 
 // ConnectionConfig _
@@ -200,6 +233,19 @@ type ClientAuthCredentials struct {
 
 	// Timestamp of credentials creation.
 	Timestamp int64 `json:"timestamp"`
+
+	// Attributes the attributes of client used to select clients to deliver, such as region and app version.
+	Attributes map[string]string `json:"attributes,omitempty"`
+
+	// GuestID the temp uid of the guest signed in as the user, set by business service to keep the subscriptions and
+	// conversations of the guest, such as the customer support chat before login, see messages.GuestUpgrade.
+	GuestID string `json:"guest_id,omitempty"`
+
+	// Locale the language of the client, such as zh-CN, errors and notifications are sent in it, see i18n.
+	Locale string `json:"locale,omitempty"`
+
+	// Capabilities the capabilities of the client, overridden by the capabilities declared in EncryptedCredential.
+	Capabilities []string `json:"capabilities,omitempty"`
 }
 
 func (a *ClientAuthCredentials) validate() error {
@@ -3,9 +3,13 @@ package gate
 import (
 	"errors"
 	"github.com/glide-im/glide/pkg/conn"
-	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metering"
+	"github.com/glide-im/glide/pkg/metrics"
+	"github.com/glide-im/glide/pkg/tap"
 	"github.com/glide-im/glide/pkg/timingwheel"
+	"github.com/glide-im/glide/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,8 +23,61 @@ const (
 	defaultHeartbeatDuration       = time.Second * 20
 	defaultHeartbeatLostLimit      = 3
 	defaultCloseImmediately        = false
+	defaultSendQueueSize           = 100
+	defaultEnqueueTimeout          = time.Second
+	defaultMaxMessageSize          = 1 << 20
 )
 
+const errQueueFull = "client send queue is full"
+
+// OverflowPolicy decides what to do when the client send queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDropNew drops the message being enqueued.
+	OverflowDropNew OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest message in the queue to make room for the new one.
+	OverflowDropOldest
+	// OverflowBlock blocks the caller until the queue has room or ClientConfig.EnqueueTimeout elapsed.
+	OverflowBlock
+	// OverflowDisconnect disconnects the client, it's considered too slow to keep.
+	OverflowDisconnect
+)
+
+// ParseOverflowPolicy parses the policy name: drop_new, drop_oldest, block or disconnect, empty is drop_new.
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	switch name {
+	case "", "drop_new":
+		return OverflowDropNew, nil
+	case "drop_oldest":
+		return OverflowDropOldest, nil
+	case "block":
+		return OverflowBlock, nil
+	case "disconnect":
+		return OverflowDisconnect, nil
+	default:
+		return OverflowDropNew, errors.New("unknown overflow policy: " + name)
+	}
+}
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropOldest:
+		return "drop_oldest"
+	case OverflowBlock:
+		return "block"
+	case OverflowDisconnect:
+		return "disconnect"
+	default:
+		return "drop_new"
+	}
+}
+
+// IsQueueFull returns true if the error is caused by the full client send queue.
+func IsQueueFull(err error) bool {
+	return err != nil && err.Error() == errQueueFull
+}
+
 // client state
 const (
 	_ int32 = iota
@@ -46,6 +103,35 @@ type ClientConfig struct {
 	// otherwise client will close runRead, and mark as stateClosing, the client cannot receive and enqueue message,
 	// after all message in queue is sent, client will close runWrite and connection.
 	CloseImmediately bool
+
+	// SendQueueSize is the capacity of the client send queue, default 100.
+	SendQueueSize int
+
+	// OverflowPolicy decides what to do when the send queue is full, default OverflowDropNew.
+	OverflowPolicy OverflowPolicy
+
+	// EnqueueTimeout is the max duration to block when OverflowPolicy is OverflowBlock, default 1s.
+	EnqueueTimeout time.Duration
+
+	// Outbound measures the bytes written to the connection, optional.
+	Outbound *RateMeter
+
+	// Shaper caps the egress bytes per second of the client by device type, optional.
+	Shaper *EgressShaper
+
+	// Events publishes EventQueueOverflow of the client, optional.
+	Events *EventBus
+
+	// MaxMessageSize is the max size of a message read from the connection, notified to the client when a message is
+	// too large, the limit is enforced by the connection.
+	MaxMessageSize int64
+
+	// MaxAssembledSize is the max size of a message sent in chunks, default 16MB, see messages.Chunk.
+	MaxAssembledSize int64
+
+	// RejectUnknownAction true to reject the message of action not registered, see messages.RegisterAction, the
+	// message of internal action is always rejected.
+	RejectUnknownAction bool
 }
 
 type MessageInterceptor = func(dc DefaultClient, msg *messages.GlideMessage) bool
@@ -61,6 +147,14 @@ type DefaultClient interface {
 }
 
 var _ DefaultClient = (*UserClient)(nil)
+var _ WriteStater = (*UserClient)(nil)
+
+// envelope is the message in the client send queue.
+type envelope struct {
+	m *messages.GlideMessage
+	// at the unix nano the message enqueued at.
+	at int64
+}
 
 // UserClient represent a user conn client.
 type UserClient struct {
@@ -71,10 +165,26 @@ type UserClient struct {
 	// state is the client state
 	state int32
 
-	// queuedMessage message count in the messages channel
+	// queuedMessage message count in the send queue lanes
 	queuedMessage int64
-	// messages is the buffered channel for message to push to client.
-	messages chan *messages.GlideMessage
+	// messages is the buffered channel for message to push to client, it's the lane of messages.PriorityNormal.
+	messages chan envelope
+	// control and bulk are the lanes of messages.PriorityHigh and messages.PriorityLow, messages in lanes of higher
+	// priority are written first.
+	control chan envelope
+	bulk    chan envelope
+
+	// writingSince the unix nano the in-progress write started at, 0 if no write in progress.
+	writingSince int64
+	// lastWriteLatency the nanoseconds of the last write to connection.
+	lastWriteLatency int64
+	// lastResidence the nanoseconds the last written message waited in queue.
+	lastResidence int64
+
+	// polled true if the connection is read by netpoll server instead of runRead.
+	polled bool
+	// lastReadAt the unix nano of the last message read from the polled connection.
+	lastReadAt int64
 
 	// closeReadCh is the channel for runRead goroutine to close
 	closeReadCh chan struct{}
@@ -105,6 +215,23 @@ type UserClient struct {
 
 	// config is the client config
 	config *ClientConfig
+
+	// codec the message codec of the connection subprotocol.
+	codec messages.Codec
+
+	// chunks reassembles messages sent in chunks.
+	chunks *chunkAssembler
+
+	// protocol the protocol version negotiated by hello, messages are translated between it and the current version.
+	protocol int64
+
+	// locale the language of the client, texts generated by the server are localized to it, see localize.
+	locale atomic.Value
+	// capabilities the set of capabilities declared by the client, map[string]bool, see setCapabilities.
+	capabilities atomic.Value
+
+	// egress the token bucket of the egress rate, used by the write loop only, nil if uncapped.
+	egress *tokenBucket
 }
 
 func NewClientWithConfig(conn conn.Connection, mgr Gateway, handler MessageHandler, config *ClientConfig) DefaultClient {
@@ -116,21 +243,37 @@ func NewClientWithConfig(conn conn.Connection, mgr Gateway, handler MessageHandl
 			CloseImmediately:        false,
 		}
 	}
+	// copy the config, it's modified by credentials of each client.
+	cfg := *config
+	if cfg.SendQueueSize <= 0 {
+		cfg.SendQueueSize = defaultSendQueueSize
+	}
+	if cfg.EnqueueTimeout <= 0 {
+		cfg.EnqueueTimeout = defaultEnqueueTimeout
+	}
 
+	connInfo := conn.GetConnInfo()
 	ret := UserClient{
 		conn:         conn,
-		messages:     make(chan *messages.GlideMessage, 100),
+		messages:     make(chan envelope, cfg.SendQueueSize),
+		control:      make(chan envelope, cfg.SendQueueSize),
+		bulk:         make(chan envelope, cfg.SendQueueSize),
 		closeReadCh:  make(chan struct{}),
 		closeWriteCh: make(chan struct{}),
 		hbC:          tw.After(config.ClientHeartbeatDuration),
 		hbS:          tw.After(config.ServerHeartbeatDuration),
 		info: &Info{
 			ConnectionAt: time.Now().UnixMilli(),
-			CliAddr:      conn.GetConnInfo().Addr,
+			CliAddr:      connInfo.Addr,
+			Protocol:     messages.ProtocolV1,
+			Fingerprint:  connInfo.Fingerprint,
 		},
 		mgr:        mgr,
 		msgHandler: handler,
-		config:     config,
+		config:     &cfg,
+		codec:      codecOf(conn),
+		chunks:     newChunkAssembler(cfg.MaxAssembledSize),
+		protocol:   messages.ProtocolV1,
 	}
 	return &ret
 }
@@ -142,6 +285,8 @@ func NewClient(conn conn.Connection, mgr Gateway, handler MessageHandler) Defaul
 func (c *UserClient) SetCredentials(credentials *ClientAuthCredentials) {
 	c.credentials = credentials
 	c.info.ConnectionId = credentials.ConnectionID
+	c.setLocale(credentials.Locale)
+	c.setCapabilities(credentials.Capabilities)
 	if credentials.ConnectionConfig != nil {
 		c.config.HeartbeatLostLimit = credentials.ConnectionConfig.AllowMaxHeartbeatLost
 		c.config.CloseImmediately = credentials.ConnectionConfig.CloseImmediately
@@ -164,7 +309,9 @@ func (c *UserClient) AddMessageInterceptor(interceptor MessageInterceptor) {
 }
 
 func (c *UserClient) GetInfo() Info {
-	return *c.info
+	info := *c.info
+	info.QueueDepth = atomic.LoadInt64(&c.queuedMessage)
+	return info
 }
 
 // SetID set client id.
@@ -177,19 +324,120 @@ func (c *UserClient) IsRunning() bool {
 	return atomic.LoadInt32(&c.state) == stateRunning
 }
 
-// EnqueueMessage enqueue message to client message queue.
+// EnqueueMessage enqueue message to the lane of the message priority in client message queue, when the lane is full,
+// the message is handled by the ClientConfig.OverflowPolicy.
 func (c *UserClient) EnqueueMessage(msg *messages.GlideMessage) error {
 	if atomic.LoadInt32(&c.state) == stateClosed {
 		return errors.New("client has closed")
 	}
-	logger.I("EnqueueMessage ID=%s msg=%v", c.info.ID, msg)
+	log.I("EnqueueMessage ID=%s msg=%v", c.info.ID, msg)
+	e := envelope{m: msg, at: time.Now().UnixNano()}
+	lane := c.lane(msg)
 	select {
-	case c.messages <- msg:
-		atomic.AddInt64(&c.queuedMessage, 1)
+	case lane <- e:
+		c.onEnqueued()
+		return nil
 	default:
-		logger.E("msg chan is full, id=%v", c.info.ID)
 	}
-	return nil
+
+	metrics.QueueOverflows.WithLabelValues(c.config.OverflowPolicy.String()).Inc()
+	c.config.Events.Publish(&Event{Type: EventQueueOverflow, Client: c.GetInfo(), Message: msg, Reason: c.config.OverflowPolicy.String()})
+	switch c.config.OverflowPolicy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case <-lane:
+				c.onDequeued()
+			default:
+			}
+			select {
+			case lane <- e:
+				c.onEnqueued()
+				return nil
+			default:
+			}
+		}
+	case OverflowBlock:
+		timer := time.NewTimer(c.config.EnqueueTimeout)
+		defer timer.Stop()
+		select {
+		case lane <- e:
+			c.onEnqueued()
+			return nil
+		case <-c.closeWriteCh:
+			return errors.New("client has closed")
+		case <-timer.C:
+		}
+	case OverflowDisconnect:
+		log.W("msg chan is full, disconnect slow client, id=%v", c.info.ID)
+		go c.Exit()
+	}
+	metrics.EnqueueFailures.Inc()
+	log.E("msg chan is full, id=%v", c.info.ID)
+	return errors.New(errQueueFull)
+}
+
+// lane returns the lane of the send queue of the message priority.
+func (c *UserClient) lane(m *messages.GlideMessage) chan envelope {
+	switch m.GetPriority() {
+	case messages.PriorityHigh:
+		return c.control
+	case messages.PriorityLow:
+		return c.bulk
+	default:
+		return c.messages
+	}
+}
+
+// poll returns the message queued in the lane of the highest priority which is higher than p without blocking,
+// messages.PriorityAuto polls all lanes. It returns false if no message queued or the queue is closed.
+func (c *UserClient) poll(p messages.Priority) (envelope, bool) {
+	lanes := [...]chan envelope{c.control, c.messages, c.bulk}
+	for i, lane := range lanes {
+		if p != messages.PriorityAuto && messages.Priority(i+1) >= p {
+			break
+		}
+		select {
+		case e := <-lane:
+			return e, e.m != nil
+		default:
+		}
+	}
+	return envelope{}, false
+}
+
+// writePrior writes messages queued in lanes of higher priority than p.
+func (c *UserClient) writePrior(p messages.Priority) {
+	for {
+		e, ok := c.poll(p)
+		if !ok {
+			return
+		}
+		c.write2Conn(e)
+	}
+}
+
+// WriteStats returns the write statistics of the client.
+func (c *UserClient) WriteStats() WriteStats {
+	stats := WriteStats{
+		QueueDepth:       atomic.LoadInt64(&c.queuedMessage),
+		LastWriteLatency: time.Duration(atomic.LoadInt64(&c.lastWriteLatency)),
+		LastResidence:    time.Duration(atomic.LoadInt64(&c.lastResidence)),
+	}
+	if since := atomic.LoadInt64(&c.writingSince); since != 0 {
+		stats.Stall = time.Duration(time.Now().UnixNano() - since)
+	}
+	return stats
+}
+
+func (c *UserClient) onEnqueued() {
+	atomic.AddInt64(&c.queuedMessage, 1)
+	metrics.QueuedMessages.Inc()
+}
+
+func (c *UserClient) onDequeued() {
+	atomic.AddInt64(&c.queuedMessage, -1)
+	metrics.QueuedMessages.Dec()
 }
 
 // runRead message from client.
@@ -197,7 +445,7 @@ func (c *UserClient) runRead() {
 	defer func() {
 		err := recover()
 		if err != nil {
-			logger.E("read message panic: %v", err)
+			log.E("read message panic: %v", err)
 			c.Exit()
 		}
 	}()
@@ -222,7 +470,7 @@ func (c *UserClient) runRead() {
 			}
 			c.hbC.Cancel()
 			c.hbC = tw.After(c.config.ClientHeartbeatDuration)
-			_ = c.EnqueueMessage(messages.NewMessage(0, messages.ActionHeartbeat, nil))
+			_ = c.EnqueueMessage(messages.NewPooledMessage(0, messages.ActionHeartbeat, nil))
 		case msg := <-readChan:
 			if msg == nil {
 				closeReason = "readCh closed"
@@ -231,7 +479,11 @@ func (c *UserClient) runRead() {
 			}
 			if msg.err != nil {
 				if messages.IsDecodeError(msg.err) {
-					_ = c.EnqueueMessage(messages.NewMessage(0, messages.ActionNotifyError, msg.err.Error()))
+					_ = c.EnqueueMessage(messages.NewMessage(0, messages.ActionNotifyError, messages.ErrCodeInvalidMessage.New(msg.err.Error())))
+					continue
+				}
+				if msg.err == conn.ErrMessageTooLarge {
+					_ = c.EnqueueMessage(messages.NewMessage(0, messages.ActionNotifyError, tooLargeError(c.config.MaxMessageSize)))
 					continue
 				}
 				closeReason = msg.err.Error()
@@ -247,18 +499,112 @@ func (c *UserClient) runRead() {
 			c.hbC.Cancel()
 			c.hbC = tw.After(c.config.ClientHeartbeatDuration)
 
-			if msg.m.GetAction() == messages.ActionHello {
-				c.handleHello(msg.m)
-			} else {
-				c.msgHandler(c.info, msg.m)
-			}
+			c.dispatch(msg.m)
 			msg.Recycle()
 		}
 	}
 STOP:
 	close(done)
 	c.hbC.Cancel()
-	logger.I("read exit, reason=%s", closeReason)
+	log.I("read exit, reason=%s", closeReason)
+}
+
+// onPolledRead handles the message read from the connection served by netpoll, it replaces the runRead.
+func (c *UserClient) onPolledRead(data []byte, err error) {
+	if !c.IsRunning() {
+		return
+	}
+	if err != nil {
+		if err == conn.ErrMessageTooLarge {
+			// the rest of message is not read, the connection can't be read anymore.
+			_ = c.EnqueueMessage(messages.NewMessage(0, messages.ActionNotifyError, tooLargeError(c.config.MaxMessageSize)))
+		}
+		log.I("read exit, reason=%s", err.Error())
+		c.Exit()
+		return
+	}
+	m := messages.NewEmptyMessage()
+	err = c.codec.Decode(data, m)
+	if err != nil {
+		_ = c.EnqueueMessage(messages.NewMessage(0, messages.ActionNotifyError, messages.ErrCodeInvalidMessage.New(err.Error())))
+		return
+	}
+	if c.info.ID == "" {
+		log.I("read exit, reason=client not logged")
+		c.Exit()
+		return
+	}
+	atomic.StoreInt64(&c.lastReadAt, time.Now().UnixNano())
+	c.dispatch(m)
+}
+
+// checkPolledHeartbeat checks the client heartbeat of the connection served by netpoll, it runs in runWrite.
+func (c *UserClient) checkPolledHeartbeat() {
+	if time.Duration(time.Now().UnixNano()-atomic.LoadInt64(&c.lastReadAt)) < c.config.ClientHeartbeatDuration {
+		c.hbLost = 0
+	} else {
+		c.hbLost++
+		if c.hbLost > c.config.HeartbeatLostLimit {
+			log.I("read exit, reason=heartbeat lost")
+			c.Exit()
+			return
+		}
+	}
+	c.hbC.Cancel()
+	c.hbC = tw.After(c.config.ClientHeartbeatDuration)
+	_ = c.EnqueueMessage(messages.NewPooledMessage(0, messages.ActionHeartbeat, nil))
+}
+
+// dispatch the message from client to handler.
+func (c *UserClient) dispatch(m *messages.GlideMessage) {
+	if p := atomic.LoadInt64(&c.protocol); p < messages.ProtocolCurrent {
+		m = messages.Upgrade(m, p)
+	}
+	if !c.acceptAction(m) {
+		return
+	}
+	switch m.GetAction() {
+	case messages.ActionHello:
+		c.handleHello(m)
+		return
+	case messages.ActionChunk:
+		c.handleChunk(m)
+		return
+	case messages.ActionBatch:
+		c.handleBatch(m)
+		return
+	}
+	ctx, span := tracing.Start(m, "gate.read", attribute.String("glide.uid", c.info.ID.UID()))
+	tracing.Inject(ctx, m)
+	c.msgHandler(c.info, m)
+	span.End()
+}
+
+// acceptAction returns false and notifies the client if the action of message is not accepted from client.
+func (c *UserClient) acceptAction(m *messages.GlideMessage) bool {
+	action := m.GetAction()
+	reason := c.rejectReason(action)
+	if reason == "" {
+		return true
+	}
+	metrics.ActionsRejected.WithLabelValues(reason).Inc()
+	log.D("action %s of client %s rejected: %s", action, c.info.ID, reason)
+	_ = c.EnqueueMessage(messages.NewMessage(m.GetSeq(), messages.ActionNotifyUnknownAction, action))
+	return false
+}
+
+// rejectReason returns the reason why the action is not accepted from client, empty if it's accepted.
+func (c *UserClient) rejectReason(action messages.Action) string {
+	if action.IsInternal() || action.Group() == messages.GroupInternal {
+		return "internal"
+	}
+	if c.config.RejectUnknownAction && !action.IsKnown() {
+		return "unknown"
+	}
+	if capability := messages.CapabilityOf(action); capability != "" && messages.IsDisabled(capability) {
+		return "disabled"
+	}
+	return ""
 }
 
 // runWrite message to client.
@@ -266,11 +612,16 @@ func (c *UserClient) runWrite() {
 	defer func() {
 		err := recover()
 		if err != nil {
-			logger.D("write message error, exit client: %v", err)
+			log.D("write message error, exit client: %v", err)
 			c.Exit()
 		}
 	}()
 
+	// the client heartbeat is checked here when the connection is served by netpoll, there is no runRead.
+	var hbC chan struct{}
+	if c.polled {
+		hbC = c.hbC.C
+	}
 	var closeReason string
 	for {
 		select {
@@ -279,28 +630,44 @@ func (c *UserClient) runWrite() {
 				closeReason = "closed initiative"
 			}
 			goto STOP
+		case <-hbC:
+			c.checkPolledHeartbeat()
+			hbC = c.hbC.C
 		case <-c.hbS.C:
 			if !c.IsRunning() {
 				closeReason = "client not running"
 				goto STOP
 			}
-			_ = c.EnqueueMessage(messages.NewMessage(0, messages.ActionHeartbeat, nil))
+			_ = c.EnqueueMessage(messages.NewPooledMessage(0, messages.ActionHeartbeat, nil))
+			c.hbS.Cancel()
+			c.hbS = tw.After(c.config.ServerHeartbeatDuration)
+		case e := <-c.control:
+			c.write2Conn(e)
 			c.hbS.Cancel()
 			c.hbS = tw.After(c.config.ServerHeartbeatDuration)
-		case m := <-c.messages:
-			if m == nil {
+		case e := <-c.messages:
+			if e.m == nil {
 				closeReason = "message is nil, maybe client has closed"
 				c.Exit()
 				break
 			}
-			c.write2Conn(m)
+			c.writePrior(messages.PriorityNormal)
+			c.write2Conn(e)
+			c.hbS.Cancel()
+			c.hbS = tw.After(c.config.ServerHeartbeatDuration)
+		case e := <-c.bulk:
+			c.writePrior(messages.PriorityLow)
+			c.write2Conn(e)
 			c.hbS.Cancel()
 			c.hbS = tw.After(c.config.ServerHeartbeatDuration)
 		}
 	}
 STOP:
 	c.hbS.Cancel()
-	logger.D("write exit, addr=%s, reason:%s", c.info.CliAddr, closeReason)
+	if c.polled {
+		c.hbC.Cancel()
+	}
+	log.D("write exit, addr=%s, reason:%s", c.info.CliAddr, closeReason)
 }
 
 // Exit client, note: exit client will not close conn right now, but will close when message chan is empty.
@@ -326,26 +693,25 @@ func (c *UserClient) Exit() {
 	} else {
 		// close connection when all message in queue is sent
 		go func() {
-			for {
-				select {
-				case m := <-c.messages:
-					c.write2Conn(m)
-				default:
-					goto END
-				}
-			}
-		END:
+			c.writePrior(messages.PriorityAuto)
 			c.close()
 		}()
 	}
 }
 
 func (c *UserClient) Run() {
-	logger.I("new client running addr:%s id:%s", c.conn.GetConnInfo().Addr, c.info.ID)
+	log.I("new client running addr:%s id:%s", c.conn.GetConnInfo().Addr, c.info.ID)
 	atomic.StoreInt32(&c.state, stateRunning)
 	c.closeWriteOnce = sync.Once{}
 	c.closeReadOnce = sync.Once{}
 
+	if pc, ok := c.conn.(conn.PolledConnection); ok {
+		c.polled = true
+		atomic.StoreInt64(&c.lastReadAt, time.Now().UnixNano())
+		pc.SetReadHandler(c.onPolledRead)
+		go c.runWrite()
+		return
+	}
 	go c.runRead()
 	go c.runWrite()
 }
@@ -355,23 +721,77 @@ func (c *UserClient) isClosed() bool {
 }
 
 func (c *UserClient) close() {
+	// messages left in queue are discarded.
+	metrics.QueuedMessages.Sub(float64(atomic.LoadInt64(&c.queuedMessage)))
 	close(c.messages)
 	_ = c.conn.Close()
 }
 
-func (c *UserClient) write2Conn(m *messages.GlideMessage) {
-	b, err := codec.Encode(m)
+func (c *UserClient) write2Conn(e envelope) {
+	m := e.m
+	defer messages.ReleaseMessage(m)
+	m, ok := c.adapt(m)
+	if !ok {
+		c.onDequeued()
+		return
+	}
+	m = c.localize(m)
+	if p := atomic.LoadInt64(&c.protocol); p < messages.ProtocolCurrent {
+		m = messages.Downgrade(m, p)
+	}
+	b, err := messages.Encode(c.codec, m)
 	if err != nil {
-		logger.E("serialize output message", err)
+		c.onDequeued()
+		log.E("serialize output message", err)
 		return
 	}
+	c.shape(len(b))
+	start := time.Now().UnixNano()
+	atomic.StoreInt64(&c.lastResidence, start-e.at)
+	atomic.StoreInt64(&c.writingSince, start)
 	err = c.conn.Write(b)
-	atomic.AddInt64(&c.queuedMessage, -1)
+	atomic.StoreInt64(&c.writingSince, 0)
+	atomic.StoreInt64(&c.lastWriteLatency, time.Now().UnixNano()-start)
+	c.onDequeued()
 	if err != nil {
-		logger.D("runWrite error: %s", err.Error())
+		log.D("runWrite error: %s", err.Error())
 		c.closeWriteOnce.Do(func() {
 			close(c.closeWriteCh)
 		})
+		return
+	}
+	metrics.MessagesOut.WithLabelValues(m.GetAction().Label()).Inc()
+	if id := c.info.ID; id != "" && !id.IsTemp() {
+		metering.Bytes(id.UID(), int64(len(b)))
+		tap.Mirror(id.UID(), id.Device(), m)
+	}
+	if c.config.Outbound != nil {
+		c.config.Outbound.Add(int64(len(b)))
+	}
+}
+
+// shape waits until n bytes are allowed to write by the egress rate of the device of client, see EgressShaper.
+func (c *UserClient) shape(n int) {
+	if c.config.Shaper == nil {
+		return
+	}
+	rate := c.config.Shaper.rate(c.info.ID.Device())
+	if rate <= 0 {
+		c.egress = nil
+		return
+	}
+	if c.egress == nil || c.egress.rate != rate {
+		c.egress = newTokenBucket(rate)
+	}
+	wait := c.egress.take(n, time.Now())
+	if wait <= 0 {
+		return
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-c.closeWriteCh:
 	}
 }
 
@@ -384,12 +804,59 @@ func (c *UserClient) stopReadWrite() {
 	})
 }
 
+// handleChunk adds the chunk to assembler, and dispatches the message when all chunks received, chunks are accepted
+// from authenticated clients only.
+func (c *UserClient) handleChunk(m *messages.GlideMessage) {
+	if c.info.ID.IsTemp() {
+		_ = c.EnqueueMessage(messages.NewMessage(0, messages.ActionNotifyUnauthenticated, nil))
+		return
+	}
+	if !messages.HasFeature(atomic.LoadInt64(&c.protocol), messages.FeatureChunk) {
+		_ = c.EnqueueMessage(messages.NewErrorMessage(0, messages.ActionNotifyError, errChunkUnsupported.Error()))
+		return
+	}
+	chunk := messages.Chunk{}
+	err := m.Data.Deserialize(&chunk)
+	if err != nil {
+		_ = c.EnqueueMessage(messages.NewErrorMessage(0, messages.ActionNotifyError, errInvalidChunk.Error()))
+		return
+	}
+	data, err := c.chunks.add(&chunk)
+	if err != nil {
+		_ = c.EnqueueMessage(messages.NewErrorMessage(0, messages.ActionNotifyError, err.Error()))
+		return
+	}
+	if data == nil {
+		return
+	}
+	assembled := messages.NewEmptyMessage()
+	err = c.codec.Decode(data, assembled)
+	if err == nil && assembled.GetAction() == messages.ActionChunk {
+		err = errInvalidChunk
+	}
+	if err != nil {
+		_ = c.EnqueueMessage(messages.NewErrorMessage(0, messages.ActionNotifyError, err.Error()))
+		return
+	}
+	c.dispatch(assembled)
+}
+
 func (c *UserClient) handleHello(m *messages.GlideMessage) {
 	hello := messages.Hello{}
 	err := m.Data.Deserialize(&hello)
 	if err != nil {
-		_ = c.EnqueueMessage(messages.NewMessage(0, messages.ActionNotifyError, "invalid handleHello message"))
-	} else {
-		c.info.Version = hello.ClientVersion
+		_ = c.EnqueueMessage(messages.NewErrorMessage(0, messages.ActionNotifyError, errInvalidHello))
+		return
+	}
+	protocol, err := messages.NegotiateProtocol(hello.Protocol)
+	if err != nil {
+		_ = c.EnqueueMessage(messages.NewErrorMessage(0, messages.ActionNotifyError, err.Error()))
+		log.I("read exit, reason=%s %d", err.Error(), hello.Protocol)
+		c.Exit()
+		return
 	}
+	atomic.StoreInt64(&c.protocol, protocol)
+	c.info.Version = hello.ClientVersion
+	c.info.Protocol = protocol
+	c.setLocale(hello.Locale)
 }
@@ -4,7 +4,6 @@ import (
 	"github.com/glide-im/glide/pkg/conn"
 	"github.com/glide-im/glide/pkg/messages"
 	"github.com/stretchr/testify/assert"
-	"log"
 	"testing"
 	"time"
 )
@@ -135,7 +134,7 @@ type mockConnection struct {
 
 func (m *mockConnection) Write(data []byte) error {
 	time.Sleep(time.Millisecond * m.writeDelayMilliSec)
-	log.Println("runWrite:", string(data))
+	log.D("runWrite: %s", string(data))
 	return nil
 }
 
@@ -146,7 +145,7 @@ func (m *mockConnection) Read() ([]byte, error) {
 }
 
 func (m *mockConnection) Close() error {
-	log.Println("close connection")
+	log.D("close connection")
 	return nil
 }
 
@@ -191,10 +190,139 @@ func (m mockGateway) SetClientID(old ID, new_ ID) error {
 }
 
 func (m mockGateway) ExitClient(id ID) error {
-	log.Println("exit client:", id)
+	log.D("exit client: %s", id)
 	return nil
 }
 
 func (m mockGateway) EnqueueMessage(id ID, message *messages.GlideMessage) error {
 	return nil
 }
+
+func TestClient_EnqueueOverflow(t *testing.T) {
+	newClient := func(policy OverflowPolicy) *UserClient {
+		fn, _ := mockReadFn()
+		return NewClientWithConfig(&mockConnection{mockRead: fn}, mockGateway{}, mockMsgHandler, &ClientConfig{
+			ClientHeartbeatDuration: defaultHeartbeatDuration,
+			ServerHeartbeatDuration: defaultServerHeartbeatDuration,
+			SendQueueSize:           2,
+			OverflowPolicy:          policy,
+			EnqueueTimeout:          time.Millisecond * 50,
+		}).(*UserClient)
+	}
+	msg := func(seq int64) *messages.GlideMessage {
+		return messages.NewMessage(seq, messages.ActionChatMessage, nil)
+	}
+
+	// the clients are not running, nothing is consumed from the queue.
+	client := newClient(OverflowDropNew)
+	assert.NoError(t, client.EnqueueMessage(msg(1)))
+	assert.NoError(t, client.EnqueueMessage(msg(2)))
+	assert.True(t, IsQueueFull(client.EnqueueMessage(msg(3))))
+	assert.Equal(t, int64(2), client.GetInfo().QueueDepth)
+
+	client = newClient(OverflowDropOldest)
+	for i := int64(1); i <= 3; i++ {
+		assert.NoError(t, client.EnqueueMessage(msg(i)))
+	}
+	assert.Equal(t, int64(2), client.GetInfo().QueueDepth)
+	assert.Equal(t, int64(2), (<-client.messages).m.GetSeq())
+
+	client = newClient(OverflowBlock)
+	_ = client.EnqueueMessage(msg(1))
+	_ = client.EnqueueMessage(msg(2))
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		<-client.messages
+	}()
+	assert.NoError(t, client.EnqueueMessage(msg(3)))
+	start := time.Now()
+	assert.True(t, IsQueueFull(client.EnqueueMessage(msg(4))))
+	assert.True(t, time.Since(start) >= time.Millisecond*50)
+
+	client = newClient(OverflowDisconnect)
+	client.state = stateRunning
+	_ = client.EnqueueMessage(msg(1))
+	_ = client.EnqueueMessage(msg(2))
+	assert.True(t, IsQueueFull(client.EnqueueMessage(msg(3))))
+	time.Sleep(time.Millisecond * 50)
+	assert.False(t, client.IsRunning())
+}
+
+type mockPolledConnection struct {
+	mockConnection
+	handler conn.ReadHandler
+}
+
+func (m *mockPolledConnection) SetReadHandler(h conn.ReadHandler) {
+	m.handler = h
+}
+
+func TestClient_Polled(t *testing.T) {
+	pc := &mockPolledConnection{}
+	received := make(chan *messages.GlideMessage, 1)
+	client := NewClientWithConfig(pc, mockGateway{}, func(cliInfo *Info, message *messages.GlideMessage) {
+		received <- message
+	}, &ClientConfig{
+		ClientHeartbeatDuration: time.Millisecond * 600,
+		ServerHeartbeatDuration: defaultServerHeartbeatDuration,
+		HeartbeatLostLimit:      0,
+	})
+	client.SetID(NewID2("1"))
+	client.Run()
+	assert.NotNil(t, pc.handler)
+
+	b, _ := messages.JsonCodec.Encode(messages.NewMessage(1, messages.ActionHeartbeat, nil))
+	pc.handler(b, nil)
+	assert.Equal(t, int64(1), (<-received).Seq)
+
+	// no message from client, the heartbeat lost.
+	time.Sleep(time.Second * 2)
+	assert.False(t, client.IsRunning())
+}
+
+func TestClient_PriorityLanes(t *testing.T) {
+	fn, _ := mockReadFn()
+	client := NewClient(&mockConnection{mockRead: fn}, mockGateway{}, mockMsgHandler).(*UserClient)
+
+	history := messages.NewMessage(1, messages.ActionApiSuccess, nil)
+	history.Priority = messages.PriorityLow
+	assert.NoError(t, client.EnqueueMessage(history))
+	assert.NoError(t, client.EnqueueMessage(messages.NewMessage(2, messages.ActionChatMessage, nil)))
+	assert.NoError(t, client.EnqueueMessage(messages.NewMessage(3, messages.ActionAckMessage, nil)))
+	assert.NoError(t, client.EnqueueMessage(messages.NewMessage(4, messages.ActionCallInvite, nil)))
+
+	// only the control lane is prior to the normal.
+	e, ok := client.poll(messages.PriorityNormal)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), e.m.GetSeq())
+
+	var seq []int64
+	for {
+		e, ok = client.poll(messages.PriorityAuto)
+		if !ok {
+			break
+		}
+		seq = append(seq, e.m.GetSeq())
+	}
+	assert.Equal(t, []int64{4, 2, 1}, seq)
+}
+
+func TestClient_AcceptAction(t *testing.T) {
+	fn, _ := mockReadFn()
+	handled := make(chan messages.Action, 3)
+	client := NewClientWithConfig(&mockConnection{mockRead: fn}, mockGateway{}, func(cliInfo *Info, message *messages.GlideMessage) {
+		handled <- message.GetAction()
+	}, &ClientConfig{RejectUnknownAction: true}).(*UserClient)
+
+	client.dispatch(messages.NewMessage(1, messages.ActionInternalOnline, nil))
+	client.dispatch(messages.NewMessage(2, "test.unknown", nil))
+	client.dispatch(messages.NewMessage(3, messages.ActionChatMessage, nil))
+
+	assert.Equal(t, messages.Action(messages.ActionChatMessage), <-handled)
+	assert.Empty(t, handled)
+	for i := 0; i < 2; i++ {
+		e, ok := client.poll(messages.PriorityAuto)
+		assert.True(t, ok)
+		assert.Equal(t, messages.Action(messages.ActionNotifyUnknownAction), e.m.GetAction())
+	}
+}
@@ -0,0 +1,43 @@
+package gate
+
+// ConflictPolicy decides how the client authenticating with the id of another connected client is handled.
+type ConflictPolicy int
+
+const (
+	// ConflictKickExisting kicks out the connected client with messages.ActionNotifyKickOut, the default policy.
+	ConflictKickExisting ConflictPolicy = iota
+	// ConflictRejectIncoming keeps the connected client, the authentication fails with ErrIDConflict.
+	ConflictRejectIncoming
+)
+
+// ConflictResolver returns the policy for the incoming client authenticating with the id of the existing client.
+type ConflictResolver func(existing Info, incoming Info) ConflictPolicy
+
+// conflictResolver resolves duplicate id on authentication, implemented by Impl.
+type conflictResolver interface {
+	resolveConflict(existing ID, incoming ID) ConflictPolicy
+}
+
+var _ conflictResolver = (*Impl)(nil)
+
+// SetConflictResolver sets the resolver decides whether the client authenticating with the id of a connected client
+// kicks it out or is rejected, nil to always kick out the connected client.
+func (c *Impl) SetConflictResolver(r ConflictResolver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conflictResolver = r
+}
+
+func (c *Impl) resolveConflict(existing ID, incoming ID) ConflictPolicy {
+	existing.SetGateway(c.id)
+	incoming.SetGateway(c.id)
+	c.mu.RLock()
+	r := c.conflictResolver
+	e, ok1 := c.clients[existing]
+	i, ok2 := c.clients[incoming]
+	c.mu.RUnlock()
+	if r == nil || !ok1 || !ok2 {
+		return ConflictKickExisting
+	}
+	return r(e.GetInfo(), i.GetInfo())
+}
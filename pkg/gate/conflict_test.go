@@ -0,0 +1,60 @@
+package gate
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImpl_SetClientID_Errors(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+	gateway.AddClient(&mockClient{info: Info{ID: NewID("g1", "1", "")}, running: true})
+	gateway.AddClient(&mockClient{info: Info{ID: NewID("g1", "2", "")}, running: true})
+
+	err = gateway.SetClientID(NewID2("3"), NewID2("4"))
+	assert.True(t, errors.Is(err, ErrClientNotExist))
+	assert.True(t, IsClientNotExist(err))
+	err = gateway.SetClientID(NewID2("1"), NewID2("2"))
+	assert.True(t, errors.Is(err, ErrIDConflict))
+	assert.True(t, IsIDAlreadyExist(err))
+	err = gateway.SetClientID(NewID2("1"), NewID2("1"))
+	assert.True(t, errors.Is(err, ErrAlreadyAuthenticated))
+	assert.False(t, IsIDAlreadyExist(err))
+}
+
+func TestAuthenticator_ConflictResolver(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+	var existing, incoming Info
+	gateway.SetConflictResolver(func(e Info, i Info) ConflictPolicy {
+		existing, incoming = e, i
+		return ConflictRejectIncoming
+	})
+	auth := NewAuthenticator(gateway, "secret")
+
+	logged := &credClient{mockClient: mockClient{info: Info{ID: NewID("g1", "1", "")}, running: true}}
+	gateway.AddClient(logged)
+	tempID, _ := GenTempID("g1")
+	c := &credClient{mockClient: mockClient{info: Info{ID: tempID}, running: true}}
+	gateway.AddClient(c)
+
+	_, err = auth.updateClient(c, &ClientAuthCredentials{UserID: "1"})
+	assert.ErrorIs(t, err, ErrIDConflict)
+	assert.Nil(t, c.GetCredentials())
+	assert.Equal(t, "1", existing.ID.UID())
+	assert.Equal(t, tempID.UID(), incoming.ID.UID())
+	assert.Nil(t, logged.find(messages.ActionNotifyKickOut))
+	assert.Equal(t, logged, gateway.GetClient(NewID("g1", "1", "")))
+
+	gateway.SetConflictResolver(nil)
+	_, err = auth.updateClient(c, &ClientAuthCredentials{UserID: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", c.GetCredentials().UserID)
+	assert.NotNil(t, logged.find(messages.ActionNotifyKickOut))
+	assert.Equal(t, c, gateway.GetClient(NewID("g1", "1", "")))
+}
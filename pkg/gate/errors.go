@@ -1,9 +1,33 @@
 package gate
 
+import (
+	"errors"
+
+	"github.com/glide-im/glide/pkg/messages"
+)
+
 const (
-	errClientClosed       = "client closed"
-	errClientNotExist     = "client does not exist"
-	errClientAlreadyExist = "id already exist"
+	errClientClosed         = "client closed"
+	errClientNotExist       = "client does not exist"
+	errClientAlreadyExist   = "id already exist"
+	errAlreadyAuthenticated = "client already authenticated with the id"
+	errEnqueueFailed        = "enqueue message to client failed"
+	errInvalidHello         = "invalid handleHello message"
+	errInvalidAuthMessage   = "invalid authenticate message"
+	errCredentialExpired    = "credential expired"
+	errNoCredentials        = "no credentials"
+	errMessageTooLarge      = "message too large, send it in chunks"
+)
+
+// Errors returned by SetClientID, UpdateClient and ExitClient of the gateway, check them by errors.Is, or by IsXxx
+// functions which also match errors passed through RPC by message.
+var (
+	// ErrClientNotExist the client of the id does not exist.
+	ErrClientNotExist = errors.New(errClientNotExist)
+	// ErrIDConflict the new id of SetClientID is used by another client.
+	ErrIDConflict = errors.New(errClientAlreadyExist)
+	// ErrAlreadyAuthenticated the client is already set the new id of SetClientID.
+	ErrAlreadyAuthenticated = errors.New(errAlreadyAuthenticated)
 )
 
 func IsClientClosed(err error) bool {
@@ -19,3 +43,27 @@ func IsClientNotExist(err error) bool {
 func IsIDAlreadyExist(err error) bool {
 	return err != nil && err.Error() == errClientAlreadyExist
 }
+
+// IsAlreadyAuthenticated returns true if SetClientID is called with the id the client already has.
+func IsAlreadyAuthenticated(err error) bool {
+	return err != nil && err.Error() == errAlreadyAuthenticated
+}
+
+// init registers codes of errors notified to clients, see messages.ErrorOf.
+func init() {
+	messages.RegisterError(messages.ErrCodeInvalidMessage, errInvalidHello, errInvalidChunk.Error(), errInvalidBatch,
+		errBatchItemNotAllowed, errBatchItemRejected)
+	messages.RegisterError(messages.ErrCodeMessageTooLarge, errMessageTooLarge, errChunkedTooLarge.Error())
+	messages.RegisterError(messages.ErrCodeUnsupported, errChunkUnsupported.Error(), errBatchUnsupported)
+	messages.RegisterError(messages.ErrCodeBusy, errQueueFull, errEnqueueFailed)
+	messages.RegisterError(messages.ErrCodeRateLimited, errTooManyChunked.Error())
+	messages.RegisterError(messages.ErrCodeInvalidCredentials, errInvalidAuthMessage, errCredentialInvalid,
+		errNoCredentials, errLegacyRejected)
+	messages.RegisterError(messages.ErrCodeCredentialExpired, errCredentialExpired)
+	messages.RegisterError(messages.ErrCodeConnectionsExceeded, errUserConnectionsExceeded)
+	messages.RegisterError(messages.ErrCodeChallengeRequired, errChallengeRequired)
+	messages.RegisterError(messages.ErrCodeChallengeFailed, errChallengeFailed)
+	messages.RegisterError(messages.ErrCodeTicketInvalid, errTicketInvalid, errTicketExpired, errTicketReplayed)
+	messages.RegisterError(messages.ErrCodeResumeInvalid, errResumeInvalid)
+	messages.RegisterError(messages.ErrCodeSignInvalid, errSignMissing, errSignInvalid, errSignNoSecret)
+}
@@ -0,0 +1,155 @@
+package gate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+// EventType the type of client connection events published by the EventBus.
+type EventType string
+
+const (
+	// EventClientConnected a connection is added to the gateway with the temporary id.
+	EventClientConnected EventType = "client.connected"
+	// EventClientAuthenticated the client is set the id of the authenticated user.
+	EventClientAuthenticated EventType = "client.authenticated"
+	// EventClientClosed the client is removed from the gateway.
+	EventClientClosed EventType = "client.closed"
+	// EventMessageDropped the message from or to the client is dropped, Reason is why.
+	EventMessageDropped EventType = "message.dropped"
+	// EventQueueOverflow the send queue of the client is full, Reason is the OverflowPolicy applied.
+	EventQueueOverflow EventType = "queue.overflow"
+)
+
+const defaultEventQueueSize = 1024
+
+// Event the client connection event.
+type Event struct {
+	Type EventType
+	// Client the info of the client, the ID is the id after the event.
+	Client Info
+	// PreviousID the id before the client authenticated, empty for other events.
+	PreviousID ID
+	// Message the message dropped, nil for other events.
+	Message *messages.GlideMessage
+	Reason  string
+	Time    time.Time
+}
+
+// EventHandler handles events of the subscription.
+type EventHandler func(e *Event)
+
+// eventSubscriber delivers events to the handler in its own goroutine in the order published, events are dropped when
+// the queue is full, so a slow handler never blocks the gateway.
+type eventSubscriber struct {
+	types   map[EventType]bool
+	handler EventHandler
+	queue   chan *Event
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (s *eventSubscriber) run() {
+	for {
+		select {
+		case e := <-s.queue:
+			s.handle(e)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *eventSubscriber) handle(e *Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.E("panic in event handler of %s: %v", e.Type, r)
+		}
+	}()
+	s.handler(e)
+}
+
+// EventBus publishes client connection events of the gateway to subscribers in process, embedders implement the
+// presence, metrics or webhooks by subscribing it instead of patching the gateway.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[*eventSubscriber]struct{}
+	queueSize   int
+}
+
+// NewEventBus returns the EventBus buffers queueSize events for each subscriber, default 1024.
+func NewEventBus(queueSize int) *EventBus {
+	if queueSize <= 0 {
+		queueSize = defaultEventQueueSize
+	}
+	return &EventBus{
+		subscribers: map[*eventSubscriber]struct{}{},
+		queueSize:   queueSize,
+	}
+}
+
+// Subscribe subscribes events of the types, all types if empty. The handler is called in a goroutine of the
+// subscription, events are dropped if the handler can't keep up. It returns the function to cancel the subscription.
+func (b *EventBus) Subscribe(handler EventHandler, types ...EventType) (unsubscribe func()) {
+	s := &eventSubscriber{
+		handler: handler,
+		queue:   make(chan *Event, b.queueSize),
+		done:    make(chan struct{}),
+	}
+	if len(types) > 0 {
+		s.types = map[EventType]bool{}
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+	b.mu.Lock()
+	b.subscribers[s] = struct{}{}
+	b.mu.Unlock()
+	go s.run()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.subscribers, s)
+		b.mu.Unlock()
+		s.once.Do(func() {
+			close(s.done)
+		})
+	}
+}
+
+// Publish publishes the event to subscribers without blocking, the Time is set if zero.
+func (b *EventBus) Publish(e *Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.subscribers) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	for s := range b.subscribers {
+		if s.types != nil && !s.types[e.Type] {
+			continue
+		}
+		select {
+		case s.queue <- e:
+		default:
+			log.W("event queue is full, %s event of %s dropped", e.Type, e.Client.ID)
+		}
+	}
+}
+
+// Events returns the bus of client connection events of the gateway.
+func (c *Impl) Events() *EventBus {
+	return c.events
+}
+
+// publishEvent publishes the event of the client to the event bus of the gateway.
+func (c *Impl) publishEvent(t EventType, info Info, reason string) {
+	c.events.Publish(&Event{Type: t, Client: info, Reason: reason})
+}
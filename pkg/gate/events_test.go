@@ -0,0 +1,129 @@
+package gate
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/ordered"
+	"github.com/stretchr/testify/assert"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (r *eventRecorder) handle(e *Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) types() []EventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ret []EventType
+	for _, e := range r.events {
+		ret = append(ret, e.Type)
+	}
+	return ret
+}
+
+func TestEventBus_Subscribe(t *testing.T) {
+	bus := NewEventBus(0)
+	all, connected := &eventRecorder{}, &eventRecorder{}
+	cancel := bus.Subscribe(all.handle)
+	bus.Subscribe(connected.handle, EventClientConnected)
+
+	bus.Publish(&Event{Type: EventClientConnected})
+	bus.Publish(&Event{Type: EventClientClosed})
+	assert.Eventually(t, func() bool { return len(all.types()) == 2 }, time.Second, time.Millisecond*10)
+	assert.Equal(t, []EventType{EventClientConnected}, connected.types())
+
+	cancel()
+	bus.Publish(&Event{Type: EventClientConnected})
+	assert.Eventually(t, func() bool { return len(connected.types()) == 2 }, time.Second, time.Millisecond*10)
+	assert.Len(t, all.types(), 2)
+}
+
+func TestImpl_Events(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+	gateway.Use(func(c Client, m *messages.GlideMessage) (bool, error) {
+		return false, errors.New("rejected")
+	})
+	r := &eventRecorder{}
+	gateway.Events().Subscribe(r.handle)
+
+	tempID, _ := GenTempID("g1")
+	c := &credClient{mockClient: mockClient{info: Info{ID: tempID}, running: true}}
+	gateway.AddClient(c)
+	assert.NoError(t, gateway.SetClientID(tempID, NewID2("1")))
+	assert.True(t, gateway.interceptClientMessage(c, messages.NewMessage(1, messages.ActionChatMessage, nil)))
+	assert.NoError(t, gateway.ExitClient(NewID2("1")))
+
+	want := []EventType{EventClientConnected, EventClientAuthenticated, EventMessageDropped, EventClientClosed}
+	assert.Eventually(t, func() bool { return len(r.types()) == len(want) }, time.Second, time.Millisecond*10)
+	assert.Equal(t, want, r.types())
+	assert.Equal(t, tempID, r.events[1].PreviousID)
+	assert.Equal(t, "1", r.events[1].Client.ID.UID())
+	assert.Equal(t, "rejected", r.events[2].Reason)
+	assert.Equal(t, "1", r.events[3].Client.ID.UID())
+}
+
+type blockingClient struct {
+	mockClient
+	block    chan struct{}
+	enqueued chan *messages.GlideMessage
+}
+
+func (b *blockingClient) EnqueueMessage(message *messages.GlideMessage) error {
+	<-b.block
+	b.enqueued <- message
+	return nil
+}
+
+func TestImpl_EnqueueOverflow(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+	gateway.sendOrder = ordered.NewExecutor(gateway.pool.Submit, &ordered.Options{MaxPending: 2})
+	r := &eventRecorder{}
+	gateway.Events().Subscribe(r.handle, EventQueueOverflow)
+
+	id := NewID2("1")
+	c := &blockingClient{
+		mockClient: mockClient{info: Info{ID: id}, running: true},
+		block:      make(chan struct{}),
+		enqueued:   make(chan *messages.GlideMessage, 10),
+	}
+	gateway.AddClient(c)
+	m := func(seq int64) *messages.GlideMessage {
+		return messages.NewMessage(seq, messages.ActionChatMessage, nil)
+	}
+	assert.NoError(t, gateway.EnqueueMessage(id, m(1)))
+	assert.NoError(t, gateway.EnqueueMessage(id, m(2)))
+	// drop new by default.
+	assert.Error(t, gateway.EnqueueMessage(id, m(3)))
+
+	gateway.SetOverflowPolicy(OverflowDropOldest, 0)
+	assert.NoError(t, gateway.EnqueueMessage(id, m(4)))
+
+	gateway.SetOverflowPolicy(OverflowBlock, time.Second)
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		close(c.block)
+	}()
+	assert.NoError(t, gateway.EnqueueMessage(id, m(5)))
+	var seqs []int64
+	for i := 0; i < 3; i++ {
+		seqs = append(seqs, (<-c.enqueued).GetSeq())
+	}
+	assert.Equal(t, []int64{1, 4, 5}, seqs)
+	assert.Eventually(t, func() bool { return len(r.types()) == 3 }, time.Second, time.Millisecond*10)
+	assert.Equal(t, OverflowBlock.String(), r.events[2].Reason)
+}
@@ -0,0 +1,114 @@
+package gatetest
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+var _ gate.DefaultClient = (*Client)(nil)
+
+// Client is a fake gate.DefaultClient, the messages enqueued are captured instead of sent to a connection.
+type Client struct {
+	// Capture the messages enqueued to the client.
+	Capture
+
+	mu           sync.Mutex
+	info         gate.Info
+	running      bool
+	credentials  *gate.ClientAuthCredentials
+	interceptors []gate.MessageInterceptor
+}
+
+// NewClient creates a running fake client of id.
+func NewClient(id gate.ID) *Client {
+	now := time.Now().UnixMilli()
+	return &Client{
+		info: gate.Info{
+			ID:           id,
+			Protocol:     messages.ProtocolCurrent,
+			AliveAt:      now,
+			ConnectionAt: now,
+			Gateway:      id.Gateway(),
+			CliAddr:      "127.0.0.1:0",
+		},
+		running: true,
+	}
+}
+
+func (c *Client) SetID(id gate.ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.info.ID = id
+}
+
+func (c *Client) IsRunning() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.running
+}
+
+func (c *Client) EnqueueMessage(message *messages.GlideMessage) error {
+	if !c.IsRunning() {
+		return errors.New(errClientClosed)
+	}
+	c.add(message)
+	return nil
+}
+
+func (c *Client) Exit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = false
+}
+
+// Run does nothing, the fake client has no message loop.
+func (c *Client) Run() {}
+
+func (c *Client) GetInfo() gate.Info {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.info
+}
+
+// SetInfo updates the info of the client, such as Attributes and Protocol.
+func (c *Client) SetInfo(fn func(info *gate.Info)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.info)
+}
+
+func (c *Client) SetCredentials(credentials *gate.ClientAuthCredentials) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.credentials = credentials
+}
+
+func (c *Client) GetCredentials() *gate.ClientAuthCredentials {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.credentials
+}
+
+func (c *Client) AddMessageInterceptor(interceptor gate.MessageInterceptor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interceptors = append(c.interceptors, interceptor)
+}
+
+// Intercept runs the message through interceptors added as the message is received from the connection, returns true
+// if the message is intercepted.
+func (c *Client) Intercept(m *messages.GlideMessage) bool {
+	c.mu.Lock()
+	interceptors := append([]gate.MessageInterceptor{}, c.interceptors...)
+	c.mu.Unlock()
+	for _, i := range interceptors {
+		if i(c, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+// Package gatetest provides in-memory fakes of the gate interfaces for applications embedding glide to unit test their
+// middlewares, interceptors and message handlers without real sockets.
+//
+//	gw := gatetest.NewGateway()
+//	c := gw.Connect("uid1", "web")
+//	gw.SetMessageHandler(myHandler)
+//	gw.Receive(c.GetInfo().ID, messages.NewMessage(1, messages.ActionChatMessage, msg))
+//	m, err := c.Wait(messages.ActionAckMessage, time.Second)
+package gatetest
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+const (
+	errClientClosed = "client closed"
+	errWaitTimeout  = "wait message timeout"
+)
+
+// ErrWaitTimeout returned by Capture.Wait when no message of the action is captured before timeout.
+var ErrWaitTimeout = errors.New(errWaitTimeout)
+
+// Capture records messages, it's safe for concurrent use.
+type Capture struct {
+	mu       sync.Mutex
+	messages []*messages.GlideMessage
+	notify   chan struct{}
+}
+
+func (c *Capture) add(m *messages.GlideMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, m)
+	if c.notify != nil {
+		close(c.notify)
+		c.notify = nil
+	}
+}
+
+// Messages returns all messages captured in the order received.
+func (c *Capture) Messages() []*messages.GlideMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*messages.GlideMessage{}, c.messages...)
+}
+
+// Find returns the messages captured of the action.
+func (c *Capture) Find(action messages.Action) []*messages.GlideMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ms []*messages.GlideMessage
+	for _, m := range c.messages {
+		if m.GetAction() == action {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// Last returns the last message captured, nil if there is none.
+func (c *Capture) Last() *messages.GlideMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.messages) == 0 {
+		return nil
+	}
+	return c.messages[len(c.messages)-1]
+}
+
+// Reset removes all messages captured.
+func (c *Capture) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = nil
+}
+
+// Wait waits for the first message of the action captured, the message captured before is returned immediately, the
+// handlers usually run in goroutines.
+func (c *Capture) Wait(action messages.Action, timeout time.Duration) (*messages.GlideMessage, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		c.mu.Lock()
+		for _, m := range c.messages {
+			if m.GetAction() == action {
+				c.mu.Unlock()
+				return m, nil
+			}
+		}
+		if c.notify == nil {
+			c.notify = make(chan struct{})
+		}
+		notify := c.notify
+		c.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-deadline.C:
+			return nil, ErrWaitTimeout
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package gatetest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGateway_Receive(t *testing.T) {
+	g := NewGateway()
+	c := g.Connect("1", "")
+	var handled []*messages.GlideMessage
+	g.SetMessageHandler(func(cliInfo *gate.Info, message *messages.GlideMessage) {
+		handled = append(handled, message)
+	})
+	g.Use(func(c gate.Client, m *messages.GlideMessage) (bool, error) {
+		if m.To == "error" {
+			return false, errors.New("rejected")
+		}
+		return false, nil
+	})
+	c.AddMessageInterceptor(func(dc gate.DefaultClient, msg *messages.GlideMessage) bool {
+		return msg.To == "intercepted"
+	})
+
+	m := messages.NewMessage(1, messages.ActionChatMessage, nil)
+	ok, err := g.Receive(gate.NewID2("1"), m)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []*messages.GlideMessage{m}, handled)
+
+	m = messages.NewMessage(2, messages.ActionChatMessage, nil)
+	m.To = "intercepted"
+	ok, _ = g.Receive(c.GetInfo().ID, m)
+	assert.False(t, ok)
+
+	m.To = "error"
+	ok, _ = g.Receive(c.GetInfo().ID, m)
+	assert.False(t, ok)
+	assert.Equal(t, messages.ActionNotifyError, c.Last().GetAction())
+	assert.Len(t, handled, 1)
+
+	_, err = g.Receive(gate.NewID2("2"), m)
+	assert.True(t, gate.IsClientNotExist(err))
+}
+
+func TestGateway_EnqueueMessage(t *testing.T) {
+	g := NewGateway()
+	c := g.Connect("1", "2")
+
+	assert.NoError(t, g.EnqueueMessage(gate.NewID("", "1", "2"), messages.NewMessage(1, messages.ActionHeartbeat, nil)))
+	assert.True(t, gate.IsClientNotExist(g.EnqueueMessage(gate.NewID2("1"), messages.NewMessage(1, messages.ActionHeartbeat, nil))))
+	assert.Len(t, c.Find(messages.ActionHeartbeat), 1)
+	assert.Len(t, g.Messages(), 1)
+
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		_ = g.EnqueueMessage(c.GetInfo().ID, messages.NewMessage(2, messages.ActionAckMessage, nil))
+	}()
+	m, err := c.Wait(messages.ActionAckMessage, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), m.GetSeq())
+	_, err = c.Wait(messages.ActionApiSuccess, time.Millisecond*10)
+	assert.ErrorIs(t, err, ErrWaitTimeout)
+
+	assert.NoError(t, g.SetClientID(c.GetInfo().ID, gate.NewID("", "3", "2")))
+	id := g.Client(gate.NewID("", "3", "2")).GetInfo().ID
+	assert.Equal(t, "3", id.UID())
+
+	assert.NoError(t, g.ExitClient(gate.NewID("", "3", "2")))
+	assert.False(t, c.IsRunning())
+	assert.Len(t, g.Exited(), 1)
+	assert.True(t, gate.IsClientClosed(c.EnqueueMessage(messages.NewMessage(3, messages.ActionHeartbeat, nil))))
+}
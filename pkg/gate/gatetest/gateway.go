@@ -0,0 +1,222 @@
+package gatetest
+
+import (
+	"sync"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+var _ gate.DefaultGateway = (*Gateway)(nil)
+var _ gate.AttributeUpdater = (*Gateway)(nil)
+
+// Gateway is a fake gate.DefaultGateway of in-memory clients, the messages received from clients are simulated by
+// Receive, passed through the middlewares to the MessageHandler synchronously. The gateway part of ids is set to the
+// gateway id as gate.Impl does, gate.NewID2(uid) addresses the client connected by Connect(uid, "").
+type Gateway struct {
+	// Capture the messages enqueued to all clients.
+	Capture
+
+	id string
+
+	mu          sync.RWMutex
+	clients     map[gate.ID]gate.Client
+	exited      []gate.ID
+	h           gate.MessageHandler
+	middlewares []gate.Middleware
+}
+
+// NewGateway creates the fake gateway with id "gatetest".
+func NewGateway() *Gateway {
+	return &Gateway{
+		id:      "gatetest",
+		clients: map[gate.ID]gate.Client{},
+	}
+}
+
+// Connect adds a fake client of uid and device to the gateway.
+func (g *Gateway) Connect(uid string, device string) *Client {
+	c := NewClient(gate.NewID(g.id, uid, device))
+	g.AddClient(c)
+	return c
+}
+
+// Client returns the fake client of id, nil if the client does not exist or it's not a fake client.
+func (g *Gateway) Client(id gate.ID) *Client {
+	c, _ := g.GetClient(id).(*Client)
+	return c
+}
+
+// Exited returns the ids of clients exited by ExitClient.
+func (g *Gateway) Exited() []gate.ID {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]gate.ID{}, g.exited...)
+}
+
+// Use adds the middleware run by Receive before the MessageHandler, in the order added.
+func (g *Gateway) Use(m gate.Middleware) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.middlewares = append(g.middlewares, m)
+}
+
+// Receive simulates the message received from the client of id, returns true if the message is handled by the
+// MessageHandler, false if it's dropped by the interceptors or middlewares.
+func (g *Gateway) Receive(id gate.ID, m *messages.GlideMessage) (bool, error) {
+	id.SetGateway(g.id)
+	g.mu.RLock()
+	c, ok := g.clients[id]
+	h := g.h
+	middlewares := g.middlewares
+	g.mu.RUnlock()
+	if !ok {
+		return false, gate.ErrClientNotExist
+	}
+	if fc, ok := c.(*Client); ok && fc.Intercept(m) {
+		return false, nil
+	}
+	for _, mw := range middlewares {
+		handled, err := mw(c, m)
+		if err != nil {
+			_ = c.EnqueueMessage(messages.NewErrorMessage(m.GetSeq(), messages.ActionNotifyError, err.Error()))
+			return false, nil
+		}
+		if handled {
+			return false, nil
+		}
+	}
+	if h != nil {
+		info := c.GetInfo()
+		h(&info, m)
+	}
+	return true, nil
+}
+
+func (g *Gateway) SetClientID(old gate.ID, new_ gate.ID) error {
+	old.SetGateway(g.id)
+	new_.SetGateway(g.id)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c, ok := g.clients[old]
+	if !ok {
+		return gate.ErrClientNotExist
+	}
+	if _, ok = g.clients[new_]; ok {
+		if old.Equals(new_) {
+			return gate.ErrAlreadyAuthenticated
+		}
+		return gate.ErrIDConflict
+	}
+	delete(g.clients, old)
+	c.SetID(new_)
+	g.clients[new_] = c
+	return nil
+}
+
+func (g *Gateway) UpdateClient(id gate.ID, info *gate.ClientSecrets) error {
+	id.SetGateway(g.id)
+	g.mu.RLock()
+	c, ok := g.clients[id]
+	g.mu.RUnlock()
+	if !ok {
+		return gate.ErrClientNotExist
+	}
+	if dc, ok := c.(gate.DefaultClient); ok {
+		cred := dc.GetCredentials()
+		if cred == nil {
+			cred = &gate.ClientAuthCredentials{}
+		}
+		cp := *cred
+		cp.Secrets = info
+		dc.SetCredentials(&cp)
+	}
+	return nil
+}
+
+func (g *Gateway) ExitClient(id gate.ID) error {
+	id.SetGateway(g.id)
+	g.mu.Lock()
+	c, ok := g.clients[id]
+	if ok {
+		delete(g.clients, id)
+		g.exited = append(g.exited, id)
+	}
+	g.mu.Unlock()
+	if !ok {
+		return gate.ErrClientNotExist
+	}
+	c.Exit()
+	return nil
+}
+
+func (g *Gateway) EnqueueMessage(id gate.ID, message *messages.GlideMessage) error {
+	id.SetGateway(g.id)
+	g.mu.RLock()
+	c, ok := g.clients[id]
+	g.mu.RUnlock()
+	if !ok {
+		return gate.ErrClientNotExist
+	}
+	if err := c.EnqueueMessage(message); err != nil {
+		return err
+	}
+	g.add(message)
+	return nil
+}
+
+func (g *Gateway) SetClientAttributes(id gate.ID, attributes map[string]string, replace bool) error {
+	id.SetGateway(g.id)
+	g.mu.RLock()
+	c, ok := g.clients[id]
+	g.mu.RUnlock()
+	if !ok {
+		return gate.ErrClientNotExist
+	}
+	fc, ok := c.(*Client)
+	if !ok {
+		return nil
+	}
+	fc.SetInfo(func(info *gate.Info) {
+		if replace || info.Attributes == nil {
+			info.Attributes = map[string]string{}
+		}
+		for k, v := range attributes {
+			if v == "" {
+				delete(info.Attributes, k)
+			} else {
+				info.Attributes[k] = v
+			}
+		}
+	})
+	return nil
+}
+
+func (g *Gateway) GetClient(id gate.ID) gate.Client {
+	id.SetGateway(g.id)
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.clients[id]
+}
+
+func (g *Gateway) GetAll() map[gate.ID]gate.Info {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	all := map[gate.ID]gate.Info{}
+	for id, c := range g.clients {
+		all[id] = c.GetInfo()
+	}
+	return all
+}
+
+func (g *Gateway) SetMessageHandler(h gate.MessageHandler) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.h = h
+}
+
+func (g *Gateway) AddClient(cs gate.Client) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.clients[cs.GetInfo().ID] = cs
+}
@@ -1,16 +1,25 @@
 package gate
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"github.com/glide-im/glide/pkg/conn"
 	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metering"
+	"github.com/glide-im/glide/pkg/metrics"
+	"github.com/glide-im/glide/pkg/ordered"
+	"github.com/glide-im/glide/pkg/tracing"
 	"github.com/panjf2000/ants/v2"
-	"log"
+	"go.opentelemetry.io/otel/attribute"
+	"net/http"
 	"sync"
 	"time"
 )
 
+var log = logger.Named("gate")
+
 // Gateway is the basic and common interface for all gate implementations.
 // As the basic gate, it is used to provide a common gate interface for other modules to interact with the gate.
 type Gateway interface {
@@ -66,13 +75,19 @@ type Options struct {
 }
 
 var _ DefaultGateway = (*Impl)(nil)
+var _ AttributeUpdater = (*Impl)(nil)
 
 type Impl struct {
 	id string
 
 	// clients is a map of all connected clients
 	clients map[ID]Client
-	mu      sync.RWMutex
+	// attributes of clients, the map of a client is replaced instead of modified, it's safe to share.
+	attributes map[ID]map[string]string
+	// uidCounts the count of clients of each uid.
+	uidCounts map[string]int
+	maxPerUID int
+	mu        sync.RWMutex
 
 	// msgHandler client message handler
 	msgHandler MessageHandler
@@ -81,23 +96,60 @@ type Impl struct {
 
 	// pool of ants, used to process messages concurrently.
 	pool *ants.Pool
+	// sendOrder enqueues messages to a client in goroutines of pool, in the order EnqueueMessage called.
+	sendOrder *ordered.Executor
+	// overflow the policy applied when messages waiting in sendOrder of a client are full, see SetOverflowPolicy.
+	overflow       OverflowPolicy
+	enqueueTimeout time.Duration
+	overflowMu     sync.RWMutex
+
+	// registry the cluster session registry, optional.
+	registry SessionRegistry
+	// resumer keeps sessions of clients disconnected to resume, nil if resume disabled.
+	resumer *sessionResumer
+
+	middlewares middlewareChain
+
+	// loginNotify notifies devices of the user when a device logs in, locator resolves the location of devices.
+	loginNotify bool
+	locator     Locator
+
+	// conflictResolver decides how duplicate id on authentication is handled, nil to kick out the existing client.
+	conflictResolver ConflictResolver
+
+	// events publishes client connection events.
+	events *EventBus
+
+	// remoteConfig the config pushed to clients, see SetRemoteConfig.
+	remoteConfig   *messages.RemoteConfig
+	remoteConfigMu sync.RWMutex
 }
 
 func NewServer(options *Options) (*Impl, error) {
 
 	ret := new(Impl)
 	ret.clients = map[ID]Client{}
+	ret.attributes = map[ID]map[string]string{}
+	ret.uidCounts = map[string]int{}
 	ret.mu = sync.RWMutex{}
 	ret.id = options.ID
+	ret.events = NewEventBus(0)
+	ret.enqueueTimeout = defaultEnqueueTimeout
+	ret.middlewares.onDrop = func(cli Client, m *messages.GlideMessage, err error) {
+		ret.events.Publish(&Event{Type: EventMessageDropped, Client: cli.GetInfo(), Message: m, Reason: err.Error()})
+	}
 
 	if options.SecretKey != "" {
 		ret.authenticator = NewAuthenticator(ret, options.SecretKey)
+		ret.authenticator.KeyRing().SetGateway(options.ID)
+		ret.UseWithPriority(PriorityAuthenticate, interceptorMiddleware(ret.authenticator.ClientAuthMessageInterceptor))
+		ret.UseWithPriority(PriorityTicket, interceptorMiddleware(ret.authenticator.MessageInterceptor))
 	}
 
 	pool, err := ants.NewPool(options.MaxMessageConcurrency,
 		ants.WithNonblocking(true),
 		ants.WithPanicHandler(func(i interface{}) {
-			log.Printf("panic: %v", i)
+			log.E("panic: %v", i)
 		}),
 		ants.WithPreAlloc(false),
 	)
@@ -105,6 +157,11 @@ func NewServer(options *Options) (*Impl, error) {
 		return nil, err
 	}
 	ret.pool = pool
+	ret.sendOrder = ordered.NewExecutor(pool.Submit, &ordered.Options{
+		PanicHandler: func(i interface{}) {
+			log.E("panic: %v", i)
+		},
+	})
 	return ret, nil
 }
 
@@ -122,11 +179,106 @@ func (c *Impl) GetAll() map[ID]Info {
 
 	result := map[ID]Info{}
 	for id, client := range c.clients {
-		result[id] = client.GetInfo()
+		info := client.GetInfo()
+		info.Attributes = c.attributes[id]
+		result[id] = info
 	}
 	return result
 }
 
+// ClientCount returns the count of clients in the gateway.
+func (c *Impl) ClientCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.clients)
+}
+
+// SetMaxConnectionsPerUID sets the max count of authenticated clients of a user, SetClientID fails if exceeded, 0 is
+// unlimited.
+func (c *Impl) SetMaxConnectionsPerUID(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxPerUID = n
+}
+
+func (c *Impl) countClient(id ID, delta int) {
+	uid := id.UID()
+	c.uidCounts[uid] += delta
+	if c.uidCounts[uid] <= 0 {
+		delete(c.uidCounts, uid)
+	}
+}
+
+// SetClientAttributes merges the attributes to the client, the attribute with empty value is removed, all attributes
+// are replaced if replace is true.
+func (c *Impl) SetClientAttributes(id ID, attributes map[string]string, replace bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id.SetGateway(c.id)
+	if _, ok := c.clients[id]; !ok {
+		return ErrClientNotExist
+	}
+	merged := map[string]string{}
+	if !replace {
+		for k, v := range c.attributes[id] {
+			merged[k] = v
+		}
+	}
+	for k, v := range attributes {
+		if v == "" {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+	if len(merged) == 0 {
+		delete(c.attributes, id)
+	} else {
+		c.attributes[id] = merged
+	}
+	return nil
+}
+
+// SetTicketReplayProtection enables the replay protected ticket of messages, see Authenticator.SetReplayProtection.
+// It does nothing if the gateway has no secret key.
+func (c *Impl) SetTicketReplayProtection(window time.Duration, requireSigned bool, nonces NonceStore) {
+	if c.authenticator != nil {
+		c.authenticator.SetReplayProtection(window, requireSigned, nonces)
+	}
+}
+
+// KeyRing returns the keys to decrypt client credentials, nil if the gateway has no secret key.
+func (c *Impl) KeyRing() *KeyRing {
+	if c.authenticator == nil {
+		return nil
+	}
+	return c.authenticator.KeyRing()
+}
+
+// SetSessionRegistry sets the session registry, client sessions will be recorded in the registry.
+func (c *Impl) SetSessionRegistry(r SessionRegistry) {
+	c.registry = r
+}
+
+func (c *Impl) registerSession(id ID) {
+	if c.registry == nil {
+		return
+	}
+	if err := c.registry.Register(id, c.id); err != nil {
+		log.E("[gateway] register session %s error: %v", id, err)
+	}
+}
+
+func (c *Impl) removeSession(id ID) {
+	if c.registry == nil {
+		return
+	}
+	if err := c.registry.Remove(id); err != nil {
+		log.E("[gateway] remove session %s error: %v", id, err)
+	}
+}
+
 func (c *Impl) SetMessageHandler(h MessageHandler) {
 	c.msgHandler = h
 }
@@ -139,15 +291,18 @@ func (c *Impl) UpdateClient(id ID, info *ClientSecrets) error {
 
 	cli, ok := c.clients[id]
 	if !ok || cli == nil {
-		return errors.New(errClientNotExist)
+		return ErrClientNotExist
 	}
 
 	dc, ok := cli.(DefaultClient)
 	if ok {
-		credentials := dc.GetCredentials()
+		credentials := ClientAuthCredentials{}
+		if c := dc.GetCredentials(); c != nil {
+			credentials = *c
+		}
 		credentials.Secrets = info
-		dc.SetCredentials(credentials)
-		logger.D("gateway", "update client %s, %v", id, info.MessageDeliverSecret)
+		dc.SetCredentials(&credentials)
+		log.D("update client %s, %v", id, info.MessageDeliverSecret)
 	}
 
 	return nil
@@ -166,13 +321,18 @@ func (c *Impl) AddClient(cs Client) {
 	}
 
 	c.clients[id] = cs
+	c.countClient(id, 1)
+	c.registerSession(id)
+	metrics.Connects.Inc()
+	metrics.Connections.Inc()
 	info := cs.GetInfo()
 	c.msgHandler(&info, messages.NewMessage(0, messages.ActionInternalOnline, id))
+	c.publishEvent(EventClientConnected, info, "")
 }
 
 // SetClientID replace the oldID with newID of the client.
-// If the oldID is not exist, return errClientNotExist.
-// If the newID is existed, return errClientAlreadyExist.
+// If the oldID is not exist, return ErrClientNotExist.
+// If the newID is existed, return ErrAlreadyAuthenticated if it's the oldID, otherwise ErrIDConflict.
 func (c *Impl) SetClientID(oldID, newID ID) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -182,12 +342,21 @@ func (c *Impl) SetClientID(oldID, newID ID) error {
 
 	cli, ok := c.clients[oldID]
 	if !ok || cli == nil {
-		return errors.New(errClientNotExist)
+		return ErrClientNotExist
 	}
 	cliLogged, exist := c.clients[newID]
 	if exist && cliLogged != nil {
-		return errors.New(errClientAlreadyExist)
+		if oldID.Equals(newID) {
+			return ErrAlreadyAuthenticated
+		}
+		return ErrIDConflict
+	}
+	// the session parked is replaced by the new login.
+	c.revokeSession(newID)
+	if err := c.checkUserConnections(oldID, newID); err != nil {
+		return err
 	}
+	c.revokeSession(oldID)
 
 	oldInfo := cli.GetInfo()
 	cli.SetID(newID)
@@ -197,11 +366,22 @@ func (c *Impl) SetClientID(oldID, newID ID) error {
 	c.msgHandler(&newInfo, messages.NewMessage(0, messages.ActionInternalOnline, newID))
 
 	c.clients[newID] = cli
+	c.countClient(oldID, -1)
+	c.countClient(newID, 1)
+	if attributes, ok := c.attributes[oldID]; ok {
+		delete(c.attributes, oldID)
+		c.attributes[newID] = attributes
+	}
+	c.removeSession(oldID)
+	c.registerSession(newID)
+	if !newID.IsTemp() {
+		c.events.Publish(&Event{Type: EventClientAuthenticated, Client: newInfo, PreviousID: oldID})
+	}
 	return nil
 }
 
 // ExitClient close the client with the specified id.
-// If the client is not exist, return errClientNotExist.
+// If the client is not exist, return ErrClientNotExist.
 func (c *Impl) ExitClient(id ID) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -210,64 +390,137 @@ func (c *Impl) ExitClient(id ID) error {
 
 	cli, ok := c.clients[id]
 	if !ok || cli == nil {
-		return errors.New(errClientNotExist)
+		return ErrClientNotExist
 	}
 
 	info := cli.GetInfo()
 	cli.SetID("")
 	delete(c.clients, id)
-	c.msgHandler(&info, messages.NewMessage(0, messages.ActionInternalOffline, id))
+	metrics.Disconnects.Inc()
+	metrics.Connections.Dec()
+	if !id.IsTemp() {
+		metering.Connection(id.UID(), time.Since(time.UnixMilli(info.ConnectionAt)))
+	}
+	// the client exited by itself is the connection lost, the session is kept online for resume.
+	if cli.IsRunning() || !c.parkClient(id, info) {
+		c.revokeSession(id)
+		c.clientOffline(id, info)
+	}
 	cli.Exit()
+	c.publishEvent(EventClientClosed, info, "")
 
 	return nil
 }
 
+// clientOffline removes the states of the client offline, must be called with lock.
+func (c *Impl) clientOffline(id ID, info Info) {
+	delete(c.attributes, id)
+	c.countClient(id, -1)
+	c.removeSession(id)
+	c.msgHandler(&info, messages.NewMessage(0, messages.ActionInternalOffline, id))
+}
+
 // EnqueueMessage to the client with the specified id.
 func (c *Impl) EnqueueMessage(id ID, msg *messages.GlideMessage) error {
 
+	_, span := tracing.Start(msg, "gate.enqueue", attribute.String("glide.uid", id.UID()))
+	defer span.End()
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	id.SetGateway(c.id)
 	cli, ok := c.clients[id]
 	if !ok || cli == nil {
-		return errors.New(errClientNotExist)
+		if c.resumer != nil && c.resumer.buffer(id, msg) {
+			return nil
+		}
+		return ErrClientNotExist
 	}
 
-	return c.enqueueMessage(cli, msg)
+	return c.enqueueMessage(id, cli, msg)
 }
 
 func (c *Impl) interceptClientMessage(dc DefaultClient, m *messages.GlideMessage) bool {
-
-	if m.Action == messages.ActionAuthenticate {
-		if c.authenticator != nil {
-			return c.authenticator.ClientAuthMessageInterceptor(dc, m)
-		}
-	}
-
-	return c.authenticator.MessageInterceptor(dc, m)
+	return c.middlewares.handle(dc, m)
 }
 
-func (c *Impl) enqueueMessage(cli Client, msg *messages.GlideMessage) error {
+// enqueueMessage enqueues the message to the client asynchronously, messages to a client are enqueued in the order
+// called, so the order of messages of a conversation handled in order is kept.
+func (c *Impl) enqueueMessage(id ID, cli Client, msg *messages.GlideMessage) error {
 	if !cli.IsRunning() {
+		metrics.EnqueueFailures.Inc()
+		c.events.Publish(&Event{Type: EventMessageDropped, Client: cli.GetInfo(), Message: msg, Reason: errClientClosed})
 		return errors.New(errClientClosed)
 	}
-	err := c.pool.Submit(func() {
-		_ = cli.EnqueueMessage(msg)
-	})
+	task := func() {
+		if err := cli.EnqueueMessage(msg); err != nil {
+			c.events.Publish(&Event{Type: EventMessageDropped, Client: cli.GetInfo(), Message: msg, Reason: err.Error()})
+		}
+	}
+	err := c.sendOrder.Submit(string(id), task)
+	if err == ordered.ErrQueueFull {
+		err = c.overflowMessage(id, cli, msg, task)
+	}
 	if err != nil {
-		return errors.New("enqueue message to client failed")
+		metrics.EnqueueFailures.Inc()
+		c.events.Publish(&Event{Type: EventMessageDropped, Client: cli.GetInfo(), Message: msg, Reason: errEnqueueFailed})
+		return errors.New(errEnqueueFailed)
 	}
 	return nil
 }
 
+// SetOverflowPolicy sets the policy applied when messages waiting to be enqueued to a client are full, it's the same
+// as the policy of the client send queue, timeout is the max duration to block for OverflowBlock, default 1s.
+func (c *Impl) SetOverflowPolicy(policy OverflowPolicy, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultEnqueueTimeout
+	}
+	c.overflowMu.Lock()
+	defer c.overflowMu.Unlock()
+	c.overflow = policy
+	c.enqueueTimeout = timeout
+}
+
+// overflowMessage applies the overflow policy to the message when messages waiting to be enqueued to the client are
+// full, such as the client send queue is blocked by OverflowBlock.
+func (c *Impl) overflowMessage(id ID, cli Client, msg *messages.GlideMessage, task func()) error {
+	c.overflowMu.RLock()
+	policy, timeout := c.overflow, c.enqueueTimeout
+	c.overflowMu.RUnlock()
+
+	metrics.QueueOverflows.WithLabelValues(policy.String()).Inc()
+	c.events.Publish(&Event{Type: EventQueueOverflow, Client: cli.GetInfo(), Message: msg, Reason: policy.String()})
+	switch policy {
+	case OverflowDropOldest:
+		return c.sendOrder.SubmitEvict(string(id), task)
+	case OverflowBlock:
+		return c.sendOrder.SubmitWait(string(id), task, timeout)
+	case OverflowDisconnect:
+		log.W("messages waiting to enqueue are full, disconnect slow client, id=%v", id)
+		go func() { _ = c.ExitClient(id) }()
+	}
+	return ordered.ErrQueueFull
+}
+
 type WebsocketGatewayServer struct {
 	gateId    string
 	addr      string
 	port      int
 	server    conn.Server
-	decorator DefaultGateway
+	wsOptions *conn.WsServerOptions
+	decorator *Impl
 	h         MessageHandler
+
+	cfgMu        sync.RWMutex
+	clientConfig ClientConfig
+	certResolver CertResolver
+	admission    *Admission
+	challenge    *ChallengeGuard
+	quota        *Quota
+	connWrapper  func(c conn.Connection) conn.Connection
+	upgrade      *conn.UpgradePolicy
+	poll         *conn.PollServer
 }
 
 func NewWebsocketServer(gateId string, addr string, port int, secretKey string) *WebsocketGatewayServer {
@@ -282,11 +535,21 @@ func NewWebsocketServer(gateId string, addr string, port int, secretKey string)
 	srv.addr = addr
 	srv.port = port
 	srv.gateId = gateId
-	options := &conn.WsServerOptions{
-		ReadTimeout:  time.Minute * 3,
-		WriteTimeout: time.Minute * 3,
+	srv.clientConfig = ClientConfig{
+		HeartbeatLostLimit:      3,
+		ClientHeartbeatDuration: time.Second * 30,
+		ServerHeartbeatDuration: time.Second * 30,
+		CloseImmediately:        false,
+	}
+	srv.wsOptions = &conn.WsServerOptions{
+		ReadTimeout:    time.Minute * 3,
+		WriteTimeout:   time.Minute * 3,
+		MaxMessageSize: defaultMaxMessageSize,
 	}
-	srv.server = conn.NewWsServer(options)
+	srv.clientConfig.MaxMessageSize = defaultMaxMessageSize
+	srv.clientConfig.Events = srv.decorator.Events()
+	srv.clientConfig.Shaper = NewEgressShaper()
+	srv.server = conn.NewWsServer(srv.wsOptions)
 	return &srv
 }
 
@@ -296,18 +559,36 @@ func (w *WebsocketGatewayServer) SetMessageHandler(h MessageHandler) {
 }
 
 func (w *WebsocketGatewayServer) HandleConnection(c conn.Connection) ID {
+	if w.admission != nil {
+		ip := c.GetConnInfo().Ip
+		if ok, reason := w.admission.Admit(ip); !ok {
+			metrics.ConnectionsRejected.WithLabelValues(reason).Inc()
+			log.I("[gateway] connection from %s rejected: %s", ip, reason)
+			_ = c.Close()
+			return ""
+		}
+	}
+	if w.quota != nil {
+		if reason, busy := w.quota.admit(w.decorator.ClientCount()); busy != nil {
+			metrics.ConnectionsRejected.WithLabelValues(reason).Inc()
+			log.I("[gateway] connection from %s rejected: %s", c.GetConnInfo().Ip, reason)
+			if b, err := messages.Encode(codecOf(c), messages.NewMessage(0, messages.ActionNotifyServerBusy, busy)); err == nil {
+				_ = c.Write(b)
+			}
+			_ = c.Close()
+			return ""
+		}
+	}
 	// 获取一个临时 uid 标识这个连接
 	id, err := GenTempID(w.gateId)
 	if err != nil {
-		logger.E("[gateway] gen temp id error: %v", err)
+		log.E("[gateway] gen temp id error: %v", err)
 		return ""
 	}
-	ret := NewClientWithConfig(c, w, w.h, &ClientConfig{
-		HeartbeatLostLimit:      3,
-		ClientHeartbeatDuration: time.Second * 30,
-		ServerHeartbeatDuration: time.Second * 30,
-		CloseImmediately:        false,
-	})
+	w.cfgMu.RLock()
+	config := w.clientConfig
+	w.cfgMu.RUnlock()
+	ret := NewClientWithConfig(c, w, w.h, &config)
 	ret.SetID(id)
 	w.decorator.AddClient(ret)
 
@@ -316,19 +597,276 @@ func (w *WebsocketGatewayServer) HandleConnection(c conn.Connection) ID {
 
 	hello := messages.ServerHello{
 		TempID:            id.UID(),
-		HeartbeatInterval: 30,
+		HeartbeatInterval: int(config.ClientHeartbeatDuration / time.Second),
+		Protocol:          messages.ProtocolCurrent,
+		Features:          messages.Features(messages.ProtocolCurrent),
 	}
 
 	m := messages.NewMessage(0, messages.ActionHello, hello)
 	_ = ret.EnqueueMessage(m)
+	if w.challenge != nil {
+		w.challenge.Issue(ret)
+	}
 
+	if certs := c.GetConnInfo().PeerCertificates; w.certResolver != nil && len(certs) > 0 {
+		return w.authenticateByCert(id, certs[0])
+	}
 	return id
 }
 
+// authenticateByCert logs in the client by the client certificate, returns the temp id if failed.
+func (w *WebsocketGatewayServer) authenticateByCert(tempID ID, cert *x509.Certificate) ID {
+	uid, err := w.certResolver(cert)
+	if err != nil {
+		auditAuthFailure(tempID, "resolve client certificate: "+err.Error())
+		log.W("[gateway] resolve client certificate %s error: %v", cert.Subject.CommonName, err)
+		return tempID
+	}
+	var device, kicked *messages.DeviceInfo
+	if cli := w.decorator.GetClient(tempID); cli != nil {
+		device, kicked = w.decorator.loginDevices(cli, uid)
+	}
+	id, err := bindClientID(w, tempID, uid, &messages.KickOutNotify{Device: device})
+	if err != nil {
+		auditAuthFailure(tempID, err.Error())
+		log.E("[gateway] authenticate client %s by certificate error: %v", uid, err)
+		return tempID
+	}
+	w.decorator.notifyLogin(id, device, kicked)
+	_ = w.EnqueueMessage(id, messages.NewMessage(0, messages.ActionNotifySuccess, nil))
+	return id
+}
+
+// UseTLS serves connections over TLS, mutual TLS is enabled if the config has ClientCAs, see conn.NewTLSConfig.
+// It's not supported by the netpoll server.
+func (w *WebsocketGatewayServer) UseTLS(cfg *tls.Config) error {
+	s, ok := w.server.(interface{ SetTLSConfig(cfg *tls.Config) })
+	if !ok {
+		return errors.New("tls is not supported by the connection server")
+	}
+	s.SetTLSConfig(cfg)
+	return nil
+}
+
+// SetCertResolver sets the resolver to authenticate clients by the verified client certificate of mutual TLS, the
+// client is logged in with the resolved uid once connected.
+func (w *WebsocketGatewayServer) SetCertResolver(r CertResolver) {
+	w.certResolver = r
+}
+
+// SetTicketReplayProtection enables the replay protected ticket of messages, see Authenticator.SetReplayProtection.
+func (w *WebsocketGatewayServer) SetTicketReplayProtection(window time.Duration, requireSigned bool, nonces NonceStore) {
+	w.decorator.SetTicketReplayProtection(window, requireSigned, nonces)
+}
+
+// SetSignVerification enables the verification of message sign, see Impl.SetSignVerification.
+func (w *WebsocketGatewayServer) SetSignVerification(opts *SignVerifierOptions) {
+	w.decorator.SetSignVerification(opts)
+}
+
+// KeyRing returns the keys to decrypt client credentials, nil if the gateway has no secret key.
+func (w *WebsocketGatewayServer) KeyRing() *KeyRing {
+	return w.decorator.KeyRing()
+}
+
+// SetAdmission sets the admission to reject connections by remote ip before handled.
+func (w *WebsocketGatewayServer) SetAdmission(a *Admission) {
+	w.admission = a
+}
+
+// SetChallenger requires clients to solve the challenge issued by challenger before authenticate, the challenge
+// expires after ttl. It must be called before Run.
+func (w *WebsocketGatewayServer) SetChallenger(challenger Challenger, ttl time.Duration) {
+	w.challenge = NewChallengeGuard(challenger, ttl)
+	w.UseWithPriority(PriorityChallenge, w.challenge.Middleware)
+}
+
+// SetAnomalyDetector scores clients connected and authenticated by the detector, the suspicious clients are stepped up
+// by the challenge or disconnected, see AnomalyGuard. It must be called after SetChallenger, otherwise the step up
+// verdict is ignored.
+func (w *WebsocketGatewayServer) SetAnomalyDetector(d AnomalyDetector) {
+	NewAnomalyGuard(d, w.decorator, w.challenge).Subscribe(w.Events())
+}
+
+// EnableResume enables session resume of clients, see Impl.EnableResume. It must be called before Run.
+func (w *WebsocketGatewayServer) EnableResume(opts *ResumeOptions) {
+	w.decorator.EnableResume(opts)
+}
+
+// SetUpgradePolicy validates websocket upgrade requests by origin and subprotocol, rejected requests are counted in
+// metrics.ConnectionsRejected. It must be called before Run.
+func (w *WebsocketGatewayServer) SetUpgradePolicy(p *conn.UpgradePolicy) {
+	if p != nil && p.OnReject == nil {
+		p.OnReject = func(r *http.Request, reason string) {
+			metrics.ConnectionsRejected.WithLabelValues(reason).Inc()
+			log.I("[gateway] upgrade request from %s rejected: %s, origin=%s", r.RemoteAddr, reason, r.Header.Get("Origin"))
+		}
+	}
+	w.upgrade = p
+}
+
+// UseLongPolling serves clients behind proxies hostile to websocket by HTTP long-polling or server-sent events on the
+// port of websocket, see conn.PollPath. Clients reconnected are routed by the resume token as websocket clients, so
+// sticky sessions of the load balancer are not required. It must be called before Run.
+func (w *WebsocketGatewayServer) UseLongPolling(opts *conn.PollServerOptions) {
+	w.poll = conn.NewPollServer(opts)
+}
+
+// UseNetpoll serves connections by the epoll based server instead of a goroutine per connection, it must be called
+// before Run, only supported on linux.
+func (w *WebsocketGatewayServer) UseNetpoll(opts *conn.NetpollServerOptions) error {
+	server, err := conn.NewNetpollServer(opts)
+	if err != nil {
+		return err
+	}
+	w.server = server
+	return nil
+}
+
+// SetSendQueue sets the send queue of clients connected after, size and timeout less than or equal to 0 use defaults.
+func (w *WebsocketGatewayServer) SetSendQueue(size int, policy OverflowPolicy, timeout time.Duration) {
+	w.cfgMu.Lock()
+	defer w.cfgMu.Unlock()
+	w.clientConfig.SendQueueSize = size
+	w.clientConfig.OverflowPolicy = policy
+	w.clientConfig.EnqueueTimeout = timeout
+	w.decorator.SetOverflowPolicy(policy, timeout)
+}
+
+// SetMessageSize sets the max size of a message read from connection and the max size of a message sent in chunks,
+// less than or equal to 0 use defaults, it must be called before Run. The max message size of netpoll server is set by
+// conn.NetpollServerOptions.
+func (w *WebsocketGatewayServer) SetMessageSize(maxSize int64, maxAssembledSize int64) {
+	if maxSize <= 0 {
+		maxSize = defaultMaxMessageSize
+	}
+	w.cfgMu.Lock()
+	defer w.cfgMu.Unlock()
+	w.wsOptions.MaxMessageSize = maxSize
+	w.clientConfig.MaxMessageSize = maxSize
+	w.clientConfig.MaxAssembledSize = maxAssembledSize
+}
+
+// SetRejectUnknownAction sets whether to reject the messages of actions not registered, it applies to new clients.
+func (w *WebsocketGatewayServer) SetRejectUnknownAction(reject bool) {
+	w.cfgMu.Lock()
+	defer w.cfgMu.Unlock()
+	w.clientConfig.RejectUnknownAction = reject
+}
+
+// SetQuota sets the limits of connections and outbound bandwidth, nil to remove limits.
+func (w *WebsocketGatewayServer) SetQuota(q *Quota) {
+	w.cfgMu.Lock()
+	defer w.cfgMu.Unlock()
+	w.quota = q
+	w.clientConfig.Outbound = nil
+	maxPerUID := 0
+	if q != nil {
+		w.clientConfig.Outbound = q.Outbound()
+		maxPerUID = q.opts.MaxConnectionsPerUID
+	}
+	w.decorator.SetMaxConnectionsPerUID(maxPerUID)
+}
+
+// SetEgressRates sets the egress bytes per second of clients by device type, ShapeAllDevices for devices not listed,
+// 0 is uncapped, it applies to connected clients, see EgressShaper.
+func (w *WebsocketGatewayServer) SetEgressRates(rates map[string]int64) error {
+	return w.clientConfig.Shaper.SetRates(rates)
+}
+
+// EgressRates returns the egress bytes per second of clients by device type.
+func (w *WebsocketGatewayServer) EgressRates() map[string]int64 {
+	return w.clientConfig.Shaper.Rates()
+}
+
+// SetHeartbeat sets the client and server heartbeat interval of clients connected after, less than or equal to 0 is
+// ignored.
+func (w *WebsocketGatewayServer) SetHeartbeat(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	w.cfgMu.Lock()
+	defer w.cfgMu.Unlock()
+	w.clientConfig.ClientHeartbeatDuration = interval
+	w.clientConfig.ServerHeartbeatDuration = interval
+}
+
+// SetConnWrapper sets the function wraps connections accepted before handled, such as injecting faults by chaos
+// tests, nil to not wrap.
+func (w *WebsocketGatewayServer) SetConnWrapper(wrap func(c conn.Connection) conn.Connection) {
+	w.cfgMu.Lock()
+	defer w.cfgMu.Unlock()
+	w.connWrapper = wrap
+}
+
+// UpdateClientTicket sets the message deliver secret of clients of the uid, see Impl.UpdateClientTicket.
+func (w *WebsocketGatewayServer) UpdateClientTicket(uid string, device string, secret string) (int, error) {
+	return w.decorator.UpdateClientTicket(uid, device, secret)
+}
+
+// SetTicketProvider sets the provider of message deliver secret, see Impl.SetTicketProvider.
+func (w *WebsocketGatewayServer) SetTicketProvider(p TicketProvider) {
+	w.decorator.SetTicketProvider(p)
+}
+
+// Events returns the bus of client connection events of the gateway, see EventBus.
+func (w *WebsocketGatewayServer) Events() *EventBus {
+	return w.decorator.Events()
+}
+
+// SetRemoteConfig sets the config pushed to clients, see Impl.SetRemoteConfig.
+func (w *WebsocketGatewayServer) SetRemoteConfig(cfg *messages.RemoteConfig) {
+	w.decorator.SetRemoteConfig(cfg)
+}
+
+// RemoteConfig returns the config pushed to clients, see Impl.RemoteConfig.
+func (w *WebsocketGatewayServer) RemoteConfig() *messages.RemoteConfig {
+	return w.decorator.RemoteConfig()
+}
+
+// SetConflictResolver sets the resolver of duplicate id on authentication, see Impl.SetConflictResolver.
+func (w *WebsocketGatewayServer) SetConflictResolver(r ConflictResolver) {
+	w.decorator.SetConflictResolver(r)
+}
+
+// SetLoginNotify enables notifying devices of the user when a device logs in, see Impl.SetLoginNotify.
+func (w *WebsocketGatewayServer) SetLoginNotify(enable bool, locator Locator) {
+	w.decorator.SetLoginNotify(enable, locator)
+}
+
+// Use adds the middleware with PriorityDefault to the gateway.
+func (w *WebsocketGatewayServer) Use(m Middleware) {
+	w.decorator.Use(m)
+}
+
+// UseWithPriority adds the middleware with the priority to the gateway, lower priority runs first.
+func (w *WebsocketGatewayServer) UseWithPriority(priority int, m Middleware) {
+	w.decorator.UseWithPriority(priority, m)
+}
+
 func (w *WebsocketGatewayServer) Run() error {
-	w.server.SetConnHandler(func(conn conn.Connection) {
-		w.HandleConnection(conn)
-	})
+	if w.upgrade != nil {
+		s, ok := w.server.(interface{ SetUpgradePolicy(p *conn.UpgradePolicy) })
+		if !ok {
+			return errors.New("upgrade policy is not supported by the connection server")
+		}
+		s.SetUpgradePolicy(w.upgrade)
+	}
+	handler := func(c conn.Connection) {
+		w.cfgMu.RLock()
+		wrap := w.connWrapper
+		w.cfgMu.RUnlock()
+		if wrap != nil {
+			c = wrap(c)
+		}
+		w.HandleConnection(c)
+	}
+	if w.poll != nil {
+		w.poll.SetConnHandler(handler)
+		w.poll.SetUpgradePolicy(w.upgrade)
+		http.Handle(conn.PollPath+"/", w.poll)
+	}
+	w.server.SetConnHandler(handler)
 	return w.server.Run(w.addr, w.port)
 }
 
@@ -348,6 +886,10 @@ func (w *WebsocketGatewayServer) SetClientID(old ID, new_ ID) error {
 	return w.decorator.SetClientID(old, new_)
 }
 
+func (w *WebsocketGatewayServer) SetClientAttributes(id ID, attributes map[string]string, replace bool) error {
+	return w.decorator.SetClientAttributes(id, attributes, replace)
+}
+
 func (w *WebsocketGatewayServer) UpdateClient(id ID, info *ClientSecrets) error {
 	return w.decorator.UpdateClient(id, info)
 }
@@ -0,0 +1,99 @@
+package gate
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingClient struct {
+	mockClient
+	mu  sync.Mutex
+	got []int64
+}
+
+func (r *recordingClient) EnqueueMessage(message *messages.GlideMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got = append(r.got, message.GetSeq())
+	return nil
+}
+
+func TestImpl_EnqueueMessage_Order(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 100})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+	c := &recordingClient{mockClient: mockClient{info: Info{ID: NewID2("1")}, running: true}}
+	gateway.AddClient(c)
+
+	const n = 1000
+	for i := int64(0); i < n; i++ {
+		assert.NoError(t, gateway.EnqueueMessage(NewID2("1"), messages.NewMessage(i, messages.ActionChatMessage, nil)))
+	}
+	assert.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.got) == n
+	}, time.Second*5, time.Millisecond*10)
+	for i, seq := range c.got {
+		assert.Equal(t, int64(i), seq)
+	}
+}
+
+func TestImpl_EnqueueMessages(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 100})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+	c1 := &recordingClient{mockClient: mockClient{info: Info{ID: NewID2("1")}, running: true}}
+	c2 := &recordingClient{mockClient: mockClient{info: Info{ID: NewID2("2")}, running: true}}
+	gateway.AddClient(c1)
+	gateway.AddClient(c2)
+
+	n, err := gateway.EnqueueMessages([]ID{NewID2("1"), NewID2("2"), NewID2("3")}, messages.NewMessage(7, messages.ActionChatMessage, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	for _, c := range []*recordingClient{c1, c2} {
+		c := c
+		assert.Eventually(t, func() bool {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return len(c.got) == 1 && c.got[0] == 7
+		}, time.Second, time.Millisecond*10)
+	}
+}
+
+type chanConnection struct {
+	recordConnection
+	written chan []byte
+}
+
+func (c *chanConnection) Write(data []byte) error {
+	c.written <- data
+	return nil
+}
+
+func TestWebsocketGatewayServer_HelloHeartbeat(t *testing.T) {
+	w := NewWebsocketServer("gw", "", 0, "")
+	w.SetMessageHandler(func(cliInfo *Info, message *messages.GlideMessage) {})
+	w.SetHeartbeat(time.Second * 45)
+
+	c := &chanConnection{written: make(chan []byte, 10)}
+	id := w.HandleConnection(c)
+	assert.NotEmpty(t, id)
+	defer func() { _ = w.decorator.ExitClient(id) }()
+
+	select {
+	case b := <-c.written:
+		m := messages.NewEmptyMessage()
+		assert.NoError(t, messages.JsonCodec.Decode(b, m))
+		assert.Equal(t, messages.ActionHello, m.GetAction())
+		hello := &messages.ServerHello{}
+		assert.NoError(t, m.Data.Deserialize(hello))
+		assert.Equal(t, 45, hello.HeartbeatInterval)
+	case <-time.After(time.Second * 3):
+		t.Fatal("server hello not sent")
+	}
+}
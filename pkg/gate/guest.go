@@ -0,0 +1,33 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+// guestUpgrader the gateway upgrades the guest to the user authenticated, implemented by Impl.
+type guestUpgrader interface {
+	upgradeGuest(id ID, guest string)
+}
+
+var _ guestUpgrader = (*Impl)(nil)
+
+// upgradeGuest passes the internal.upgrade message to the message handler, the guest may be the temp uid of the
+// client of id before authenticated, or of another connection such as the page reloaded after signed in.
+func (c *Impl) upgradeGuest(id ID, guest string) {
+	if !IsTempUID(guest) || id.IsTemp() {
+		return
+	}
+	c.mu.RLock()
+	id.SetGateway(c.id)
+	cli, ok := c.clients[id]
+	h := c.msgHandler
+	c.mu.RUnlock()
+	if !ok || h == nil {
+		return
+	}
+	info := cli.GetInfo()
+	h(&info, messages.NewMessage(0, messages.ActionInternalUpgrade, &messages.GuestUpgrade{
+		GuestID: guest,
+		Uid:     id.UID(),
+	}))
+}
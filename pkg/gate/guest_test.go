@@ -0,0 +1,41 @@
+package gate
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticator_UpgradeGuest(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	mu := sync.Mutex{}
+	var upgrades []*messages.GuestUpgrade
+	gateway.SetMessageHandler(func(cliInfo *Info, message *messages.GlideMessage) {
+		if message.GetAction() == messages.ActionInternalUpgrade {
+			u := new(messages.GuestUpgrade)
+			assert.NoError(t, message.Data.Deserialize(u))
+			mu.Lock()
+			upgrades = append(upgrades, u)
+			mu.Unlock()
+		}
+	})
+	auth := NewAuthenticator(gateway, "secret")
+
+	tempID, _ := GenTempID("g1")
+	guest := &credClient{mockClient: mockClient{info: Info{ID: tempID}, running: true}}
+	gateway.AddClient(guest)
+	_, err = auth.updateClient(guest, &ClientAuthCredentials{UserID: "1", GuestID: tempID.UID()})
+	assert.NoError(t, err)
+
+	other := &credClient{mockClient: mockClient{info: Info{ID: NewID("g1", "", "other")}, running: true}}
+	gateway.AddClient(other)
+	_, err = auth.updateClient(other, &ClientAuthCredentials{UserID: "2", GuestID: "3"})
+	assert.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []*messages.GuestUpgrade{{GuestID: tempID.UID(), Uid: "1"}}, upgrades)
+}
@@ -0,0 +1,221 @@
+package gate
+
+import (
+	"crypto/sha512"
+	"errors"
+	"github.com/glide-im/glide/pkg/audit"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultKeyID the key id of the secret key of gateway Options.
+const DefaultKeyID = "default"
+
+const (
+	errKeyNotExist     = "key does not exist"
+	errKeyIDEmpty      = "key id is empty"
+	errRetirePrimary   = "primary key cannot be retired"
+	errKeyAlreadyExist = "key already exist"
+	errLegacyRejected  = "legacy credentials are rejected"
+)
+
+// CredentialKeyInfo the information of a key in KeyRing, the secret is not included.
+type CredentialKeyInfo struct {
+	ID      string `json:"id"`
+	Primary bool   `json:"primary"`
+	AddedAt int64  `json:"added_at"`
+}
+
+type credentialKey struct {
+	info CredentialKeyInfo
+	// crypto the AES-GCM crypto of the key, legacy the AES-CBC crypto to decrypt credentials issued before.
+	crypto AesGCMCrypto
+	legacy CredentialCrypto
+}
+
+// KeyRing holds keys to decrypt client credentials by key id, keys can be added and retired at runtime to rotate the
+// key without disconnecting clients. Credentials are encrypted by the primary key with AesGCMCrypto, the legacy
+// AesCBCCrypto credentials are accepted unless rejected by SetRejectLegacy.
+type KeyRing struct {
+	mu      sync.RWMutex
+	keys    map[string]*credentialKey
+	primary string
+	// gateway the id of gateway credentials are bound to.
+	gateway      string
+	rejectLegacy bool
+}
+
+// NewKeyRing creates the KeyRing with the primary key.
+func NewKeyRing(kid string, secret string) *KeyRing {
+	k := &KeyRing{keys: map[string]*credentialKey{}}
+	_ = k.add(kid, secret, true)
+	return k
+}
+
+// Add adds the key with id, the key secret is hashed as the gateway secret key. The primary key is used to encrypt
+// credentials and tried first when decrypting credentials without key id.
+func (k *KeyRing) Add(kid string, secret string, primary bool) error {
+	err := k.add(kid, secret, primary)
+	if err == nil {
+		audit.Record(audit.EventKeyRotation, "", kid, map[string]string{"op": "add", "primary": strconv.FormatBool(primary)})
+	}
+	return err
+}
+
+func (k *KeyRing) add(kid string, secret string, primary bool) error {
+	if kid == "" {
+		return errors.New(errKeyIDEmpty)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[kid]; ok {
+		return errors.New(errKeyAlreadyExist)
+	}
+	crypto, err := NewAesGCMCrypto(secret, "")
+	if err != nil {
+		return err
+	}
+	k.keys[kid] = &credentialKey{
+		info:   CredentialKeyInfo{ID: kid, AddedAt: time.Now().Unix()},
+		crypto: *crypto,
+		legacy: NewAesCBCCrypto(sha512.New().Sum([]byte(secret))),
+	}
+	if primary || k.primary == "" {
+		k.primary = kid
+	}
+	return nil
+}
+
+// SetGateway binds credentials encrypted to the gateway, credentials bound to other gateways are rejected.
+func (k *KeyRing) SetGateway(id string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.gateway = id
+}
+
+// SetRejectLegacy rejects the legacy AES-CBC credentials, it's set when all credential issuers are upgraded.
+func (k *KeyRing) SetRejectLegacy(reject bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.rejectLegacy = reject
+}
+
+// cryptoOf returns the crypto to decrypt the credential by the key, must be called with lock.
+func (k *KeyRing) cryptoOf(key *credentialKey, credential string) CredentialCrypto {
+	if strings.HasPrefix(credential, credentialV2Prefix) {
+		crypto := key.crypto
+		crypto.Gateway = k.gateway
+		return &crypto
+	}
+	if k.rejectLegacy {
+		return nil
+	}
+	return key.legacy
+}
+
+// SetPrimary sets the key used to encrypt credentials.
+func (k *KeyRing) SetPrimary(kid string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[kid]; !ok {
+		return errors.New(errKeyNotExist)
+	}
+	k.primary = kid
+	audit.Record(audit.EventKeyRotation, "", kid, map[string]string{"op": "primary"})
+	return nil
+}
+
+// Retire removes the key, credentials encrypted by it are rejected after, the primary key cannot be retired.
+func (k *KeyRing) Retire(kid string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[kid]; !ok {
+		return errors.New(errKeyNotExist)
+	}
+	if kid == k.primary {
+		return errors.New(errRetirePrimary)
+	}
+	delete(k.keys, kid)
+	audit.Record(audit.EventKeyRotation, "", kid, map[string]string{"op": "retire"})
+	return nil
+}
+
+// Keys returns information of all keys ordered by id.
+func (k *KeyRing) Keys() []CredentialKeyInfo {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var ret []CredentialKeyInfo
+	for id, key := range k.keys {
+		info := key.info
+		info.Primary = id == k.primary
+		ret = append(ret, info)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ID < ret[j].ID
+	})
+	return ret
+}
+
+// Encrypt encrypts the credentials by the primary key, returns the encrypted credential with key id.
+func (k *KeyRing) Encrypt(c *ClientAuthCredentials) (*EncryptedCredential, error) {
+	k.mu.RLock()
+	kid := k.primary
+	crypto := k.keys[kid].crypto
+	crypto.Gateway = k.gateway
+	k.mu.RUnlock()
+
+	b, err := crypto.EncryptCredentials(c)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedCredential{Kid: kid, Credential: string(b)}, nil
+}
+
+// Decrypt decrypts the credential by the key of Kid, or the key id of Version if Kid is empty. Credentials without
+// known key id are tried with all keys, the primary key first. The legacy credential is rejected if SetRejectLegacy.
+func (k *KeyRing) Decrypt(c *EncryptedCredential) (*ClientAuthCredentials, error) {
+	k.mu.RLock()
+	kid := c.Kid
+	if kid == "" {
+		if _, ok := k.keys[strconv.Itoa(c.Version)]; ok {
+			kid = strconv.Itoa(c.Version)
+		}
+	}
+	var candidates []CredentialCrypto
+	if kid != "" {
+		key, ok := k.keys[kid]
+		if !ok {
+			k.mu.RUnlock()
+			return nil, errors.New(errKeyNotExist)
+		}
+		candidates = append(candidates, k.cryptoOf(key, c.Credential))
+	} else {
+		candidates = append(candidates, k.cryptoOf(k.keys[k.primary], c.Credential))
+		for id, key := range k.keys {
+			if id != k.primary {
+				candidates = append(candidates, k.cryptoOf(key, c.Credential))
+			}
+		}
+	}
+	k.mu.RUnlock()
+	if candidates[0] == nil {
+		return nil, errors.New(errLegacyRejected)
+	}
+
+	var err error
+	for _, crypto := range candidates {
+		var credentials *ClientAuthCredentials
+		credentials, err = crypto.DecryptCredentials([]byte(c.Credential))
+		if err == nil {
+			return credentials, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,69 @@
+package gate
+
+import (
+	"crypto/sha512"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyRing_Rotate(t *testing.T) {
+	ring := NewKeyRing(DefaultKeyID, "old")
+	credentials := &ClientAuthCredentials{UserID: "1", Timestamp: time.Now().UnixMilli()}
+
+	old, err := ring.Encrypt(credentials)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultKeyID, old.Kid)
+
+	assert.NoError(t, ring.Add("k2", "new", true))
+	assert.Error(t, ring.Add("k2", "new", false))
+	assert.Error(t, ring.Retire("k2"))
+
+	rotated, err := ring.Encrypt(credentials)
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", rotated.Kid)
+
+	// credential without key id is tried with all keys
+	for _, c := range []*EncryptedCredential{old, rotated, {Credential: old.Credential}} {
+		decrypted, err := ring.Decrypt(c)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", decrypted.UserID)
+	}
+
+	assert.NoError(t, ring.Retire(DefaultKeyID))
+	_, err = ring.Decrypt(old)
+	assert.Error(t, err)
+	assert.Len(t, ring.Keys(), 1)
+}
+
+func TestKeyRing_GCM(t *testing.T) {
+	ring := NewKeyRing(DefaultKeyID, "secret")
+	ring.SetGateway("g1")
+	credentials := &ClientAuthCredentials{UserID: "1", Timestamp: time.Now().UnixMilli()}
+
+	c, err := ring.Encrypt(credentials)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(c.Credential, credentialV2Prefix))
+	decrypted, err := ring.Decrypt(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", decrypted.UserID)
+
+	// the credential is bound to the gateway
+	other := NewKeyRing(DefaultKeyID, "secret")
+	other.SetGateway("g2")
+	_, err = other.Decrypt(c)
+	assert.Error(t, err)
+
+	// the legacy credential is accepted until rejected
+	legacy, err := NewAesCBCCrypto(sha512.New().Sum([]byte("secret"))).EncryptCredentials(credentials)
+	assert.NoError(t, err)
+	decrypted, err = ring.Decrypt(&EncryptedCredential{Credential: string(legacy)})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", decrypted.UserID)
+	ring.SetRejectLegacy(true)
+	_, err = ring.Decrypt(&EncryptedCredential{Credential: string(legacy)})
+	assert.EqualError(t, err, errLegacyRejected)
+	_, err = ring.Decrypt(c)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,40 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/i18n"
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+// setLocale sets the locale of the client from the credentials or the hello message, the latest one wins.
+func (c *UserClient) setLocale(locale string) {
+	if locale == "" {
+		return
+	}
+	c.locale.Store(locale)
+	c.info.Locale = locale
+}
+
+// localize returns the copy of the error message with the text translated to the locale of the client and the code of
+// the text in the extra, the message is returned as is if it's not an error or the text is not defined, see
+// messages.IsErrorAction.
+func (c *UserClient) localize(m *messages.GlideMessage) *messages.GlideMessage {
+	e, ok := messages.ErrorOf(m)
+	if !ok {
+		return m
+	}
+	locale, _ := c.locale.Load().(string)
+	code, localized := i18n.Localize(locale, e.Message)
+	if code == "" {
+		return m
+	}
+	le := *e
+	le.Message = localized
+	l := messages.CopyMessage(m)
+	l.Data = messages.NewData(&le)
+	l.Extra = make(map[string]string, len(m.Extra)+1)
+	for k, v := range m.Extra {
+		l.Extra[k] = v
+	}
+	l.Extra[i18n.ExtraKeyCode] = code
+	return l
+}
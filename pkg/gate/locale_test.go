@@ -0,0 +1,40 @@
+package gate
+
+import (
+	"testing"
+
+	"github.com/glide-im/glide/pkg/i18n"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserClient_Localize(t *testing.T) {
+	c := &UserClient{info: &Info{}}
+
+	m := messages.NewErrorMessage(1, messages.ActionNotifyError, errInvalidAuthMessage)
+	l := c.localize(m)
+	assert.Equal(t, "auth.invalid_message", l.Extra[i18n.ExtraKeyCode])
+	assert.Nil(t, m.Extra)
+
+	c.setLocale("zh-CN")
+	assert.Equal(t, "zh-CN", c.info.Locale)
+	l = c.localize(m)
+	e, ok := messages.ErrorOf(l)
+	assert.True(t, ok)
+	assert.Equal(t, "无效的认证消息", e.Message)
+	assert.Equal(t, messages.ErrCodeInvalidCredentials.Code, e.Code)
+	assert.Equal(t, "auth.invalid_message", l.Extra[i18n.ExtraKeyCode])
+	// the shared message is not modified.
+	e, _ = messages.ErrorOf(m)
+	assert.Equal(t, errInvalidAuthMessage, e.Message)
+
+	// received from the wire, the text payload is raw json.
+	decoded := messages.NewEmptyMessage()
+	assert.NoError(t, messages.JsonCodec.Decode([]byte(`{"action":"notify.error","data":"credential expired"}`), decoded))
+	e, _ = messages.ErrorOf(c.localize(decoded))
+	assert.Equal(t, "凭证已过期", e.Message)
+	assert.True(t, e.Retryable)
+
+	chat := messages.NewMessage(1, messages.ActionChatMessage, errInvalidAuthMessage)
+	assert.Same(t, chat, c.localize(chat))
+}
@@ -0,0 +1,134 @@
+package gate
+
+import (
+	"net"
+	"time"
+
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+// Locator resolves the approximate location of IP for the login notify, such as GeoIP database, returns empty if
+// unknown.
+type Locator interface {
+	Locate(ip string) string
+}
+
+// LocatorFunc adapts the function to Locator.
+type LocatorFunc func(ip string) string
+
+func (f LocatorFunc) Locate(ip string) string {
+	return f(ip)
+}
+
+// GeoIPLocator returns the Locator resolves the country of ip by the GeoIP lookup.
+func GeoIPLocator(lookup GeoIPLookup) Locator {
+	return LocatorFunc(func(ip string) string {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return ""
+		}
+		info, err := lookup(parsed)
+		if err != nil || info == nil {
+			return ""
+		}
+		return info.Country
+	})
+}
+
+// SetLoginNotify enables the login notify, when a device logs in, the device kicked out and coexisting devices of the
+// user are notified with the new device, and the new device is notified with them, see messages.LoginNotify. The
+// locator is optional.
+func (c *Impl) SetLoginNotify(enable bool, locator Locator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loginNotify = enable
+	c.locator = locator
+}
+
+// loginDevices returns the device of the client logging in as uid, and the device of client kicked by it, nil if
+// the login notify is disabled or no client is kicked.
+func (c *Impl) loginDevices(cli Client, uid string) (device *messages.DeviceInfo, kicked *messages.DeviceInfo) {
+	c.mu.RLock()
+	enable, locator := c.loginNotify, c.locator
+	id := NewID2(uid)
+	id.SetGateway(c.id)
+	prev := c.clients[id]
+	c.mu.RUnlock()
+	if !enable {
+		return nil, nil
+	}
+	device = deviceInfo(cli, locator)
+	device.LoginAt = time.Now().UnixMilli()
+	if prev != nil && prev != cli {
+		kicked = deviceInfo(prev, locator)
+	}
+	return device, kicked
+}
+
+// notifyLogin notifies the client of id logged in with the device kicked, and notifies the devices of the user
+// coexisting and the new device each other.
+func (c *Impl) notifyLogin(id ID, device *messages.DeviceInfo, kicked *messages.DeviceInfo) {
+	if device == nil {
+		return
+	}
+	if kicked != nil {
+		_ = c.EnqueueMessage(id, messages.NewMessage(0, messages.ActionNotifyLogin, &messages.LoginNotify{
+			Kicked: true,
+			Device: kicked,
+		}))
+	}
+
+	id.SetGateway(c.id)
+	uid := id.UID()
+	c.mu.RLock()
+	locator := c.locator
+	var others []Client
+	if c.uidCounts[uid] > 1 {
+		for cid, cli := range c.clients {
+			if cid != id && cid.UID() == uid {
+				others = append(others, cli)
+			}
+		}
+	}
+	c.mu.RUnlock()
+
+	for _, other := range others {
+		_ = c.EnqueueMessage(other.GetInfo().ID, messages.NewMessage(0, messages.ActionNotifyLogin, &messages.LoginNotify{
+			Device: device,
+		}))
+		_ = c.EnqueueMessage(id, messages.NewMessage(0, messages.ActionNotifyLogin, &messages.LoginNotify{
+			Device: deviceInfo(other, locator),
+		}))
+	}
+}
+
+// deviceInfo returns the device of the client from the credentials and the connection.
+func deviceInfo(cli Client, locator Locator) *messages.DeviceInfo {
+	info := cli.GetInfo()
+	d := &messages.DeviceInfo{
+		IP:      info.CliAddr,
+		LoginAt: info.ConnectionAt,
+	}
+	if host, _, err := net.SplitHostPort(info.CliAddr); err == nil {
+		d.IP = host
+	}
+	if dc, ok := cli.(DefaultClient); ok {
+		if cred := dc.GetCredentials(); cred != nil {
+			d.DeviceId = cred.DeviceID
+			d.DeviceName = cred.DeviceName
+			d.DeviceType = cred.Type
+		}
+	}
+	if locator != nil && d.IP != "" {
+		d.Location = locator.Locate(d.IP)
+	}
+	return d
+}
+
+// loginNotifier the gateway notifies devices of the user logging in, implemented by Impl.
+type loginNotifier interface {
+	loginDevices(cli Client, uid string) (device *messages.DeviceInfo, kicked *messages.DeviceInfo)
+	notifyLogin(id ID, device *messages.DeviceInfo, kicked *messages.DeviceInfo)
+}
+
+var _ loginNotifier = (*Impl)(nil)
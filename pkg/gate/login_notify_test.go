@@ -0,0 +1,117 @@
+package gate
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+type credClient struct {
+	mockClient
+	mu   sync.Mutex
+	cred *ClientAuthCredentials
+	got  []*messages.GlideMessage
+}
+
+func (c *credClient) EnqueueMessage(message *messages.GlideMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.got = append(c.got, message)
+	return nil
+}
+
+func (c *credClient) SetCredentials(credentials *ClientAuthCredentials) {
+	c.cred = credentials
+}
+
+func (c *credClient) GetCredentials() *ClientAuthCredentials {
+	return c.cred
+}
+
+func (c *credClient) AddMessageInterceptor(interceptor MessageInterceptor) {}
+
+func (c *credClient) find(action messages.Action) *messages.GlideMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range c.got {
+		if m.GetAction() == action {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestAuthenticator_LoginNotify(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+	gateway.SetLoginNotify(true, LocatorFunc(func(ip string) string {
+		return "CN"
+	}))
+	auth := NewAuthenticator(gateway, "secret")
+
+	old := &credClient{mockClient: mockClient{info: Info{ID: NewID("g1", "", "old"), CliAddr: "1.1.1.1:80", ConnectionAt: 1}, running: true}}
+	gateway.AddClient(old)
+	_, err = auth.updateClient(old, &ClientAuthCredentials{UserID: "1", DeviceID: "a", DeviceName: "iPhone", Type: 1})
+	assert.NoError(t, err)
+
+	neu := &credClient{mockClient: mockClient{info: Info{ID: NewID("g1", "", "new"), CliAddr: "2.2.2.2:80"}, running: true}}
+	gateway.AddClient(neu)
+	_, err = auth.updateClient(neu, &ClientAuthCredentials{UserID: "1", DeviceID: "b", DeviceName: "Pixel", Type: 2})
+	assert.NoError(t, err)
+
+	var kickOut *messages.GlideMessage
+	assert.Eventually(t, func() bool {
+		kickOut = old.find(messages.ActionNotifyKickOut)
+		return kickOut != nil
+	}, time.Second, time.Millisecond*10)
+	notify := kickOut.Data.GetData().(*messages.KickOutNotify)
+	assert.Equal(t, "b", notify.Device.DeviceId)
+	assert.Equal(t, "2.2.2.2", notify.Device.IP)
+	assert.Equal(t, "CN", notify.Device.Location)
+	assert.NotZero(t, notify.Device.LoginAt)
+
+	var login *messages.GlideMessage
+	assert.Eventually(t, func() bool {
+		login = neu.find(messages.ActionNotifyLogin)
+		return login != nil
+	}, time.Second, time.Millisecond*10)
+	loginNotify := login.Data.GetData().(*messages.LoginNotify)
+	assert.True(t, loginNotify.Kicked)
+	assert.Equal(t, "a", loginNotify.Device.DeviceId)
+	assert.Equal(t, 1, loginNotify.Device.DeviceType)
+	assert.Equal(t, "1.1.1.1", loginNotify.Device.IP)
+}
+
+func TestImpl_NotifyLogin_Coexist(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+	gateway.SetLoginNotify(true, nil)
+
+	phone := &credClient{mockClient: mockClient{info: Info{ID: NewID("g1", "1", "1"), CliAddr: "1.1.1.1:80"}, running: true}}
+	desktop := &credClient{mockClient: mockClient{info: Info{ID: NewID("g1", "1", "2"), CliAddr: "2.2.2.2:80"}, running: true}}
+	gateway.AddClient(phone)
+	gateway.AddClient(desktop)
+
+	device, kicked := gateway.loginDevices(desktop, "1")
+	assert.Nil(t, kicked)
+	gateway.notifyLogin(desktop.GetInfo().ID, device, kicked)
+
+	assert.Eventually(t, func() bool {
+		return phone.find(messages.ActionNotifyLogin) != nil && desktop.find(messages.ActionNotifyLogin) != nil
+	}, time.Second, time.Millisecond*10)
+	n := phone.find(messages.ActionNotifyLogin).Data.GetData().(*messages.LoginNotify)
+	assert.False(t, n.Kicked)
+	assert.Equal(t, "2.2.2.2", n.Device.IP)
+	n = desktop.find(messages.ActionNotifyLogin).Data.GetData().(*messages.LoginNotify)
+	assert.Equal(t, "1.1.1.1", n.Device.IP)
+
+	gateway.SetLoginNotify(false, nil)
+	device, kicked = gateway.loginDevices(desktop, "1")
+	assert.Nil(t, device)
+	assert.Nil(t, kicked)
+}
@@ -0,0 +1,87 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"sort"
+	"sync"
+)
+
+// Middleware intercepts the message from client before it's passed to the MessageHandler, the message can be mutated
+// in place. Returns handled true to drop the message, the rest middlewares and the MessageHandler will not receive it.
+// Returns error to drop the message and notify the client with the error.
+type Middleware func(c Client, m *messages.GlideMessage) (handled bool, err error)
+
+// Priorities of the built-in middlewares, middleware with lower priority runs first.
+const (
+	PriorityAuthenticate = -2000
+	PriorityTicket       = -1000
+	PriorityDefault      = 0
+)
+
+type middlewareEntry struct {
+	priority int
+	m        Middleware
+}
+
+// middlewareChain runs middlewares ordered by priority, middlewares with the same priority run in the order added.
+type middlewareChain struct {
+	mu      sync.RWMutex
+	entries []middlewareEntry
+	// onDrop is called when the message is dropped by the middleware returns error, optional.
+	onDrop func(c Client, m *messages.GlideMessage, err error)
+}
+
+func (mc *middlewareChain) use(priority int, m Middleware) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	entries := append(append([]middlewareEntry{}, mc.entries...), middlewareEntry{priority: priority, m: m})
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].priority < entries[j].priority
+	})
+	mc.entries = entries
+}
+
+// handle runs the message through middlewares, returns true if the message is dropped by any of them.
+func (mc *middlewareChain) handle(c Client, m *messages.GlideMessage) bool {
+	mc.mu.RLock()
+	entries := mc.entries
+	mc.mu.RUnlock()
+
+	for _, e := range entries {
+		handled, err := e.m(c, m)
+		if err != nil {
+			log.D("message %s dropped by middleware: %v", m.GetAction(), err)
+			_ = c.EnqueueMessage(messages.NewErrorMessage(m.GetSeq(), messages.ActionNotifyError, err.Error()))
+			if mc.onDrop != nil {
+				mc.onDrop(c, m, err)
+			}
+			return true
+		}
+		if handled {
+			return true
+		}
+	}
+	return false
+}
+
+// Use adds the middleware with PriorityDefault to the gateway, it applies to all clients.
+func (c *Impl) Use(m Middleware) {
+	c.middlewares.use(PriorityDefault, m)
+}
+
+// UseWithPriority adds the middleware with the priority to the gateway, lower priority runs first.
+func (c *Impl) UseWithPriority(priority int, m Middleware) {
+	c.middlewares.use(priority, m)
+}
+
+// interceptorMiddleware adapts the MessageInterceptor to Middleware.
+func interceptorMiddleware(i MessageInterceptor) Middleware {
+	return func(c Client, m *messages.GlideMessage) (bool, error) {
+		dc, ok := c.(DefaultClient)
+		if !ok {
+			return false, nil
+		}
+		return i(dc, m), nil
+	}
+}
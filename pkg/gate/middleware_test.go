@@ -0,0 +1,42 @@
+package gate
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+
+	var order []string
+	gateway.Use(func(c Client, m *messages.GlideMessage) (bool, error) {
+		order = append(order, "default")
+		m.To = "mutated"
+		return false, nil
+	})
+	gateway.UseWithPriority(-1, func(c Client, m *messages.GlideMessage) (bool, error) {
+		order = append(order, "first")
+		return false, nil
+	})
+	gateway.UseWithPriority(1, func(c Client, m *messages.GlideMessage) (bool, error) {
+		order = append(order, "last")
+		if m.GetAction() == messages.ActionGroupMessage {
+			return true, nil
+		}
+		if m.GetAction() == messages.ActionClientCustom {
+			return false, errors.New("rejected")
+		}
+		return false, nil
+	})
+
+	m := messages.NewMessage(0, messages.ActionChatMessage, nil)
+	assert.False(t, gateway.middlewares.handle(&mockClient{}, m))
+	assert.Equal(t, []string{"first", "default", "last"}, order)
+	assert.Equal(t, "mutated", m.To)
+
+	assert.True(t, gateway.middlewares.handle(&mockClient{}, messages.NewMessage(0, messages.ActionGroupMessage, nil)))
+	assert.True(t, gateway.middlewares.handle(&mockClient{}, messages.NewMessage(0, messages.ActionClientCustom, nil)))
+}
@@ -0,0 +1,35 @@
+package gate
+
+import "github.com/glide-im/glide/pkg/messages"
+
+// Multicaster enqueues a message to many clients in one call, implemented optionally by Gateway, such as the client of
+// a remote gateway to send to clients of the gateway in one request.
+type Multicaster interface {
+
+	// EnqueueMessages enqueues the message to clients exist, returns the count of clients enqueued.
+	EnqueueMessages(ids []ID, msg *messages.GlideMessage) (int, error)
+}
+
+var _ Multicaster = (*Impl)(nil)
+
+// EnqueueMessages enqueues the message to clients connected to the gateway, the message is encoded once per codec,
+// clients not exist are skipped.
+func (c *Impl) EnqueueMessages(ids []ID, msg *messages.GlideMessage) (int, error) {
+	msg = messages.Serialize(msg)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := 0
+	for _, id := range ids {
+		id.SetGateway(c.id)
+		cli, ok := c.clients[id]
+		if !ok || cli == nil {
+			continue
+		}
+		if c.enqueueMessage(id, cli, msg) == nil {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,143 @@
+package gate
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/messages"
+	"sync"
+	"time"
+)
+
+const errUserConnectionsExceeded = "too many connections of the user"
+
+const defaultQuotaRetryAfter = time.Second * 30
+
+// Reasons of connections rejected by Quota.
+const (
+	QuotaReasonConnections     = "max_connections"
+	QuotaReasonBandwidth       = "max_bandwidth"
+	QuotaReasonUserConnections = "max_user_connections"
+)
+
+// IsUserConnectionsExceeded returns true if the error is caused by the user reaching the max connections.
+func IsUserConnectionsExceeded(err error) bool {
+	return err != nil && err.Error() == errUserConnectionsExceeded
+}
+
+// QuotaOptions the limits of the gateway, 0 is unlimited.
+type QuotaOptions struct {
+	// MaxConnections the max count of concurrent connections.
+	MaxConnections int
+	// MaxConnectionsPerUID the max count of authenticated connections of a user, such as devices.
+	MaxConnectionsPerUID int
+	// MaxOutboundBytes the max aggregate outbound bytes per second of all connections, new connections are rejected
+	// when the outbound rate of the last second exceeds.
+	MaxOutboundBytes int64
+	// RetryAfter the seconds the rejected client should retry after, default 30 seconds.
+	RetryAfter time.Duration
+}
+
+// Quota enforces the limits of the gateway, connections exceeding limits are rejected with messages.ServerBusy
+// referencing another gateway to retry at, see SetRedirect.
+type Quota struct {
+	opts     QuotaOptions
+	outbound *RateMeter
+
+	mu       sync.RWMutex
+	redirect func() string
+}
+
+func NewQuota(opts *QuotaOptions) *Quota {
+	q := &Quota{opts: *opts, outbound: &RateMeter{}}
+	if q.opts.RetryAfter <= 0 {
+		q.opts.RetryAfter = defaultQuotaRetryAfter
+	}
+	return q
+}
+
+// SetRedirect sets fn returns the address of another gateway the rejected client retries at, such as the gateway
+// allocated by discovery.Allocator, empty if no gateway available.
+func (q *Quota) SetRedirect(fn func() string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.redirect = fn
+}
+
+// Outbound returns the meter of the outbound bytes of the gateway.
+func (q *Quota) Outbound() *RateMeter {
+	return q.outbound
+}
+
+// admit returns the empty reason if a new connection is admitted with the current connections, otherwise returns
+// the reason and the message to notify.
+func (q *Quota) admit(connections int) (string, *messages.ServerBusy) {
+	if q.opts.MaxConnections > 0 && connections >= q.opts.MaxConnections {
+		return QuotaReasonConnections, q.busy(messages.ServerBusyConnections, "too many connections")
+	}
+	if q.opts.MaxOutboundBytes > 0 && q.outbound.Rate() >= q.opts.MaxOutboundBytes {
+		return QuotaReasonBandwidth, q.busy(messages.ServerBusyBandwidth, "outbound bandwidth exceeded")
+	}
+	return "", nil
+}
+
+func (q *Quota) busy(code int, reason string) *messages.ServerBusy {
+	b := &messages.ServerBusy{
+		Code:       code,
+		Reason:     reason,
+		RetryAfter: int(q.opts.RetryAfter / time.Second),
+	}
+	q.mu.RLock()
+	redirect := q.redirect
+	q.mu.RUnlock()
+	if redirect != nil {
+		b.RetryAt = redirect()
+	}
+	return b
+}
+
+// RateMeter measures the count per second.
+type RateMeter struct {
+	mu   sync.Mutex
+	sec  int64
+	cur  int64
+	last int64
+}
+
+// Add adds n to the count of the current second.
+func (m *RateMeter) Add(n int64) {
+	m.mu.Lock()
+	m.roll(time.Now().Unix())
+	m.cur += n
+	m.mu.Unlock()
+}
+
+// Rate returns the count of the last second.
+func (m *RateMeter) Rate() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roll(time.Now().Unix())
+	return m.last
+}
+
+func (m *RateMeter) roll(now int64) {
+	if now == m.sec {
+		return
+	}
+	if now == m.sec+1 {
+		m.last = m.cur
+	} else {
+		m.last = 0
+	}
+	m.cur = 0
+	m.sec = now
+}
+
+// checkUserConnections returns error if the user of newID reaches the max connections, must be called with lock.
+func (c *Impl) checkUserConnections(oldID ID, newID ID) error {
+	if c.maxPerUID <= 0 || newID.IsTemp() || oldID.UID() == newID.UID() {
+		return nil
+	}
+	if c.uidCounts[newID.UID()] >= c.maxPerUID {
+		return errors.New(errUserConnectionsExceeded)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/conn"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type recordConnection struct {
+	written [][]byte
+	closed  bool
+}
+
+func (r *recordConnection) Write(data []byte) error {
+	r.written = append(r.written, data)
+	return nil
+}
+
+func (r *recordConnection) Read() ([]byte, error) { select {} }
+
+func (r *recordConnection) Close() error {
+	r.closed = true
+	return nil
+}
+
+func (r *recordConnection) GetConnInfo() *conn.ConnectionInfo {
+	return &conn.ConnectionInfo{Ip: "127.0.0.1"}
+}
+
+func TestRateMeter(t *testing.T) {
+	m := &RateMeter{sec: 100}
+	m.cur = 10
+	m.roll(101)
+	assert.Equal(t, int64(10), m.last)
+	m.roll(103)
+	assert.Equal(t, int64(0), m.last)
+}
+
+func TestImpl_MaxConnectionsPerUID(t *testing.T) {
+	g, err := NewServer(&Options{ID: "gw", MaxMessageConcurrency: 1})
+	assert.NoError(t, err)
+	g.SetMessageHandler(func(cliInfo *Info, message *messages.GlideMessage) {})
+	for _, uid := range []string{"tmp@1", "tmp@2", "tmp@3"} {
+		g.AddClient(&mockClient{info: Info{ID: NewID("gw", uid, "")}, running: true})
+	}
+	g.SetMaxConnectionsPerUID(2)
+
+	assert.NoError(t, g.SetClientID(NewID("gw", "tmp@1", ""), NewID("gw", "1", "1")))
+	assert.NoError(t, g.SetClientID(NewID("gw", "tmp@2", ""), NewID("gw", "1", "2")))
+	err = g.SetClientID(NewID("gw", "tmp@3", ""), NewID("gw", "1", "3"))
+	assert.True(t, IsUserConnectionsExceeded(err))
+
+	assert.NoError(t, g.ExitClient(NewID("gw", "1", "1")))
+	assert.NoError(t, g.SetClientID(NewID("gw", "tmp@3", ""), NewID("gw", "1", "3")))
+}
+
+func TestWebsocketGatewayServer_Quota(t *testing.T) {
+	w := NewWebsocketServer("gw", "", 0, "")
+	w.SetMessageHandler(func(cliInfo *Info, message *messages.GlideMessage) {})
+	w.decorator.AddClient(&mockClient{info: Info{ID: NewID("gw", "tmp@1", "")}, running: true})
+
+	q := NewQuota(&QuotaOptions{MaxConnections: 1})
+	q.SetRedirect(func() string { return "wss://gw2" })
+	w.SetQuota(q)
+
+	c := &recordConnection{}
+	assert.Equal(t, ID(""), w.HandleConnection(c))
+	assert.True(t, c.closed)
+	assert.Len(t, c.written, 1)
+
+	m := messages.NewEmptyMessage()
+	assert.NoError(t, messages.JsonCodec.Decode(c.written[0], m))
+	assert.Equal(t, messages.ActionNotifyServerBusy, m.GetAction())
+	busy := &messages.ServerBusy{}
+	assert.NoError(t, m.Data.Deserialize(busy))
+	assert.Equal(t, messages.ServerBusyConnections, busy.Code)
+	assert.Equal(t, "wss://gw2", busy.RetryAt)
+	assert.Equal(t, 30, busy.RetryAfter)
+}
@@ -2,9 +2,8 @@ package gate
 
 import (
 	"github.com/glide-im/glide/pkg/conn"
-	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
-	"sync"
+	"github.com/glide-im/glide/pkg/pool"
 )
 
 var messageReader MessageReader
@@ -13,17 +12,27 @@ var messageReader MessageReader
 var codec messages.Codec = messages.DefaultCodec
 
 // recyclePool 回收池, 减少临时对象, 回收复用 readerRes
-var recyclePool sync.Pool
+var recyclePool = pool.New("reader_result", func() interface{} {
+	return &readerRes{}
+})
 
 func init() {
-	recyclePool = sync.Pool{
-		New: func() interface{} {
-			return &readerRes{}
-		},
-	}
 	SetMessageReader(&defaultReader{})
 }
 
+// codecOf returns the codec of the connection negotiated subprotocol.
+func codecOf(c conn.Connection) messages.Codec {
+	if conn.IsBinarySubprotocol(c.GetConnInfo().Subprotocol) {
+		return messages.BinaryCodec
+	}
+	return codec
+}
+
+// isSkippable returns true if the message failed to read is skipped, and the connection can be read continue.
+func isSkippable(err error) bool {
+	return messages.IsDecodeError(err) || err == conn.ErrMessageTooLarge
+}
+
 func SetMessageReader(s MessageReader) {
 	messageReader = s
 }
@@ -55,12 +64,13 @@ type defaultReader struct{}
 func (d *defaultReader) ReadCh(conn conn.Connection) (<-chan *readerRes, chan<- interface{}) {
 	c := make(chan *readerRes, 5)
 	done := make(chan interface{})
+	cdc := codecOf(conn)
 
 	go func() {
 		defer func() {
 			e := recover()
 			if e != nil {
-				logger.E("error on runRead msg from connection %v", e)
+				log.E("error on runRead msg from connection %v", e)
 			}
 		}()
 		for {
@@ -68,12 +78,12 @@ func (d *defaultReader) ReadCh(conn conn.Connection) (<-chan *readerRes, chan<-
 			case <-done:
 				goto CLOSE
 			default:
-				m, err := d.Read(conn)
+				m, err := d.read(conn, cdc)
 				res := recyclePool.Get().(*readerRes)
 				if err != nil {
 					res.err = err
 					c <- res
-					if messages.IsDecodeError(err) {
+					if isSkippable(err) {
 						continue
 					}
 					goto CLOSE
@@ -90,12 +100,16 @@ func (d *defaultReader) ReadCh(conn conn.Connection) (<-chan *readerRes, chan<-
 }
 
 func (d *defaultReader) Read(conn conn.Connection) (*messages.GlideMessage, error) {
+	return d.read(conn, codecOf(conn))
+}
+
+func (d *defaultReader) read(conn conn.Connection, cdc messages.Codec) (*messages.GlideMessage, error) {
 	// TODO 2021-12-3 校验数据包
 	bytes, err := conn.Read()
 	if err != nil {
 		return nil, err
 	}
 	m := messages.NewEmptyMessage()
-	err = codec.Decode(bytes, m)
+	err = cdc.Decode(bytes, m)
 	return m, err
 }
@@ -0,0 +1,23 @@
+package gate
+
+import (
+	"github.com/go-redis/redis"
+	"time"
+)
+
+const redisKeyNoncePrefix = "im:ticket:nonce:"
+
+var _ NonceStore = (*RedisNonceStore)(nil)
+
+// RedisNonceStore remembers nonces of tickets in redis by SETNX, shared by gateways of the cluster.
+type RedisNonceStore struct {
+	client *redis.Client
+}
+
+func NewRedisNonceStore(client *redis.Client) *RedisNonceStore {
+	return &RedisNonceStore{client: client}
+}
+
+func (r *RedisNonceStore) Remember(key string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(redisKeyNoncePrefix+key, 1, ttl).Result()
+}
@@ -0,0 +1,327 @@
+package gate
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/sharding"
+	"github.com/rcrowley/go-metrics"
+	"sync"
+	"time"
+)
+
+// SessionRegistry records the clients connected to each gateway of the cluster, used to route message to the gateway
+// which the client connected to.
+type SessionRegistry interface {
+
+	// Register records the client is connected to the gateway.
+	Register(id ID, gateway string) error
+
+	// Remove removes the client session.
+	Remove(id ID) error
+
+	// Sessions returns all client sessions registered for the gateway.
+	Sessions(gateway string) ([]ID, error)
+}
+
+// SessionLocator looks up sessions of many users in one batch, implemented optionally by SessionRegistry.
+type SessionLocator interface {
+
+	// Locate returns the sessions of users connected, grouped by the gateway connected to.
+	Locate(uids []string) (map[string][]ID, error)
+}
+
+var _ SessionRegistry = (*MemSessionRegistry)(nil)
+var _ SessionLocator = (*MemSessionRegistry)(nil)
+
+// MemSessionRegistry in memory SessionRegistry implementation, used for single node deployment.
+type MemSessionRegistry struct {
+	mu       sync.RWMutex
+	sessions map[ID]string
+}
+
+func NewMemSessionRegistry() *MemSessionRegistry {
+	return &MemSessionRegistry{
+		sessions: map[ID]string{},
+	}
+}
+
+func (m *MemSessionRegistry) Register(id ID, gateway string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[id] = gateway
+	return nil
+}
+
+func (m *MemSessionRegistry) Remove(id ID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemSessionRegistry) Sessions(gateway string) ([]ID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var ids []ID
+	for id, g := range m.sessions {
+		if g == gateway {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *MemSessionRegistry) Locate(uids []string) (map[string][]ID, error) {
+	want := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		want[uid] = true
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ret := map[string][]ID{}
+	for id, g := range m.sessions {
+		if want[id.UID()] {
+			ret[g] = append(ret[g], id)
+		}
+	}
+	return ret, nil
+}
+
+// ReconcileResult is the discrepancies found and fixed in one reconciliation.
+type ReconcileResult struct {
+	// Ghost sessions in registry but not connected to the gateway.
+	Ghost int
+	// Missing clients connected to the gateway but not in registry.
+	Missing int
+	// Dead clients in the gateway which is not running.
+	Dead int
+}
+
+// Reconciler periodically compares the session registry with the clients actually connected to gateway, and fixes
+// the drift: removes ghost sessions, registers missing sessions and exits dead clients.
+// Discrepancies are reported to the metrics registry.
+type Reconciler struct {
+	gatewayID string
+	gateway   DefaultGateway
+	registry  SessionRegistry
+	interval  time.Duration
+
+	ghost   metrics.Counter
+	missing metrics.Counter
+	dead    metrics.Counter
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewReconciler creates a Reconciler, the metrics registry can be nil, use the metrics.DefaultRegistry when nil.
+func NewReconciler(gatewayID string, gateway DefaultGateway, registry SessionRegistry, interval time.Duration,
+	r metrics.Registry) *Reconciler {
+	if r == nil {
+		r = metrics.DefaultRegistry
+	}
+	return &Reconciler{
+		gatewayID: gatewayID,
+		gateway:   gateway,
+		registry:  registry,
+		interval:  interval,
+		ghost:     metrics.GetOrRegisterCounter("gateway.reconcile.ghost", r),
+		missing:   metrics.GetOrRegisterCounter("gateway.reconcile.missing", r),
+		dead:      metrics.GetOrRegisterCounter("gateway.reconcile.dead", r),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start runs reconciliation periodically in a new goroutine.
+func (r *Reconciler) Start() {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				result, err := r.Reconcile()
+				if err != nil {
+					log.E("[reconciler] reconcile error: %v", err)
+				} else if result.Ghost+result.Missing+result.Dead > 0 {
+					log.W("[reconciler] session drift fixed: %+v", result)
+				}
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (r *Reconciler) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+}
+
+// Reconcile compares and fixes the drift once.
+func (r *Reconciler) Reconcile() (ReconcileResult, error) {
+	result := ReconcileResult{}
+
+	sessions, err := r.registry.Sessions(r.gatewayID)
+	if err != nil {
+		return result, err
+	}
+	registered := map[ID]bool{}
+	for _, id := range sessions {
+		registered[id] = true
+	}
+
+	connected := map[ID]bool{}
+	for id := range r.gateway.GetAll() {
+		cli := r.gateway.GetClient(id)
+		if cli == nil || !cli.IsRunning() {
+			result.Dead++
+			_ = r.gateway.ExitClient(id)
+			continue
+		}
+		connected[id] = true
+		if !registered[id] {
+			result.Missing++
+			if err = r.registry.Register(id, r.gatewayID); err != nil {
+				log.E("[reconciler] register session %s error: %v", id, err)
+			}
+		}
+	}
+
+	for id := range registered {
+		if connected[id] {
+			continue
+		}
+		result.Ghost++
+		if err = r.registry.Remove(id); err != nil {
+			log.E("[reconciler] remove session %s error: %v", id, err)
+		}
+	}
+
+	r.ghost.Inc(int64(result.Ghost))
+	r.missing.Inc(int64(result.Missing))
+	r.dead.Inc(int64(result.Dead))
+	return result, nil
+}
+
+var _ SessionRegistry = (*ShardedSessionRegistry)(nil)
+var _ SessionLocator = (*ShardedSessionRegistry)(nil)
+
+// ShardedSessionRegistry partitions sessions by uid across registries, such as redis instances, the shard of the
+// uid is located by the sharding.Router of shard names. Sessions placed on another shard after shards changed are
+// reported missing by Sessions, so the Reconciler registers them on the shard they belong to.
+type ShardedSessionRegistry struct {
+	router *sharding.Router
+
+	mu     sync.RWMutex
+	shards map[string]SessionRegistry
+}
+
+// NewShardedSessionRegistry returns the registry of shards by name, the uid is placed by the strategy s.
+func NewShardedSessionRegistry(shards map[string]SessionRegistry, s sharding.Sharding) *ShardedSessionRegistry {
+	r := &ShardedSessionRegistry{router: sharding.NewRouter(s)}
+	r.SetShards(shards)
+	return r
+}
+
+// Router returns the router of shards, embedders register rebalance hooks to migrate sessions eagerly.
+func (r *ShardedSessionRegistry) Router() *sharding.Router {
+	return r.router
+}
+
+// SetShards replaces shards by name, sessions of uid moved are registered again by the Reconciler.
+func (r *ShardedSessionRegistry) SetShards(shards map[string]SessionRegistry) {
+	names := make([]string, 0, len(shards))
+	r.mu.Lock()
+	r.shards = map[string]SessionRegistry{}
+	for name, s := range shards {
+		r.shards[name] = s
+		names = append(names, name)
+	}
+	r.mu.Unlock()
+	r.router.SetNodes(names)
+}
+
+func (r *ShardedSessionRegistry) shard(uid string) (SessionRegistry, error) {
+	name, err := r.router.Locate(uid)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.shards[name]
+	if !ok {
+		return nil, errors.New("session registry shard not found: " + name)
+	}
+	return s, nil
+}
+
+func (r *ShardedSessionRegistry) Register(id ID, gateway string) error {
+	s, err := r.shard(id.UID())
+	if err != nil {
+		return err
+	}
+	return s.Register(id, gateway)
+}
+
+// Remove removes the session from all shards, the session may be registered on the shard it belonged to before
+// shards changed.
+func (r *ShardedSessionRegistry) Remove(id ID) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var err error
+	for _, s := range r.shards {
+		if e := s.Remove(id); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Sessions returns sessions of the gateway on the shard they belong to.
+func (r *ShardedSessionRegistry) Sessions(gateway string) ([]ID, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var ret []ID
+	for name, s := range r.shards {
+		ids, err := s.Sessions(gateway)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if n, _ := r.router.Locate(id.UID()); n == name {
+				ret = append(ret, id)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// Locate looks up users on each shard in batch, shards must implement SessionLocator.
+func (r *ShardedSessionRegistry) Locate(uids []string) (map[string][]ID, error) {
+	byShard := map[string][]string{}
+	for _, uid := range uids {
+		name, err := r.router.Locate(uid)
+		if err != nil {
+			return nil, err
+		}
+		byShard[name] = append(byShard[name], uid)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ret := map[string][]ID{}
+	for name, us := range byShard {
+		l, ok := r.shards[name].(SessionLocator)
+		if !ok {
+			return nil, errors.New("session registry shard does not locate sessions: " + name)
+		}
+		located, err := l.Locate(us)
+		if err != nil {
+			return nil, err
+		}
+		for gw, ids := range located {
+			ret[gw] = append(ret[gw], ids...)
+		}
+	}
+	return ret, nil
+}
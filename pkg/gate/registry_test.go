@@ -0,0 +1,110 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/sharding"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type mockClient struct {
+	info    Info
+	running bool
+}
+
+func (m *mockClient) SetID(id ID) {
+	m.info.ID = id
+}
+
+func (m *mockClient) IsRunning() bool {
+	return m.running
+}
+
+func (m *mockClient) EnqueueMessage(message *messages.GlideMessage) error {
+	return nil
+}
+
+func (m *mockClient) Exit() {
+	m.running = false
+}
+
+func (m *mockClient) Run() {
+	m.running = true
+}
+
+func (m *mockClient) GetInfo() Info {
+	return m.info
+}
+
+func TestReconciler_Reconcile(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+
+	registry := NewMemSessionRegistry()
+
+	gateway.AddClient(&mockClient{info: Info{ID: NewID2("1")}, running: true})
+	gateway.AddClient(&mockClient{info: Info{ID: NewID2("2")}, running: false})
+	gateway.SetSessionRegistry(registry)
+	gateway.AddClient(&mockClient{info: Info{ID: NewID2("3")}, running: true})
+	_ = registry.Register(NewID("g1", "ghost", ""), "g1")
+
+	reconciler := NewReconciler("g1", gateway, registry, time.Minute, nil)
+	result, err := reconciler.Reconcile()
+	assert.NoError(t, err)
+	assert.Equal(t, ReconcileResult{Ghost: 1, Missing: 1, Dead: 1}, result)
+
+	sessions, _ := registry.Sessions("g1")
+	assert.ElementsMatch(t, []ID{NewID("g1", "1", ""), NewID("g1", "3", "")}, sessions)
+
+	result, err = reconciler.Reconcile()
+	assert.NoError(t, err)
+	assert.Equal(t, ReconcileResult{}, result)
+}
+
+func TestMemSessionRegistry_Locate(t *testing.T) {
+	registry := NewMemSessionRegistry()
+	_ = registry.Register(NewID("g1", "1", "1"), "g1")
+	_ = registry.Register(NewID("g1", "1", "2"), "g1")
+	_ = registry.Register(NewID("g2", "2", ""), "g2")
+	_ = registry.Register(NewID("g2", "3", ""), "g2")
+
+	got, err := registry.Locate([]string{"1", "2", "4"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []ID{NewID("g1", "1", "1"), NewID("g1", "1", "2")}, got["g1"])
+	assert.Equal(t, []ID{NewID("g2", "2", "")}, got["g2"])
+}
+
+func TestShardedSessionRegistry(t *testing.T) {
+	s1, s2 := NewMemSessionRegistry(), NewMemSessionRegistry()
+	registry := NewShardedSessionRegistry(map[string]SessionRegistry{"s1": s1}, sharding.NewRendezvous())
+	uids := []string{"1", "2", "3", "4", "5", "6"}
+	for _, uid := range uids {
+		assert.NoError(t, registry.Register(NewID("g1", uid, ""), "g1"))
+	}
+
+	// sessions of uid moved to the shard joined are missing until registered again.
+	registry.SetShards(map[string]SessionRegistry{"s1": s1, "s2": s2})
+	sessions, err := registry.Sessions("g1")
+	assert.NoError(t, err)
+	assert.Less(t, len(sessions), 6)
+	for _, uid := range uids {
+		assert.NoError(t, registry.Register(NewID("g1", uid, ""), "g1"))
+	}
+	sessions, _ = registry.Sessions("g1")
+	assert.Len(t, sessions, 6)
+	moved, _ := s2.Sessions("g1")
+	assert.NotEmpty(t, moved)
+
+	got, err := registry.Locate(append(uids, "7"))
+	assert.NoError(t, err)
+	assert.Len(t, got["g1"], 6)
+
+	// the stale session on the shard before is removed too.
+	assert.NoError(t, registry.Remove(moved[0]))
+	stale, _ := s1.Sessions("g1")
+	assert.NotContains(t, stale, moved[0])
+	sessions, _ = registry.Sessions("g1")
+	assert.Len(t, sessions, 5)
+}
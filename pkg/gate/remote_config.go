@@ -0,0 +1,67 @@
+package gate
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"github.com/glide-im/glide/pkg/messages"
+	"hash/fnv"
+)
+
+// remoteConfigPusher is implemented by the gateway pushes the remote config to clients authenticated, see
+// Impl.SetRemoteConfig.
+type remoteConfigPusher interface {
+	pushRemoteConfig(cli Client)
+}
+
+var _ remoteConfigPusher = (*Impl)(nil)
+
+// SetRemoteConfig sets the config pushed to clients on authenticate and resume, and pushes it to clients
+// authenticated now, nil to stop pushing. The Version is the hash of config if it's empty, so the same config pushed
+// by gateways of the cluster has the same version.
+func (c *Impl) SetRemoteConfig(cfg *messages.RemoteConfig) {
+	if cfg != nil {
+		copied := *cfg
+		if copied.Version == "" {
+			b, _ := json.Marshal(&copied)
+			h := fnv.New64a()
+			_, _ = h.Write(b)
+			copied.Version = hex.EncodeToString(h.Sum(nil))
+		}
+		cfg = &copied
+	}
+	c.remoteConfigMu.Lock()
+	c.remoteConfig = cfg
+	c.remoteConfigMu.Unlock()
+	if cfg == nil {
+		return
+	}
+
+	c.mu.RLock()
+	clients := make([]Client, 0, len(c.clients))
+	for id, cli := range c.clients {
+		if !id.IsTemp() {
+			clients = append(clients, cli)
+		}
+	}
+	c.mu.RUnlock()
+	for _, cli := range clients {
+		c.pushRemoteConfig(cli)
+	}
+}
+
+// RemoteConfig returns the config pushed to clients, nil if not set.
+func (c *Impl) RemoteConfig() *messages.RemoteConfig {
+	c.remoteConfigMu.RLock()
+	defer c.remoteConfigMu.RUnlock()
+	return c.remoteConfig
+}
+
+// pushRemoteConfig enqueues the remote config to the client, the client without messages.CapabilityRemoteConfig
+// drops it, see UserClient.adapt.
+func (c *Impl) pushRemoteConfig(cli Client) {
+	cfg := c.RemoteConfig()
+	if cfg == nil {
+		return
+	}
+	_ = cli.EnqueueMessage(messages.NewMessage(0, messages.ActionNotifyConfig, cfg))
+}
@@ -0,0 +1,61 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestImpl_SetRemoteConfig(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(func(cliInfo *Info, message *messages.GlideMessage) {})
+
+	tempID, _ := GenTempID("g1")
+	temp := &credClient{mockClient: mockClient{info: Info{ID: tempID}, running: true}}
+	cli := &credClient{mockClient: mockClient{info: Info{ID: NewID2("1")}, running: true}}
+	gateway.AddClient(temp)
+	gateway.AddClient(cli)
+
+	gateway.SetRemoteConfig(&messages.RemoteConfig{HeartbeatInterval: 30})
+	// the config is pushed to authenticated clients only.
+	assert.Empty(t, temp.got)
+	assert.Len(t, cli.got, 1)
+	assert.Equal(t, messages.Action(messages.ActionNotifyConfig), cli.got[0].GetAction())
+
+	version := gateway.RemoteConfig().Version
+	assert.NotEmpty(t, version)
+	gateway.SetRemoteConfig(&messages.RemoteConfig{HeartbeatInterval: 30})
+	assert.Equal(t, version, gateway.RemoteConfig().Version)
+	gateway.SetRemoteConfig(&messages.RemoteConfig{HeartbeatInterval: 60})
+	assert.NotEqual(t, version, gateway.RemoteConfig().Version)
+
+	gateway.SetRemoteConfig(nil)
+	assert.Len(t, cli.got, 3)
+	assert.Nil(t, gateway.RemoteConfig())
+}
+
+func TestAuthenticator_RemoteConfig(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(func(cliInfo *Info, message *messages.GlideMessage) {})
+	gateway.SetRemoteConfig(&messages.RemoteConfig{MediaUploadURL: "https://media"})
+	auth := NewAuthenticator(gateway, "secret")
+
+	tempID, _ := GenTempID("g1")
+	cli := &credClient{mockClient: mockClient{info: Info{ID: tempID}, running: true}}
+	gateway.AddClient(cli)
+	credential, err := auth.keys.Encrypt(&ClientAuthCredentials{UserID: "1", Timestamp: time.Now().UnixMilli()})
+	assert.NoError(t, err)
+	auth.ClientAuthMessageInterceptor(cli, messages.NewMessage(1, messages.ActionAuthenticate, credential))
+
+	var actions []messages.Action
+	for _, m := range cli.got {
+		actions = append(actions, m.GetAction())
+	}
+	assert.Contains(t, actions, messages.Action(messages.ActionNotifyConfig))
+	cfg := &messages.RemoteConfig{}
+	assert.NoError(t, cli.got[len(cli.got)-1].Data.Deserialize(cfg))
+	assert.Equal(t, "https://media", cfg.MediaUploadURL)
+}
@@ -0,0 +1,121 @@
+package gate
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	defaultReplicaTTL       = time.Hour * 24
+	defaultReplicaQueueSize = 4096
+
+	replicaKeySessionPrefix = "session:"
+)
+
+// SessionReplica the cache shared by gateways the resumable sessions are replicated to, such as store.RedisCache, it's
+// the method set of store.Cache. The client resumes on another gateway with the token after the gateway it connected
+// crashed.
+type SessionReplica interface {
+
+	// Get returns the value of key, nil if the key does not exist or expired.
+	Get(key string) ([]byte, error)
+
+	// Set sets the value of key expires after ttl.
+	Set(key string, value []byte, ttl time.Duration) error
+
+	// Delete deletes keys.
+	Delete(keys ...string) error
+}
+
+// replicatedSession the state of the session replicated, messages buffered are not replicated, the client syncs
+// messages missed after resumed on another gateway.
+type replicatedSession struct {
+	ID          ID                     `json:"id"`
+	Credentials *ClientAuthCredentials `json:"credentials"`
+}
+
+type replicaOp struct {
+	token   string
+	session *replicatedSession
+}
+
+// sessionReplicator writes sessions to the replica in order in its own goroutine, so the gateway is never blocked by
+// the replica, writes are dropped when the queue is full.
+type sessionReplicator struct {
+	replica SessionReplica
+	ttl     time.Duration
+	ops     chan replicaOp
+}
+
+func newSessionReplicator(replica SessionReplica, ttl time.Duration) *sessionReplicator {
+	if ttl <= 0 {
+		ttl = defaultReplicaTTL
+	}
+	r := &sessionReplicator{
+		replica: replica,
+		ttl:     ttl,
+		ops:     make(chan replicaOp, defaultReplicaQueueSize),
+	}
+	go r.run()
+	return r
+}
+
+func (r *sessionReplicator) run() {
+	for op := range r.ops {
+		key := replicaKeySessionPrefix + op.token
+		if op.session == nil {
+			if err := r.replica.Delete(key); err != nil {
+				log.E("delete replicated session error: %v", err)
+			}
+			continue
+		}
+		b, err := json.Marshal(op.session)
+		if err == nil {
+			err = r.replica.Set(key, b, r.ttl)
+		}
+		if err != nil {
+			log.E("replicate session %s error: %v", op.session.ID, err)
+		}
+	}
+}
+
+func (r *sessionReplicator) enqueue(op replicaOp) {
+	select {
+	case r.ops <- op:
+	default:
+		log.W("session replica queue is full, session of token dropped")
+	}
+}
+
+// save replicates the session of token.
+func (r *sessionReplicator) save(s *resumeSession) {
+	r.enqueue(replicaOp{token: s.token, session: &replicatedSession{ID: s.id, Credentials: s.credentials}})
+}
+
+// remove removes the session of token from the replica.
+func (r *sessionReplicator) remove(token string) {
+	r.enqueue(replicaOp{token: token})
+}
+
+// load returns the session of token replicated by other gateways and removes it from the replica, nil if not exist.
+func (r *sessionReplicator) load(token string) *resumeSession {
+	key := replicaKeySessionPrefix + token
+	b, err := r.replica.Get(key)
+	if err != nil {
+		log.E("load replicated session error: %v", err)
+		return nil
+	}
+	if b == nil {
+		return nil
+	}
+	rs := replicatedSession{}
+	if err = json.Unmarshal(b, &rs); err != nil || rs.ID == "" || rs.ID.IsTemp() {
+		log.E("invalid replicated session: %v", err)
+		return nil
+	}
+	// the token is used once.
+	if err = r.replica.Delete(key); err != nil {
+		log.E("delete replicated session error: %v", err)
+	}
+	return &resumeSession{id: rs.ID, token: token, credentials: rs.Credentials, replicated: true}
+}
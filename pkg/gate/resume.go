@@ -0,0 +1,333 @@
+package gate
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
+	"sync"
+	"time"
+)
+
+const (
+	defaultResumeWindow     = time.Minute * 2
+	defaultResumeBufferSize = 100
+
+	errResumeInvalid = "invalid or expired resume token"
+)
+
+// ResumeOptions the options of session resume.
+type ResumeOptions struct {
+	// Window the duration the session is kept after the connection lost, default 2 minutes.
+	Window time.Duration
+	// BufferSize the max count of messages buffered for the disconnected session, the oldest is dropped when full,
+	// default 100.
+	BufferSize int
+	// Replica the cache shared by gateways sessions are replicated to, the client resumes on another gateway after the
+	// gateway it connected crashed, nil to resume on this gateway only.
+	Replica SessionReplica
+	// ReplicaTTL the duration the replicated session is kept, default 24 hours.
+	ReplicaTTL time.Duration
+}
+
+// resumeTokenIssuer issues the resume token to the client authenticated.
+type resumeTokenIssuer interface {
+	issueResumeToken(id ID, credentials *ClientAuthCredentials) *messages.AuthResult
+}
+
+var _ resumeTokenIssuer = (*Impl)(nil)
+
+// resumeSession the session of an authenticated client, it's parked when the connection lost.
+type resumeSession struct {
+	id          ID
+	token       string
+	credentials *ClientAuthCredentials
+
+	// info the client info when parked, nil if the client is connected.
+	info   *Info
+	buffer []*messages.GlideMessage
+	expire *time.Timer
+	// replicated true if the session is loaded from the replica, it's not online on this gateway.
+	replicated bool
+}
+
+// sessionResumer keeps sessions of authenticated clients, the session of the client disconnected unexpectedly is
+// parked for the window, the client is kept online and messages to it are buffered until it resumes with the token
+// issued on authenticate, or the session goes offline when the window expires.
+type sessionResumer struct {
+	opts ResumeOptions
+
+	mu       sync.Mutex
+	tokens   map[string]*resumeSession
+	sessions map[ID]*resumeSession
+
+	// replicator replicates sessions for failover, nil if disabled.
+	replicator *sessionReplicator
+}
+
+func newSessionResumer(opts *ResumeOptions) *sessionResumer {
+	r := &sessionResumer{
+		opts:     *opts,
+		tokens:   map[string]*resumeSession{},
+		sessions: map[ID]*resumeSession{},
+	}
+	if r.opts.Window <= 0 {
+		r.opts.Window = defaultResumeWindow
+	}
+	if r.opts.BufferSize <= 0 {
+		r.opts.BufferSize = defaultResumeBufferSize
+	}
+	if r.opts.Replica != nil {
+		r.replicator = newSessionReplicator(r.opts.Replica, r.opts.ReplicaTTL)
+	}
+	return r
+}
+
+// issue issues a new token of the client id, the token issued before is revoked.
+func (r *sessionResumer) issue(id ID, credentials *ClientAuthCredentials) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := &resumeSession{id: id, token: base64.RawURLEncoding.EncodeToString(b), credentials: credentials}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remove(r.sessions[id])
+	r.sessions[id] = s
+	r.tokens[s.token] = s
+	if r.replicator != nil {
+		r.replicator.save(s)
+	}
+	return s.token, nil
+}
+
+// park parks the session of id, onExpire is called with the session when the window expires, returns false if id has
+// no session.
+func (r *sessionResumer) park(id ID, info *Info, onExpire func(s *resumeSession)) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[id]
+	if !ok {
+		return false
+	}
+	s.info = info
+	s.expire = time.AfterFunc(r.opts.Window, func() {
+		onExpire(s)
+	})
+	return true
+}
+
+// drop removes the session parked, returns false if it's resumed or removed.
+func (r *sessionResumer) drop(s *resumeSession) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sessions[s.id] != s {
+		return false
+	}
+	r.remove(s)
+	return true
+}
+
+// buffer buffers the message to the parked session of id, returns false if id is not parked.
+func (r *sessionResumer) buffer(id ID, m *messages.GlideMessage) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[id]
+	if !ok || s.info == nil {
+		return false
+	}
+	if len(s.buffer) >= r.opts.BufferSize {
+		s.buffer = s.buffer[1:]
+	}
+	s.buffer = append(s.buffer, m)
+	return true
+}
+
+// resume removes and returns the session of the token, the session replicated by other gateways is loaded if the token
+// is not issued by this gateway.
+func (r *sessionResumer) resume(token string) *resumeSession {
+	r.mu.Lock()
+	s, ok := r.tokens[token]
+	if ok {
+		r.remove(s)
+	}
+	r.mu.Unlock()
+	if !ok && r.replicator != nil {
+		s = r.replicator.load(token)
+	}
+	return s
+}
+
+// take removes and returns the session of id if it's parked, the session of the connected client is revoked.
+func (r *sessionResumer) take(id ID) *resumeSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[id]
+	if !ok {
+		return nil
+	}
+	r.remove(s)
+	if s.info == nil {
+		return nil
+	}
+	return s
+}
+
+func (r *sessionResumer) remove(s *resumeSession) {
+	if s == nil {
+		return
+	}
+	if s.expire != nil {
+		s.expire.Stop()
+	}
+	delete(r.tokens, s.token)
+	if r.sessions[s.id] == s {
+		delete(r.sessions, s.id)
+	}
+	if r.replicator != nil {
+		r.replicator.remove(s.token)
+	}
+}
+
+// EnableResume keeps the session of authenticated clients disconnected unexpectedly, the client is still online and
+// messages to it are buffered until it reconnects and resumes with messages.Resume, the token is issued in the
+// messages.AuthResult of authenticate. Clients exited by the server are not resumable. It must be called before
+// clients connected.
+func (c *Impl) EnableResume(opts *ResumeOptions) {
+	c.resumer = newSessionResumer(opts)
+	c.UseWithPriority(PriorityAuthenticate, c.resumeMiddleware)
+}
+
+// supportsResume returns true if the client of credentials declares nothing or declares the resume capability.
+func supportsResume(credentials *ClientAuthCredentials) bool {
+	info := Info{Capabilities: credentials.Capabilities}
+	return info.HasCapability(messages.CapabilityResume)
+}
+
+// issueResumeToken returns the result of authenticate with the resume token of id, nil if resume is disabled or the
+// client does not declare the resume capability.
+func (c *Impl) issueResumeToken(id ID, credentials *ClientAuthCredentials) *messages.AuthResult {
+	if c.resumer == nil || messages.IsDisabled(messages.CapabilityResume) || !supportsResume(credentials) {
+		return nil
+	}
+	id.SetGateway(c.id)
+	token, err := c.resumer.issue(id, credentials)
+	if err != nil {
+		log.E("issue resume token error: %v", err)
+		return nil
+	}
+	return &messages.AuthResult{ResumeToken: token, ResumeWindow: int(c.resumer.opts.Window / time.Second)}
+}
+
+// parkClient parks the session of the client disconnected, must be called with lock.
+func (c *Impl) parkClient(id ID, info Info) bool {
+	if c.resumer == nil || id.IsTemp() {
+		return false
+	}
+	parked := c.resumer.park(id, &info, func(s *resumeSession) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.resumer.drop(s) {
+			metrics.Resumes.WithLabelValues("expired").Inc()
+			c.clientOffline(id, *s.info)
+		}
+	})
+	if parked {
+		metrics.Resumes.WithLabelValues("parked").Inc()
+	}
+	return parked
+}
+
+// revokeSession revokes the session of id, the session parked goes offline, must be called with lock.
+func (c *Impl) revokeSession(id ID) {
+	if c.resumer == nil || id.IsTemp() {
+		return
+	}
+	if s := c.resumer.take(id); s != nil {
+		c.clientOffline(id, *s.info)
+	}
+}
+
+func (c *Impl) resumeMiddleware(cli Client, m *messages.GlideMessage) (bool, error) {
+	if m.GetAction() != messages.ActionResume {
+		return false, nil
+	}
+	r := messages.Resume{}
+	if err := m.Data.Deserialize(&r); err != nil {
+		return false, errors.New(errResumeInvalid)
+	}
+	result, buffered, err := c.resumeClient(cli, r.Token)
+	if err != nil {
+		metrics.Resumes.WithLabelValues("failed").Inc()
+		return false, err
+	}
+	metrics.Resumes.WithLabelValues("resumed").Inc()
+
+	// replays in order after the result, in compressed batches if the client supports.
+	_ = cli.EnqueueMessage(messages.NewMessage(m.GetSeq(), messages.ActionNotifySuccess, result))
+	c.pushRemoteConfig(cli)
+	if bw, ok := cli.(batchWriter); ok && len(buffered) > 0 {
+		_ = bw.EnqueueBatch(buffered)
+		return true, nil
+	}
+	for _, bm := range buffered {
+		_ = cli.EnqueueMessage(bm)
+	}
+	return true, nil
+}
+
+// resumeClient binds the temp client to the session of token, the connection of the session is replaced if it's not
+// detected lost yet. The client goes online without online notified as the session is kept online.
+func (c *Impl) resumeClient(cli Client, token string) (*messages.AuthResult, []*messages.GlideMessage, error) {
+	if info := cli.GetInfo(); !info.ID.IsTemp() {
+		return nil, nil, errors.New("already authenticated")
+	}
+	// the replicated session is loaded without lock.
+	s := c.resumer.resume(token)
+	if s == nil {
+		return nil, nil, errors.New(errResumeInvalid)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tempID := cli.GetInfo().ID
+	id := s.id
+	id.SetGateway(c.id)
+
+	old, online := c.clients[id]
+	if online && old != nil {
+		delete(c.clients, id)
+		old.SetID("")
+		old.Exit()
+		metrics.Disconnects.Inc()
+		metrics.Connections.Dec()
+	}
+
+	tempInfo := cli.GetInfo()
+	delete(c.clients, tempID)
+	delete(c.attributes, tempID)
+	c.countClient(tempID, -1)
+	c.removeSession(tempID)
+	c.msgHandler(&tempInfo, messages.NewMessage(0, messages.ActionInternalOffline, tempID))
+
+	cli.SetID(id)
+	c.clients[id] = cli
+	if dc, ok := cli.(DefaultClient); ok {
+		dc.SetCredentials(s.credentials)
+	}
+	c.registerSession(id)
+	if s.replicated && !online {
+		// the session replicated by the gateway crashed, the client goes online on this gateway.
+		c.countClient(id, 1)
+		info := cli.GetInfo()
+		c.msgHandler(&info, messages.NewMessage(0, messages.ActionInternalOnline, id))
+		c.events.Publish(&Event{Type: EventClientAuthenticated, Client: info, PreviousID: tempID})
+	}
+
+	result := &messages.AuthResult{Replayed: len(s.buffer), ResumeWindow: int(c.resumer.opts.Window / time.Second)}
+	result.ResumeToken, _ = c.resumer.issue(id, s.credentials)
+	return result, s.buffer, nil
+}
@@ -0,0 +1,149 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestImpl_Resume(t *testing.T) {
+	g, err := NewServer(&Options{ID: "gw", MaxMessageConcurrency: 1})
+	assert.NoError(t, err)
+	var offline []ID
+	g.SetMessageHandler(func(cliInfo *Info, message *messages.GlideMessage) {
+		if message.GetAction() == messages.ActionInternalOffline {
+			offline = append(offline, cliInfo.ID)
+		}
+	})
+	g.EnableResume(&ResumeOptions{Window: time.Millisecond * 100})
+
+	id := NewID("gw", "1", "")
+	old := &mockClient{info: Info{ID: NewID("gw", "tmp@1", "")}, running: true}
+	g.AddClient(old)
+	assert.NoError(t, g.SetClientID(old.info.ID, id))
+	result := g.issueResumeToken(id, &ClientAuthCredentials{UserID: "1"})
+	assert.NotEmpty(t, result.ResumeToken)
+
+	// the connection lost, the session is parked and messages are buffered.
+	old.running = false
+	assert.NoError(t, g.ExitClient(id))
+	offline = nil
+	assert.NoError(t, g.EnqueueMessage(id, messages.NewMessage(0, messages.ActionChatMessage, nil)))
+
+	cli := &recordClient{mockClient: mockClient{info: Info{ID: NewID("gw", "tmp@2", "")}, running: true}}
+	g.AddClient(cli)
+	resume := messages.NewMessage(1, messages.ActionResume, &messages.Resume{Token: result.ResumeToken})
+	handled, err := g.resumeMiddleware(cli, resume)
+	assert.True(t, handled)
+	assert.NoError(t, err)
+	assert.Equal(t, cli, g.GetClient(id))
+	assert.Equal(t, []ID{NewID("gw", "tmp@2", "")}, offline)
+	assert.Len(t, cli.received, 2)
+	assert.Equal(t, messages.ActionNotifySuccess, cli.received[0].GetAction())
+	assert.Equal(t, messages.ActionChatMessage, cli.received[1].GetAction())
+
+	// the token is used once.
+	_, err = g.resumeMiddleware(&mockClient{info: Info{ID: NewID("gw", "tmp@3", "")}}, resume)
+	assert.Error(t, err)
+}
+
+func TestImpl_ResumeExpired(t *testing.T) {
+	g, err := NewServer(&Options{ID: "gw", MaxMessageConcurrency: 1})
+	assert.NoError(t, err)
+	offline := make(chan ID, 4)
+	g.SetMessageHandler(func(cliInfo *Info, message *messages.GlideMessage) {
+		if message.GetAction() == messages.ActionInternalOffline {
+			offline <- cliInfo.ID
+		}
+	})
+	g.EnableResume(&ResumeOptions{Window: time.Millisecond * 10})
+
+	id := NewID("gw", "1", "")
+	cli := &mockClient{info: Info{ID: id}, running: true}
+	g.AddClient(cli)
+	result := g.issueResumeToken(id, &ClientAuthCredentials{UserID: "1"})
+
+	cli.running = false
+	assert.NoError(t, g.ExitClient(id))
+	select {
+	case o := <-offline:
+		assert.Equal(t, id, o)
+	case <-time.After(time.Second):
+		t.Fatal("session not expired")
+	}
+	_, _, err = g.resumeClient(&mockClient{info: Info{ID: NewID("gw", "tmp@2", "")}}, result.ResumeToken)
+	assert.Error(t, err)
+	assert.Error(t, g.EnqueueMessage(id, messages.NewMessage(0, messages.ActionChatMessage, nil)))
+}
+
+type mapReplica struct {
+	mu sync.Mutex
+	m  map[string][]byte
+}
+
+func (r *mapReplica) Get(key string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.m[key], nil
+}
+
+func (r *mapReplica) Set(key string, value []byte, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.m[key] = value
+	return nil
+}
+
+func (r *mapReplica) Delete(keys ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range keys {
+		delete(r.m, k)
+	}
+	return nil
+}
+
+func TestImpl_ResumeReplicated(t *testing.T) {
+	replica := &mapReplica{m: map[string][]byte{}}
+	g1, err := NewServer(&Options{ID: "gw1", MaxMessageConcurrency: 1})
+	assert.NoError(t, err)
+	g1.SetMessageHandler(mockMsgHandler)
+	g1.EnableResume(&ResumeOptions{Replica: replica})
+
+	id := NewID("gw1", "1", "")
+	g1.AddClient(&mockClient{info: Info{ID: id}, running: true})
+	result := g1.issueResumeToken(id, &ClientAuthCredentials{UserID: "1", Secrets: &ClientSecrets{MessageDeliverSecret: "s"}})
+	assert.Eventually(t, func() bool {
+		b, _ := replica.Get(replicaKeySessionPrefix + result.ResumeToken)
+		return b != nil
+	}, time.Second, time.Millisecond*10)
+
+	// the gateway crashed, the client resumes on another gateway.
+	g2, err := NewServer(&Options{ID: "gw2", MaxMessageConcurrency: 1})
+	assert.NoError(t, err)
+	var online []ID
+	g2.SetMessageHandler(func(cliInfo *Info, message *messages.GlideMessage) {
+		if message.GetAction() == messages.ActionInternalOnline {
+			online = append(online, cliInfo.ID)
+		}
+	})
+	g2.EnableResume(&ResumeOptions{Replica: replica})
+	cli := &recordClient{mockClient: mockClient{info: Info{ID: NewID("gw2", "tmp@1", "")}, running: true}}
+	g2.AddClient(cli)
+	online = nil
+
+	resume := messages.NewMessage(1, messages.ActionResume, &messages.Resume{Token: result.ResumeToken})
+	handled, err := g2.resumeMiddleware(cli, resume)
+	assert.True(t, handled)
+	assert.NoError(t, err)
+	resumed := NewID("gw2", "1", "")
+	assert.Equal(t, cli, g2.GetClient(resumed))
+	assert.Equal(t, []ID{resumed}, online)
+	assert.Equal(t, messages.ActionNotifySuccess, cli.received[0].GetAction())
+
+	// the token is used once.
+	_, err = g2.resumeMiddleware(&mockClient{info: Info{ID: NewID("gw2", "tmp@2", "")}}, resume)
+	assert.Error(t, err)
+}
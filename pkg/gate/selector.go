@@ -0,0 +1,165 @@
+package gate
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AttributeUpdater updates the attributes of clients, attributes are used to select clients to deliver, see Selector.
+type AttributeUpdater interface {
+
+	// SetClientAttributes merges the attributes to the client, the attribute with empty value is removed, all
+	// attributes are replaced if replace is true.
+	SetClientAttributes(id ID, attributes map[string]string, replace bool) error
+}
+
+const (
+	opEq = "="
+	opNe = "!="
+	opGt = ">"
+	opGe = ">="
+	opLt = "<"
+	opLe = "<="
+)
+
+// operators ordered by length to match the longest first.
+var selectorOps = []string{"==", opNe, opGe, opLe, opEq, opGt, opLt}
+
+var selectorAnd = regexp.MustCompile(`(?i)\s+and\s+|&&|,`)
+
+type condition struct {
+	key   string
+	op    string
+	value string
+}
+
+// Selector selects clients by attributes, such as `region=eu and app_version>=3.2`. Conditions are joined by `and`,
+// `&&` or `,`, operators are =, !=, >, >=, < and <=. Values are compared as versions if both are dotted numbers,
+// otherwise as strings. A client without the attribute matches the `!=` condition only.
+type Selector struct {
+	expr  string
+	conds []condition
+}
+
+// ParseSelector parses the selector expression, the empty expression selects all clients.
+func ParseSelector(expr string) (*Selector, error) {
+	s := &Selector{expr: strings.TrimSpace(expr)}
+	if s.expr == "" {
+		return s, nil
+	}
+	for _, part := range selectorAnd.Split(s.expr, -1) {
+		c, err := parseCondition(part)
+		if err != nil {
+			return nil, err
+		}
+		s.conds = append(s.conds, c)
+	}
+	return s, nil
+}
+
+func parseCondition(s string) (condition, error) {
+	for _, op := range selectorOps {
+		i := strings.Index(s, op)
+		if i < 0 {
+			continue
+		}
+		c := condition{
+			key:   strings.TrimSpace(s[:i]),
+			op:    op,
+			value: strings.Trim(strings.TrimSpace(s[i+len(op):]), `"'`),
+		}
+		if c.op == "==" {
+			c.op = opEq
+		}
+		if c.key == "" || strings.ContainsAny(c.key, "=!<>") {
+			break
+		}
+		return c, nil
+	}
+	return condition{}, errors.New("invalid selector condition: " + strings.TrimSpace(s))
+}
+
+// Match returns true if the attributes match all conditions.
+func (s *Selector) Match(attributes map[string]string) bool {
+	for _, c := range s.conds {
+		v, ok := attributes[c.key]
+		if !ok {
+			if c.op != opNe {
+				return false
+			}
+			continue
+		}
+		r := compareValue(v, c.value)
+		var matched bool
+		switch c.op {
+		case opEq:
+			matched = r == 0
+		case opNe:
+			matched = r != 0
+		case opGt:
+			matched = r > 0
+		case opGe:
+			matched = r >= 0
+		case opLt:
+			matched = r < 0
+		case opLe:
+			matched = r <= 0
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Empty returns true if the selector selects all clients.
+func (s *Selector) Empty() bool {
+	return len(s.conds) == 0
+}
+
+func (s *Selector) String() string {
+	return s.expr
+}
+
+// compareValue compares a and b as versions if both are dotted numbers, such as 3.2.1, otherwise as strings.
+func compareValue(a, b string) int {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	if !okA || !okB {
+		return strings.Compare(a, b)
+	}
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y int64
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func parseVersion(s string) ([]int64, bool) {
+	s = strings.TrimPrefix(s, "v")
+	if s == "" {
+		return nil, false
+	}
+	var ret []int64
+	for _, p := range strings.Split(s, ".") {
+		n, err := strconv.ParseInt(p, 10, 64)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		ret = append(ret, n)
+	}
+	return ret, true
+}
@@ -0,0 +1,55 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSelector_Match(t *testing.T) {
+	attrs := map[string]string{"region": "eu", "app_version": "3.10.1", "platform": "ios"}
+
+	cases := []struct {
+		expr    string
+		matched bool
+	}{
+		{"", true},
+		{"region=eu", true},
+		{"region == 'eu'", true},
+		{"region!=eu", false},
+		{"region=eu and app_version>=3.2", true},
+		{"region=eu && app_version<3.2", false},
+		{"app_version>3.10", true},
+		{"app_version<=3.10.1, platform=ios", true},
+		{"plan=pro", false},
+		{"plan!=pro", true},
+		{"platform>android", true},
+	}
+	for _, c := range cases {
+		s, err := ParseSelector(c.expr)
+		assert.NoError(t, err, c.expr)
+		assert.Equal(t, c.matched, s.Match(attrs), c.expr)
+	}
+
+	for _, expr := range []string{"region", "=eu", "region=eu &&"} {
+		_, err := ParseSelector(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestImpl_SetClientAttributes(t *testing.T) {
+	g, err := NewServer(&Options{ID: "gw", MaxMessageConcurrency: 1})
+	assert.NoError(t, err)
+	g.SetMessageHandler(func(cliInfo *Info, message *messages.GlideMessage) {})
+	g.AddClient(&mockClient{info: Info{ID: NewID("gw", "tmp@1", "")}, running: true})
+
+	assert.Error(t, g.SetClientAttributes(NewID("gw", "2", ""), map[string]string{"a": "1"}, false))
+	assert.NoError(t, g.SetClientAttributes(NewID("gw", "tmp@1", ""), map[string]string{"a": "1", "b": "2"}, false))
+	assert.NoError(t, g.SetClientAttributes(NewID("gw", "tmp@1", ""), map[string]string{"b": "", "c": "3"}, false))
+
+	assert.NoError(t, g.SetClientID(NewID("gw", "tmp@1", ""), NewID("gw", "1", "")))
+	assert.Equal(t, map[string]string{"a": "1", "c": "3"}, g.GetAll()[NewID("gw", "1", "")].Attributes)
+
+	assert.NoError(t, g.SetClientAttributes(NewID("gw", "1", ""), map[string]string{"d": "4"}, true))
+	assert.Equal(t, map[string]string{"d": "4"}, g.GetAll()[NewID("gw", "1", "")].Attributes)
+}
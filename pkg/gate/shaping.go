@@ -0,0 +1,86 @@
+package gate
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ShapeAllDevices the key of the egress rate of devices without the rate of their own.
+const ShapeAllDevices = "*"
+
+// EgressShaper caps the egress bytes per second of clients by the device type of client ID, such as background mobile
+// clients, rates can be replaced at runtime and applied to connected clients on their next write. Writes exceeding
+// the rate are delayed in the write loop, so messages pile up in the send queue and the OverflowPolicy applies.
+type EgressShaper struct {
+	// rates map[string]int64 the bytes per second by device.
+	rates atomic.Value
+}
+
+func NewEgressShaper() *EgressShaper {
+	s := &EgressShaper{}
+	s.rates.Store(map[string]int64{})
+	return s
+}
+
+// SetRates replaces the bytes per second by device type, the rate of ShapeAllDevices applies to devices not listed,
+// 0 is uncapped.
+func (s *EgressShaper) SetRates(rates map[string]int64) error {
+	m := make(map[string]int64, len(rates))
+	for device, rate := range rates {
+		if rate < 0 {
+			return errors.New("negative egress rate of device " + device)
+		}
+		if rate > 0 {
+			m[device] = rate
+		}
+	}
+	s.rates.Store(m)
+	return nil
+}
+
+// Rates returns the bytes per second by device type.
+func (s *EgressShaper) Rates() map[string]int64 {
+	rates := s.rates.Load().(map[string]int64)
+	m := make(map[string]int64, len(rates))
+	for k, v := range rates {
+		m[k] = v
+	}
+	return m
+}
+
+// rate returns the bytes per second of the device, 0 is uncapped.
+func (s *EgressShaper) rate(device string) int64 {
+	rates := s.rates.Load().(map[string]int64)
+	if r, ok := rates[device]; ok {
+		return r
+	}
+	return rates[ShapeAllDevices]
+}
+
+// tokenBucket the bucket of bytes refilled at rate per second, it holds one second of bytes at most. It's used by the
+// write loop of the client only.
+type tokenBucket struct {
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int64) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: float64(rate), last: time.Now()}
+}
+
+// take takes n bytes from the bucket, returns the duration to wait before writing if the bucket is in debt. The
+// message larger than the bucket is written after the debt is refilled.
+func (b *tokenBucket) take(n int, now time.Time) time.Duration {
+	b.tokens += now.Sub(b.last).Seconds() * float64(b.rate)
+	if b.tokens > float64(b.rate) {
+		b.tokens = float64(b.rate)
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(b.rate) * float64(time.Second))
+}
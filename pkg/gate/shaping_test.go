@@ -0,0 +1,62 @@
+package gate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEgressShaper_Rates(t *testing.T) {
+	s := NewEgressShaper()
+	assert.Zero(t, s.rate("1"))
+
+	assert.NoError(t, s.SetRates(map[string]int64{"1": 1024, ShapeAllDevices: 4096, "2": 0}))
+	assert.Equal(t, int64(1024), s.rate("1"))
+	// uncapped device is removed, the default applies.
+	assert.Equal(t, int64(4096), s.rate("2"))
+	assert.Equal(t, map[string]int64{"1": 1024, ShapeAllDevices: 4096}, s.Rates())
+
+	assert.Error(t, s.SetRates(map[string]int64{"1": -1}))
+	assert.Equal(t, int64(1024), s.rate("1"))
+}
+
+func TestTokenBucket_Take(t *testing.T) {
+	b := newTokenBucket(1000)
+	now := b.last
+	// the burst of one second is allowed.
+	assert.Zero(t, b.take(1000, now))
+	assert.Equal(t, time.Millisecond*500, b.take(500, now))
+	// refilled after the debt.
+	assert.Zero(t, b.take(100, now.Add(time.Millisecond*600)))
+	// the bucket holds one second at most.
+	assert.Zero(t, b.take(1000, now.Add(time.Hour)))
+	assert.Equal(t, time.Second, b.take(1000, now.Add(time.Hour)))
+}
+
+func TestUserClient_Shape(t *testing.T) {
+	shaper := NewEgressShaper()
+	c := &UserClient{
+		info:         &Info{ID: NewID("", "1", "3")},
+		config:       &ClientConfig{Shaper: shaper},
+		closeWriteCh: make(chan struct{}),
+	}
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		c.shape(1000)
+	}
+	assert.Less(t, time.Since(start), time.Millisecond*50)
+
+	_ = shaper.SetRates(map[string]int64{"3": 10000})
+	start = time.Now()
+	for i := 0; i < 12; i++ {
+		c.shape(1000)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*150)
+
+	// the client closed is not delayed.
+	close(c.closeWriteCh)
+	start = time.Now()
+	c.shape(100000)
+	assert.Less(t, time.Since(start), time.Millisecond*50)
+}
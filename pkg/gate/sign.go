@@ -0,0 +1,117 @@
+package gate
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
+)
+
+// PrioritySign the priority of the message sign verifier, it runs after the ticket verified.
+const PrioritySign = -900
+
+const (
+	errSignMissing  = "message sign is required"
+	errSignInvalid  = "invalid message sign"
+	errSignNoSecret = "no message deliver secret"
+)
+
+// Reasons of sign failures counted by metrics.SignFailures.
+const (
+	signFailureMissing  = "missing"
+	signFailureInvalid  = "invalid"
+	signFailureNoSecret = "no_secret"
+)
+
+// SignMessage returns the sign of the message sent to the target, it's the hex of
+// HMAC-SHA256(secret, to\nseq\nbody), the secret is the message deliver secret of the sender, see ClientSecrets, the
+// body is returned by SignedBody.
+func SignMessage(secret string, to string, seq int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(to + "\n" + strconv.FormatInt(seq, 10) + "\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignedBody returns the bytes of the message data covered by the sign, the raw payload of the binary data, otherwise
+// the json of the data as sent by the client, including the json mixes $binary with other fields.
+func SignedBody(m *messages.GlideMessage) []byte {
+	if m.Data == nil {
+		return nil
+	}
+	if b, ok := m.Data.Binary(); ok {
+		return b.Data
+	}
+	body, err := m.Data.MarshalJSON()
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+// VerifyMessageSign verifies the sign of the message by the secret in constant time, the sign binds the target, so a
+// signed message cannot be forwarded to another target.
+func VerifyMessageSign(secret string, m *messages.GlideMessage) bool {
+	expect := SignMessage(secret, m.To, m.GetSeq(), SignedBody(m))
+	return hmac.Equal([]byte(strings.ToLower(m.Sign)), []byte(expect))
+}
+
+// SignVerifierOptions the options of the message sign verifier.
+type SignVerifierOptions struct {
+	// Required rejects the message without sign, otherwise only messages with sign are verified.
+	Required bool
+	// Actions the actions of messages verified, default chat, group and resend messages.
+	Actions []messages.Action
+}
+
+// NewSignVerifier returns the Middleware verifies the sign of messages from authenticated clients, see SignMessage.
+// The message with forged target or body is rejected.
+func NewSignVerifier(opts *SignVerifierOptions) Middleware {
+	actions := opts.Actions
+	if len(actions) == 0 {
+		actions = []messages.Action{messages.ActionChatMessage, messages.ActionGroupMessage, messages.ActionChatMessageResend}
+	}
+	verified := map[string]bool{}
+	for _, a := range actions {
+		verified[string(a)] = true
+	}
+	required := opts.Required
+
+	return func(c Client, m *messages.GlideMessage) (bool, error) {
+		if !verified[m.Action] {
+			return false, nil
+		}
+		dc, ok := c.(DefaultClient)
+		if !ok || dc.GetCredentials() == nil {
+			return false, nil
+		}
+		if m.Sign == "" {
+			if !required {
+				return false, nil
+			}
+			metrics.SignFailures.WithLabelValues(signFailureMissing).Inc()
+			return false, errors.New(errSignMissing)
+		}
+		secrets := dc.GetCredentials().Secrets
+		if secrets == nil || secrets.MessageDeliverSecret == "" {
+			metrics.SignFailures.WithLabelValues(signFailureNoSecret).Inc()
+			return false, errors.New(errSignNoSecret)
+		}
+		if !VerifyMessageSign(secrets.MessageDeliverSecret, m) {
+			metrics.SignFailures.WithLabelValues(signFailureInvalid).Inc()
+			log.I("invalid sign of message %s from %s to %s", m.Action, c.GetInfo().ID, m.To)
+			return false, errors.New(errSignInvalid)
+		}
+		return false, nil
+	}
+}
+
+// SetSignVerification enables the verification of message sign, see NewSignVerifier.
+func (c *Impl) SetSignVerification(opts *SignVerifierOptions) {
+	c.UseWithPriority(PrioritySign, NewSignVerifier(opts))
+}
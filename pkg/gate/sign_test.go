@@ -0,0 +1,53 @@
+package gate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func signedMessage(t *testing.T, secret string, to string, signTo string) *messages.GlideMessage {
+	body := `{"content":"hi"}`
+	m := &messages.GlideMessage{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"seq":7,"action":"message.chat","to":"`+to+`","data":`+body+`}`), m))
+	m.Sign = SignMessage(secret, signTo, 7, []byte(body))
+	return m
+}
+
+func TestSignVerifier(t *testing.T) {
+	verify := NewSignVerifier(&SignVerifierOptions{Required: true})
+	c := &credClient{cred: &ClientAuthCredentials{UserID: "1", Secrets: &ClientSecrets{MessageDeliverSecret: "secret"}}}
+
+	_, err := verify(c, signedMessage(t, "secret", "2", "2"))
+	assert.NoError(t, err)
+
+	// the target is forged
+	_, err = verify(c, signedMessage(t, "secret", "3", "2"))
+	assert.EqualError(t, err, errSignInvalid)
+	_, err = verify(c, signedMessage(t, "other", "2", "2"))
+	assert.EqualError(t, err, errSignInvalid)
+
+	m := signedMessage(t, "secret", "2", "2")
+	m.Sign = ""
+	_, err = verify(c, m)
+	assert.EqualError(t, err, errSignMissing)
+	_, err = NewSignVerifier(&SignVerifierOptions{})(c, m)
+	assert.NoError(t, err)
+
+	// actions not verified
+	_, err = verify(c, messages.NewMessage(1, messages.ActionHeartbeat, nil))
+	assert.NoError(t, err)
+}
+
+func TestSignedBody_Binary(t *testing.T) {
+	m := &messages.GlideMessage{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"seq":7,"action":"message.chat","to":"2","data":{"$binary":"AAH/"}}`), m))
+	assert.Equal(t, []byte{0, 1, 0xff}, SignedBody(m))
+
+	// the fields besides the binary are signed.
+	body := `{"$binary":"AAH/","content":"hi"}`
+	assert.NoError(t, json.Unmarshal([]byte(`{"seq":7,"action":"message.chat","to":"2","data":`+body+`}`), m))
+	assert.Equal(t, []byte(body), SignedBody(m))
+}
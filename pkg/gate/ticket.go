@@ -0,0 +1,180 @@
+package gate
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/glide-im/glide/pkg/hash"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultReplayWindow = time.Minute * 5
+
+const (
+	errTicketInvalid  = "invalid ticket"
+	errTicketExpired  = "ticket expired"
+	errTicketReplayed = "ticket replayed"
+)
+
+// IsTicketReplayed returns true if the error is caused by the nonce of the ticket has been used.
+func IsTicketReplayed(err error) bool {
+	return err != nil && err.Error() == errTicketReplayed
+}
+
+// TicketKey returns the ticket of the sender to deliver message to the receiver, which is issued by the business
+// service to the client. It's sent in message directly as the legacy ticket, or used as the key to sign each message
+// by SignTicket.
+func TicketKey(secret string, from string, to string) string {
+	return hash.SHA1(secret + from + hash.SHA1(secret+to))
+}
+
+// BypassTicketKey returns the ticket used as TicketKey, which additionally allows the sender to deliver message to the
+// receiver regardless of relation requirements such as contacts, it's issued by the business service for approved
+// sends, e.g. customer support.
+func BypassTicketKey(secret string, from string, to string) string {
+	return hash.SHA1(secret + from + hash.SHA1(secret+to) + "bypass")
+}
+
+// SignedTicket the replay protected ticket in format `timestamp.nonce.signature`, the timestamp is unix millisecond,
+// the nonce must be unique in replay window and must not contain '.', the signature is the hex of
+// HMAC-SHA256(TicketKey, from\nto\ntimestamp\nnonce).
+type SignedTicket struct {
+	Timestamp int64
+	Nonce     string
+	Signature string
+	// Bypass true if the ticket is signed with BypassTicketKey, set by VerifyTicket.
+	Bypass bool
+}
+
+// SignTicket signs the ticket of a message with the key returned by TicketKey.
+func SignTicket(key string, from string, to string, timestamp int64, nonce string) string {
+	return strconv.FormatInt(timestamp, 10) + "." + nonce + "." + ticketSignature(key, from, to, timestamp, nonce)
+}
+
+// ParseSignedTicket parses the ticket signed by SignTicket.
+func ParseSignedTicket(ticket string) (*SignedTicket, error) {
+	parts := strings.SplitN(ticket, ".", 3)
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return nil, errors.New(errTicketInvalid)
+	}
+	ts, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, errors.New(errTicketInvalid)
+	}
+	return &SignedTicket{Timestamp: ts, Nonce: parts[1], Signature: parts[2]}, nil
+}
+
+// VerifyTicket verifies the signature of the signed ticket of message sent from the sender to the receiver, the
+// timestamp and nonce are not checked, use ReplayGuard to reject expired and replayed tickets.
+func VerifyTicket(secret string, from string, to string, ticket string) (*SignedTicket, error) {
+	t, err := ParseSignedTicket(ticket)
+	if err != nil {
+		return nil, err
+	}
+	signature := []byte(strings.ToLower(t.Signature))
+	expect := ticketSignature(TicketKey(secret, from, to), from, to, t.Timestamp, t.Nonce)
+	if hmac.Equal(signature, []byte(expect)) {
+		return t, nil
+	}
+	expect = ticketSignature(BypassTicketKey(secret, from, to), from, to, t.Timestamp, t.Nonce)
+	if hmac.Equal(signature, []byte(expect)) {
+		t.Bypass = true
+		return t, nil
+	}
+	return nil, errors.New(errTicketInvalid)
+}
+
+func ticketSignature(key string, from string, to string, timestamp int64, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(from + "\n" + to + "\n" + strconv.FormatInt(timestamp, 10) + "\n" + nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type replayEntry struct {
+	key string
+	at  time.Time
+}
+
+// NonceStore remembers used nonces of tickets shared by gateways of the cluster, such as RedisNonceStore.
+type NonceStore interface {
+
+	// Remember remembers the key for ttl, returns false if the key is already remembered.
+	Remember(key string, ttl time.Duration) (bool, error)
+}
+
+// ReplayGuard rejects signed tickets whose timestamp is out of the window, and remembers nonces in a sliding window
+// to reject tickets used more than once. Nonces are remembered in memory of the node unless a NonceStore is set, the
+// ticket replayed to another node is accepted then.
+type ReplayGuard struct {
+	mu     sync.Mutex
+	window time.Duration
+	nonces map[string]*list.Element
+	// order of nonces by time, the front is the oldest.
+	order *list.List
+	// store remembers nonces instead if not nil.
+	store NonceStore
+}
+
+// NewReplayGuard creates the ReplayGuard, window is the max clock difference between the ticket and the server,
+// default 5 minutes. Nonces are remembered in store if not nil.
+func NewReplayGuard(window time.Duration, store NonceStore) *ReplayGuard {
+	if window <= 0 {
+		window = defaultReplayWindow
+	}
+	return &ReplayGuard{
+		window: window,
+		nonces: map[string]*list.Element{},
+		order:  list.New(),
+		store:  store,
+	}
+}
+
+// Check returns error if the ticket of the sender is expired or replayed, otherwise the nonce is remembered.
+func (r *ReplayGuard) Check(from string, t *SignedTicket) error {
+	now := time.Now()
+	diff := now.Sub(time.UnixMilli(t.Timestamp))
+	if diff > r.window || diff < -r.window {
+		return errors.New(errTicketExpired)
+	}
+
+	key := from + "\x00" + t.Nonce
+	if r.store != nil {
+		// the ticket is expired after twice the window, see expire.
+		ok, err := r.store.Remember(key, r.window*2)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New(errTicketReplayed)
+		}
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.expire(now)
+	if _, ok := r.nonces[key]; ok {
+		return errors.New(errTicketReplayed)
+	}
+	r.nonces[key] = r.order.PushBack(&replayEntry{key: key, at: now})
+	return nil
+}
+
+// expire removes nonces remembered before twice the window, the ticket of them is expired as the timestamp is at most
+// one window ahead of the time remembered.
+func (r *ReplayGuard) expire(now time.Time) {
+	for e := r.order.Front(); e != nil; e = r.order.Front() {
+		entry := e.Value.(*replayEntry)
+		if now.Sub(entry.at) < r.window*2 {
+			return
+		}
+		r.order.Remove(e)
+		delete(r.nonces, entry.key)
+	}
+}
@@ -0,0 +1,115 @@
+package gate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const defaultTicketProviderTimeout = time.Second * 3
+
+// TicketUpdater updates the ticket secret of live clients, see ClientSecrets.MessageDeliverSecret. The business
+// service rotates the secret to invalidate tickets issued before.
+type TicketUpdater interface {
+
+	// UpdateClientTicket sets the message deliver secret of authenticated clients of the uid, clients of all devices
+	// if device is empty. It returns the count of clients updated, ErrClientNotExist if no client online.
+	UpdateClientTicket(uid string, device string, secret string) (int, error)
+}
+
+// TicketProvider provides the message deliver secret of the client authenticating with the credentials without one,
+// so the business service needn't put the secret in credentials.
+type TicketProvider interface {
+	TicketSecret(uid string, device string) (string, error)
+}
+
+// TicketProviderFunc adapts the function to TicketProvider.
+type TicketProviderFunc func(uid string, device string) (string, error)
+
+func (f TicketProviderFunc) TicketSecret(uid string, device string) (string, error) {
+	return f(uid, device)
+}
+
+type httpTicketProvider struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPTicketProvider returns the TicketProvider fetches the secret from the business service by
+// GET url?uid=&device=, the response body is {"secret": ""}. The timeout is 3s if not positive.
+func NewHTTPTicketProvider(url string, timeout time.Duration) TicketProvider {
+	if timeout <= 0 {
+		timeout = defaultTicketProviderTimeout
+	}
+	return &httpTicketProvider{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (h *httpTicketProvider) TicketSecret(uid string, device string) (string, error) {
+	q := url.Values{}
+	q.Set("uid", uid)
+	q.Set("device", device)
+	resp, err := h.client.Get(h.url + "?" + q.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	r := struct {
+		Secret string `json:"secret"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", err
+	}
+	return r.Secret, nil
+}
+
+var _ TicketUpdater = (*Impl)(nil)
+
+// SetTicketProvider sets the provider of message deliver secret of clients authenticating without one, nil to not
+// fetch. It does nothing if the gateway has no secret key.
+func (c *Impl) SetTicketProvider(p TicketProvider) {
+	if c.authenticator != nil {
+		c.authenticator.SetTicketProvider(p)
+	}
+}
+
+func (c *Impl) UpdateClientTicket(uid string, device string, secret string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for id, cli := range c.clients {
+		if id.IsTemp() || id.UID() != uid || (device != "" && id.Device() != device) {
+			continue
+		}
+		if dc, ok := cli.(DefaultClient); ok {
+			setDeliverSecret(dc, secret)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, ErrClientNotExist
+	}
+	log.D("ticket secret of %d clients of %s updated", n, uid)
+	return n, nil
+}
+
+// setDeliverSecret replaces the credentials of the client with the copy of the message deliver secret set, the
+// credentials read by the client concurrently are not modified.
+func setDeliverSecret(dc DefaultClient, secret string) {
+	credentials := ClientAuthCredentials{}
+	secrets := ClientSecrets{}
+	if c := dc.GetCredentials(); c != nil {
+		credentials = *c
+		if c.Secrets != nil {
+			secrets = *c.Secrets
+		}
+	}
+	secrets.MessageDeliverSecret = secret
+	credentials.Secrets = &secrets
+	dc.SetCredentials(&credentials)
+}
@@ -0,0 +1,61 @@
+package gate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImpl_UpdateClientTicket(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+	phone := &credClient{mockClient: mockClient{info: Info{ID: NewID("g1", "1", "phone")}, running: true}}
+	phone.cred = &ClientAuthCredentials{UserID: "1", Secrets: &ClientSecrets{MessageDeliverSecret: "old", OnlineStateSecret: "online"}}
+	pc := &credClient{mockClient: mockClient{info: Info{ID: NewID("g1", "1", "pc")}, running: true}}
+	gateway.AddClient(phone)
+	gateway.AddClient(pc)
+
+	n, err := gateway.UpdateClientTicket("1", "phone", "new")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "new", phone.cred.Secrets.MessageDeliverSecret)
+	assert.Equal(t, "online", phone.cred.Secrets.OnlineStateSecret)
+	assert.Nil(t, pc.cred)
+
+	n, err = gateway.UpdateClientTicket("1", "", "newer")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "newer", pc.cred.Secrets.MessageDeliverSecret)
+
+	_, err = gateway.UpdateClientTicket("2", "", "new")
+	assert.ErrorIs(t, err, ErrClientNotExist)
+}
+
+func TestAuthenticator_TicketProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"secret":"` + r.URL.Query().Get("uid") + "_" + r.URL.Query().Get("device") + `"}`))
+	}))
+	defer srv.Close()
+
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+	auth := NewAuthenticator(gateway, "secret")
+	auth.SetTicketProvider(NewHTTPTicketProvider(srv.URL, 0))
+
+	c1 := &credClient{mockClient: mockClient{info: Info{ID: NewID("g1", "", "c1")}, running: true}}
+	gateway.AddClient(c1)
+	_, err = auth.updateClient(c1, &ClientAuthCredentials{UserID: "1", DeviceID: "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1_2", c1.cred.Secrets.MessageDeliverSecret)
+
+	// the secret in credentials is kept
+	c2 := &credClient{mockClient: mockClient{info: Info{ID: NewID("g1", "", "c2")}, running: true}}
+	gateway.AddClient(c2)
+	_, err = auth.updateClient(c2, &ClientAuthCredentials{UserID: "3", Secrets: &ClientSecrets{MessageDeliverSecret: "s"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "s", c2.cred.Secrets.MessageDeliverSecret)
+}
@@ -0,0 +1,85 @@
+package gate
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyTicket(t *testing.T) {
+	key := TicketKey("secret", "1", "2")
+	now := time.Now().UnixMilli()
+	ticket := SignTicket(key, "1", "2", now, "n1")
+
+	st, err := VerifyTicket("secret", "1", "2", ticket)
+	assert.NoError(t, err)
+	assert.Equal(t, now, st.Timestamp)
+	assert.Equal(t, "n1", st.Nonce)
+
+	_, err = VerifyTicket("secret", "1", "3", ticket)
+	assert.Error(t, err)
+	_, err = VerifyTicket("secret", "1", "2", "bad")
+	assert.Error(t, err)
+}
+
+func TestReplayGuard_Check(t *testing.T) {
+	g := NewReplayGuard(time.Minute, nil)
+	now := time.Now().UnixMilli()
+
+	assert.NoError(t, g.Check("1", &SignedTicket{Timestamp: now, Nonce: "a"}))
+	assert.True(t, IsTicketReplayed(g.Check("1", &SignedTicket{Timestamp: now, Nonce: "a"})))
+	assert.NoError(t, g.Check("2", &SignedTicket{Timestamp: now, Nonce: "a"}))
+
+	expired := time.Now().Add(-time.Minute * 2).UnixMilli()
+	assert.Error(t, g.Check("1", &SignedTicket{Timestamp: expired, Nonce: "b"}))
+}
+
+func TestAuthenticator_VerifyTicket(t *testing.T) {
+	a := NewAuthenticator(nil, "key")
+	legacy := TicketKey("secret", "1", "2")
+	signed := SignTicket(legacy, "1", "2", time.Now().UnixMilli(), strconv.Itoa(1))
+
+	bypass, err := a.verifyTicket("secret", "1", "2", legacy)
+	assert.NoError(t, err)
+	assert.False(t, bypass)
+	bypass, err = a.verifyTicket("secret", "1", "2", BypassTicketKey("secret", "1", "2"))
+	assert.NoError(t, err)
+	assert.True(t, bypass)
+
+	a.SetReplayProtection(time.Minute, true, nil)
+	_, err = a.verifyTicket("secret", "1", "2", signed)
+	assert.NoError(t, err)
+	_, err = a.verifyTicket("secret", "1", "2", signed)
+	assert.Error(t, err)
+	_, err = a.verifyTicket("secret", "1", "2", legacy)
+	assert.Error(t, err)
+
+	signed = SignTicket(BypassTicketKey("secret", "1", "2"), "1", "2", time.Now().UnixMilli(), strconv.Itoa(2))
+	bypass, err = a.verifyTicket("secret", "1", "2", signed)
+	assert.NoError(t, err)
+	assert.True(t, bypass)
+}
+
+// mapNonceStore remembers nonces in map as the shared store.
+type mapNonceStore map[string]time.Duration
+
+func (m mapNonceStore) Remember(key string, ttl time.Duration) (bool, error) {
+	if _, ok := m[key]; ok {
+		return false, nil
+	}
+	m[key] = ttl
+	return true, nil
+}
+
+func TestReplayGuard_Store(t *testing.T) {
+	nonces := mapNonceStore{}
+	g1 := NewReplayGuard(time.Minute, nonces)
+	g2 := NewReplayGuard(time.Minute, nonces)
+	now := time.Now().UnixMilli()
+
+	assert.NoError(t, g1.Check("1", &SignedTicket{Timestamp: now, Nonce: "a"}))
+	// replayed to another node.
+	assert.True(t, IsTicketReplayed(g2.Check("1", &SignedTicket{Timestamp: now, Nonce: "a"})))
+	assert.Equal(t, time.Minute*2, nonces["1\x00a"])
+}
@@ -0,0 +1,161 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/audit"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWatchdogInterval = time.Second * 5
+	defaultStallThreshold   = time.Second * 10
+)
+
+// Reasons of the slow client.
+const (
+	// SlowReasonStall the write to connection is blocked longer than the threshold.
+	SlowReasonStall = "write_stall"
+	// SlowReasonResidence the message waited in the send queue longer than the threshold.
+	SlowReasonResidence = "queue_residence"
+)
+
+// WriteStats is the write statistics of a client.
+type WriteStats struct {
+	// QueueDepth the count of messages waiting in the send queue.
+	QueueDepth int64
+	// Stall how long the in-progress write has been blocked, 0 if no write in progress.
+	Stall time.Duration
+	// LastWriteLatency the duration of the last write to connection.
+	LastWriteLatency time.Duration
+	// LastResidence the duration the last written message waited in the send queue.
+	LastResidence time.Duration
+}
+
+// WriteStater reports the write statistics of the client, implemented by UserClient.
+type WriteStater interface {
+	WriteStats() WriteStats
+}
+
+type WatchdogOptions struct {
+	// Interval the interval of checking clients, default 5s.
+	Interval time.Duration
+	// StallThreshold the client is slow when a write is blocked longer than it, default 10s.
+	StallThreshold time.Duration
+	// ResidenceThreshold the client is slow when a message waited in queue longer than it, 0 to disable.
+	ResidenceThreshold time.Duration
+	// Evict true to disconnect the slow client, otherwise it's only reported.
+	Evict bool
+}
+
+// SlowClient is the client flagged by Watchdog.
+type SlowClient struct {
+	ID     ID
+	Reason string
+	Stats  WriteStats
+}
+
+// Watchdog periodically checks the write statistics of clients, flags the clients whose writes stall or whose
+// messages wait in queue too long, and optionally disconnects them to protect gateway memory from slow readers.
+type Watchdog struct {
+	gateway DefaultGateway
+	opts    WatchdogOptions
+
+	mu   sync.Mutex
+	slow []SlowClient
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewWatchdog(gateway DefaultGateway, opts *WatchdogOptions) *Watchdog {
+	o := *opts
+	if o.Interval <= 0 {
+		o.Interval = defaultWatchdogInterval
+	}
+	if o.StallThreshold <= 0 {
+		o.StallThreshold = defaultStallThreshold
+	}
+	return &Watchdog{
+		gateway: gateway,
+		opts:    o,
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start runs the check periodically in a new goroutine.
+func (w *Watchdog) Start() {
+	go func() {
+		ticker := time.NewTicker(w.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.Check()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (w *Watchdog) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}
+
+// Check checks all clients once, returns the slow clients, they are disconnected if WatchdogOptions.Evict is true.
+func (w *Watchdog) Check() []SlowClient {
+	var slow []SlowClient
+	for id := range w.gateway.GetAll() {
+		ws, ok := w.gateway.GetClient(id).(WriteStater)
+		if !ok {
+			continue
+		}
+		stats := ws.WriteStats()
+		reason := w.reasonOf(stats)
+		if reason == "" {
+			continue
+		}
+		slow = append(slow, SlowClient{ID: id, Reason: reason, Stats: stats})
+		metrics.SlowClients.WithLabelValues(reason, strconv.FormatBool(w.opts.Evict)).Inc()
+		log.W("[watchdog] slow client %s, reason=%s, stats=%+v", id, reason, stats)
+		if w.opts.Evict {
+			w.evict(id, reason)
+		}
+	}
+	w.mu.Lock()
+	w.slow = slow
+	w.mu.Unlock()
+	return slow
+}
+
+// Slow returns the slow clients flagged by the last check.
+func (w *Watchdog) Slow() []SlowClient {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]SlowClient{}, w.slow...)
+}
+
+func (w *Watchdog) reasonOf(stats WriteStats) string {
+	if stats.Stall >= w.opts.StallThreshold {
+		return SlowReasonStall
+	}
+	if w.opts.ResidenceThreshold > 0 && stats.QueueDepth > 0 && stats.LastResidence >= w.opts.ResidenceThreshold {
+		return SlowReasonResidence
+	}
+	return ""
+}
+
+func (w *Watchdog) evict(id ID, reason string) {
+	kickOut := &messages.KickOutNotify{Code: messages.KickOutCodeSlowClient, Reason: reason}
+	_ = w.gateway.EnqueueMessage(id, messages.NewMessage(0, messages.ActionNotifyKickOut, kickOut))
+	audit.Record(audit.EventKick, "", string(id), map[string]string{"reason": "slow client: " + reason})
+	err := w.gateway.ExitClient(id)
+	if err != nil {
+		log.E("[watchdog] exit slow client %s error: %v", id, err)
+	}
+}
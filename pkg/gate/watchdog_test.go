@@ -0,0 +1,44 @@
+package gate
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWatchdog_Check(t *testing.T) {
+	gateway, err := NewServer(&Options{ID: "g1", MaxMessageConcurrency: 10})
+	assert.NoError(t, err)
+	gateway.SetMessageHandler(mockMsgHandler)
+
+	fn, _ := mockReadFn()
+	client := NewClientWithConfig(&mockConnection{
+		writeDelayMilliSec: 500,
+		mockRead:           fn,
+	}, gateway, mockMsgHandler, &ClientConfig{
+		ClientHeartbeatDuration: defaultHeartbeatDuration,
+		ServerHeartbeatDuration: defaultServerHeartbeatDuration,
+		CloseImmediately:        true,
+	})
+	client.SetID(NewID2("1"))
+	gateway.AddClient(client)
+	gateway.AddClient(&mockClient{info: Info{ID: NewID2("2")}, running: true})
+	client.Run()
+
+	watchdog := NewWatchdog(gateway, &WatchdogOptions{StallThreshold: time.Millisecond * 100})
+	assert.Empty(t, watchdog.Check())
+
+	_ = client.EnqueueMessage(messages.NewMessage(1, messages.ActionHeartbeat, nil))
+	time.Sleep(time.Millisecond * 200)
+
+	slow := watchdog.Check()
+	assert.Len(t, slow, 1)
+	assert.Equal(t, SlowReasonStall, slow[0].Reason)
+	assert.True(t, client.IsRunning())
+
+	watchdog.opts.Evict = true
+	assert.Len(t, watchdog.Check(), 1)
+	assert.False(t, client.IsRunning())
+	assert.Nil(t, gateway.GetClient(NewID("g1", "1", "")))
+}
@@ -0,0 +1,202 @@
+// Package health serves the liveness and readiness endpoints for probes such as Kubernetes, reporting the status of
+// dependencies checked by plugins, the count of connections and the drain state.
+//
+//	GET /healthz  200 if the process is alive, with the status report
+//	GET /readyz   200 if ready to accept connections, 503 if draining or any required dependency is unhealthy
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultTimeout = time.Second * 2
+
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+const errCheckTimeout = "check timeout"
+
+// Check checks a dependency, returns nil if healthy.
+type Check func() error
+
+// Dependency the status of a dependency.
+type Dependency struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	// Required the service is not ready if the required dependency is unhealthy, otherwise it's degraded.
+	Required bool   `json:"required"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report the body of endpoints.
+type Report struct {
+	Status       string        `json:"status"`
+	Connections  int           `json:"connections"`
+	Draining     bool          `json:"draining"`
+	Dependencies []*Dependency `json:"dependencies"`
+}
+
+type Options struct {
+	// Addr the address of the http server listen on.
+	Addr string
+	// Timeout the max duration of checking a dependency, default 2s.
+	Timeout time.Duration
+	// Connections returns the count of client connections, optional.
+	Connections func() int
+}
+
+type plugin struct {
+	check    Check
+	required bool
+}
+
+// Server serves health endpoints, dependencies are checked concurrently on every request.
+type Server struct {
+	addr        string
+	timeout     time.Duration
+	connections func() int
+	mux         *http.ServeMux
+
+	mu       sync.RWMutex
+	plugins  map[string]*plugin
+	draining int32
+}
+
+func NewServer(opts *Options) *Server {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ret := &Server{
+		addr:        opts.Addr,
+		timeout:     timeout,
+		connections: opts.Connections,
+		mux:         http.NewServeMux(),
+		plugins:     map[string]*plugin{},
+	}
+	ret.mux.HandleFunc("/healthz", ret.handleHealthz)
+	ret.mux.HandleFunc("/readyz", ret.handleReadyz)
+	return ret
+}
+
+// Register adds the check plugin of the dependency, the service is not ready when the required dependency is
+// unhealthy.
+func (s *Server) Register(name string, check Check, required bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plugins[name] = &plugin{check: check, required: required}
+}
+
+// SetDraining marks the service draining, it's not ready while draining so that no new connections are routed to.
+func (s *Server) SetDraining(draining bool) {
+	var v int32
+	if draining {
+		v = 1
+	}
+	atomic.StoreInt32(&s.draining, v)
+}
+
+func (s *Server) Draining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// Check checks all dependencies and returns the report.
+func (s *Server) Check() *Report {
+	s.mu.RLock()
+	plugins := make(map[string]*plugin, len(s.plugins))
+	for name, p := range s.plugins {
+		plugins[name] = p
+	}
+	s.mu.RUnlock()
+
+	deps := make([]*Dependency, 0, len(plugins))
+	wg := sync.WaitGroup{}
+	for name, p := range plugins {
+		d := &Dependency{Name: name, Required: p.required}
+		deps = append(deps, d)
+		wg.Add(1)
+		go func(p *plugin) {
+			defer wg.Done()
+			if err := s.check(p.check); err != nil {
+				d.Error = err.Error()
+			} else {
+				d.Healthy = true
+			}
+		}(p)
+	}
+	wg.Wait()
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Name < deps[j].Name
+	})
+
+	r := &Report{Status: StatusOK, Draining: s.Draining(), Dependencies: deps}
+	if s.connections != nil {
+		r.Connections = s.connections()
+	}
+	for _, d := range deps {
+		if d.Healthy {
+			continue
+		}
+		if d.Required {
+			r.Status = StatusUnavailable
+			break
+		}
+		r.Status = StatusDegraded
+	}
+	if r.Draining && r.Status != StatusUnavailable {
+		r.Status = StatusDraining
+	}
+	return r
+}
+
+func (s *Server) check(c Check) error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- c()
+	}()
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	select {
+	case err := <-ch:
+		return err
+	case <-timer.C:
+		return errors.New(errCheckTimeout)
+	}
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Check())
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := s.Check()
+	status := http.StatusOK
+	if report.Status == StatusUnavailable || report.Status == StatusDraining {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) Run() error {
+	return http.ListenAndServe(s.addr, s)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
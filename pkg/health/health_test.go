@@ -0,0 +1,66 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func get(s *Server, path string) (int, *Report) {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	r := &Report{}
+	_ = json.NewDecoder(rec.Body).Decode(r)
+	return rec.Code, r
+}
+
+func TestServer(t *testing.T) {
+	var storeErr, cacheErr error
+	s := NewServer(&Options{Timeout: time.Millisecond * 50, Connections: func() int { return 3 }})
+	s.Register("store", func() error { return storeErr }, true)
+	s.Register("cache", func() error { return cacheErr }, false)
+
+	code, r := get(s, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, r.Status)
+	assert.Equal(t, 3, r.Connections)
+	assert.Len(t, r.Dependencies, 2)
+	assert.Equal(t, "cache", r.Dependencies[0].Name)
+
+	cacheErr = errors.New("down")
+	code, r = get(s, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusDegraded, r.Status)
+	assert.Equal(t, "down", r.Dependencies[0].Error)
+
+	storeErr = errors.New("down")
+	code, r = get(s, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusUnavailable, r.Status)
+	// alive even if dependencies are down.
+	code, _ = get(s, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	storeErr, cacheErr = nil, nil
+	s.SetDraining(true)
+	code, r = get(s, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusDraining, r.Status)
+	assert.True(t, r.Draining)
+}
+
+func TestServer_CheckTimeout(t *testing.T) {
+	s := NewServer(&Options{Timeout: time.Millisecond * 10})
+	s.Register("slow", func() error {
+		time.Sleep(time.Millisecond * 100)
+		return nil
+	}, true)
+	code, r := get(s, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, errCheckTimeout, r.Dependencies[0].Error)
+}
@@ -0,0 +1,65 @@
+package i18n
+
+// builtin codes, English texts and Chinese translations of texts generated by the gateway and the messaging.
+var builtin = []struct {
+	code string
+	en   string
+	zh   string
+}{
+	{"auth.invalid_message", "invalid authenticate message", "无效的认证消息"},
+	{"auth.credential_expired", "credential expired", "凭证已过期"},
+	{"auth.credential_invalid", "invalid credentials", "无效的凭证"},
+	{"auth.no_credentials", "no credentials", "缺少凭证"},
+	{"auth.legacy_rejected", "legacy credentials are rejected", "不再接受旧版凭证"},
+	{"auth.connections_exceeded", "too many connections of the user", "用户连接数过多"},
+	{"auth.challenge_required", "challenge required", "需要完成验证"},
+	{"auth.challenge_failed", "challenge failed", "验证失败"},
+	{"auth.ticket_invalid", "invalid ticket", "无效的票据"},
+	{"auth.ticket_expired", "ticket expired", "票据已过期"},
+	{"auth.ticket_replayed", "ticket replayed", "票据已被使用"},
+	{"auth.resume_invalid", "invalid or expired resume token", "会话恢复令牌无效或已过期"},
+	{"sign.missing", "message sign is required", "消息签名缺失"},
+	{"sign.invalid", "invalid message sign", "无效的消息签名"},
+	{"sign.no_secret", "no message deliver secret", "缺少消息投递密钥"},
+	{"client.closed", "client closed", "客户端已关闭"},
+	{"client.queue_full", "client send queue is full", "客户端发送队列已满"},
+	{"client.invalid_hello", "invalid handleHello message", "无效的握手消息"},
+	{"message.blocked", "message blocked", "消息已被拦截"},
+	{"message.invalid_payload", "invalid payload", "无效的消息内容"},
+	{"message.worker_queue_full", "message worker queue is full", "服务繁忙，请稍后重试"},
+	{"message.no_receiver", "receiver does not exist", "接收者不存在"},
+	{"message.content_empty", "message content is empty", "消息内容为空"},
+	{"message.not_owner", "not the sender of the message", "不是该消息的发送者"},
+	{"message.window_expired", "message can not be modified anymore", "消息已无法修改"},
+	{"message.invalid_range", "invalid message range", "无效的消息范围"},
+	{"message.history_unavailable", "message history is unavailable temporarily", "消息历史暂时不可用"},
+	{"message.not_participant", "not a participant of the conversation", "不是该会话的参与者"},
+	{"reaction.invalid", "reaction emoji is invalid", "无效的表情回应"},
+	{"reaction.too_many", "too many reactions of the message", "消息的表情回应过多"},
+	{"relation.blocked", "blocked by the receiver", "已被对方拉黑"},
+	{"relation.not_contact", "not a contact of the receiver", "不是对方的联系人"},
+	{"call.not_exist", "call does not exist", "通话不存在"},
+	{"call.invalid_state", "invalid call state", "无效的通话状态"},
+	{"call.invalid_media", "invalid call media", "无效的通话媒体"},
+	{"call.self", "can not call yourself", "不能呼叫自己"},
+	{"channel.already_member", "already a member of the channel", "已是频道成员"},
+	{"channel.not_admin", "not an admin of the channel", "不是频道管理员"},
+	{"channel.join_request_not_exist", "join request does not exist", "入群申请不存在"},
+	{"channel.join_request_expired", "join request is expired", "入群申请已过期"},
+	{"media.invalid", "invalid media", "无效的媒体"},
+	{"media.upload_not_enabled", "media upload is not enabled", "未启用媒体上传"},
+	{"push.invalid_dnd_period", "invalid do-not-disturb period", "无效的免打扰时段"},
+	{"schedule.not_exist", "scheduled message does not exist", "定时消息不存在"},
+}
+
+// NewBuiltinCatalog returns the catalog with the builtin codes and Chinese translations.
+func NewBuiltinCatalog() *Catalog {
+	c := NewCatalog()
+	zh := map[string]string{}
+	for _, t := range builtin {
+		c.Define(t.code, t.en)
+		zh[t.code] = t.zh
+	}
+	c.Add("zh", zh)
+	return c
+}
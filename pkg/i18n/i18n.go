@@ -0,0 +1,122 @@
+// Package i18n translates texts generated by the server, such as errors and system notifications, to the language of
+// the client. Texts are identified by the English text sent before localization, each text is defined with a stable
+// code, so clients can branch on the code regardless of the language.
+package i18n
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/glide-im/glide/pkg/logger"
+)
+
+var log = logger.Named("i18n")
+
+// ExtraKeyCode the extra key of the message carries the code of the localized text.
+const ExtraKeyCode = "code"
+
+// Catalog the codes of English texts and translations of codes by locale.
+type Catalog struct {
+	mu sync.RWMutex
+	// codes English text => code.
+	codes map[string]string
+	// texts locale => code => translation, locales are lower case.
+	texts map[string]map[string]string
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{
+		codes: map[string]string{},
+		texts: map[string]map[string]string{},
+	}
+}
+
+// Define defines the code of the English text.
+func (c *Catalog) Define(code string, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.codes[text] = code
+}
+
+// Add adds translations of codes in the locale, such as zh-CN, existing translations are replaced.
+func (c *Catalog) Add(locale string, texts map[string]string) {
+	locale = normalize(locale)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.texts[locale]
+	if !ok {
+		m = map[string]string{}
+		c.texts[locale] = m
+	}
+	for code, text := range texts {
+		m[code] = text
+	}
+}
+
+// LoadDir adds translations in json files of the directory, the file name is the locale, such as zh-CN.json, the
+// content is the object of code to translation.
+func (c *Catalog) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		texts := map[string]string{}
+		if err = json.Unmarshal(b, &texts); err != nil {
+			return err
+		}
+		locale := strings.TrimSuffix(filepath.Base(f), ".json")
+		c.Add(locale, texts)
+		log.I("%d translations of %s loaded", len(texts), locale)
+	}
+	return nil
+}
+
+// Localize returns the code of the English text and the translation in the locale, the language of the locale is
+// tried if the locale is not translated, such as zh for zh-TW. The text is returned as is if it's not defined or not
+// translated, the code is empty if it's not defined.
+func (c *Catalog) Localize(locale string, text string) (code string, localized string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	code, ok := c.codes[text]
+	if !ok {
+		return "", text
+	}
+	locale = normalize(locale)
+	for locale != "" {
+		if t, ok := c.texts[locale][code]; ok {
+			return code, t
+		}
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return code, text
+}
+
+// normalize returns the lower case locale separated by -, such as zh-cn for zh_CN.
+func normalize(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+var defaultCatalog = NewBuiltinCatalog()
+
+// Default returns the catalog used by Localize, translations can be added to it.
+func Default() *Catalog {
+	return defaultCatalog
+}
+
+// Localize localizes the text by the default catalog.
+func Localize(locale string, text string) (code string, localized string) {
+	return defaultCatalog.Localize(locale, text)
+}
@@ -0,0 +1,38 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog_Localize(t *testing.T) {
+	c := NewBuiltinCatalog()
+
+	code, text := c.Localize("zh-CN", "invalid authenticate message")
+	assert.Equal(t, "auth.invalid_message", code)
+	assert.Equal(t, "无效的认证消息", text)
+
+	code, text = c.Localize("fr", "invalid authenticate message")
+	assert.Equal(t, "auth.invalid_message", code)
+	assert.Equal(t, "invalid authenticate message", text)
+
+	code, text = c.Localize("zh", "undefined text")
+	assert.Empty(t, code)
+	assert.Equal(t, "undefined text", text)
+}
+
+func TestCatalog_LoadDir(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "ja.json"), []byte(`{"auth.credential_expired":"認証情報の有効期限が切れています"}`), 0644)
+	assert.NoError(t, err)
+
+	c := NewBuiltinCatalog()
+	assert.NoError(t, c.LoadDir(dir))
+
+	code, text := c.Localize("ja_JP", "credential expired")
+	assert.Equal(t, "auth.credential_expired", code)
+	assert.Equal(t, "認証情報の有効期限が切れています", text)
+}
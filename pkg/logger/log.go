@@ -4,52 +4,53 @@ import (
 	"go.uber.org/zap"
 )
 
+// Zap is the zap logger of the default sink.
 var Zap *zap.Logger
 
-var sugar *zap.SugaredLogger
+// std is the default logger without subsystem.
+var std = &Logger{}
 
 func init() {
 	var err error
 	Zap, err = zap.NewDevelopment(
 		zap.Development(),
-		zap.AddCallerSkip(1),
 		zap.WithCaller(true),
 		zap.AddCaller(),
 	)
 	if err != nil {
 		panic(err)
 	}
-	sugar = Zap.Sugar()
+	SetSink(NewZapSink(Zap))
 }
 
 func E(format string, logs ...interface{}) {
-	sugar.Errorf(format, logs...)
+	std.logf(LevelError, format, logs)
 }
 
 func I(format string, args ...interface{}) {
-	sugar.Infof(format, args...)
+	std.logf(LevelInfo, format, args)
 }
 
 func D(format string, args ...interface{}) {
-	sugar.Debugf(format, args...)
+	std.logf(LevelDebug, format, args)
 }
 
 func W(format string, args ...interface{}) {
-	sugar.Warnf(format, args)
+	std.logf(LevelWarn, format, args)
 }
 
 func ErrE(msg string, e error) {
-	Zap.Error(msg, zap.Error(e))
+	std.log(LevelError, msg, []Field{Err(e)})
 }
 
 func ErrStr(msg string, k string, v string) {
-	Zap.Error(msg, zap.String(k, v))
+	std.log(LevelError, msg, []Field{String(k, v)})
 }
 
 func ErrInt(msg string, k string, v int64) {
-	Zap.Error(msg, zap.Int64(k, v))
+	std.log(LevelError, msg, []Field{Int64(k, v)})
 }
 
 func DebugStr(msg string, k string, v string) {
-	Zap.Debug(msg, zap.String(k, v))
+	std.log(LevelDebug, msg, []Field{String(k, v)})
 }
@@ -0,0 +1,204 @@
+package logger
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Level is the level of log.
+type Level int8
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", l)
+	}
+}
+
+// Keys of common fields.
+const (
+	KeySubsystem = "subsystem"
+	KeyUid       = "uid"
+	KeyGateway   = "gateway"
+	KeyAction    = "action"
+	KeyMid       = "mid"
+	KeyError     = "error"
+)
+
+// Field is a key-value pair of structured log.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func String(k string, v string) Field {
+	return Field{Key: k, Value: v}
+}
+
+func Int64(k string, v int64) Field {
+	return Field{Key: k, Value: v}
+}
+
+func Any(k string, v interface{}) Field {
+	return Field{Key: k, Value: v}
+}
+
+func Err(e error) Field {
+	if e == nil {
+		return Field{Key: KeyError, Value: nil}
+	}
+	return Field{Key: KeyError, Value: e.Error()}
+}
+
+func Uid(uid string) Field {
+	return String(KeyUid, uid)
+}
+
+func Gateway(id string) Field {
+	return String(KeyGateway, id)
+}
+
+func Action(action string) Field {
+	return String(KeyAction, action)
+}
+
+func Mid(mid int64) Field {
+	return Int64(KeyMid, mid)
+}
+
+// Entry is a log entry written to Sink.
+type Entry struct {
+	Time      time.Time
+	Level     Level
+	Subsystem string
+	Message   string
+	Fields    []Field
+}
+
+// Sink writes log entries, implement it to send logs to other backends.
+type Sink interface {
+	Write(e *Entry)
+	Sync() error
+}
+
+type sinkHolder struct {
+	sink Sink
+}
+
+var (
+	sink  atomic.Value
+	level = int32(LevelDebug)
+)
+
+// SetSink sets the sink of all loggers.
+func SetSink(s Sink) {
+	sink.Store(&sinkHolder{sink: s})
+}
+
+// SetLevel sets the min level of logs written to sink.
+func SetLevel(l Level) {
+	atomic.StoreInt32(&level, int32(l))
+}
+
+// Sync flushes buffered logs of the sink.
+func Sync() error {
+	return sink.Load().(*sinkHolder).sink.Sync()
+}
+
+// Logger is a logger tags logs with subsystem name and fields.
+type Logger struct {
+	subsystem string
+	fields    []Field
+}
+
+// Named returns a logger of subsystem, such as gate, messaging.
+func Named(subsystem string) *Logger {
+	return &Logger{subsystem: subsystem}
+}
+
+// With returns a logger with fields added to every log.
+func (l *Logger) With(fields ...Field) *Logger {
+	f := make([]Field, 0, len(l.fields)+len(fields))
+	f = append(f, l.fields...)
+	f = append(f, fields...)
+	return &Logger{subsystem: l.subsystem, fields: f}
+}
+
+func (l *Logger) D(format string, args ...interface{}) {
+	l.logf(LevelDebug, format, args)
+}
+
+func (l *Logger) I(format string, args ...interface{}) {
+	l.logf(LevelInfo, format, args)
+}
+
+func (l *Logger) W(format string, args ...interface{}) {
+	l.logf(LevelWarn, format, args)
+}
+
+func (l *Logger) E(format string, args ...interface{}) {
+	l.logf(LevelError, format, args)
+}
+
+func (l *Logger) ErrE(msg string, e error) {
+	l.log(LevelError, msg, []Field{Err(e)})
+}
+
+func (l *Logger) Debug(msg string, fields ...Field) {
+	l.log(LevelDebug, msg, fields)
+}
+
+func (l *Logger) Info(msg string, fields ...Field) {
+	l.log(LevelInfo, msg, fields)
+}
+
+func (l *Logger) Warn(msg string, fields ...Field) {
+	l.log(LevelWarn, msg, fields)
+}
+
+func (l *Logger) Error(msg string, fields ...Field) {
+	l.log(LevelError, msg, fields)
+}
+
+func (l *Logger) logf(lv Level, format string, args []interface{}) {
+	if lv < Level(atomic.LoadInt32(&level)) {
+		return
+	}
+	l.write(lv, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *Logger) log(lv Level, msg string, fields []Field) {
+	if lv < Level(atomic.LoadInt32(&level)) {
+		return
+	}
+	l.write(lv, msg, fields)
+}
+
+func (l *Logger) write(lv Level, msg string, fields []Field) {
+	if len(l.fields) > 0 {
+		fields = append(append(make([]Field, 0, len(l.fields)+len(fields)), l.fields...), fields...)
+	}
+	sink.Load().(*sinkHolder).sink.Write(&Entry{
+		Time:      time.Now(),
+		Level:     lv,
+		Subsystem: l.subsystem,
+		Message:   msg,
+		Fields:    fields,
+	})
+}
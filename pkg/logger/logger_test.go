@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogger_JSONSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetSink(NewJSONSink(buf))
+	defer SetSink(NewZapSink(Zap))
+
+	l := Named("gate").With(Uid("1"))
+	l.I("client %s connected", "1")
+	l.Error("enqueue failed", Action("message.chat"), Mid(2))
+
+	var lines []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		m := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(line, &m))
+		lines = append(lines, m)
+	}
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "client 1 connected", lines[0]["msg"])
+	assert.Equal(t, "gate", lines[0][KeySubsystem])
+	assert.Equal(t, "1", lines[0][KeyUid])
+	assert.Equal(t, "error", lines[1]["level"])
+	assert.Equal(t, "message.chat", lines[1][KeyAction])
+
+	SetLevel(LevelWarn)
+	defer SetLevel(LevelDebug)
+	buf.Reset()
+	l.D("dropped")
+	assert.Zero(t, buf.Len())
+}
+
+func TestRotateWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "glide.log")
+	w, err := NewRotateWriter(path, 10, 1)
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = w.Write([]byte("0123456789"))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+
+	backups, _ := filepath.Glob(path + ".*")
+	assert.Len(t, backups, 1)
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), info.Size())
+}
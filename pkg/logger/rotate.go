@@ -0,0 +1,101 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultRotateSize = 100 << 20
+
+// RotateWriter is a file writer rotates the file when the size exceeds MaxSize, the rotated file is renamed with
+// timestamp suffix, and the oldest files exceed MaxBackups are removed.
+type RotateWriter struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	f    *os.File
+	size int64
+}
+
+// NewRotateWriter creates RotateWriter, maxSize default 100MB, maxBackups 0 to keep all rotated files.
+func NewRotateWriter(path string, maxSize int64, maxBackups int) (*RotateWriter, error) {
+	if maxSize <= 0 {
+		maxSize = defaultRotateSize
+	}
+	r := &RotateWriter{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotateWriter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size+int64(len(p)) > r.maxSize && r.size > 0 {
+		err := r.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotateWriter) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Sync()
+}
+
+func (r *RotateWriter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+func (r *RotateWriter) open() error {
+	err := os.MkdirAll(filepath.Dir(r.path), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *RotateWriter) rotate() error {
+	_ = r.f.Close()
+	backup := fmt.Sprintf("%s.%s", r.path, time.Now().Format("20060102150405.000"))
+	err := os.Rename(r.path, backup)
+	if err != nil {
+		return err
+	}
+	if r.maxBackups > 0 {
+		backups, _ := filepath.Glob(r.path + ".*")
+		sort.Strings(backups)
+		for len(backups) > r.maxBackups {
+			_ = os.Remove(backups[0])
+			backups = backups[1:]
+		}
+	}
+	return r.open()
+}
@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"encoding/json"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"io"
+	"sync"
+)
+
+// callerSkip is the count of frames from the caller of logger to the zap sink.
+const callerSkip = 4
+
+var _ Sink = (*ZapSink)(nil)
+
+// ZapSink writes logs to zap logger.
+type ZapSink struct {
+	z *zap.Logger
+}
+
+func NewZapSink(z *zap.Logger) *ZapSink {
+	return &ZapSink{z: z.WithOptions(zap.AddCallerSkip(callerSkip))}
+}
+
+func (z *ZapSink) Write(e *Entry) {
+	var lv zapcore.Level
+	switch e.Level {
+	case LevelDebug:
+		lv = zapcore.DebugLevel
+	case LevelInfo:
+		lv = zapcore.InfoLevel
+	case LevelWarn:
+		lv = zapcore.WarnLevel
+	default:
+		lv = zapcore.ErrorLevel
+	}
+	ce := z.z.Check(lv, e.Message)
+	if ce == nil {
+		return
+	}
+	fields := make([]zap.Field, 0, len(e.Fields)+1)
+	if e.Subsystem != "" {
+		fields = append(fields, zap.String(KeySubsystem, e.Subsystem))
+	}
+	for _, f := range e.Fields {
+		fields = append(fields, zap.Any(f.Key, f.Value))
+	}
+	ce.Write(fields...)
+}
+
+func (z *ZapSink) Sync() error {
+	return z.z.Sync()
+}
+
+var _ Sink = (*JSONSink)(nil)
+
+// JSONSink writes logs as json lines to writer, such as os.Stdout or RotateWriter.
+type JSONSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{w: w}
+}
+
+func (j *JSONSink) Write(e *Entry) {
+	m := make(map[string]interface{}, len(e.Fields)+4)
+	for _, f := range e.Fields {
+		m[f.Key] = f.Value
+	}
+	m["time"] = e.Time.Format("2006-01-02T15:04:05.000Z07:00")
+	m["level"] = e.Level.String()
+	m["msg"] = e.Message
+	if e.Subsystem != "" {
+		m[KeySubsystem] = e.Subsystem
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, _ = j.w.Write(b)
+}
+
+func (j *JSONSink) Sync() error {
+	if s, ok := j.w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
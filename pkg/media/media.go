@@ -0,0 +1,143 @@
+package media
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+var log = logger.Named("media")
+
+const (
+	defaultMaxSize = 100 << 20
+	defaultExpires = time.Minute * 15
+)
+
+var (
+	ErrTooLarge       = errors.New("media size exceeds the limit")
+	ErrMimeNotAllowed = errors.New("media mime type is not allowed")
+	ErrInvalidRequest = errors.New("invalid upload request")
+)
+
+// PresignedRequest the http request to upload an object without credentials of storage.
+type PresignedRequest struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+}
+
+// StorageProvider issues pre-signed upload urls of an object storage, such as S3, MinIO or OSS.
+type StorageProvider interface {
+
+	// PresignUpload returns the request to upload the object of key with the mime and size, the request expires after
+	// expires.
+	PresignUpload(key string, mime string, size int64, expires time.Duration) (*PresignedRequest, error)
+
+	// DownloadURL returns the url to download the object of key.
+	DownloadURL(key string) string
+}
+
+// Options the options of Uploader.
+type Options struct {
+	// MaxSize the max size in bytes of a media, default 100MB.
+	MaxSize int64
+	// AllowedMimes the allowed mime types, the type ends with `/` matches all subtypes, such as `image/`, empty to
+	// allow all.
+	AllowedMimes []string
+	// Expires the duration the upload url is valid, default 15 minutes.
+	Expires time.Duration
+}
+
+// Uploader issues upload tokens to clients, clients upload the media to storage by the token directly, then send the
+// media message with the url, so the binary is never sent through message channel.
+type Uploader struct {
+	provider StorageProvider
+	maxSize  int64
+	mimes    []string
+	expires  time.Duration
+}
+
+// NewUploader creates the Uploader issues upload url by provider.
+func NewUploader(provider StorageProvider, opts *Options) *Uploader {
+	u := &Uploader{
+		provider: provider,
+		maxSize:  opts.MaxSize,
+		mimes:    opts.AllowedMimes,
+		expires:  opts.Expires,
+	}
+	if u.maxSize <= 0 {
+		u.maxSize = defaultMaxSize
+	}
+	if u.expires <= 0 {
+		u.expires = defaultExpires
+	}
+	return u
+}
+
+// Issue issues the upload token of the media of uid.
+func (u *Uploader) Issue(uid string, req *messages.UploadRequest) (*messages.UploadToken, error) {
+	if req.Size <= 0 || req.Mime == "" || !messages.IsMediaType(req.Type) {
+		return nil, ErrInvalidRequest
+	}
+	if req.Size > u.maxSize {
+		return nil, ErrTooLarge
+	}
+	if !u.allowed(req.Mime) {
+		return nil, ErrMimeNotAllowed
+	}
+
+	key, err := objectKey(uid, req)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(u.expires)
+	r, err := u.provider.PresignUpload(key, req.Mime, req.Size, u.expires)
+	if err != nil {
+		log.E("presign upload error: %v", err)
+		return nil, err
+	}
+	return &messages.UploadToken{
+		Method:      r.Method,
+		URL:         r.URL,
+		Headers:     r.Headers,
+		Key:         key,
+		DownloadURL: u.provider.DownloadURL(key),
+		ExpiresAt:   expiresAt.Unix(),
+	}, nil
+}
+
+func (u *Uploader) allowed(m string) bool {
+	if len(u.mimes) == 0 {
+		return true
+	}
+	for _, a := range u.mimes {
+		if a == m || (strings.HasSuffix(a, "/") && strings.HasPrefix(m, a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectKey returns the key of object, `uid/yyyymmdd/random.ext`, the ext is from the file name or the mime type.
+func objectKey(uid string, req *messages.UploadRequest) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	ext := path.Ext(req.Name)
+	if ext == "" {
+		if exts, _ := mime.ExtensionsByType(req.Mime); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+	if strings.ContainsAny(ext, "/\\?#") {
+		ext = ""
+	}
+	return uid + "/" + time.Now().UTC().Format("20060102") + "/" + hex.EncodeToString(b) + ext, nil
+}
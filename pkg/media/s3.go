@@ -0,0 +1,198 @@
+package media
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ StorageProvider = (*S3Storage)(nil)
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3MaxExpires      = time.Hour * 24 * 7
+	s3RequestTimeout  = time.Second * 30
+)
+
+// S3Options the options of S3Storage.
+type S3Options struct {
+	// Endpoint the url of the storage service, such as https://s3.amazonaws.com, http://127.0.0.1:9000 for MinIO or
+	// https://oss-cn-hangzhou.aliyuncs.com for OSS.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle true to address the bucket by path `endpoint/bucket/key`, MinIO usually requires, otherwise the
+	// bucket is the subdomain of the endpoint.
+	PathStyle bool
+	// PublicURL the url prefix to download objects, such as the CDN domain, default the url of the object in bucket.
+	PublicURL string
+}
+
+// S3Storage issues pre-signed urls of the S3 compatible storage by AWS signature version 4.
+type S3Storage struct {
+	endpoint *url.URL
+	opts     S3Options
+	now      func() time.Time
+	client   *http.Client
+}
+
+// NewS3Storage creates the S3Storage.
+func NewS3Storage(opts *S3Options) (*S3Storage, error) {
+	if opts.Bucket == "" || opts.AccessKey == "" || opts.SecretKey == "" {
+		return nil, errors.New("bucket and access key are required")
+	}
+	u, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("invalid endpoint: " + opts.Endpoint)
+	}
+	o := *opts
+	if o.Region == "" {
+		o.Region = "us-east-1"
+	}
+	o.PublicURL = strings.TrimSuffix(o.PublicURL, "/")
+	return &S3Storage{endpoint: u, opts: o, now: time.Now, client: &http.Client{Timeout: s3RequestTimeout}}, nil
+}
+
+func (s *S3Storage) PresignUpload(key string, mime string, size int64, expires time.Duration) (*PresignedRequest, error) {
+	headers := map[string]string{
+		"Content-Type":   mime,
+		"Content-Length": strconv.FormatInt(size, 10),
+	}
+	u, err := s.presign("PUT", key, headers, nil, expires)
+	if err != nil {
+		return nil, err
+	}
+	return &PresignedRequest{Method: "PUT", URL: u, Headers: headers}, nil
+}
+
+func (s *S3Storage) DownloadURL(key string) string {
+	if s.opts.PublicURL != "" {
+		return s.opts.PublicURL + "/" + encodePath(key)
+	}
+	host, p := s.object(key)
+	return s.endpoint.Scheme + "://" + host + p
+}
+
+// PresignDownload returns the pre-signed url to download the object of the private bucket.
+func (s *S3Storage) PresignDownload(key string, expires time.Duration) (string, error) {
+	return s.presign("GET", key, nil, nil, expires)
+}
+
+// object returns the host and the escaped path of the object.
+func (s *S3Storage) object(key string) (string, string) {
+	base := strings.TrimSuffix(s.endpoint.EscapedPath(), "/")
+	if s.opts.PathStyle {
+		return s.endpoint.Host, base + "/" + encodePath(s.opts.Bucket) + "/" + encodePath(key)
+	}
+	return s.opts.Bucket + "." + s.endpoint.Host, base + "/" + encodePath(key)
+}
+
+// presign signs the request by query parameters, the headers must be sent with the request as they are signed, the
+// params are the additional query parameters of the request, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+func (s *S3Storage) presign(method string, key string, headers map[string]string, params map[string]string, expires time.Duration) (string, error) {
+	if expires <= 0 || expires > s3MaxExpires {
+		return "", errors.New("expires must be in 1 second to 7 days")
+	}
+	host, p := s.object(key)
+	now := s.now().UTC()
+	date := now.Format("20060102")
+	amzDate := now.Format("20060102T150405Z")
+	scope := date + "/" + s.opts.Region + "/s3/aws4_request"
+
+	canonicalHeaders := map[string]string{"host": host}
+	for k, v := range headers {
+		canonicalHeaders[strings.ToLower(k)] = strings.TrimSpace(v)
+	}
+	var names []string
+	for k := range canonicalHeaders {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	signedHeaders := strings.Join(names, ";")
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     s3Algorithm,
+		"X-Amz-Credential":    s.opts.AccessKey + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       strconv.FormatInt(int64(expires/time.Second), 10),
+		"X-Amz-SignedHeaders": signedHeaders,
+	}
+	for k, v := range params {
+		query[k] = v
+	}
+	canonicalQuery := canonicalQueryString(query)
+
+	var b strings.Builder
+	b.WriteString(method + "\n" + p + "\n" + canonicalQuery + "\n")
+	for _, k := range names {
+		b.WriteString(k + ":" + canonicalHeaders[k] + "\n")
+	}
+	b.WriteString("\n" + signedHeaders + "\n" + s3UnsignedPayload)
+
+	hash := sha256.Sum256([]byte(b.String()))
+	stringToSign := s3Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.opts.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, s.opts.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	return s.endpoint.Scheme + "://" + host + p + "?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+func canonicalQueryString(query map[string]string) string {
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(query[k], true))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// encodePath encodes the object key as the uri path, the `/` is not encoded.
+func encodePath(p string) string {
+	return uriEncode(p, false)
+}
+
+// uriEncode encodes s by RFC 3986 as AWS requires, unreserved characters are not encoded.
+func uriEncode(s string, encodeSlash bool) string {
+	const hexChars = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexChars[c>>4])
+		b.WriteByte(hexChars[c&15])
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,142 @@
+package media
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// s3RequestExpires the expiration of urls signed for requests sent by S3Storage.
+const s3RequestExpires = time.Minute * 5
+
+// ErrObjectNotExist is returned by S3Storage.GetObject if the object does not exist.
+var ErrObjectNotExist = errors.New("object does not exist")
+
+// PutObject uploads the object of key.
+func (s *S3Storage) PutObject(key string, mime string, data []byte) error {
+	headers := map[string]string{
+		"Content-Type":   mime,
+		"Content-Length": strconv.Itoa(len(data)),
+	}
+	u, err := s.presign(http.MethodPut, key, headers, nil, s3RequestExpires)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mime)
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// GetObject downloads the object of key, returns ErrObjectNotExist if it does not exist.
+func (s *S3Storage) GetObject(key string) ([]byte, error) {
+	u, err := s.presign(http.MethodGet, key, nil, nil, s3RequestExpires)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// DeleteObject deletes the object of key, it's not an error if the object does not exist.
+func (s *S3Storage) DeleteObject(key string) error {
+	u, err := s.presign(http.MethodDelete, key, nil, nil, s3RequestExpires)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodDelete, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err == ErrObjectNotExist {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// ListObjects returns keys of all objects with the prefix in lexicographical order.
+func (s *S3Storage) ListObjects(prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		params := map[string]string{"list-type": "2", "prefix": prefix}
+		if token != "" {
+			params["continuation-token"] = token
+		}
+		u, err := s.presign(http.MethodGet, "", nil, params, s3RequestExpires)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+		result := listBucketResult{}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do sends the request, the response of status other than 2xx is returned as error.
+func (s *S3Storage) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrObjectNotExist
+	}
+	return nil, fmt.Errorf("s3 %s %s: %d %s", req.Method, req.URL.Path, resp.StatusCode, body)
+}
@@ -1,44 +1,318 @@
 package messages
 
-import "strings"
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
 
 // Action is the type of action that is being performed.
 type Action string
 
 const (
 	ActionHello               Action = "hello"
-	ActionHeartbeat                  = "heartbeat"
-	ActionNotifyUnknownAction        = "notify.unknown.action"
-
-	ActionChatMessage       = "message.chat"
-	ActionChatMessageResend = "message.chat.resend"
-	ActionGroupMessage      = "message.group"
-	ActionGroupNotify       = "message.group.notify"
-	ActionClientCustom      = "message.cli"
-
-	ActionAuthenticate          = "authenticate"
-	ActionNotifyError           = "notify.error"
-	ActionNotifySuccess         = "notify.success"
-	ActionNotifyKickOut         = "notify.kickout"
-	ActionNotifyForbidden       = "notify.forbidden"
-	ActionNotifyUnauthenticated = "notify.unauthenticated"
-	ActionNotifyUserState       = "notify.state"
-
-	ActionAckRequest  = "ack.request"
-	ActionAckGroupMsg = "ack.group.msg"
-	ActionAckMessage  = "ack.message"
-	ActionAckNotify   = "ack.notify"
-	AckOffline        = "ack.offline"
-
-	ActionApiGroupMembers = "api.group.members"
-	ActionApiSubUserState = "api.state.sub"
-	ActionApiFailed       = "api.failed"
-	ActionApiSuccess      = "api.success"
-
-	ActionInternalOnline  = "internal.online"
-	ActionInternalOffline = "internal.offline"
+	ActionHeartbeat           Action = "heartbeat"
+	ActionNotifyUnknownAction Action = "notify.unknown.action"
+
+	ActionChatMessage       Action = "message.chat"
+	ActionChatMessageResend Action = "message.chat.resend"
+	ActionGroupMessage      Action = "message.group"
+	ActionGroupNotify       Action = "message.group.notify"
+	ActionClientCustom      Action = "message.cli"
+	ActionMessageRecall     Action = "message.recall"
+	ActionGroupRecall       Action = "message.group.recall"
+	ActionMessageEdit       Action = "message.edit"
+	ActionGroupMessageEdit  Action = "message.group.edit"
+	ActionMessageRead       Action = "message.read"
+	ActionGroupMessageRead  Action = "message.group.read"
+
+	// ActionMessageReact and others react or unreact a message with an emoji, see Reaction.
+	ActionMessageReact        Action = "message.react"
+	ActionMessageUnreact      Action = "message.unreact"
+	ActionGroupMessageReact   Action = "message.group.react"
+	ActionGroupMessageUnreact Action = "message.group.unreact"
+
+	// ActionStateMessage ephemeral state message, such as typing, do not store and ack.
+	ActionStateMessage      Action = "message.state"
+	ActionGroupStateMessage Action = "message.group.state"
+
+	ActionAuthenticate          Action = "authenticate"
+	ActionNotifyError           Action = "notify.error"
+	ActionNotifySuccess         Action = "notify.success"
+	ActionNotifyKickOut         Action = "notify.kickout"
+	ActionNotifyForbidden       Action = "notify.forbidden"
+	ActionNotifyUnauthenticated Action = "notify.unauthenticated"
+	ActionNotifyUserState       Action = "notify.state"
+	ActionNotifySystem          Action = "notify.system"
+	ActionNotifyRejected        Action = "notify.rejected"
+	ActionNotifyExpired         Action = "notify.expired"
+	// ActionNotifyQuotaExceeded the message is rejected as the sender exceeds the quota, see QuotaExceeded.
+	ActionNotifyQuotaExceeded Action = "notify.quota"
+	// ActionNotifyServerBusy the connection is rejected as the gateway reaches quotas, see ServerBusy.
+	ActionNotifyServerBusy Action = "notify.busy"
+	// ActionNotifyLogin notifies devices of the user when a device logs in, see LoginNotify.
+	ActionNotifyLogin Action = "notify.login"
+	// ActionNotifyService notifies the visitor and agents of the service session changed, see ServiceNotify.
+	ActionNotifyService Action = "notify.service"
+	// ActionNotifyMention notifies the user mentioned in the channel message, see MentionNotify.
+	ActionNotifyMention Action = "notify.mention"
+	// ActionNotifyJoinRequest notifies admins of the channel the join request, and the requester the decision, see
+	// JoinRequest.
+	ActionNotifyJoinRequest Action = "notify.join"
+	// ActionNotifyReadCount pushes the read counts of recent channel messages to the sender periodically, the data is
+	// []*ReadCount of messages whose count changed.
+	ActionNotifyReadCount Action = "notify.read.count"
+	// ActionNotifyConfig pushes the client config on authenticate and when it's changed, see RemoteConfig.
+	ActionNotifyConfig Action = "notify.config"
+	// ActionNotifySync pushes a phase of the bootstrap sync, see SyncPhase.
+	ActionNotifySync Action = "notify.sync"
+	// ActionChunk a part of the message larger than the max message size, see Chunk.
+	ActionChunk Action = "chunk"
+	// ActionBatch multiple messages sent in a frame, see Batch, the gateway replies ActionBatchResult to the batch sent
+	// by client.
+	ActionBatch       Action = "batch"
+	ActionBatchResult Action = "batch.result"
+	// ActionResume resumes the session disconnected by the resume token instead of authenticate, see Resume.
+	ActionResume Action = "resume"
+
+	// ActionChallenge the anti-abuse challenge client must solve before authenticate, see Challenge.
+	ActionChallenge       Action = "challenge"
+	ActionChallengeAnswer Action = "challenge.answer"
+
+	// ActionCallInvite and others the signaling of voice and video call, see CallSignal.
+	ActionCallInvite    Action = "call.invite"
+	ActionCallRinging   Action = "call.ringing"
+	ActionCallAnswer    Action = "call.answer"
+	ActionCallReject    Action = "call.reject"
+	ActionCallHangup    Action = "call.hangup"
+	ActionCallCandidate Action = "call.candidate"
+
+	ActionAckRequest  Action = "ack.request"
+	ActionAckGroupMsg Action = "ack.group.msg"
+	ActionAckMessage  Action = "ack.message"
+	ActionAckNotify   Action = "ack.notify"
+	AckOffline        Action = "ack.offline"
+
+	ActionApiGroupMembers   Action = "api.group.members"
+	ActionApiSubUserState   Action = "api.state.sub"
+	ActionApiUnsubUserState Action = "api.state.unsub"
+	ActionApiUserState      Action = "api.state.query"
+	ActionApiReadCursors    Action = "api.read.cursors"
+	ActionApiReadCount      Action = "api.read.count"
+	// ActionApiReadReaders queries subscribers who have read the message of the channel, see ReadReaders.
+	ActionApiReadReaders  Action = "api.read.readers"
+	ActionApiMessageRange Action = "api.message.range"
+	// ActionApiGetConversations queries recent conversations with unread counts, see ConversationInfo.
+	ActionApiGetConversations Action = "api.conversations"
+	ActionApiPushRegister     Action = "api.push.register"
+	ActionApiPushSettings     Action = "api.push.settings"
+	ActionApiPushSettingsSet  Action = "api.push.settings.set"
+	ActionApiUploadToken      Action = "api.upload.token"
+	ActionApiScheduleCancel   Action = "api.schedule.cancel"
+	ActionApiFailed           Action = "api.failed"
+	ActionApiSuccess          Action = "api.success"
+
+	// ActionApiSync starts the bootstrap sync after authenticate, the server pushes phases by ActionNotifySync and
+	// responds the done phase, see SyncRequest and SyncPhase.
+	ActionApiSync Action = "api.sync"
+
+	// ActionApiServiceTransfer and ActionApiServiceClose transfer and close the service session, see ServiceSession.
+	ActionApiServiceTransfer Action = "api.service.transfer"
+	ActionApiServiceClose    Action = "api.service.close"
+
+	// ActionApiGroupJoin and others request to join the channel and approve or reject the request by channel admins,
+	// see JoinRequest.
+	ActionApiGroupJoin        Action = "api.group.join"
+	ActionApiGroupJoinApprove Action = "api.group.join.approve"
+	ActionApiGroupJoinReject  Action = "api.group.join.reject"
+	ActionApiGroupJoinList    Action = "api.group.join.list"
+
+	ActionInternalOnline  Action = "internal.online"
+	ActionInternalOffline Action = "internal.offline"
+	ActionInternalUpgrade Action = "internal.upgrade"
 )
 
+// IsInternal returns true if the action is in the internal namespace.
 func (a Action) IsInternal() bool {
 	return strings.HasPrefix(string(a), "internal.")
 }
+
+// ActionGroup the namespace of actions, each known action belongs to one group.
+type ActionGroup string
+
+// Groups of actions.
+const (
+	// GroupSession handshake and connection control, such as hello, heartbeat and authenticate.
+	GroupSession ActionGroup = "session"
+	// GroupChat messages of one-to-one conversations.
+	GroupChat ActionGroup = "chat"
+	// GroupChannel messages of group conversations.
+	GroupChannel ActionGroup = "channel"
+	// GroupCall voice and video call signaling.
+	GroupCall ActionGroup = "call"
+	// GroupAck acks of messages.
+	GroupAck ActionGroup = "ack"
+	// GroupNotify notifications sent by server.
+	GroupNotify ActionGroup = "notify"
+	// GroupApi api requests and responses.
+	GroupApi ActionGroup = "api"
+	// GroupInternal actions between server components, they are never accepted from clients.
+	GroupInternal ActionGroup = "internal"
+	// GroupApp application specific actions, see RegisterAction.
+	GroupApp ActionGroup = "app"
+)
+
+const (
+	errActionRegistered = "action already registered in group "
+
+	// unknownActionLabel the metrics label of actions not registered.
+	unknownActionLabel = "unknown"
+)
+
+var actionsMu sync.RWMutex
+
+// actionGroups the registry of known actions.
+var actionGroups = map[Action]ActionGroup{
+	ActionHello:           GroupSession,
+	ActionHeartbeat:       GroupSession,
+	ActionAuthenticate:    GroupSession,
+	ActionChunk:           GroupSession,
+	ActionBatch:           GroupSession,
+	ActionBatchResult:     GroupSession,
+	ActionResume:          GroupSession,
+	ActionChallenge:       GroupSession,
+	ActionChallengeAnswer: GroupSession,
+
+	ActionChatMessage:       GroupChat,
+	ActionChatMessageResend: GroupChat,
+	ActionClientCustom:      GroupChat,
+	ActionMessageRecall:     GroupChat,
+	ActionMessageEdit:       GroupChat,
+	ActionMessageRead:       GroupChat,
+	ActionStateMessage:      GroupChat,
+
+	ActionGroupMessage:      GroupChannel,
+	ActionGroupNotify:       GroupChannel,
+	ActionGroupRecall:       GroupChannel,
+	ActionGroupMessageEdit:  GroupChannel,
+	ActionGroupMessageRead:  GroupChannel,
+	ActionGroupStateMessage: GroupChannel,
+
+	ActionMessageReact:        GroupChat,
+	ActionMessageUnreact:      GroupChat,
+	ActionGroupMessageReact:   GroupChannel,
+	ActionGroupMessageUnreact: GroupChannel,
+
+	ActionCallInvite:    GroupCall,
+	ActionCallRinging:   GroupCall,
+	ActionCallAnswer:    GroupCall,
+	ActionCallReject:    GroupCall,
+	ActionCallHangup:    GroupCall,
+	ActionCallCandidate: GroupCall,
+
+	ActionAckRequest:  GroupAck,
+	ActionAckGroupMsg: GroupAck,
+	ActionAckMessage:  GroupAck,
+	ActionAckNotify:   GroupAck,
+	AckOffline:        GroupAck,
+
+	ActionNotifyUnknownAction:   GroupNotify,
+	ActionNotifyError:           GroupNotify,
+	ActionNotifySuccess:         GroupNotify,
+	ActionNotifyKickOut:         GroupNotify,
+	ActionNotifyLogin:           GroupNotify,
+	ActionNotifyService:         GroupNotify,
+	ActionNotifyMention:         GroupNotify,
+	ActionNotifyJoinRequest:     GroupNotify,
+	ActionNotifyConfig:          GroupNotify,
+	ActionNotifyReadCount:       GroupNotify,
+	ActionNotifySync:            GroupNotify,
+	ActionNotifyForbidden:       GroupNotify,
+	ActionNotifyUnauthenticated: GroupNotify,
+	ActionNotifyUserState:       GroupNotify,
+	ActionNotifySystem:          GroupNotify,
+	ActionNotifyRejected:        GroupNotify,
+	ActionNotifyExpired:         GroupNotify,
+	ActionNotifyQuotaExceeded:   GroupNotify,
+	ActionNotifyServerBusy:      GroupNotify,
+
+	ActionApiGroupMembers:     GroupApi,
+	ActionApiSubUserState:     GroupApi,
+	ActionApiUnsubUserState:   GroupApi,
+	ActionApiUserState:        GroupApi,
+	ActionApiReadCursors:      GroupApi,
+	ActionApiReadCount:        GroupApi,
+	ActionApiReadReaders:      GroupApi,
+	ActionApiMessageRange:     GroupApi,
+	ActionApiGetConversations: GroupApi,
+	ActionApiSync:             GroupApi,
+	ActionApiPushRegister:     GroupApi,
+	ActionApiPushSettings:     GroupApi,
+	ActionApiPushSettingsSet:  GroupApi,
+	ActionApiUploadToken:      GroupApi,
+	ActionApiScheduleCancel:   GroupApi,
+	ActionApiServiceTransfer:  GroupApi,
+	ActionApiServiceClose:     GroupApi,
+	ActionApiGroupJoin:        GroupApi,
+	ActionApiGroupJoinApprove: GroupApi,
+	ActionApiGroupJoinReject:  GroupApi,
+	ActionApiGroupJoinList:    GroupApi,
+	ActionApiFailed:           GroupApi,
+	ActionApiSuccess:          GroupApi,
+
+	ActionInternalOnline:  GroupInternal,
+	ActionInternalOffline: GroupInternal,
+	ActionInternalUpgrade: GroupInternal,
+}
+
+// RegisterAction adds the action to the group, the action registered is known, see Action.IsKnown. It's safe to
+// register the action again to the same group.
+func RegisterAction(a Action, g ActionGroup) error {
+	actionsMu.Lock()
+	defer actionsMu.Unlock()
+
+	if registered, ok := actionGroups[a]; ok && registered != g {
+		return errors.New(errActionRegistered + string(registered))
+	}
+	actionGroups[a] = g
+	return nil
+}
+
+// Actions returns the sorted actions of the group.
+func Actions(g ActionGroup) []Action {
+	actionsMu.RLock()
+	defer actionsMu.RUnlock()
+
+	var ret []Action
+	for a, group := range actionGroups {
+		if group == g {
+			ret = append(ret, a)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i] < ret[j]
+	})
+	return ret
+}
+
+// Group returns the group of action, empty if the action is not registered.
+func (a Action) Group() ActionGroup {
+	actionsMu.RLock()
+	defer actionsMu.RUnlock()
+	return actionGroups[a]
+}
+
+// IsKnown returns true if the action is registered.
+func (a Action) IsKnown() bool {
+	return a.Group() != ""
+}
+
+// Label returns the action as the label of per action metrics, "unknown" if the action is not registered, so that
+// the labels are bounded.
+func (a Action) Label() string {
+	if !a.IsKnown() {
+		return unknownActionLabel
+	}
+	return string(a)
+}
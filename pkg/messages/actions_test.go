@@ -0,0 +1,24 @@
+package messages
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAction_Group(t *testing.T) {
+	assert.Equal(t, GroupChat, Action(ActionChatMessage).Group())
+	assert.Equal(t, GroupChannel, Action(ActionGroupMessage).Group())
+	assert.Equal(t, GroupInternal, Action(ActionInternalOnline).Group())
+	assert.Contains(t, Actions(GroupNotify), Action(ActionNotifyError))
+
+	unknown := Action("test.unknown")
+	assert.False(t, unknown.IsKnown())
+	assert.Equal(t, "unknown", unknown.Label())
+	assert.Equal(t, PriorityNormal, ActionPriority(unknown))
+
+	assert.NoError(t, RegisterAction(unknown, GroupApp))
+	assert.NoError(t, RegisterAction(unknown, GroupApp))
+	assert.Error(t, RegisterAction(unknown, GroupApi))
+	assert.Equal(t, "test.unknown", unknown.Label())
+	assert.Equal(t, []Action{unknown}, Actions(GroupApp))
+}
@@ -0,0 +1,109 @@
+package messages
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+const (
+	// MaxBatchItems the max count of items of a batch.
+	MaxBatchItems = 100
+	// maxBatchDecompressed the max size of the decompressed items of a batch.
+	maxBatchDecompressed = 16 << 20
+)
+
+const (
+	errBatchEmpty      = "empty batch"
+	errBatchTooMany    = "too many items in batch"
+	errBatchCompressed = "invalid compressed batch"
+)
+
+// Batch carries multiple messages in one frame, see ActionBatch. The client reconnected after a long offline period
+// sends messages queued in a batch, the gateway handles each item as if it's sent alone and replies a BatchResult with
+// the same seq. The server delivers messages buffered for the client, such as messages replayed on resume, in a batch
+// compressed by gzip, the client handles items in order as if they are received one by one.
+type Batch struct {
+	// Items the messages of the batch, empty if the batch is compressed.
+	Items []*GlideMessage `json:"items,omitempty"`
+	// Compressed the gzip compressed json array of items.
+	Compressed []byte `json:"compressed,omitempty"`
+}
+
+// BatchItemResult the result of an item of batch, the accepted item is handled as usual, such as the chat message is
+// acked by ActionAckMessage.
+type BatchItemResult struct {
+	// Seq the seq of the item.
+	Seq int64 `json:"seq"`
+	// Ok true if the item is accepted.
+	Ok bool `json:"ok"`
+	// Error the reason why the item is rejected.
+	Error *Error `json:"error,omitempty"`
+}
+
+// BatchResult the results of items of the batch, in order of items.
+type BatchResult struct {
+	Results []*BatchItemResult `json:"results"`
+}
+
+// NewBatch returns the batch of items.
+func NewBatch(items []*GlideMessage) *Batch {
+	return &Batch{Items: items}
+}
+
+// NewCompressedBatch returns the batch of items compressed by gzip.
+func NewCompressedBatch(items []*GlideMessage) (*Batch, error) {
+	b, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	w := gzip.NewWriter(&buf)
+	if _, err = w.Write(b); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return &Batch{Compressed: buf.Bytes()}, nil
+}
+
+// Messages returns items of the batch, the compressed items are decompressed.
+func (b *Batch) Messages() ([]*GlideMessage, error) {
+	items := b.Items
+	if len(b.Compressed) > 0 {
+		r, err := gzip.NewReader(bytes.NewReader(b.Compressed))
+		if err != nil {
+			return nil, errors.New(errBatchCompressed)
+		}
+		data, err := io.ReadAll(io.LimitReader(r, maxBatchDecompressed+1))
+		if err != nil || len(data) > maxBatchDecompressed {
+			return nil, errors.New(errBatchCompressed)
+		}
+		if err = json.Unmarshal(data, &items); err != nil {
+			return nil, errors.New(errBatchCompressed)
+		}
+	}
+	if len(items) == 0 {
+		return nil, errors.New(errBatchEmpty)
+	}
+	if len(items) > MaxBatchItems {
+		return nil, errors.New(errBatchTooMany)
+	}
+	return items, nil
+}
+
+// SplitBatches splits messages into batches of at most MaxBatchItems items.
+func SplitBatches(ms []*GlideMessage) [][]*GlideMessage {
+	var ret [][]*GlideMessage
+	for len(ms) > MaxBatchItems {
+		ret = append(ret, ms[:MaxBatchItems])
+		ms = ms[MaxBatchItems:]
+	}
+	if len(ms) > 0 {
+		ret = append(ret, ms)
+	}
+	return ret
+}
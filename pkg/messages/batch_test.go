@@ -0,0 +1,44 @@
+package messages
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBatch_Messages(t *testing.T) {
+	items := []*GlideMessage{
+		NewMessage(1, ActionChatMessage, &ChatMessage{Content: "1"}),
+		NewMessage(2, ActionChatMessage, &ChatMessage{Content: "2"}),
+	}
+	b, err := NewCompressedBatch(items)
+	assert.NoError(t, err)
+	assert.Empty(t, b.Items)
+
+	encoded, err := JsonCodec.Encode(NewMessage(0, ActionBatch, b))
+	assert.NoError(t, err)
+	m := NewEmptyMessage()
+	assert.NoError(t, JsonCodec.Decode(encoded, m))
+	decoded := Batch{}
+	assert.NoError(t, m.Data.Deserialize(&decoded))
+
+	ms, err := decoded.Messages()
+	assert.NoError(t, err)
+	assert.Len(t, ms, 2)
+	chat := ChatMessage{}
+	assert.NoError(t, ms[1].Data.Deserialize(&chat))
+	assert.Equal(t, "2", chat.Content)
+
+	_, err = NewBatch(nil).Messages()
+	assert.EqualError(t, err, errBatchEmpty)
+	_, err = (&Batch{Compressed: []byte("invalid")}).Messages()
+	assert.EqualError(t, err, errBatchCompressed)
+	_, err = NewBatch(make([]*GlideMessage, MaxBatchItems+1)).Messages()
+	assert.EqualError(t, err, errBatchTooMany)
+}
+
+func TestSplitBatches(t *testing.T) {
+	assert.Empty(t, SplitBatches(nil))
+	batches := SplitBatches(make([]*GlideMessage, MaxBatchItems*2+1))
+	assert.Len(t, batches, 3)
+	assert.Len(t, batches[2], 1)
+}
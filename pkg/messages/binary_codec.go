@@ -0,0 +1,310 @@
+package messages
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/glide-im/glide/pkg/pool"
+	"sort"
+)
+
+// BinaryCodec compact binary codec for constrained clients (smartwatch, embedded device etc.), it only supports
+// *GlideMessage.
+//
+// Layout of the encoded message:
+//
+//	+-------+---------+---------------+------------------------+
+//	| magic | version | flags(uint16) | fields present in flags |
+//	+-------+---------+---------------+------------------------+
+//
+// Header is fixed 4 bytes, flags is big endian, each bit express whether the field is present, fields are
+// encoded in the bit order:
+//   - bit 0 Ver, bit 1 Seq: zigzag varint.
+//   - bit 2 Action, bit 3 From, bit 4 To, bit 6 Msg, bit 7 Ticket, bit 8 Sign: uvarint length + utf-8 bytes.
+//   - bit 5 Data: uvarint length + json encoded data.
+//   - bit 9 Extra: uvarint count + key, value pairs sorted by key, each one is encoded as string.
+//   - bit 10 ReplyTo, bit 11 DeliverAt: zigzag varint.
+//   - bit 12 Data of Binary: uvarint length + content type, uvarint length + raw bytes, bit 5 is not set.
+//   - bit 13 QoS: zigzag varint.
+var BinaryCodec = binaryCodec{}
+
+const (
+	binaryMagic   byte = 0x47
+	binaryVersion byte = 0x01
+
+	binaryHeaderLen = 4
+)
+
+const (
+	flagVer uint16 = 1 << iota
+	flagSeq
+	flagAction
+	flagFrom
+	flagTo
+	flagData
+	flagMsg
+	flagTicket
+	flagSign
+	flagExtra
+	flagReplyTo
+	flagDeliverAt
+	flagBinary
+	flagQoS
+)
+
+// scratchPool the encoding scratch space, the encoded message is copied out of it.
+var scratchPool = pool.New("binary_scratch", func() interface{} {
+	b := make([]byte, 0, 256)
+	return &b
+})
+
+type binaryCodec struct {
+}
+
+func (b binaryCodec) Encode(i interface{}) ([]byte, error) {
+	m, ok := i.(*GlideMessage)
+	if !ok {
+		return nil, errors.New("illegal argument, binary codec only supports *GlideMessage")
+	}
+
+	var flags uint16
+	scratch := scratchPool.Get().(*[]byte)
+	buf := append((*scratch)[:0], 0, 0, 0, 0)
+	defer func() {
+		// keep the grown buffer in pool.
+		if cap(buf) <= pool.MaxBufferSize {
+			*scratch = buf[:0]
+			scratchPool.Put(scratch)
+		}
+	}()
+
+	if m.Ver != 0 {
+		flags |= flagVer
+		buf = appendVarint(buf, m.Ver)
+	}
+	if m.Seq != 0 {
+		flags |= flagSeq
+		buf = appendVarint(buf, m.Seq)
+	}
+	if m.Action != "" {
+		flags |= flagAction
+		buf = appendString(buf, m.Action)
+	}
+	if m.From != "" {
+		flags |= flagFrom
+		buf = appendString(buf, m.From)
+	}
+	if m.To != "" {
+		flags |= flagTo
+		buf = appendString(buf, m.To)
+	}
+	bin, isBinary := m.Data.Binary()
+	if !isBinary && m.Data != nil {
+		data, err := m.Data.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		flags |= flagData
+		buf = appendBytes(buf, data)
+	}
+	if m.Msg != "" {
+		flags |= flagMsg
+		buf = appendString(buf, m.Msg)
+	}
+	if m.Ticket != "" {
+		flags |= flagTicket
+		buf = appendString(buf, m.Ticket)
+	}
+	if m.Sign != "" {
+		flags |= flagSign
+		buf = appendString(buf, m.Sign)
+	}
+	if len(m.Extra) != 0 {
+		flags |= flagExtra
+		keys := make([]string, 0, len(m.Extra))
+		for k := range m.Extra {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf = appendUvarint(buf, uint64(len(keys)))
+		for _, k := range keys {
+			buf = appendString(buf, k)
+			buf = appendString(buf, m.Extra[k])
+		}
+	}
+	if m.ReplyTo != 0 {
+		flags |= flagReplyTo
+		buf = appendVarint(buf, m.ReplyTo)
+	}
+	if m.DeliverAt != 0 {
+		flags |= flagDeliverAt
+		buf = appendVarint(buf, m.DeliverAt)
+	}
+	if isBinary {
+		flags |= flagBinary
+		buf = appendString(buf, bin.ContentType)
+		buf = appendBytes(buf, bin.Data)
+	}
+	if m.QoS != 0 {
+		flags |= flagQoS
+		buf = appendVarint(buf, int64(m.QoS))
+	}
+
+	buf[0] = binaryMagic
+	buf[1] = binaryVersion
+	binary.BigEndian.PutUint16(buf[2:], flags)
+	return append(make([]byte, 0, len(buf)), buf...), nil
+}
+
+func (b binaryCodec) Decode(data []byte, i interface{}) error {
+	m, ok := i.(*GlideMessage)
+	if !ok {
+		return errors.New("illegal argument, binary codec only supports *GlideMessage")
+	}
+	if len(data) < binaryHeaderLen {
+		return errors.New(errDecode + "binary message too short")
+	}
+	if data[0] != binaryMagic {
+		return errors.New(errDecode + "invalid binary message magic")
+	}
+	if data[1] != binaryVersion {
+		return errors.New(errDecode + "unsupported binary message version")
+	}
+	flags := binary.BigEndian.Uint16(data[2:])
+	if flags >= flagQoS<<1 || flags&(flagData|flagBinary) == flagData|flagBinary {
+		return errors.New(errDecode + "unknown binary message flags")
+	}
+
+	r := binaryReader{b: data[binaryHeaderLen:]}
+	if flags&flagVer != 0 {
+		m.Ver = r.varint()
+	}
+	if flags&flagSeq != 0 {
+		m.Seq = r.varint()
+	}
+	if flags&flagAction != 0 {
+		m.Action = r.string()
+	}
+	if flags&flagFrom != 0 {
+		m.From = r.string()
+	}
+	if flags&flagTo != 0 {
+		m.To = r.string()
+	}
+	if flags&flagData != 0 {
+		m.Data = NewData(r.bytes())
+	}
+	if flags&flagMsg != 0 {
+		m.Msg = r.string()
+	}
+	if flags&flagTicket != 0 {
+		m.Ticket = r.string()
+	}
+	if flags&flagSign != 0 {
+		m.Sign = r.string()
+	}
+	if flags&flagExtra != 0 {
+		n := r.uvarint()
+		if n > uint64(len(r.b)) {
+			return errors.New(errDecode + "invalid binary message extra count")
+		}
+		m.Extra = make(map[string]string, n)
+		for j := uint64(0); j < n && r.err == nil; j++ {
+			k := r.string()
+			m.Extra[k] = r.string()
+		}
+	}
+	if flags&flagReplyTo != 0 {
+		m.ReplyTo = r.varint()
+	}
+	if flags&flagDeliverAt != 0 {
+		m.DeliverAt = r.varint()
+	}
+	if flags&flagBinary != 0 {
+		bin := &Binary{ContentType: r.string()}
+		bin.Data = r.bytes()
+		m.Data = NewData(bin)
+	}
+	if flags&flagQoS != 0 {
+		m.QoS = QoS(r.varint())
+	}
+	if r.err != nil {
+		return errors.New(errDecode + r.err.Error())
+	}
+	if len(r.b) != 0 {
+		return errors.New(errDecode + "unexpected trailing bytes of binary message")
+	}
+	return nil
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	return append(buf, b[:n]...)
+}
+
+func appendVarint(buf []byte, v int64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	return append(buf, b[:n]...)
+}
+
+func appendBytes(buf []byte, p []byte) []byte {
+	buf = appendUvarint(buf, uint64(len(p)))
+	return append(buf, p...)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// binaryReader reads fields sequentially, the first error is kept and stops subsequent reads.
+type binaryReader struct {
+	b   []byte
+	err error
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errors.New("invalid uvarint")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *binaryReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = errors.New("invalid varint")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *binaryReader) bytes() []byte {
+	l := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if l > uint64(len(r.b)) {
+		r.err = errors.New("field length out of range")
+		return nil
+	}
+	p := make([]byte, l)
+	copy(p, r.b[:l])
+	r.b = r.b[l:]
+	return p
+}
+
+func (r *binaryReader) string() string {
+	return string(r.bytes())
+}
@@ -0,0 +1,114 @@
+package messages
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// conformance vectors of the binary subprotocol, client implementations should produce and accept the same bytes.
+var binaryCodecVectors = []struct {
+	name string
+	msg  *GlideMessage
+	hex  string
+}{
+	{
+		name: "empty",
+		msg:  &GlideMessage{},
+		hex:  "47010000",
+	},
+	{
+		name: "heartbeat",
+		msg:  &GlideMessage{Ver: 1, Seq: -1, Action: "heartbeat"},
+		hex:  "47010007020109686561727462656174",
+	},
+	{
+		name: "chat with data",
+		msg:  &GlideMessage{Action: "message.chat", To: "2", Data: NewData([]byte(`{"content":"hi"}`))},
+		hex:  "47010034" + "0c" + hex.EncodeToString([]byte("message.chat")) + "0132" + "10" + hex.EncodeToString([]byte(`{"content":"hi"}`)),
+	},
+	{
+		name: "binary data",
+		msg:  &GlideMessage{Action: "a", Data: NewBinaryData("a/b", []byte{1, 2, 3})},
+		hex:  "47011004" + "0161" + "03612f62" + "03010203",
+	},
+	{
+		name: "extra sorted by key",
+		msg:  &GlideMessage{Extra: map[string]string{"b": "2", "a": "1"}},
+		hex:  "47010200" + "02" + "0161" + "0131" + "0162" + "0132",
+	},
+}
+
+func TestBinaryCodec_Vectors(t *testing.T) {
+	for _, v := range binaryCodecVectors {
+		t.Run(v.name, func(t *testing.T) {
+			encoded, err := BinaryCodec.Encode(v.msg)
+			assert.NoError(t, err)
+			assert.Equal(t, v.hex, hex.EncodeToString(encoded))
+
+			b, _ := hex.DecodeString(v.hex)
+			decoded := &GlideMessage{}
+			assert.NoError(t, BinaryCodec.Decode(b, decoded))
+			assert.Equal(t, v.msg.Ver, decoded.Ver)
+			assert.Equal(t, v.msg.Seq, decoded.Seq)
+			assert.Equal(t, v.msg.Action, decoded.Action)
+			assert.Equal(t, v.msg.To, decoded.To)
+			assert.Equal(t, v.msg.Extra, decoded.Extra)
+			if v.msg.Data != nil {
+				assert.Equal(t, v.msg.Data.GetData(), decoded.Data.GetData())
+			}
+		})
+	}
+}
+
+func TestBinaryCodec_RoundTrip(t *testing.T) {
+	m := NewMessage(12, ActionChatMessage, &ChatMessage{Mid: 1, From: "1", To: "2", Content: "hello"})
+	m.From = "1"
+	m.To = "2"
+	m.Msg = "msg"
+	m.Ticket = "ticket"
+	m.Sign = "sign"
+	m.Extra = map[string]string{"k": "v"}
+	m.ReplyTo = 11
+	m.DeliverAt = 1700000000
+	m.QoS = QoSOnlineOnly
+
+	encoded, err := BinaryCodec.Encode(m)
+	assert.NoError(t, err)
+
+	decoded := NewEmptyMessage()
+	assert.NoError(t, BinaryCodec.Decode(encoded, decoded))
+
+	cm := ChatMessage{}
+	assert.NoError(t, decoded.Data.Deserialize(&cm))
+	assert.Equal(t, "hello", cm.Content)
+	assert.Equal(t, m.Seq, decoded.Seq)
+	assert.Equal(t, m.From, decoded.From)
+	assert.Equal(t, m.Msg, decoded.Msg)
+	assert.Equal(t, m.Ticket, decoded.Ticket)
+	assert.Equal(t, m.Sign, decoded.Sign)
+	assert.Equal(t, m.Extra, decoded.Extra)
+	assert.Equal(t, m.ReplyTo, decoded.ReplyTo)
+	assert.Equal(t, m.DeliverAt, decoded.DeliverAt)
+	assert.Equal(t, m.QoS, decoded.QoS)
+}
+
+func TestBinaryCodec_DecodeMalformed(t *testing.T) {
+	malformed := map[string]string{
+		"too short":         "4701",
+		"bad magic":         "48010000",
+		"bad version":       "47020000",
+		"unknown flags":     "47011000",
+		"truncated varint":  "4701000180",
+		"length overflow":   "4701000405616263",
+		"trailing bytes":    "47010000ff",
+		"extra count large": "47010200ff01",
+	}
+	for name, h := range malformed {
+		t.Run(name, func(t *testing.T) {
+			b, _ := hex.DecodeString(h)
+			err := BinaryCodec.Decode(b, &GlideMessage{})
+			assert.True(t, IsDecodeError(err), "%v", err)
+		})
+	}
+}
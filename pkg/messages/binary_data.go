@@ -0,0 +1,73 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Binary the raw bytes payload of GlideMessage.Data, such as protobuf encoded app payloads and thumbnails. The bytes
+// are carried as is by BinaryCodec, and base64 encoded by JsonCodec as {"$binary": "", "$content_type": ""}.
+type Binary struct {
+	// ContentType the hint of the payload format, such as application/x-protobuf or image/jpeg.
+	ContentType string
+	Data        []byte
+}
+
+// binaryJson the json representation of Binary.
+type binaryJson struct {
+	Data        []byte `json:"$binary"`
+	ContentType string `json:"$content_type,omitempty"`
+}
+
+// binaryJsonKey the key present in json of Binary only, used to detect binary payloads before unmarshal.
+var binaryJsonKey = []byte(`"$binary"`)
+
+func (b *Binary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&binaryJson{Data: b.Data, ContentType: b.ContentType})
+}
+
+func (b *Binary) UnmarshalJSON(data []byte) error {
+	j := binaryJson{}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	b.Data = j.Data
+	b.ContentType = j.ContentType
+	return nil
+}
+
+// NewBinaryData returns the Data of raw bytes with the content type hint.
+func NewBinaryData(contentType string, data []byte) *Data {
+	return NewData(&Binary{ContentType: contentType, Data: data})
+}
+
+// Binary returns the binary payload if the data is Binary, the data decoded from json is parsed if it's in the json
+// representation of Binary, the json object has fields other than $binary and $content_type is not Binary.
+func (d *Data) Binary() (*Binary, bool) {
+	if d == nil {
+		return nil, false
+	}
+	switch v := d.des.(type) {
+	case *Binary:
+		return v, true
+	case []byte:
+		if !bytes.Contains(v, binaryJsonKey) {
+			return nil, false
+		}
+		fields := map[string]json.RawMessage{}
+		if json.Unmarshal(v, &fields) != nil {
+			return nil, false
+		}
+		for k := range fields {
+			if k != "$binary" && k != "$content_type" {
+				return nil, false
+			}
+		}
+		j := binaryJson{}
+		if json.Unmarshal(v, &j) != nil || j.Data == nil {
+			return nil, false
+		}
+		return &Binary{ContentType: j.ContentType, Data: j.Data}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,34 @@
+package messages
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestData_Binary(t *testing.T) {
+	m := &GlideMessage{Action: string(ActionClientCustom), Data: NewBinaryData("application/x-protobuf", []byte{0, 1, 0xff})}
+	encoded, err := JsonCodec.Encode(m)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"data":{"$binary":"AAH/","$content_type":"application/x-protobuf"}`)
+
+	// the binary payload decoded from json is carried as raw bytes by the binary codec.
+	decoded := NewEmptyMessage()
+	assert.NoError(t, JsonCodec.Decode(encoded, decoded))
+	bin, ok := decoded.Data.Binary()
+	assert.True(t, ok)
+	assert.Equal(t, []byte{0, 1, 0xff}, bin.Data)
+
+	b, err := BinaryCodec.Encode(decoded)
+	assert.NoError(t, err)
+	decoded = NewEmptyMessage()
+	assert.NoError(t, BinaryCodec.Decode(b, decoded))
+	got := Binary{}
+	assert.NoError(t, decoded.Data.Deserialize(&got))
+	assert.Equal(t, "application/x-protobuf", got.ContentType)
+
+	_, ok = NewData([]byte(`{"content":"hi"}`)).Binary()
+	assert.False(t, ok)
+	// the json mixes the binary with other fields is carried as json.
+	_, ok = NewData([]byte(`{"$binary":"AAH/","content":"hi"}`)).Binary()
+	assert.False(t, ok)
+}
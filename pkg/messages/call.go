@@ -0,0 +1,29 @@
+package messages
+
+// Media types of call.
+const (
+	CallMediaAudio = "audio"
+	CallMediaVideo = "video"
+)
+
+// Reasons of call.reject and call.hangup sent by server.
+const (
+	CallReasonBusy    = "busy"
+	CallReasonTimeout = "timeout"
+	CallReasonOffline = "offline"
+)
+
+// CallSignal the data of call signaling actions, the server validates the state of call and relays the signal to the
+// peer, the SDP and ICE candidate are opaque to the server.
+type CallSignal struct {
+	// CallID the id of call, assigned by server when invite.
+	CallID string `json:"call_id"`
+	// Media audio or video, required when invite.
+	Media string `json:"media,omitempty"`
+	// SDP the session description of the offer or answer.
+	SDP string `json:"sdp,omitempty"`
+	// Candidate the ICE candidate.
+	Candidate string `json:"candidate,omitempty"`
+	// Reason the reason of reject or hangup.
+	Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,72 @@
+package messages
+
+import (
+	"encoding/base64"
+	"sync/atomic"
+)
+
+// Capabilities of clients, the client declares capabilities it supports on authenticate, and the server adapts the
+// messages sent to it, such as reaction events are not sent to the client which can't render them. The client which
+// declares nothing is treated as supporting all capabilities.
+const (
+	// CapabilityReactions the client renders reactions, see ActionMessageReact.
+	CapabilityReactions = "reactions"
+	// CapabilityBinaryCodec the client decodes the Binary payload of Data, it's sent as the base64 string otherwise.
+	CapabilityBinaryCodec = "binary_codec"
+	// CapabilityResume the client resumes the session by the resume token, see ActionResume.
+	CapabilityResume = "resume"
+	// CapabilityRemoteConfig the client applies the config pushed by the server, see ActionNotifyConfig.
+	CapabilityRemoteConfig = "remote_config"
+)
+
+// actionCapabilities the capability required to handle the action.
+var actionCapabilities = map[Action]string{
+	ActionMessageReact:        CapabilityReactions,
+	ActionMessageUnreact:      CapabilityReactions,
+	ActionGroupMessageReact:   CapabilityReactions,
+	ActionGroupMessageUnreact: CapabilityReactions,
+	ActionResume:              CapabilityResume,
+	ActionNotifyConfig:        CapabilityRemoteConfig,
+}
+
+// disabledFeatures the set of features and capabilities disabled, map[string]bool.
+var disabledFeatures atomic.Value
+
+// RegisterActionCapability sets the capability required by the client to handle the action, it's not safe for
+// concurrent use and should be called at init.
+func RegisterActionCapability(action Action, capability string) {
+	actionCapabilities[action] = capability
+}
+
+// CapabilityOf returns the capability required by the client to handle the action, empty if none.
+func CapabilityOf(action Action) string {
+	return actionCapabilities[action]
+}
+
+// SetDisabledFeatures disables features of protocol and capabilities cluster-wide, such as FeatureBatch and
+// CapabilityReactions, the features disabled before are enabled again. Actions of disabled capabilities are neither
+// accepted from nor sent to clients.
+func SetDisabledFeatures(features []string) {
+	disabled := make(map[string]bool, len(features))
+	for _, f := range features {
+		disabled[f] = true
+	}
+	disabledFeatures.Store(disabled)
+}
+
+// IsDisabled returns true if the feature or capability is disabled by SetDisabledFeatures.
+func IsDisabled(feature string) bool {
+	disabled, _ := disabledFeatures.Load().(map[string]bool)
+	return disabled[feature]
+}
+
+// StripBinary replaces the Binary payload with the base64 string of bytes, for clients can't decode it.
+func StripBinary(m *GlideMessage) *GlideMessage {
+	bin, ok := m.Data.Binary()
+	if !ok {
+		return m
+	}
+	c := CopyMessage(m)
+	c.Data = NewData(base64.StdEncoding.EncodeToString(bin.Data))
+	return c
+}
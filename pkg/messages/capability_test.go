@@ -0,0 +1,25 @@
+package messages
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSetDisabledFeatures(t *testing.T) {
+	defer SetDisabledFeatures(nil)
+
+	assert.True(t, HasFeature(ProtocolCurrent, FeatureBatch))
+	SetDisabledFeatures([]string{FeatureBatch, CapabilityReactions})
+	assert.False(t, HasFeature(ProtocolCurrent, FeatureBatch))
+	assert.NotContains(t, Features(ProtocolCurrent), FeatureBatch)
+	assert.True(t, IsDisabled(CapabilityReactions))
+
+	SetDisabledFeatures(nil)
+	assert.True(t, HasFeature(ProtocolCurrent, FeatureBatch))
+	assert.False(t, IsDisabled(CapabilityReactions))
+}
+
+func TestCapabilityOf(t *testing.T) {
+	assert.Equal(t, CapabilityReactions, CapabilityOf(ActionGroupMessageUnreact))
+	assert.Equal(t, "", CapabilityOf(ActionChatMessage))
+}
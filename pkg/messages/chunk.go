@@ -0,0 +1,32 @@
+package messages
+
+// Chunk a part of the message larger than the max message size of connection. The client encodes the message by the
+// codec of connection, splits the encoded bytes into chunks in order, and sends each chunk by ActionChunk, the gateway
+// reassembles the message and handles it as if it's sent in one piece.
+type Chunk struct {
+	// ID identifies the chunked message in the connection, chunks of a message share the same ID.
+	ID string `json:"id"`
+	// Index the index of chunk, in [0, Total).
+	Index int `json:"index"`
+	// Total the count of chunks of the message.
+	Total int `json:"total"`
+	// Data the part of encoded message.
+	Data []byte `json:"data"`
+}
+
+// SplitChunks splits the encoded message into chunks of the message id, the data of each chunk is at most size bytes.
+func SplitChunks(id string, data []byte, size int) []*Chunk {
+	if size <= 0 {
+		size = len(data)
+	}
+	total := (len(data) + size - 1) / size
+	chunks := make([]*Chunk, 0, total)
+	for i := 0; i < total; i++ {
+		end := (i + 1) * size
+		if end > len(data) {
+			end = len(data)
+		}
+		chunks = append(chunks, &Chunk{ID: id, Index: i, Total: total, Data: data[i*size : end]})
+	}
+	return chunks
+}
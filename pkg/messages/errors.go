@@ -0,0 +1,191 @@
+package messages
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Error the payload of error messages since ProtocolV3, such as ActionNotifyError and ActionApiFailed. Clients branch
+// on the Code or the Reason, the Message is for human and may be localized, see RegisterError.
+type Error struct {
+	// Code the numeric code of the error, see ErrorCode.
+	Code int `json:"code"`
+	// Reason the machine-readable reason of the Code, such as auth.credential_expired.
+	Reason string `json:"reason"`
+	// Message the human-readable text of the error.
+	Message string `json:"message"`
+	// Retryable true if the request may succeed when it's retried later.
+	Retryable bool `json:"retryable,omitempty"`
+	// Details the optional details of the error, such as the max size of the message too large.
+	Details map[string]string `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WithDetail sets the detail of the error and returns the error.
+func (e *Error) WithDetail(key string, value string) *Error {
+	if e.Details == nil {
+		e.Details = map[string]string{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// ErrorCode the code, reason and retryable flag shared by errors of the same cause. Codes 1000-1999 are errors of the
+// protocol, 2000-2999 of the authentication, 3000-3999 of the messaging, codes from 10000 are free for business
+// services.
+type ErrorCode struct {
+	Code      int    `json:"code"`
+	Reason    string `json:"reason"`
+	Retryable bool   `json:"retryable"`
+}
+
+// New returns the Error of the code with the text, it's used for texts not registered such as texts with variables.
+func (c ErrorCode) New(text string) *Error {
+	return &Error{
+		Code:      c.Code,
+		Reason:    c.Reason,
+		Message:   text,
+		Retryable: c.Retryable,
+	}
+}
+
+var (
+	ErrCodeUnknown          = ErrorCode{Code: 1000, Reason: "unknown"}
+	ErrCodeInvalidMessage   = ErrorCode{Code: 1001, Reason: "invalid_message"}
+	ErrCodeMessageTooLarge  = ErrorCode{Code: 1002, Reason: "message_too_large"}
+	ErrCodeUnsupported      = ErrorCode{Code: 1003, Reason: "unsupported"}
+	ErrCodeBusy             = ErrorCode{Code: 1004, Reason: "busy", Retryable: true}
+	ErrCodeRateLimited      = ErrorCode{Code: 1005, Reason: "rate_limited", Retryable: true}
+	ErrCodeNotFound         = ErrorCode{Code: 1006, Reason: "not_found"}
+	ErrCodePermissionDenied = ErrorCode{Code: 1007, Reason: "permission_denied"}
+	ErrCodeLimitExceeded    = ErrorCode{Code: 1008, Reason: "limit_exceeded"}
+
+	ErrCodeInvalidCredentials  = ErrorCode{Code: 2000, Reason: "auth.invalid_credentials"}
+	ErrCodeCredentialExpired   = ErrorCode{Code: 2001, Reason: "auth.credential_expired", Retryable: true}
+	ErrCodeConnectionsExceeded = ErrorCode{Code: 2002, Reason: "auth.connections_exceeded", Retryable: true}
+	ErrCodeChallengeRequired   = ErrorCode{Code: 2003, Reason: "auth.challenge_required"}
+	ErrCodeChallengeFailed     = ErrorCode{Code: 2004, Reason: "auth.challenge_failed", Retryable: true}
+	ErrCodeTicketInvalid       = ErrorCode{Code: 2005, Reason: "auth.ticket_invalid"}
+	ErrCodeResumeInvalid       = ErrorCode{Code: 2006, Reason: "auth.resume_invalid"}
+	ErrCodeSignInvalid         = ErrorCode{Code: 2007, Reason: "auth.sign_invalid"}
+
+	ErrCodeMessageBlocked  = ErrorCode{Code: 3000, Reason: "message.blocked"}
+	ErrCodeNoReceiver      = ErrorCode{Code: 3001, Reason: "message.no_receiver"}
+	ErrCodeRelationBlocked = ErrorCode{Code: 3002, Reason: "relation.blocked"}
+	ErrCodeNotContact      = ErrorCode{Code: 3003, Reason: "relation.not_contact"}
+	ErrCodeWindowExpired   = ErrorCode{Code: 3004, Reason: "message.window_expired"}
+	ErrCodeInvalidState    = ErrorCode{Code: 3005, Reason: "invalid_state"}
+)
+
+var errorRegistry = struct {
+	sync.RWMutex
+	// texts the error text => code.
+	texts map[string]ErrorCode
+	codes map[int]ErrorCode
+}{
+	texts: map[string]ErrorCode{},
+	codes: map[int]ErrorCode{},
+}
+
+func init() {
+	RegisterError(ErrCodeUnknown)
+	RegisterError(ErrCodeUnsupported, errUnsupportedProtocol)
+	RegisterError(ErrCodeInvalidMessage, errBatchEmpty, errBatchCompressed)
+	RegisterError(ErrCodeLimitExceeded, errBatchTooMany)
+}
+
+// RegisterError registers the code of error texts, the text is the error string of the error sent to clients, such as
+// the error returned by the handler of the action. It panics if the code number is registered with different reason.
+func RegisterError(code ErrorCode, texts ...string) {
+	errorRegistry.Lock()
+	defer errorRegistry.Unlock()
+	if c, ok := errorRegistry.codes[code.Code]; ok && c != code {
+		panic(fmt.Sprintf("error code %d already registered as %s", code.Code, c.Reason))
+	}
+	errorRegistry.codes[code.Code] = code
+	for _, text := range texts {
+		errorRegistry.texts[text] = code
+	}
+}
+
+// LookupError returns the code registered of the error text, ErrCodeUnknown if it's not registered.
+func LookupError(text string) ErrorCode {
+	errorRegistry.RLock()
+	defer errorRegistry.RUnlock()
+	if c, ok := errorRegistry.texts[text]; ok {
+		return c
+	}
+	return ErrCodeUnknown
+}
+
+// ErrorCodes returns the registered codes sorted by the code number.
+func ErrorCodes() []ErrorCode {
+	errorRegistry.RLock()
+	defer errorRegistry.RUnlock()
+	codes := make([]ErrorCode, 0, len(errorRegistry.codes))
+	for _, c := range errorRegistry.codes {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].Code < codes[j].Code
+	})
+	return codes
+}
+
+// NewError returns the Error of the text with the code registered.
+func NewError(text string) *Error {
+	return LookupError(text).New(text)
+}
+
+// NewErrorMessage returns the message of the action with the Error of the text, such as ActionNotifyError.
+func NewErrorMessage(seq int64, action Action, text string) *GlideMessage {
+	return NewMessage(seq, action, NewError(text))
+}
+
+// NewErrorReply returns the ActionApiFailed reply of the request with the Error of the text.
+func NewErrorReply(req *GlideMessage, text string) *GlideMessage {
+	return NewReply(req, ActionApiFailed, NewError(text))
+}
+
+// IsErrorAction returns true if the payload of the action is the Error, the text before ProtocolV3.
+func IsErrorAction(action Action) bool {
+	switch action {
+	case ActionNotifyError, ActionNotifyForbidden, ActionNotifyKickOut, ActionApiFailed:
+		return true
+	}
+	return false
+}
+
+// ErrorOf returns the Error of the error message, the text payload sent before ProtocolV3 is resolved by the codes
+// registered, false if the message is not an error or the payload is not an error.
+func ErrorOf(m *GlideMessage) (*Error, bool) {
+	if m == nil || !IsErrorAction(m.GetAction()) || m.Data == nil {
+		return nil, false
+	}
+	switch v := m.Data.GetData().(type) {
+	case *Error:
+		return v, true
+	case string:
+		return NewError(v), true
+	case []byte:
+		v = bytes.TrimSpace(v)
+		if bytes.HasPrefix(v, []byte("{")) {
+			e := &Error{}
+			if JsonCodec.Decode(v, e) != nil || e.Code == 0 {
+				return nil, false
+			}
+			return e, true
+		}
+		var text string
+		if JsonCodec.Decode(v, &text) != nil {
+			return nil, false
+		}
+		return NewError(text), true
+	}
+	return nil, false
+}
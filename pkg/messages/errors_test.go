@@ -0,0 +1,49 @@
+package messages
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterError(t *testing.T) {
+	code := ErrorCode{Code: 10001, Reason: "test.registered", Retryable: true}
+	RegisterError(code, "test error registered")
+
+	e := NewError("test error registered")
+	assert.Equal(t, 10001, e.Code)
+	assert.Equal(t, "test.registered", e.Reason)
+	assert.True(t, e.Retryable)
+	assert.Contains(t, ErrorCodes(), code)
+
+	assert.Equal(t, ErrCodeUnknown, LookupError("test error not registered"))
+	assert.Panics(t, func() {
+		RegisterError(ErrorCode{Code: 10001, Reason: "test.conflict"})
+	})
+}
+
+func TestErrorOf(t *testing.T) {
+	m := NewErrorReply(NewMessage(3, ActionApiSubUserState, nil), errUnsupportedProtocol)
+	e, ok := ErrorOf(m)
+	assert.True(t, ok)
+	assert.Equal(t, ErrCodeUnsupported.Code, e.Code)
+	assert.Equal(t, int64(3), m.ReplyTo)
+
+	// the payload decoded from the wire.
+	b, err := JsonCodec.Encode(NewMessage(1, ActionNotifyError, NewError(errUnsupportedProtocol).WithDetail("max", "3")))
+	assert.NoError(t, err)
+	decoded := NewEmptyMessage()
+	assert.NoError(t, JsonCodec.Decode(b, decoded))
+	e, ok = ErrorOf(decoded)
+	assert.True(t, ok)
+	assert.Equal(t, ErrCodeUnsupported.Reason, e.Reason)
+	assert.Equal(t, "3", e.Details["max"])
+
+	// the text payload sent before ProtocolV3.
+	e, ok = ErrorOf(NewMessage(1, ActionNotifyError, errUnsupportedProtocol))
+	assert.True(t, ok)
+	assert.Equal(t, ErrCodeUnsupported.Code, e.Code)
+
+	_, ok = ErrorOf(NewMessage(1, ActionChatMessage, errUnsupportedProtocol))
+	assert.False(t, ok)
+}
@@ -1,9 +1,13 @@
 package messages
 
 type Hello struct {
+	// Protocol the protocol version the client speaks, 0 for ProtocolV1, see NegotiateProtocol.
+	Protocol      int64  `json:"protocol,omitempty"`
 	ClientVersion string `json:"client_version,omitempty"`
 	ClientName    string `json:"client_name,omitempty"`
 	ClientType    string `json:"client_type,omitempty"`
+	// Locale the language of the client, such as zh-CN, texts generated by the server are sent in it.
+	Locale string `json:"locale,omitempty"`
 }
 
 type ServerHello struct {
@@ -11,4 +15,33 @@ type ServerHello struct {
 	TempID            string   `json:"temp_id,omitempty"`
 	HeartbeatInterval int      `json:"heartbeat_interval,omitempty"`
 	Protocols         []string `json:"protocols,omitempty"`
+	// Protocol the protocol version the server speaks, the client uses the lower of it and the client version.
+	Protocol int64 `json:"protocol,omitempty"`
+	// Features the features enabled by Protocol.
+	Features []string `json:"features,omitempty"`
+}
+
+// Types of Challenge.
+const (
+	// ChallengeTypePoW proof-of-work, client finds a Solution that the sha256 of Nonce+Solution has Difficulty leading
+	// zero bits.
+	ChallengeTypePoW = "pow"
+	// ChallengeTypeCaptcha client answers with the Token of a CAPTCHA solved by the user, which is validated by the
+	// CAPTCHA provider.
+	ChallengeTypeCaptcha = "captcha"
+)
+
+// Challenge the anti-abuse challenge sent to client after hello, authenticate is rejected until it's solved.
+type Challenge struct {
+	Type       string `json:"type"`
+	Nonce      string `json:"nonce,omitempty"`
+	Difficulty int    `json:"difficulty,omitempty"`
+	// SiteKey the public key of CAPTCHA provider to render the CAPTCHA.
+	SiteKey string `json:"site_key,omitempty"`
+}
+
+// ChallengeAnswer the answer of Challenge from client.
+type ChallengeAnswer struct {
+	Solution string `json:"solution,omitempty"`
+	Token    string `json:"token,omitempty"`
 }
@@ -0,0 +1,97 @@
+package messages
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Types of ChatMessage, the Content of media message is the json of MediaContent.
+const (
+	MessageTypeText  int32 = 1
+	MessageTypeImage int32 = 2
+	MessageTypeFile  int32 = 3
+	MessageTypeAudio int32 = 4
+)
+
+// IsMediaType returns true if the type of ChatMessage is a media type.
+func IsMediaType(t int32) bool {
+	return t == MessageTypeImage || t == MessageTypeFile || t == MessageTypeAudio
+}
+
+// MediaContent the metadata of media uploaded to storage, the binary is never sent through message channel.
+type MediaContent struct {
+	// URL the url to download the media.
+	URL string `json:"url"`
+	// Key the object key of the media in storage, returned by UploadToken.
+	Key string `json:"key,omitempty"`
+	// Name the file name.
+	Name string `json:"name,omitempty"`
+	// Size the size in bytes.
+	Size int64 `json:"size"`
+	// Mime the mime type, such as image/png.
+	Mime string `json:"mime"`
+	// Width and Height the dimensions in pixels of image.
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// Duration the milliseconds of audio.
+	Duration int64 `json:"duration,omitempty"`
+	// Thumbnail the url of thumbnail of image.
+	Thumbnail string `json:"thumbnail,omitempty"`
+}
+
+// Validate checks the required metadata of the media type.
+func (m *MediaContent) Validate(t int32) error {
+	if m.URL == "" || m.Mime == "" || m.Size <= 0 {
+		return errors.New("media url, mime and size are required")
+	}
+	switch t {
+	case MessageTypeImage:
+		if m.Width <= 0 || m.Height <= 0 {
+			return errors.New("image dimensions are required")
+		}
+	case MessageTypeAudio:
+		if m.Duration <= 0 {
+			return errors.New("audio duration is required")
+		}
+	}
+	return nil
+}
+
+// Media decodes the content of media message.
+func (c *ChatMessage) Media() (*MediaContent, error) {
+	if !IsMediaType(c.Type) {
+		return nil, errors.New("not a media message")
+	}
+	m := &MediaContent{}
+	err := json.Unmarshal([]byte(c.Content), m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UploadRequest requests an upload token of action ActionApiUploadToken.
+type UploadRequest struct {
+	// Type the message type of the media, MessageTypeImage, MessageTypeFile or MessageTypeAudio.
+	Type int32  `json:"type"`
+	Name string `json:"name,omitempty"`
+	Size int64  `json:"size"`
+	Mime string `json:"mime"`
+}
+
+// UploadToken the pre-signed upload url, client uploads the media by it then sends the media message with Key and
+// DownloadURL.
+type UploadToken struct {
+	// Method the http method to upload, such as PUT.
+	Method string `json:"method"`
+	// URL the pre-signed url to upload.
+	URL string `json:"url"`
+	// Headers the headers must be sent with the upload request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Key the object key of the media in storage.
+	Key string `json:"key"`
+	// DownloadURL the url to download the media after uploaded.
+	DownloadURL string `json:"download_url"`
+	// ExpiresAt the unix seconds the upload url expires.
+	ExpiresAt int64 `json:"expires_at"`
+}
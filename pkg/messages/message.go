@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/glide-im/glide/pkg/pool"
 	"reflect"
 )
 
@@ -19,12 +20,35 @@ type GlideMessage struct {
 	Data   *Data  `json:"data,omitempty"`
 	Msg    string `json:"msg,omitempty"`
 
+	// ReplyTo the seq of the request this message responds to, see Requester.
+	ReplyTo int64 `json:"reply_to,omitempty"`
+	// DeliverAt the unix seconds to deliver the message at, the message is delivered immediately if it's not in the
+	// future, see Scheduled.
+	DeliverAt int64 `json:"deliver_at,omitempty"`
+	// QoS the delivery guarantee of the chat message, see GetQoS.
+	QoS QoS `json:"qos,omitempty"`
+
 	Ticket string `json:"ticket,omitempty"`
 	Sign   string `json:"sign,omitempty"`
 
 	Extra map[string]string `json:"extra,omitempty"`
+
+	// Priority the lane of the message in the client send queue, it's not sent to client, see GetPriority.
+	Priority Priority `json:"-"`
+	// Bypass true if the message is sent with the bypass ticket, relation requirements such as contacts are skipped,
+	// it's set by the gateway after the ticket verified and never decoded from client.
+	Bypass bool `json:"-"`
+
+	// serialized the encoded bytes cache, set by Serialize.
+	serialized *Serialized
+	// pooled true if the message is got from messagePool.
+	pooled bool
 }
 
+var messagePool = pool.New("message", func() interface{} {
+	return &GlideMessage{}
+})
+
 func NewMessage(seq int64, action Action, data interface{}) *GlideMessage {
 	return &GlideMessage{
 		Ver:    messageVersion,
@@ -35,6 +59,27 @@ func NewMessage(seq int64, action Action, data interface{}) *GlideMessage {
 	}
 }
 
+// NewPooledMessage returns a message from pool, the message must be sent to one client only, it's put back to pool by
+// ReleaseMessage after written to connection.
+func NewPooledMessage(seq int64, action Action, data interface{}) *GlideMessage {
+	m := messagePool.Get().(*GlideMessage)
+	m.Ver = messageVersion
+	m.Seq = seq
+	m.Action = string(action)
+	m.Data = NewData(data)
+	m.pooled = true
+	return m
+}
+
+// ReleaseMessage puts the message created by NewPooledMessage back to pool, other messages are ignored.
+func ReleaseMessage(m *GlideMessage) {
+	if m == nil || !m.pooled {
+		return
+	}
+	*m = GlideMessage{}
+	messagePool.Put(m)
+}
+
 func NewEmptyMessage() *GlideMessage {
 	return &GlideMessage{
 		Ver:   messageVersion,
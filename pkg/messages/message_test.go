@@ -51,3 +51,47 @@ func TestData_MarshalJSON(t *testing.T) {
 
 	assert.Equal(t, s, data.des)
 }
+
+func TestReleaseMessage(t *testing.T) {
+	m := NewPooledMessage(1, ActionHeartbeat, nil)
+	m.Extra = map[string]string{"k": "v"}
+	ReleaseMessage(m)
+	assert.Equal(t, GlideMessage{}, *m)
+
+	// not pooled message is ignored.
+	m = NewMessage(1, ActionHeartbeat, nil)
+	ReleaseMessage(m)
+	assert.Equal(t, int64(1), m.Seq)
+}
+
+// sink keeps the benchmark messages escape to heap like the messages sent through the client queue.
+var sink *GlideMessage
+
+// BenchmarkNewPooledMessage the allocations of server heartbeat messages, it's sent to every connection periodically.
+func BenchmarkNewPooledMessage(b *testing.B) {
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sink = NewMessage(0, ActionHeartbeat, nil)
+			_, _ = BinaryCodec.Encode(sink)
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sink = NewPooledMessage(0, ActionHeartbeat, nil)
+			_, _ = BinaryCodec.Encode(sink)
+			ReleaseMessage(sink)
+		}
+	})
+}
+
+func TestSplitChunks(t *testing.T) {
+	chunks := SplitChunks("1", []byte("abcdefg"), 3)
+	assert.Len(t, chunks, 3)
+	assert.Equal(t, "g", string(chunks[2].Data))
+	for i, c := range chunks {
+		assert.Equal(t, i, c.Index)
+		assert.Equal(t, 3, c.Total)
+	}
+}
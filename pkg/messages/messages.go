@@ -23,6 +23,168 @@ type ChatMessage struct {
 	Content string `json:"content,omitempty"`
 	/// message send time, server store message time.
 	SendAt int64 `json:"sendAt,omitempty"`
+	/// the seconds the message waits for the offline receiver, it's dropped from the offline queue when expired,
+	/// 0 never expires.
+	TTL int64 `json:"ttl,omitempty"`
+	/// server message id of the message replied, 0 if the message is not a reply.
+	Parent int64 `json:"parent,omitempty"`
+	/// server message id of the root message of the thread the reply belongs to, set by server from the parent.
+	Thread int64 `json:"thread,omitempty"`
+	/// uids mentioned in the channel message, MentionAll mentions all subscribers, parsed from the text content by
+	/// server if it's empty.
+	Mentions []string `json:"mentions,omitempty"`
+}
+
+// MentionAll the mention of all subscribers of the channel, only admins of the channel can mention all.
+const MentionAll = "all"
+
+// MentionNotify notifies the user mentioned in the channel message.
+type MentionNotify struct {
+	/// the channel id
+	Channel string `json:"channel,omitempty"`
+	/// true if the message mentions all subscribers
+	All bool `json:"all,omitempty"`
+	/// the message mentioned the user
+	Message *ChatMessage `json:"message,omitempty"`
+}
+
+// RecallMessage recall a message sent by self, and the notification of the message recalled.
+type RecallMessage struct {
+	/// server message id of the message to recall.
+	Mid int64 `json:"mid,omitempty"`
+	/// sender of the recalled message
+	From string `json:"from,omitempty"`
+	/// receiver or channel id of the recalled message
+	To string `json:"to,omitempty"`
+	/// recall time, unix seconds
+	RecallAt int64 `json:"recallAt,omitempty"`
+}
+
+// ReadReceipt reports the highest sequence read in a conversation, and the notification of the peer read.
+type ReadReceipt struct {
+	/// the reader
+	From string `json:"from,omitempty"`
+	/// receiver or channel id of the conversation
+	To string `json:"to,omitempty"`
+	/// the highest sequence read
+	Seq int64 `json:"seq,omitempty"`
+	/// read time, unix seconds
+	ReadAt int64 `json:"readAt,omitempty"`
+}
+
+// ReadCursor is the read position of a user in a conversation.
+type ReadCursor struct {
+	/// conversation id
+	Conversation string `json:"conversation,omitempty"`
+	/// the highest sequence read
+	Seq int64 `json:"seq,omitempty"`
+	/// read time, unix seconds
+	ReadAt int64 `json:"readAt,omitempty"`
+}
+
+// ReadCount is the count of participants who have read the message of sequence in a channel.
+type ReadCount struct {
+	/// channel id
+	To string `json:"to,omitempty"`
+	/// the sequence of message
+	Seq int64 `json:"seq,omitempty"`
+	/// the count of readers
+	Count int64 `json:"count,omitempty"`
+}
+
+// ReadReaders the subscribers of the channel who have read the message of sequence, the reader list is queried in
+// channels not larger than the limit of the server only, use ReadCount in larger channels.
+type ReadReaders struct {
+	/// channel id
+	To string `json:"to,omitempty"`
+	/// the sequence of message
+	Seq int64 `json:"seq,omitempty"`
+	/// uid of readers
+	Readers []string `json:"readers,omitempty"`
+}
+
+// MessageRange queries messages of a conversation by sequence range, used to fill the gap of sequence.
+type MessageRange struct {
+	/// conversation id
+	Conversation string `json:"conversation,omitempty"`
+	/// the first sequence, inclusive
+	Start int64 `json:"start,omitempty"`
+	/// the last sequence, inclusive, it's optional when Thread is set
+	End int64 `json:"end,omitempty"`
+	/// server message id of the root message, set to query the root and replies of the thread only
+	Thread int64 `json:"thread,omitempty"`
+}
+
+// ConversationsRequest queries recent conversations of the user.
+type ConversationsRequest struct {
+	/// max count of conversations, default 50
+	Limit int `json:"limit,omitempty"`
+}
+
+// ConversationInfo the recent conversation of the user, the response of ActionApiGetConversations.
+type ConversationInfo struct {
+	/// conversation id
+	Conversation string `json:"conversation,omitempty"`
+	/// the latest message of the conversation
+	Last *ChatMessage `json:"last,omitempty"`
+	/// the count of messages after the read cursor
+	Unread int64 `json:"unread,omitempty"`
+	/// true if the push notification of the conversation is muted
+	Muted bool `json:"muted,omitempty"`
+}
+
+// EditMessage edit the content of a message sent by self, and the notification of the message edited.
+type EditMessage struct {
+	/// server message id of the message to edit.
+	Mid int64 `json:"mid,omitempty"`
+	/// sender of the edited message
+	From string `json:"from,omitempty"`
+	/// receiver or channel id of the edited message
+	To string `json:"to,omitempty"`
+	/// the new content
+	Content string `json:"content,omitempty"`
+	/// edit time, unix seconds
+	EditAt int64 `json:"editAt,omitempty"`
+}
+
+// Reaction react or unreact a message with an emoji, and the notification of reactions of the message changed.
+type Reaction struct {
+	/// server message id of the message reacted.
+	Mid int64 `json:"mid,omitempty"`
+	/// the user reacted
+	From string `json:"from,omitempty"`
+	/// receiver or channel id of the reacted message
+	To string `json:"to,omitempty"`
+	/// the emoji
+	Emoji string `json:"emoji,omitempty"`
+	/// the count of users reacted with each emoji, set by server in the notification
+	Counts map[string]int64 `json:"counts,omitempty"`
+	/// react time, unix seconds
+	ReactAt int64 `json:"reactAt,omitempty"`
+}
+
+// Status of JoinRequest.
+const (
+	JoinStatusPending  = "pending"
+	JoinStatusApproved = "approved"
+	JoinStatusRejected = "rejected"
+	JoinStatusExpired  = "expired"
+)
+
+// JoinRequest the request of the user to join the channel, and the notification of the request and the decision.
+type JoinRequest struct {
+	/// the channel id
+	Channel string `json:"channel,omitempty"`
+	/// the user requests to join, set by server for the requester
+	Uid string `json:"uid,omitempty"`
+	/// the reason of the request
+	Reason string `json:"reason,omitempty"`
+	/// status of the request, see JoinStatusPending and others
+	Status string `json:"status,omitempty"`
+	/// the admin approved or rejected the request
+	By string `json:"by,omitempty"`
+	/// request time, unix seconds
+	RequestAt int64 `json:"requestAt,omitempty"`
 }
 
 // ClientCustom client custom message, server does not store to database.
@@ -65,7 +227,239 @@ type AckNotify struct {
 	From   string `json:"from,omitempty"`
 }
 
+// Codes of KickOutNotify.
+const (
+	// KickOutCodeSlowClient the client is disconnected because it's too slow to receive messages.
+	KickOutCodeSlowClient = 1
+	// KickOutCodeAnomaly the client is disconnected because the connection is scored as abusive.
+	KickOutCodeAnomaly = 2
+)
+
 type KickOutNotify struct {
 	DeviceId   string `json:"device_id,omitempty"`
 	DeviceName string `json:"device_name,omitempty"`
+	Code       int    `json:"code,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	// Device the device logged in kicks the client out, present if the gateway enables login notify.
+	Device *DeviceInfo `json:"device,omitempty"`
+}
+
+// DeviceInfo the device of a login session, used to detect the account compromise.
+type DeviceInfo struct {
+	DeviceId   string `json:"device_id,omitempty"`
+	DeviceName string `json:"device_name,omitempty"`
+	// DeviceType the type of client in credentials.
+	DeviceType int    `json:"device_type,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	// Location the approximate location of IP, such as city, empty if unknown.
+	Location string `json:"location,omitempty"`
+	// LoginAt the unix milliseconds the device logged in.
+	LoginAt int64 `json:"login_at,omitempty"`
+}
+
+// LoginNotify notifies devices of the user when a device logs in, the new device receives it of each device kicked
+// or coexisting, and the coexisting devices receive it of the new device, see ActionNotifyLogin.
+type LoginNotify struct {
+	// Kicked true if the Device is kicked out by the new login.
+	Kicked bool        `json:"kicked,omitempty"`
+	Device *DeviceInfo `json:"device"`
+}
+
+// AuthResult the data of notify.success of authenticate and resume.
+type AuthResult struct {
+	// ResumeToken the token to resume the session after disconnected, present if the gateway enables resume.
+	ResumeToken string `json:"resume_token,omitempty"`
+	// ResumeWindow the seconds the session is kept after disconnected.
+	ResumeWindow int `json:"resume_window,omitempty"`
+	// Replayed the count of messages buffered when disconnected and replayed after resumed.
+	Replayed int `json:"replayed,omitempty"`
+}
+
+// RemoteConfig the config pushed to clients to tune behaviors without app releases, see ActionNotifyConfig. The zero
+// value of a field means unset, the client keeps its default.
+type RemoteConfig struct {
+	// Version identifies the config, the client skips the config of the version applied already.
+	Version string `json:"version,omitempty"`
+	// HeartbeatInterval the seconds between two heartbeats of client.
+	HeartbeatInterval int `json:"heartbeat_interval,omitempty"`
+	// MinBackoff and MaxBackoff the range of milliseconds of the exponential backoff of reconnect.
+	MinBackoff int64 `json:"min_backoff,omitempty"`
+	MaxBackoff int64 `json:"max_backoff,omitempty"`
+	// MediaUploadURL the endpoint of media upload, see ActionApiUploadToken.
+	MediaUploadURL string `json:"media_upload_url,omitempty"`
+	// Features the toggles of client features by name.
+	Features map[string]bool `json:"features,omitempty"`
+	// Extra the custom config of the app.
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// Phases of the bootstrap sync in the order pushed, see SyncPhase.
+const (
+	SyncPhaseSession       = "session"
+	SyncPhaseConversations = "conversations"
+	SyncPhaseUnread        = "unread"
+	SyncPhaseDone          = "done"
+)
+
+// SyncRequest the data of ActionApiSync, the client sends the state of the last sync done to receive changes after it
+// only, zero values for a full sync.
+type SyncRequest struct {
+	// Version the sync state version of the last sync done, conversations with messages after it are synced.
+	Version int64 `json:"version,omitempty"`
+	// ConfigVersion the version of RemoteConfig applied, the config is not sent if unchanged.
+	ConfigVersion string `json:"config_version,omitempty"`
+	// UnreadDigest the digest of unread counts of the last sync done, unread counts are not sent if unchanged.
+	UnreadDigest string `json:"unread_digest,omitempty"`
+	// Limit the max count of recent conversations synced, default 50.
+	Limit int `json:"limit,omitempty"`
+}
+
+// SyncPhase a phase of the bootstrap sync, phases are pushed by ActionNotifySync in the order of session config,
+// conversation deltas and unread counts, phases without changes are skipped, and the done phase is the response of
+// ActionApiSync. The history of conversations is backfilled on demand by ActionApiMessageRange.
+type SyncPhase struct {
+	Phase string `json:"phase"`
+	// Config and PushSettings of the session phase, Config is absent if the config is not changed.
+	Config       *RemoteConfig `json:"config,omitempty"`
+	PushSettings *PushSettings `json:"push_settings,omitempty"`
+	// Conversations the conversations with messages after the version of request, the sequence of Last is where
+	// the history is backfilled to.
+	Conversations []*ConversationInfo `json:"conversations,omitempty"`
+	// Unread the unread counts of conversations, conversations absent have no unread messages.
+	Unread map[string]int64 `json:"unread,omitempty"`
+	// Version and UnreadDigest the state of the sync done, the client saves them for the next sync.
+	Version      int64  `json:"version,omitempty"`
+	UnreadDigest string `json:"unread_digest,omitempty"`
+}
+
+// Events of ServiceNotify.
+const (
+	ServiceEventAssigned    = "assigned"
+	ServiceEventTransferred = "transferred"
+	ServiceEventClosed      = "closed"
+)
+
+// ServiceSession the session of the visitor with the service account, the data of api.service.transfer and
+// api.service.close. Agent is the agent transferred to, empty to pick by the service.
+type ServiceSession struct {
+	Service string `json:"service"`
+	Visitor string `json:"visitor"`
+	Agent   string `json:"agent,omitempty"`
+}
+
+// ServiceNotify the data of notify.service, sent to the visitor and agents when the session is assigned, transferred
+// or closed.
+type ServiceNotify struct {
+	Event   string `json:"event"`
+	Service string `json:"service"`
+	Visitor string `json:"visitor"`
+	Agent   string `json:"agent"`
+	// From the previous agent of the session transferred.
+	From string `json:"from,omitempty"`
+}
+
+// GuestUpgrade the data of internal.upgrade, the guest of temp uid signed in as the user.
+type GuestUpgrade struct {
+	GuestID string `json:"guest_id"`
+	Uid     string `json:"uid"`
+}
+
+// Resume resumes the session of the token in AuthResult, the client is authenticated without credential, and
+// messages to it when disconnected are replayed.
+type Resume struct {
+	Token string `json:"token"`
+}
+
+// Codes of ServerBusy.
+const (
+	// ServerBusyConnections the gateway reaches the max connections.
+	ServerBusyConnections = 1
+	// ServerBusyBandwidth the gateway reaches the max outbound bandwidth.
+	ServerBusyBandwidth = 2
+	// ServerBusyUserConnections the user reaches the max connections.
+	ServerBusyUserConnections = 3
+)
+
+// ServerBusy notifies the client the connection is rejected and closed, the client should connect to RetryAt if
+// present, or retry after RetryAfter seconds.
+type ServerBusy struct {
+	Code       int    `json:"code"`
+	Reason     string `json:"reason,omitempty"`
+	RetryAt    string `json:"retry_at,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+}
+
+// MessageRejected notifies the sender that the message is rejected by the server, such as moderation.
+type MessageRejected struct {
+	CliMid string `json:"cliMid,omitempty"`
+	To     string `json:"to,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// QuotaExceeded notifies the sender that the message is rejected as the quota is exceeded, the sender may retry after
+// ResetAt, the unix seconds the quota resets, zero if the quota never resets, such as the message length.
+type QuotaExceeded struct {
+	CliMid  string `json:"cliMid,omitempty"`
+	To      string `json:"to,omitempty"`
+	Quota   string `json:"quota"`
+	Limit   int64  `json:"limit"`
+	ResetAt int64  `json:"reset_at,omitempty"`
+}
+
+// MessageExpired notifies the sender that the message is dropped as the receiver is not online before the TTL
+// expires.
+type MessageExpired struct {
+	CliMid string `json:"cliMid,omitempty"`
+	Mid    int64  `json:"mid,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// Scheduled the message scheduled to deliver at DeliverAt, it's the reply of the message with
+// GlideMessage.DeliverAt, and the data of ActionApiScheduleCancel to cancel it by ID.
+type Scheduled struct {
+	ID        int64 `json:"id"`
+	DeliverAt int64 `json:"deliver_at,omitempty"`
+}
+
+// SystemNotify system message broadcast by the server operator to online clients.
+type SystemNotify struct {
+	Content string `json:"content,omitempty"`
+	SendAt  int64  `json:"send_at,omitempty"`
+}
+
+// PushDevice the device registered to receive push notification when user is offline.
+type PushDevice struct {
+	// Platform apns or fcm.
+	Platform string `json:"platform,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// PushSettings the mute and do-not-disturb settings of user, messages of muted conversations or in the do-not-disturb
+// period are still delivered to online devices, but not pushed to offline devices nor counted to the badge.
+type PushSettings struct {
+	MuteAll bool `json:"mute_all,omitempty"`
+	// Muted the muted conversation ids.
+	Muted []string `json:"muted,omitempty"`
+	// DNDStart and DNDEnd the do-not-disturb period in minutes of day, disabled if they are equal.
+	DNDStart int `json:"dnd_start,omitempty"`
+	DNDEnd   int `json:"dnd_end,omitempty"`
+	// TimezoneOffset the seconds east of UTC of user's timezone.
+	TimezoneOffset int `json:"timezone_offset,omitempty"`
+}
+
+const (
+	StateTyping    = "typing"
+	StateRecording = "recording"
+	StateLocation  = "location"
+)
+
+// StateMessage ephemeral state message, like typing, recording, location-sharing heartbeat.
+// Server does not store and ack it, and only delivers to online receivers.
+type StateMessage struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	/// state type, StateTyping, StateRecording, etc.
+	State string `json:"state,omitempty"`
+	/// state content, optional, like the location.
+	Content interface{} `json:"content,omitempty"`
 }
@@ -0,0 +1,37 @@
+package messages
+
+// Priority the priority of message in the client send queue, messages of higher priority jump ahead of the queued.
+type Priority int8
+
+const (
+	// PriorityAuto the priority is decided by the action of message, see ActionPriority.
+	PriorityAuto Priority = iota
+	// PriorityHigh control messages, such as ack, notify, authenticate results and call signaling.
+	PriorityHigh
+	// PriorityNormal chat messages and api responses.
+	PriorityNormal
+	// PriorityLow bulk messages, such as the history backfill.
+	PriorityLow
+)
+
+// ActionPriority returns the default priority of the action, ack, notify, call signaling and the handshake actions
+// are PriorityHigh, others are PriorityNormal.
+func ActionPriority(a Action) Priority {
+	switch a {
+	case ActionHello, ActionHeartbeat, ActionChallenge, ActionResume:
+		return PriorityHigh
+	}
+	switch a.Group() {
+	case GroupAck, GroupNotify, GroupCall:
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+// GetPriority returns the Priority of message, the priority of action if it's PriorityAuto.
+func (g *GlideMessage) GetPriority() Priority {
+	if g.Priority != PriorityAuto {
+		return g.Priority
+	}
+	return ActionPriority(g.GetAction())
+}
@@ -0,0 +1,26 @@
+package messages
+
+// QoS the delivery guarantee of the chat message, expensive guarantees are not paid for ephemeral messages such as
+// the live comment or the location sharing.
+type QoS int8
+
+const (
+	// QoSAtLeastOnce the message is stored and acked, it's queued offline and pushed for the receiver not online, the
+	// sender resends the message not acked with the same client message id. It's the default.
+	QoSAtLeastOnce QoS = iota
+	// QoSAtMostOnce fire and forget, the message is neither stored nor acked, it's delivered to online receivers only
+	// and dropped under load.
+	QoSAtMostOnce
+	// QoSOnlineOnly the message is stored and acked, but delivered to online receivers only, it's neither queued
+	// offline nor pushed.
+	QoSOnlineOnly
+)
+
+// GetQoS returns the QoS of message, unknown QoS is QoSAtLeastOnce.
+func (g *GlideMessage) GetQoS() QoS {
+	switch g.QoS {
+	case QoSAtMostOnce, QoSOnlineOnly:
+		return g.QoS
+	}
+	return QoSAtLeastOnce
+}
@@ -0,0 +1,110 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultRequestTimeout = time.Second * 10
+
+// ErrRequestTimeout the response of request is not received in timeout.
+var ErrRequestTimeout = errors.New("request timeout")
+
+// NewReply creates the response of the request, the seq of request is set to ReplyTo and Seq of the response.
+func NewReply(req *GlideMessage, action Action, data interface{}) *GlideMessage {
+	m := NewMessage(req.GetSeq(), action, data)
+	m.ReplyTo = req.GetSeq()
+	return m
+}
+
+// Requester sends requests and matches responses by ReplyTo, it's the client side helper of request and response
+// over GlideMessage. The responder, the server or another client, responds the request by NewReply.
+type Requester struct {
+	send    func(m *GlideMessage) error
+	timeout time.Duration
+	seq     int64
+
+	mu      sync.Mutex
+	pending map[int64]chan *GlideMessage
+}
+
+// NewRequester creates the Requester sends messages by send, the request fails if the response is not received in
+// timeout, default 10 seconds.
+func NewRequester(send func(m *GlideMessage) error, timeout time.Duration) *Requester {
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+	return &Requester{
+		send:    send,
+		timeout: timeout,
+		pending: map[int64]chan *GlideMessage{},
+	}
+}
+
+// NextSeq returns the next seq, messages sent without Requester should use it to avoid seq conflict with requests.
+func (r *Requester) NextSeq() int64 {
+	return atomic.AddInt64(&r.seq, 1)
+}
+
+// Request sends the request and waits for the response.
+func (r *Requester) Request(m *GlideMessage) (*GlideMessage, error) {
+	return r.RequestContext(context.Background(), m)
+}
+
+// RequestContext sends the request and waits for the response until timeout or the ctx done, the seq of m is set to
+// a new one.
+func (r *Requester) RequestContext(ctx context.Context, m *GlideMessage) (*GlideMessage, error) {
+	seq := r.NextSeq()
+	m.Seq = seq
+	ch := make(chan *GlideMessage, 1)
+
+	r.mu.Lock()
+	r.pending[seq] = ch
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, seq)
+		r.mu.Unlock()
+	}()
+
+	if err := r.send(m); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-timer.C:
+		return nil, ErrRequestTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Dispatch delivers the message to the pending request it responds to, returns false if it's not the response of any
+// pending request, the message should be handled as usual.
+func (r *Requester) Dispatch(m *GlideMessage) bool {
+	if m.ReplyTo == 0 {
+		return false
+	}
+	r.mu.Lock()
+	ch, ok := r.pending[m.ReplyTo]
+	delete(r.pending, m.ReplyTo)
+	r.mu.Unlock()
+	if ok {
+		ch <- m
+	}
+	return ok
+}
+
+// Pending returns the count of requests waiting for response.
+func (r *Requester) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
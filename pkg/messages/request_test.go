@@ -0,0 +1,34 @@
+package messages
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRequester_Request(t *testing.T) {
+	var r *Requester
+	r = NewRequester(func(m *GlideMessage) error {
+		go func() {
+			// unrelated message is not dispatched
+			assert.False(t, r.Dispatch(NewMessage(0, ActionNotifySystem, nil)))
+			assert.True(t, r.Dispatch(NewReply(m, ActionApiSuccess, "pong")))
+		}()
+		return nil
+	}, time.Second)
+
+	resp, err := r.Request(NewMessage(0, "ping", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "pong", resp.Data.GetData())
+	assert.Equal(t, resp.Seq, resp.ReplyTo)
+	assert.Equal(t, 0, r.Pending())
+}
+
+func TestRequester_Timeout(t *testing.T) {
+	r := NewRequester(func(m *GlideMessage) error { return nil }, time.Millisecond*10)
+	_, err := r.Request(NewMessage(0, "ping", nil))
+	assert.Equal(t, ErrRequestTimeout, err)
+
+	// late response
+	assert.False(t, r.Dispatch(&GlideMessage{ReplyTo: 1}))
+}
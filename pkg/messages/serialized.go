@@ -0,0 +1,72 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/glide-im/glide/pkg/pool"
+	"reflect"
+	"sync"
+)
+
+// Serialized caches the encoded bytes of a message for each codec, the message sent to many clients is encoded once
+// per codec, and the same byte slice is written to all connections.
+type Serialized struct {
+	// m the message serialized, shallow copies of it are not cached.
+	m *GlideMessage
+
+	mu      sync.Mutex
+	encoded map[Codec][]byte
+}
+
+// Serialize marks the message to be encoded once per codec and returns the message itself, it's used in broadcast
+// and fan-out paths. The message must not be modified after serialized.
+func Serialize(m *GlideMessage) *GlideMessage {
+	if m != nil && m.serialized == nil {
+		m.serialized = &Serialized{m: m, encoded: map[Codec][]byte{}}
+	}
+	return m
+}
+
+// Encode encodes the message with the codec, the cached bytes are returned if the message is serialized, the returned
+// bytes must not be modified.
+func Encode(c Codec, m *GlideMessage) ([]byte, error) {
+	s := m.serialized
+	if s == nil || s.m != m || !reflect.TypeOf(c).Comparable() {
+		return c.Encode(m)
+	}
+	return s.encode(c)
+}
+
+func (s *Serialized) encode(c Codec) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.encoded[c]; ok {
+		return b, nil
+	}
+	var b []byte
+	var err error
+	if c == JsonCodec {
+		b, err = encodeJson(s.m)
+	} else {
+		b, err = c.Encode(s.m)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.encoded[c] = b
+	return b, nil
+}
+
+// encodeJson encodes with a pooled buffer, only the result is allocated.
+func encodeJson(m *GlideMessage) ([]byte, error) {
+	buf := pool.GetBuffer()
+	defer pool.PutBuffer(buf)
+	err := json.NewEncoder(buf).Encode(m)
+	if err != nil {
+		return nil, err
+	}
+	// trim the newline appended by encoder.
+	b := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	return append(make([]byte, 0, len(b)), b...), nil
+}
@@ -0,0 +1,30 @@
+package messages
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEncode_Serialized(t *testing.T) {
+	m := Serialize(NewMessage(1, ActionChatMessage, &ChatMessage{Mid: 1, Content: "<hello>"}))
+
+	expected, _ := json.Marshal(m)
+	b1, err := Encode(JsonCodec, m)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, b1)
+	b2, _ := Encode(JsonCodec, m)
+	assert.Same(t, &b1[0], &b2[0])
+
+	bin1, err := Encode(BinaryCodec, m)
+	assert.NoError(t, err)
+	bin2, _ := Encode(BinaryCodec, m)
+	assert.Same(t, &bin1[0], &bin2[0])
+
+	// shallow copy is not cached.
+	c := *m
+	c.Seq = 2
+	b3, _ := Encode(JsonCodec, &c)
+	expected, _ = json.Marshal(&c)
+	assert.Equal(t, expected, b3)
+}
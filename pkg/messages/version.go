@@ -0,0 +1,151 @@
+package messages
+
+import (
+	"errors"
+	"sort"
+)
+
+// Versions of the protocol, the client declares the version it speaks in Hello, and the messages are translated
+// between the client version and ProtocolCurrent by shims, so the message schema evolves without breaking the deployed
+// clients.
+const (
+	// ProtocolV1 the protocol of clients which do not declare the version.
+	ProtocolV1 int64 = 1
+	// ProtocolV2 adds binary payload of Data and chunked messages.
+	ProtocolV2 int64 = 2
+	// ProtocolV3 adds the structured Error payload of error messages.
+	ProtocolV3 int64 = 3
+	// ProtocolV4 adds batches of messages.
+	ProtocolV4 int64 = 4
+
+	// ProtocolCurrent the version spoken by the server.
+	ProtocolCurrent = ProtocolV4
+	// ProtocolMin the oldest version supported, clients older than it are rejected.
+	ProtocolMin = ProtocolV1
+)
+
+// Features of the protocol, they are enabled by the negotiated version.
+const (
+	// FeatureBinaryData the Binary payload of Data, see NewBinaryData.
+	FeatureBinaryData = "binary_data"
+	// FeatureChunk the large message sent in chunks, see ActionChunk.
+	FeatureChunk = "chunk"
+	// FeatureStructuredError the Error payload of error messages, see IsErrorAction.
+	FeatureStructuredError = "structured_error"
+	// FeatureBatch multiple messages sent in a frame, see ActionBatch.
+	FeatureBatch = "batch"
+)
+
+const errUnsupportedProtocol = "unsupported protocol version"
+
+var ErrUnsupportedProtocol = errors.New(errUnsupportedProtocol)
+
+// featureSince the version which the feature is introduced in.
+var featureSince = map[string]int64{
+	FeatureBinaryData: ProtocolV2,
+	FeatureChunk:      ProtocolV2,
+
+	FeatureStructuredError: ProtocolV3,
+
+	FeatureBatch: ProtocolV4,
+}
+
+// Shim translates messages between the version it's registered with and the next version.
+type Shim struct {
+	// Upgrade translates the message received from the client of the version to the next version.
+	Upgrade func(m *GlideMessage) *GlideMessage
+	// Downgrade translates the message of the next version to the version, before sent to the client.
+	Downgrade func(m *GlideMessage) *GlideMessage
+}
+
+var shims = map[int64]Shim{
+	ProtocolV1: {Downgrade: StripBinary},
+	ProtocolV2: {Downgrade: downgradeV2},
+}
+
+// RegisterShim sets the shim between the version and the next version, it's not safe for concurrent use and should be
+// called at init. The shim must not modify the message passed in as it may be shared by many clients, it returns a
+// copy of the message modified, see CopyMessage.
+func RegisterShim(version int64, s Shim) {
+	shims[version] = s
+}
+
+// NegotiateProtocol returns the version used with the client declared the version, 0 is treated as ProtocolV1, the
+// version newer than ProtocolCurrent is lowered to ProtocolCurrent.
+func NegotiateProtocol(version int64) (int64, error) {
+	if version == 0 {
+		version = ProtocolV1
+	}
+	if version < ProtocolMin {
+		return 0, ErrUnsupportedProtocol
+	}
+	if version > ProtocolCurrent {
+		version = ProtocolCurrent
+	}
+	return version, nil
+}
+
+// Features returns the sorted features enabled by the version, features disabled are excluded, see
+// SetDisabledFeatures.
+func Features(version int64) []string {
+	var f []string
+	for feature, since := range featureSince {
+		if version >= since && !IsDisabled(feature) {
+			f = append(f, feature)
+		}
+	}
+	sort.Strings(f)
+	return f
+}
+
+// HasFeature returns true if the feature is enabled by the version and not disabled.
+func HasFeature(version int64, feature string) bool {
+	since, ok := featureSince[feature]
+	return ok && version >= since && !IsDisabled(feature)
+}
+
+// Upgrade translates the message received from the client of the version to ProtocolCurrent.
+func Upgrade(m *GlideMessage, from int64) *GlideMessage {
+	for v := from; v < ProtocolCurrent; v++ {
+		if s := shims[v]; s.Upgrade != nil {
+			m = s.Upgrade(m)
+		}
+	}
+	return m
+}
+
+// Downgrade translates the message of ProtocolCurrent to the version, the message is returned as is if there is
+// nothing to translate.
+func Downgrade(m *GlideMessage, to int64) *GlideMessage {
+	for v := ProtocolCurrent - 1; v >= to; v-- {
+		if s := shims[v]; s.Downgrade != nil {
+			m = s.Downgrade(m)
+		}
+	}
+	return m
+}
+
+// CopyMessage returns a shallow copy of the message for shims to modify, the copy is not serialized nor pooled.
+func CopyMessage(m *GlideMessage) *GlideMessage {
+	c := *m
+	c.serialized = nil
+	c.pooled = false
+	return &c
+}
+
+// downgradeV2 replaces the Error payload of error messages with the text, as it's unknown to ProtocolV2 clients.
+func downgradeV2(m *GlideMessage) *GlideMessage {
+	if !IsErrorAction(m.GetAction()) || m.Data == nil {
+		return m
+	}
+	if _, ok := m.Data.GetData().(string); ok {
+		return m
+	}
+	e, ok := ErrorOf(m)
+	if !ok {
+		return m
+	}
+	c := CopyMessage(m)
+	c.Data = NewData(e.Message)
+	return c
+}
@@ -0,0 +1,57 @@
+package messages
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	v, err := NegotiateProtocol(0)
+	assert.NoError(t, err)
+	assert.Equal(t, ProtocolV1, v)
+
+	v, err = NegotiateProtocol(ProtocolCurrent + 1)
+	assert.NoError(t, err)
+	assert.Equal(t, ProtocolCurrent, v)
+
+	_, err = NegotiateProtocol(-1)
+	assert.Equal(t, ErrUnsupportedProtocol, err)
+
+	assert.Empty(t, Features(ProtocolV1))
+	assert.Equal(t, []string{FeatureBinaryData, FeatureChunk}, Features(ProtocolV2))
+	assert.False(t, HasFeature(ProtocolV1, FeatureChunk))
+	assert.True(t, HasFeature(ProtocolV2, FeatureChunk))
+}
+
+func TestDowngrade(t *testing.T) {
+	m := Serialize(NewMessage(1, ActionClientCustom, &Binary{Data: []byte{0, 1, 0xff}}))
+
+	assert.Same(t, m, Downgrade(m, ProtocolCurrent))
+
+	v1 := Downgrade(m, ProtocolV1)
+	assert.NotSame(t, m, v1)
+	b, err := Encode(JsonCodec, v1)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"data":"AAH/"`)
+
+	// the shared message is not modified.
+	_, ok := m.Data.Binary()
+	assert.True(t, ok)
+
+	chat := NewMessage(1, ActionChatMessage, &ChatMessage{Content: "hi"})
+	assert.Same(t, chat, Downgrade(chat, ProtocolV1))
+	assert.Same(t, chat, Downgrade(chat, ProtocolV2))
+	assert.Same(t, chat, Upgrade(chat, ProtocolV1))
+}
+
+func TestDowngrade_Error(t *testing.T) {
+	m := NewErrorMessage(1, ActionNotifyError, errUnsupportedProtocol)
+	assert.Same(t, m, Downgrade(m, ProtocolV3))
+
+	v2 := Downgrade(m, ProtocolV2)
+	b, err := Encode(JsonCodec, v2)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"data":"unsupported protocol version"`)
+	_, ok := m.Data.GetData().(*Error)
+	assert.True(t, ok)
+}
@@ -0,0 +1,124 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"sort"
+	"sync"
+)
+
+const (
+	errActionRegistered = "action already registered"
+	errActionInternal   = "internal action can not be registered"
+	errInvalidPayload   = "invalid payload"
+)
+
+// ActionContext the context of the message handled by the handler registered by RegisterHandler.
+type ActionContext struct {
+	// Info the client sent the message.
+	Info *gate.Info
+	// Message the message received.
+	Message *messages.GlideMessage
+
+	h *MessageInterfaceImpl
+}
+
+// Reply responds the client with ActionApiSuccess, see messages.NewReply.
+func (c *ActionContext) Reply(data interface{}) error {
+	return c.h.GetClientInterface().EnqueueMessage(c.Info.ID, messages.NewReply(c.Message, messages.ActionApiSuccess, data))
+}
+
+// Send sends the message with action to all devices of the user.
+func (c *ActionContext) Send(uid string, action messages.Action, data interface{}) {
+	dispatch2AllDevice(c.h, uid, messages.NewMessage(0, action, data))
+}
+
+type actionFunc func(ctx *ActionContext) error
+
+// ActionRegistry maps application specific actions to handlers, it's the first handler of MessageInterfaceImpl, the
+// registered handler takes precedence over the default handler of the same action.
+type ActionRegistry struct {
+	mu       sync.RWMutex
+	handlers map[messages.Action]actionFunc
+}
+
+func newActionRegistry() *ActionRegistry {
+	return &ActionRegistry{handlers: map[messages.Action]actionFunc{}}
+}
+
+// RegisterHandler registers the handler of action, the payload is decoded from the message data. The client is
+// responded with ActionApiFailed if the payload is invalid or the handler returns error, otherwise the handler should
+// reply by ActionContext.Reply if needed.
+func RegisterHandler[T any](r *ActionRegistry, action messages.Action, fn func(ctx *ActionContext, payload *T) error) error {
+	return r.register(action, func(ctx *ActionContext) error {
+		payload := new(T)
+		if ctx.Message.Data != nil {
+			if err := ctx.Message.Data.Deserialize(payload); err != nil {
+				return errors.New(errInvalidPayload)
+			}
+		}
+		return fn(ctx, payload)
+	})
+}
+
+func (r *ActionRegistry) register(action messages.Action, fn actionFunc) error {
+	if action.IsInternal() {
+		return errors.New(errActionInternal)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.handlers[action]; ok {
+		return errors.New(errActionRegistered)
+	}
+	// the action overriding a known action keeps its group.
+	if !action.IsKnown() {
+		if err := messages.RegisterAction(action, messages.GroupApp); err != nil {
+			return err
+		}
+	}
+	r.handlers[action] = fn
+	return nil
+}
+
+// Unregister removes the handler of action, returns false if not registered.
+func (r *ActionRegistry) Unregister(action messages.Action) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.handlers[action]
+	delete(r.handlers, action)
+	return ok
+}
+
+// Actions returns all registered actions.
+func (r *ActionRegistry) Actions() []messages.Action {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ret []messages.Action
+	for action := range r.handlers {
+		ret = append(ret, action)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i] < ret[j]
+	})
+	return ret
+}
+
+func (r *ActionRegistry) Handle(h *MessageInterfaceImpl, cliInfo *gate.Info, message *messages.GlideMessage) bool {
+	r.mu.RLock()
+	fn, ok := r.handlers[message.GetAction()]
+	r.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	err := fn(&ActionContext{Info: cliInfo, Message: message, h: h})
+	if err != nil {
+		log.D("handle action %s error: %v", message.GetAction(), err)
+		_ = h.GetClientInterface().EnqueueMessage(cliInfo.ID, messages.NewErrorReply(message, err.Error()))
+	}
+	return true
+}
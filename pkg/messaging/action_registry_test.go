@@ -0,0 +1,51 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type callInvite struct {
+	Callee string `json:"callee"`
+}
+
+func TestRegisterHandler(t *testing.T) {
+	g := newMockGateway()
+	impl, err := NewDefaultImpl(&Options{MaxMessageConcurrency: 1})
+	assert.NoError(t, err)
+	impl.SetGate(g)
+
+	err = RegisterHandler(impl.Actions(), "call.invite", func(ctx *ActionContext, payload *callInvite) error {
+		if payload.Callee == "" {
+			return errors.New("callee is empty")
+		}
+		ctx.Send(payload.Callee, "call.incoming", ctx.Info.ID.UID())
+		return ctx.Reply(nil)
+	})
+	assert.NoError(t, err)
+	assert.Error(t, RegisterHandler(impl.Actions(), "call.invite", func(ctx *ActionContext, payload *callInvite) error { return nil }))
+	assert.Error(t, RegisterHandler(impl.Actions(), messages.ActionInternalOnline, func(ctx *ActionContext, payload *struct{}) error { return nil }))
+
+	caller := &gate.Info{ID: gate.NewID2("1")}
+	m := messages.NewMessage(1, "call.invite", nil)
+	m.Data = messages.NewData([]byte(`{"callee":"2"}`))
+	assert.True(t, impl.hc.handle(impl, caller, m))
+	assert.Equal(t, messages.ActionApiSuccess, g.messagesOf(caller.ID)[0].GetAction())
+	assert.Equal(t, "call.incoming", g.messagesOf(gate.NewID2("2"))[0].Action)
+
+	m = messages.NewMessage(2, "call.invite", &callInvite{})
+	assert.True(t, impl.hc.handle(impl, caller, m))
+	assert.Equal(t, messages.ActionApiFailed, g.messagesOf(caller.ID)[1].GetAction())
+
+	assert.NoError(t, RegisterHandler(impl.Actions(), "app.ping", func(ctx *ActionContext, payload *struct{}) error { return nil }))
+	assert.Equal(t, messages.GroupApp, messages.Action("app.ping").Group())
+	assert.Equal(t, messages.GroupCall, messages.Action("call.invite").Group())
+	assert.True(t, impl.Actions().Unregister("app.ping"))
+
+	assert.Equal(t, []messages.Action{"call.invite"}, impl.Actions().Actions())
+	assert.True(t, impl.Actions().Unregister("call.invite"))
+	assert.False(t, impl.hc.handle(impl, caller, m))
+}
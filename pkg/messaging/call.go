@@ -0,0 +1,219 @@
+package messaging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"sync"
+	"time"
+)
+
+const defaultCallRingTimeout = time.Second * 30
+
+const (
+	errCallNotExist     = "call does not exist"
+	errCallInvalidState = "invalid call state"
+	errCallInvalidMedia = "invalid call media"
+	errCallSelf         = "can not call yourself"
+)
+
+const (
+	callStateInviting = iota + 1
+	callStateRinging
+	callStateActive
+)
+
+type call struct {
+	id     string
+	media  string
+	caller string
+	callee string
+	// callerID the client invited, calleeID the client answered.
+	callerID gate.ID
+	calleeID gate.ID
+	state    int
+	timer    *time.Timer
+}
+
+// peer returns the other party of uid, returns false if uid is not a party of the call.
+func (c *call) peer(uid string) (string, bool) {
+	switch uid {
+	case c.caller:
+		return c.callee, true
+	case c.callee:
+		return c.caller, true
+	}
+	return "", false
+}
+
+// callManager tracks calls brokered by this node, a user can be in one call at a time.
+type callManager struct {
+	mu          sync.Mutex
+	calls       map[string]*call
+	users       map[string]string
+	ringTimeout time.Duration
+}
+
+func newCallManager(ringTimeout time.Duration) *callManager {
+	if ringTimeout <= 0 {
+		ringTimeout = defaultCallRingTimeout
+	}
+	return &callManager{
+		calls:       map[string]*call{},
+		users:       map[string]string{},
+		ringTimeout: ringTimeout,
+	}
+}
+
+// remove removes the call, must be called with lock.
+func (m *callManager) remove(c *call) {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	delete(m.calls, c.id)
+	delete(m.users, c.caller)
+	delete(m.users, c.callee)
+}
+
+// handleCallSignal validates the call state and relays the signal to the peer.
+func (d *MessageHandlerImpl) handleCallSignal(c *gate.Info, m *messages.GlideMessage) error {
+	signal := new(messages.CallSignal)
+	if !d.unmarshalData(c, m, signal) {
+		return nil
+	}
+	uid := c.ID.UID()
+	if m.GetAction() == messages.ActionCallInvite {
+		return d.inviteCall(c, m, signal)
+	}
+
+	mgr := d.calls
+	mgr.mu.Lock()
+	cl, ok := mgr.calls[signal.CallID]
+	if !ok {
+		mgr.mu.Unlock()
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errCallNotExist))
+		return nil
+	}
+	peer, isParty := cl.peer(uid)
+	valid := isParty
+	switch m.GetAction() {
+	case messages.ActionCallRinging:
+		valid = uid == cl.callee && cl.state == callStateInviting
+		if valid {
+			cl.state = callStateRinging
+		}
+	case messages.ActionCallAnswer:
+		valid = uid == cl.callee && cl.state != callStateActive
+		if valid {
+			cl.state = callStateActive
+			cl.calleeID = c.ID
+			cl.timer.Stop()
+		}
+	case messages.ActionCallReject:
+		valid = uid == cl.callee && cl.state != callStateActive
+		if valid {
+			mgr.remove(cl)
+		}
+	case messages.ActionCallHangup:
+		if valid {
+			mgr.remove(cl)
+		}
+	}
+	mgr.mu.Unlock()
+
+	if !valid {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errCallInvalidState))
+		return nil
+	}
+	d.sendCallSignal(uid, peer, m.GetAction(), signal)
+	return nil
+}
+
+func (d *MessageHandlerImpl) inviteCall(c *gate.Info, m *messages.GlideMessage, signal *messages.CallSignal) error {
+	caller, callee := c.ID.UID(), m.To
+	if signal.Media != messages.CallMediaAudio && signal.Media != messages.CallMediaVideo {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errCallInvalidMedia))
+		return nil
+	}
+	if caller == callee || callee == "" {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errCallSelf))
+		return nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	signal.CallID = hex.EncodeToString(b)
+
+	mgr := d.calls
+	mgr.mu.Lock()
+	_, callerBusy := mgr.users[caller]
+	_, calleeBusy := mgr.users[callee]
+	if callerBusy || calleeBusy {
+		mgr.mu.Unlock()
+		reject := &messages.CallSignal{CallID: signal.CallID, Reason: messages.CallReasonBusy}
+		d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionCallReject, reject))
+		return nil
+	}
+	cl := &call{
+		id:       signal.CallID,
+		media:    signal.Media,
+		caller:   caller,
+		callee:   callee,
+		callerID: c.ID,
+		state:    callStateInviting,
+	}
+	cl.timer = time.AfterFunc(mgr.ringTimeout, func() {
+		d.endCall(cl.id, messages.CallReasonTimeout)
+	})
+	mgr.calls[cl.id] = cl
+	mgr.users[caller] = cl.id
+	mgr.users[callee] = cl.id
+	mgr.mu.Unlock()
+
+	// the caller gets the call id by the reply.
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, &messages.CallSignal{CallID: cl.id, Media: cl.media}))
+	d.sendCallSignal(caller, callee, messages.ActionCallInvite, signal)
+	return nil
+}
+
+// endCall ends the call by server, both parties receive call.hangup with the reason.
+func (d *MessageHandlerImpl) endCall(id string, reason string) {
+	mgr := d.calls
+	mgr.mu.Lock()
+	cl, ok := mgr.calls[id]
+	if ok {
+		mgr.remove(cl)
+	}
+	mgr.mu.Unlock()
+	if !ok {
+		return
+	}
+	signal := &messages.CallSignal{CallID: id, Reason: reason}
+	d.sendCallSignal(cl.callee, cl.caller, messages.ActionCallHangup, signal)
+	d.sendCallSignal(cl.caller, cl.callee, messages.ActionCallHangup, signal)
+}
+
+// endCallOf ends the call of the client disconnected, the call is ended only if the client is the one in the call.
+func (d *MessageHandlerImpl) endCallOf(id gate.ID) {
+	mgr := d.calls
+	mgr.mu.Lock()
+	callID, ok := mgr.users[id.UID()]
+	var inCall bool
+	if ok {
+		cl := mgr.calls[callID]
+		inCall = cl.callerID == id || cl.calleeID == id
+	}
+	mgr.mu.Unlock()
+	if inCall {
+		d.endCall(callID, messages.CallReasonOffline)
+	}
+}
+
+func (d *MessageHandlerImpl) sendCallSignal(from string, to string, action messages.Action, signal *messages.CallSignal) {
+	m := messages.NewMessage(0, action, signal)
+	m.From = from
+	m.To = to
+	dispatch2AllDevice(d.def, to, m)
+}
@@ -0,0 +1,94 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func sendCallSignal(t *testing.T, h *MessageHandlerImpl, from gate.ID, to string, action messages.Action, signal *messages.CallSignal) {
+	m := &messages.GlideMessage{Seq: 1, Action: string(action), To: to, Data: messages.NewData(signal)}
+	assert.NoError(t, h.handleCallSignal(&gate.Info{ID: from}, m))
+}
+
+func lastCallSignal(t *testing.T, g *mockGateway, id gate.ID) (messages.Action, *messages.CallSignal) {
+	received := g.messagesOf(id)
+	assert.NotEmpty(t, received)
+	m := received[len(received)-1]
+	signal := new(messages.CallSignal)
+	if m.GetAction() != messages.ActionApiFailed {
+		assert.NoError(t, m.Data.Deserialize(signal))
+	}
+	return m.GetAction(), signal
+}
+
+func TestMessageHandlerImpl_CallSignal(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	caller, callee := gate.NewID2("1"), gate.NewID2("2")
+
+	sendCallSignal(t, handler, caller, "2", messages.ActionCallInvite, &messages.CallSignal{Media: "fax"})
+	action, _ := lastCallSignal(t, g, caller)
+	assert.Equal(t, messages.ActionApiFailed, action)
+
+	sendCallSignal(t, handler, caller, "2", messages.ActionCallInvite, &messages.CallSignal{Media: messages.CallMediaVideo, SDP: "offer"})
+	action, reply := lastCallSignal(t, g, caller)
+	assert.Equal(t, messages.ActionApiSuccess, action)
+	action, invite := lastCallSignal(t, g, callee)
+	assert.Equal(t, messages.ActionCallInvite, action)
+	assert.Equal(t, reply.CallID, invite.CallID)
+	assert.Equal(t, "offer", invite.SDP)
+
+	// the callee is busy
+	sendCallSignal(t, handler, gate.NewID2("3"), "2", messages.ActionCallInvite, &messages.CallSignal{Media: messages.CallMediaAudio})
+	action, busy := lastCallSignal(t, g, gate.NewID2("3"))
+	assert.Equal(t, messages.ActionCallReject, action)
+	assert.Equal(t, messages.CallReasonBusy, busy.Reason)
+
+	// only the callee answers
+	sendCallSignal(t, handler, caller, "", messages.ActionCallAnswer, &messages.CallSignal{CallID: invite.CallID})
+	action, _ = lastCallSignal(t, g, caller)
+	assert.Equal(t, messages.ActionApiFailed, action)
+
+	sendCallSignal(t, handler, callee, "", messages.ActionCallRinging, &messages.CallSignal{CallID: invite.CallID})
+	sendCallSignal(t, handler, callee, "", messages.ActionCallAnswer, &messages.CallSignal{CallID: invite.CallID, SDP: "answer"})
+	action, answer := lastCallSignal(t, g, caller)
+	assert.Equal(t, messages.ActionCallAnswer, action)
+	assert.Equal(t, "answer", answer.SDP)
+
+	sendCallSignal(t, handler, caller, "", messages.ActionCallCandidate, &messages.CallSignal{CallID: invite.CallID, Candidate: "c"})
+	action, candidate := lastCallSignal(t, g, callee)
+	assert.Equal(t, messages.ActionCallCandidate, action)
+	assert.Equal(t, "c", candidate.Candidate)
+
+	// the caller disconnected
+	handler.endCallOf(caller)
+	action, hangup := lastCallSignal(t, g, callee)
+	assert.Equal(t, messages.ActionCallHangup, action)
+	assert.Equal(t, messages.CallReasonOffline, hangup.Reason)
+
+	sendCallSignal(t, handler, callee, "", messages.ActionCallHangup, &messages.CallSignal{CallID: invite.CallID})
+	action, _ = lastCallSignal(t, g, callee)
+	assert.Equal(t, messages.ActionApiFailed, action)
+}
+
+func TestMessageHandlerImpl_CallTimeout(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{CallRingTimeout: time.Millisecond * 20})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	sendCallSignal(t, handler, gate.NewID2("1"), "2", messages.ActionCallInvite, &messages.CallSignal{Media: messages.CallMediaAudio})
+	time.Sleep(time.Millisecond * 100)
+
+	for _, id := range []gate.ID{gate.NewID2("1"), gate.NewID2("2")} {
+		action, signal := lastCallSignal(t, g, id)
+		assert.Equal(t, messages.ActionCallHangup, action)
+		assert.Equal(t, messages.CallReasonTimeout, signal.Reason)
+	}
+	assert.Empty(t, handler.calls.users)
+}
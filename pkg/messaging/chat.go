@@ -1,44 +1,210 @@
 package messaging
 
 import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/degrade"
 	"github.com/glide-im/glide/pkg/gate"
-	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/tracing"
+	"github.com/glide-im/glide/pkg/webhook"
 )
 
+const errUnknownMessage = "message resent is not found"
+
 // handleChatMessage 分发用户单聊消息
 func (d *MessageHandlerImpl) handleChatMessage(c *gate.Info, m *messages.GlideMessage) error {
+	if d.schedule(c, m) {
+		return nil
+	}
 	msg := new(messages.ChatMessage)
 	if !d.unmarshalData(c, m, msg) {
 		return nil
 	}
 	msg.From = c.ID.UID()
-	msg.To = m.To
+	// the reply to the guest signed in is sent to the user.
+	msg.To = d.guests.resolve(m.To)
+	// the reply of the agent to the visitor is sent as the service account.
+	msg.From = d.serviceSender(msg.From, msg.To)
+	conv := conversation.NewP2P(msg.From, msg.To)
 
-	if msg.Mid == 0 && m.Action != messages.ActionChatMessageResend {
+	if d.notContact(msg.From, msg.To, m) {
+		rejected := messages.MessageRejected{CliMid: msg.CliMid, To: m.To, Reason: errNotContact}
+		d.enqueueMessage(c.ID, messages.NewMessage(m.GetSeq(), messages.ActionNotifyRejected, &rejected))
+		return nil
+	}
+	if d.noReceiver(msg.To) {
+		d.deadLetter(msg, store.DeadLetterNoReceiver, nil)
+		rejected := messages.MessageRejected{CliMid: msg.CliMid, To: m.To, Reason: errNoReceiver}
+		d.enqueueMessage(c.ID, messages.NewMessage(m.GetSeq(), messages.ActionNotifyRejected, &rejected))
+		return nil
+	}
+	blocked := d.isBlocked(msg.From, msg.To)
+	if blocked && !d.dropBlocked {
+		rejected := messages.MessageRejected{CliMid: msg.CliMid, To: m.To, Reason: errBlocked}
+		d.enqueueMessage(c.ID, messages.NewMessage(m.GetSeq(), messages.ActionNotifyRejected, &rejected))
+		return nil
+	}
+
+	var tags []string
+	qos := m.GetQoS()
+	if qos == messages.QoSAtMostOnce {
+		// fire and forget, the message is neither stored nor acked.
+		var ok bool
+		if tags, ok = d.acceptChatMessage(c, m, conv, msg); !ok {
+			return nil
+		}
+	} else if msg.Mid == 0 && m.GetAction() != messages.ActionChatMessageResend {
+		if err := d.resolveThread(conv, msg.From, msg.To, msg); err != nil {
+			log.D("resolve thread of reply %d failed: %v", msg.Parent, err)
+			d.enqueueMessage(c.ID, messages.NewErrorMessage(m.GetSeq(), messages.ActionNotifyError, err.Error()))
+			return nil
+		}
 		// 当客户端发送一条 mid 为 0 的消息时表示这条消息未被服务端收到过, 或客户端未收到服务端的确认回执
-		err := d.store.StoreMessage(msg)
+		if msg.CliMid != "" {
+			if entry, dup := d.dedup.reserve(msg.From, msg.CliMid); dup {
+				// the message has been received, ack the originally assigned id, the message being stored is dropped.
+				if entry.mid != 0 {
+					msg.Mid = entry.mid
+					msg.Seq = entry.seq
+					return d.ackChatMessage(c, msg)
+				}
+				return nil
+			}
+		}
+		var ok bool
+		if tags, ok = d.acceptChatMessage(c, m, conv, msg); !ok {
+			if msg.CliMid != "" {
+				d.dedup.release(msg.From, msg.CliMid)
+			}
+			return nil
+		}
+		err := d.storeChatMessage(m, conv, msg)
+		if msg.CliMid != "" {
+			if err != nil {
+				d.dedup.release(msg.From, msg.CliMid)
+			} else {
+				d.dedup.commit(msg.From, msg.CliMid, msg.Mid, msg.Seq)
+			}
+		}
 		if err != nil {
-			logger.E("store chat message error %v", err)
 			return err
 		}
+		webhook.Emit(webhook.EventMessageSent, &webhook.MessageEventData{Conversation: string(conv.ID), Message: msg})
+		d.moderateAsync(conv, msg)
+	} else {
+		// the message resent must have been stored, the id chosen by client is never trusted.
+		if !d.knownChatMessage(conv, msg) {
+			rejected := messages.MessageRejected{CliMid: msg.CliMid, To: m.To, Reason: errUnknownMessage}
+			d.enqueueMessage(c.ID, messages.NewMessage(m.GetSeq(), messages.ActionNotifyRejected, &rejected))
+			return nil
+		}
+		var ok bool
+		if tags, ok = d.acceptChatMessage(c, m, conv, msg); !ok {
+			return nil
+		}
 	}
-	// sender resend message to receiver, server has already acked it
-	// does the server should not ack it again ?
-	err := d.ackChatMessage(c, msg)
-	if err != nil {
-		logger.E("ack chat message error %v", err)
+	if qos != messages.QoSAtMostOnce {
+		// sender resend message to receiver, server has already acked it
+		// does the server should not ack it again ?
+		err := d.ackChatMessage(c, msg)
+		if err != nil {
+			log.E("ack chat message error %v", err)
+		}
+	}
+	if blocked {
+		// the message is stored as sent, but never delivered to the receiver blocked the sender.
+		return nil
 	}
 
 	pushMsg := messages.NewMessage(0, messages.ActionChatMessage, msg)
+	pushMsg.QoS = qos
+	tracing.Propagate(m, pushMsg)
+	tagMessage(pushMsg, tags)
+	copyRouteRule(m, pushMsg)
 
-	if !d.dispatchAllDevice(msg.To, pushMsg) {
+	delivered, _ := d.route(msg.From, conv, pushMsg, false)
+	if !delivered && qos == messages.QoSAtLeastOnce {
 		// receiver offline, send offline message, and ack message
 		err := d.ackNotifyMessage(c, msg)
 		if err != nil {
-			logger.E("ack notify message error %v", err)
+			log.E("ack notify message error %v", err)
+		}
+		if d.push != nil {
+			d.push.Notify(msg.To, conv.ID, msg)
 		}
-		return d.dispatchOffline(c, msg)
+		if err = d.dispatchOffline(c, msg); err != nil {
+			return err
+		}
+		if msg.TTL > 0 {
+			d.expiry.track(msg, func() {
+				d.expireOffline(msg)
+			})
+		}
+	}
+	return nil
+}
+
+// acceptChatMessage validates the media or filters the text of the message, then checks quotas and moderates it,
+// it returns tags of filter rules matched, false if the message is rejected and the sender is notified.
+func (d *MessageHandlerImpl) acceptChatMessage(c *gate.Info, m *messages.GlideMessage, conv *conversation.Conversation, msg *messages.ChatMessage) ([]string, bool) {
+	var tags []string
+	var ok bool
+	if messages.IsMediaType(msg.Type) {
+		ok = d.validateMedia(c, m, msg)
+	} else {
+		tags, ok = d.filterChatMessage(c, m, msg)
+	}
+	if !ok || d.exceedQuota(c, m, "", msg) || !d.moderateSync(c, m, conv, msg) {
+		return nil, false
+	}
+	d.chargeQuota("", msg)
+	return tags, true
+}
+
+// knownChatMessage reports whether the message resent by client has been stored, the server assigned id and sequence
+// of the message are set from the dedup cache or the store.
+func (d *MessageHandlerImpl) knownChatMessage(conv *conversation.Conversation, msg *messages.ChatMessage) bool {
+	if msg.CliMid != "" {
+		if entry, ok := d.dedup.lookup(msg.From, msg.CliMid); ok && (msg.Mid == 0 || msg.Mid == entry.mid) {
+			msg.Mid = entry.mid
+			msg.Seq = entry.seq
+			return true
+		}
+	}
+	if msg.Mid == 0 {
+		return false
+	}
+	rs, ok := store.As[store.MessageRecallStore](d.store)
+	if !ok {
+		return false
+	}
+	cm, err := rs.GetMessage(conv.ID, msg.Mid)
+	if err != nil || cm.From != msg.From || cm.To != msg.To {
+		return false
+	}
+	msg.Seq = cm.Seq
+	return true
+}
+
+func (d *MessageHandlerImpl) storeChatMessage(m *messages.GlideMessage, conv *conversation.Conversation, msg *messages.ChatMessage) error {
+	_, span := tracing.Start(m, "store.write")
+	defer span.End()
+
+	seq, err := d.seqAllocator.Next(string(conv.ID))
+	if err != nil {
+		log.E("allocate message sequence error %v", err)
+		// the message is delivered without sequence, the order is kept by the conversation worker.
+		if !d.degradation.Disabled(degrade.FeaturePersistence) {
+			return err
+		}
+	}
+	msg.Seq = seq
+	err = d.store.StoreMessage(msg)
+	if err != nil {
+		tracing.RecordError(span, err)
+		log.E("store chat message error %v", err)
+		return err
 	}
 	return nil
 }
@@ -71,10 +237,11 @@ func (d *MessageHandlerImpl) ackChatMessage(c *gate.Info, msg *messages.ChatMess
 
 // dispatchOffline 接收者不在线, 离线推送
 func (d *MessageHandlerImpl) dispatchOffline(c *gate.Info, message *messages.ChatMessage) error {
-	logger.D("dispatch offline message %v %v", c.ID, message)
+	log.D("dispatch offline message %v %v", c.ID, message)
 	err := d.store.StoreOffline(message)
 	if err != nil {
-		logger.E("store chat message error %v", err)
+		log.E("store chat message error %v", err)
+		d.deadLetter(message, store.DeadLetterRetriesExhausted, err)
 		return err
 	}
 	return nil
@@ -90,15 +257,13 @@ func (d *MessageHandlerImpl) dispatchOnline(c *gate.Info, msg *messages.ChatMess
 
 // TODO optimize 2022-6-20 11:18:24
 func (d *MessageHandlerImpl) dispatchAllDevice(uid string, m *messages.GlideMessage) bool {
-	devices := []string{"", "1", "2", "3"}
-
 	var ok = false
-	for _, device := range devices {
+	for _, device := range knownDevices {
 		id := gate.NewID("", uid, device)
 		err := d.def.GetClientInterface().EnqueueMessage(id, m)
 		if err != nil {
 			if !gate.IsClientNotExist(err) {
-				logger.E("dispatch message error %v", err)
+				log.E("dispatch message error %v", err)
 			}
 		} else {
 			ok = true
@@ -9,7 +9,7 @@ type ClientCustomMessageHandler struct {
 }
 
 func (c *ClientCustomMessageHandler) Handle(h *MessageInterfaceImpl, ci *gate.Info, m *messages.GlideMessage) bool {
-	if m.Action != messages.ActionClientCustom {
+	if m.GetAction() != messages.ActionClientCustom {
 		return false
 	}
 	dispatch2AllDevice(h, m.To, m)
@@ -0,0 +1,76 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/glide-im/glide/pkg/subscription/subscription_impl"
+	"github.com/glide-im/glide/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ConversationRouter delivers message to participants of the conversation, returns false if the message is not
+// delivered to any online participant.
+// notify true express the message is a notification, such as state message, recall notify, which should not be stored.
+type ConversationRouter func(from string, c *conversation.Conversation, m *messages.GlideMessage, notify bool) (bool, error)
+
+// SetConversationRouter sets the router of conversation type, used to add new conversation type or replace the default.
+func (d *MessageHandlerImpl) SetConversationRouter(t conversation.Type, r ConversationRouter) {
+	d.routers[t] = r
+}
+
+func (d *MessageHandlerImpl) route(from string, c *conversation.Conversation, m *messages.GlideMessage, notify bool) (bool, error) {
+	ctx, span := tracing.Start(m, "messaging.route", attribute.String("glide.conversation", string(c.ID)))
+	defer span.End()
+
+	r, ok := d.routers[c.Type]
+	if !ok {
+		return false, errors.New("no router for conversation: " + string(c.ID))
+	}
+	delivered, err := r(from, c, tracing.WithTrace(ctx, m), notify)
+	span.SetAttributes(attribute.Bool("glide.delivered", delivered))
+	tracing.RecordError(span, err)
+	return delivered, err
+}
+
+// routeP2P delivers message to devices of participants except the sender selected by the route rule, participants
+// blocked the sender are skipped, the message to the service account is delivered to the agent of the sender, the
+// message to the participant homed in another region is forwarded to the region if federation is enabled.
+func (d *MessageHandlerImpl) routeP2P(from string, c *conversation.Conversation, m *messages.GlideMessage, notify bool) (bool, error) {
+	delivered := false
+	for _, uid := range c.Participants {
+		if uid == from || d.isBlocked(from, uid) {
+			continue
+		}
+		if pool := d.servicePool(uid); pool != nil {
+			if d.dispatchService(pool, from, m, notify) {
+				delivered = true
+			}
+			continue
+		}
+		if d.forwardRemote(uid, m) {
+			delivered = true
+			continue
+		}
+		if d.dispatchDevices(uid, m) {
+			delivered = true
+		}
+	}
+	return delivered, nil
+}
+
+// routeChannel publishes message to the channel, notification is published as subscription_impl.TypeNotify.
+func (d *MessageHandlerImpl) routeChannel(from string, c *conversation.Conversation, m *messages.GlideMessage, notify bool) (bool, error) {
+	t := subscription_impl.TypeMessage
+	if notify {
+		t = subscription_impl.TypeNotify
+	}
+	pm := subscription_impl.PublishMessage{
+		From:    subscription.SubscriberID(from),
+		Type:    t,
+		Message: m,
+	}
+	err := d.def.GetGroupInterface().PublishMessage(subscription.ChanID(c.ID.Target()), &pm)
+	return err == nil, err
+}
@@ -0,0 +1,90 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/degrade"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/push"
+	"github.com/glide-im/glide/pkg/store"
+)
+
+const (
+	defaultConversationLimit = 50
+	maxConversationLimit     = 200
+
+	errConversationsNotSupported = "conversation list is not supported"
+)
+
+// handleApiGetConversations responds recent conversations of the user with the latest message, unread count and mute
+// flag, so that clients need not sync the conversation list from a separate service.
+func (d *MessageHandlerImpl) handleApiGetConversations(c *gate.Info, m *messages.GlideMessage) error {
+	r := new(messages.ConversationsRequest)
+	if m.Data != nil && m.Data.GetData() != nil && !d.unmarshalData(c, m, r) {
+		return nil
+	}
+	ret, err := d.getConversations(c.ID.UID(), r.Limit)
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, ret))
+	return nil
+}
+
+// getConversations returns recent P2P conversations of uid and channels uid has read cursors in. The unread count is
+// the sequence distance from the read cursor, it's 0 if the latest message is sent by uid.
+func (d *MessageHandlerImpl) getConversations(uid string, limit int) ([]*messages.ConversationInfo, error) {
+	if d.degradation.Disabled(degrade.FeatureHistory) {
+		return nil, errors.New(errHistoryUnavailable)
+	}
+	cs, ok := store.Unwrap(d.store).(store.ConversationStore)
+	if !ok {
+		return nil, errors.New(errConversationsNotSupported)
+	}
+	if limit <= 0 {
+		limit = defaultConversationLimit
+	} else if limit > maxConversationLimit {
+		limit = maxConversationLimit
+	}
+
+	cursors, err := d.readCursors.GetReadCursors(uid)
+	if err != nil {
+		return nil, err
+	}
+	read := map[conversation.ID]int64{}
+	var channels []string
+	for _, cursor := range cursors {
+		id := conversation.ID(cursor.Conversation)
+		read[id] = cursor.Seq
+		if id.Type() == conversation.TypeChannel {
+			channels = append(channels, id.Target())
+		}
+	}
+	summaries, err := cs.GetRecentConversations(uid, channels, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings *push.Settings
+	if d.push != nil {
+		settings, err = d.push.Settings().GetSettings(uid)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret := make([]*messages.ConversationInfo, 0, len(summaries))
+	for _, s := range summaries {
+		id := s.Conversation
+		info := &messages.ConversationInfo{Conversation: string(id), Last: s.Last}
+		if s.Last != nil && s.Last.From != uid && s.Last.Seq > read[id] {
+			info.Unread = s.Last.Seq - read[id]
+		}
+		if settings != nil {
+			info.Muted = settings.MuteAll || settings.Muted[string(id)]
+		}
+		ret = append(ret, info)
+	}
+	return ret, nil
+}
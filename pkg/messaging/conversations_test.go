@@ -0,0 +1,68 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/push"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type conversationStore struct {
+	countingStore
+	channels []string
+}
+
+func (s *conversationStore) GetRecentConversations(uid string, channels []string, limit int) ([]*store.ConversationSummary, error) {
+	s.channels = channels
+	return []*store.ConversationSummary{
+		{Conversation: conversation.NewChannel("100").ID, Last: &messages.ChatMessage{From: "3", To: "100", Seq: 8, SendAt: 3}},
+		{Conversation: conversation.NewP2P("1", "2").ID, Last: &messages.ChatMessage{From: "2", To: "1", Seq: 5, SendAt: 2}},
+		{Conversation: conversation.NewP2P("1", "3").ID, Last: &messages.ChatMessage{From: "1", To: "3", Seq: 9, SendAt: 1}},
+	}, nil
+}
+
+func TestMessageHandlerImpl_handleApiGetConversations(t *testing.T) {
+	settings := push.NewMemSettingsStore()
+	bridge, err := push.NewBridge(&push.Options{Devices: push.NewMemDeviceStore(), Settings: settings})
+	assert.NoError(t, err)
+	defer bridge.Close()
+	s := &conversationStore{}
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s, PushBridge: bridge})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	channel := conversation.NewChannel("100").ID
+	_, _ = handler.readCursors.UpdateReadCursor("1", channel, 6, 1)
+	_, _ = handler.readCursors.UpdateReadCursor("1", conversation.NewP2P("1", "2").ID, 1, 1)
+	assert.NoError(t, settings.SetSettings("1", &push.Settings{Muted: map[string]bool{string(channel): true}}))
+
+	c := &gate.Info{ID: gate.NewID2("1")}
+	assert.NoError(t, handler.handleApiGetConversations(c, messages.NewMessage(1, messages.ActionApiGetConversations, nil)))
+	assert.Equal(t, []string{"100"}, s.channels)
+
+	replies := g.messagesOf(c.ID)
+	assert.Equal(t, messages.ActionApiSuccess, replies[0].GetAction())
+	cs := replies[0].Data.GetData().([]*messages.ConversationInfo)
+	assert.Len(t, cs, 3)
+	assert.Equal(t, int64(2), cs[0].Unread)
+	assert.True(t, cs[0].Muted)
+	assert.Equal(t, int64(4), cs[1].Unread)
+	assert.False(t, cs[1].Muted)
+	// the latest message is sent by self.
+	assert.Equal(t, int64(0), cs[2].Unread)
+}
+
+func TestMessageHandlerImpl_handleApiGetConversations_NotSupported(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	c := &gate.Info{ID: gate.NewID2("1")}
+	assert.NoError(t, handler.handleApiGetConversations(c, messages.NewMessage(1, messages.ActionApiGetConversations, &messages.ConversationsRequest{Limit: 10})))
+	assert.Equal(t, messages.ActionApiFailed, g.messagesOf(c.ID)[0].GetAction())
+}
@@ -0,0 +1,117 @@
+package messaging
+
+import (
+	"errors"
+	"time"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
+	"github.com/glide-im/glide/pkg/snowflake"
+	"github.com/glide-im/glide/pkg/store"
+)
+
+const (
+	errNoReceiver             = "receiver does not exist"
+	errDeadLetterNotSupported = "dead letter store does not support inspecting"
+
+	defaultDeadLetterLimit = 100
+)
+
+// UserDirectory tells whether users exist, P2P messages to users not exist are rejected and dead lettered.
+type UserDirectory interface {
+
+	// UserExists returns true if the user of uid exists.
+	UserExists(uid string) (bool, error)
+}
+
+// UserDirectoryFunc the func implements UserDirectory.
+type UserDirectoryFunc func(uid string) (bool, error)
+
+func (f UserDirectoryFunc) UserExists(uid string) (bool, error) {
+	return f(uid)
+}
+
+// noReceiver returns true if the receiver does not exist, the message is delivered if the directory is unavailable.
+func (d *MessageHandlerImpl) noReceiver(uid string) bool {
+	if d.users == nil || d.servicePool(uid) != nil {
+		return false
+	}
+	ok, err := d.users.UserExists(uid)
+	if err != nil {
+		log.E("query existence of user %s error %v", uid, err)
+		return false
+	}
+	return !ok
+}
+
+// deadLetter writes the undeliverable message to the dead letter sink with the reason, such as
+// store.DeadLetterExpired, cause is the error of the last failure, optional.
+func (d *MessageHandlerImpl) deadLetter(m *messages.ChatMessage, reason string, cause error) {
+	if d.deadLetters == nil {
+		return
+	}
+	metrics.DeadLetters.WithLabelValues(reason).Inc()
+	l := &store.DeadLetter{
+		ID:       snowflake.Generate(),
+		Reason:   reason,
+		FailedAt: time.Now().Unix(),
+		Message:  m,
+	}
+	if cause != nil {
+		l.Error = cause.Error()
+	}
+	if err := d.deadLetters.StoreDeadLetter(l); err != nil {
+		log.E("store dead letter of message %d to %s error %v", m.Mid, m.To, err)
+	}
+}
+
+// DeadLetters returns at most limit dead letters of id greater than after, ordered by id, the dead letter sink must
+// implement store.DeadLetterStore.
+func (d *MessageHandlerImpl) DeadLetters(after int64, limit int) ([]*store.DeadLetter, error) {
+	ds, ok := d.deadLetters.(store.DeadLetterStore)
+	if !ok {
+		return nil, errors.New(errDeadLetterNotSupported)
+	}
+	if limit <= 0 {
+		limit = defaultDeadLetterLimit
+	}
+	return ds.GetDeadLetters(after, limit)
+}
+
+// Redeliver removes the dead letter of id and delivers the message again, the message not stored is stored first, and
+// it's queued offline if the receiver is offline. It returns false if the dead letter does not exist.
+func (d *MessageHandlerImpl) Redeliver(id int64) (bool, error) {
+	ds, ok := d.deadLetters.(store.DeadLetterStore)
+	if !ok {
+		return false, errors.New(errDeadLetterNotSupported)
+	}
+	l, err := ds.RemoveDeadLetter(id)
+	if err != nil || l == nil {
+		return false, err
+	}
+	msg := l.Message
+	conv := conversation.NewP2P(msg.From, msg.To)
+	pushMsg := messages.NewMessage(0, messages.ActionChatMessage, msg)
+	if msg.Mid == 0 {
+		if err = d.storeChatMessage(pushMsg, conv, msg); err != nil {
+			// keep the dead letter to redeliver later.
+			_ = ds.StoreDeadLetter(l)
+			return true, err
+		}
+	}
+	log.I("redeliver dead letter %d, message %d to %s", id, msg.Mid, msg.To)
+
+	delivered, _ := d.route(msg.From, conv, pushMsg, false)
+	if delivered {
+		return true, nil
+	}
+	if err = d.store.StoreOffline(msg); err != nil {
+		d.deadLetter(msg, store.DeadLetterRetriesExhausted, err)
+		return true, err
+	}
+	if d.push != nil {
+		d.push.Notify(msg.To, conv.ID, msg)
+	}
+	return true, nil
+}
@@ -0,0 +1,71 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageHandlerImpl_DeadLetter(t *testing.T) {
+	s := &offlineStore{offline: map[int64]bool{}}
+	g := &offlineGateway{mockGateway: newMockGateway(), offline: map[string]bool{"2": true}}
+	users := map[string]bool{"1": true, "2": true}
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{
+		MessageStore: s,
+		DeadLetters:  store.NewMemDeadLetterStore(0),
+		Users: UserDirectoryFunc(func(uid string) (bool, error) {
+			return users[uid], nil
+		}),
+	})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	sender := &gate.Info{ID: gate.NewID2("1")}
+	send := func(to string, ttl int64) {
+		m := &messages.GlideMessage{
+			Action: string(messages.ActionChatMessage),
+			To:     to,
+			Data:   messages.NewData(&messages.ChatMessage{CliMid: to, Content: "123456", TTL: ttl}),
+		}
+		assert.NoError(t, handler.handleChatMessage(sender, m))
+	}
+	send("3", 0)
+	send("2", 1)
+
+	rejected := g.messagesOf(sender.ID)[0]
+	assert.Equal(t, messages.ActionNotifyRejected, rejected.GetAction())
+	assert.Equal(t, errNoReceiver, rejected.Data.GetData().(*messages.MessageRejected).Reason)
+
+	var letters []*store.DeadLetter
+	assert.Eventually(t, func() bool {
+		letters, err = handler.DeadLetters(0, 0)
+		return err == nil && len(letters) == 2
+	}, time.Second*2, time.Millisecond*50)
+	assert.Equal(t, store.DeadLetterNoReceiver, letters[0].Reason)
+	assert.Equal(t, store.DeadLetterExpired, letters[1].Reason)
+
+	// the receiver signed up, the message is stored and delivered.
+	users["3"] = true
+	ok, err := handler.Redeliver(letters[0].ID)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	delivered := g.messagesOf(gate.NewID2("3"))
+	assert.Len(t, delivered, 1)
+	assert.NotZero(t, delivered[0].Data.GetData().(*messages.ChatMessage).Mid)
+
+	// the receiver is still offline, the message is queued offline.
+	ok, err = handler.Redeliver(letters[1].ID)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.True(t, s.isOffline(letters[1].Message.Mid))
+
+	ok, err = handler.Redeliver(letters[1].ID)
+	assert.False(t, ok)
+	assert.NoError(t, err)
+	letters, _ = handler.DeadLetters(0, 0)
+	assert.Empty(t, letters)
+}
@@ -0,0 +1,105 @@
+package messaging
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const defaultDedupWindow = time.Minute * 5
+
+// dedupEntry is the server assigned id of a message sent by client, mid is 0 when the message is being stored.
+type dedupEntry struct {
+	key string
+	mid int64
+	seq int64
+	at  time.Time
+}
+
+// dedupCache remembers messages keyed on sender and client message id in a sliding window, used to detect the
+// message resent by client after network timeout.
+type dedupCache struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*list.Element
+	// order of entries by time, the front is the oldest.
+	order *list.List
+}
+
+func newDedupCache(window time.Duration) *dedupCache {
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+	return &dedupCache{
+		window:  window,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// reserve returns the entry of the message if exists, otherwise reserves an entry with mid 0 and returns false.
+func (c *dedupCache) reserve(from string, cliMid string) (dedupEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.expire(now)
+
+	key := from + "\x00" + cliMid
+	if e, ok := c.entries[key]; ok {
+		return *e.Value.(*dedupEntry), true
+	}
+	c.entries[key] = c.order.PushBack(&dedupEntry{key: key, at: now})
+	return dedupEntry{}, false
+}
+
+// lookup returns the entry of the message stored if exists, the entry being stored is not returned.
+func (c *dedupCache) lookup(from string, cliMid string) (dedupEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire(time.Now())
+
+	if e, ok := c.entries[from+"\x00"+cliMid]; ok {
+		entry := e.Value.(*dedupEntry)
+		if entry.mid != 0 {
+			return *entry, true
+		}
+	}
+	return dedupEntry{}, false
+}
+
+// commit sets the server assigned id of the reserved entry.
+func (c *dedupCache) commit(from string, cliMid string, mid int64, seq int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[from+"\x00"+cliMid]; ok {
+		entry := e.Value.(*dedupEntry)
+		entry.mid = mid
+		entry.seq = seq
+	}
+}
+
+// release removes the reserved entry, used when the message is failed to store.
+func (c *dedupCache) release(from string, cliMid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := from + "\x00" + cliMid
+	if e, ok := c.entries[key]; ok {
+		c.order.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+func (c *dedupCache) expire(now time.Time) {
+	for e := c.order.Front(); e != nil; e = c.order.Front() {
+		entry := e.Value.(*dedupEntry)
+		if now.Sub(entry.at) < c.window {
+			return
+		}
+		c.order.Remove(e)
+		delete(c.entries, entry.key)
+	}
+}
@@ -0,0 +1,92 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type countingStore struct {
+	stored int64
+}
+
+func (s *countingStore) StoreMessage(message *messages.ChatMessage) error {
+	s.stored++
+	message.Mid = s.stored
+	return nil
+}
+
+func (s *countingStore) StoreOffline(message *messages.ChatMessage) error {
+	return nil
+}
+
+func TestMessageHandlerImpl_handleChatMessage_Dedup(t *testing.T) {
+	s := &countingStore{}
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	sender := &gate.Info{ID: gate.NewID2("1")}
+	send := func() *messages.AckMessage {
+		m := &messages.GlideMessage{
+			Action: string(messages.ActionChatMessage),
+			To:     "2",
+			Data:   messages.NewData(&messages.ChatMessage{CliMid: "uuid-1", Content: "hi"}),
+		}
+		assert.NoError(t, handler.handleChatMessage(sender, m))
+		acks := g.messagesOf(sender.ID)
+		return acks[len(acks)-1].Data.GetData().(*messages.AckMessage)
+	}
+	ack1 := send()
+	ack2 := send()
+	assert.Equal(t, int64(1), s.stored)
+	assert.Equal(t, ack1.Mid, ack2.Mid)
+	assert.Equal(t, ack1.Seq, ack2.Seq)
+
+	// the message resent without server id is known by the client message id.
+	m := &messages.GlideMessage{
+		Action: string(messages.ActionChatMessageResend),
+		To:     "2",
+		Data:   messages.NewData(&messages.ChatMessage{CliMid: "uuid-1", Content: "hi"}),
+	}
+	assert.NoError(t, handler.handleChatMessage(sender, m))
+	acks := g.messagesOf(sender.ID)
+	assert.Equal(t, ack1.Mid, acks[len(acks)-1].Data.GetData().(*messages.AckMessage).Mid)
+	assert.Equal(t, int64(1), s.stored)
+}
+
+func TestMessageHandlerImpl_handleChatMessage_Resend(t *testing.T) {
+	s := &mockRecallStore{
+		messages: map[int64]*messages.ChatMessage{
+			1: {Mid: 1, Seq: 1, From: "1", To: "2"},
+			2: {Mid: 2, Seq: 1, From: "3", To: "2"},
+		},
+	}
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	sender := &gate.Info{ID: gate.NewID2("1")}
+	resend := func(mid int64) messages.Action {
+		m := &messages.GlideMessage{
+			Action: string(messages.ActionChatMessageResend),
+			To:     "2",
+			Data:   messages.NewData(&messages.ChatMessage{CliMid: "uuid-1", Mid: mid, Content: "hi"}),
+		}
+		assert.NoError(t, handler.handleChatMessage(sender, m))
+		replies := g.messagesOf(sender.ID)
+		return replies[len(replies)-1].GetAction()
+	}
+
+	assert.Equal(t, messages.ActionNotifyRejected, resend(0))
+	assert.Equal(t, messages.ActionNotifyRejected, resend(100))
+	// the message stored is sent by another user.
+	assert.Equal(t, messages.ActionNotifyRejected, resend(2))
+	assert.Empty(t, g.messagesOf(gate.NewID2("2")))
+
+	resend(1)
+	assert.Len(t, g.messagesOf(gate.NewID2("2")), 1)
+}
@@ -0,0 +1,203 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policies of RouteRule.
+const (
+	// RouteAllDevices delivers message to all online devices of the receiver.
+	RouteAllDevices = "all"
+	// RouteLastActive delivers message to the device of the receiver sent message most recently only.
+	RouteLastActive = "last_active"
+)
+
+const (
+	// extraKeyRoute the key of message extra specifies the route policy by sender.
+	extraKeyRoute = "route"
+	// extraKeyRouteDevices the key of message extra specifies the device types to deliver, separated by comma.
+	extraKeyRouteDevices = "route.devices"
+)
+
+// knownDevices the device types a user may be connected with.
+var knownDevices = []string{"", "1", "2", "3"}
+
+// RouteRule decides which devices of the receiver a P2P message is delivered to. The sender can specify the rule of
+// a message by the message extra `route` and `route.devices`, which overrides the server default rule.
+type RouteRule struct {
+	// Policy all or last_active, default all.
+	Policy string `json:"policy,omitempty"`
+	// Devices the device types to deliver, empty for all device types.
+	Devices []string `json:"devices,omitempty"`
+}
+
+func (r *RouteRule) validate() error {
+	switch r.Policy {
+	case "", RouteAllDevices, RouteLastActive:
+		return nil
+	}
+	return errors.New("unknown route policy: " + r.Policy)
+}
+
+// routeRuleOf returns the rule specified by the sender of message, or the default rule.
+func routeRuleOf(m *messages.GlideMessage, def *RouteRule) *RouteRule {
+	policy, devices := m.Extra[extraKeyRoute], m.Extra[extraKeyRouteDevices]
+	if policy == "" && devices == "" {
+		return def
+	}
+	r := &RouteRule{Policy: policy}
+	if devices != "" {
+		r.Devices = strings.Split(devices, ",")
+	}
+	if r.validate() != nil {
+		return def
+	}
+	return r
+}
+
+// copyRouteRule copies the route rule specified by sender to the message delivered to receiver.
+func copyRouteRule(from *messages.GlideMessage, to *messages.GlideMessage) {
+	for _, k := range []string{extraKeyRoute, extraKeyRouteDevices} {
+		v, ok := from.Extra[k]
+		if !ok {
+			continue
+		}
+		if to.Extra == nil {
+			to.Extra = map[string]string{}
+		}
+		to.Extra[k] = v
+	}
+}
+
+// deviceTracker tracks the time of last activity of online devices of users on this node.
+type deviceTracker struct {
+	mu sync.RWMutex
+	// uid -> device -> the unix nano of last activity
+	devices map[string]map[string]int64
+}
+
+func newDeviceTracker() *deviceTracker {
+	return &deviceTracker{devices: map[string]map[string]int64{}}
+}
+
+func (t *deviceTracker) online(id gate.ID) {
+	if id.IsTemp() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	uid := id.UID()
+	if _, ok := t.devices[uid]; !ok {
+		t.devices[uid] = map[string]int64{}
+	}
+	t.devices[uid][id.Device()] = time.Now().UnixNano()
+}
+
+func (t *deviceTracker) offline(id gate.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	uid := id.UID()
+	devices, ok := t.devices[uid]
+	if !ok {
+		return
+	}
+	delete(devices, id.Device())
+	if len(devices) == 0 {
+		delete(t.devices, uid)
+	}
+}
+
+func (t *deviceTracker) touch(id gate.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if devices, ok := t.devices[id.UID()]; ok {
+		if _, online := devices[id.Device()]; online {
+			devices[id.Device()] = time.Now().UnixNano()
+		}
+	}
+}
+
+// lastActive returns the device of uid active most recently in devices, all devices if empty, returns false if no
+// device of the user is tracked.
+func (t *deviceTracker) lastActive(uid string, devices []string) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	online, ok := t.devices[uid]
+	if !ok {
+		return "", false
+	}
+	device, last, found := "", int64(-1), false
+	for d, at := range online {
+		if len(devices) > 0 && !containsString(devices, d) {
+			continue
+		}
+		if at > last {
+			device, last, found = d, at, true
+		}
+	}
+	return device, found
+}
+
+// SetRouteRule sets the default rule to deliver P2P messages to devices of receiver.
+func (d *MessageHandlerImpl) SetRouteRule(r *RouteRule) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+	d.routeRule.Store(r)
+	return nil
+}
+
+// dispatchDevices delivers message to devices of uid selected by the route rule, returns true if any device received.
+// The chat message is transformed for each device if the transformer is enabled.
+func (d *MessageHandlerImpl) dispatchDevices(uid string, m *messages.GlideMessage) bool {
+	uid = d.guests.resolve(uid)
+	rule := routeRuleOf(m, d.routeRule.Load().(*RouteRule))
+
+	devices := rule.Devices
+	if len(devices) == 0 {
+		devices = knownDevices
+	}
+	if rule.Policy == RouteLastActive {
+		// the user is connected to other nodes if not tracked, deliver to all devices.
+		if device, ok := d.devices.lastActive(uid, rule.Devices); ok {
+			devices = []string{device}
+		}
+	}
+
+	transform := d.newDeviceTransform(uid, m)
+	var ok = false
+	for _, device := range devices {
+		id := gate.NewID("", uid, device)
+		dm := m
+		if transform != nil {
+			dm = transform.of(device)
+		}
+		err := d.def.GetClientInterface().EnqueueMessage(id, dm)
+		if err != nil {
+			if !gate.IsClientNotExist(err) {
+				log.E("dispatch message error %v", err)
+			}
+		} else {
+			ok = true
+		}
+	}
+	return ok
+}
+
+func containsString(s []string, v string) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMessageHandlerImpl_RouteLastActive(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{
+		MessageStore: &countingStore{},
+		RouteRule:    &RouteRule{Policy: RouteLastActive},
+	})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	handler.devices.online(gate.NewID("", "2", "1"))
+	handler.devices.online(gate.NewID("", "2", "2"))
+	handler.devices.touch(gate.NewID("", "2", "1"))
+
+	sendChat(t, handler, "1", "2", "hello")
+	assert.Len(t, g.messagesOf(gate.NewID("", "2", "1")), 1)
+	assert.Empty(t, g.messagesOf(gate.NewID("", "2", "2")))
+
+	// the rule specified by sender overrides the default rule
+	m := &messages.GlideMessage{
+		Action: string(messages.ActionChatMessage),
+		To:     "2",
+		Data:   messages.NewData(&messages.ChatMessage{CliMid: "2", Content: "hi"}),
+		Extra:  map[string]string{extraKeyRoute: RouteAllDevices, extraKeyRouteDevices: "2,3"},
+	}
+	assert.NoError(t, handler.handleChatMessage(&gate.Info{ID: gate.NewID2("1")}, m))
+	assert.Len(t, g.messagesOf(gate.NewID("", "2", "1")), 1)
+	assert.Len(t, g.messagesOf(gate.NewID("", "2", "2")), 1)
+	assert.Len(t, g.messagesOf(gate.NewID("", "2", "3")), 1)
+
+	handler.devices.offline(gate.NewID("", "2", "1"))
+	device, ok := handler.devices.lastActive("2", nil)
+	assert.True(t, ok)
+	assert.Equal(t, "2", device)
+
+	assert.Error(t, handler.SetRouteRule(&RouteRule{Policy: "unknown"}))
+}
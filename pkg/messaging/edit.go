@@ -0,0 +1,70 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"time"
+)
+
+const defaultEditWindow = time.Hour * 24
+
+const (
+	errEditNotSupported = "message edit is not supported"
+	errEditEmpty        = "message content is empty"
+)
+
+// validateEdit checks the message ownership and edit window, and updates the content in store.
+func (d *MessageHandlerImpl) validateEdit(c *gate.Info, conv conversation.ID, edit *messages.EditMessage, to string) error {
+	es, ok := store.As[store.MessageEditStore](d.store)
+	if !ok {
+		return errors.New(errEditNotSupported)
+	}
+	if edit.Content == "" {
+		return errors.New(errEditEmpty)
+	}
+	cm, err := es.GetMessage(conv, edit.Mid)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = checkOwnMessage(cm, c.ID.UID(), to, now, d.editWindow)
+	if err != nil {
+		return err
+	}
+	err = es.EditMessage(conv, edit.Mid, edit.Content, now.Unix())
+	if err != nil {
+		return err
+	}
+	edit.From = cm.From
+	edit.To = cm.To
+	edit.EditAt = now.Unix()
+	return nil
+}
+
+// handleEditMessage edits a message, notify all participants of the conversation and other devices of the sender.
+func (d *MessageHandlerImpl) handleEditMessage(c *gate.Info, m *messages.GlideMessage) error {
+	edit := new(messages.EditMessage)
+	if !d.unmarshalData(c, m, edit) {
+		return nil
+	}
+	conv, err := conversation.Of(c.ID.UID(), m)
+	if err != nil {
+		return err
+	}
+	err = d.validateEdit(c, conv.ID, edit, m.To)
+	if err != nil {
+		log.D("edit message %d failed: %v", edit.Mid, err)
+		d.enqueueMessage(c.ID, messages.NewErrorMessage(m.GetSeq(), messages.ActionNotifyError, err.Error()))
+		return nil
+	}
+
+	notify := messages.NewMessage(m.GetSeq(), m.GetAction(), edit)
+	_, err = d.route(edit.From, conv, notify, true)
+	if conv.Type == conversation.TypeP2P {
+		d.dispatchAllDevice(edit.From, notify)
+	}
+	return err
+}
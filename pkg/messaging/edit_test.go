@@ -0,0 +1,42 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageHandlerImpl_handleEditMessage(t *testing.T) {
+	s := storetest.NewMessageStore()
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	cm := &messages.ChatMessage{From: "1", To: "2", Content: "hi", SendAt: time.Now().Unix()}
+	assert.NoError(t, s.StoreMessage(cm))
+
+	sender := &gate.Info{ID: gate.NewID2("1")}
+	edit := func() {
+		err := handler.handleEditMessage(sender, &messages.GlideMessage{Action: string(messages.ActionMessageEdit), To: "2", Data: messages.NewData(&messages.EditMessage{Mid: cm.Mid, Content: "hello"})})
+		assert.NoError(t, err)
+	}
+	edit()
+	stored, err := s.GetMessage(conversation.NewP2P("1", "2").ID, cm.Mid)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", stored.Content)
+	assert.Equal(t, messages.ActionMessageEdit, g.messagesOf(gate.NewID2("2"))[0].GetAction())
+
+	// the recalled message can not be edited.
+	assert.NoError(t, s.MarkRecalled(conversation.NewP2P("1", "2").ID, cm.Mid))
+	edit()
+	notify := g.messagesOf(sender.ID)
+	last := notify[len(notify)-1]
+	assert.Equal(t, messages.ActionNotifyError, last.GetAction())
+	assert.Equal(t, store.ErrMessageRecalled.Error(), last.Data.GetData().(*messages.Error).Message)
+}
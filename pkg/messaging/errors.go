@@ -0,0 +1,24 @@
+package messaging
+
+import "github.com/glide-im/glide/pkg/messages"
+
+// init registers codes of errors notified to clients, see messages.ErrorOf.
+func init() {
+	messages.RegisterError(messages.ErrCodeInvalidMessage, errInvalidPayload, errInvalidRange, errEditEmpty,
+		errJoinInvalid, errReactionInvalid, errInvalidMedia, errCallInvalidMedia, errCallSelf, errInvalidDNDPeriod)
+	messages.RegisterError(messages.ErrCodeUnsupported, errRecallNotSupported, errEditNotSupported,
+		errHistoryNotSupported, errConversationsNotSupported, errReplyNotSupported, errThreadNotSupported,
+		errJoinNotSupported, errPushNotEnabled, errUploadNotEnabled, errReadersNotSupported)
+	messages.RegisterError(messages.ErrCodeBusy, errWorkerQueueFull, errHistoryUnavailable)
+	messages.RegisterError(messages.ErrCodeNotFound, errCallNotExist, errScheduledNotExist, errServiceNotExist,
+		errJoinRequestNotExist)
+	messages.RegisterError(messages.ErrCodePermissionDenied, errNotMessageOwner, errNotParticipant,
+		errNotChannelAdmin, errNotServiceAgent, errNotInMessageConv)
+	messages.RegisterError(messages.ErrCodeLimitExceeded, errTooManyReactions, errChannelTooLarge)
+	messages.RegisterError(messages.ErrCodeInvalidState, errCallInvalidState, errAlreadyMember)
+	messages.RegisterError(messages.ErrCodeWindowExpired, errWindowExpired, errJoinRequestExpired)
+	messages.RegisterError(messages.ErrCodeMessageBlocked, errMessageBlocked)
+	messages.RegisterError(messages.ErrCodeNoReceiver, errNoReceiver)
+	messages.RegisterError(messages.ErrCodeRelationBlocked, errBlocked)
+	messages.RegisterError(messages.ErrCodeNotContact, errNotContact)
+}
@@ -0,0 +1,52 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/federation"
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+// EnableFederation forwards P2P messages to receivers homed in other regions to the clusters of the regions, and
+// delivers messages forwarded from other regions to receivers homed in this region, see package federation. The chat
+// message forwarded is stored as offline message of the home region if the receiver is offline.
+func (d *MessageHandlerImpl) EnableFederation(f *federation.Federation) {
+	d.federation = f
+	f.SetDeliver(d.deliverFederated)
+}
+
+// forwardRemote forwards the message to the receiver homed in another region, returns false if the receiver is
+// homed in this region or the message is failed to forward.
+func (d *MessageHandlerImpl) forwardRemote(uid string, m *messages.GlideMessage) bool {
+	if d.federation == nil {
+		return false
+	}
+	region, remote := d.federation.Remote(uid)
+	if !remote {
+		return false
+	}
+	if err := d.federation.Forward(region, uid, m); err != nil {
+		log.E("forward message to %s of region %s error: %v", uid, region, err)
+		return false
+	}
+	return true
+}
+
+// deliverFederated delivers the message from another region to devices of the receiver, the message is never
+// forwarded again by this handler.
+func (d *MessageHandlerImpl) deliverFederated(e *federation.Envelope) error {
+	m := e.Message
+	if d.dispatchDevices(e.To, m) {
+		return nil
+	}
+	if m.GetAction() != messages.ActionChatMessage || m.GetQoS() != messages.QoSAtLeastOnce {
+		return nil
+	}
+	msg := new(messages.ChatMessage)
+	if err := m.Data.Deserialize(msg); err != nil {
+		return err
+	}
+	if d.push != nil {
+		d.push.Notify(e.To, conversation.NewP2P(msg.From, msg.To).ID, msg)
+	}
+	return d.store.StoreOffline(msg)
+}
@@ -0,0 +1,46 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/federation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type federationPeer struct {
+	f *federation.Federation
+}
+
+func (p *federationPeer) Forward(e *federation.Envelope) error {
+	return p.f.Receive(e)
+}
+
+func TestMessageHandlerImpl_Federation(t *testing.T) {
+	registry := federation.NewMemRegistry("us")
+	registry.Set("2", "eu")
+
+	newRegion := func(region string) (*MessageHandlerImpl, *mockGateway, *federation.Federation) {
+		g := newMockGateway()
+		handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}})
+		assert.NoError(t, err)
+		handler.SetGate(g)
+		f, err := federation.New(&federation.Options{Region: region, Registry: registry})
+		assert.NoError(t, err)
+		handler.EnableFederation(f)
+		return handler, g, f
+	}
+	us, usGateway, usFederation := newRegion("us")
+	_, euGateway, euFederation := newRegion("eu")
+	usFederation.SetPeer("eu", &federationPeer{f: euFederation})
+
+	sendChat(t, us, "1", "2", "hello")
+	// the message is delivered in the home region of the receiver only.
+	assert.Empty(t, usGateway.messagesOf(gate.NewID("", "2", "1")))
+	received := euGateway.messagesOf(gate.NewID("", "2", "1"))
+	assert.Len(t, received, 1)
+	assert.Equal(t, messages.Action(messages.ActionChatMessage), received[0].GetAction())
+
+	sendChat(t, us, "2", "1", "hi")
+	assert.Len(t, usGateway.messagesOf(gate.NewID("", "1", "1")), 1)
+}
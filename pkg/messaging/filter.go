@@ -0,0 +1,245 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Actions of the message filter rule.
+const (
+	FilterActionBlock  = "block"
+	FilterActionRedact = "redact"
+	FilterActionTag    = "tag"
+)
+
+const (
+	defaultRedactReplacement = "***"
+	errMessageBlocked        = "message blocked"
+
+	// extraKeyTags the key of message extra carrying tags of matched rules, separated by comma.
+	extraKeyTags = "tags"
+)
+
+// FilterRule matches message content by keywords, regex pattern or payload size.
+type FilterRule struct {
+	// Name the unique name of the rule.
+	Name string `json:"name"`
+	// Keywords matched case-insensitively.
+	Keywords []string `json:"keywords,omitempty"`
+	// Pattern the regular expression to match.
+	Pattern string `json:"pattern,omitempty"`
+	// MaxSize the max size in bytes of the content, 0 for unlimited.
+	MaxSize int `json:"max_size,omitempty"`
+	// Action block, redact or tag.
+	Action string `json:"action"`
+	// Tag the tag of the message when Action is tag, default the rule name.
+	Tag string `json:"tag,omitempty"`
+	// Replacement the text to replace the matched when Action is redact, default "***".
+	Replacement string `json:"replacement,omitempty"`
+}
+
+type compiledRule struct {
+	FilterRule
+	keywords []*regexp.Regexp
+	pattern  *regexp.Regexp
+}
+
+func compileRule(r FilterRule) (*compiledRule, error) {
+	if r.Name == "" {
+		return nil, errors.New("rule name is empty")
+	}
+	switch r.Action {
+	case FilterActionBlock, FilterActionRedact, FilterActionTag:
+	default:
+		return nil, errors.New("unknown rule action: " + r.Action)
+	}
+	if len(r.Keywords) == 0 && r.Pattern == "" && r.MaxSize <= 0 {
+		return nil, errors.New("rule matches nothing: " + r.Name)
+	}
+	c := &compiledRule{FilterRule: r}
+	if c.Replacement == "" {
+		c.Replacement = defaultRedactReplacement
+	}
+	if c.Tag == "" {
+		c.Tag = c.Name
+	}
+	for _, k := range r.Keywords {
+		c.keywords = append(c.keywords, regexp.MustCompile("(?i)"+regexp.QuoteMeta(k)))
+	}
+	if r.Pattern != "" {
+		p, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		c.pattern = p
+	}
+	return c, nil
+}
+
+// match returns true if the content is matched by the rule, and the content redacted.
+func (c *compiledRule) match(content string) (bool, string) {
+	matched := false
+	if c.MaxSize > 0 && len(content) > c.MaxSize {
+		matched = true
+		if c.Action == FilterActionRedact {
+			content = content[:c.MaxSize]
+		}
+	}
+	res := c.keywords
+	if c.pattern != nil {
+		res = append(res[:len(res):len(res)], c.pattern)
+	}
+	for _, re := range res {
+		if !re.MatchString(content) {
+			continue
+		}
+		matched = true
+		if c.Action == FilterActionRedact {
+			content = re.ReplaceAllLiteralString(content, c.Replacement)
+		}
+	}
+	return matched, content
+}
+
+// FilterResult is the result of filtering a message.
+type FilterResult struct {
+	// Blocked true if the message is blocked by any rule.
+	Blocked bool
+	// Content the content redacted.
+	Content string
+	// Tags the tags of matched tag rules.
+	Tags []string
+}
+
+// MessageFilter is the rule engine to block, redact or tag messages, rules can be updated at runtime.
+type MessageFilter struct {
+	mu    sync.RWMutex
+	rules []*compiledRule
+}
+
+func NewMessageFilter() *MessageFilter {
+	return &MessageFilter{}
+}
+
+// SetRules replaces all rules.
+func (f *MessageFilter) SetRules(rules []FilterRule) error {
+	var compiled []*compiledRule
+	for _, r := range rules {
+		c, err := compileRule(r)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, c)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = compiled
+	return nil
+}
+
+// AddRule adds the rule, the rule with the same name is replaced.
+func (f *MessageFilter) AddRule(r FilterRule) error {
+	c, err := compileRule(r)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rules := make([]*compiledRule, 0, len(f.rules)+1)
+	for _, rule := range f.rules {
+		if rule.Name != r.Name {
+			rules = append(rules, rule)
+		}
+	}
+	f.rules = append(rules, c)
+	return nil
+}
+
+// RemoveRule removes the rule by name, returns false if the rule does not exist.
+func (f *MessageFilter) RemoveRule(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rules := make([]*compiledRule, 0, len(f.rules))
+	for _, rule := range f.rules {
+		if rule.Name != name {
+			rules = append(rules, rule)
+		}
+	}
+	removed := len(rules) != len(f.rules)
+	f.rules = rules
+	return removed
+}
+
+// Rules returns all rules.
+func (f *MessageFilter) Rules() []FilterRule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	ret := make([]FilterRule, 0, len(f.rules))
+	for _, r := range f.rules {
+		ret = append(ret, r.FilterRule)
+	}
+	return ret
+}
+
+// Filter applies all rules to the content in order, stops at the first block rule matched.
+func (f *MessageFilter) Filter(content string) *FilterResult {
+	f.mu.RLock()
+	rules := f.rules
+	f.mu.RUnlock()
+
+	result := &FilterResult{Content: content}
+	for _, r := range rules {
+		matched, redacted := r.match(result.Content)
+		if !matched {
+			continue
+		}
+		metrics.FilterHits.WithLabelValues(r.Name, r.Action).Inc()
+		switch r.Action {
+		case FilterActionBlock:
+			result.Blocked = true
+			return result
+		case FilterActionRedact:
+			result.Content = redacted
+		case FilterActionTag:
+			result.Tags = append(result.Tags, r.Tag)
+		}
+	}
+	return result
+}
+
+// MessageFilter returns the message filter of the handler, used to manage rules at runtime.
+func (d *MessageHandlerImpl) MessageFilter() *MessageFilter {
+	return d.filter
+}
+
+// filterChatMessage applies filter rules to the message, the content is redacted in place. Returns tags of matched
+// rules, and false if the message is blocked, the sender is notified.
+func (d *MessageHandlerImpl) filterChatMessage(c *gate.Info, m *messages.GlideMessage, msg *messages.ChatMessage) ([]string, bool) {
+	result := d.filter.Filter(msg.Content)
+	if result.Blocked {
+		rejected := messages.MessageRejected{CliMid: msg.CliMid, To: m.To, Reason: errMessageBlocked}
+		d.enqueueMessage(c.ID, messages.NewMessage(m.GetSeq(), messages.ActionNotifyRejected, &rejected))
+		return nil, false
+	}
+	msg.Content = result.Content
+	return result.Tags, true
+}
+
+// tagMessage sets tags of matched filter rules to the extra of message.
+func tagMessage(m *messages.GlideMessage, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if m.Extra == nil {
+		m.Extra = map[string]string{}
+	}
+	m.Extra[extraKeyTags] = strings.Join(tags, ",")
+}
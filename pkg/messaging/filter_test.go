@@ -0,0 +1,80 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMessageFilter_Filter(t *testing.T) {
+	f := NewMessageFilter()
+	err := f.SetRules([]FilterRule{
+		{Name: "ad", Keywords: []string{"BUY NOW"}, Action: FilterActionTag},
+		{Name: "phone", Pattern: `\d{11}`, Action: FilterActionRedact},
+		{Name: "size", MaxSize: 20, Action: FilterActionBlock},
+	})
+	assert.NoError(t, err)
+
+	r := f.Filter("buy now 13800138000")
+	assert.False(t, r.Blocked)
+	assert.Equal(t, "buy now ***", r.Content)
+	assert.Equal(t, []string{"ad"}, r.Tags)
+
+	r = f.Filter("this message is longer than twenty bytes")
+	assert.True(t, r.Blocked)
+
+	assert.True(t, f.RemoveRule("size"))
+	assert.False(t, f.Filter("this message is longer than twenty bytes").Blocked)
+	assert.Len(t, f.Rules(), 2)
+
+	assert.Error(t, f.AddRule(FilterRule{Name: "bad", Pattern: "(", Action: FilterActionBlock}))
+	assert.Error(t, f.AddRule(FilterRule{Name: "unknown", Keywords: []string{"a"}, Action: "drop"}))
+}
+
+func TestMessageHandlerImpl_FilterChatMessage(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	_ = handler.MessageFilter().AddRule(FilterRule{Name: "spam", Keywords: []string{"spam"}, Action: FilterActionBlock})
+	_ = handler.MessageFilter().AddRule(FilterRule{Name: "greeting", Keywords: []string{"hello"}, Action: FilterActionTag})
+
+	sendChat(t, handler, "1", "2", "spam")
+	assert.Empty(t, g.messagesOf(gate.NewID2("2")))
+	assert.Equal(t, messages.ActionNotifyRejected, g.messagesOf(gate.NewID2("1"))[0].GetAction())
+
+	sendChat(t, handler, "1", "2", "hello")
+	received := g.messagesOf(gate.NewID2("2"))
+	assert.Equal(t, "greeting", received[0].Extra["tags"])
+}
+
+func TestMessageHandlerImpl_FilterChatMessage_Resend(t *testing.T) {
+	s := &mockRecallStore{messages: map[int64]*messages.ChatMessage{1: {Mid: 1, From: "1", To: "2"}}}
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	_ = handler.MessageFilter().AddRule(FilterRule{Name: "spam", Keywords: []string{"spam"}, Action: FilterActionBlock})
+	_ = handler.MessageFilter().AddRule(FilterRule{Name: "phone", Pattern: `\d{11}`, Action: FilterActionRedact})
+
+	resend := func(content string) {
+		m := &messages.GlideMessage{
+			Action: string(messages.ActionChatMessageResend),
+			To:     "2",
+			Data:   messages.NewData(&messages.ChatMessage{CliMid: content, Mid: 1, Content: content}),
+		}
+		assert.NoError(t, handler.handleChatMessage(&gate.Info{ID: gate.NewID2("1")}, m))
+	}
+
+	resend("spam")
+	assert.Empty(t, g.messagesOf(gate.NewID2("2")))
+	assert.Equal(t, messages.ActionNotifyRejected, g.messagesOf(gate.NewID2("1"))[0].GetAction())
+
+	resend("13800138000")
+	received := g.messagesOf(gate.NewID2("2"))
+	assert.Len(t, received, 1)
+	cm := messages.ChatMessage{}
+	assert.NoError(t, received[0].Data.Deserialize(&cm))
+	assert.Equal(t, "***", cm.Content)
+}
@@ -0,0 +1,118 @@
+package messaging
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/glide-im/glide/pkg/webhook"
+)
+
+const defaultGuestAliasWindow = time.Minute * 30
+
+// UpgradeResult the result of MessageHandlerImpl.UpgradeGuest.
+type UpgradeResult struct {
+	GuestID string `json:"guest_id"`
+	Uid     string `json:"uid"`
+	// Channels the channels the subscriptions of guest moved to the user.
+	Channels []string `json:"channels"`
+}
+
+type guestAlias struct {
+	uid string
+	at  time.Time
+}
+
+// guestAliases maps the temp uid of guests upgraded to the uid of the user in a window, so the messages replied to
+// the guest after signed in are delivered to the user.
+type guestAliases struct {
+	mu      sync.Mutex
+	window  time.Duration
+	aliases map[string]guestAlias
+}
+
+func newGuestAliases(window time.Duration) *guestAliases {
+	if window <= 0 {
+		window = defaultGuestAliasWindow
+	}
+	return &guestAliases{
+		window:  window,
+		aliases: map[string]guestAlias{},
+	}
+}
+
+func (g *guestAliases) set(guest string, uid string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	for k, a := range g.aliases {
+		if now.Sub(a.at) > g.window {
+			delete(g.aliases, k)
+		}
+	}
+	g.aliases[guest] = guestAlias{uid: uid, at: now}
+}
+
+// resolve returns the uid of the user the guest upgraded to, or uid itself if it's not a guest upgraded.
+func (g *guestAliases) resolve(uid string) string {
+	if !gate.IsTempUID(uid) {
+		return uid
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	a, ok := g.aliases[uid]
+	if !ok {
+		return uid
+	}
+	if time.Since(a.at) > g.window {
+		delete(g.aliases, uid)
+		return uid
+	}
+	return a.uid
+}
+
+// UpgradeGuest keeps the conversations of the guest signed in as the user: the guest is replaced by the user in all
+// channels it subscribed with the same permission, and P2P messages to the guest in the GuestAliasWindow are
+// delivered to the user, the webhook.EventGuestUpgraded is emitted. It's called when the client authenticated with
+// gate.ClientAuthCredentials.GuestID, the message history of the guest is kept as it is.
+func (d *MessageHandlerImpl) UpgradeGuest(guest string, uid string) (*UpgradeResult, error) {
+	if !gate.IsTempUID(guest) {
+		return nil, errors.New("guest id is not a temp uid")
+	}
+	if uid == "" || gate.IsTempUID(uid) {
+		return nil, errors.New("invalid uid")
+	}
+	ret := &UpgradeResult{GuestID: guest, Uid: uid, Channels: []string{}}
+
+	d.guests.set(guest, uid)
+
+	if renamer, ok := d.def.GetGroupInterface().(subscription.SubscriberRenamer); ok {
+		chs, err := renamer.RenameSubscriber(subscription.SubscriberID(guest), subscription.SubscriberID(uid))
+		for _, ch := range chs {
+			ret.Channels = append(ret.Channels, string(ch))
+		}
+		if err != nil {
+			return ret, err
+		}
+	}
+
+	log.I("guest %s upgraded to %s, moved to %d channels", guest, uid, len(ret.Channels))
+	webhook.Emit(webhook.EventGuestUpgraded, &webhook.GuestUpgradedEventData{
+		GuestID:  guest,
+		Uid:      uid,
+		Channels: ret.Channels,
+	})
+	return ret, nil
+}
+
+func (d *MessageHandlerImpl) handleInternalUpgrade(c *gate.Info, m *messages.GlideMessage) error {
+	upgrade := new(messages.GuestUpgrade)
+	if err := m.Data.Deserialize(upgrade); err != nil {
+		return err
+	}
+	_, err := d.UpgradeGuest(upgrade.GuestID, upgrade.Uid)
+	return err
+}
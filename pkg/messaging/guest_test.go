@@ -0,0 +1,63 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSubscriberRenamer struct {
+	mockSubscriberRemover
+}
+
+func (m *mockSubscriberRenamer) RenameSubscriber(from subscription.SubscriberID, to subscription.SubscriberID) ([]subscription.ChanID, error) {
+	var ret []subscription.ChanID
+	for ch, subscribers := range m.channels {
+		for i, s := range subscribers {
+			if s == from {
+				subscribers[i] = to
+				ret = append(ret, ch)
+				break
+			}
+		}
+	}
+	return ret, nil
+}
+
+func TestMessageHandlerImpl_UpgradeGuest(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	sub := &mockSubscriberRenamer{mockSubscriberRemover{channels: map[subscription.ChanID][]subscription.SubscriberID{
+		"cs": {"tmp@1", "agent"},
+	}}}
+	handler.SetSubscription(sub)
+
+	upgrade := messages.NewMessage(0, messages.ActionInternalUpgrade, &messages.GuestUpgrade{GuestID: "tmp@1", Uid: "1"})
+	assert.NoError(t, handler.handleInternalUpgrade(&gate.Info{ID: gate.NewID2("1")}, upgrade))
+	assert.Equal(t, []subscription.SubscriberID{"1", "agent"}, sub.channels["cs"])
+
+	// the reply to the guest is delivered to the user
+	sendChat(t, handler, "agent", "tmp@1", "hello")
+	assert.Len(t, g.messagesOf(gate.NewID("", "1", "")), 1)
+	assert.Empty(t, g.messagesOf(gate.NewID("", "tmp@1", "")))
+
+	_, err = handler.UpgradeGuest("2", "1")
+	assert.Error(t, err)
+	_, err = handler.UpgradeGuest("tmp@2", "tmp@3")
+	assert.Error(t, err)
+}
+
+func TestGuestAliases_Expire(t *testing.T) {
+	g := newGuestAliases(time.Millisecond * 10)
+	g.set("tmp@1", "1")
+	assert.Equal(t, "1", g.resolve("tmp@1"))
+	assert.Equal(t, "2", g.resolve("2"))
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, "tmp@1", g.resolve("tmp@1"))
+}
@@ -1,13 +1,28 @@
 package messaging
 
 import (
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/degrade"
+	"github.com/glide-im/glide/pkg/federation"
 	"github.com/glide-im/glide/pkg/gate"
-	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/media"
 	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/moderation"
+	"github.com/glide-im/glide/pkg/push"
+	"github.com/glide-im/glide/pkg/relation"
+	"github.com/glide-im/glide/pkg/schedule"
+	"github.com/glide-im/glide/pkg/sequence"
+	"github.com/glide-im/glide/pkg/service"
 	"github.com/glide-im/glide/pkg/store"
 	"github.com/glide-im/glide/pkg/subscription"
+	"sync/atomic"
+	"time"
 )
 
+// defaultMaxMessageConcurrency the max count of messages handled concurrently by MessageHandlerImpl.
+const defaultMaxMessageConcurrency = 100_000
+
 var _ Messaging = (*MessageHandlerImpl)(nil)
 
 type MessageHandlerOptions struct {
@@ -19,6 +34,135 @@ type MessageHandlerOptions struct {
 
 	// NotifyOnErr true express notify client on server error.
 	NotifyOnErr bool
+
+	// StateMessageInterval the min interval of state messages from a sender to the same target, default 500ms.
+	StateMessageInterval time.Duration
+
+	// RecallWindow the max duration after sending that a message can be recalled, default 2 minutes.
+	RecallWindow time.Duration
+
+	// EditWindow the max duration after sending that a message can be edited, default 24 hours.
+	EditWindow time.Duration
+
+	// ReadCursorStore stores read cursors of conversations, default is MessageStore if it implements
+	// store.ReadCursorStore, otherwise store.MemReadCursorStore.
+	ReadCursorStore store.ReadCursorStore
+
+	// ReactionStore stores reactions of messages, default is MessageStore if it implements store.ReactionStore,
+	// otherwise store.MemReactionStore.
+	ReactionStore store.ReactionStore
+
+	// MaxReactions the max count of distinct emojis reacted to a message, default 20.
+	MaxReactions int
+
+	// JoinRequestStore stores requests to join channels, default is MessageStore if it implements
+	// store.JoinRequestStore, otherwise store.MemJoinRequestStore.
+	JoinRequestStore store.JoinRequestStore
+
+	// JoinRequestTTL the duration join requests not approved or rejected expire, default 7 days.
+	JoinRequestTTL time.Duration
+
+	// SequenceAllocator allocates sequence of stored message per conversation, default sequence.SegmentAllocator if
+	// MessageStore implements sequence.SegmentStore, otherwise sequence.MemAllocator. Use sequence.RedisAllocator in
+	// cluster.
+	SequenceAllocator sequence.Allocator
+
+	// PresenceDebounce the duration to delay offline notification of user presence, brief reconnects in the duration
+	// are not notified, default 3 seconds.
+	PresenceDebounce time.Duration
+
+	// PushBridge pushes chat messages to devices of offline receivers, nil to disable.
+	PushBridge *push.Bridge
+
+	// MessageFilter the rule engine to block, redact or tag chat and channel messages, default an empty filter, rules
+	// can be added at runtime by MessageHandlerImpl.MessageFilter.
+	MessageFilter *MessageFilter
+
+	// Moderator moderates chat and channel messages, nil to disable.
+	Moderator moderation.Moderator
+
+	// ModerationMode moderates message before delivery or after delivery, default moderation.ModeSync.
+	ModerationMode moderation.Mode
+
+	// DedupWindow the duration of remembering client message id to detect duplicate sending, default 5 minutes.
+	DedupWindow time.Duration
+
+	// Workers the count of shared workers handle messages, messages of a conversation are handled in order, 0 to
+	// handle messages in goroutines of pool, a goroutine per conversation.
+	Workers int
+
+	// WorkerQueueSize the capacity of task queue of each worker, default 1024.
+	WorkerQueueSize int
+
+	// ConversationQueueSize the max count of messages of a conversation waiting to be handled when Workers is 0,
+	// default 1024.
+	ConversationQueueSize int
+
+	// RouteRule the default rule to deliver P2P messages to devices of receiver, default all devices.
+	RouteRule *RouteRule
+
+	// Uploader issues upload tokens of media messages, nil to disable.
+	Uploader *media.Uploader
+
+	// CallRingTimeout the duration the invited call is ended if not answered, default 30 seconds.
+	CallRingTimeout time.Duration
+
+	// NotifyExpired notifies the sender when the message is dropped from the offline queue as its TTL expires.
+	NotifyExpired bool
+
+	// Relations the block list consulted before delivering P2P messages, nil to disable.
+	Relations relation.RelationProvider
+
+	// DropBlocked drops messages to the receiver blocked the sender silently, the sender is acked as usual,
+	// otherwise the sender is notified with messages.ActionNotifyRejected.
+	DropBlocked bool
+
+	// RequireContact rejects P2P messages between users who are not contacts, unless the message is sent with the
+	// bypass ticket, see gate.BypassTicketKey. Relations must implement relation.ContactProvider.
+	RequireContact bool
+
+	// GuestAliasWindow the duration P2P messages to the guest upgraded are delivered to the user signed in, default
+	// 30 minutes, see MessageHandlerImpl.UpgradeGuest.
+	GuestAliasWindow time.Duration
+
+	// SessionLocator looks up the gateways of users in batch for SendToUsers, optional, users are looked up by known
+	// devices on the gateway of the handler if nil.
+	SessionLocator gate.SessionLocator
+
+	// Degradation the controller of degraded mode, history APIs are rejected and messages are delivered without
+	// sequence if the sequence allocator is unavailable while degraded, nil to disable. Wrap MessageStore by
+	// degrade.NewStore to skip persistence while degraded.
+	Degradation *degrade.Controller
+
+	// Quotas the default quotas of messages, nil for unlimited, updated by SetRateLimit with the quota name.
+	Quotas *QuotaLimits
+
+	// QuotaProvider provides quotas of users and channels from the business service, nil to apply Quotas only.
+	QuotaProvider QuotaProvider
+
+	// DeadLetters the sink of undeliverable messages, such as messages expired offline, failed to be queued offline
+	// after retries or sent to users not exist, nil to disable. Dead letters can be inspected and redelivered by
+	// MessageHandlerImpl.Redeliver if it implements store.DeadLetterStore.
+	DeadLetters store.DeadLetterSink
+
+	// Users the directory to reject P2P messages to users not exist, nil to disable.
+	Users UserDirectory
+
+	// ReadCountInterval the interval read counts of the latest channel messages are pushed to their senders by
+	// messages.ActionNotifyReadCount, read receipts in the interval are aggregated, 0 to disable.
+	ReadCountInterval time.Duration
+
+	// ReadCountWindow the count of the latest messages of a channel whose read counts are pushed, default 20.
+	ReadCountWindow int
+
+	// MaxReaderListMembers the max count of subscribers of channels whose reader list can be queried by
+	// messages.ActionApiReadReaders, default 100.
+	MaxReaderListMembers int
+
+	// Transformer transforms chat messages for each recipient device by the locale of the device before delivery,
+	// such as machine translation, nil to disable. Messages stored, pushed and forwarded to other regions are not
+	// transformed.
+	Transformer Transformer
 }
 
 // MessageHandlerImpl .
@@ -27,12 +171,69 @@ type MessageHandlerImpl struct {
 	store store.MessageStore
 
 	userState *UserState
+
+	stateLimiter *stateLimiter
+	recallWindow time.Duration
+	editWindow   time.Duration
+	readCursors  store.ReadCursorStore
+	reactions    store.ReactionStore
+	maxReactions int
+	joinRequests *joinRequests
+	seqAllocator sequence.Allocator
+	dedup        *dedupCache
+	push         *push.Bridge
+	uploader     *media.Uploader
+	calls        *callManager
+
+	expiry        *offlineExpiry
+	notifyExpired bool
+	scheduler     *schedule.Scheduler
+
+	relations   relation.RelationProvider
+	dropBlocked bool
+	// contacts the contacts required to send P2P messages, nil if not required.
+	contacts relation.ContactProvider
+
+	filter         *MessageFilter
+	moderator      moderation.Moderator
+	moderationMode moderation.Mode
+
+	routers map[conversation.Type]ConversationRouter
+
+	// routeRule the default *RouteRule.
+	routeRule atomic.Value
+	devices   *deviceTracker
+	guests    *guestAliases
+	// services the service accounts routed to agents, nil if disabled.
+	services *service.Manager
+	// sessions looks up gateways of users for SendToUsers, nil to look up by known devices.
+	sessions gate.SessionLocator
+	// degradation the controller of degraded mode, nil if disabled.
+	degradation *degrade.Controller
+	quotas      *quotas
+	// deadLetters the sink of undeliverable messages, nil if disabled.
+	deadLetters store.DeadLetterSink
+	// purgers the stores keeping data of users outside the message store, such as the archive, see PurgeUser.
+	purgers []store.UserPurgeStore
+	// users the directory of users, nil if disabled.
+	users UserDirectory
+	// federation routes messages to users homed in other regions, nil if disabled.
+	federation *federation.Federation
+	// readCounts aggregates read receipts of channels to push read counts, nil if disabled.
+	readCounts           *readCountAggregator
+	maxReaderListMembers int
+	// transformer transforms chat messages for recipient devices, nil if disabled.
+	transformer Transformer
+	locales     *localeTracker
 }
 
 func NewHandlerWithOptions(gateway gate.Gateway, opts *MessageHandlerOptions) (*MessageHandlerImpl, error) {
 	impl, err := NewDefaultImpl(&Options{
 		NotifyServerError:     true,
-		MaxMessageConcurrency: 10_0000,
+		MaxMessageConcurrency: defaultMaxMessageConcurrency,
+		Workers:               opts.Workers,
+		WorkerQueueSize:       opts.WorkerQueueSize,
+		ConversationQueueSize: opts.ConversationQueueSize,
 	})
 	if err != nil {
 		return nil, err
@@ -40,9 +241,111 @@ func NewHandlerWithOptions(gateway gate.Gateway, opts *MessageHandlerOptions) (*
 	impl.SetNotifyErrorOnServer(opts.NotifyOnErr)
 
 	ret := &MessageHandlerImpl{
-		def:       impl,
-		store:     opts.MessageStore,
-		userState: NewUserState(gateway),
+		def:          impl,
+		store:        opts.MessageStore,
+		userState:    NewUserState(gateway),
+		stateLimiter: newStateLimiter(opts.StateMessageInterval),
+		recallWindow: opts.RecallWindow,
+		editWindow:   opts.EditWindow,
+		readCursors:  opts.ReadCursorStore,
+		reactions:    opts.ReactionStore,
+		maxReactions: opts.MaxReactions,
+		joinRequests: newJoinRequests(opts.JoinRequestTTL),
+		seqAllocator: opts.SequenceAllocator,
+		dedup:        newDedupCache(opts.DedupWindow),
+		push:         opts.PushBridge,
+		uploader:     opts.Uploader,
+		calls:        newCallManager(opts.CallRingTimeout),
+
+		expiry:        newOfflineExpiry(),
+		notifyExpired: opts.NotifyExpired,
+
+		relations:   opts.Relations,
+		dropBlocked: opts.DropBlocked,
+
+		filter:         opts.MessageFilter,
+		moderator:      opts.Moderator,
+		moderationMode: opts.ModerationMode,
+		devices:        newDeviceTracker(),
+		guests:         newGuestAliases(opts.GuestAliasWindow),
+		sessions:       opts.SessionLocator,
+		degradation:    opts.Degradation,
+		quotas:         newQuotas(opts.Quotas, opts.QuotaProvider),
+		deadLetters:    opts.DeadLetters,
+		users:          opts.Users,
+
+		maxReaderListMembers: opts.MaxReaderListMembers,
+		transformer:          opts.Transformer,
+		locales:              newLocaleTracker(),
+	}
+	routeRule := opts.RouteRule
+	if routeRule == nil {
+		routeRule = &RouteRule{Policy: RouteAllDevices}
+	}
+	if err = ret.SetRouteRule(routeRule); err != nil {
+		return nil, err
+	}
+	presenceDebounce := opts.PresenceDebounce
+	if presenceDebounce <= 0 {
+		presenceDebounce = defaultPresenceDebounce
+	}
+	ret.userState.SetDebounce(presenceDebounce)
+	if ret.filter == nil {
+		ret.filter = NewMessageFilter()
+	}
+	if ret.seqAllocator == nil {
+		if ss, ok := store.As[sequence.SegmentStore](opts.MessageStore); ok {
+			ret.seqAllocator = sequence.NewSegmentAllocator(ss, 0)
+		} else {
+			ret.seqAllocator = sequence.NewMemAllocator()
+		}
+	}
+	if ret.recallWindow <= 0 {
+		ret.recallWindow = defaultRecallWindow
+	}
+	if ret.editWindow <= 0 {
+		ret.editWindow = defaultEditWindow
+	}
+	if ret.readCursors == nil {
+		if rs, ok := store.As[store.ReadCursorStore](opts.MessageStore); ok {
+			ret.readCursors = rs
+		} else {
+			ret.readCursors = store.NewMemReadCursorStore()
+		}
+	}
+	if ret.reactions == nil {
+		if rs, ok := store.Unwrap(opts.MessageStore).(store.ReactionStore); ok {
+			ret.reactions = rs
+		} else {
+			ret.reactions = store.NewMemReactionStore()
+		}
+	}
+	if opts.ReadCountInterval > 0 {
+		ret.readCounts = newReadCountAggregator(opts.ReadCountInterval, opts.ReadCountWindow, ret.flushReadCounts)
+	}
+	if ret.maxReaderListMembers <= 0 {
+		ret.maxReaderListMembers = defaultMaxReaderListMembers
+	}
+	if ret.maxReactions <= 0 {
+		ret.maxReactions = defaultMaxReactions
+	}
+	if opts.JoinRequestStore != nil {
+		ret.joinRequests.store = opts.JoinRequestStore
+	} else if js, ok := store.Unwrap(opts.MessageStore).(store.JoinRequestStore); ok {
+		ret.joinRequests.store = js
+	} else {
+		ret.joinRequests.store = store.NewMemJoinRequestStore()
+	}
+	if opts.RequireContact {
+		cp, ok := opts.Relations.(relation.ContactProvider)
+		if !ok {
+			return nil, errors.New("relation provider does not provide contacts")
+		}
+		ret.contacts = cp
+	}
+	ret.routers = map[conversation.Type]ConversationRouter{
+		conversation.TypeP2P:     ret.routeP2P,
+		conversation.TypeChannel: ret.routeChannel,
 	}
 	if !opts.DontInitDefaultHandler {
 		ret.InitDefaultHandler(nil)
@@ -65,7 +368,44 @@ func (d *MessageHandlerImpl) InitDefaultHandler(callback func(action messages.Ac
 		messages.ActionHeartbeat:       d.handleHeartbeat,
 		messages.ActionInternalOnline:  d.handleInternalOnline,
 		messages.ActionInternalOffline: d.handleInternalOffline,
+		messages.ActionInternalUpgrade: d.handleInternalUpgrade,
 		messages.ActionApiSubUserState: d.userState.subUserStateApi,
+
+		messages.ActionStateMessage:        d.handleStateMessage,
+		messages.ActionGroupStateMessage:   d.handleStateMessage,
+		messages.ActionMessageRecall:       d.handleRecallMessage,
+		messages.ActionGroupRecall:         d.handleRecallMessage,
+		messages.ActionMessageEdit:         d.handleEditMessage,
+		messages.ActionGroupMessageEdit:    d.handleEditMessage,
+		messages.ActionMessageRead:         d.handleReadMessage,
+		messages.ActionGroupMessageRead:    d.handleReadMessage,
+		messages.ActionMessageReact:        d.handleReaction,
+		messages.ActionMessageUnreact:      d.handleReaction,
+		messages.ActionGroupMessageReact:   d.handleReaction,
+		messages.ActionGroupMessageUnreact: d.handleReaction,
+		messages.ActionApiReadCursors:      d.handleApiReadCursors,
+		messages.ActionApiReadCount:        d.handleApiReadCount,
+		messages.ActionApiReadReaders:      d.handleApiReadReaders,
+		messages.ActionApiMessageRange:     d.handleApiMessageRange,
+		messages.ActionApiGetConversations: d.handleApiGetConversations,
+		messages.ActionApiSync:             d.handleApiSync,
+		messages.ActionApiUnsubUserState:   d.userState.unsubUserStateApi,
+		messages.ActionApiUserState:        d.userState.queryUserStateApi,
+		messages.ActionApiPushRegister:     d.handleApiPushRegister,
+		messages.ActionApiPushSettings:     d.handleApiPushSettings,
+		messages.ActionApiPushSettingsSet:  d.handleApiPushSettingsSet,
+		messages.ActionApiUploadToken:      d.handleApiUploadToken,
+		messages.ActionApiGroupJoin:        d.handleApiGroupJoin,
+		messages.ActionApiGroupJoinApprove: d.handleApiGroupJoinApprove,
+		messages.ActionApiGroupJoinReject:  d.handleApiGroupJoinReject,
+		messages.ActionApiGroupJoinList:    d.handleApiGroupJoinList,
+
+		messages.ActionCallInvite:    d.handleCallSignal,
+		messages.ActionCallRinging:   d.handleCallSignal,
+		messages.ActionCallAnswer:    d.handleCallSignal,
+		messages.ActionCallReject:    d.handleCallSignal,
+		messages.ActionCallHangup:    d.handleCallSignal,
+		messages.ActionCallCandidate: d.handleCallSignal,
 	}
 	for action, handlerFunc := range m {
 		if callback != nil {
@@ -82,7 +422,31 @@ func (d *MessageHandlerImpl) AddHandler(i MessageHandler) {
 	d.def.AddHandler(i)
 }
 
+// Actions returns the registry of application specific action handlers, see RegisterHandler.
+func (d *MessageHandlerImpl) Actions() *ActionRegistry {
+	return d.def.Actions()
+}
+
+// AddPresenceObserver registers an observer notified on every presence change of users, used by business services.
+func (d *MessageHandlerImpl) AddPresenceObserver(o PresenceObserver) {
+	d.userState.AddPresenceObserver(o)
+}
+
+// UserState returns the presence subsystem of the handler.
+func (d *MessageHandlerImpl) UserState() *UserState {
+	return d.userState
+}
+
+// AddPresenceSource registers an external presence source, the user online state notified to subscribers is merged
+// from gateway connections and all sources.
+func (d *MessageHandlerImpl) AddPresenceSource(s PresenceSource) {
+	d.userState.AddPresenceSource(s)
+}
+
 func (d *MessageHandlerImpl) Handle(cInfo *gate.Info, msg *messages.GlideMessage) error {
+	if !msg.GetAction().IsInternal() && msg.GetAction() != messages.ActionHeartbeat {
+		d.devices.touch(cInfo.ID)
+	}
 	return d.def.Handle(cInfo, msg)
 }
 
@@ -94,32 +458,28 @@ func (d *MessageHandlerImpl) SetSubscription(s subscription.Interface) {
 	d.def.SetSubscription(s)
 }
 
-func (d *MessageHandlerImpl) dispatchGroupMessage(gid int64, msg *messages.ChatMessage) error {
-	return d.def.GetGroupInterface().PublishMessage("", nil)
-}
-
 func (d *MessageHandlerImpl) enqueueMessage(id gate.ID, message *messages.GlideMessage) {
 	err := d.def.GetClientInterface().EnqueueMessage(id, message)
 	if err != nil {
-		logger.E("%v", err)
+		log.E("%v", err)
 	}
 }
 func (d *MessageHandlerImpl) unmarshalData(c *gate.Info, msg *messages.GlideMessage, to interface{}) bool {
 	err := msg.Data.Deserialize(to)
 	if err != nil {
-		logger.E("sender chat senderMsg %v", err)
+		log.E("sender chat senderMsg %v", err)
 		return false
 	}
 	return true
 }
 
 func dispatch2AllDevice(h *MessageInterfaceImpl, uid string, m *messages.GlideMessage) bool {
-	devices := []string{"", "1", "2", "3"}
-	for _, device := range devices {
+	m = messages.Serialize(m)
+	for _, device := range knownDevices {
 		id := gate.NewID("", uid, device)
 		err := h.GetClientInterface().EnqueueMessage(id, m)
 		if err != nil && !gate.IsClientNotExist(err) {
-			logger.E("dispatch message error %v", err)
+			log.E("dispatch message error %v", err)
 		}
 	}
 	return true
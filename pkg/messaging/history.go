@@ -0,0 +1,94 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/degrade"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"strings"
+)
+
+const maxMessageRange = 100
+
+const (
+	errHistoryNotSupported = "message history is not supported"
+	errInvalidRange        = "invalid message range"
+	errNotParticipant      = "not a participant of the conversation"
+	errHistoryUnavailable  = "message history is unavailable temporarily"
+)
+
+// handleApiMessageRange responds messages of conversation in the sequence range, at most 100 messages a time, only
+// messages of the thread are responded if the thread is set.
+func (d *MessageHandlerImpl) handleApiMessageRange(c *gate.Info, m *messages.GlideMessage) error {
+	r := new(messages.MessageRange)
+	if !d.unmarshalData(c, m, r) {
+		return nil
+	}
+	ms, err := d.getMessageRange(c.ID.UID(), r)
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	// the history backfill is sent after messages queued.
+	reply := messages.NewReply(m, messages.ActionApiSuccess, ms)
+	reply.Priority = messages.PriorityLow
+	d.enqueueMessage(c.ID, reply)
+	return nil
+}
+
+func (d *MessageHandlerImpl) getMessageRange(uid string, r *messages.MessageRange) ([]*messages.ChatMessage, error) {
+	if d.degradation.Disabled(degrade.FeatureHistory) {
+		return nil, errors.New(errHistoryUnavailable)
+	}
+	hs, ok := store.As[store.MessageHistoryStore](d.store)
+	if !ok {
+		return nil, errors.New(errHistoryNotSupported)
+	}
+	// the end of range is optional for threads.
+	if r.Start <= 0 || (r.End < r.Start && (r.Thread == 0 || r.End != 0)) {
+		return nil, errors.New(errInvalidRange)
+	}
+	if r.End-r.Start >= maxMessageRange {
+		r.End = r.Start + maxMessageRange - 1
+	}
+	id := conversation.ID(r.Conversation)
+	switch id.Type() {
+	case conversation.TypeP2P:
+		participants := strings.Split(id.Target(), "_")
+		if len(participants) != 2 || (participants[0] != uid && participants[1] != uid) {
+			return nil, errors.New(errNotParticipant)
+		}
+	case conversation.TypeChannel:
+		if err := d.checkChannelMember(uid, id.Target()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, conversation.ErrInvalidID
+	}
+	if r.Thread != 0 {
+		return d.getThread(id, r)
+	}
+	return hs.GetBySeqRange(id, r.Start, r.End)
+}
+
+// checkChannelMember returns error if uid is not a subscriber of the channel, the history of channels is not available
+// if subscribers of channels are unknown.
+func (d *MessageHandlerImpl) checkChannelMember(uid string, channel string) error {
+	mi, ok := d.def.GetGroupInterface().(subscription.MemberInspector)
+	if !ok {
+		return errors.New(errHistoryNotSupported)
+	}
+	subscribers, err := mi.Subscribers(subscription.ChanID(channel))
+	if err != nil {
+		return err
+	}
+	for _, s := range subscribers {
+		if string(s) == uid {
+			return nil
+		}
+	}
+	return errors.New(errNotParticipant)
+}
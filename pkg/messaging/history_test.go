@@ -0,0 +1,39 @@
+package messaging
+
+import (
+	"testing"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store/storetest"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageHandlerImpl_getMessageRange(t *testing.T) {
+	g := newMockGateway()
+	s := &channelHistoryStore{MessageStore: storetest.NewMessageStore()}
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	for seq := int64(1); seq <= 3; seq++ {
+		_ = s.StoreChannelMessage("g", &messages.ChatMessage{Mid: seq, Seq: seq, From: "1", To: "g"})
+	}
+	channel := string(conversation.NewChannel("g").ID)
+
+	// the history of channels is not available if subscribers are unknown.
+	_, err = handler.getMessageRange("1", &messages.MessageRange{Conversation: channel, Start: 1, End: 3})
+	assert.EqualError(t, err, errHistoryNotSupported)
+
+	handler.SetSubscription(&mockMembers{subscribers: []subscription.SubscriberID{"1", "2"}})
+	ms, err := handler.getMessageRange("2", &messages.MessageRange{Conversation: channel, Start: 1, End: 3})
+	assert.NoError(t, err)
+	assert.Len(t, ms, 3)
+
+	_, err = handler.getMessageRange("9", &messages.MessageRange{Conversation: channel, Start: 1, End: 3})
+	assert.EqualError(t, err, errNotParticipant)
+
+	p2p := string(conversation.NewP2P("1", "2").ID)
+	_, err = handler.getMessageRange("9", &messages.MessageRange{Conversation: p2p, Start: 1, End: 3})
+	assert.EqualError(t, err, errNotParticipant)
+}
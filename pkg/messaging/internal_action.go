@@ -1,11 +1,10 @@
 package messaging
 
 import (
-	"github.com/glide-im/glide/config"
 	"github.com/glide-im/glide/internal/world_channel"
 	"github.com/glide-im/glide/pkg/gate"
-	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/webhook"
 	"time"
 )
 
@@ -13,19 +12,34 @@ func (d *MessageHandlerImpl) handleInternalOffline(c *gate.Info, m *messages.Gli
 	go world_channel.OnUserOffline(c.ID)
 
 	d.userState.onUserOffline(c.ID)
+	d.devices.offline(c.ID)
+	d.locales.offline(c.ID)
+	d.endCallOf(c.ID)
 
+	// the temp id goes offline when the client authenticated, only the authenticated client disconnection is emitted.
+	if !c.ID.IsTemp() {
+		webhook.Emit(webhook.EventClientDisconnected, clientEventData(c))
+	}
 	return nil
 }
 
 func (d *MessageHandlerImpl) handleInternalOnline(c *gate.Info, m *messages.GlideMessage) error {
 
 	d.userState.onUserOnline(c.ID)
+	d.devices.online(c.ID)
+	d.locales.online(c)
+
+	if c.ID.IsTemp() {
+		webhook.Emit(webhook.EventClientConnected, clientEventData(c))
+	} else {
+		webhook.Emit(webhook.EventClientAuthenticated, clientEventData(c))
+	}
 
 	go func() {
 		defer func() {
 			err, ok := recover().(error)
 			if err != nil && ok {
-				logger.ErrE("push offline message error", err)
+				log.ErrE("push offline message error", err)
 			}
 		}()
 		go func() {
@@ -33,9 +47,18 @@ func (d *MessageHandlerImpl) handleInternalOnline(c *gate.Info, m *messages.Glid
 			world_channel.OnUserOnline(c.ID)
 		}()
 
-		if config.Common.StoreOfflineMessage {
+		if StoreOfflineMessage {
 			// message_handler.PushOfflineMessage(h, cliInfo.ID.UID())
 		}
 	}()
 	return nil
 }
+
+func clientEventData(c *gate.Info) *webhook.ClientEventData {
+	return &webhook.ClientEventData{
+		ID:      string(c.ID),
+		Uid:     c.ID.UID(),
+		Gateway: c.Gateway,
+		Addr:    c.CliAddr,
+	}
+}
@@ -0,0 +1,251 @@
+package messaging
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/glide-im/glide/pkg/subscription/subscription_impl"
+)
+
+const (
+	errJoinNotSupported    = "join request is not supported"
+	errJoinInvalid         = "channel and uid of the join request are required"
+	errAlreadyMember       = "already a member of the channel"
+	errNotChannelAdmin     = "not an admin of the channel"
+	errJoinRequestNotExist = "join request does not exist"
+	errJoinRequestExpired  = "join request is expired"
+)
+
+const (
+	defaultJoinRequestTTL = time.Hour * 24 * 7
+	// joinRequestSweepInterval the min interval stale join requests are removed from the store.
+	joinRequestSweepInterval = time.Hour
+)
+
+// joinRequests keeps pending join requests in the store, requests older than ttl are expired and removed lazily.
+type joinRequests struct {
+	store store.JoinRequestStore
+	ttl   time.Duration
+	// sweptAt the unix seconds stale requests are removed last time.
+	sweptAt int64
+}
+
+func newJoinRequests(ttl time.Duration) *joinRequests {
+	if ttl <= 0 {
+		ttl = defaultJoinRequestTTL
+	}
+	return &joinRequests{ttl: ttl}
+}
+
+func (j *joinRequests) expired(r *messages.JoinRequest) bool {
+	return r.RequestAt < time.Now().Add(-j.ttl).Unix()
+}
+
+// sweep removes stale requests, at most once per joinRequestSweepInterval.
+func (j *joinRequests) sweep() {
+	now := time.Now().Unix()
+	last := atomic.LoadInt64(&j.sweptAt)
+	if now-last < int64(joinRequestSweepInterval/time.Second) || !atomic.CompareAndSwapInt64(&j.sweptAt, last, now) {
+		return
+	}
+	n, err := j.store.RemoveJoinRequestsBefore(time.Now().Add(-j.ttl).Unix())
+	if err != nil {
+		log.E("remove stale join requests error: %v", err)
+		return
+	}
+	if n > 0 {
+		log.D("%d stale join requests removed", n)
+	}
+}
+
+// members returns the MemberInspector of the subscription, false if join requests are not supported.
+func (d *MessageHandlerImpl) members() (subscription.MemberInspector, bool) {
+	mi, ok := d.def.GetGroupInterface().(subscription.MemberInspector)
+	return mi, ok
+}
+
+// notifyChannelAdmins sends the message to online admins of the channel except the user.
+func (d *MessageHandlerImpl) notifyChannelAdmins(mi subscription.MemberInspector, ch subscription.ChanID, except string, m *messages.GlideMessage) {
+	subscribers, err := mi.Subscribers(ch)
+	if err != nil {
+		log.D("notify admins of channel %s error: %v", ch, err)
+		return
+	}
+	for _, sb := range subscribers {
+		if string(sb) == except {
+			continue
+		}
+		if admin, err := mi.IsAdmin(ch, sb); err == nil && admin {
+			d.dispatchDevices(string(sb), m)
+		}
+	}
+}
+
+func (d *MessageHandlerImpl) handleApiGroupJoin(c *gate.Info, m *messages.GlideMessage) error {
+	req := new(messages.JoinRequest)
+	if !d.unmarshalData(c, m, req) {
+		return nil
+	}
+	mi, ok := d.members()
+	if !ok {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errJoinNotSupported))
+		return nil
+	}
+	if req.Channel == "" {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errJoinInvalid))
+		return nil
+	}
+	ch := subscription.ChanID(req.Channel)
+	subscribers, err := mi.Subscribers(ch)
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	uid := c.ID.UID()
+	for _, sb := range subscribers {
+		if string(sb) == uid {
+			d.enqueueMessage(c.ID, messages.NewErrorReply(m, errAlreadyMember))
+			return nil
+		}
+	}
+
+	d.joinRequests.sweep()
+	r := &messages.JoinRequest{
+		Channel:   req.Channel,
+		Uid:       uid,
+		Reason:    req.Reason,
+		Status:    messages.JoinStatusPending,
+		RequestAt: time.Now().Unix(),
+	}
+	if err = d.joinRequests.store.AddJoinRequest(r); err != nil {
+		log.E("add join request of %s to channel %s error: %v", uid, ch, err)
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	d.notifyChannelAdmins(mi, ch, uid, messages.NewMessage(0, messages.ActionNotifyJoinRequest, r))
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, r))
+	return nil
+}
+
+func (d *MessageHandlerImpl) handleApiGroupJoinApprove(c *gate.Info, m *messages.GlideMessage) error {
+	return d.decideJoinRequest(c, m, messages.JoinStatusApproved)
+}
+
+func (d *MessageHandlerImpl) handleApiGroupJoinReject(c *gate.Info, m *messages.GlideMessage) error {
+	return d.decideJoinRequest(c, m, messages.JoinStatusRejected)
+}
+
+// decideJoinRequest finalizes the pending request by the admin of the channel, the requester subscribes the channel
+// with the permission to read and write if approved. The requester and other admins are notified the decision.
+func (d *MessageHandlerImpl) decideJoinRequest(c *gate.Info, m *messages.GlideMessage, status string) error {
+	req := new(messages.JoinRequest)
+	if !d.unmarshalData(c, m, req) {
+		return nil
+	}
+	mi, ok := d.members()
+	if !ok {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errJoinNotSupported))
+		return nil
+	}
+	if req.Channel == "" || req.Uid == "" {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errJoinInvalid))
+		return nil
+	}
+	ch := subscription.ChanID(req.Channel)
+	admin, err := mi.IsAdmin(ch, subscription.SubscriberID(c.ID.UID()))
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	if !admin {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errNotChannelAdmin))
+		return nil
+	}
+
+	d.joinRequests.sweep()
+	r, err := d.joinRequests.store.RemoveJoinRequest(req.Channel, req.Uid)
+	if err != nil {
+		log.E("remove join request of %s to channel %s error: %v", req.Uid, ch, err)
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	if r == nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errJoinRequestNotExist))
+		return nil
+	}
+	if d.joinRequests.expired(r) {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errJoinRequestExpired))
+		return nil
+	}
+
+	if status == messages.JoinStatusApproved {
+		sub, ok := d.def.GetGroupInterface().(subscription.Subscribe)
+		if !ok {
+			_ = d.joinRequests.store.AddJoinRequest(r)
+			d.enqueueMessage(c.ID, messages.NewErrorReply(m, errJoinNotSupported))
+			return nil
+		}
+		err = sub.UpdateSubscriber(ch, []subscription.Update{{
+			Flag: subscription.SubscriberSubscribe,
+			ID:   subscription.SubscriberID(r.Uid),
+			Extra: &subscription_impl.SubscriberOptions{
+				Perm:     subscription_impl.PermRead | subscription_impl.PermWrite,
+				Approved: true,
+			},
+		}})
+		if err != nil {
+			// keep the request pending to be approved again.
+			_ = d.joinRequests.store.AddJoinRequest(r)
+			d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+			return nil
+		}
+	}
+
+	r.Status = status
+	r.By = c.ID.UID()
+	notify := messages.NewMessage(0, messages.ActionNotifyJoinRequest, r)
+	d.dispatchDevices(r.Uid, notify)
+	d.notifyChannelAdmins(mi, ch, c.ID.UID(), notify)
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, r))
+	return nil
+}
+
+// handleApiGroupJoinList returns pending requests to the channel to the admin of the channel.
+func (d *MessageHandlerImpl) handleApiGroupJoinList(c *gate.Info, m *messages.GlideMessage) error {
+	req := new(messages.JoinRequest)
+	if !d.unmarshalData(c, m, req) {
+		return nil
+	}
+	mi, ok := d.members()
+	if !ok {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errJoinNotSupported))
+		return nil
+	}
+	admin, err := mi.IsAdmin(subscription.ChanID(req.Channel), subscription.SubscriberID(c.ID.UID()))
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	if !admin {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errNotChannelAdmin))
+		return nil
+	}
+	d.joinRequests.sweep()
+	rs, err := d.joinRequests.store.GetJoinRequests(req.Channel)
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	ret := make([]*messages.JoinRequest, 0, len(rs))
+	for _, r := range rs {
+		if !d.joinRequests.expired(r) {
+			ret = append(ret, r)
+		}
+	}
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, ret))
+	return nil
+}
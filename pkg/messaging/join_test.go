@@ -0,0 +1,104 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/glide-im/glide/pkg/subscription/subscription_impl"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockChannelSubscribe struct {
+	mockMembers
+	updates []subscription.Update
+}
+
+func (m *mockChannelSubscribe) SetGateInterface(gate gate.DefaultGateway) {}
+
+func (m *mockChannelSubscribe) UpdateSubscriber(id subscription.ChanID, updates []subscription.Update) error {
+	m.updates = append(m.updates, updates...)
+	for _, u := range updates {
+		m.subscribers = append(m.subscribers, u.ID)
+	}
+	return nil
+}
+
+func (m *mockChannelSubscribe) UpdateChannel(id subscription.ChanID, update subscription.ChannelUpdate) error {
+	return nil
+}
+
+func lastAction(g *mockGateway, uid string) messages.Action {
+	got := g.messagesOf(gate.NewID2(uid))
+	if len(got) == 0 {
+		return ""
+	}
+	return got[len(got)-1].GetAction()
+}
+
+func TestMessageHandlerImpl_JoinRequest(t *testing.T) {
+	g := newMockGateway()
+	js := store.NewMemJoinRequestStore()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}, JoinRequestStore: js})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	sub := &mockChannelSubscribe{mockMembers: mockMembers{
+		subscribers: []subscription.SubscriberID{"admin", "member"},
+		admins:      map[subscription.SubscriberID]bool{"admin": true},
+	}}
+	handler.SetSubscription(sub)
+	for _, uid := range []string{"admin", "member", "u1", "u2"} {
+		handler.userState.onUserOnline(gate.NewID2(uid))
+	}
+	api := func(uid string, action messages.Action, r *messages.JoinRequest) {
+		m := messages.NewMessage(1, action, r)
+		m.From = uid
+		assert.True(t, handler.def.hc.handle(handler.def, &gate.Info{ID: gate.NewID2(uid)}, m))
+	}
+
+	api("member", messages.ActionApiGroupJoin, &messages.JoinRequest{Channel: "g"})
+	assert.Equal(t, messages.ActionApiFailed, lastAction(g, "member"))
+
+	api("u1", messages.ActionApiGroupJoin, &messages.JoinRequest{Channel: "g", Reason: "hi"})
+	assert.Equal(t, messages.ActionApiSuccess, lastAction(g, "u1"))
+	assert.Equal(t, messages.ActionNotifyJoinRequest, lastAction(g, "admin"))
+	assert.NotEqual(t, messages.ActionNotifyJoinRequest, lastAction(g, "member"))
+
+	// only admins approve and list requests
+	api("member", messages.ActionApiGroupJoinApprove, &messages.JoinRequest{Channel: "g", Uid: "u1"})
+	assert.Equal(t, messages.ActionApiFailed, lastAction(g, "member"))
+	api("member", messages.ActionApiGroupJoinList, &messages.JoinRequest{Channel: "g"})
+	assert.Equal(t, messages.ActionApiFailed, lastAction(g, "member"))
+
+	api("admin", messages.ActionApiGroupJoinList, &messages.JoinRequest{Channel: "g"})
+	got := g.messagesOf(gate.NewID2("admin"))
+	list := got[len(got)-1].Data.GetData().([]*messages.JoinRequest)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "u1", list[0].Uid)
+	assert.Equal(t, "hi", list[0].Reason)
+
+	api("admin", messages.ActionApiGroupJoinApprove, &messages.JoinRequest{Channel: "g", Uid: "u1"})
+	assert.Equal(t, messages.ActionApiSuccess, lastAction(g, "admin"))
+	assert.Len(t, sub.updates, 1)
+	assert.Equal(t, subscription.SubscriberID("u1"), sub.updates[0].ID)
+	assert.True(t, sub.updates[0].Extra.(*subscription_impl.SubscriberOptions).Approved)
+	got = g.messagesOf(gate.NewID2("u1"))
+	decision := got[len(got)-1]
+	assert.Equal(t, messages.ActionNotifyJoinRequest, decision.GetAction())
+	assert.Equal(t, messages.JoinStatusApproved, decision.Data.GetData().(*messages.JoinRequest).Status)
+	assert.Equal(t, "admin", decision.Data.GetData().(*messages.JoinRequest).By)
+
+	// the request is finalized
+	api("admin", messages.ActionApiGroupJoinReject, &messages.JoinRequest{Channel: "g", Uid: "u1"})
+	assert.Equal(t, messages.ActionApiFailed, lastAction(g, "admin"))
+
+	// the stale request expires
+	assert.NoError(t, js.AddJoinRequest(&messages.JoinRequest{Channel: "g", Uid: "u2", RequestAt: time.Now().Add(-defaultJoinRequestTTL - time.Minute).Unix()}))
+	api("admin", messages.ActionApiGroupJoinReject, &messages.JoinRequest{Channel: "g", Uid: "u2"})
+	assert.Equal(t, messages.ActionApiFailed, lastAction(g, "admin"))
+	assert.NotEqual(t, messages.ActionNotifyJoinRequest, lastAction(g, "u2"))
+	assert.Len(t, sub.updates, 1)
+}
@@ -0,0 +1,47 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+const (
+	errUploadNotEnabled = "media upload is not enabled"
+	errInvalidMedia     = "invalid media"
+)
+
+// handleApiUploadToken issues the pre-signed url to upload the media, the client sends the media message with the url
+// after uploaded.
+func (d *MessageHandlerImpl) handleApiUploadToken(c *gate.Info, m *messages.GlideMessage) error {
+	req := new(messages.UploadRequest)
+	if !d.unmarshalData(c, m, req) {
+		return nil
+	}
+	if d.uploader == nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errUploadNotEnabled))
+		return nil
+	}
+	token, err := d.uploader.Issue(c.ID.UID(), req)
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, token))
+	return nil
+}
+
+// validateMedia rejects the media message without required metadata, the content of media message is not filtered as
+// text.
+func (d *MessageHandlerImpl) validateMedia(c *gate.Info, m *messages.GlideMessage, msg *messages.ChatMessage) bool {
+	content, err := msg.Media()
+	if err == nil {
+		err = content.Validate(msg.Type)
+	}
+	if err != nil {
+		log.D("invalid media message from %s: %v", msg.From, err)
+		rejected := messages.MessageRejected{CliMid: msg.CliMid, To: m.To, Reason: errInvalidMedia}
+		d.enqueueMessage(c.ID, messages.NewMessage(m.GetSeq(), messages.ActionNotifyRejected, &rejected))
+		return false
+	}
+	return true
+}
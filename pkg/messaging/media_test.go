@@ -0,0 +1,78 @@
+package messaging
+
+import (
+	"encoding/json"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/media"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type mockStorage struct{}
+
+func (m *mockStorage) PresignUpload(key string, mime string, size int64, expires time.Duration) (*media.PresignedRequest, error) {
+	return &media.PresignedRequest{Method: "PUT", URL: "https://upload/" + key}, nil
+}
+
+func (m *mockStorage) DownloadURL(key string) string {
+	return "https://cdn/" + key
+}
+
+func TestMessageHandlerImpl_ApiUploadToken(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	req := &messages.GlideMessage{
+		Seq:    1,
+		Action: string(messages.ActionApiUploadToken),
+		Data:   messages.NewData(&messages.UploadRequest{Type: messages.MessageTypeImage, Size: 10, Mime: "image/png"}),
+	}
+	assert.NoError(t, handler.handleApiUploadToken(&gate.Info{ID: gate.NewID2("1")}, req))
+	assert.Equal(t, messages.ActionApiFailed, g.messagesOf(gate.NewID2("1"))[0].GetAction())
+
+	handler.uploader = media.NewUploader(&mockStorage{}, &media.Options{})
+	assert.NoError(t, handler.handleApiUploadToken(&gate.Info{ID: gate.NewID2("1")}, req))
+	reply := g.messagesOf(gate.NewID2("1"))[1]
+	assert.Equal(t, messages.ActionApiSuccess, reply.GetAction())
+	assert.Equal(t, int64(1), reply.ReplyTo)
+
+	token := new(messages.UploadToken)
+	assert.NoError(t, reply.Data.Deserialize(token))
+	assert.Equal(t, "https://upload/"+token.Key, token.URL)
+	assert.Equal(t, "https://cdn/"+token.Key, token.DownloadURL)
+}
+
+func TestMessageHandlerImpl_MediaMessage(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	send := func(content *messages.MediaContent) {
+		b, _ := json.Marshal(content)
+		m := &messages.GlideMessage{
+			Action: string(messages.ActionChatMessage),
+			To:     "2",
+			Data:   messages.NewData(&messages.ChatMessage{Type: messages.MessageTypeImage, Content: string(b)}),
+		}
+		assert.NoError(t, handler.handleChatMessage(&gate.Info{ID: gate.NewID2("1")}, m))
+	}
+
+	send(&messages.MediaContent{URL: "https://cdn/a.png", Size: 10, Mime: "image/png"})
+	assert.Empty(t, g.messagesOf(gate.NewID2("2")))
+	assert.Equal(t, messages.ActionNotifyRejected, g.messagesOf(gate.NewID2("1"))[0].GetAction())
+
+	send(&messages.MediaContent{URL: "https://cdn/a.png", Size: 10, Mime: "image/png", Width: 10, Height: 10})
+	received := g.messagesOf(gate.NewID2("2"))
+	assert.Len(t, received, 1)
+
+	msg := new(messages.ChatMessage)
+	assert.NoError(t, received[0].Data.Deserialize(msg))
+	content, err := msg.Media()
+	assert.NoError(t, err)
+	assert.Equal(t, 10, content.Width)
+}
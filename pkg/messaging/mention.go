@@ -0,0 +1,119 @@
+package messaging
+
+import (
+	"strings"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/subscription"
+)
+
+// maxMentions the max count of users mentioned in a message, the rest are ignored.
+const maxMentions = 50
+
+// parseMentions returns uids mentioned by "@uid" in the text content, the trailing punctuation is trimmed.
+func parseMentions(content string) []string {
+	var ret []string
+	for _, word := range strings.Fields(content) {
+		if len(word) < 2 || word[0] != '@' {
+			continue
+		}
+		if uid := strings.TrimRight(word[1:], ",.!?:;，。！？：；"); uid != "" {
+			ret = append(ret, uid)
+		}
+	}
+	return ret
+}
+
+// resolveMentions sets mentions of the channel message to the subscribers mentioned, mentions are parsed from the text
+// content if it's empty, MentionAll is kept only if the sender is an admin of the channel. Returns the users to notify,
+// the sender is excluded.
+func (d *MessageHandlerImpl) resolveMentions(conv *conversation.Conversation, cm *messages.ChatMessage) []string {
+	mentions := cm.Mentions
+	if len(mentions) == 0 && cm.Type == messages.MessageTypeText {
+		mentions = parseMentions(cm.Content)
+	}
+	cm.Mentions = nil
+	if len(mentions) == 0 {
+		return nil
+	}
+	mi, ok := d.def.GetGroupInterface().(subscription.MemberInspector)
+	if !ok {
+		return nil
+	}
+	ch := subscription.ChanID(conv.ID.Target())
+	subscribers, err := mi.Subscribers(ch)
+	if err != nil {
+		log.D("resolve mentions of channel %s error: %v", ch, err)
+		return nil
+	}
+	members := make(map[string]bool, len(subscribers))
+	for _, sb := range subscribers {
+		members[string(sb)] = true
+	}
+
+	all := false
+	seen := map[string]bool{}
+	for _, uid := range mentions {
+		if len(cm.Mentions) >= maxMentions {
+			break
+		}
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		if uid == messages.MentionAll {
+			admin, err := mi.IsAdmin(ch, subscription.SubscriberID(cm.From))
+			if err != nil || !admin {
+				log.D("%s is not allowed to mention all in channel %s", cm.From, ch)
+				continue
+			}
+			all = true
+		} else if !members[uid] {
+			continue
+		}
+		cm.Mentions = append(cm.Mentions, uid)
+	}
+
+	var targets []string
+	if all {
+		for uid := range members {
+			if uid != cm.From {
+				targets = append(targets, uid)
+			}
+		}
+		return targets
+	}
+	for _, uid := range cm.Mentions {
+		if uid != cm.From {
+			targets = append(targets, uid)
+		}
+	}
+	return targets
+}
+
+// notifyMentions notifies online users mentioned with messages.ActionNotifyMention, and pushes to offline users even
+// if they muted the channel.
+func (d *MessageHandlerImpl) notifyMentions(conv *conversation.Conversation, cm *messages.ChatMessage, targets []string) {
+	if len(targets) == 0 {
+		return
+	}
+	all := false
+	for _, uid := range cm.Mentions {
+		if uid == messages.MentionAll {
+			all = true
+		}
+	}
+	notify := messages.NewMessage(0, messages.ActionNotifyMention, &messages.MentionNotify{
+		Channel: conv.ID.Target(),
+		All:     all,
+		Message: cm,
+	})
+	for _, uid := range targets {
+		if d.userState.IsOnline(uid) {
+			d.dispatchDevices(uid, notify)
+		} else if d.push != nil {
+			d.push.NotifyMention(uid, conv.ID, cm)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package messaging
+
+import (
+	"testing"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockMembers struct {
+	subscribers []subscription.SubscriberID
+	admins      map[subscription.SubscriberID]bool
+	published   []subscription.Message
+}
+
+func (m *mockMembers) PublishMessage(id subscription.ChanID, message subscription.Message) error {
+	m.published = append(m.published, message)
+	return nil
+}
+
+func (m *mockMembers) Subscribers(ch subscription.ChanID) ([]subscription.SubscriberID, error) {
+	return m.subscribers, nil
+}
+
+func (m *mockMembers) IsAdmin(ch subscription.ChanID, id subscription.SubscriberID) (bool, error) {
+	return m.admins[id], nil
+}
+
+func TestParseMentions(t *testing.T) {
+	assert.Equal(t, []string{"2", "all"}, parseMentions("hi @2 and @all, @ mail@x"))
+}
+
+func TestMessageHandlerImpl_Mentions(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	sub := &mockMembers{subscribers: []subscription.SubscriberID{"1", "2", "3"}, admins: map[subscription.SubscriberID]bool{"1": true}}
+	handler.SetSubscription(sub)
+	for _, uid := range []string{"1", "2", "3"} {
+		handler.userState.onUserOnline(gate.NewID2(uid))
+	}
+
+	send := func(from string, cm *messages.ChatMessage) *messages.ChatMessage {
+		m := &messages.GlideMessage{Action: string(messages.ActionGroupMessage), From: from, To: "g", Data: messages.NewData(cm)}
+		assert.NoError(t, handler.handleGroupMsg(&gate.Info{ID: gate.NewID2(from)}, m))
+		published := sub.published[len(sub.published)-1].(interface {
+			GetChatMessage() (*messages.ChatMessage, error)
+		})
+		ret, err := published.GetChatMessage()
+		assert.NoError(t, err)
+		return ret
+	}
+	mentioned := func(uid string) int {
+		n := 0
+		for _, m := range g.messagesOf(gate.NewID2(uid)) {
+			if m.GetAction() == messages.ActionNotifyMention {
+				n++
+			}
+		}
+		return n
+	}
+
+	// non subscribers are not mentioned, mentions are parsed from text
+	cm := send("2", &messages.ChatMessage{Type: messages.MessageTypeText, Content: "@3 @4 hi"})
+	assert.Equal(t, []string{"3"}, cm.Mentions)
+	assert.Equal(t, 1, mentioned("3"))
+	assert.Equal(t, 0, mentioned("1"))
+
+	// only admins mention all
+	cm = send("2", &messages.ChatMessage{Mentions: []string{messages.MentionAll}})
+	assert.Empty(t, cm.Mentions)
+	assert.Equal(t, 0, mentioned("1"))
+
+	cm = send("1", &messages.ChatMessage{Mentions: []string{messages.MentionAll}})
+	assert.Equal(t, []string{messages.MentionAll}, cm.Mentions)
+	assert.Equal(t, 0, mentioned("1"))
+	assert.Equal(t, 1, mentioned("2"))
+	assert.Equal(t, 2, mentioned("3"))
+}
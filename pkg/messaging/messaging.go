@@ -5,10 +5,17 @@ import (
 	"github.com/glide-im/glide/pkg/gate"
 	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metering"
+	"github.com/glide-im/glide/pkg/metrics"
+	"github.com/glide-im/glide/pkg/ordered"
 	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/glide-im/glide/pkg/tracing"
 	"github.com/panjf2000/ants/v2"
+	"time"
 )
 
+var log = logger.Named("messaging")
+
 // MessageHandler is the interface for message offlineMessageHandler
 type MessageHandler interface {
 	// Handle handles the message, returns true if the message is handled,
@@ -51,7 +58,7 @@ func (m *MessageValidationHandler) Handle(h *MessageInterfaceImpl, cliInfo *gate
 		err, reply := v(message)
 		if err != nil {
 			if reply == nil {
-				reply = messages.NewMessage(message.GetSeq(), messages.ActionNotifyError, err.Error())
+				reply = messages.NewErrorMessage(message.GetSeq(), messages.ActionNotifyError, err.Error())
 			}
 			_ = h.GetClientInterface().EnqueueMessage(cliInfo.ID, reply)
 			return true
@@ -119,6 +126,9 @@ func (rh *ActionWithReplyHandler) Handle(h *MessageInterfaceImpl, cInfo *gate.In
 		if err != nil {
 			h.OnHandleMessageError(cInfo, msg, err)
 		}
+		if r != nil && r.ReplyTo == 0 {
+			r.ReplyTo = msg.GetSeq()
+		}
 		_ = h.GetClientInterface().EnqueueMessage(cInfo.ID, r)
 		return true
 	}
@@ -128,21 +138,32 @@ func (rh *ActionWithReplyHandler) Handle(h *MessageInterfaceImpl, cInfo *gate.In
 type Options struct {
 	NotifyServerError     bool
 	MaxMessageConcurrency int
+	// Workers the count of shared workers handle messages, messages of a conversation are handled in order. 0 to
+	// handle messages in goroutines of pool with MaxMessageConcurrency capacity, a goroutine per conversation.
+	Workers int
+	// WorkerQueueSize the capacity of task queue of each worker, default 1024.
+	WorkerQueueSize int
+	// ConversationQueueSize the max count of messages of a conversation waiting to be handled when Workers is 0,
+	// default 1024.
+	ConversationQueueSize int
 }
 
 func onMessageHandlerPanic(i interface{}) {
-	logger.E("MessageInterfaceImpl panic: %v", i)
+	log.E("MessageInterfaceImpl panic: %v", i)
 }
 
 // MessageInterfaceImpl default implementation of the messaging interface.
 type MessageInterfaceImpl struct {
 
-	// execPool 100 capacity goroutine pool, 假设每个消息处理需要10ms, 一个协程则每秒能处理100条消息
-	execPool *ants.Pool
+	// execPool runs message handling, the orderedExecutor or the workerPool keeps order of messages of a conversation.
+	execPool executor
 
 	// hc message offlineMessageHandler chain
 	hc *handlerChain
 
+	// actions the handlers of application specific actions, the first handler of hc.
+	actions *ActionRegistry
+
 	subscription subscription.Interface
 	gate         gate.Gateway
 
@@ -155,10 +176,15 @@ func NewDefaultImpl(options *Options) (*MessageInterfaceImpl, error) {
 	ret := MessageInterfaceImpl{
 		notifyOnSrvErr: options.NotifyServerError,
 		hc:             &handlerChain{},
+		actions:        newActionRegistry(),
 	}
+	ret.hc.add(ret.actions)
 
-	var err error
-	ret.execPool, err = ants.NewPool(
+	if options.Workers > 0 {
+		ret.execPool = newWorkerPool(options.Workers, options.WorkerQueueSize)
+		return &ret, nil
+	}
+	pool, err := ants.NewPool(
 		options.MaxMessageConcurrency,
 		ants.WithNonblocking(true),
 		ants.WithPanicHandler(onMessageHandlerPanic),
@@ -167,6 +193,10 @@ func NewDefaultImpl(options *Options) (*MessageInterfaceImpl, error) {
 	if err != nil {
 		return nil, err
 	}
+	ret.execPool = &orderedExecutor{e: ordered.NewExecutor(pool.Submit, &ordered.Options{
+		MaxPending:   options.ConversationQueueSize,
+		PanicHandler: onMessageHandlerPanic,
+	})}
 	return &ret, nil
 }
 
@@ -175,15 +205,32 @@ func (d *MessageInterfaceImpl) Handle(cInfo *gate.Info, msg *messages.GlideMessa
 	if !msg.GetAction().IsInternal() {
 		msg.From = cInfo.ID.UID()
 	}
-	logger.D("handle message: %s", msg)
-	err := d.execPool.Submit(func() {
+	log.D("handle message: %s", msg)
+	err := d.execPool.submit(orderKey(cInfo, msg), func() {
+		ctx, span := tracing.Start(msg, "messaging.handle")
+		defer span.End()
+		tracing.Inject(ctx, msg)
+
+		start := time.Now()
 		handled := d.hc.handle(d, cInfo, msg)
+		action := msg.GetAction().Label()
+		if !handled {
+			action = "unknown"
+		}
+		metrics.MessagesIn.WithLabelValues(action).Inc()
+		switch msg.GetAction() {
+		case messages.ActionChatMessage, messages.ActionGroupMessage, messages.ActionClientCustom:
+			if handled {
+				metering.Messages(cInfo.ID.UID(), 1)
+			}
+		}
+		metrics.HandleLatency.WithLabelValues(action).Observe(time.Since(start).Seconds())
 		if !handled {
 			if !msg.GetAction().IsInternal() {
 				r := messages.NewMessage(msg.GetSeq(), messages.ActionNotifyUnknownAction, msg.GetAction())
 				_ = d.gate.EnqueueMessage(cInfo.ID, r)
 			}
-			logger.W("action is not handled: %s", msg.GetAction())
+			log.W("action is not handled: %s", msg.GetAction())
 		}
 	})
 	if err != nil {
@@ -197,6 +244,11 @@ func (d *MessageInterfaceImpl) AddHandler(i MessageHandler) {
 	d.hc.add(i)
 }
 
+// Actions returns the registry of application specific action handlers, see RegisterHandler.
+func (d *MessageInterfaceImpl) Actions() *ActionRegistry {
+	return d.actions
+}
+
 func (d *MessageInterfaceImpl) SetGate(g gate.Gateway) {
 	d.gate = g
 }
@@ -219,7 +271,7 @@ func (d *MessageInterfaceImpl) GetGroupInterface() subscription.Interface {
 
 func (d *MessageInterfaceImpl) OnHandleMessageError(cInfo *gate.Info, msg *messages.GlideMessage, err error) {
 	if d.notifyOnSrvErr {
-		_ = d.gate.EnqueueMessage(cInfo.ID, messages.NewMessage(-1, messages.ActionNotifyError, err.Error()))
+		_ = d.gate.EnqueueMessage(cInfo.ID, messages.NewErrorMessage(-1, messages.ActionNotifyError, err.Error()))
 	}
 }
 
@@ -0,0 +1,58 @@
+// Package messagingtest wires the messaging handler with the fake gateway and store, for applications embedding glide
+// to unit test their handlers and interceptors end to end without real sockets and database.
+//
+//	h, _ := messagingtest.New(nil)
+//	alice, bob := h.Gateway.Connect("alice", ""), h.Gateway.Connect("bob", "")
+//	_, _ = h.Send(alice, messages.ActionChatMessage, "bob", &messages.ChatMessage{Content: "hi"})
+//	m, err := bob.Wait(messages.ActionChatMessage, time.Second)
+package messagingtest
+
+import (
+	"sync/atomic"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/gate/gatetest"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/messaging"
+	"github.com/glide-im/glide/pkg/store/storetest"
+)
+
+// Harness the messaging handler connected to the fake gateway and store.
+type Harness struct {
+	Handler *messaging.MessageHandlerImpl
+	Gateway *gatetest.Gateway
+	Store   *storetest.MessageStore
+
+	seq int64
+}
+
+// New creates the Harness, the MessageStore of opts is replaced by the fake store, nil opts to use the default.
+func New(opts *messaging.MessageHandlerOptions) (*Harness, error) {
+	o := messaging.MessageHandlerOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	h := &Harness{
+		Gateway: gatetest.NewGateway(),
+		Store:   storetest.NewMessageStore(),
+	}
+	o.MessageStore = h.Store
+	handler, err := messaging.NewHandlerWithOptions(h.Gateway, &o)
+	if err != nil {
+		return nil, err
+	}
+	handler.SetGate(h.Gateway)
+	h.Handler = handler
+	h.Gateway.SetMessageHandler(func(cliInfo *gate.Info, message *messages.GlideMessage) {
+		_ = handler.Handle(cliInfo, message)
+	})
+	return h, nil
+}
+
+// Send simulates the client sends the message of action to the target, the message is passed through the gateway
+// middlewares to the handler, returns false if it's dropped before the handler.
+func (h *Harness) Send(c *gatetest.Client, action messages.Action, to string, data interface{}) (bool, error) {
+	m := messages.NewMessage(atomic.AddInt64(&h.seq, 1), action, data)
+	m.To = to
+	return h.Gateway.Receive(c.GetInfo().ID, m)
+}
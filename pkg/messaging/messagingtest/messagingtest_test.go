@@ -0,0 +1,45 @@
+package messagingtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHarness_Send(t *testing.T) {
+	h, err := New(nil)
+	assert.NoError(t, err)
+	alice, bob := h.Gateway.Connect("alice", ""), h.Gateway.Connect("bob", "")
+
+	handled, err := h.Send(alice, messages.ActionChatMessage, "bob", &messages.ChatMessage{CliMid: "1", Content: "hi"})
+	assert.NoError(t, err)
+	assert.True(t, handled)
+
+	m, err := bob.Wait(messages.ActionChatMessage, time.Second)
+	assert.NoError(t, err)
+	chat := &messages.ChatMessage{}
+	assert.NoError(t, m.Data.Deserialize(chat))
+	assert.Equal(t, "hi", chat.Content)
+	assert.Equal(t, "alice", chat.From)
+
+	_, err = alice.Wait(messages.ActionAckMessage, time.Second)
+	assert.NoError(t, err)
+	assert.Len(t, h.Store.Messages(), 1)
+}
+
+func TestHarness_Middleware(t *testing.T) {
+	h, err := New(nil)
+	assert.NoError(t, err)
+	alice := h.Gateway.Connect("alice", "")
+	h.Gateway.Use(func(c gate.Client, m *messages.GlideMessage) (bool, error) {
+		return m.To == "blocked", nil
+	})
+
+	handled, err := h.Send(alice, messages.ActionChatMessage, "blocked", &messages.ChatMessage{CliMid: "1"})
+	assert.NoError(t, err)
+	assert.False(t, handled)
+	assert.Empty(t, h.Store.Messages())
+}
@@ -6,6 +6,7 @@ import (
 )
 
 func (d *MessageHandlerImpl) handleHeartbeat(cInfo *gate.Info, msg *messages.GlideMessage) error {
+	d.userState.onHeartbeat(cInfo.ID)
 	return nil
 }
 
@@ -27,5 +28,6 @@ func (d *MessageHandlerImpl) handleAckOffline(c *gate.Info, msg *messages.GlideM
 		return nil
 	}
 	AckOfflineMessage(c.ID.UID())
+	d.expiry.acked(c.ID.UID())
 	return nil
 }
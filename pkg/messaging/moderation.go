@@ -0,0 +1,108 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/audit"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/moderation"
+	"github.com/glide-im/glide/pkg/store"
+	"strconv"
+	"time"
+)
+
+func moderationRequest(conv *conversation.Conversation, msg *messages.ChatMessage) *moderation.Request {
+	return &moderation.Request{
+		Mid:          msg.Mid,
+		From:         msg.From,
+		To:           msg.To,
+		Conversation: string(conv.ID),
+		Type:         msg.Type,
+		Content:      msg.Content,
+	}
+}
+
+// moderateSync moderates the message before delivery, the content of message is replaced if the verdict is modify.
+// Returns false if the message is rejected, and the sender is notified with the reason.
+func (d *MessageHandlerImpl) moderateSync(c *gate.Info, m *messages.GlideMessage, conv *conversation.Conversation, msg *messages.ChatMessage) bool {
+	if d.moderator == nil || d.moderationMode != moderation.ModeSync {
+		return true
+	}
+	v, err := d.moderator.Moderate(moderationRequest(conv, msg))
+	if err != nil {
+		log.E("moderate message error: %v", err)
+		return true
+	}
+	if !v.Allowed() {
+		auditModeration(conv, msg, "reject", v.Reason)
+		rejected := messages.MessageRejected{CliMid: msg.CliMid, To: msg.To, Reason: v.Reason}
+		d.enqueueMessage(c.ID, messages.NewMessage(m.GetSeq(), messages.ActionNotifyRejected, &rejected))
+		return false
+	}
+	if v.Action == moderation.ActionModify {
+		auditModeration(conv, msg, "modify", v.Reason)
+		msg.Content = v.Content
+	}
+	return true
+}
+
+// moderateAsync moderates the message after delivery, the rejected message is recalled.
+func (d *MessageHandlerImpl) moderateAsync(conv *conversation.Conversation, msg *messages.ChatMessage) {
+	if d.moderator == nil || d.moderationMode != moderation.ModeAsync {
+		return
+	}
+	cm := *msg
+	go func() {
+		v, err := d.moderator.Moderate(moderationRequest(conv, &cm))
+		if err != nil {
+			log.E("moderate message error: %v", err)
+			return
+		}
+		if v.Allowed() {
+			return
+		}
+		log.I("message %d from %s flagged by moderation: %s", cm.Mid, cm.From, v.Reason)
+		if cm.Mid == 0 {
+			return
+		}
+		auditModeration(conv, &cm, "recall", v.Reason)
+		d.recallFlagged(conv, &cm)
+	}()
+}
+
+// auditModeration records the action taken to the message by moderation.
+func auditModeration(conv *conversation.Conversation, msg *messages.ChatMessage, action string, reason string) {
+	audit.Record(audit.EventModeration, msg.From, string(conv.ID), map[string]string{
+		"action": action,
+		"mid":    strconv.FormatInt(msg.Mid, 10),
+		"reason": reason,
+	})
+}
+
+// recallFlagged recalls the message flagged by moderation, notify all participants and devices of the sender.
+func (d *MessageHandlerImpl) recallFlagged(conv *conversation.Conversation, msg *messages.ChatMessage) {
+	if rs, ok := store.As[store.MessageRecallStore](d.store); ok {
+		err := rs.MarkRecalled(conv.ID, msg.Mid)
+		if err != nil {
+			log.E("recall flagged message %d error: %v", msg.Mid, err)
+		}
+	}
+	recall := messages.RecallMessage{
+		Mid:      msg.Mid,
+		From:     msg.From,
+		To:       msg.To,
+		RecallAt: time.Now().Unix(),
+	}
+	action := messages.Action(messages.ActionMessageRecall)
+	if conv.Type == conversation.TypeChannel {
+		action = messages.ActionGroupRecall
+	}
+	notify := messages.NewMessage(0, action, &recall)
+	_, err := d.route(msg.From, conv, notify, true)
+	if err != nil {
+		log.E("notify recall of flagged message error: %v", err)
+	}
+	if conv.Type == conversation.TypeP2P {
+		d.dispatchAllDevice(msg.From, notify)
+	}
+}
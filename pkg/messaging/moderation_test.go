@@ -0,0 +1,68 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/moderation"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type mockModerator struct{}
+
+func (m *mockModerator) Moderate(r *moderation.Request) (*moderation.Verdict, error) {
+	switch r.Content {
+	case "bad":
+		return &moderation.Verdict{Action: moderation.ActionReject, Reason: "spam"}, nil
+	case "rude":
+		return &moderation.Verdict{Action: moderation.ActionModify, Content: "***"}, nil
+	}
+	return &moderation.Verdict{Action: moderation.ActionAllow}, nil
+}
+
+func sendChat(t *testing.T, h *MessageHandlerImpl, from string, to string, content string) {
+	m := &messages.GlideMessage{
+		Action: string(messages.ActionChatMessage),
+		To:     to,
+		Data:   messages.NewData(&messages.ChatMessage{CliMid: content, Content: content}),
+	}
+	assert.NoError(t, h.handleChatMessage(&gate.Info{ID: gate.NewID2(from)}, m))
+}
+
+func TestMessageHandlerImpl_ModerateSync(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}, Moderator: &mockModerator{}})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	sendChat(t, handler, "1", "2", "bad")
+	assert.Empty(t, g.messagesOf(gate.NewID2("2")))
+	rejected := g.messagesOf(gate.NewID2("1"))[0]
+	assert.Equal(t, messages.ActionNotifyRejected, rejected.GetAction())
+	assert.Equal(t, "spam", rejected.Data.GetData().(*messages.MessageRejected).Reason)
+
+	sendChat(t, handler, "1", "2", "rude")
+	received := g.messagesOf(gate.NewID2("2"))
+	assert.Equal(t, "***", received[0].Data.GetData().(*messages.ChatMessage).Content)
+}
+
+func TestMessageHandlerImpl_ModerateAsync(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{
+		MessageStore:   &countingStore{},
+		Moderator:      &mockModerator{},
+		ModerationMode: moderation.ModeAsync,
+	})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	sendChat(t, handler, "1", "2", "bad")
+	time.Sleep(time.Millisecond * 50)
+
+	received := g.messagesOf(gate.NewID2("2"))
+	assert.Len(t, received, 2)
+	assert.Equal(t, messages.ActionChatMessage, received[0].GetAction())
+	assert.Equal(t, messages.ActionMessageRecall, received[1].GetAction())
+	assert.Equal(t, int64(1), received[1].Data.GetData().(*messages.RecallMessage).Mid)
+}
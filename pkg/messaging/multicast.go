@@ -0,0 +1,72 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+// SendToUsers sends the message to all devices of users online, returns the count of clients the message enqueued to.
+// Duplicated uids are sent once. The message is encoded once per codec for all clients, gateways of users are looked
+// up in one batch by the gate.SessionLocator, and clients of each gateway are sent in one call if the gateway is a
+// gate.Multicaster, which is much more efficient than sending to users one by one. The message must not be modified
+// after sent.
+func (d *MessageHandlerImpl) SendToUsers(uids []string, m *messages.GlideMessage) (int, error) {
+	seen := make(map[string]bool, len(uids))
+	targets := make([]string, 0, len(uids))
+	for _, uid := range uids {
+		if uid == "" || seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		targets = append(targets, uid)
+	}
+	if len(targets) == 0 {
+		return 0, nil
+	}
+	m = messages.Serialize(m)
+
+	var groups map[string][]gate.ID
+	if d.sessions != nil {
+		var err error
+		groups, err = d.sessions.Locate(targets)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		ids := make([]gate.ID, 0, len(targets)*len(knownDevices))
+		for _, uid := range targets {
+			for _, device := range knownDevices {
+				ids = append(ids, gate.NewID("", uid, device))
+			}
+		}
+		groups = map[string][]gate.ID{"": ids}
+	}
+
+	n := 0
+	for gateway, ids := range groups {
+		sent, err := d.multicast(ids, m)
+		if err != nil {
+			log.E("multicast to %d clients of gateway %s error: %v", len(ids), gateway, err)
+		}
+		n += sent
+	}
+	return n, nil
+}
+
+// multicast enqueues the message to clients in one call if the gateway is a gate.Multicaster, or one by one.
+func (d *MessageHandlerImpl) multicast(ids []gate.ID, m *messages.GlideMessage) (int, error) {
+	g := d.def.GetClientInterface()
+	if mc, ok := g.(gate.Multicaster); ok {
+		return mc.EnqueueMessages(ids, m)
+	}
+	n := 0
+	for _, id := range ids {
+		err := g.EnqueueMessage(id, m)
+		if err == nil {
+			n++
+		} else if !gate.IsClientNotExist(err) {
+			log.E("dispatch message to %s error: %v", id, err)
+		}
+	}
+	return n, nil
+}
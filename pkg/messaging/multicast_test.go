@@ -0,0 +1,49 @@
+package messaging
+
+import (
+	"testing"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+type multicastGateway struct {
+	*mockGateway
+	calls int
+}
+
+func (m *multicastGateway) EnqueueMessages(ids []gate.ID, msg *messages.GlideMessage) (int, error) {
+	m.calls++
+	for _, id := range ids {
+		_ = m.EnqueueMessage(id, msg)
+	}
+	return len(ids), nil
+}
+
+func TestMessageHandlerImpl_SendToUsers(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	m := messages.NewMessage(0, messages.ActionNotifyService, "hi")
+	n, err := handler.SendToUsers([]string{"1", "2", "1", ""}, m)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*len(knownDevices), n)
+	assert.Len(t, g.messagesOf(gate.NewID("", "1", "")), 1)
+
+	registry := gate.NewMemSessionRegistry()
+	_ = registry.Register(gate.NewID("g1", "1", "1"), "g1")
+	_ = registry.Register(gate.NewID("g1", "2", "1"), "g1")
+	_ = registry.Register(gate.NewID("g2", "3", "2"), "g2")
+	mg := &multicastGateway{mockGateway: newMockGateway()}
+	handler, err = NewHandlerWithOptions(mg, &MessageHandlerOptions{MessageStore: &countingStore{}, SessionLocator: registry})
+	assert.NoError(t, err)
+	handler.SetGate(mg)
+	n, err = handler.SendToUsers([]string{"1", "2", "3", "4"}, m)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	// one call for each gateway
+	assert.Equal(t, 2, mg.calls)
+	assert.Len(t, mg.messagesOf(gate.NewID("g2", "3", "2")), 1)
+}
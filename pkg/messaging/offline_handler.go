@@ -3,7 +3,6 @@ package messaging
 import (
 	"github.com/glide-im/glide/internal/pkg/db"
 	"github.com/glide-im/glide/pkg/gate"
-	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
 	"time"
 )
@@ -22,12 +21,12 @@ func offlineMessageHandler(_ *MessageHandlerImpl, _ *gate.Info, m *messages.Glid
 		c := messages.ChatMessage{}
 		err := m.Data.Deserialize(&c)
 		if err != nil {
-			logger.E("deserialize chat message error: %v", err)
+			log.E("deserialize chat message error: %v", err)
 			return
 		}
 		bytes, err := messages.JsonCodec.Encode(m)
 		if err != nil {
-			logger.E("deserialize chat message error: %v", err)
+			log.E("deserialize chat message error: %v", err)
 			return
 		}
 		storeOfflineMessage(m.To, string(bytes))
@@ -46,14 +45,14 @@ func PushOfflineMessage(h *MessageInterfaceImpl, id string) {
 	key := KeyRedisOfflineMsgPrefix + id
 	members, err := db.Redis.SMembers(key).Result()
 	if err != nil {
-		logger.ErrE("push offline msg error", err)
+		log.ErrE("push offline msg error", err)
 		return
 	}
 	for _, member := range members {
 		msg := messages.NewEmptyMessage()
 		err := messages.JsonCodec.Decode([]byte(member), msg)
 		if err != nil {
-			logger.ErrE("deserialize redis offline msg error", err)
+			log.ErrE("deserialize redis offline msg error", err)
 			continue
 		}
 		id2 := gate.NewID2(id)
@@ -67,7 +66,7 @@ func AckOfflineMessage(id string) {
 	key := KeyRedisOfflineMsgPrefix + id
 	result, err := db.Redis.Del(key).Result()
 	if err != nil {
-		logger.ErrE("remove offline message error", err)
+		log.ErrE("remove offline message error", err)
 	}
-	logger.I("user %s ack %d offline messages", id, result)
+	log.I("user %s ack %d offline messages", id, result)
 }
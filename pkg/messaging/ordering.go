@@ -0,0 +1,41 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/ordered"
+)
+
+// Ordering guarantee: messages of a conversation are handled one by one in the order received by the gateway, the
+// sequence is allocated, the message is stored and enqueued to the receivers before the next message of the
+// conversation is handled, and the gateway enqueues messages to a client in the order enqueued, so each receiver
+// receives messages of a conversation in the order sent, see gate.Impl.EnqueueMessage. Messages of different
+// conversations, and messages other than chat messages of a user, are handled in order of the sender uid.
+//
+// Messages forwarded to the receiver connected to other gateways keep the order as the rpc is called synchronously in
+// the handling of message.
+
+// orderKey returns the key of message handled in order, the conversation of chat messages, or the uid of sender.
+func orderKey(c *gate.Info, m *messages.GlideMessage) string {
+	if m.To != "" {
+		switch m.GetAction() {
+		case messages.ActionChatMessage, messages.ActionChatMessageResend, messages.ActionClientCustom,
+			messages.ActionMessageRecall, messages.ActionMessageEdit, messages.ActionMessageRead:
+			return string(conversation.NewP2P(c.ID.UID(), m.To).ID)
+		case messages.ActionGroupMessage, messages.ActionGroupRecall, messages.ActionGroupMessageEdit,
+			messages.ActionGroupMessageRead:
+			return string(conversation.NewChannel(m.To).ID)
+		}
+	}
+	return c.ID.UID()
+}
+
+// orderedExecutor runs each task in a goroutine of ants pool, tasks of the same key run serially in order submitted.
+type orderedExecutor struct {
+	e *ordered.Executor
+}
+
+func (o *orderedExecutor) submit(key string, task func()) error {
+	return o.e.Submit(key, task)
+}
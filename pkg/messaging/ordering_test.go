@@ -0,0 +1,59 @@
+package messaging
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderKey(t *testing.T) {
+	alice := &gate.Info{ID: gate.NewID2("1")}
+	bob := &gate.Info{ID: gate.NewID2("2")}
+	m := func(action messages.Action, to string) *messages.GlideMessage {
+		r := messages.NewMessage(1, action, nil)
+		r.To = to
+		return r
+	}
+
+	p2p := string(conversation.NewP2P("1", "2").ID)
+	assert.Equal(t, p2p, orderKey(alice, m(messages.ActionChatMessage, "2")))
+	assert.Equal(t, p2p, orderKey(bob, m(messages.ActionChatMessage, "1")))
+	assert.Equal(t, p2p, orderKey(bob, m(messages.ActionMessageRecall, "1")))
+	assert.Equal(t, string(conversation.NewChannel("9").ID), orderKey(alice, m(messages.ActionGroupMessage, "9")))
+	assert.Equal(t, "1", orderKey(alice, m(messages.ActionApiReadCursors, "")))
+	assert.Equal(t, "1", orderKey(alice, m(messages.ActionChatMessage, "")))
+}
+
+func TestMessageHandlerImpl_OrderedDelivery(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	const n = 200
+	alice := &gate.Info{ID: gate.NewID2("1")}
+	for i := 0; i < n; i++ {
+		m := messages.NewMessage(int64(i), messages.ActionChatMessage, &messages.ChatMessage{
+			CliMid:  strconv.Itoa(i),
+			Content: strconv.Itoa(i),
+		})
+		m.To = "2"
+		assert.NoError(t, handler.Handle(alice, m))
+	}
+
+	bob := gate.NewID2("2")
+	assert.Eventually(t, func() bool {
+		return len(g.messagesOf(bob)) == n
+	}, time.Second*5, time.Millisecond*10)
+	for i, m := range g.messagesOf(bob) {
+		chat := &messages.ChatMessage{}
+		assert.NoError(t, m.Data.Deserialize(chat))
+		assert.Equal(t, strconv.Itoa(i), chat.Content)
+		assert.Equal(t, int64(i+1), chat.Seq)
+	}
+}
@@ -0,0 +1,92 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/audit"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/glide-im/glide/pkg/webhook"
+)
+
+// PurgeResult the result of MessageHandlerImpl.PurgeUser.
+type PurgeResult struct {
+	Uid string `json:"uid"`
+	// Kicked the count of clients of the user kicked.
+	Kicked int `json:"kicked"`
+	// Channels the channels the user is removed from.
+	Channels []string `json:"channels"`
+}
+
+// AddUserPurger adds the store keeping data of users outside the message store, such as archive.Archiver, data of the
+// user is deleted from it by PurgeUser as well.
+func (d *MessageHandlerImpl) AddUserPurger(p store.UserPurgeStore) {
+	d.purgers = append(d.purgers, p)
+}
+
+// PurgeUser deletes all data of uid for the right to be forgotten: the clients of uid are kicked, the presence and
+// presence subscriptions are removed, uid is unsubscribed from all channels, and the message history, offline queue,
+// read cursors and dead letters of uid, and data in stores added by AddUserPurger are deleted if the stores implement
+// store.UserPurgeStore. The wrapped message store is purged by the outermost store implementing store.UserPurgeStore,
+// such as store.WriteBehindStore dropping messages not written and store.CacheStore invalidating the cache. The
+// webhook.EventUserPurged is emitted when all data is deleted.
+func (d *MessageHandlerImpl) PurgeUser(uid string) (*PurgeResult, error) {
+	if uid == "" {
+		return nil, errors.New("uid is required")
+	}
+	ret := &PurgeResult{Uid: uid, Channels: []string{}}
+
+	kickOut := messages.NewMessage(0, messages.ActionNotifyKickOut, &messages.KickOutNotify{})
+	for _, device := range knownDevices {
+		id := gate.NewID("", uid, device)
+		_ = d.def.GetClientInterface().EnqueueMessage(id, kickOut)
+		if d.def.GetClientInterface().ExitClient(id) == nil {
+			ret.Kicked++
+			audit.Record(audit.EventKick, "", string(id), map[string]string{"reason": "user data purged"})
+		}
+	}
+	d.userState.remove(uid)
+	d.expiry.acked(uid)
+
+	if remover, ok := d.def.GetGroupInterface().(subscription.SubscriberRemover); ok {
+		chs, err := remover.RemoveSubscriber(subscription.SubscriberID(uid))
+		for _, ch := range chs {
+			ret.Channels = append(ret.Channels, string(ch))
+		}
+		if err != nil {
+			return ret, err
+		}
+	}
+
+	stores := []interface{}{d.store, d.readCursors, d.deadLetters}
+	for _, p := range d.purgers {
+		stores = append(stores, p)
+	}
+	purged := map[interface{}]bool{}
+	for _, s := range stores {
+		if s == nil || purged[s] {
+			continue
+		}
+		// stores wrapped by s are purged through s.
+		for w := s; ; {
+			purged[w] = true
+			u, ok := w.(store.Unwrapper)
+			if !ok {
+				break
+			}
+			w = u.Unwrap()
+		}
+		ps, ok := store.As[store.UserPurgeStore](s)
+		if !ok {
+			continue
+		}
+		if err := ps.PurgeUser(uid); err != nil {
+			return ret, err
+		}
+	}
+
+	log.I("user %s purged, %d clients kicked, removed from %d channels", uid, ret.Kicked, len(ret.Channels))
+	webhook.Emit(webhook.EventUserPurged, &webhook.UserPurgedEventData{Uid: uid, Channels: ret.Channels})
+	return ret, nil
+}
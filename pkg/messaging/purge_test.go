@@ -0,0 +1,81 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type mockSubscriberRemover struct {
+	channels map[subscription.ChanID][]subscription.SubscriberID
+}
+
+func (m *mockSubscriberRemover) PublishMessage(id subscription.ChanID, message subscription.Message) error {
+	return nil
+}
+
+func (m *mockSubscriberRemover) RemoveSubscriber(id subscription.SubscriberID) ([]subscription.ChanID, error) {
+	var ret []subscription.ChanID
+	for ch, subscribers := range m.channels {
+		for i, s := range subscribers {
+			if s == id {
+				m.channels[ch] = append(subscribers[:i], subscribers[i+1:]...)
+				ret = append(ret, ch)
+				break
+			}
+		}
+	}
+	return ret, nil
+}
+
+type mockPurger struct {
+	purged []string
+}
+
+func (m *mockPurger) PurgeUser(uid string) error {
+	m.purged = append(m.purged, uid)
+	return nil
+}
+
+func TestMessageHandlerImpl_PurgeUser(t *testing.T) {
+	g := newMockGateway()
+	deadLetters := store.NewMemDeadLetterStore(0)
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}, DeadLetters: deadLetters})
+	assert.NoError(t, err)
+	purger := &mockPurger{}
+	handler.AddUserPurger(purger)
+	_ = deadLetters.StoreDeadLetter(&store.DeadLetter{ID: 1, Message: &messages.ChatMessage{From: "2", To: "1"}})
+	_ = deadLetters.StoreDeadLetter(&store.DeadLetter{ID: 2, Message: &messages.ChatMessage{From: "2", To: "3"}})
+	handler.SetGate(g)
+	sub := &mockSubscriberRemover{channels: map[subscription.ChanID][]subscription.SubscriberID{
+		"a": {"1", "2"},
+		"b": {"2"},
+	}}
+	handler.SetSubscription(sub)
+
+	_, err = handler.readCursors.UpdateReadCursor("1", conversation.NewChannel("a").ID, 1, 1)
+	assert.NoError(t, err)
+	handler.userState.Subscribe("1", []string{"2"})
+	handler.userState.Subscribe("3", []string{"1"})
+
+	result, err := handler.PurgeUser("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, result.Channels)
+	assert.Equal(t, []subscription.SubscriberID{"2"}, sub.channels["a"])
+	assert.Equal(t, len(knownDevices), result.Kicked)
+	assert.Equal(t, messages.ActionNotifyKickOut, g.messagesOf(gate.NewID("", "1", ""))[0].GetAction())
+
+	cursors, err := handler.readCursors.GetReadCursors("1")
+	assert.NoError(t, err)
+	assert.Empty(t, cursors)
+	assert.NotContains(t, handler.userState.subscribers["2"], "1")
+	assert.NotContains(t, handler.userState.mySubs, "3")
+	assert.Equal(t, []string{"1"}, purger.purged)
+	letters, _ := deadLetters.GetDeadLetters(0, 0)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, int64(2), letters[0].ID)
+}
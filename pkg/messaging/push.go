@@ -0,0 +1,110 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/push"
+	"sort"
+)
+
+const (
+	errPushNotEnabled   = "push notification is not enabled"
+	errInvalidDNDPeriod = "invalid do-not-disturb period"
+	minutesOfDay        = 24 * 60
+)
+
+// handleApiPushRegister registers the device of user to receive push notification when the user is offline.
+func (d *MessageHandlerImpl) handleApiPushRegister(c *gate.Info, m *messages.GlideMessage) error {
+	device := new(messages.PushDevice)
+	if !d.unmarshalData(c, m, device) {
+		return nil
+	}
+	if d.push == nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errPushNotEnabled))
+		return nil
+	}
+	err := d.push.Devices().AddDevice(c.ID.UID(), push.Device{Platform: device.Platform, Token: device.Token})
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, nil))
+	return nil
+}
+
+// handleApiPushSettings responds the mute and do-not-disturb settings of user.
+func (d *MessageHandlerImpl) handleApiPushSettings(c *gate.Info, m *messages.GlideMessage) error {
+	if d.push == nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errPushNotEnabled))
+		return nil
+	}
+	ret, err := d.getPushSettings(c.ID.UID())
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, ret))
+	return nil
+}
+
+func (d *MessageHandlerImpl) getPushSettings(uid string) (*messages.PushSettings, error) {
+	s, err := d.push.Settings().GetSettings(uid)
+	if err != nil {
+		return nil, err
+	}
+	ret := &messages.PushSettings{}
+	if s != nil {
+		ret.MuteAll = s.MuteAll
+		ret.DNDStart = s.DNDStart
+		ret.DNDEnd = s.DNDEnd
+		ret.TimezoneOffset = s.TimezoneOffset
+		for id, muted := range s.Muted {
+			if muted {
+				ret.Muted = append(ret.Muted, id)
+			}
+		}
+		sort.Strings(ret.Muted)
+	}
+	return ret, nil
+}
+
+// handleApiPushSettingsSet replaces the mute and do-not-disturb settings of user, muted conversations and messages in
+// the do-not-disturb period are still delivered to online devices, but not pushed nor counted to the badge. Muted
+// conversations are conversation.ID of P2P or channel conversations.
+func (d *MessageHandlerImpl) handleApiPushSettingsSet(c *gate.Info, m *messages.GlideMessage) error {
+	ps := new(messages.PushSettings)
+	if !d.unmarshalData(c, m, ps) {
+		return nil
+	}
+	if d.push == nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errPushNotEnabled))
+		return nil
+	}
+	if ps.DNDStart < 0 || ps.DNDStart >= minutesOfDay || ps.DNDEnd < 0 || ps.DNDEnd >= minutesOfDay {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errInvalidDNDPeriod))
+		return nil
+	}
+	for _, id := range ps.Muted {
+		if t := conversation.ID(id).Type(); t != conversation.TypeP2P && t != conversation.TypeChannel {
+			d.enqueueMessage(c.ID, messages.NewErrorReply(m, conversation.ErrInvalidID.Error()))
+			return nil
+		}
+	}
+	s := &push.Settings{
+		MuteAll:        ps.MuteAll,
+		Muted:          map[string]bool{},
+		DNDStart:       ps.DNDStart,
+		DNDEnd:         ps.DNDEnd,
+		TimezoneOffset: ps.TimezoneOffset,
+	}
+	for _, id := range ps.Muted {
+		s.Muted[id] = true
+	}
+	if err := d.push.Settings().SetSettings(c.ID.UID(), s); err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, nil))
+	return nil
+}
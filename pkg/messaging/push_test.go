@@ -0,0 +1,48 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/push"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMessageHandlerImpl_handleApiPushSettings(t *testing.T) {
+	bridge, err := push.NewBridge(&push.Options{Devices: push.NewMemDeviceStore(), Settings: push.NewMemSettingsStore()})
+	assert.NoError(t, err)
+	defer bridge.Close()
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}, PushBridge: bridge})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	c := &gate.Info{ID: gate.NewID2("1")}
+	set := func(s *messages.PushSettings) *messages.GlideMessage {
+		m := messages.NewMessage(1, messages.ActionApiPushSettingsSet, s)
+		assert.NoError(t, handler.handleApiPushSettingsSet(c, m))
+		replies := g.messagesOf(c.ID)
+		return replies[len(replies)-1]
+	}
+	channel, p2p := string(conversation.NewChannel("100").ID), string(conversation.NewP2P("1", "2").ID)
+	reply := set(&messages.PushSettings{Muted: []string{channel, p2p}, DNDStart: 22 * 60, DNDEnd: 8 * 60})
+	assert.Equal(t, messages.ActionApiSuccess, reply.GetAction())
+	reply = set(&messages.PushSettings{DNDStart: 24 * 60})
+	assert.Equal(t, messages.ActionApiFailed, reply.GetAction())
+	// muted conversations must be conversation ids.
+	reply = set(&messages.PushSettings{Muted: []string{"2_1"}})
+	assert.Equal(t, messages.ActionApiFailed, reply.GetAction())
+
+	assert.NoError(t, handler.handleApiPushSettings(c, messages.NewMessage(2, messages.ActionApiPushSettings, nil)))
+	replies := g.messagesOf(c.ID)
+	reply = replies[len(replies)-1]
+	assert.Equal(t, messages.ActionApiSuccess, reply.GetAction())
+	s := &messages.PushSettings{}
+	assert.NoError(t, reply.Data.Deserialize(s))
+	assert.Equal(t, []string{p2p, channel}, s.Muted)
+	assert.Equal(t, 22*60, s.DNDStart)
+
+	stored, _ := bridge.Settings().GetSettings("1")
+	assert.True(t, stored.Muted[channel])
+}
@@ -0,0 +1,53 @@
+package messaging
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageHandlerImpl_handleChatMessage_QoS(t *testing.T) {
+	s := &offlineStore{offline: map[int64]bool{}}
+	g := &offlineGateway{mockGateway: newMockGateway(), offline: map[string]bool{"3": true}}
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	sender := &gate.Info{ID: gate.NewID2("1")}
+	send := func(to string, qos messages.QoS) {
+		m := &messages.GlideMessage{
+			Action: string(messages.ActionChatMessage),
+			To:     to,
+			QoS:    qos,
+			Data:   messages.NewData(&messages.ChatMessage{CliMid: to + strconv.Itoa(int(qos)), Content: "hi"}),
+		}
+		assert.NoError(t, handler.handleChatMessage(sender, m))
+	}
+
+	// the at-most-once message is delivered to the online receiver without storing and acking.
+	send("2", messages.QoSAtMostOnce)
+	assert.Equal(t, int64(0), s.stored)
+	assert.Empty(t, g.messagesOf(sender.ID))
+	delivered := g.messagesOf(gate.NewID2("2"))
+	assert.NotEmpty(t, delivered)
+	assert.Equal(t, messages.QoSAtMostOnce, delivered[0].QoS)
+
+	// and dropped for the offline receiver.
+	send("3", messages.QoSAtMostOnce)
+	assert.Equal(t, int64(0), s.stored)
+	assert.Empty(t, s.offline)
+
+	// the online-only message is stored and acked, but not queued offline.
+	send("3", messages.QoSOnlineOnly)
+	assert.Equal(t, int64(1), s.stored)
+	assert.False(t, s.isOffline(1))
+	acks := g.messagesOf(sender.ID)
+	assert.Len(t, acks, 1)
+	assert.Equal(t, messages.ActionAckMessage, acks[0].GetAction())
+
+	send("3", messages.QoSAtLeastOnce)
+	assert.True(t, s.isOffline(s.stored))
+}
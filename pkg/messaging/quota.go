@@ -0,0 +1,284 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
+)
+
+// Names of quotas, the default quotas are set by SetRateLimit with the name.
+const (
+	// QuotaUserDailyMessages the max count of chat and channel messages a user sends per day, the day is in UTC.
+	QuotaUserDailyMessages = "user_daily_messages"
+	// QuotaChannelMinuteMessages the max count of messages sent to a channel per minute by all members.
+	QuotaChannelMinuteMessages = "channel_minute_messages"
+	// QuotaMessageLength the max characters of the content of a text message.
+	QuotaMessageLength = "message_length"
+)
+
+const (
+	defaultQuotaCacheTTL        = time.Minute
+	defaultQuotaRetryInterval   = time.Second * 10
+	defaultQuotaProviderTimeout = time.Second * 3
+	// maxQuotaEntries the count of cached quotas or counters of a window before expired ones are removed.
+	maxQuotaEntries = 100_000
+)
+
+// QuotaLimits the quotas of messages, zero is unlimited.
+type QuotaLimits struct {
+	UserDailyMessages     int64 `json:"user_daily_messages"`
+	ChannelMinuteMessages int64 `json:"channel_minute_messages"`
+	MessageLength         int64 `json:"message_length"`
+}
+
+// QuotaProvider provides quotas of the sender and the channel from the business service, such as quotas of the plan
+// the user subscribed. The channel is empty for P2P messages. It returns nil to apply the default quotas.
+type QuotaProvider interface {
+	QuotaLimits(uid string, channel string) (*QuotaLimits, error)
+}
+
+// QuotaProviderFunc adapts the function to QuotaProvider.
+type QuotaProviderFunc func(uid string, channel string) (*QuotaLimits, error)
+
+func (f QuotaProviderFunc) QuotaLimits(uid string, channel string) (*QuotaLimits, error) {
+	return f(uid, channel)
+}
+
+type httpQuotaProvider struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPQuotaProvider returns the QuotaProvider fetches quotas from the business service by
+// GET url?uid=&channel=, the response body is QuotaLimits in json, 404 to apply the default quotas. The timeout is 3s
+// if not positive.
+func NewHTTPQuotaProvider(url string, timeout time.Duration) QuotaProvider {
+	if timeout <= 0 {
+		timeout = defaultQuotaProviderTimeout
+	}
+	return &httpQuotaProvider{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (h *httpQuotaProvider) QuotaLimits(uid string, channel string) (*QuotaLimits, error) {
+	q := url.Values{}
+	q.Set("uid", uid)
+	q.Set("channel", channel)
+	resp, err := h.client.Get(h.url + "?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	l := &QuotaLimits{}
+	if err = json.NewDecoder(resp.Body).Decode(l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// quotaWindow counts messages in the fixed window started at start.
+type quotaWindow struct {
+	start time.Time
+	count int64
+}
+
+// cachedQuota the quotas from the provider, nil limits to apply the default quotas.
+type cachedQuota struct {
+	limits   *QuotaLimits
+	expireAt time.Time
+}
+
+// quotas counts messages of users per day and channels per minute in memory, the counters are of this node only.
+type quotas struct {
+	mu       sync.Mutex
+	defaults QuotaLimits
+	provider QuotaProvider
+	cached   map[string]*cachedQuota
+	users    map[string]*quotaWindow
+	channels map[string]*quotaWindow
+}
+
+func newQuotas(defaults *QuotaLimits, provider QuotaProvider) *quotas {
+	q := &quotas{
+		provider: provider,
+		cached:   map[string]*cachedQuota{},
+		users:    map[string]*quotaWindow{},
+		channels: map[string]*quotaWindow{},
+	}
+	if defaults != nil {
+		q.defaults = *defaults
+	}
+	return q
+}
+
+func (q *quotas) getDefaults() QuotaLimits {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.defaults
+}
+
+func (q *quotas) setDefault(name string, value int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	switch name {
+	case QuotaUserDailyMessages:
+		q.defaults.UserDailyMessages = value
+	case QuotaChannelMinuteMessages:
+		q.defaults.ChannelMinuteMessages = value
+	case QuotaMessageLength:
+		q.defaults.MessageLength = value
+	default:
+		return false
+	}
+	return true
+}
+
+func (q *quotas) setProvider(p QuotaProvider) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.provider = p
+	q.cached = map[string]*cachedQuota{}
+}
+
+// limits returns quotas of the sender and the channel, quotas from the provider are cached for a minute, the cached or
+// default quotas are applied if the provider fails, and the provider is not called again until retried after 10s.
+func (q *quotas) limits(uid string, channel string, now time.Time) QuotaLimits {
+	key := uid + "\x00" + channel
+	q.mu.Lock()
+	provider := q.provider
+	c := q.cached[key]
+	q.mu.Unlock()
+
+	if provider != nil && (c == nil || !now.Before(c.expireAt)) {
+		l, err := provider.QuotaLimits(uid, channel)
+		if err != nil {
+			log.E("get quotas of %s in %s error: %v", uid, channel, err)
+			retry := &cachedQuota{expireAt: now.Add(defaultQuotaRetryInterval)}
+			if c != nil {
+				retry.limits = c.limits
+			}
+			c = retry
+		} else {
+			c = &cachedQuota{limits: l, expireAt: now.Add(defaultQuotaCacheTTL)}
+		}
+		q.mu.Lock()
+		q.cached[key] = c
+		if len(q.cached) > maxQuotaEntries {
+			for k, v := range q.cached {
+				if !now.Before(v.expireAt) {
+					delete(q.cached, k)
+				}
+			}
+		}
+		q.mu.Unlock()
+	}
+	if c != nil && c.limits != nil {
+		return *c.limits
+	}
+	return q.getDefaults()
+}
+
+// check returns the quota exceeded by the message of length characters from the sender to the channel, nil if the
+// message is allowed. The channel is empty for P2P messages. The message is not counted until charge.
+func (q *quotas) check(uid string, channel string, length int, now time.Time) *messages.QuotaExceeded {
+	l := q.limits(uid, channel, now)
+	if l.MessageLength > 0 && int64(length) > l.MessageLength {
+		return &messages.QuotaExceeded{Quota: QuotaMessageLength, Limit: l.MessageLength}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if l.UserDailyMessages > 0 {
+		user := q.window(q.users, uid, now.UTC().Truncate(time.Hour*24))
+		if user.count >= l.UserDailyMessages {
+			return &messages.QuotaExceeded{
+				Quota: QuotaUserDailyMessages, Limit: l.UserDailyMessages, ResetAt: user.start.Add(time.Hour * 24).Unix(),
+			}
+		}
+	}
+	if channel != "" && l.ChannelMinuteMessages > 0 {
+		ch := q.window(q.channels, channel, now.Truncate(time.Minute))
+		if ch.count >= l.ChannelMinuteMessages {
+			return &messages.QuotaExceeded{
+				Quota: QuotaChannelMinuteMessages, Limit: l.ChannelMinuteMessages, ResetAt: ch.start.Add(time.Minute).Unix(),
+			}
+		}
+	}
+	return nil
+}
+
+// charge counts the message accepted from the sender to the channel.
+func (q *quotas) charge(uid string, channel string, now time.Time) {
+	l := q.limits(uid, channel, now)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if l.UserDailyMessages > 0 {
+		q.window(q.users, uid, now.UTC().Truncate(time.Hour*24)).count++
+	}
+	if channel != "" && l.ChannelMinuteMessages > 0 {
+		q.window(q.channels, channel, now.Truncate(time.Minute)).count++
+	}
+}
+
+// window returns the counter of the key in the window started at start, the counter of the previous window is reset.
+func (q *quotas) window(windows map[string]*quotaWindow, key string, start time.Time) *quotaWindow {
+	w, ok := windows[key]
+	if !ok || w.start.Before(start) {
+		if len(windows) > maxQuotaEntries {
+			for k, v := range windows {
+				if v.start.Before(start) {
+					delete(windows, k)
+				}
+			}
+		}
+		w = &quotaWindow{start: start}
+		windows[key] = w
+	}
+	return w
+}
+
+// SetQuotaProvider sets the provider of quotas of users and channels, nil to apply the default quotas only.
+func (d *MessageHandlerImpl) SetQuotaProvider(p QuotaProvider) {
+	d.quotas.setProvider(p)
+}
+
+// exceedQuota returns true if the message is rejected as the sender exceeds quotas, the sender is notified with
+// ActionNotifyQuotaExceeded. The channel is empty for P2P messages. The message is counted by chargeQuota after
+// accepted.
+func (d *MessageHandlerImpl) exceedQuota(c *gate.Info, m *messages.GlideMessage, channel string, cm *messages.ChatMessage) bool {
+	length := 0
+	if !messages.IsMediaType(cm.Type) {
+		length = utf8.RuneCountInString(cm.Content)
+	}
+	exceeded := d.quotas.check(cm.From, channel, length, time.Now())
+	if exceeded == nil {
+		return false
+	}
+	metrics.QuotaExceeded.WithLabelValues(exceeded.Quota).Inc()
+	log.D("message of %s to %s rejected, quota %s exceeded", cm.From, m.To, exceeded.Quota)
+	exceeded.CliMid = cm.CliMid
+	exceeded.To = m.To
+	d.enqueueMessage(c.ID, messages.NewMessage(m.GetSeq(), messages.ActionNotifyQuotaExceeded, exceeded))
+	return true
+}
+
+// chargeQuota counts the message accepted in quotas of the sender and the channel.
+func (d *MessageHandlerImpl) chargeQuota(channel string, cm *messages.ChatMessage) {
+	d.quotas.charge(cm.From, channel, time.Now())
+}
@@ -0,0 +1,140 @@
+package messaging
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+// consume checks the message and charges it if allowed.
+func consume(q *quotas, uid string, channel string, length int, now time.Time) *messages.QuotaExceeded {
+	if e := q.check(uid, channel, length, now); e != nil {
+		return e
+	}
+	q.charge(uid, channel, now)
+	return nil
+}
+
+func TestQuotas_check(t *testing.T) {
+	q := newQuotas(&QuotaLimits{UserDailyMessages: 2, ChannelMinuteMessages: 1, MessageLength: 5}, nil)
+	now := time.Date(2022, 1, 1, 23, 59, 30, 0, time.UTC)
+
+	e := consume(q, "1", "", 6, now)
+	assert.Equal(t, QuotaMessageLength, e.Quota)
+	assert.Zero(t, e.ResetAt)
+
+	assert.Nil(t, consume(q, "1", "g", 5, now))
+	// the channel quota exceeded, the message is not counted in the daily quota.
+	e = consume(q, "2", "g", 1, now)
+	assert.Equal(t, QuotaChannelMinuteMessages, e.Quota)
+	assert.Equal(t, now.Truncate(time.Minute).Add(time.Minute).Unix(), e.ResetAt)
+
+	assert.Nil(t, consume(q, "1", "", 1, now))
+	e = consume(q, "1", "", 1, now)
+	assert.Equal(t, QuotaUserDailyMessages, e.Quota)
+	assert.Equal(t, int64(2), e.Limit)
+	assert.Equal(t, time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC).Unix(), e.ResetAt)
+
+	// quotas reset in the next window.
+	assert.Nil(t, consume(q, "1", "g", 1, now.Add(time.Minute)))
+}
+
+func TestQuotas_provider(t *testing.T) {
+	calls := 0
+	q := newQuotas(&QuotaLimits{UserDailyMessages: 1}, QuotaProviderFunc(func(uid string, channel string) (*QuotaLimits, error) {
+		calls++
+		if uid == "vip" {
+			return &QuotaLimits{UserDailyMessages: 2}, nil
+		}
+		return nil, nil
+	}))
+	now := time.Now()
+
+	assert.Nil(t, consume(q, "vip", "", 1, now))
+	assert.Nil(t, consume(q, "vip", "", 1, now))
+	assert.NotNil(t, consume(q, "vip", "", 1, now))
+	assert.Equal(t, 1, calls)
+
+	assert.Nil(t, consume(q, "1", "", 1, now))
+	assert.NotNil(t, consume(q, "1", "", 1, now))
+
+	// the default quota updated applies to users without quotas from the provider.
+	assert.True(t, q.setDefault(QuotaUserDailyMessages, 0))
+	assert.Nil(t, consume(q, "1", "", 1, now))
+}
+
+func TestQuotas_providerFailed(t *testing.T) {
+	calls := 0
+	failed := false
+	q := newQuotas(&QuotaLimits{UserDailyMessages: 1}, QuotaProviderFunc(func(uid string, channel string) (*QuotaLimits, error) {
+		calls++
+		if failed {
+			return nil, errors.New("unavailable")
+		}
+		return &QuotaLimits{UserDailyMessages: 3}, nil
+	}))
+	now := time.Now()
+
+	assert.Nil(t, consume(q, "1", "", 1, now))
+	failed = true
+	// the cached quotas are applied after expired, the provider is retried after the interval only.
+	now = now.Add(defaultQuotaCacheTTL)
+	assert.Nil(t, consume(q, "1", "", 1, now))
+	assert.Nil(t, consume(q, "1", "", 1, now))
+	assert.NotNil(t, consume(q, "1", "", 1, now))
+	assert.Equal(t, 2, calls)
+
+	consume(q, "1", "", 1, now.Add(defaultQuotaRetryInterval))
+	assert.Equal(t, 3, calls)
+}
+
+func TestMessageHandlerImpl_handleChatMessage_Quota(t *testing.T) {
+	s := &countingStore{}
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	assert.NoError(t, handler.SetRateLimit(QuotaUserDailyMessages, 1))
+	assert.Equal(t, int64(1), handler.RateLimits()[QuotaUserDailyMessages])
+
+	sender := &gate.Info{ID: gate.NewID2("1")}
+	send := func(cliMid string) *messages.GlideMessage {
+		m := &messages.GlideMessage{
+			Action: string(messages.ActionChatMessage),
+			To:     "2",
+			Data:   messages.NewData(&messages.ChatMessage{CliMid: cliMid, Content: "hi"}),
+		}
+		assert.NoError(t, handler.handleChatMessage(sender, m))
+		replies := g.messagesOf(sender.ID)
+		return replies[len(replies)-1]
+	}
+
+	assert.Equal(t, messages.ActionAckMessage, send("1").GetAction())
+	reply := send("2")
+	assert.Equal(t, messages.ActionNotifyQuotaExceeded, reply.GetAction())
+	exceeded := reply.Data.GetData().(*messages.QuotaExceeded)
+	assert.Equal(t, "2", exceeded.CliMid)
+	assert.Equal(t, QuotaUserDailyMessages, exceeded.Quota)
+	assert.Equal(t, int64(1), s.stored)
+
+	// the rejected message is sent again after the quota reset.
+	assert.NoError(t, handler.SetRateLimit(QuotaUserDailyMessages, 0))
+	assert.Equal(t, messages.ActionAckMessage, send("2").GetAction())
+}
+
+func TestMessageHandlerImpl_handleChatMessage_QuotaRejected(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}, Moderator: &mockModerator{}})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	assert.NoError(t, handler.SetRateLimit(QuotaUserDailyMessages, 1))
+
+	// the message rejected by moderation is not counted.
+	sendChat(t, handler, "1", "2", "bad")
+	sendChat(t, handler, "1", "2", "hi")
+	assert.Len(t, g.messagesOf(gate.NewID2("2")), 1)
+}
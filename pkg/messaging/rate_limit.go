@@ -0,0 +1,38 @@
+package messaging
+
+import (
+	"errors"
+	"time"
+)
+
+// RateLimitStateMessageInterval the min interval in milliseconds of state messages from a sender to the same target.
+const RateLimitStateMessageInterval = "state_message_interval_ms"
+
+const errUnknownRateLimit = "unknown rate limit"
+
+// RateLimits returns current rate limits and default quotas of the handler by name.
+func (d *MessageHandlerImpl) RateLimits() map[string]int64 {
+	q := d.quotas.getDefaults()
+	return map[string]int64{
+		RateLimitStateMessageInterval: d.stateLimiter.getInterval().Milliseconds(),
+		QuotaUserDailyMessages:        q.UserDailyMessages,
+		QuotaChannelMinuteMessages:    q.ChannelMinuteMessages,
+		QuotaMessageLength:            q.MessageLength,
+	}
+}
+
+// SetRateLimit updates the rate limit or the default quota with the name at runtime, zero quota is unlimited.
+func (d *MessageHandlerImpl) SetRateLimit(name string, value int64) error {
+	if value < 0 {
+		return errors.New("rate limit must not be negative")
+	}
+	switch name {
+	case RateLimitStateMessageInterval:
+		d.stateLimiter.setInterval(time.Duration(value) * time.Millisecond)
+	default:
+		if !d.quotas.setDefault(name, value) {
+			return errors.New(errUnknownRateLimit)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package messaging
+
+import (
+	"errors"
+	"time"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+)
+
+const (
+	defaultMaxReactions = 20
+	maxEmojiLength      = 32
+)
+
+const (
+	errReactionInvalid  = "reaction emoji is invalid"
+	errTooManyReactions = "too many reactions of the message"
+	errNotInMessageConv = "message is not in the conversation"
+)
+
+// messageGetter is implemented by stores that support getting the stored message, such as store.MessageRecallStore.
+type messageGetter interface {
+	GetMessage(c conversation.ID, mid int64) (*messages.ChatMessage, error)
+}
+
+// conversationMessage returns the stored message of mid in the conversation of uid with to, nil if the store does not
+// support getting messages.
+func (d *MessageHandlerImpl) conversationMessage(conv *conversation.Conversation, uid string, to string, mid int64) (*messages.ChatMessage, error) {
+	mg, ok := store.Unwrap(d.store).(messageGetter)
+	if !ok {
+		return nil, nil
+	}
+	cm, err := mg.GetMessage(conv.ID, mid)
+	if err != nil {
+		return nil, err
+	}
+	if conv.Type == conversation.TypeChannel {
+		if cm.To != to {
+			return nil, errors.New(errNotInMessageConv)
+		}
+		return cm, nil
+	}
+	if !(cm.From == uid && cm.To == to) && !(cm.From == to && cm.To == uid) {
+		return nil, errors.New(errNotInMessageConv)
+	}
+	return cm, nil
+}
+
+// updateReaction adds or removes the reaction of the sender, returns false if the reactions are not changed.
+func (d *MessageHandlerImpl) updateReaction(c *gate.Info, conv *conversation.Conversation, r *messages.Reaction, to string, react bool) (bool, error) {
+	if r.Emoji == "" || len(r.Emoji) > maxEmojiLength {
+		return false, errors.New(errReactionInvalid)
+	}
+	uid := c.ID.UID()
+	// the reaction is not checked if the store does not support getting messages.
+	if _, err := d.conversationMessage(conv, uid, to, r.Mid); err != nil {
+		return false, err
+	}
+	var changed bool
+	var err error
+	if react {
+		counts, err := d.reactions.GetReactions(r.Mid)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := counts[r.Emoji]; !ok && len(counts) >= d.maxReactions {
+			return false, errors.New(errTooManyReactions)
+		}
+		changed, err = d.reactions.AddReaction(r.Mid, uid, r.Emoji)
+	} else {
+		changed, err = d.reactions.RemoveReaction(r.Mid, uid, r.Emoji)
+	}
+	if err != nil || !changed {
+		return false, err
+	}
+	r.Counts, err = d.reactions.GetReactions(r.Mid)
+	if err != nil {
+		return false, err
+	}
+	r.From = uid
+	r.To = to
+	r.ReactAt = time.Now().Unix()
+	return true, nil
+}
+
+// handleReaction reacts or unreacts a message, notify all participants of the conversation and other devices of the
+// sender with the counts of reactions.
+func (d *MessageHandlerImpl) handleReaction(c *gate.Info, m *messages.GlideMessage) error {
+	r := new(messages.Reaction)
+	if !d.unmarshalData(c, m, r) {
+		return nil
+	}
+	conv, err := conversation.Of(c.ID.UID(), m)
+	if err != nil {
+		return err
+	}
+	action := m.GetAction()
+	react := action == messages.ActionMessageReact || action == messages.ActionGroupMessageReact
+	changed, err := d.updateReaction(c, conv, r, m.To, react)
+	if err != nil {
+		log.D("react message %d failed: %v", r.Mid, err)
+		d.enqueueMessage(c.ID, messages.NewErrorMessage(m.GetSeq(), messages.ActionNotifyError, err.Error()))
+		return nil
+	}
+	if !changed {
+		return nil
+	}
+
+	notify := messages.NewMessage(m.GetSeq(), action, r)
+	_, err = d.route(r.From, conv, notify, true)
+	if conv.Type == conversation.TypeP2P {
+		d.dispatchAllDevice(r.From, notify)
+	}
+	return err
+}
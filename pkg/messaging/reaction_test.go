@@ -0,0 +1,60 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageHandlerImpl_handleReaction(t *testing.T) {
+	s := &mockRecallStore{
+		messages: map[int64]*messages.ChatMessage{
+			1: {Mid: 1, From: "1", To: "2", SendAt: time.Now().Unix()},
+		},
+		recalled: map[int64]bool{},
+	}
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s, MaxReactions: 1})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	react := func(uid string, action messages.Action, to string, emoji string) {
+		m := &messages.GlideMessage{Action: string(action), To: to, Data: messages.NewData(&messages.Reaction{Mid: 1, Emoji: emoji})}
+		assert.NoError(t, handler.handleReaction(&gate.Info{ID: gate.NewID2(uid)}, m))
+	}
+	last := func(uid string) *messages.GlideMessage {
+		ms := g.messagesOf(gate.NewID2(uid))
+		return ms[len(ms)-1]
+	}
+
+	react("2", messages.ActionMessageReact, "1", "+1")
+	n := last("1")
+	assert.Equal(t, messages.ActionMessageReact, n.GetAction())
+	r := n.Data.GetData().(*messages.Reaction)
+	assert.Equal(t, "2", r.From)
+	assert.Equal(t, map[string]int64{"+1": 1}, r.Counts)
+
+	react("1", messages.ActionMessageReact, "2", "+1")
+	r = last("2").Data.GetData().(*messages.Reaction)
+	assert.Equal(t, map[string]int64{"+1": 2}, r.Counts)
+
+	// distinct emojis reach the max reactions
+	react("1", messages.ActionMessageReact, "2", "heart")
+	assert.Equal(t, messages.ActionNotifyError, last("1").GetAction())
+
+	// not a participant of the conversation
+	react("3", messages.ActionMessageReact, "1", "+1")
+	assert.Equal(t, messages.ActionNotifyError, last("3").GetAction())
+
+	react("2", messages.ActionMessageUnreact, "1", "+1")
+	r = last("1").Data.GetData().(*messages.Reaction)
+	assert.Equal(t, messages.ActionMessageUnreact, last("1").GetAction())
+	assert.Equal(t, map[string]int64{"+1": 1}, r.Counts)
+
+	counts, err := handler.reactions.GetReactions(1)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"+1": 1}, counts)
+}
@@ -0,0 +1,75 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"time"
+)
+
+// handleReadMessage updates the read cursor of the conversation, notify the peer of P2P conversation and other devices
+// of the reader. read receipts of channel are not broadcast, use ActionApiReadCount instead, read counts of the latest
+// messages are pushed to senders periodically if enabled, see MessageHandlerOptions.ReadCountInterval.
+func (d *MessageHandlerImpl) handleReadMessage(c *gate.Info, m *messages.GlideMessage) error {
+	receipt := new(messages.ReadReceipt)
+	if !d.unmarshalData(c, m, receipt) {
+		return nil
+	}
+	conv, err := conversation.Of(c.ID.UID(), m)
+	if err != nil {
+		return err
+	}
+	receipt.From = c.ID.UID()
+	receipt.To = m.To
+	receipt.ReadAt = time.Now().Unix()
+
+	advanced, err := d.readCursors.UpdateReadCursor(receipt.From, conv.ID, receipt.Seq, receipt.ReadAt)
+	if err != nil {
+		log.E("update read cursor error %v", err)
+		return err
+	}
+	if !advanced {
+		return nil
+	}
+	if d.push != nil {
+		if err = d.push.ClearBadge(receipt.From, conv.ID); err != nil {
+			log.E("clear badge error %v", err)
+		}
+	}
+
+	notify := messages.NewMessage(0, m.GetAction(), receipt)
+	if conv.Type == conversation.TypeP2P {
+		_, err = d.route(receipt.From, conv, notify, true)
+	} else if d.readCounts != nil {
+		d.readCounts.mark(conv.ID, receipt.Seq)
+	}
+	d.dispatchAllDevice(receipt.From, notify)
+	return err
+}
+
+// handleApiReadCursors responds all read cursors of the user, used by newly connected devices to sync unread count.
+func (d *MessageHandlerImpl) handleApiReadCursors(c *gate.Info, m *messages.GlideMessage) error {
+	cursors, err := d.readCursors.GetReadCursors(c.ID.UID())
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, cursors))
+	return nil
+}
+
+// handleApiReadCount responds the count of channel subscribers who have read the message of sequence.
+func (d *MessageHandlerImpl) handleApiReadCount(c *gate.Info, m *messages.GlideMessage) error {
+	rc := new(messages.ReadCount)
+	if !d.unmarshalData(c, m, rc) {
+		return nil
+	}
+	count, err := d.readCursors.GetReadCount(conversation.NewChannel(rc.To).ID, rc.Seq)
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	rc.Count = count
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, rc))
+	return nil
+}
@@ -0,0 +1,177 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReadCountWindow      = 20
+	defaultMaxReaderListMembers = 100
+	// maxReadCountChannels the max count of channels whose read counts pushed are remembered.
+	maxReadCountChannels = 10000
+)
+
+const (
+	errReadersNotSupported = "reader list is not supported"
+	errChannelTooLarge     = "channel is too large to list readers"
+)
+
+// readCountAggregator collects channels whose read cursors advanced, and flushes them periodically, so the read
+// counts are computed once per channel in an interval regardless of the count of read receipts.
+type readCountAggregator struct {
+	mu       sync.Mutex
+	interval time.Duration
+	window   int64
+	// dirty the max sequence read of channels since last flush.
+	dirty     map[conversation.ID]int64
+	scheduled bool
+	// pushed the read counts of messages pushed by channel and sequence.
+	pushed map[conversation.ID]map[int64]int64
+	flush  func(c conversation.ID, latest int64)
+}
+
+func newReadCountAggregator(interval time.Duration, window int, flush func(c conversation.ID, latest int64)) *readCountAggregator {
+	if window <= 0 {
+		window = defaultReadCountWindow
+	}
+	return &readCountAggregator{
+		interval: interval,
+		window:   int64(window),
+		dirty:    map[conversation.ID]int64{},
+		pushed:   map[conversation.ID]map[int64]int64{},
+		flush:    flush,
+	}
+}
+
+// mark marks the channel read to seq, the flush is scheduled after interval if not scheduled.
+func (a *readCountAggregator) mark(c conversation.ID, seq int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if seq > a.dirty[c] {
+		a.dirty[c] = seq
+	}
+	if !a.scheduled {
+		a.scheduled = true
+		time.AfterFunc(a.interval, a.run)
+	}
+}
+
+func (a *readCountAggregator) run() {
+	a.mu.Lock()
+	dirty := a.dirty
+	a.dirty = map[conversation.ID]int64{}
+	a.scheduled = false
+	a.mu.Unlock()
+	for c, latest := range dirty {
+		a.flush(c, latest)
+	}
+}
+
+// changed returns true if the count differs from the count pushed, and remembers the count. Counts of messages out
+// of the window ended at latest are forgotten.
+func (a *readCountAggregator) changed(c conversation.ID, latest int64, seq int64, count int64) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	counts, ok := a.pushed[c]
+	if !ok {
+		if len(a.pushed) >= maxReadCountChannels {
+			a.pushed = map[conversation.ID]map[int64]int64{}
+		}
+		counts = map[int64]int64{}
+		a.pushed[c] = counts
+	}
+	for s := range counts {
+		if s <= latest-a.window {
+			delete(counts, s)
+		}
+	}
+	if counts[seq] == count {
+		return false
+	}
+	counts[seq] = count
+	return true
+}
+
+// flushReadCounts pushes the read counts changed of the latest messages of the channel to their senders.
+func (d *MessageHandlerImpl) flushReadCounts(c conversation.ID, latest int64) {
+	hs, ok := store.As[store.MessageHistoryStore](d.store)
+	if !ok {
+		return
+	}
+	start := latest - d.readCounts.window + 1
+	if start < 1 {
+		start = 1
+	}
+	ms, err := hs.GetBySeqRange(c, start, latest)
+	if err != nil {
+		log.E("get messages of %s for read count error: %v", c, err)
+		return
+	}
+	bySender := map[string][]*messages.ReadCount{}
+	for _, m := range ms {
+		count, err := d.readCursors.GetReadCount(c, m.Seq)
+		if err != nil {
+			log.E("get read count of %s error: %v", c, err)
+			return
+		}
+		if d.readCounts.changed(c, latest, m.Seq, count) {
+			bySender[m.From] = append(bySender[m.From], &messages.ReadCount{To: c.Target(), Seq: m.Seq, Count: count})
+		}
+	}
+	for sender, counts := range bySender {
+		d.dispatchAllDevice(sender, messages.NewMessage(0, messages.ActionNotifyReadCount, counts))
+	}
+}
+
+// handleApiReadReaders responds subscribers who have read the message of the channel, the requester must be a
+// subscriber, and the channel must not be larger than the max members of reader list.
+func (d *MessageHandlerImpl) handleApiReadReaders(c *gate.Info, m *messages.GlideMessage) error {
+	rr := new(messages.ReadReaders)
+	if !d.unmarshalData(c, m, rr) {
+		return nil
+	}
+	readers, err := d.getReaders(c.ID.UID(), rr.To, rr.Seq)
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	rr.Readers = readers
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, rr))
+	return nil
+}
+
+func (d *MessageHandlerImpl) getReaders(uid string, channel string, seq int64) ([]string, error) {
+	rs, ok := store.As[store.ReaderStore](d.readCursors)
+	if !ok {
+		return nil, errors.New(errReadersNotSupported)
+	}
+	mi, ok := d.def.GetGroupInterface().(subscription.MemberInspector)
+	if !ok {
+		return nil, errors.New(errReadersNotSupported)
+	}
+	subscribers, err := mi.Subscribers(subscription.ChanID(channel))
+	if err != nil {
+		return nil, err
+	}
+	if len(subscribers) > d.maxReaderListMembers {
+		return nil, errors.New(errChannelTooLarge)
+	}
+	uids := make([]string, 0, len(subscribers))
+	member := false
+	for _, s := range subscribers {
+		uids = append(uids, string(s))
+		member = member || string(s) == uid
+	}
+	if !member {
+		return nil, errors.New(errNotParticipant)
+	}
+	// the readers left the channel are excluded.
+	return rs.GetReaders(conversation.NewChannel(channel).ID, seq, uids)
+}
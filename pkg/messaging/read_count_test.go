@@ -0,0 +1,106 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store/storetest"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/stretchr/testify/assert"
+)
+
+// channelHistoryStore stores channel messages in memory for history queries.
+type channelHistoryStore struct {
+	*storetest.MessageStore
+	channel []*messages.ChatMessage
+}
+
+func (s *channelHistoryStore) StoreChannelMessage(ch subscription.ChanID, msg *messages.ChatMessage) error {
+	s.channel = append(s.channel, msg)
+	return nil
+}
+
+func (s *channelHistoryStore) GetBySeqRange(c conversation.ID, start int64, end int64) ([]*messages.ChatMessage, error) {
+	var ret []*messages.ChatMessage
+	for _, m := range s.channel {
+		if string(conversation.NewChannel(m.To).ID) == string(c) && m.Seq >= start && m.Seq <= end {
+			ret = append(ret, m)
+		}
+	}
+	return ret, nil
+}
+
+func (s *channelHistoryStore) Migrate() error {
+	return nil
+}
+
+func TestMessageHandlerImpl_ReadCount(t *testing.T) {
+	s := &channelHistoryStore{MessageStore: storetest.NewMessageStore()}
+	for seq, from := range []string{"1", "1", "2"} {
+		_ = s.StoreChannelMessage("g", &messages.ChatMessage{From: from, To: "g", Seq: int64(seq + 1)})
+	}
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s, ReadCountInterval: time.Millisecond * 10})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	read := func(uid string, seq int64) {
+		err := handler.handleReadMessage(&gate.Info{ID: gate.NewID2(uid)}, &messages.GlideMessage{
+			Action: string(messages.ActionGroupMessageRead),
+			To:     "g",
+			Data:   messages.NewData(&messages.ReadReceipt{Seq: seq}),
+		})
+		assert.NoError(t, err)
+	}
+	counts := func(uid string, n int) []*messages.ReadCount {
+		var ms []*messages.GlideMessage
+		assert.Eventually(t, func() bool {
+			ms = nil
+			for _, m := range g.messagesOf(gate.NewID2(uid)) {
+				if m.GetAction() == messages.ActionNotifyReadCount {
+					ms = append(ms, m)
+				}
+			}
+			return len(ms) == n
+		}, time.Second, time.Millisecond*10)
+		return ms[n-1].Data.GetData().([]*messages.ReadCount)
+	}
+
+	read("3", 2)
+	read("4", 3)
+	// read receipts in the interval are pushed in one notification to each sender.
+	assert.Equal(t, []*messages.ReadCount{{To: "g", Seq: 1, Count: 2}, {To: "g", Seq: 2, Count: 2}}, counts("1", 1))
+	assert.Equal(t, []*messages.ReadCount{{To: "g", Seq: 3, Count: 1}}, counts("2", 1))
+
+	// only the counts changed are pushed.
+	read("5", 1)
+	assert.Equal(t, []*messages.ReadCount{{To: "g", Seq: 1, Count: 3}}, counts("1", 2))
+	assert.Len(t, counts("2", 1), 1)
+}
+
+func TestMessageHandlerImpl_ReadReaders(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	handler.SetSubscription(&mockMembers{subscribers: []subscription.SubscriberID{"1", "3", "4"}})
+
+	conv := conversation.NewChannel("g").ID
+	for uid, seq := range map[string]int64{"3": 2, "4": 3, "5": 3, "1": 1} {
+		_, _ = handler.readCursors.UpdateReadCursor(uid, conv, seq, 0)
+	}
+	readers, err := handler.getReaders("1", "g", 2)
+	assert.NoError(t, err)
+	// the user left the channel is excluded.
+	assert.ElementsMatch(t, []string{"3", "4"}, readers)
+
+	_, err = handler.getReaders("9", "g", 2)
+	assert.EqualError(t, err, errNotParticipant)
+
+	handler.maxReaderListMembers = 2
+	_, err = handler.getReaders("1", "g", 2)
+	assert.EqualError(t, err, errChannelTooLarge)
+}
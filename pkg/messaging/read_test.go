@@ -0,0 +1,34 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMessageHandlerImpl_handleReadMessage(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	reader := &gate.Info{ID: gate.NewID2("1")}
+	read := func(seq int64) {
+		err := handler.handleReadMessage(reader, &messages.GlideMessage{
+			Action: string(messages.ActionMessageRead),
+			To:     "2",
+			Data:   messages.NewData(&messages.ReadReceipt{Seq: seq}),
+		})
+		assert.NoError(t, err)
+	}
+	read(10)
+	read(5)
+	assert.Len(t, g.messagesOf(gate.NewID2("2")), 1)
+
+	err = handler.handleApiReadCursors(reader, &messages.GlideMessage{Seq: 1, Action: string(messages.ActionApiReadCursors)})
+	assert.NoError(t, err)
+	resp := g.messagesOf(reader.ID)
+	cursors := resp[len(resp)-1].Data.GetData().([]*messages.ReadCursor)
+	assert.Equal(t, int64(10), cursors[0].Seq)
+}
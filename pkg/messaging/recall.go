@@ -0,0 +1,79 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"time"
+)
+
+const defaultRecallWindow = time.Minute * 2
+
+const (
+	errRecallNotSupported = "message recall is not supported"
+	errNotMessageOwner    = "not the sender of the message"
+	errWindowExpired      = "message can not be modified anymore"
+)
+
+// checkOwnMessage checks the message is sent by uid to the target, and it's sent in the window.
+func checkOwnMessage(cm *messages.ChatMessage, uid string, to string, now time.Time, window time.Duration) error {
+	if cm.From != uid || cm.To != to {
+		return errors.New(errNotMessageOwner)
+	}
+	if now.Sub(time.Unix(cm.SendAt, 0)) > window {
+		return errors.New(errWindowExpired)
+	}
+	return nil
+}
+
+// validateRecall checks the message ownership and recall window, and marks it recalled in store.
+func (d *MessageHandlerImpl) validateRecall(c *gate.Info, conv conversation.ID, recall *messages.RecallMessage, to string) error {
+	rs, ok := store.As[store.MessageRecallStore](d.store)
+	if !ok {
+		return errors.New(errRecallNotSupported)
+	}
+	cm, err := rs.GetMessage(conv, recall.Mid)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = checkOwnMessage(cm, c.ID.UID(), to, now, d.recallWindow)
+	if err != nil {
+		return err
+	}
+	err = rs.MarkRecalled(conv, recall.Mid)
+	if err != nil {
+		return err
+	}
+	recall.From = cm.From
+	recall.To = cm.To
+	recall.RecallAt = now.Unix()
+	return nil
+}
+
+// handleRecallMessage recalls a message, notify all participants of the conversation and other devices of the sender.
+func (d *MessageHandlerImpl) handleRecallMessage(c *gate.Info, m *messages.GlideMessage) error {
+	recall := new(messages.RecallMessage)
+	if !d.unmarshalData(c, m, recall) {
+		return nil
+	}
+	conv, err := conversation.Of(c.ID.UID(), m)
+	if err != nil {
+		return err
+	}
+	err = d.validateRecall(c, conv.ID, recall, m.To)
+	if err != nil {
+		log.D("recall message %d failed: %v", recall.Mid, err)
+		d.enqueueMessage(c.ID, messages.NewErrorMessage(m.GetSeq(), messages.ActionNotifyError, err.Error()))
+		return nil
+	}
+
+	notify := messages.NewMessage(m.GetSeq(), m.GetAction(), recall)
+	_, err = d.route(recall.From, conv, notify, true)
+	if conv.Type == conversation.TypeP2P {
+		d.dispatchAllDevice(recall.From, notify)
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type mockRecallStore struct {
+	messages map[int64]*messages.ChatMessage
+	recalled map[int64]bool
+}
+
+func (m *mockRecallStore) StoreMessage(message *messages.ChatMessage) error {
+	return nil
+}
+
+func (m *mockRecallStore) StoreOffline(message *messages.ChatMessage) error {
+	return nil
+}
+
+func (m *mockRecallStore) GetMessage(c conversation.ID, mid int64) (*messages.ChatMessage, error) {
+	cm, ok := m.messages[mid]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return cm, nil
+}
+
+func (m *mockRecallStore) MarkRecalled(c conversation.ID, mid int64) error {
+	m.recalled[mid] = true
+	return nil
+}
+
+func TestMessageHandlerImpl_handleRecallMessage(t *testing.T) {
+	s := &mockRecallStore{
+		messages: map[int64]*messages.ChatMessage{
+			1: {Mid: 1, From: "1", To: "2", SendAt: time.Now().Unix()},
+			2: {Mid: 2, From: "1", To: "2", SendAt: time.Now().Add(-time.Hour).Unix()},
+		},
+		recalled: map[int64]bool{},
+	}
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	sender := &gate.Info{ID: gate.NewID2("1")}
+	err = handler.handleRecallMessage(sender, &messages.GlideMessage{Action: string(messages.ActionMessageRecall), To: "2", Data: messages.NewData(&messages.RecallMessage{Mid: 1})})
+	assert.NoError(t, err)
+	assert.True(t, s.recalled[1])
+	assert.Equal(t, messages.ActionMessageRecall, g.messagesOf(gate.NewID2("2"))[0].GetAction())
+
+	// expired
+	err = handler.handleRecallMessage(sender, &messages.GlideMessage{Action: string(messages.ActionMessageRecall), To: "2", Data: messages.NewData(&messages.RecallMessage{Mid: 2})})
+	assert.NoError(t, err)
+	assert.False(t, s.recalled[2])
+
+	// not owner
+	other := &gate.Info{ID: gate.NewID2("2")}
+	err = handler.handleRecallMessage(other, &messages.GlideMessage{Action: string(messages.ActionMessageRecall), To: "1", Data: messages.NewData(&messages.RecallMessage{Mid: 1})})
+	assert.NoError(t, err)
+	notify := g.messagesOf(other.ID)
+	assert.Equal(t, messages.ActionNotifyError, notify[len(notify)-1].GetAction())
+}
@@ -0,0 +1,42 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+const (
+	errBlocked    = "blocked by the receiver"
+	errNotContact = "not a contact of the receiver"
+)
+
+// isBlocked returns true if the receiver to blocked the sender from, the message is delivered if the block list is
+// unavailable.
+func (d *MessageHandlerImpl) isBlocked(from string, to string) bool {
+	if d.relations == nil {
+		return false
+	}
+	blocked, err := d.relations.IsBlocked(from, to)
+	if err != nil {
+		log.E("query block list of %s error %v", to, err)
+		return false
+	}
+	return blocked
+}
+
+// notContact returns true if the message is rejected as contacts are required and the sender is not a contact
+// of the receiver, the message with bypass ticket is always allowed. The message is rejected if contacts unavailable.
+func (d *MessageHandlerImpl) notContact(from string, to string, m *messages.GlideMessage) bool {
+	if d.contacts == nil || m.Bypass {
+		return false
+	}
+	// visitors are not contacts of service accounts.
+	if d.servicePool(from) != nil || d.servicePool(to) != nil {
+		return false
+	}
+	ok, err := d.contacts.IsContact(from, to)
+	if err != nil {
+		log.E("query contacts of %s error %v", from, err)
+		return true
+	}
+	return !ok
+}
@@ -0,0 +1,72 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/relation"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMessageHandlerImpl_handleChatMessage_Blocked(t *testing.T) {
+	relations := relation.NewMemProvider()
+	assert.NoError(t, relations.Block("2", "1"))
+
+	for _, drop := range []bool{false, true} {
+		s := &countingStore{}
+		g := newMockGateway()
+		handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s, Relations: relations, DropBlocked: drop})
+		assert.NoError(t, err)
+		handler.SetGate(g)
+
+		sender := &gate.Info{ID: gate.NewID2("1")}
+		m := &messages.GlideMessage{
+			Action: string(messages.ActionChatMessage),
+			To:     "2",
+			Data:   messages.NewData(&messages.ChatMessage{CliMid: "1", Content: "hi"}),
+		}
+		assert.NoError(t, handler.handleChatMessage(sender, m))
+
+		assert.Empty(t, g.messagesOf(gate.NewID2("2")))
+		replies := g.messagesOf(sender.ID)
+		assert.Len(t, replies, 1)
+		if drop {
+			// the sender is acked as the message sent.
+			assert.Equal(t, messages.ActionAckMessage, replies[0].GetAction())
+			assert.Equal(t, int64(1), s.stored)
+		} else {
+			assert.Equal(t, messages.ActionNotifyRejected, replies[0].GetAction())
+			assert.Equal(t, int64(0), s.stored)
+		}
+	}
+}
+
+func TestMessageHandlerImpl_handleChatMessage_RequireContact(t *testing.T) {
+	relations := relation.NewMemProvider()
+	assert.NoError(t, relations.AddContact("1", "2"))
+	s := &countingStore{}
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s, Relations: relations, RequireContact: true})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	sender := &gate.Info{ID: gate.NewID2("1")}
+	send := func(to string, bypass bool) messages.Action {
+		m := &messages.GlideMessage{
+			Action: string(messages.ActionChatMessage),
+			To:     to,
+			Data:   messages.NewData(&messages.ChatMessage{Content: "hi"}),
+			Bypass: bypass,
+		}
+		assert.NoError(t, handler.handleChatMessage(sender, m))
+		replies := g.messagesOf(sender.ID)
+		return replies[len(replies)-1].GetAction()
+	}
+	assert.Equal(t, messages.ActionAckMessage, send("2", false))
+	assert.Equal(t, messages.ActionNotifyRejected, send("3", false))
+	assert.Equal(t, messages.ActionAckMessage, send("3", true))
+	assert.Equal(t, int64(2), s.stored)
+
+	_, err = NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s, RequireContact: true})
+	assert.Error(t, err)
+}
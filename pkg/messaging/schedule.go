@@ -0,0 +1,84 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/schedule"
+	"github.com/glide-im/glide/pkg/store"
+	"time"
+)
+
+const errScheduledNotExist = "scheduled message does not exist"
+
+// extraKeyBypass keeps messages.GlideMessage.Bypass of the scheduled message in store, as it's not serialized.
+const extraKeyBypass = "_bypass"
+
+// EnableSchedule delivers chat and group messages at messages.GlideMessage.DeliverAt if it's in the future, the
+// sender is replied with messages.Scheduled, and cancels it by messages.ActionApiScheduleCancel. The scheduler
+// returned is used by business services to schedule messages.
+func (d *MessageHandlerImpl) EnableSchedule(opts *schedule.Options) (*schedule.Scheduler, error) {
+	s := schedule.New(d.deliverScheduled, opts)
+	if err := s.Start(); err != nil {
+		return nil, err
+	}
+	d.scheduler = s
+	d.def.AddHandler(NewActionHandler(messages.ActionApiScheduleCancel, d.handleApiScheduleCancel))
+	return s, nil
+}
+
+// schedule schedules the message of DeliverAt in the future, returns false if the message should be delivered now.
+func (d *MessageHandlerImpl) schedule(c *gate.Info, m *messages.GlideMessage) bool {
+	if d.scheduler == nil || m.DeliverAt <= time.Now().Unix() || c.ID.IsTemp() {
+		return false
+	}
+	// the message is delivered as it's sent at the time.
+	sm := *m
+	sm.DeliverAt = 0
+	sm.Extra = map[string]string{}
+	for k, v := range m.Extra {
+		sm.Extra[k] = v
+	}
+	// the extra set by client is never trusted.
+	delete(sm.Extra, extraKeyBypass)
+	if m.Bypass {
+		sm.Extra[extraKeyBypass] = "1"
+	}
+	id, err := d.scheduler.Schedule(c.ID.UID(), time.Unix(m.DeliverAt, 0), &sm)
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return true
+	}
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, &messages.Scheduled{ID: id, DeliverAt: m.DeliverAt}))
+	return true
+}
+
+// deliverScheduled handles the scheduled message in the normal delivery path as it's sent by the sender now.
+func (d *MessageHandlerImpl) deliverScheduled(sm *store.ScheduledMessage) {
+	sm.Message.DeliverAt = 0
+	if _, ok := sm.Message.Extra[extraKeyBypass]; ok {
+		sm.Message.Bypass = true
+		delete(sm.Message.Extra, extraKeyBypass)
+	}
+	err := d.def.Handle(&gate.Info{ID: gate.NewID2(sm.From)}, sm.Message)
+	if err != nil {
+		log.E("deliver scheduled message %d error: %v", sm.ID, err)
+	}
+}
+
+func (d *MessageHandlerImpl) handleApiScheduleCancel(c *gate.Info, m *messages.GlideMessage) error {
+	r := new(messages.Scheduled)
+	if !d.unmarshalData(c, m, r) {
+		return nil
+	}
+	ok, err := d.scheduler.Cancel(r.ID, c.ID.UID())
+	if err == nil && !ok {
+		err = errors.New(errScheduledNotExist)
+	}
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, r))
+	return nil
+}
@@ -0,0 +1,54 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/schedule"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMessageHandlerImpl_Schedule(t *testing.T) {
+	s := &countingStore{}
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	scheduler, err := handler.EnableSchedule(&schedule.Options{})
+	assert.NoError(t, err)
+	defer scheduler.Stop()
+
+	sender := &gate.Info{ID: gate.NewID2("1")}
+	lastReply := func() *messages.GlideMessage {
+		ms := g.messagesOf(sender.ID)
+		return ms[len(ms)-1]
+	}
+	m := &messages.GlideMessage{
+		Seq:       1,
+		Action:    string(messages.ActionChatMessage),
+		To:        "2",
+		DeliverAt: time.Now().Add(time.Minute).Unix(),
+		Data:      messages.NewData(&messages.ChatMessage{Content: "hi"}),
+	}
+	assert.NoError(t, handler.handleChatMessage(sender, m))
+	assert.Equal(t, int64(0), s.stored)
+	assert.Empty(t, g.messagesOf(gate.NewID2("2")))
+	reply := lastReply()
+	assert.Equal(t, messages.ActionApiSuccess, reply.GetAction())
+	scheduled := reply.Data.GetData().(*messages.Scheduled)
+
+	cancel := messages.NewMessage(2, messages.ActionApiScheduleCancel, scheduled)
+	assert.NoError(t, handler.handleApiScheduleCancel(sender, cancel))
+	assert.Equal(t, messages.ActionApiSuccess, lastReply().GetAction())
+	assert.NoError(t, handler.handleApiScheduleCancel(sender, cancel))
+	assert.Equal(t, messages.ActionApiFailed, lastReply().GetAction())
+
+	// the message is delivered in the normal path when it's due.
+	handler.deliverScheduled(&store.ScheduledMessage{ID: scheduled.ID, From: "1", Message: m})
+	assert.Eventually(t, func() bool {
+		return len(g.messagesOf(gate.NewID2("2"))) == 1
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, messages.ActionChatMessage, g.messagesOf(gate.NewID2("2"))[0].GetAction())
+}
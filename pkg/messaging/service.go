@@ -0,0 +1,148 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/service"
+)
+
+const (
+	errServiceNotExist = "service does not exist"
+	errNotServiceAgent = "not the agent of the session"
+)
+
+// extraKeyService the service account of the message delivered to the agent.
+const extraKeyService = "service"
+
+// EnableServices routes P2P messages to service accounts of the manager to agents, see package service. The agent
+// replies to the visitor with the chat message to the visitor, which is sent as the service account. Agents transfer
+// sessions by messages.ActionApiServiceTransfer, agents and visitors close sessions by
+// messages.ActionApiServiceClose. Agents are assigned sessions when online in the user state of the handler, unless
+// service.Options.Online is set.
+func (d *MessageHandlerImpl) EnableServices(m *service.Manager) {
+	m.SetPresence(d.userState.IsOnline)
+	d.services = m
+	d.def.AddHandler(NewActionHandler(messages.ActionApiServiceTransfer, d.handleApiServiceTransfer))
+	d.def.AddHandler(NewActionHandler(messages.ActionApiServiceClose, d.handleApiServiceClose))
+}
+
+// servicePool returns the pool of the service account uid, nil if uid is not a service account.
+func (d *MessageHandlerImpl) servicePool(uid string) *service.Pool {
+	if d.services == nil {
+		return nil
+	}
+	return d.services.Get(uid)
+}
+
+// serviceSender returns the service account if the sender is the agent serving the receiver, otherwise the sender.
+func (d *MessageHandlerImpl) serviceSender(from string, to string) string {
+	if d.services == nil {
+		return from
+	}
+	if pool := d.services.ServedBy(from, to); pool != nil {
+		return pool.ID()
+	}
+	return from
+}
+
+// dispatchService delivers the message of the visitor to the agent of the session, the session is opened if it's not
+// a notification, returns false if no agent serves the visitor.
+func (d *MessageHandlerImpl) dispatchService(pool *service.Pool, visitor string, m *messages.GlideMessage, notify bool) bool {
+	var s service.Session
+	if notify {
+		var ok bool
+		if s, ok = pool.Session(visitor); !ok {
+			return false
+		}
+	} else {
+		var created bool
+		var err error
+		s, created, err = pool.Assign(visitor)
+		if err != nil {
+			log.W("assign agent of service %s to %s error: %v", pool.ID(), visitor, err)
+			return false
+		}
+		if created {
+			d.notifyService(messages.ServiceEventAssigned, s, "")
+		}
+	}
+	dm := *m
+	dm.Extra = map[string]string{}
+	for k, v := range m.Extra {
+		dm.Extra[k] = v
+	}
+	dm.Extra[extraKeyService] = pool.ID()
+	return d.dispatchDevices(s.Agent, &dm)
+}
+
+// notifyService notifies the visitor, the agent and the previous agent of the session changed.
+func (d *MessageHandlerImpl) notifyService(event string, s service.Session, from string) {
+	n := messages.NewMessage(0, messages.ActionNotifyService, &messages.ServiceNotify{
+		Event:   event,
+		Service: s.Service,
+		Visitor: s.Visitor,
+		Agent:   s.Agent,
+		From:    from,
+	})
+	for _, uid := range []string{s.Visitor, s.Agent, from} {
+		if uid != "" {
+			d.dispatchDevices(uid, n)
+		}
+	}
+}
+
+func (d *MessageHandlerImpl) handleApiServiceTransfer(c *gate.Info, m *messages.GlideMessage) error {
+	req := new(messages.ServiceSession)
+	if !d.unmarshalData(c, m, req) {
+		return nil
+	}
+	pool := d.servicePool(req.Service)
+	if pool == nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errServiceNotExist))
+		return nil
+	}
+	if s, ok := pool.Session(req.Visitor); !ok || s.Agent != c.ID.UID() {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errNotServiceAgent))
+		return nil
+	}
+	s, from, err := pool.Transfer(req.Visitor, req.Agent)
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	d.notifyService(messages.ServiceEventTransferred, s, from)
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, &messages.ServiceSession{
+		Service: s.Service,
+		Visitor: s.Visitor,
+		Agent:   s.Agent,
+	}))
+	return nil
+}
+
+func (d *MessageHandlerImpl) handleApiServiceClose(c *gate.Info, m *messages.GlideMessage) error {
+	req := new(messages.ServiceSession)
+	if !d.unmarshalData(c, m, req) {
+		return nil
+	}
+	pool := d.servicePool(req.Service)
+	if pool == nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errServiceNotExist))
+		return nil
+	}
+	// the visitor closes its own session.
+	visitor := req.Visitor
+	if visitor == "" || visitor == c.ID.UID() {
+		visitor = c.ID.UID()
+	} else if s, ok := pool.Session(visitor); !ok || s.Agent != c.ID.UID() {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, errNotServiceAgent))
+		return nil
+	}
+	s, err := pool.Close(visitor)
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	d.notifyService(messages.ServiceEventClosed, s, "")
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, nil))
+	return nil
+}
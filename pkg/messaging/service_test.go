@@ -0,0 +1,63 @@
+package messaging
+
+import (
+	"testing"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageHandlerImpl_Services(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	m := service.NewManager()
+	pool, err := m.Register("cs", nil)
+	assert.NoError(t, err)
+	pool.AddAgent("a1")
+	pool.AddAgent("a2")
+	handler.EnableServices(m)
+	handler.userState.onUserOnline(gate.NewID2("a1"))
+	handler.userState.onUserOnline(gate.NewID2("a2"))
+
+	a1, a2, visitor := gate.NewID("", "a1", ""), gate.NewID("", "a2", ""), gate.NewID("", "v", "")
+
+	sendChat(t, handler, "v", "cs", "hello")
+	got := g.messagesOf(a1)
+	assert.Len(t, got, 2)
+	assert.Equal(t, messages.ActionNotifyService, got[0].GetAction())
+	assert.Equal(t, messages.ActionChatMessage, got[1].GetAction())
+	assert.Equal(t, "cs", got[1].Extra[extraKeyService])
+	assert.Equal(t, "v", got[1].Data.GetData().(*messages.ChatMessage).From)
+
+	// the reply of agent is sent as the service
+	sendChat(t, handler, "a1", "v", "hi")
+	got = g.messagesOf(visitor)
+	reply := got[len(got)-1]
+	assert.Equal(t, messages.ActionChatMessage, reply.GetAction())
+	assert.Equal(t, "cs", reply.Data.GetData().(*messages.ChatMessage).From)
+
+	// only the agent of the session can transfer
+	transfer := messages.NewMessage(1, messages.ActionApiServiceTransfer, &messages.ServiceSession{Service: "cs", Visitor: "v"})
+	assert.NoError(t, handler.handleApiServiceTransfer(&gate.Info{ID: a2}, transfer))
+	assert.Equal(t, messages.ActionApiFailed, g.messagesOf(a2)[0].GetAction())
+	assert.NoError(t, handler.handleApiServiceTransfer(&gate.Info{ID: a1}, transfer))
+	s, ok := pool.Session("v")
+	assert.True(t, ok)
+	assert.Equal(t, "a2", s.Agent)
+
+	sendChat(t, handler, "v", "cs", "again")
+	got = g.messagesOf(a2)
+	assert.Equal(t, messages.ActionChatMessage, got[len(got)-1].GetAction())
+
+	closeSession := messages.NewMessage(2, messages.ActionApiServiceClose, &messages.ServiceSession{Service: "cs"})
+	assert.NoError(t, handler.handleApiServiceClose(&gate.Info{ID: visitor}, closeSession))
+	_, ok = pool.Session("v")
+	assert.False(t, ok)
+	got = g.messagesOf(a2)
+	n := got[len(got)-1].Data.GetData().(*messages.ServiceNotify)
+	assert.Equal(t, messages.ServiceEventClosed, n.Event)
+}
@@ -0,0 +1,85 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"sync"
+	"time"
+)
+
+const defaultStateMessageInterval = time.Millisecond * 500
+
+// maxStateLimiterEntries the count of senders and targets tracked by stateLimiter before expired ones are removed.
+const maxStateLimiterEntries = 100_000
+
+// stateLimiter limits the frequency of state messages from a sender to a conversation.
+type stateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     map[string]time.Time
+}
+
+func newStateLimiter(interval time.Duration) *stateLimiter {
+	if interval <= 0 {
+		interval = defaultStateMessageInterval
+	}
+	return &stateLimiter{
+		interval: interval,
+		last:     map[string]time.Time{},
+	}
+}
+
+func (s *stateLimiter) getInterval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interval
+}
+
+func (s *stateLimiter) setInterval(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval = interval
+}
+
+func (s *stateLimiter) allow(from string, c conversation.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := from + "\x00" + string(c)
+	if at, ok := s.last[key]; ok && now.Sub(at) < s.interval {
+		return false
+	}
+	s.last[key] = now
+
+	if len(s.last) > maxStateLimiterEntries {
+		for k, at := range s.last {
+			if now.Sub(at) >= s.interval {
+				delete(s.last, k)
+			}
+		}
+	}
+	return true
+}
+
+// handleStateMessage dispatches ephemeral state message to online participants of the conversation, the message will
+// not be stored and acked.
+func (d *MessageHandlerImpl) handleStateMessage(c *gate.Info, m *messages.GlideMessage) error {
+	sm := new(messages.StateMessage)
+	if !d.unmarshalData(c, m, sm) {
+		return nil
+	}
+	conv, err := conversation.Of(c.ID.UID(), m)
+	if err != nil {
+		return err
+	}
+	if !d.stateLimiter.allow(c.ID.UID(), conv.ID) {
+		return nil
+	}
+	sm.From = c.ID.UID()
+	sm.To = m.To
+
+	_, err = d.route(sm.From, conv, messages.NewMessage(0, m.GetAction(), sm), true)
+	return err
+}
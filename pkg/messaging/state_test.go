@@ -0,0 +1,62 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStateLimiter_Allow(t *testing.T) {
+	l := newStateLimiter(time.Millisecond * 50)
+	p2p := conversation.NewP2P("1", "2").ID
+
+	assert.True(t, l.allow("1", p2p))
+	assert.False(t, l.allow("1", p2p))
+	// limited by sender and conversation.
+	assert.True(t, l.allow("1", conversation.NewP2P("1", "3").ID))
+	assert.True(t, l.allow("2", p2p))
+
+	time.Sleep(time.Millisecond * 60)
+	assert.True(t, l.allow("1", p2p))
+}
+
+func TestStateLimiter_Expire(t *testing.T) {
+	l := newStateLimiter(time.Millisecond)
+	for i := 0; i <= maxStateLimiterEntries; i++ {
+		l.last[strconv.Itoa(i)] = time.Now().Add(-time.Second)
+	}
+	assert.True(t, l.allow("1", conversation.NewP2P("1", "2").ID))
+	assert.Len(t, l.last, 1)
+}
+
+func TestMessageHandlerImpl_handleStateMessage(t *testing.T) {
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: &countingStore{}})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	sender := &gate.Info{ID: gate.NewID2("1")}
+	state := func() *messages.GlideMessage {
+		return &messages.GlideMessage{
+			Action: string(messages.ActionStateMessage),
+			To:     "2",
+			Data:   messages.NewData(&messages.StateMessage{State: messages.StateTyping}),
+		}
+	}
+	assert.NoError(t, handler.handleStateMessage(sender, state()))
+	ms := g.messagesOf(gate.NewID2("2"))
+	assert.Len(t, ms, 1)
+	sm := &messages.StateMessage{}
+	assert.NoError(t, ms[0].Data.Deserialize(sm))
+	assert.Equal(t, "1", sm.From)
+	assert.Equal(t, "2", sm.To)
+	assert.Equal(t, messages.StateTyping, sm.State)
+
+	// limited in the interval.
+	assert.NoError(t, handler.handleStateMessage(sender, state()))
+	assert.Len(t, g.messagesOf(gate.NewID2("2")), 1)
+}
@@ -1,17 +1,17 @@
 package messaging
 
 import (
+	"github.com/glide-im/glide/pkg/conversation"
 	"github.com/glide-im/glide/pkg/gate"
-	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
-	"github.com/glide-im/glide/pkg/subscription"
-	"github.com/glide-im/glide/pkg/subscription/subscription_impl"
+	"github.com/glide-im/glide/pkg/webhook"
 )
 
 // handleGroupMsg 分发群消息
 func (d *MessageHandlerImpl) handleGroupMsg(c *gate.Info, msg *messages.GlideMessage) error {
-
-	id := subscription.ChanID(msg.To)
+	if d.schedule(c, msg) {
+		return nil
+	}
 
 	cm := messages.ChatMessage{}
 	e := msg.Data.Deserialize(&cm)
@@ -19,19 +19,40 @@ func (d *MessageHandlerImpl) handleGroupMsg(c *gate.Info, msg *messages.GlideMes
 		return e
 	}
 
-	m := subscription_impl.PublishMessage{
-		From:    subscription.SubscriberID(msg.From),
-		Message: msg,
-		Type:    subscription_impl.TypeMessage,
+	conv := conversation.NewChannel(msg.To)
+	cm.From = msg.From
+	cm.To = msg.To
+	thread := cm.Thread
+	if e = d.resolveThread(conv, cm.From, cm.To, &cm); e != nil {
+		log.D("resolve thread of reply %d failed: %v", cm.Parent, e)
+		d.enqueueMessage(c.ID, messages.NewErrorMessage(msg.GetSeq(), messages.ActionNotifyError, e.Error()))
+		return nil
+	}
+	mentions := len(cm.Mentions)
+	targets := d.resolveMentions(conv, &cm)
+	content := cm.Content
+	tags, ok := d.filterChatMessage(c, msg, &cm)
+	if !ok || d.exceedQuota(c, msg, msg.To, &cm) || !d.moderateSync(c, msg, conv, &cm) {
+		return nil
+	}
+	d.chargeQuota(msg.To, &cm)
+	tagMessage(msg, tags)
+	if cm.Content != content || cm.Thread != thread || len(cm.Mentions) != mentions {
+		msg.Data = messages.NewData(&cm)
 	}
-	err := d.def.GetGroupInterface().PublishMessage(id, &m)
+	_, err := d.route(msg.From, conv, msg, false)
 
 	if err != nil {
-		logger.E("dispatch group message error: %v", err)
-		notify := messages.NewMessage(msg.GetSeq(), messages.ActionNotifyError, err.Error())
+		log.E("dispatch group message error: %v", err)
+		notify := messages.NewErrorMessage(msg.GetSeq(), messages.ActionNotifyError, err.Error())
 		d.enqueueMessage(c.ID, notify)
 	} else {
-		_ = d.ackChatMessage(c, &cm)
+		if msg.GetQoS() != messages.QoSAtMostOnce {
+			_ = d.ackChatMessage(c, &cm)
+		}
+		d.notifyMentions(conv, &cm, targets)
+		webhook.Emit(webhook.EventMessageSent, &webhook.MessageEventData{Conversation: string(conv.ID), Message: &cm})
+		d.moderateAsync(conv, &cm)
 	}
 
 	return nil
@@ -0,0 +1,111 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// remoteConfigSource is implemented by the gateway pushes the remote config, such as gate.Impl.
+type remoteConfigSource interface {
+	RemoteConfig() *messages.RemoteConfig
+}
+
+// handleApiSync handles the bootstrap sync of the client after authenticate, the server pushes the session config,
+// the conversations changed and the unread counts in order, and responds the state synced. The client sends the state
+// of the last sync, so phases not changed since are skipped. The session config is pushed in the control lane, other
+// phases are not prior to live messages.
+func (d *MessageHandlerImpl) handleApiSync(c *gate.Info, m *messages.GlideMessage) error {
+	req := new(messages.SyncRequest)
+	if m.Data != nil && m.Data.GetData() != nil && !d.unmarshalData(c, m, req) {
+		return nil
+	}
+	uid := c.ID.UID()
+	session, err := d.syncSession(uid, req.ConfigVersion)
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+	conversations, err := d.getConversations(uid, req.Limit)
+	if err != nil {
+		d.enqueueMessage(c.ID, messages.NewErrorReply(m, err.Error()))
+		return nil
+	}
+
+	done := &messages.SyncPhase{Phase: messages.SyncPhaseDone, Version: req.Version}
+	var changed []*messages.ConversationInfo
+	unread := map[string]int64{}
+	for _, info := range conversations {
+		if info.Last != nil && info.Last.Mid > req.Version {
+			changed = append(changed, info)
+			if info.Last.Mid > done.Version {
+				done.Version = info.Last.Mid
+			}
+		}
+		if info.Unread > 0 {
+			unread[info.Conversation] = info.Unread
+		}
+	}
+	done.UnreadDigest = unreadDigest(unread)
+
+	if session != nil {
+		d.enqueueMessage(c.ID, messages.NewMessage(0, messages.ActionNotifySync, session))
+	}
+	if len(changed) > 0 {
+		phase := &messages.SyncPhase{Phase: messages.SyncPhaseConversations, Conversations: changed}
+		d.enqueueMessage(c.ID, syncMessage(phase))
+	}
+	if done.UnreadDigest != req.UnreadDigest {
+		phase := &messages.SyncPhase{Phase: messages.SyncPhaseUnread, Unread: unread}
+		d.enqueueMessage(c.ID, syncMessage(phase))
+	}
+	d.enqueueMessage(c.ID, messages.NewReply(m, messages.ActionApiSuccess, done))
+	return nil
+}
+
+// syncSession returns the session phase, nil if the config is not changed and push settings are disabled.
+func (d *MessageHandlerImpl) syncSession(uid string, configVersion string) (*messages.SyncPhase, error) {
+	phase := &messages.SyncPhase{Phase: messages.SyncPhaseSession}
+	if src, ok := d.def.GetClientInterface().(remoteConfigSource); ok {
+		if cfg := src.RemoteConfig(); cfg != nil && cfg.Version != configVersion {
+			phase.Config = cfg
+		}
+	}
+	if d.push != nil {
+		settings, err := d.getPushSettings(uid)
+		if err != nil {
+			return nil, err
+		}
+		phase.PushSettings = settings
+	}
+	if phase.Config == nil && phase.PushSettings == nil {
+		return nil, nil
+	}
+	return phase, nil
+}
+
+// syncMessage returns the notification of the bulk phase, it's queued in the lane of chat messages.
+func syncMessage(phase *messages.SyncPhase) *messages.GlideMessage {
+	m := messages.NewMessage(0, messages.ActionNotifySync, phase)
+	m.Priority = messages.PriorityNormal
+	return m
+}
+
+// unreadDigest returns the fnv-1a hash of unread counts, empty if nothing unread.
+func unreadDigest(unread map[string]int64) string {
+	if len(unread) == 0 {
+		return ""
+	}
+	ids := make([]string, 0, len(unread))
+	for id := range unread {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	h := fnv.New64a()
+	for _, id := range ids {
+		_, _ = h.Write([]byte(id + "=" + strconv.FormatInt(unread[id], 10) + "\n"))
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -0,0 +1,91 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type syncStore struct {
+	countingStore
+	summaries []*store.ConversationSummary
+}
+
+func (s *syncStore) GetRecentConversations(uid string, channels []string, limit int) ([]*store.ConversationSummary, error) {
+	return s.summaries, nil
+}
+
+type configGateway struct {
+	*mockGateway
+	cfg *messages.RemoteConfig
+}
+
+func (g *configGateway) RemoteConfig() *messages.RemoteConfig {
+	return g.cfg
+}
+
+func phasesOf(ms []*messages.GlideMessage) []*messages.SyncPhase {
+	var ret []*messages.SyncPhase
+	for _, m := range ms {
+		ret = append(ret, m.Data.GetData().(*messages.SyncPhase))
+	}
+	return ret
+}
+
+func TestMessageHandlerImpl_handleApiSync(t *testing.T) {
+	p2p := conversation.NewP2P("1", "2").ID
+	channel := conversation.NewChannel("100").ID
+	s := &syncStore{summaries: []*store.ConversationSummary{
+		{Conversation: channel, Last: &messages.ChatMessage{Mid: 20, From: "3", To: "100", Seq: 8}},
+		{Conversation: p2p, Last: &messages.ChatMessage{Mid: 10, From: "2", To: "1", Seq: 5}},
+	}}
+	g := &configGateway{mockGateway: newMockGateway(), cfg: &messages.RemoteConfig{Version: "v1"}}
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+	_, _ = handler.readCursors.UpdateReadCursor("1", p2p, 4, 1)
+
+	c := &gate.Info{ID: gate.NewID2("1")}
+	assert.NoError(t, handler.handleApiSync(c, messages.NewMessage(1, messages.ActionApiSync, nil)))
+	ms := g.messagesOf(c.ID)
+	assert.Len(t, ms, 4)
+	assert.Equal(t, messages.ActionApiSuccess, ms[3].GetAction())
+	assert.Equal(t, int64(1), ms[3].GetSeq())
+
+	phases := phasesOf(ms)
+	assert.Equal(t, messages.SyncPhaseSession, phases[0].Phase)
+	assert.Equal(t, "v1", phases[0].Config.Version)
+	assert.Equal(t, messages.PriorityHigh, ms[0].GetPriority())
+	assert.Equal(t, messages.SyncPhaseConversations, phases[1].Phase)
+	assert.Len(t, phases[1].Conversations, 2)
+	assert.Equal(t, messages.PriorityNormal, ms[1].GetPriority())
+	assert.Equal(t, messages.SyncPhaseUnread, phases[2].Phase)
+	assert.Equal(t, map[string]int64{string(channel): 8, string(p2p): 1}, phases[2].Unread)
+	done := phases[3]
+	assert.Equal(t, messages.SyncPhaseDone, done.Phase)
+	assert.Equal(t, int64(20), done.Version)
+	assert.NotEmpty(t, done.UnreadDigest)
+
+	// nothing changed since the last sync.
+	g.enqueued = map[gate.ID][]*messages.GlideMessage{}
+	req := &messages.SyncRequest{Version: done.Version, ConfigVersion: "v1", UnreadDigest: done.UnreadDigest}
+	assert.NoError(t, handler.handleApiSync(c, messages.NewMessage(2, messages.ActionApiSync, req)))
+	ms = g.messagesOf(c.ID)
+	assert.Len(t, ms, 1)
+	assert.Equal(t, done, phasesOf(ms)[0])
+
+	// a new message of the channel, and the p2p conversation is read on another device.
+	s.summaries[0].Last = &messages.ChatMessage{Mid: 30, From: "3", To: "100", Seq: 9}
+	_, _ = handler.readCursors.UpdateReadCursor("1", p2p, 5, 1)
+	g.enqueued = map[gate.ID][]*messages.GlideMessage{}
+	assert.NoError(t, handler.handleApiSync(c, messages.NewMessage(3, messages.ActionApiSync, req)))
+	phases = phasesOf(g.messagesOf(c.ID))
+	assert.Len(t, phases, 3)
+	assert.Equal(t, string(channel), phases[0].Conversations[0].Conversation)
+	assert.Len(t, phases[0].Conversations, 1)
+	assert.Equal(t, map[string]int64{string(channel): 9}, phases[1].Unread)
+	assert.Equal(t, int64(30), phases[2].Version)
+}
@@ -0,0 +1,48 @@
+package messaging
+
+import (
+	"errors"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+)
+
+const (
+	errReplyNotSupported  = "message reply is not supported"
+	errThreadNotSupported = "message thread is not supported"
+)
+
+// resolveThread validates the parent of the reply is in the conversation, and sets the thread of the reply to the
+// thread of the parent, or the parent if it's the root. The thread set by client is ignored.
+func (d *MessageHandlerImpl) resolveThread(conv *conversation.Conversation, from string, to string, cm *messages.ChatMessage) error {
+	cm.Thread = 0
+	if cm.Parent == 0 {
+		return nil
+	}
+	parent, err := d.conversationMessage(conv, from, to, cm.Parent)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return errors.New(errReplyNotSupported)
+	}
+	cm.Thread = parent.Thread
+	if cm.Thread == 0 {
+		cm.Thread = parent.Mid
+	}
+	return nil
+}
+
+// getThread returns messages of the thread of the range, at most 100 messages from the start sequence.
+func (d *MessageHandlerImpl) getThread(id conversation.ID, r *messages.MessageRange) ([]*messages.ChatMessage, error) {
+	ts, ok := store.Unwrap(d.store).(store.ThreadStore)
+	if !ok {
+		return nil, errors.New(errThreadNotSupported)
+	}
+	limit := maxMessageRange
+	if r.End > 0 && r.End-r.Start+1 < int64(limit) {
+		limit = int(r.End - r.Start + 1)
+	}
+	return ts.GetThread(id, r.Thread, r.Start, limit)
+}
@@ -0,0 +1,49 @@
+package messaging
+
+import (
+	"testing"
+
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageHandlerImpl_resolveThread(t *testing.T) {
+	s := storetest.NewMessageStore()
+	g := newMockGateway()
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	reply := func(from string, to string, content string, parent int64) {
+		m := &messages.GlideMessage{
+			Action: string(messages.ActionChatMessage),
+			To:     to,
+			// the thread set by client is ignored.
+			Data: messages.NewData(&messages.ChatMessage{CliMid: content, Content: content, Parent: parent, Thread: 100}),
+		}
+		assert.NoError(t, handler.handleChatMessage(&gate.Info{ID: gate.NewID2(from)}, m))
+	}
+
+	reply("1", "2", "root", 0)
+	root := s.Messages()[0]
+	assert.Zero(t, root.Thread)
+
+	reply("2", "1", "reply", root.Mid)
+	r1 := s.Messages()[1]
+	assert.Equal(t, root.Mid, r1.Parent)
+	assert.Equal(t, root.Mid, r1.Thread)
+
+	// the reply of the reply belongs to the thread of the root
+	reply("1", "2", "reply of reply", r1.Mid)
+	r2 := s.Messages()[2]
+	assert.Equal(t, r1.Mid, r2.Parent)
+	assert.Equal(t, root.Mid, r2.Thread)
+
+	// the parent is not in the conversation
+	reply("3", "2", "other", root.Mid)
+	assert.Len(t, s.Messages(), 3)
+	got := g.messagesOf(gate.NewID2("3"))
+	assert.Equal(t, messages.ActionNotifyError, got[len(got)-1].GetAction())
+}
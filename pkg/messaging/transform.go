@@ -0,0 +1,171 @@
+package messaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultTransformerTimeout = time.Second * 3
+
+// Transformer transforms the content of chat messages for each recipient before delivery, such as machine translating
+// messages to the locale of the recipient device. It's called in the worker of the conversation after the message
+// moderated and stored, so it should be fast or have a timeout.
+type Transformer interface {
+	// Transform returns the message transformed for the recipient device of the locale, nil to deliver the message
+	// as is. The msg must not be modified.
+	Transform(msg *messages.ChatMessage, recipient string, locale string) (*messages.ChatMessage, error)
+}
+
+// TransformerFunc the function implements Transformer.
+type TransformerFunc func(msg *messages.ChatMessage, recipient string, locale string) (*messages.ChatMessage, error)
+
+func (f TransformerFunc) Transform(msg *messages.ChatMessage, recipient string, locale string) (*messages.ChatMessage, error) {
+	return f(msg, recipient, locale)
+}
+
+// transformRequest the request body of the http transformer.
+type transformRequest struct {
+	Message   *messages.ChatMessage `json:"message"`
+	Recipient string                `json:"recipient"`
+	Locale    string                `json:"locale"`
+}
+
+type httpTransformer struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPTransformer returns the Transformer posts {"message": {}, "recipient": "", "locale": ""} in json to the url of
+// the business service, such as a translation service, the response body is the ChatMessage transformed in json, 204
+// to deliver the message as is. The timeout is 3s if not positive.
+func NewHTTPTransformer(url string, timeout time.Duration) Transformer {
+	if timeout <= 0 {
+		timeout = defaultTransformerTimeout
+	}
+	return &httpTransformer{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (h *httpTransformer) Transform(msg *messages.ChatMessage, recipient string, locale string) (*messages.ChatMessage, error) {
+	body, err := json.Marshal(&transformRequest{Message: msg, Recipient: recipient, Locale: locale})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	transformed := &messages.ChatMessage{}
+	if err = json.NewDecoder(resp.Body).Decode(transformed); err != nil {
+		return nil, err
+	}
+	return transformed, nil
+}
+
+// localeTracker tracks locales of online devices of users on this node.
+type localeTracker struct {
+	mu sync.RWMutex
+	// uid -> device -> locale
+	locales map[string]map[string]string
+}
+
+func newLocaleTracker() *localeTracker {
+	return &localeTracker{locales: map[string]map[string]string{}}
+}
+
+func (t *localeTracker) online(c *gate.Info) {
+	if c.ID.IsTemp() || c.Locale == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	uid := c.ID.UID()
+	if _, ok := t.locales[uid]; !ok {
+		t.locales[uid] = map[string]string{}
+	}
+	t.locales[uid][c.ID.Device()] = c.Locale
+}
+
+func (t *localeTracker) offline(id gate.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	uid := id.UID()
+	devices, ok := t.locales[uid]
+	if !ok {
+		return
+	}
+	delete(devices, id.Device())
+	if len(devices) == 0 {
+		delete(t.locales, uid)
+	}
+}
+
+func (t *localeTracker) localeOf(uid string, device string) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.locales[uid][device]
+}
+
+// deviceTransform transforms the chat message for devices of a recipient, the message transformed is cached by
+// locale, so devices of the same locale share a transform.
+type deviceTransform struct {
+	d         *MessageHandlerImpl
+	recipient string
+	m         *messages.GlideMessage
+	msg       *messages.ChatMessage
+	// transformed locale -> the message delivered to devices of the locale.
+	transformed map[string]*messages.GlideMessage
+}
+
+// newDeviceTransform returns nil if the transformer is disabled or the message is not a chat message.
+func (d *MessageHandlerImpl) newDeviceTransform(recipient string, m *messages.GlideMessage) *deviceTransform {
+	if d.transformer == nil {
+		return nil
+	}
+	switch m.GetAction() {
+	case messages.ActionChatMessage, messages.ActionChatMessageResend:
+	default:
+		return nil
+	}
+	msg := new(messages.ChatMessage)
+	if err := m.Data.Deserialize(msg); err != nil {
+		return nil
+	}
+	return &deviceTransform{d: d, recipient: recipient, m: m, msg: msg, transformed: map[string]*messages.GlideMessage{}}
+}
+
+// of returns the message delivered to the device, the message is delivered as is if the locale of device is unknown,
+// such as the device is connected to another node, or the transform is failed.
+func (t *deviceTransform) of(device string) *messages.GlideMessage {
+	locale := t.d.locales.localeOf(t.recipient, device)
+	if locale == "" {
+		return t.m
+	}
+	if m, ok := t.transformed[locale]; ok {
+		return m
+	}
+	m := t.m
+	msg, err := t.d.transformer.Transform(t.msg, t.recipient, locale)
+	if err != nil {
+		log.E("transform message %d to %s for %s error: %v", t.msg.Mid, locale, t.recipient, err)
+	} else if msg != nil {
+		m = messages.CopyMessage(t.m)
+		m.Data = messages.NewData(msg)
+	}
+	t.transformed[locale] = m
+	return m
+}
@@ -0,0 +1,87 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func contentOf(t *testing.T, m *messages.GlideMessage) string {
+	msg := new(messages.ChatMessage)
+	assert.NoError(t, m.Data.Deserialize(msg))
+	return msg.Content
+}
+
+func TestMessageHandlerImpl_Transform(t *testing.T) {
+	g := newMockGateway()
+	var calls []string
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{
+		MessageStore: &countingStore{},
+		Transformer: TransformerFunc(func(msg *messages.ChatMessage, recipient string, locale string) (*messages.ChatMessage, error) {
+			calls = append(calls, recipient+":"+locale)
+			switch locale {
+			case "zh-CN":
+				transformed := *msg
+				transformed.Content = "你好"
+				return &transformed, nil
+			case "fr":
+				return nil, errors.New("not supported")
+			}
+			return nil, nil
+		}),
+	})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	handler.locales.online(&gate.Info{ID: gate.NewID("", "2", "1"), Locale: "zh-CN"})
+	handler.locales.online(&gate.Info{ID: gate.NewID("", "2", "2"), Locale: "zh-CN"})
+	handler.locales.online(&gate.Info{ID: gate.NewID("", "2", "3"), Locale: "fr"})
+
+	sendChat(t, handler, "1", "2", "hello")
+	assert.Equal(t, "你好", contentOf(t, g.messagesOf(gate.NewID("", "2", "1"))[0]))
+	assert.Equal(t, "你好", contentOf(t, g.messagesOf(gate.NewID("", "2", "2"))[0]))
+	// the message is delivered as is if the transform failed or the locale is unknown.
+	assert.Equal(t, "hello", contentOf(t, g.messagesOf(gate.NewID("", "2", "3"))[0]))
+	assert.Equal(t, "hello", contentOf(t, g.messagesOf(gate.NewID("", "2", ""))[0]))
+	// devices of the same locale share the transform.
+	assert.Equal(t, []string{"2:zh-CN", "2:fr"}, calls)
+
+	handler.locales.offline(gate.NewID("", "2", "1"))
+	assert.Empty(t, handler.locales.localeOf("2", "1"))
+	assert.Equal(t, "zh-CN", handler.locales.localeOf("2", "2"))
+}
+
+func TestHTTPTransformer(t *testing.T) {
+	var req transformRequest
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			_ = json.NewEncoder(w).Encode(&messages.ChatMessage{Mid: req.Message.Mid, Content: "bonjour"})
+		}
+	}))
+	defer srv.Close()
+
+	tf := NewHTTPTransformer(srv.URL, 0)
+	msg, err := tf.Transform(&messages.ChatMessage{Mid: 1, Content: "hello"}, "2", "fr")
+	assert.NoError(t, err)
+	assert.Equal(t, "bonjour", msg.Content)
+	assert.Equal(t, "2", req.Recipient)
+	assert.Equal(t, "fr", req.Locale)
+	assert.Equal(t, "hello", req.Message.Content)
+
+	status = http.StatusNoContent
+	msg, err = tf.Transform(&messages.ChatMessage{Mid: 1}, "2", "fr")
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+
+	status = http.StatusBadGateway
+	_, err = tf.Transform(&messages.ChatMessage{Mid: 1}, "2", "fr")
+	assert.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"sync"
+	"time"
+)
+
+// offlineExpiry expires messages with TTL in the offline queue of receivers, the expiry is canceled when the receiver
+// acks offline messages.
+type offlineExpiry struct {
+	mu sync.Mutex
+	// timers the expiry timers of messages keyed on receiver and message id.
+	timers map[string]map[int64]*time.Timer
+}
+
+func newOfflineExpiry() *offlineExpiry {
+	return &offlineExpiry{timers: map[string]map[int64]*time.Timer{}}
+}
+
+// track calls onExpire after the TTL of message unless the offline messages of the receiver are acked.
+func (e *offlineExpiry) track(m *messages.ChatMessage, onExpire func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	uid, mid := m.To, m.Mid
+	timers, ok := e.timers[uid]
+	if !ok {
+		timers = map[int64]*time.Timer{}
+		e.timers[uid] = timers
+	}
+	if t, ok := timers[mid]; ok {
+		t.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(time.Duration(m.TTL)*time.Second, func() {
+		e.mu.Lock()
+		expired := e.timers[uid][mid] == t
+		if expired {
+			e.remove(uid, mid)
+		}
+		e.mu.Unlock()
+		if expired {
+			onExpire()
+		}
+	})
+	timers[mid] = t
+}
+
+// acked cancels the expiry of offline messages of uid, they are delivered.
+func (e *offlineExpiry) acked(uid string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, t := range e.timers[uid] {
+		t.Stop()
+	}
+	delete(e.timers, uid)
+}
+
+func (e *offlineExpiry) remove(uid string, mid int64) {
+	delete(e.timers[uid], mid)
+	if len(e.timers[uid]) == 0 {
+		delete(e.timers, uid)
+	}
+}
+
+// expireOffline removes the expired message from the offline queue of receiver to the dead letter sink, and notifies
+// the sender if MessageHandlerOptions.NotifyExpired is set.
+func (d *MessageHandlerImpl) expireOffline(m *messages.ChatMessage) {
+	log.D("offline message expired, mid=%d, to=%s", m.Mid, m.To)
+	d.deadLetter(m, store.DeadLetterExpired, nil)
+	if rs, ok := store.Unwrap(d.store).(store.OfflineRemoveStore); ok {
+		if err := rs.RemoveOffline(m.To, m.Mid); err != nil {
+			log.E("remove expired offline message error %v", err)
+		}
+	}
+	if !d.notifyExpired {
+		return
+	}
+	notify := messages.NewMessage(0, messages.ActionNotifyExpired, &messages.MessageExpired{
+		CliMid: m.CliMid,
+		Mid:    m.Mid,
+		To:     m.To,
+	})
+	d.dispatchAllDevice(m.From, notify)
+}
@@ -0,0 +1,89 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// offlineGateway is a mockGateway which users in offline are not connected.
+type offlineGateway struct {
+	*mockGateway
+	offline map[string]bool
+}
+
+func (o *offlineGateway) EnqueueMessage(id gate.ID, message *messages.GlideMessage) error {
+	if o.offline[id.UID()] {
+		return errors.New("client does not exist")
+	}
+	return o.mockGateway.EnqueueMessage(id, message)
+}
+
+type offlineStore struct {
+	countingStore
+	mu      sync.Mutex
+	offline map[int64]bool
+}
+
+func (s *offlineStore) StoreOffline(message *messages.ChatMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offline[message.Mid] = true
+	return nil
+}
+
+func (s *offlineStore) RemoveOffline(uid string, mid int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.offline, mid)
+	return nil
+}
+
+func (s *offlineStore) isOffline(mid int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offline[mid]
+}
+
+func TestMessageHandlerImpl_handleChatMessage_TTL(t *testing.T) {
+	s := &offlineStore{offline: map[int64]bool{}}
+	g := &offlineGateway{mockGateway: newMockGateway(), offline: map[string]bool{"2": true, "3": true}}
+	handler, err := NewHandlerWithOptions(g, &MessageHandlerOptions{MessageStore: s, NotifyExpired: true})
+	assert.NoError(t, err)
+	handler.SetGate(g)
+
+	sender := &gate.Info{ID: gate.NewID2("1")}
+	send := func(to string, ttl int64) int64 {
+		m := &messages.GlideMessage{
+			Action: string(messages.ActionChatMessage),
+			To:     to,
+			Data:   messages.NewData(&messages.ChatMessage{CliMid: to, Content: "123456", TTL: ttl}),
+		}
+		assert.NoError(t, handler.handleChatMessage(sender, m))
+		return s.stored
+	}
+	expired := send("2", 1)
+	acked := send("3", 1)
+	noTTL := send("3", 0)
+	// the receiver acked offline messages.
+	handler.expiry.acked("3")
+	time.Sleep(time.Millisecond * 1200)
+
+	assert.False(t, s.isOffline(expired))
+	assert.True(t, s.isOffline(acked))
+	assert.True(t, s.isOffline(noTTL))
+
+	var notices []*messages.MessageExpired
+	for _, m := range g.messagesOf(sender.ID) {
+		if m.GetAction() == messages.ActionNotifyExpired {
+			notices = append(notices, m.Data.GetData().(*messages.MessageExpired))
+		}
+	}
+	assert.Len(t, notices, 1)
+	assert.Equal(t, expired, notices[0].Mid)
+	assert.Equal(t, "2", notices[0].CliMid)
+}
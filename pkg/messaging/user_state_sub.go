@@ -2,25 +2,63 @@ package messaging
 
 import (
 	"github.com/glide-im/glide/pkg/gate"
-	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
 	"sync"
 	"time"
 )
 
+const defaultPresenceDebounce = time.Second * 3
+
 type UserStateData struct {
 	Uid    string `json:"uid,omitempty"`
-	Online bool   `json:"online,omitempty" json:"online,omitempty"`
+	Online bool   `json:"online,omitempty"`
+	// LastSeen the last time in unix seconds the user is active, it's updated by heartbeat and disconnection.
+	LastSeen int64 `json:"last_seen,omitempty"`
 }
 
 type StateSubscribeData struct {
 	Uids []string `json:"uids,omitempty"`
 }
 
+// PresenceObserver is notified on every presence change of all users, used by business services in process, it's
+// called with UserState locked, so implementation should not block or call back to the UserState.
+type PresenceObserver func(state UserStateData)
+
+// PresenceListener is called by PresenceSource when the presence of a user changed in the source.
+type PresenceListener func(uid string, online bool)
+
+// PresenceSource is an external presence provider, such as a companion web app the user is using without a socket
+// connected to the gateway. The presence of all sources is merged with the socket-derived presence, the user is online
+// if any of them reports online.
+type PresenceSource interface {
+
+	// Name returns the name of the source.
+	Name() string
+
+	// IsOnline returns true if the user is online in this source, it's called with UserState locked, so implementation
+	// should not call back to the UserState.
+	IsOnline(uid string) bool
+
+	// SetPresenceListener sets the listener to notify presence changes in this source.
+	SetPresenceListener(l PresenceListener)
+}
+
 type UserState struct {
 	subscribers map[string]map[string]byte
 	mySubs      map[string]map[string]byte
 
+	// online connected device count of users.
+	online map[string]int
+	// presence the last notified presence of users, merged socket and sources.
+	presence map[string]bool
+	sources  []PresenceSource
+	// lastSeen the last active time of users.
+	lastSeen map[string]int64
+	// pendingOffline the offline notifications waiting for debounce.
+	pendingOffline map[string]*time.Timer
+	debounce       time.Duration
+	observers      []PresenceObserver
+
 	mu      *sync.Mutex
 	gateway gate.Gateway
 
@@ -29,36 +67,146 @@ type UserState struct {
 
 func NewUserState(gateway gate.Gateway) *UserState {
 	return &UserState{
-		subscribers: map[string]map[string]byte{},
-		mySubs:      map[string]map[string]byte{},
-		gateway:     gateway,
-		mu:          &sync.Mutex{},
+		subscribers:    map[string]map[string]byte{},
+		mySubs:         map[string]map[string]byte{},
+		online:         map[string]int{},
+		presence:       map[string]bool{},
+		lastSeen:       map[string]int64{},
+		gateway:        gateway,
+		mu:             &sync.Mutex{},
+		pendingOffline: map[string]*time.Timer{},
 	}
 }
 
-func (u *UserState) onUserOnline(id gate.ID) {
+// SetDebounce sets the duration to delay the offline notification, the notification is dropped if the user comes back
+// online in the duration, so that brief reconnects don't spam subscribers. Zero to notify immediately.
+func (u *UserState) SetDebounce(d time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.debounce = d
+}
+
+// AddPresenceObserver registers an observer notified on every presence change.
+func (u *UserState) AddPresenceObserver(o PresenceObserver) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.observers = append(u.observers, o)
+}
+
+// Subscribe subscribes the presence of uids for the subscriber, the subscriber will receive ActionNotifyUserState
+// when the presence of uids changed.
+func (u *UserState) Subscribe(subscriber string, uids []string) {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	mySubList, ok := u.subscribers[id.UID()]
+	mySubs, ok := u.mySubs[subscriber]
 	if !ok {
-		mySubList = map[string]byte{}
-		u.subscribers[id.UID()] = mySubList
+		mySubs = map[string]byte{}
+		u.mySubs[subscriber] = mySubs
+	}
+	for _, uid := range uids {
+		subscribers, ok := u.subscribers[uid]
+		if !ok {
+			subscribers = map[string]byte{}
+			u.subscribers[uid] = subscribers
+		}
+		subscribers[subscriber] = 0
+		mySubs[uid] = 0
 	}
-	u.notifyOnline(id, mySubList)
 }
 
-func (u *UserState) onUserOffline(id gate.ID) {
+// Unsubscribe cancels the presence subscription of uids for the subscriber.
+func (u *UserState) Unsubscribe(subscriber string, uids []string) {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	mySubList, ok := u.subscribers[id.UID()]
-	if !ok || len(mySubList) == 0 {
+	mySubs := u.mySubs[subscriber]
+	for _, uid := range uids {
+		delete(u.subscribers[uid], subscriber)
+		delete(mySubs, uid)
+	}
+	if len(mySubs) == 0 {
+		delete(u.mySubs, subscriber)
+	}
+}
+
+// Presence returns current presence of uids.
+func (u *UserState) Presence(uids []string) []UserStateData {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var result []UserStateData
+	for _, uid := range uids {
+		result = append(result, UserStateData{Uid: uid, Online: u.presence[uid], LastSeen: u.lastSeen[uid]})
+	}
+	return result
+}
+
+// AddPresenceSource registers an external presence source.
+func (u *UserState) AddPresenceSource(s PresenceSource) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	s.SetPresenceListener(u.onSourceStateChanged)
+	u.sources = append(u.sources, s)
+	log.I("[UserState] presence source added: %s", s.Name())
+}
+
+// IsOnline returns true if the user is online in gateway or any presence source.
+func (u *UserState) IsOnline(uid string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.isOnline(uid)
+}
+
+func (u *UserState) onSourceStateChanged(uid string, _ bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.updatePresence(uid)
+}
+
+func (u *UserState) onUserOnline(id gate.ID) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	uid := id.UID()
+	_, ok := u.subscribers[uid]
+	if !ok {
+		u.subscribers[uid] = map[string]byte{}
+	}
+	u.online[uid]++
+	u.lastSeen[uid] = time.Now().Unix()
+	u.updatePresence(uid)
+}
+
+func (u *UserState) onHeartbeat(id gate.ID) {
+	if id.IsTemp() {
 		return
 	}
-	u.notifyOffline(id, mySubList)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.lastSeen[id.UID()] = time.Now().Unix()
+}
+
+func (u *UserState) onUserOffline(id gate.ID) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
 
 	myId := id.UID()
+	if u.online[myId] > 1 {
+		u.online[myId]--
+	} else {
+		delete(u.online, myId)
+	}
+	u.lastSeen[myId] = time.Now().Unix()
+	u.updatePresence(myId)
+
+	if u.online[myId] > 0 {
+		return
+	}
+	// the subscriptions bound to the connection, remove it when all devices are offline.
 	sub, ok := u.mySubs[myId]
 	if !ok {
 		return
@@ -68,62 +216,133 @@ func (u *UserState) onUserOffline(id gate.ID) {
 		if !ok2 {
 			continue
 		}
-		delete(target, uid)
+		delete(target, myId)
 	}
 	delete(u.mySubs, myId)
 }
 
+// remove deletes the presence of uid and presence subscriptions from and to uid, subscribers are not notified.
+func (u *UserState) remove(uid string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if t, ok := u.pendingOffline[uid]; ok {
+		t.Stop()
+		delete(u.pendingOffline, uid)
+	}
+	delete(u.presence, uid)
+	delete(u.lastSeen, uid)
+	for target := range u.mySubs[uid] {
+		delete(u.subscribers[target], uid)
+	}
+	delete(u.mySubs, uid)
+	for subscriber := range u.subscribers[uid] {
+		delete(u.mySubs[subscriber], uid)
+		if len(u.mySubs[subscriber]) == 0 {
+			delete(u.mySubs, subscriber)
+		}
+	}
+	delete(u.subscribers, uid)
+}
+
 func (u *UserState) subUserStateApi(c *gate.Info, m *messages.GlideMessage) error {
 	data := StateSubscribeData{}
 	err := m.Data.Deserialize(&data)
 	if err != nil {
 		return err
 	}
+	u.Subscribe(c.ID.UID(), data.Uids)
+	return nil
+}
 
-	myId := c.ID.UID()
-	u.mu.Lock()
-	defer u.mu.Unlock()
+func (u *UserState) unsubUserStateApi(c *gate.Info, m *messages.GlideMessage) error {
+	data := StateSubscribeData{}
+	err := m.Data.Deserialize(&data)
+	if err != nil {
+		return err
+	}
+	u.Unsubscribe(c.ID.UID(), data.Uids)
+	return nil
+}
 
-	mySubs, ok := u.mySubs[myId]
-	if !ok {
-		mySubs = map[string]byte{}
-		u.mySubs[myId] = mySubs
+func (u *UserState) queryUserStateApi(c *gate.Info, m *messages.GlideMessage) error {
+	data := StateSubscribeData{}
+	err := m.Data.Deserialize(&data)
+	if err != nil {
+		return err
 	}
+	resp := messages.NewReply(m, messages.ActionApiSuccess, u.Presence(data.Uids))
+	return u.gateway.EnqueueMessage(c.ID, resp)
+}
 
-	for _, uid := range data.Uids {
-		subscribers, ok := u.subscribers[uid]
-		if !ok {
-			subscribers = map[string]byte{}
-			u.subscribers[uid] = subscribers
+func (u *UserState) isOnline(uid string) bool {
+	if u.online[uid] > 0 {
+		return true
+	}
+	for _, source := range u.sources {
+		if source.IsOnline(uid) {
+			return true
 		}
-		u.subscribers[uid][myId] = 0
-		mySubs[uid] = 0
 	}
-	return nil
+	return false
 }
 
-func (u *UserState) notifyOnline(src gate.ID, to map[string]byte) {
-	notify := messages.NewMessage(0, messages.ActionNotifyUserState, UserStateData{
-		Uid:    src.UID(),
-		Online: false,
+// updatePresence merges the presence of the user, and notify the subscribers if the presence changed.
+func (u *UserState) updatePresence(uid string) {
+	online := u.isOnline(uid)
+	if t, ok := u.pendingOffline[uid]; ok && online {
+		// reconnected in debounce duration, the presence is not changed.
+		t.Stop()
+		delete(u.pendingOffline, uid)
+		return
+	}
+	if u.presence[uid] == online {
+		return
+	}
+	if online || u.debounce <= 0 {
+		u.commitPresence(uid, online)
+		return
+	}
+	if _, ok := u.pendingOffline[uid]; ok {
+		return
+	}
+	u.pendingOffline[uid] = time.AfterFunc(u.debounce, func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+
+		delete(u.pendingOffline, uid)
+		if u.presence[uid] && !u.isOnline(uid) {
+			u.commitPresence(uid, false)
+		}
 	})
-	for uid := range to {
-		_ = u.gateway.EnqueueMessage(gate.NewID2(uid), notify)
+}
+
+func (u *UserState) commitPresence(uid string, online bool) {
+	if online {
+		u.presence[uid] = true
+	} else {
+		delete(u.presence, uid)
+	}
+	u.notifyState(uid, online, u.subscribers[uid])
+}
+
+func (u *UserState) notifyState(uid string, online bool, to map[string]byte) {
+	state := UserStateData{
+		Uid:      uid,
+		Online:   online,
+		LastSeen: u.lastSeen[uid],
+	}
+	for _, o := range u.observers {
+		o(state)
+	}
+	notify := messages.Serialize(messages.NewMessage(0, messages.ActionNotifyUserState, state))
+	for sub := range to {
+		_ = u.gateway.EnqueueMessage(gate.NewID2(sub), notify)
 	}
 
 	var s = time.Now().Unix() - u.logStateAt
 	if s > 900 {
 		u.logStateAt = time.Now().Unix()
-		logger.D("[UserState] online users: %d, subscribes: %d", len(u.mySubs), len(u.subscribers))
-	}
-}
-
-func (u *UserState) notifyOffline(src gate.ID, to map[string]byte) {
-	notify := messages.NewMessage(0, messages.ActionNotifyUserState, UserStateData{
-		Uid:    src.UID(),
-		Online: true,
-	})
-	for uid := range to {
-		_ = u.gateway.EnqueueMessage(gate.NewID2(uid), notify)
+		log.D("[UserState] online users: %d, subscribes: %d", len(u.mySubs), len(u.subscribers))
 	}
 }
@@ -0,0 +1,129 @@
+package messaging
+
+import (
+	"github.com/glide-im/glide/pkg/gate"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockGateway struct {
+	mu       sync.Mutex
+	enqueued map[gate.ID][]*messages.GlideMessage
+}
+
+func newMockGateway() *mockGateway {
+	return &mockGateway{enqueued: map[gate.ID][]*messages.GlideMessage{}}
+}
+
+func (m *mockGateway) SetClientID(old gate.ID, new_ gate.ID) error {
+	return nil
+}
+
+func (m *mockGateway) UpdateClient(id gate.ID, info *gate.ClientSecrets) error {
+	return nil
+}
+
+func (m *mockGateway) ExitClient(id gate.ID) error {
+	return nil
+}
+
+func (m *mockGateway) EnqueueMessage(id gate.ID, message *messages.GlideMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enqueued[id] = append(m.enqueued[id], message)
+	return nil
+}
+
+func (m *mockGateway) messagesOf(id gate.ID) []*messages.GlideMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.enqueued[id]
+}
+
+type mockPresenceSource struct {
+	online   map[string]bool
+	listener PresenceListener
+}
+
+func (m *mockPresenceSource) Name() string {
+	return "mock"
+}
+
+func (m *mockPresenceSource) IsOnline(uid string) bool {
+	return m.online[uid]
+}
+
+func (m *mockPresenceSource) SetPresenceListener(l PresenceListener) {
+	m.listener = l
+}
+
+func (m *mockPresenceSource) set(uid string, online bool) {
+	m.online[uid] = online
+	m.listener(uid, online)
+}
+
+func TestUserState_PresenceSource(t *testing.T) {
+	g := newMockGateway()
+	state := NewUserState(g)
+	source := &mockPresenceSource{online: map[string]bool{}}
+	state.AddPresenceSource(source)
+
+	err := state.subUserStateApi(&gate.Info{ID: gate.NewID2("2")}, messages.NewMessage(0, messages.ActionApiSubUserState,
+		&StateSubscribeData{Uids: []string{"1"}}))
+	assert.NoError(t, err)
+
+	source.set("1", true)
+	assert.True(t, state.IsOnline("1"))
+
+	// already online in source, socket connection does not change presence.
+	state.onUserOnline(gate.NewID2("1"))
+	state.onUserOffline(gate.NewID2("1"))
+	assert.True(t, state.IsOnline("1"))
+
+	source.set("1", false)
+	assert.False(t, state.IsOnline("1"))
+
+	notified := g.messagesOf(gate.NewID2("2"))
+	assert.Len(t, notified, 2)
+	assert.True(t, notified[0].Data.GetData().(UserStateData).Online)
+	assert.False(t, notified[1].Data.GetData().(UserStateData).Online)
+}
+
+func TestUserState_Debounce(t *testing.T) {
+	g := newMockGateway()
+	state := NewUserState(g)
+	state.SetDebounce(time.Millisecond * 50)
+	var observed []UserStateData
+	state.AddPresenceObserver(func(s UserStateData) {
+		observed = append(observed, s)
+	})
+	state.Subscribe("2", []string{"1"})
+
+	state.onUserOnline(gate.NewID2("1"))
+	// brief reconnect is not notified.
+	state.onUserOffline(gate.NewID2("1"))
+	state.onUserOnline(gate.NewID2("1"))
+	time.Sleep(time.Millisecond * 100)
+	assert.Len(t, g.messagesOf(gate.NewID2("2")), 1)
+
+	state.onUserOffline(gate.NewID2("1"))
+	assert.Len(t, g.messagesOf(gate.NewID2("2")), 1)
+	time.Sleep(time.Millisecond * 100)
+
+	notified := g.messagesOf(gate.NewID2("2"))
+	assert.Len(t, notified, 2)
+	offline := notified[1].Data.GetData().(UserStateData)
+	assert.False(t, offline.Online)
+	assert.NotZero(t, offline.LastSeen)
+
+	state.mu.Lock()
+	assert.Len(t, observed, 2)
+	state.mu.Unlock()
+
+	presence := state.Presence([]string{"1"})
+	assert.False(t, presence[0].Online)
+	assert.Equal(t, offline.LastSeen, presence[0].LastSeen)
+}
@@ -0,0 +1,80 @@
+package messaging
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/hash"
+	"github.com/glide-im/glide/pkg/metrics"
+	"sync"
+)
+
+const defaultWorkerQueueSize = 1024
+
+const errWorkerQueueFull = "message worker queue is full"
+
+// executor runs the message handling tasks in order of the key, see orderKey.
+type executor interface {
+	submit(key string, task func()) error
+}
+
+// workerPool runs tasks by fixed count of workers shared by all connections, tasks with the same key are always run
+// by the same worker, so messages of a key are handled in the order received.
+type workerPool struct {
+	queues []chan func()
+
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newWorkerPool(workers int, queueSize int) *workerPool {
+	if queueSize <= 0 {
+		queueSize = defaultWorkerQueueSize
+	}
+	ret := &workerPool{
+		queues: make([]chan func(), workers),
+	}
+	for i := range ret.queues {
+		ret.queues[i] = make(chan func(), queueSize)
+		ret.wg.Add(1)
+		go ret.run(ret.queues[i])
+	}
+	return ret
+}
+
+// submit enqueues the task to the worker of the key, returns error if the queue of the worker is full.
+func (w *workerPool) submit(key string, task func()) error {
+	q := w.queues[hash.Hash([]byte(key), 0)%uint32(len(w.queues))]
+	select {
+	case q <- task:
+		metrics.HandleQueued.Inc()
+		return nil
+	default:
+		return errors.New(errWorkerQueueFull)
+	}
+}
+
+func (w *workerPool) run(q <-chan func()) {
+	defer w.wg.Done()
+	for task := range q {
+		metrics.HandleQueued.Dec()
+		w.exec(task)
+	}
+}
+
+func (w *workerPool) exec(task func()) {
+	defer func() {
+		if e := recover(); e != nil {
+			onMessageHandlerPanic(e)
+		}
+	}()
+	task()
+}
+
+// close stops accepting tasks and waits until queued tasks are done.
+func (w *workerPool) close() {
+	w.closeOnce.Do(func() {
+		for _, q := range w.queues {
+			close(q)
+		}
+	})
+	w.wg.Wait()
+}
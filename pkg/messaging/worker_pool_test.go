@@ -0,0 +1,53 @@
+package messaging
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestWorkerPool_Order(t *testing.T) {
+	p := newWorkerPool(4, 1000)
+
+	mu := sync.Mutex{}
+	received := map[string][]int{}
+	for i := 0; i < 100; i++ {
+		for u := 0; u < 5; u++ {
+			uid, seq := strconv.Itoa(u), i
+			assert.NoError(t, p.submit(uid, func() {
+				mu.Lock()
+				received[uid] = append(received[uid], seq)
+				mu.Unlock()
+			}))
+		}
+	}
+	// panic does not kill the worker.
+	assert.NoError(t, p.submit("0", func() {
+		panic("test")
+	}))
+	p.close()
+
+	for u := 0; u < 5; u++ {
+		seqs := received[strconv.Itoa(u)]
+		assert.Len(t, seqs, 100)
+		for i, seq := range seqs {
+			assert.Equal(t, i, seq)
+		}
+	}
+}
+
+func TestWorkerPool_QueueFull(t *testing.T) {
+	p := newWorkerPool(1, 1)
+	block := make(chan struct{})
+	_ = p.submit("1", func() { <-block })
+	_ = p.submit("1", func() {})
+
+	var err error
+	for i := 0; i < 2 && err == nil; i++ {
+		err = p.submit("1", func() {})
+	}
+	assert.EqualError(t, err, errWorkerQueueFull)
+	close(block)
+	p.close()
+}
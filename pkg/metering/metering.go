@@ -0,0 +1,214 @@
+// Package metering counts the usage of users, such as messages, bytes, connection time and push notifications, the
+// usage is aggregated by user in memory and flushed to sinks periodically for billing and abuse detection.
+package metering
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+var log = logger.Named("metering")
+
+const defaultInterval = time.Minute
+
+// Usage the usage of a user in the period of Report.
+type Usage struct {
+	Tenant string `json:"tenant,omitempty"`
+	Uid    string `json:"uid"`
+	// Messages the count of chat and channel messages sent.
+	Messages int64 `json:"messages"`
+	// Bytes the bytes written to connections of the user.
+	Bytes int64 `json:"bytes"`
+	// ConnectionSeconds the seconds of connections of the user, counted when the connection is closed.
+	ConnectionSeconds int64 `json:"connection_seconds"`
+	// Pushes the count of push notifications sent to devices of the user.
+	Pushes int64 `json:"pushes"`
+}
+
+// Report the usages of users in the period from Start to End, in unix seconds.
+type Report struct {
+	Start  int64    `json:"start"`
+	End    int64    `json:"end"`
+	Usages []*Usage `json:"usages"`
+}
+
+// Sink writes usage reports, such as PrometheusSink, ClickHouseSink and WebhookSink.
+type Sink interface {
+	Write(r *Report) error
+}
+
+// Options of Meter.
+type Options struct {
+	// Interval the interval of flushing usages to sinks, default 1 minute.
+	Interval time.Duration
+	// Tenant resolves the tenant of the user, nil to meter without tenant, see PrefixTenant.
+	Tenant func(uid string) string
+	Sinks  []Sink
+}
+
+// Meter aggregates usages of users and flushes to sinks every Interval.
+type Meter struct {
+	opts Options
+
+	mu     sync.Mutex
+	start  time.Time
+	usages map[string]*Usage
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New creates the meter, at least one sink is required.
+func New(opts *Options) (*Meter, error) {
+	if len(opts.Sinks) == 0 {
+		return nil, errors.New("no metering sink")
+	}
+	o := *opts
+	if o.Interval <= 0 {
+		o.Interval = defaultInterval
+	}
+	return &Meter{
+		opts:   o,
+		start:  time.Now(),
+		usages: map[string]*Usage{},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start flushes usages every Interval in background.
+func (m *Meter) Start() {
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.Flush()
+			}
+		}
+	}()
+}
+
+// Stop stops the background flushing and flushes the usages left.
+func (m *Meter) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+		<-m.done
+		m.Flush()
+	})
+}
+
+// AddMessages adds the count of messages sent by the user.
+func (m *Meter) AddMessages(uid string, n int64) {
+	m.add(uid, func(u *Usage) { u.Messages += n })
+}
+
+// AddBytes adds the bytes written to connections of the user.
+func (m *Meter) AddBytes(uid string, n int64) {
+	m.add(uid, func(u *Usage) { u.Bytes += n })
+}
+
+// AddConnection adds the duration of a connection of the user.
+func (m *Meter) AddConnection(uid string, d time.Duration) {
+	m.add(uid, func(u *Usage) { u.ConnectionSeconds += int64(d / time.Second) })
+}
+
+// AddPushes adds the count of push notifications sent to the user.
+func (m *Meter) AddPushes(uid string, n int64) {
+	m.add(uid, func(u *Usage) { u.Pushes += n })
+}
+
+func (m *Meter) add(uid string, fn func(u *Usage)) {
+	if uid == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.usages[uid]
+	if !ok {
+		u = &Usage{Uid: uid}
+		if m.opts.Tenant != nil {
+			u.Tenant = m.opts.Tenant(uid)
+		}
+		m.usages[uid] = u
+	}
+	fn(u)
+}
+
+// Flush writes usages since the last flush to sinks and resets the usages, errors of sinks are logged.
+func (m *Meter) Flush() {
+	m.mu.Lock()
+	now := time.Now()
+	r := &Report{Start: m.start.Unix(), End: now.Unix()}
+	for _, u := range m.usages {
+		r.Usages = append(r.Usages, u)
+	}
+	m.start = now
+	m.usages = map[string]*Usage{}
+	m.mu.Unlock()
+
+	if len(r.Usages) == 0 {
+		return
+	}
+	for _, s := range m.opts.Sinks {
+		if err := s.Write(r); err != nil {
+			log.E("write usage report of %d users error: %v", len(r.Usages), err)
+		}
+	}
+}
+
+// PrefixTenant returns the tenant resolver takes the part of uid before the separator as the tenant, empty if the uid
+// has no separator.
+func PrefixTenant(separator string) func(uid string) string {
+	return func(uid string) string {
+		i := strings.Index(uid, separator)
+		if i < 0 {
+			return ""
+		}
+		return uid[:i]
+	}
+}
+
+var defaultMeter *Meter
+
+// SetDefault sets the meter used by package functions, nil to disable metering.
+func SetDefault(m *Meter) {
+	defaultMeter = m
+}
+
+// Messages adds the messages sent by the user to the default meter, it does nothing if not set.
+func Messages(uid string, n int64) {
+	if defaultMeter != nil {
+		defaultMeter.AddMessages(uid, n)
+	}
+}
+
+// Bytes adds the bytes written to the user to the default meter, it does nothing if not set.
+func Bytes(uid string, n int64) {
+	if defaultMeter != nil {
+		defaultMeter.AddBytes(uid, n)
+	}
+}
+
+// Connection adds the duration of a connection of the user to the default meter, it does nothing if not set.
+func Connection(uid string, d time.Duration) {
+	if defaultMeter != nil {
+		defaultMeter.AddConnection(uid, d)
+	}
+}
+
+// Pushes adds the push notifications sent to the user to the default meter, it does nothing if not set.
+func Pushes(uid string, n int64) {
+	if defaultMeter != nil {
+		defaultMeter.AddPushes(uid, n)
+	}
+}
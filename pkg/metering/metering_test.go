@@ -0,0 +1,96 @@
+package metering
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+type reportSink struct {
+	reports []*Report
+}
+
+func (s *reportSink) Write(r *Report) error {
+	s.reports = append(s.reports, r)
+	return nil
+}
+
+func TestMeter_Flush(t *testing.T) {
+	_, err := New(&Options{})
+	assert.Error(t, err)
+
+	s := &reportSink{}
+	m, err := New(&Options{Sinks: []Sink{s}, Tenant: PrefixTenant(":")})
+	assert.NoError(t, err)
+
+	m.AddMessages("acme:1", 2)
+	m.AddBytes("acme:1", 100)
+	m.AddConnection("2", time.Minute)
+	m.AddPushes("2", 1)
+	m.AddMessages("", 1)
+	m.Flush()
+	// nothing to flush.
+	m.Flush()
+
+	assert.Len(t, s.reports, 1)
+	usages := s.reports[0].Usages
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].Uid < usages[j].Uid
+	})
+	assert.Equal(t, []*Usage{
+		{Uid: "2", ConnectionSeconds: 60, Pushes: 1},
+		{Tenant: "acme", Uid: "acme:1", Messages: 2, Bytes: 100},
+	}, usages)
+}
+
+func TestMeter_Stop(t *testing.T) {
+	s := &reportSink{}
+	m, err := New(&Options{Sinks: []Sink{s}, Interval: time.Hour})
+	assert.NoError(t, err)
+	m.Start()
+	SetDefault(m)
+	defer SetDefault(nil)
+
+	Messages("1", 1)
+	m.Stop()
+	m.Stop()
+	assert.Len(t, s.reports, 1)
+}
+
+func TestPrometheusSink(t *testing.T) {
+	s, err := NewPrometheusSink(prometheus.NewRegistry())
+	assert.NoError(t, err)
+	assert.NoError(t, s.Write(&Report{Usages: []*Usage{{Tenant: "a", Uid: "a:1", Messages: 2}, {Tenant: "a", Uid: "a:2", Messages: 3}}}))
+	assert.Equal(t, float64(5), testutil.ToFloat64(s.messages.WithLabelValues("a")))
+}
+
+func TestClickHouseSink(t *testing.T) {
+	var query string
+	var rows []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			row := map[string]interface{}{}
+			_ = json.Unmarshal(scanner.Bytes(), &row)
+			rows = append(rows, row)
+		}
+	}))
+	defer server.Close()
+
+	s, err := NewClickHouseSink(server.URL, "usage")
+	assert.NoError(t, err)
+	assert.NoError(t, s.Write(&Report{Start: 1, End: 2, Usages: []*Usage{{Uid: "1", Bytes: 10}}}))
+	assert.Equal(t, "INSERT INTO usage FORMAT JSONEachRow", query)
+	assert.Equal(t, []map[string]interface{}{{
+		"start": float64(1), "end": float64(2), "uid": "1", "messages": float64(0), "bytes": float64(10),
+		"connection_seconds": float64(0), "pushes": float64(0),
+	}}, rows)
+}
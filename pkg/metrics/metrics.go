@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "glide"
+
+// Registry is the registry of all glide metrics, embedders can register their own collectors to it.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Connections the count of active client connections.
+	Connections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "connections",
+		Help: "The count of active client connections.",
+	})
+	Connects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "connects_total",
+		Help: "The total count of client connected.",
+	})
+	Disconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "disconnects_total",
+		Help: "The total count of client disconnected.",
+	})
+	ConnectionsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "connections_rejected_total",
+		Help: "The total count of client connections rejected by admission, by reason.",
+	}, []string{"reason"})
+	Challenges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "challenges_total",
+		Help: "The total count of anti-abuse challenge answers by challenge type and result.",
+	}, []string{"type", "result"})
+	AnomalyVerdicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "anomaly_verdicts_total",
+		Help: "The total count of connections scored by the anomaly detector by stage and action.",
+	}, []string{"stage", "action"})
+	Resumes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "resumes_total",
+		Help: "The total count of sessions parked, resumed, expired and resume failures by result.",
+	}, []string{"result"})
+	EnqueueFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "enqueue_failures_total",
+		Help: "The total count of messages failed to enqueue to client.",
+	})
+	// QueuedMessages the count of messages waiting in send queues of all clients.
+	QueuedMessages = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "queued_messages",
+		Help: "The count of messages waiting in client send queues.",
+	})
+	QueueOverflows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "queue_overflows_total",
+		Help: "The total count of client send queue overflows by overflow policy.",
+	}, []string{"policy"})
+	SlowClients = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "slow_clients_total",
+		Help: "The total count of slow clients detected by watchdog, by reason and whether evicted.",
+	}, []string{"reason", "evicted"})
+	HandleQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "messaging", Name: "handle_queued",
+		Help: "The count of messages waiting in worker queues to be handled.",
+	})
+	PoolGets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "pool", Name: "gets_total",
+		Help: "The total count of objects got from pool by pool name.",
+	}, []string{"pool"})
+	PoolAllocs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "pool", Name: "allocs_total",
+		Help: "The total count of objects allocated because the pool is empty by pool name.",
+	}, []string{"pool"})
+	AuthFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "auth_failures_total",
+		Help: "The total count of client authentication and message ticket failures.",
+	})
+	// SignFailures the total count of messages rejected by the sign verifier, reason is missing, invalid or no_secret.
+	SignFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "sign_failures_total",
+		Help: "The total count of messages rejected by the message sign verification.",
+	}, []string{"reason"})
+	// MessagesOut the total count of messages written to client by action.
+	MessagesOut = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "messages_out_total",
+		Help: "The total count of messages written to client.",
+	}, []string{"action"})
+
+	// ActionsRejected the total count of messages from client rejected by the action, reason is internal or unknown.
+	ActionsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "gateway", Name: "actions_rejected_total",
+		Help: "The total count of messages from client rejected by the action.",
+	}, []string{"reason"})
+
+	// MessagesIn the total count of messages handled by action, unhandled action is counted as "unknown".
+	MessagesIn = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "messaging", Name: "messages_in_total",
+		Help: "The total count of messages received from client.",
+	}, []string{"action"})
+	HandleLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "messaging", Name: "handle_seconds",
+		Help:    "The latency of handling message.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"action"})
+	// FilterHits the total count of messages matched by message filter rules.
+	FilterHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "messaging", Name: "filter_hits_total",
+		Help: "The total count of messages matched by filter rules.",
+	}, []string{"rule", "action"})
+	// QuotaExceeded the total count of messages rejected as the sender exceeds the quota, by quota name.
+	QuotaExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "messaging", Name: "quota_exceeded_total",
+		Help: "The total count of messages rejected by quotas.",
+	}, []string{"quota"})
+	// DeadLetters the total count of undeliverable messages dead lettered, by reason.
+	DeadLetters = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "messaging", Name: "dead_letters_total",
+		Help: "The total count of undeliverable messages dead lettered, by reason: expired, retries_exhausted or no_receiver.",
+	}, []string{"reason"})
+
+	// FanoutLatency the latency of pushing a channel message to all subscribers.
+	FanoutLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "subscription", Name: "fanout_seconds",
+		Help:    "The latency of pushing channel message to subscribers.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	})
+	// ChannelDrops the total count of messages dropped by overloaded live rooms.
+	ChannelDrops = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "subscription", Name: "dropped_messages_total",
+		Help: "The total count of messages dropped by overloaded live rooms.",
+	})
+
+	// StoreFailures the total count of store calls failed after retries, by reason.
+	StoreFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "store", Name: "failures_total",
+		Help: "The total count of store calls failed after retries, by reason: error, timeout or circuit_open.",
+	}, []string{"reason"})
+	// StoreCircuitOpen 1 if the circuit breaker of the store is open.
+	StoreCircuitOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "store", Name: "circuit_open",
+		Help: "1 if the circuit breaker of the store is open, calls fail fast.",
+	})
+	// RetentionPurged the total count of messages purged by retention policies, by scope of the policy.
+	RetentionPurged = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "store", Name: "retention_purged_total",
+		Help: "The total count of messages purged by retention policies, by scope: default, tenant or channel.",
+	}, []string{"scope"})
+	// RetentionSweepSeconds the duration of the last complete retention sweep of all conversations.
+	RetentionSweepSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "store", Name: "retention_sweep_seconds",
+		Help: "The duration of the last complete retention sweep of all conversations.",
+	})
+	// FederationMessages the total count of messages exchanged with other regions, by direction and result.
+	FederationMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "federation", Name: "messages_total",
+		Help: "The total count of messages exchanged with other regions, by direction: out or in, and result.",
+	}, []string{"direction", "result"})
+	// Degraded 1 if the service is in degraded mode.
+	Degraded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace, Name: "degraded",
+		Help: "1 if the service is in degraded mode for unhealthy dependencies.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Connections, Connects, Disconnects, ConnectionsRejected, Challenges, AnomalyVerdicts, Resumes, EnqueueFailures, QueuedMessages, QueueOverflows, SlowClients, PoolGets, PoolAllocs, AuthFailures, SignFailures, MessagesOut,
+		ActionsRejected,
+		MessagesIn, HandleLatency, HandleQueued, FilterHits, QuotaExceeded, DeadLetters,
+		FanoutLatency, ChannelDrops,
+		StoreFailures, StoreCircuitOpen, RetentionPurged, RetentionSweepSeconds, FederationMessages, Degraded,
+	)
+}
+
+// Handler returns the http handler exposes metrics in Prometheus format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve starts http server serves metrics at addr/metrics, it blocks until server stopped.
+func Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return http.ListenAndServe(addr, mux)
+}
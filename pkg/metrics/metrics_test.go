@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	Connections.Inc()
+	MessagesIn.WithLabelValues("message.chat").Inc()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	assert.True(t, strings.Contains(string(body), "glide_gateway_connections 1"))
+	assert.True(t, strings.Contains(string(body), `glide_messaging_messages_in_total{action="message.chat"} 1`))
+}
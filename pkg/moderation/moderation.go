@@ -0,0 +1,158 @@
+package moderation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/glide-im/glide/pkg/logger"
+	"net/http"
+	"time"
+)
+
+var log = logger.Named("moderation")
+
+// Actions of the moderation verdict.
+const (
+	ActionAllow  = "allow"
+	ActionReject = "reject"
+	// ActionModify replaces the message content with Verdict.Content.
+	ActionModify = "modify"
+)
+
+// Mode decides when the moderator is invoked.
+type Mode int
+
+const (
+	// ModeSync moderates the message before it's stored and delivered, the message can be rejected or modified.
+	ModeSync Mode = iota
+	// ModeAsync moderates the message after it's delivered, the rejected message is recalled.
+	ModeAsync
+)
+
+// FailPolicy decides the verdict when the moderation service is unavailable.
+type FailPolicy int
+
+const (
+	// FailOpen allows the message when moderation failed.
+	FailOpen FailPolicy = iota
+	// FailClosed rejects the message when moderation failed.
+	FailClosed
+)
+
+const defaultHTTPTimeout = time.Second * 2
+
+const ReasonUnavailable = "moderation unavailable"
+
+// Request is the message to moderate.
+type Request struct {
+	Mid          int64  `json:"mid,omitempty"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Conversation string `json:"conversation"`
+	Type         int32  `json:"type"`
+	Content      string `json:"content"`
+}
+
+// Verdict is the moderation result.
+type Verdict struct {
+	Action string `json:"action"`
+	// Reason the reason of rejection, returned to the sender.
+	Reason string `json:"reason,omitempty"`
+	// Content the new content of the message when Action is ActionModify.
+	Content string `json:"content,omitempty"`
+}
+
+// Allowed returns true if the message is not rejected.
+func (v *Verdict) Allowed() bool {
+	return v == nil || v.Action != ActionReject
+}
+
+// Moderator moderates messages before or after delivery.
+type Moderator interface {
+	Moderate(r *Request) (*Verdict, error)
+}
+
+type HTTPOptions struct {
+	// URL the moderation api, the Request is posted as json, and the Verdict is expected in the response.
+	URL string
+	// Timeout the http request timeout, default 2s.
+	Timeout time.Duration
+	// FailPolicy the verdict when the api failed or timeout, default FailOpen.
+	FailPolicy FailPolicy
+	// Headers the extra headers of the request, such as authorization.
+	Headers map[string]string
+}
+
+var _ Moderator = (*HTTPModerator)(nil)
+
+// HTTPModerator moderates messages by an external http api, it never returns error, failures are resolved by
+// FailPolicy.
+type HTTPModerator struct {
+	url        string
+	failPolicy FailPolicy
+	headers    map[string]string
+	client     *http.Client
+}
+
+func NewHTTPModerator(opts *HTTPOptions) (*HTTPModerator, error) {
+	if opts.URL == "" {
+		return nil, errors.New("moderation url is empty")
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	return &HTTPModerator{
+		url:        opts.URL,
+		failPolicy: opts.FailPolicy,
+		headers:    opts.Headers,
+		client:     &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (h *HTTPModerator) Moderate(r *Request) (*Verdict, error) {
+	v, err := h.request(r)
+	if err == nil {
+		return v, nil
+	}
+	log.E("moderate message from %s error: %v", r.From, err)
+	if h.failPolicy == FailClosed {
+		return &Verdict{Action: ActionReject, Reason: ReasonUnavailable}, nil
+	}
+	return &Verdict{Action: ActionAllow}, nil
+}
+
+func (h *HTTPModerator) request(r *Request) (*Verdict, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range h.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("unexpected status %d", resp.StatusCode))
+	}
+	v := &Verdict{}
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return nil, err
+	}
+	switch v.Action {
+	case ActionAllow, ActionReject, ActionModify:
+		return v, nil
+	default:
+		return nil, errors.New("unknown verdict action: " + v.Action)
+	}
+}
@@ -0,0 +1,43 @@
+package moderation
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPModerator_Moderate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &Request{}
+		_ = json.NewDecoder(r.Body).Decode(req)
+		switch req.Content {
+		case "bad":
+			_, _ = w.Write([]byte(`{"action":"reject","reason":"spam"}`))
+		case "slow":
+			time.Sleep(time.Millisecond * 200)
+		default:
+			_, _ = w.Write([]byte(`{"action":"allow"}`))
+		}
+	}))
+	defer srv.Close()
+
+	m, err := NewHTTPModerator(&HTTPOptions{URL: srv.URL, Timeout: time.Millisecond * 100})
+	assert.NoError(t, err)
+
+	v, _ := m.Moderate(&Request{Content: "hello"})
+	assert.True(t, v.Allowed())
+	v, _ = m.Moderate(&Request{Content: "bad"})
+	assert.Equal(t, &Verdict{Action: string(ActionReject), Reason: "spam"}, v)
+
+	// fail open
+	v, _ = m.Moderate(&Request{Content: "slow"})
+	assert.True(t, v.Allowed())
+
+	m.failPolicy = FailClosed
+	v, _ = m.Moderate(&Request{Content: "slow"})
+	assert.False(t, v.Allowed())
+	assert.Equal(t, ReasonUnavailable, v.Reason)
+}
@@ -0,0 +1,183 @@
+// Package ordered runs tasks of different keys concurrently and tasks of the same key serially in the order submitted,
+// such as messages of a conversation, without a goroutine per key or head-of-line blocking between keys.
+package ordered
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultMaxPending = 1024
+
+const errQueueFull = "ordered queue of the key is full"
+
+// ErrQueueFull returned by Submit when the count of tasks waiting of the key reaches Options.MaxPending.
+var ErrQueueFull = errors.New(errQueueFull)
+
+// Options of Executor.
+type Options struct {
+	// MaxPending the max count of tasks waiting to run of a key, default 1024.
+	MaxPending int
+	// PanicHandler is called with the value recovered when a task panics, the rest tasks of the key keep running.
+	PanicHandler func(i interface{})
+}
+
+type queue struct {
+	// tasks waiting to run, the first is running if the drain goroutine started.
+	tasks []func()
+	// space is closed when a task is done, created by SubmitWait waiting for room, nil if no one is waiting.
+	space chan struct{}
+}
+
+// Executor runs the tasks of a key one by one in the goroutine started by the submit function, the goroutine exits
+// once the queue of the key is drained, it's safe for concurrent use.
+type Executor struct {
+	submit     func(task func()) error
+	maxPending int
+	onPanic    func(i interface{})
+
+	mu     sync.Mutex
+	queues map[string]*queue
+}
+
+// NewExecutor creates the Executor, submit runs the function in a new goroutine such as the Submit of ants pool, it
+// returns error if the goroutine can not be started.
+func NewExecutor(submit func(task func()) error, opts *Options) *Executor {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxPending <= 0 {
+		o.MaxPending = defaultMaxPending
+	}
+	return &Executor{
+		submit:     submit,
+		maxPending: o.MaxPending,
+		onPanic:    o.PanicHandler,
+		queues:     map[string]*queue{},
+	}
+}
+
+// Submit enqueues the task of key, the task runs after all tasks of the key submitted before are done.
+func (e *Executor) Submit(key string, task func()) error {
+	return e.enqueue(key, task, false)
+}
+
+// SubmitEvict is Submit but the oldest task waiting of the key is dropped to make room if the queue is full, the task
+// running is never dropped.
+func (e *Executor) SubmitEvict(key string, task func()) error {
+	return e.enqueue(key, task, true)
+}
+
+// SubmitWait is Submit but waits for room up to timeout if the queue of the key is full, ErrQueueFull is returned
+// after timeout.
+func (e *Executor) SubmitWait(key string, task func(), timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		err := e.enqueue(key, task, false)
+		if err != ErrQueueFull {
+			return err
+		}
+		e.mu.Lock()
+		q, ok := e.queues[key]
+		if !ok || len(q.tasks) < e.maxPending {
+			e.mu.Unlock()
+			continue
+		}
+		if q.space == nil {
+			q.space = make(chan struct{})
+		}
+		space := q.space
+		e.mu.Unlock()
+
+		select {
+		case <-space:
+		case <-timer.C:
+			return ErrQueueFull
+		}
+	}
+}
+
+func (e *Executor) enqueue(key string, task func(), evict bool) error {
+	e.mu.Lock()
+	q, ok := e.queues[key]
+	if ok {
+		if len(q.tasks) >= e.maxPending {
+			if !evict || len(q.tasks) < 2 {
+				e.mu.Unlock()
+				return ErrQueueFull
+			}
+			q.tasks = append(q.tasks[:1], q.tasks[2:]...)
+		}
+		q.tasks = append(q.tasks, task)
+		e.mu.Unlock()
+		return nil
+	}
+	q = &queue{tasks: []func(){task}}
+	e.queues[key] = q
+	e.mu.Unlock()
+
+	err := e.submit(func() {
+		e.drain(key, q)
+	})
+	if err == nil {
+		return nil
+	}
+
+	// tasks submitted meanwhile are accepted, they run in the caller as the drain goroutine is not started.
+	e.mu.Lock()
+	q.tasks = q.tasks[1:]
+	if len(q.tasks) == 0 {
+		delete(e.queues, key)
+		e.mu.Unlock()
+		return err
+	}
+	e.mu.Unlock()
+	e.drain(key, q)
+	return err
+}
+
+// Pending returns the count of tasks of key not done.
+func (e *Executor) Pending(key string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if q, ok := e.queues[key]; ok {
+		return len(q.tasks)
+	}
+	return 0
+}
+
+func (e *Executor) drain(key string, q *queue) {
+	for {
+		e.mu.Lock()
+		if len(q.tasks) == 0 {
+			delete(e.queues, key)
+			e.mu.Unlock()
+			return
+		}
+		task := q.tasks[0]
+		e.mu.Unlock()
+
+		e.exec(task)
+
+		e.mu.Lock()
+		q.tasks[0] = nil
+		q.tasks = q.tasks[1:]
+		if q.space != nil {
+			close(q.space)
+			q.space = nil
+		}
+		e.mu.Unlock()
+	}
+}
+
+func (e *Executor) exec(task func()) {
+	defer func() {
+		if i := recover(); i != nil && e.onPanic != nil {
+			e.onPanic(i)
+		}
+	}()
+	task()
+}
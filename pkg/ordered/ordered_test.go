@@ -0,0 +1,119 @@
+package ordered
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func goSubmit(task func()) error {
+	go task()
+	return nil
+}
+
+func TestExecutor_Submit(t *testing.T) {
+	e := NewExecutor(goSubmit, nil)
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	got := map[string][]int{}
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i % 10)
+		n := i
+		wg.Add(1)
+		assert.NoError(t, e.Submit(key, func() {
+			defer wg.Done()
+			mu.Lock()
+			got[key] = append(got[key], n)
+			mu.Unlock()
+		}))
+	}
+	wg.Wait()
+	for key, ns := range got {
+		assert.Len(t, ns, 100)
+		for i := 1; i < len(ns); i++ {
+			assert.Less(t, ns[i-1], ns[i], key)
+		}
+	}
+	assert.Equal(t, 0, e.Pending("1"))
+}
+
+func TestExecutor_QueueFull(t *testing.T) {
+	e := NewExecutor(goSubmit, &Options{MaxPending: 2})
+	block := make(chan struct{})
+	done := make(chan struct{})
+	assert.NoError(t, e.Submit("a", func() { <-block }))
+	assert.NoError(t, e.Submit("a", func() { close(done) }))
+	assert.ErrorIs(t, e.Submit("a", func() {}), ErrQueueFull)
+	assert.NoError(t, e.Submit("b", func() {}))
+	assert.Equal(t, 2, e.Pending("a"))
+	close(block)
+	<-done
+}
+
+func TestExecutor_Panic(t *testing.T) {
+	var recovered interface{}
+	e := NewExecutor(goSubmit, &Options{PanicHandler: func(i interface{}) { recovered = i }})
+	done := make(chan struct{})
+	assert.NoError(t, e.Submit("a", func() { panic("boom") }))
+	assert.NoError(t, e.Submit("a", func() { close(done) }))
+	<-done
+	assert.Equal(t, "boom", recovered)
+
+	e = NewExecutor(func(task func()) error { return errors.New("busy") }, nil)
+	assert.Error(t, e.Submit("a", func() {}))
+	assert.Equal(t, 0, e.Pending("a"))
+}
+
+func TestExecutor_SubmitFailed(t *testing.T) {
+	var e *Executor
+	ran := false
+	e = NewExecutor(func(task func()) error {
+		// the task of the key submitted while starting the drain goroutine is accepted.
+		assert.NoError(t, e.Submit("a", func() { ran = true }))
+		return errors.New("busy")
+	}, nil)
+	assert.Error(t, e.Submit("a", func() { t.Fatal("the task failed to submit runs") }))
+	assert.True(t, ran)
+	assert.Equal(t, 0, e.Pending("a"))
+}
+
+func TestExecutor_SubmitEvict(t *testing.T) {
+	e := NewExecutor(goSubmit, &Options{MaxPending: 3})
+	block := make(chan struct{})
+	done := make(chan struct{})
+	var ran []int
+	assert.NoError(t, e.Submit("a", func() { <-block }))
+	assert.NoError(t, e.Submit("a", func() { ran = append(ran, 1) }))
+	assert.NoError(t, e.Submit("a", func() { ran = append(ran, 2) }))
+	assert.NoError(t, e.SubmitEvict("a", func() { ran = append(ran, 3); close(done) }))
+	assert.Equal(t, 3, e.Pending("a"))
+	close(block)
+	<-done
+	assert.Equal(t, []int{2, 3}, ran)
+
+	e = NewExecutor(goSubmit, &Options{MaxPending: 1})
+	block = make(chan struct{})
+	defer close(block)
+	assert.NoError(t, e.Submit("a", func() { <-block }))
+	// the running task is not dropped.
+	assert.ErrorIs(t, e.SubmitEvict("a", func() {}), ErrQueueFull)
+}
+
+func TestExecutor_SubmitWait(t *testing.T) {
+	e := NewExecutor(goSubmit, &Options{MaxPending: 1})
+	block := make(chan struct{})
+	done := make(chan struct{})
+	assert.NoError(t, e.Submit("a", func() { <-block }))
+	assert.ErrorIs(t, e.SubmitWait("a", func() {}, time.Millisecond*10), ErrQueueFull)
+
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		close(block)
+	}()
+	assert.NoError(t, e.SubmitWait("a", func() { close(done) }, time.Second))
+	<-done
+}
@@ -0,0 +1,60 @@
+// Package pool provides sync.Pool based object pools of the read/write path, the count of gets and allocations of
+// each pool are reported to metrics, the hit ratio is 1 - allocs/gets.
+package pool
+
+import (
+	"bytes"
+	"github.com/glide-im/glide/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+)
+
+// MaxBufferSize the buffer larger than it should not be put back to pool, avoid holding memory of occasional large messages.
+const MaxBufferSize = 64 * 1024
+
+var buffers = New("buffer", func() interface{} {
+	return new(bytes.Buffer)
+})
+
+// Pool is a sync.Pool reports stats to metrics.
+type Pool struct {
+	p      sync.Pool
+	gets   prometheus.Counter
+	allocs prometheus.Counter
+}
+
+// New creates a pool with the name as metrics label, fn allocates a new object when the pool is empty.
+func New(name string, fn func() interface{}) *Pool {
+	ret := &Pool{
+		gets:   metrics.PoolGets.WithLabelValues(name),
+		allocs: metrics.PoolAllocs.WithLabelValues(name),
+	}
+	ret.p.New = func() interface{} {
+		ret.allocs.Inc()
+		return fn()
+	}
+	return ret
+}
+
+func (p *Pool) Get() interface{} {
+	p.gets.Inc()
+	return p.p.Get()
+}
+
+func (p *Pool) Put(x interface{}) {
+	p.p.Put(x)
+}
+
+// GetBuffer returns an empty buffer from pool, it must be put back by PutBuffer after used.
+func GetBuffer() *bytes.Buffer {
+	return buffers.Get().(*bytes.Buffer)
+}
+
+// PutBuffer resets the buffer and puts it back to pool, the bytes of buffer must not be used after put.
+func PutBuffer(b *bytes.Buffer) {
+	if b.Cap() > MaxBufferSize {
+		return
+	}
+	b.Reset()
+	buffers.Put(b)
+}
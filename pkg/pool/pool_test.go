@@ -0,0 +1,32 @@
+package pool
+
+import (
+	"github.com/glide-im/glide/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPool_Stats(t *testing.T) {
+	p := New("test", func() interface{} {
+		return new(int)
+	})
+	i := p.Get().(*int)
+	p.Put(i)
+	_ = p.Get()
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.PoolGets.WithLabelValues("test")))
+	assert.LessOrEqual(t, testutil.ToFloat64(metrics.PoolAllocs.WithLabelValues("test")), float64(2))
+}
+
+func TestPutBuffer(t *testing.T) {
+	b := GetBuffer()
+	b.WriteString("hello")
+	PutBuffer(b)
+	assert.Equal(t, 0, GetBuffer().Len())
+
+	large := GetBuffer()
+	large.Grow(MaxBufferSize + 1)
+	PutBuffer(large)
+	assert.Equal(t, 0, GetBuffer().Len())
+}
@@ -0,0 +1,159 @@
+package push
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	apnsProductionEndpoint = "https://api.push.apple.com"
+	apnsSandboxEndpoint    = "https://api.sandbox.push.apple.com"
+
+	// apnsTokenRefresh APNs rejects provider token older than one hour.
+	apnsTokenRefresh = time.Minute * 50
+)
+
+var _ PushProvider = (*APNsProvider)(nil)
+
+type APNsOptions struct {
+	// KeyID the key id of the APNs auth key.
+	KeyID string
+	// TeamID the apple developer team id.
+	TeamID string
+	// Topic the bundle id of the app.
+	Topic string
+	// PrivateKey the PEM content of .p8 APNs auth key.
+	PrivateKey []byte
+	// Sandbox true to push to development environment.
+	Sandbox bool
+	// Endpoint overrides the APNs server url.
+	Endpoint string
+}
+
+// APNsProvider pushes notification through Apple Push Notification service HTTP/2 API with token-based auth.
+type APNsProvider struct {
+	keyID    string
+	teamID   string
+	topic    string
+	endpoint string
+	key      *ecdsa.PrivateKey
+	client   *http.Client
+
+	mu      sync.Mutex
+	token   string
+	tokenAt time.Time
+}
+
+func NewAPNsProvider(opts *APNsOptions) (*APNsProvider, error) {
+	key, err := jwt.ParseECPrivateKeyFromPEM(opts.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	ret := &APNsProvider{
+		keyID:    opts.KeyID,
+		teamID:   opts.TeamID,
+		topic:    opts.Topic,
+		endpoint: opts.Endpoint,
+		key:      key,
+		client:   &http.Client{Timeout: time.Second * 10},
+	}
+	if ret.endpoint == "" {
+		ret.endpoint = apnsProductionEndpoint
+		if opts.Sandbox {
+			ret.endpoint = apnsSandboxEndpoint
+		}
+	}
+	return ret, nil
+}
+
+func (a *APNsProvider) Platform() string {
+	return PlatformAPNs
+}
+
+func (a *APNsProvider) Push(token string, n *Notification) error {
+	authToken, err := a.authToken()
+	if err != nil {
+		return err
+	}
+
+	alert := map[string]interface{}{
+		"title": n.Title,
+		"body":  n.Body,
+	}
+	aps := map[string]interface{}{"alert": alert}
+	if n.Badge > 0 {
+		aps["badge"] = n.Badge
+	}
+	if n.Sound != "" {
+		aps["sound"] = n.Sound
+	}
+	payload := map[string]interface{}{"aps": aps}
+	for k, v := range n.Data {
+		payload[k] = v
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, a.endpoint+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("authorization", "bearer "+authToken)
+	req.Header.Set("apns-topic", a.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	reason := struct {
+		Reason string `json:"reason"`
+	}{}
+	_ = json.NewDecoder(resp.Body).Decode(&reason)
+	if resp.StatusCode == http.StatusGone || reason.Reason == "BadDeviceToken" || reason.Reason == "Unregistered" {
+		return ErrInvalidToken
+	}
+	if reason.Reason == "ExpiredProviderToken" {
+		a.mu.Lock()
+		a.token = ""
+		a.mu.Unlock()
+	}
+	return errors.New(fmt.Sprintf("apns: %d %s", resp.StatusCode, reason.Reason))
+}
+
+// authToken returns the cached provider token, refresh it if expired.
+func (a *APNsProvider) authToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Since(a.tokenAt) < apnsTokenRefresh {
+		return a.token, nil
+	}
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": a.teamID,
+		"iat": now.Unix(),
+	})
+	t.Header["kid"] = a.keyID
+	signed, err := t.SignedString(a.key)
+	if err != nil {
+		return "", err
+	}
+	a.token = signed
+	a.tokenAt = now
+	return signed, nil
+}
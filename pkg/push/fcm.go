@@ -0,0 +1,186 @@
+package push
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fcmEndpoint = "https://fcm.googleapis.com"
+	fcmTokenURL = "https://oauth2.googleapis.com/token"
+	fcmScope    = "https://www.googleapis.com/auth/firebase.messaging"
+)
+
+var _ PushProvider = (*FCMProvider)(nil)
+
+type FCMOptions struct {
+	// Credentials the content of firebase service account json file.
+	Credentials []byte
+	// Endpoint overrides the FCM server url.
+	Endpoint string
+}
+
+// serviceAccount the fields used in google service account json file.
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// FCMProvider pushes notification through Firebase Cloud Messaging HTTP v1 API with service account auth.
+type FCMProvider struct {
+	projectID   string
+	clientEmail string
+	tokenURL    string
+	endpoint    string
+	key         *rsa.PrivateKey
+	client      *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expireAt    time.Time
+}
+
+func NewFCMProvider(opts *FCMOptions) (*FCMProvider, error) {
+	sa := serviceAccount{}
+	err := json.Unmarshal(opts.Credentials, &sa)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(sa.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+	ret := &FCMProvider{
+		projectID:   sa.ProjectID,
+		clientEmail: sa.ClientEmail,
+		tokenURL:    sa.TokenURI,
+		endpoint:    opts.Endpoint,
+		key:         key,
+		client:      &http.Client{Timeout: time.Second * 10},
+	}
+	if ret.tokenURL == "" {
+		ret.tokenURL = fcmTokenURL
+	}
+	if ret.endpoint == "" {
+		ret.endpoint = fcmEndpoint
+	}
+	return ret, nil
+}
+
+func (f *FCMProvider) Platform() string {
+	return PlatformFCM
+}
+
+func (f *FCMProvider) Push(token string, n *Notification) error {
+	accessToken, err := f.token()
+	if err != nil {
+		return err
+	}
+
+	message := map[string]interface{}{
+		"token": token,
+		"notification": map[string]string{
+			"title": n.Title,
+			"body":  n.Body,
+		},
+		"data": n.Data,
+	}
+	if n.Sound != "" {
+		message["android"] = map[string]interface{}{
+			"notification": map[string]string{"sound": n.Sound},
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{"message": message})
+	if err != nil {
+		return err
+	}
+
+	u := f.endpoint + "/v1/projects/" + f.projectID + "/messages:send"
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	e := struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}{}
+	_ = json.NewDecoder(resp.Body).Decode(&e)
+	if resp.StatusCode == http.StatusNotFound || e.Error.Status == "UNREGISTERED" || e.Error.Status == "NOT_FOUND" {
+		return ErrInvalidToken
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		f.mu.Lock()
+		f.accessToken = ""
+		f.mu.Unlock()
+	}
+	return errors.New(fmt.Sprintf("fcm: %d %s", resp.StatusCode, e.Error.Message))
+}
+
+// token returns the cached oauth2 access token, exchange a new one with service account if expired.
+func (f *FCMProvider) token() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.accessToken != "" && time.Now().Before(f.expireAt) {
+		return f.accessToken, nil
+	}
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   f.clientEmail,
+		"scope": fcmScope,
+		"aud":   f.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(f.key)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	resp, err := f.client.Post(f.tokenURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(fmt.Sprintf("fcm: exchange access token failed: %d", resp.StatusCode))
+	}
+	t := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&t)
+	if err != nil {
+		return "", err
+	}
+	f.accessToken = t.AccessToken
+	// refresh one minute before expiration.
+	f.expireAt = now.Add(time.Duration(t.ExpiresIn)*time.Second - time.Minute)
+	return f.accessToken, nil
+}
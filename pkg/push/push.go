@@ -0,0 +1,508 @@
+package push
+
+import (
+	"bytes"
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metering"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+var log = logger.Named("push")
+
+const (
+	PlatformAPNs = "apns"
+	PlatformFCM  = "fcm"
+)
+
+// ErrInvalidToken is returned by PushProvider when the device token is invalid or unregistered, the device will be
+// removed from DeviceStore.
+var ErrInvalidToken = errors.New("invalid device token")
+
+const (
+	defaultTitleTemplate = "{{.From}}"
+	defaultBodyTemplate  = "{{.Content}}"
+	defaultMaxBodyLength = 200
+	defaultWorkers       = 4
+	defaultQueueSize     = 1024
+)
+
+// Notification is the platform independent push notification.
+type Notification struct {
+	Title string
+	Body  string
+	Badge int
+	Sound string
+	// Data the custom key-values delivered to the app with notification.
+	Data map[string]string
+}
+
+// Device is a registered push device of user.
+type Device struct {
+	// Platform apns or fcm.
+	Platform string
+	// Token the device token from APNs or registration token from FCM.
+	Token string
+}
+
+// PushProvider sends notification to devices of a platform.
+type PushProvider interface {
+
+	// Platform returns the platform of devices this provider supports.
+	Platform() string
+
+	// Push sends the notification to the device, returns ErrInvalidToken if the device token is no longer valid.
+	Push(token string, n *Notification) error
+}
+
+// DeviceStore stores push devices of users.
+type DeviceStore interface {
+
+	// AddDevice registers the device to the user, the device registered with the same token is replaced.
+	AddDevice(uid string, d Device) error
+
+	GetDevices(uid string) ([]Device, error)
+
+	RemoveDevice(uid string, token string) error
+}
+
+// Settings the push preferences of user.
+type Settings struct {
+	// MuteAll true to disable push notification of all conversations.
+	MuteAll bool `json:"mute_all,omitempty"`
+	// Muted the muted conversation ids.
+	Muted map[string]bool `json:"muted,omitempty"`
+	// DNDStart and DNDEnd the do-not-disturb period in minutes of day, the period crosses midnight if DNDStart is
+	// greater than DNDEnd, disabled if they are equal.
+	DNDStart int `json:"dnd_start,omitempty"`
+	DNDEnd   int `json:"dnd_end,omitempty"`
+	// TimezoneOffset the seconds east of UTC of user's timezone, used to calculate the do-not-disturb period.
+	TimezoneOffset int `json:"timezone_offset,omitempty"`
+}
+
+// Allows returns true if the push notification of the conversation is allowed at time t.
+func (s *Settings) Allows(c conversation.ID, t time.Time) bool {
+	if s.MuteAll || s.Muted[string(c)] {
+		return false
+	}
+	return !s.inDND(t)
+}
+
+// AllowsMention returns true if the push notification of the mention in the conversation is allowed at time t, the
+// mention is pushed even if the conversation is muted.
+func (s *Settings) AllowsMention(t time.Time) bool {
+	return !s.MuteAll && !s.inDND(t)
+}
+
+func (s *Settings) inDND(t time.Time) bool {
+	if s.DNDStart == s.DNDEnd {
+		return false
+	}
+	local := t.UTC().Add(time.Duration(s.TimezoneOffset) * time.Second)
+	minute := local.Hour()*60 + local.Minute()
+	if s.DNDStart < s.DNDEnd {
+		return minute >= s.DNDStart && minute < s.DNDEnd
+	}
+	return minute >= s.DNDStart || minute < s.DNDEnd
+}
+
+// SettingsStore stores push settings of users.
+type SettingsStore interface {
+
+	// GetSettings returns the push settings of the user, nil if the user has no settings.
+	GetSettings(uid string) (*Settings, error)
+
+	// SetSettings replaces the push settings of the user.
+	SetSettings(uid string, s *Settings) error
+}
+
+// BadgeStore counts unread messages pushed to users by conversation, the badge of notification is the total count.
+type BadgeStore interface {
+
+	// Incr increases the unread count of the conversation, returns the total unread count of the user.
+	Incr(uid string, conversation string) (int, error)
+
+	// Clear resets the unread count of the conversation.
+	Clear(uid string, conversation string) error
+}
+
+// TemplateData is the data to render notification title and body templates.
+type TemplateData struct {
+	From         string
+	To           string
+	Conversation string
+	Type         int32
+	Content      string
+}
+
+type Options struct {
+	// Devices the push devices of users, required.
+	Devices DeviceStore
+	// Settings the mute and do-not-disturb settings of users, default MemSettingsStore.
+	Settings SettingsStore
+	// Badges the unread counter of notification badge, optional, the badge is not set if nil.
+	Badges BadgeStore
+	// TitleTemplate the text/template of notification title, default "{{.From}}".
+	TitleTemplate string
+	// BodyTemplate the text/template of notification body, default "{{.Content}}".
+	BodyTemplate string
+	// MaxBodyLength the max runes of notification body, the exceeded is truncated, default 200.
+	MaxBodyLength int
+	// Sound the notification sound, empty for silent.
+	Sound string
+	// Workers the count of goroutines to send notifications, default 4.
+	Workers int
+	// QueueSize the max count of pending notifications, the new one is dropped when the queue is full, default 1024.
+	QueueSize int
+}
+
+type task struct {
+	uid          string
+	conversation conversation.ID
+	msg          *messages.ChatMessage
+	// mention the user is mentioned in the message, it's pushed even if the conversation is muted.
+	mention bool
+}
+
+// Bridge pushes messages to offline users through PushProvider of their devices.
+type Bridge struct {
+	devices  DeviceStore
+	settings SettingsStore
+	badges   BadgeStore
+
+	title   *template.Template
+	body    *template.Template
+	maxBody int
+	sound   string
+
+	mu        sync.RWMutex
+	providers map[string]PushProvider
+
+	queue chan *task
+	wg    sync.WaitGroup
+}
+
+func NewBridge(opts *Options) (*Bridge, error) {
+	if opts.Devices == nil {
+		return nil, errors.New("device store is required")
+	}
+	titleTmpl := opts.TitleTemplate
+	if titleTmpl == "" {
+		titleTmpl = defaultTitleTemplate
+	}
+	bodyTmpl := opts.BodyTemplate
+	if bodyTmpl == "" {
+		bodyTmpl = defaultBodyTemplate
+	}
+	title, err := template.New("title").Parse(titleTmpl)
+	if err != nil {
+		return nil, err
+	}
+	body, err := template.New("body").Parse(bodyTmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Bridge{
+		devices:   opts.Devices,
+		settings:  opts.Settings,
+		badges:    opts.Badges,
+		title:     title,
+		body:      body,
+		maxBody:   opts.MaxBodyLength,
+		sound:     opts.Sound,
+		providers: map[string]PushProvider{},
+	}
+	if ret.settings == nil {
+		ret.settings = NewMemSettingsStore()
+	}
+	if ret.maxBody <= 0 {
+		ret.maxBody = defaultMaxBodyLength
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	ret.queue = make(chan *task, queueSize)
+	for i := 0; i < workers; i++ {
+		ret.wg.Add(1)
+		go ret.run()
+	}
+	return ret, nil
+}
+
+// Devices returns the device store of the bridge.
+func (b *Bridge) Devices() DeviceStore {
+	return b.devices
+}
+
+// Settings returns the settings store of the bridge.
+func (b *Bridge) Settings() SettingsStore {
+	return b.settings
+}
+
+// ClearBadge resets the unread count of the conversation of user, called when the user reads the conversation.
+func (b *Bridge) ClearBadge(uid string, c conversation.ID) error {
+	if b.badges == nil {
+		return nil
+	}
+	return b.badges.Clear(uid, string(c))
+}
+
+// AddProvider registers the provider for its platform, replace the existing one.
+func (b *Bridge) AddProvider(p PushProvider) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.providers[p.Platform()] = p
+}
+
+// Notify pushes the message to devices of the offline user asynchronously.
+func (b *Bridge) Notify(uid string, c conversation.ID, msg *messages.ChatMessage) {
+	select {
+	case b.queue <- &task{uid: uid, conversation: c, msg: msg}:
+	default:
+		log.W("push queue is full, notification to %s dropped", uid)
+	}
+}
+
+// NotifyMention pushes the message mentioned the offline user asynchronously, it's pushed even if the conversation is
+// muted.
+func (b *Bridge) NotifyMention(uid string, c conversation.ID, msg *messages.ChatMessage) {
+	select {
+	case b.queue <- &task{uid: uid, conversation: c, msg: msg, mention: true}:
+	default:
+		log.W("push queue is full, notification to %s dropped", uid)
+	}
+}
+
+// Close stops accepting notifications and waits for pending notifications sent.
+func (b *Bridge) Close() {
+	close(b.queue)
+	b.wg.Wait()
+}
+
+func (b *Bridge) run() {
+	defer b.wg.Done()
+	for t := range b.queue {
+		err := b.push(t.uid, t.conversation, t.msg, t.mention)
+		if err != nil {
+			log.E("push notification to %s error: %v", t.uid, err)
+		}
+	}
+}
+
+// push sends the notification to devices of user, muted conversations and messages in do-not-disturb period are
+// neither pushed nor counted to the badge, mentions are pushed in muted conversations.
+func (b *Bridge) push(uid string, c conversation.ID, msg *messages.ChatMessage, mention bool) error {
+	s, err := b.settings.GetSettings(uid)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		if mention && !s.AllowsMention(time.Now()) {
+			return nil
+		}
+		if !mention && !s.Allows(c, time.Now()) {
+			return nil
+		}
+	}
+	devices, err := b.devices.GetDevices(uid)
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return nil
+	}
+	n, err := b.render(c, msg)
+	if err != nil {
+		return err
+	}
+	if b.badges != nil {
+		n.Badge, err = b.badges.Incr(uid, string(c))
+		if err != nil {
+			log.E("increase badge of %s error: %v", uid, err)
+		}
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, device := range devices {
+		p, ok := b.providers[device.Platform]
+		if !ok {
+			continue
+		}
+		err = p.Push(device.Token, n)
+		if err == ErrInvalidToken {
+			log.D("remove invalid %s device of %s", device.Platform, uid)
+			_ = b.devices.RemoveDevice(uid, device.Token)
+		} else if err != nil {
+			log.E("push to %s device of %s error: %v", device.Platform, uid, err)
+		} else {
+			metering.Pushes(uid, 1)
+		}
+	}
+	return nil
+}
+
+func (b *Bridge) render(c conversation.ID, msg *messages.ChatMessage) (*Notification, error) {
+	data := TemplateData{
+		From:         msg.From,
+		To:           msg.To,
+		Conversation: string(c),
+		Type:         msg.Type,
+		Content:      msg.Content,
+	}
+	title := bytes.Buffer{}
+	err := b.title.Execute(&title, &data)
+	if err != nil {
+		return nil, err
+	}
+	body := bytes.Buffer{}
+	err = b.body.Execute(&body, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &Notification{
+		Title: title.String(),
+		Body:  truncate(body.String(), b.maxBody),
+		Sound: b.sound,
+		Data: map[string]string{
+			"conversation": string(c),
+			"from":         msg.From,
+			"mid":          strconv.FormatInt(msg.Mid, 10),
+		},
+	}, nil
+}
+
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:max]) + "..."
+}
+
+var _ DeviceStore = (*MemDeviceStore)(nil)
+
+// MemDeviceStore is a DeviceStore in memory.
+type MemDeviceStore struct {
+	mu      sync.RWMutex
+	devices map[string][]Device
+}
+
+func NewMemDeviceStore() *MemDeviceStore {
+	return &MemDeviceStore{devices: map[string][]Device{}}
+}
+
+func (m *MemDeviceStore) AddDevice(uid string, d Device) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ds := m.devices[uid]
+	for i, device := range ds {
+		if device.Token == d.Token {
+			ds[i] = d
+			return nil
+		}
+	}
+	m.devices[uid] = append(ds, d)
+	return nil
+}
+
+func (m *MemDeviceStore) GetDevices(uid string) ([]Device, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Device{}, m.devices[uid]...), nil
+}
+
+func (m *MemDeviceStore) RemoveDevice(uid string, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ds := m.devices[uid]
+	for i, device := range ds {
+		if device.Token == token {
+			m.devices[uid] = append(ds[:i], ds[i+1:]...)
+			break
+		}
+	}
+	if len(m.devices[uid]) == 0 {
+		delete(m.devices, uid)
+	}
+	return nil
+}
+
+var _ SettingsStore = (*MemSettingsStore)(nil)
+
+// MemSettingsStore is a SettingsStore in memory.
+type MemSettingsStore struct {
+	mu       sync.RWMutex
+	settings map[string]*Settings
+}
+
+func NewMemSettingsStore() *MemSettingsStore {
+	return &MemSettingsStore{settings: map[string]*Settings{}}
+}
+
+func (m *MemSettingsStore) SetSettings(uid string, s *Settings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings[uid] = s
+	return nil
+}
+
+func (m *MemSettingsStore) GetSettings(uid string) (*Settings, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.settings[uid], nil
+}
+
+var _ BadgeStore = (*MemBadgeStore)(nil)
+
+// MemBadgeStore is a BadgeStore in memory.
+type MemBadgeStore struct {
+	mu     sync.Mutex
+	unread map[string]map[string]int
+}
+
+func NewMemBadgeStore() *MemBadgeStore {
+	return &MemBadgeStore{unread: map[string]map[string]int{}}
+}
+
+func (m *MemBadgeStore) Incr(uid string, conversation string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.unread[uid]
+	if !ok {
+		u = map[string]int{}
+		m.unread[uid] = u
+	}
+	u[conversation]++
+	total := 0
+	for _, n := range u {
+		total += n
+	}
+	return total, nil
+}
+
+func (m *MemBadgeStore) Clear(uid string, conversation string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.unread[uid], conversation)
+	if len(m.unread[uid]) == 0 {
+		delete(m.unread, uid)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package push
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockProvider struct {
+	mu      sync.Mutex
+	pushed  map[string][]*Notification
+	invalid map[string]bool
+}
+
+func (m *mockProvider) Platform() string {
+	return PlatformFCM
+}
+
+func (m *mockProvider) Push(token string, n *Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.invalid[token] {
+		return ErrInvalidToken
+	}
+	m.pushed[token] = append(m.pushed[token], n)
+	return nil
+}
+
+func TestSettings_Allows(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	c := conversation.NewP2P("1", "2").ID
+	muted := conversation.NewChannel("muted").ID
+	s := Settings{DNDStart: 22 * 60, DNDEnd: 8 * 60}
+	assert.False(t, s.Allows(c, at(23, 0)))
+	assert.False(t, s.Allows(c, at(7, 59)))
+	assert.True(t, s.Allows(c, at(8, 0)))
+
+	// 22:00 in UTC+8 is 14:00 UTC.
+	s.TimezoneOffset = 8 * 3600
+	assert.False(t, s.Allows(c, at(14, 0)))
+
+	s = Settings{Muted: map[string]bool{string(muted): true}}
+	assert.False(t, s.Allows(muted, at(12, 0)))
+	assert.True(t, s.Allows(c, at(12, 0)))
+	// mentions are allowed in muted conversations, but not when all muted.
+	assert.True(t, s.AllowsMention(at(12, 0)))
+	s.MuteAll = true
+	assert.False(t, s.AllowsMention(at(12, 0)))
+}
+
+func TestBridge_Notify(t *testing.T) {
+	devices := NewMemDeviceStore()
+	_ = devices.AddDevice("1", Device{Platform: PlatformFCM, Token: "t1"})
+	_ = devices.AddDevice("1", Device{Platform: PlatformFCM, Token: "t2"})
+	_ = devices.AddDevice("2", Device{Platform: PlatformFCM, Token: "t3"})
+	settings := NewMemSettingsStore()
+	settings.SetSettings("2", &Settings{MuteAll: true})
+
+	b, err := NewBridge(&Options{
+		Devices:       devices,
+		Settings:      settings,
+		TitleTemplate: "New message from {{.From}}",
+		MaxBodyLength: 5,
+	})
+	assert.NoError(t, err)
+	p := &mockProvider{pushed: map[string][]*Notification{}, invalid: map[string]bool{"t2": true}}
+	b.AddProvider(p)
+
+	c := conversation.NewP2P("1", "2").ID
+	b.Notify("1", c, &messages.ChatMessage{From: "2", To: "1", Content: "hello world", Mid: 10})
+	b.Notify("2", c, &messages.ChatMessage{From: "1", To: "2", Content: "hi"})
+	b.Close()
+
+	assert.Len(t, p.pushed["t1"], 1)
+	n := p.pushed["t1"][0]
+	assert.Equal(t, "New message from 2", n.Title)
+	assert.Equal(t, "hello...", n.Body)
+	assert.Equal(t, "10", n.Data["mid"])
+	assert.Empty(t, p.pushed["t3"])
+
+	ds, _ := devices.GetDevices("1")
+	assert.Equal(t, []Device{{Platform: PlatformFCM, Token: "t1"}}, ds)
+}
+
+func TestBridge_DefaultSettings(t *testing.T) {
+	devices := NewMemDeviceStore()
+	_ = devices.AddDevice("1", Device{Platform: PlatformFCM, Token: "t1"})
+	b, err := NewBridge(&Options{Devices: devices, Workers: 1})
+	assert.NoError(t, err)
+	p := &mockProvider{pushed: map[string][]*Notification{}}
+	b.AddProvider(p)
+
+	muted := conversation.NewChannel("muted").ID
+	assert.NoError(t, b.Settings().SetSettings("1", &Settings{Muted: map[string]bool{string(muted): true}}))
+	b.Notify("1", muted, &messages.ChatMessage{From: "2", To: "1"})
+	b.Notify("1", conversation.NewChannel("a").ID, &messages.ChatMessage{From: "2", To: "1"})
+	b.Close()
+	assert.Len(t, p.pushed["t1"], 1)
+}
+
+func TestBridge_Badge(t *testing.T) {
+	devices := NewMemDeviceStore()
+	_ = devices.AddDevice("1", Device{Platform: PlatformFCM, Token: "t1"})
+	a, b2, muted := conversation.NewChannel("a").ID, conversation.NewChannel("b").ID, conversation.NewChannel("muted").ID
+	settings := NewMemSettingsStore()
+	_ = settings.SetSettings("1", &Settings{Muted: map[string]bool{string(muted): true}})
+	badges := NewMemBadgeStore()
+
+	b, err := NewBridge(&Options{Devices: devices, Settings: settings, Badges: badges, Workers: 1})
+	assert.NoError(t, err)
+	p := &mockProvider{pushed: map[string][]*Notification{}}
+	b.AddProvider(p)
+
+	b.Notify("1", a, &messages.ChatMessage{From: "2", To: "1"})
+	b.Notify("1", muted, &messages.ChatMessage{From: "3", To: "1"})
+	b.Notify("1", b2, &messages.ChatMessage{From: "4", To: "1"})
+	b.Close()
+
+	assert.Len(t, p.pushed["t1"], 2)
+	assert.Equal(t, 1, p.pushed["t1"][0].Badge)
+	assert.Equal(t, 2, p.pushed["t1"][1].Badge)
+
+	// the muted conversation is not counted.
+	assert.NoError(t, b.ClearBadge("1", a))
+	n, _ := badges.Incr("1", string(b2))
+	assert.Equal(t, 2, n)
+}
+
+func TestAPNsProvider_Push(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	var payload map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("authorization"), "bearer "))
+		assert.Equal(t, "com.example.app", r.Header.Get("apns-topic"))
+		if r.URL.Path == "/3/device/gone" {
+			w.WriteHeader(http.StatusGone)
+			_, _ = w.Write([]byte(`{"reason":"Unregistered"}`))
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer srv.Close()
+
+	p, err := NewAPNsProvider(&APNsOptions{
+		KeyID:      "key",
+		TeamID:     "team",
+		Topic:      "com.example.app",
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		Endpoint:   srv.URL,
+	})
+	assert.NoError(t, err)
+
+	err = p.Push("token", &Notification{Title: "t", Body: "b", Data: map[string]string{"mid": "1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", payload["mid"])
+	assert.Equal(t, "b", payload["aps"].(map[string]interface{})["alert"].(map[string]interface{})["body"])
+
+	assert.Equal(t, ErrInvalidToken, p.Push("gone", &Notification{}))
+}
+
+func TestFCMProvider_Push(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	tokenExchanged := 0
+	var payload map[string]map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenExchanged++
+		_, _ = w.Write([]byte(`{"access_token":"access","expires_in":3600}`))
+	})
+	mux.HandleFunc("/v1/projects/project/messages:send", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		if payload["message"]["token"] == "gone" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":"UNREGISTERED"}}`))
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	credentials, _ := json.Marshal(&serviceAccount{
+		ProjectID:   "project",
+		ClientEmail: "push@project.iam.gserviceaccount.com",
+		PrivateKey:  string(keyPem),
+		TokenURI:    srv.URL + "/token",
+	})
+	p, err := NewFCMProvider(&FCMOptions{Credentials: credentials, Endpoint: srv.URL})
+	assert.NoError(t, err)
+
+	err = p.Push("token", &Notification{Title: "t", Body: "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "token", payload["message"]["token"])
+
+	assert.Equal(t, ErrInvalidToken, p.Push("gone", &Notification{}))
+	assert.Equal(t, 1, tokenExchanged)
+}
@@ -0,0 +1,110 @@
+package push
+
+import (
+	"encoding/json"
+	"github.com/go-redis/redis"
+	"strconv"
+)
+
+const (
+	redisKeyDevicePrefix   = "im:push:devices:"
+	redisKeySettingsPrefix = "im:push:settings:"
+	redisKeyBadgePrefix    = "im:push:badge:"
+)
+
+var _ DeviceStore = (*RedisDeviceStore)(nil)
+
+// RedisDeviceStore stores push devices of user in redis hash, token => platform.
+type RedisDeviceStore struct {
+	client *redis.Client
+}
+
+func NewRedisDeviceStore(client *redis.Client) *RedisDeviceStore {
+	return &RedisDeviceStore{client: client}
+}
+
+func (r *RedisDeviceStore) AddDevice(uid string, d Device) error {
+	return r.client.HSet(redisKeyDevicePrefix+uid, d.Token, d.Platform).Err()
+}
+
+func (r *RedisDeviceStore) GetDevices(uid string) ([]Device, error) {
+	m, err := r.client.HGetAll(redisKeyDevicePrefix + uid).Result()
+	if err != nil {
+		return nil, err
+	}
+	var ret []Device
+	for token, platform := range m {
+		ret = append(ret, Device{Platform: platform, Token: token})
+	}
+	return ret, nil
+}
+
+func (r *RedisDeviceStore) RemoveDevice(uid string, token string) error {
+	return r.client.HDel(redisKeyDevicePrefix+uid, token).Err()
+}
+
+var _ SettingsStore = (*RedisSettingsStore)(nil)
+
+// RedisSettingsStore stores push settings of user in redis as json.
+type RedisSettingsStore struct {
+	client *redis.Client
+}
+
+func NewRedisSettingsStore(client *redis.Client) *RedisSettingsStore {
+	return &RedisSettingsStore{client: client}
+}
+
+func (r *RedisSettingsStore) GetSettings(uid string) (*Settings, error) {
+	b, err := r.client.Get(redisKeySettingsPrefix + uid).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &Settings{}
+	if err = json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *RedisSettingsStore) SetSettings(uid string, s *Settings) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(redisKeySettingsPrefix+uid, b, 0).Err()
+}
+
+var _ BadgeStore = (*RedisBadgeStore)(nil)
+
+// RedisBadgeStore stores unread counts of user in redis hash, conversation => count.
+type RedisBadgeStore struct {
+	client *redis.Client
+}
+
+func NewRedisBadgeStore(client *redis.Client) *RedisBadgeStore {
+	return &RedisBadgeStore{client: client}
+}
+
+func (r *RedisBadgeStore) Incr(uid string, conversation string) (int, error) {
+	key := redisKeyBadgePrefix + uid
+	if err := r.client.HIncrBy(key, conversation, 1).Err(); err != nil {
+		return 0, err
+	}
+	counts, err := r.client.HVals(key).Result()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, c := range counts {
+		n, _ := strconv.Atoi(c)
+		total += n
+	}
+	return total, nil
+}
+
+func (r *RedisBadgeStore) Clear(uid string, conversation string) error {
+	return r.client.HDel(redisKeyBadgePrefix+uid, conversation).Err()
+}
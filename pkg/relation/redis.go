@@ -0,0 +1,62 @@
+package relation
+
+import (
+	"github.com/go-redis/redis"
+)
+
+const (
+	redisKeyBlockedPrefix  = "im:relation:blocked:"
+	redisKeyContactsPrefix = "im:relation:contacts:"
+)
+
+var _ RelationProvider = (*RedisProvider)(nil)
+var _ ContactProvider = (*RedisProvider)(nil)
+
+// RedisProvider stores the block list and contacts of user in redis set, the business server can maintain them by
+// methods of the provider, or by writing the set `im:relation:blocked:<uid>` and `im:relation:contacts:<uid>`
+// directly.
+type RedisProvider struct {
+	client *redis.Client
+}
+
+func NewRedisProvider(client *redis.Client) *RedisProvider {
+	return &RedisProvider{client: client}
+}
+
+// Block adds uid to the block list of user by.
+func (r *RedisProvider) Block(by string, uid string) error {
+	return r.client.SAdd(redisKeyBlockedPrefix+by, uid).Err()
+}
+
+// Unblock removes uid from the block list of user by.
+func (r *RedisProvider) Unblock(by string, uid string) error {
+	return r.client.SRem(redisKeyBlockedPrefix+by, uid).Err()
+}
+
+func (r *RedisProvider) IsBlocked(uid string, by string) (bool, error) {
+	return r.client.SIsMember(redisKeyBlockedPrefix+by, uid).Result()
+}
+
+// AddContact adds each other as contacts.
+func (r *RedisProvider) AddContact(uid string, peer string) error {
+	_, err := r.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.SAdd(redisKeyContactsPrefix+uid, peer)
+		pipe.SAdd(redisKeyContactsPrefix+peer, uid)
+		return nil
+	})
+	return err
+}
+
+// RemoveContact removes each other from contacts.
+func (r *RedisProvider) RemoveContact(uid string, peer string) error {
+	_, err := r.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.SRem(redisKeyContactsPrefix+uid, peer)
+		pipe.SRem(redisKeyContactsPrefix+peer, uid)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisProvider) IsContact(uid string, peer string) (bool, error) {
+	return r.client.SIsMember(redisKeyContactsPrefix+uid, peer).Result()
+}
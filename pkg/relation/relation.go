@@ -0,0 +1,103 @@
+// Package relation provides relations between users consulted by the message router, such as the block list.
+package relation
+
+import (
+	"sync"
+)
+
+// RelationProvider provides relations of users, relations are usually maintained by the business server.
+type RelationProvider interface {
+
+	// IsBlocked returns true if uid is blocked by the user by, messages from uid are not delivered to by.
+	IsBlocked(uid string, by string) (bool, error)
+}
+
+// ContactProvider is the optional extension of RelationProvider provides contacts of users, it's required when
+// messages are allowed between contacts only.
+type ContactProvider interface {
+
+	// IsContact returns true if peer is a contact of uid.
+	IsContact(uid string, peer string) (bool, error)
+}
+
+var _ RelationProvider = (*MemProvider)(nil)
+var _ ContactProvider = (*MemProvider)(nil)
+
+// MemProvider is a RelationProvider and ContactProvider in memory.
+type MemProvider struct {
+	mu sync.RWMutex
+	// blocked the users blocked by user, user => blocked users.
+	blocked map[string]map[string]struct{}
+	// contacts the contacts of user, user => contacts.
+	contacts map[string]map[string]struct{}
+}
+
+func NewMemProvider() *MemProvider {
+	return &MemProvider{
+		blocked:  map[string]map[string]struct{}{},
+		contacts: map[string]map[string]struct{}{},
+	}
+}
+
+// Block adds uid to the block list of user by.
+func (m *MemProvider) Block(by string, uid string) error {
+	m.add(m.blocked, by, uid)
+	return nil
+}
+
+// Unblock removes uid from the block list of user by.
+func (m *MemProvider) Unblock(by string, uid string) error {
+	m.remove(m.blocked, by, uid)
+	return nil
+}
+
+func (m *MemProvider) IsBlocked(uid string, by string) (bool, error) {
+	return m.contains(m.blocked, by, uid), nil
+}
+
+// AddContact adds each other as contacts.
+func (m *MemProvider) AddContact(uid string, peer string) error {
+	m.add(m.contacts, uid, peer)
+	m.add(m.contacts, peer, uid)
+	return nil
+}
+
+// RemoveContact removes each other from contacts.
+func (m *MemProvider) RemoveContact(uid string, peer string) error {
+	m.remove(m.contacts, uid, peer)
+	m.remove(m.contacts, peer, uid)
+	return nil
+}
+
+func (m *MemProvider) IsContact(uid string, peer string) (bool, error) {
+	return m.contains(m.contacts, uid, peer), nil
+}
+
+func (m *MemProvider) add(set map[string]map[string]struct{}, uid string, member string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := set[uid]
+	if !ok {
+		s = map[string]struct{}{}
+		set[uid] = s
+	}
+	s[member] = struct{}{}
+}
+
+func (m *MemProvider) remove(set map[string]map[string]struct{}, uid string, member string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(set[uid], member)
+	if len(set[uid]) == 0 {
+		delete(set, uid)
+	}
+}
+
+func (m *MemProvider) contains(set map[string]map[string]struct{}, uid string, member string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := set[uid][member]
+	return ok
+}
@@ -0,0 +1,34 @@
+package relation
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMemProvider(t *testing.T) {
+	p := NewMemProvider()
+	assert.NoError(t, p.Block("1", "2"))
+
+	blocked, err := p.IsBlocked("2", "1")
+	assert.NoError(t, err)
+	assert.True(t, blocked)
+	blocked, _ = p.IsBlocked("1", "2")
+	assert.False(t, blocked)
+
+	assert.NoError(t, p.Unblock("1", "2"))
+	blocked, _ = p.IsBlocked("2", "1")
+	assert.False(t, blocked)
+}
+
+func TestMemProvider_Contact(t *testing.T) {
+	p := NewMemProvider()
+	assert.NoError(t, p.AddContact("1", "2"))
+
+	ok, err := p.IsContact("2", "1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	assert.NoError(t, p.RemoveContact("2", "1"))
+	ok, _ = p.IsContact("1", "2")
+	assert.False(t, ok)
+}
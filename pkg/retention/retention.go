@@ -0,0 +1,254 @@
+// Package retention purges message history exceeding retention policies, policies are configured per channel, per
+// tenant and by default, such as keeping messages for 30 days or keeping the latest 10000 messages of a conversation.
+package retention
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/metrics"
+	"github.com/glide-im/glide/pkg/store"
+	"sync"
+	"time"
+)
+
+var log = logger.Named("retention")
+
+const (
+	defaultInterval  = time.Hour
+	defaultBatchSize = 1000
+	defaultRateLimit = 1000
+)
+
+const (
+	ScopeDefault = "default"
+	ScopeTenant  = "tenant"
+	ScopeChannel = "channel"
+)
+
+// Policy the retention policy of conversations, messages exceeding any of limits are purged.
+type Policy struct {
+	// Age messages sent before Age ago are purged, 0 to keep messages regardless of age.
+	Age time.Duration
+	// Messages the count of the latest messages kept in a conversation, 0 for unlimited.
+	Messages int64
+}
+
+func (p *Policy) isZero() bool {
+	return p == nil || p.Age <= 0 && p.Messages <= 0
+}
+
+type Options struct {
+	// Store the message history to purge, required.
+	Store store.RetentionStore
+	// Default the policy of conversations without a channel or tenant policy, nil to keep messages forever.
+	Default *Policy
+	// Channels policies of channel conversations by channel id.
+	Channels map[string]*Policy
+	// Tenants policies of conversations by tenant.
+	Tenants map[string]*Policy
+	// Tenant resolves the tenant of the conversation by the target of conversation ID, such as the P2P conversation
+	// `acme:1_acme:2` or the channel `acme:room`, nil to ignore tenant policies, see metering.PrefixTenant.
+	Tenant func(target string) string
+	// Interval the interval between two sweeps, default 1 hour.
+	Interval time.Duration
+	// BatchSize the count of conversations listed and the max count of messages purged at a time, default 1000.
+	BatchSize int
+	// RateLimit the max count of messages purged per second, default 1000, negative for unlimited.
+	RateLimit int
+}
+
+// Manager sweeps all conversations periodically and purges messages exceeding the policy of each conversation, the
+// sweep is incremental, conversations are listed page by page and the purge is paced by the rate limit to avoid
+// loading the database. A sweep stopped or failed is resumed from the conversation it stopped at. Only one manager
+// should run for a message store.
+type Manager struct {
+	store     store.RetentionStore
+	tenant    func(target string) string
+	interval  time.Duration
+	batchSize int
+	rateLimit int
+
+	mu       sync.RWMutex
+	def      *Policy
+	channels map[string]*Policy
+	tenants  map[string]*Policy
+
+	// sweepMu serializes sweeps, cursor is the last conversation swept of the current sweep.
+	sweepMu    sync.Mutex
+	cursor     conversation.ID
+	sweepStart time.Time
+
+	quit chan struct{}
+}
+
+func New(opts *Options) (*Manager, error) {
+	if opts.Store == nil {
+		return nil, errors.New("store is required")
+	}
+	m := &Manager{
+		store:     opts.Store,
+		tenant:    opts.Tenant,
+		interval:  opts.Interval,
+		batchSize: opts.BatchSize,
+		rateLimit: opts.RateLimit,
+		def:       opts.Default,
+		channels:  copyPolicies(opts.Channels),
+		tenants:   copyPolicies(opts.Tenants),
+		quit:      make(chan struct{}),
+	}
+	if m.interval <= 0 {
+		m.interval = defaultInterval
+	}
+	if m.batchSize <= 0 {
+		m.batchSize = defaultBatchSize
+	}
+	if m.rateLimit == 0 {
+		m.rateLimit = defaultRateLimit
+	}
+	return m, nil
+}
+
+// SetPolicies replaces all policies, such as the policies reloaded from config. def is the default policy, nil to
+// keep messages forever.
+func (m *Manager) SetPolicies(def *Policy, channels map[string]*Policy, tenants map[string]*Policy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.def = def
+	m.channels = copyPolicies(channels)
+	m.tenants = copyPolicies(tenants)
+}
+
+// PolicyOf returns the policy of the conversation and the scope of it, the channel policy takes precedence over the
+// tenant policy, and the tenant policy over the default.
+func (m *Manager) PolicyOf(c conversation.ID) (*Policy, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if c.Type() == conversation.TypeChannel {
+		if p, ok := m.channels[c.Target()]; ok {
+			return p, ScopeChannel
+		}
+	}
+	if m.tenant != nil {
+		if p, ok := m.tenants[m.tenant(c.Target())]; ok {
+			return p, ScopeTenant
+		}
+	}
+	return m.def, ScopeDefault
+}
+
+// Start sweeps periodically until Stop.
+func (m *Manager) Start() {
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			n, err := m.Sweep()
+			if err != nil {
+				log.E("retention sweep error: %v", err)
+			}
+			if n > 0 {
+				log.I("%d messages purged by retention policies", n)
+			}
+			select {
+			case <-ticker.C:
+			case <-m.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (m *Manager) Stop() {
+	close(m.quit)
+}
+
+// Sweep purges messages of all conversations exceeding policies, returns the count of messages purged. It returns
+// when all conversations are swept, the manager is stopped or an error occurred, the next sweep continues from the
+// conversation it returned at.
+func (m *Manager) Sweep() (int64, error) {
+	m.sweepMu.Lock()
+	defer m.sweepMu.Unlock()
+
+	if m.cursor == "" {
+		m.sweepStart = time.Now()
+	}
+	total := int64(0)
+	for {
+		cs, err := m.store.GetConversations(m.cursor, m.batchSize)
+		if err != nil {
+			return total, err
+		}
+		for _, c := range cs {
+			n, err := m.purge(c)
+			total += n
+			if err != nil {
+				return total, err
+			}
+			m.cursor = c
+			if m.stopped() {
+				return total, nil
+			}
+		}
+		if len(cs) < m.batchSize {
+			m.cursor = ""
+			metrics.RetentionSweepSeconds.Set(time.Since(m.sweepStart).Seconds())
+			return total, nil
+		}
+	}
+}
+
+// purge purges messages of the conversation exceeding the policy in batches.
+func (m *Manager) purge(c conversation.ID) (int64, error) {
+	p, scope := m.PolicyOf(c)
+	if p.isZero() {
+		return 0, nil
+	}
+	before := int64(0)
+	if p.Age > 0 {
+		before = time.Now().Add(-p.Age).Unix()
+	}
+	total := int64(0)
+	for {
+		n, err := m.store.PurgeMessages(c, before, p.Messages, m.batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += n
+		metrics.RetentionPurged.WithLabelValues(scope).Add(float64(n))
+		// the next batch is purged if the last batch is full.
+		if !m.wait(n) || n < int64(m.batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// wait paces the purge of n messages by the rate limit, returns false if the manager is stopped.
+func (m *Manager) wait(n int64) bool {
+	if m.rateLimit < 0 || n == 0 {
+		return !m.stopped()
+	}
+	select {
+	case <-time.After(time.Duration(n) * time.Second / time.Duration(m.rateLimit)):
+		return true
+	case <-m.quit:
+		return false
+	}
+}
+
+func copyPolicies(policies map[string]*Policy) map[string]*Policy {
+	ret := make(map[string]*Policy, len(policies))
+	for k, p := range policies {
+		ret[k] = p
+	}
+	return ret
+}
+
+func (m *Manager) stopped() bool {
+	select {
+	case <-m.quit:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,79 @@
+package retention
+
+import (
+	"github.com/glide-im/glide/internal/message_store_file"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metering"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func countOf(t *testing.T, s *message_store_file.MessageStore, c conversation.ID) int {
+	ms, err := s.GetBySeqRange(c, 0, 1000)
+	assert.NoError(t, err)
+	return len(ms)
+}
+
+func TestManager_Sweep(t *testing.T) {
+	s, err := message_store_file.New("")
+	assert.NoError(t, err)
+	old := time.Now().Add(-time.Hour * 48).Unix()
+	for i := int64(1); i <= 10; i++ {
+		sendAt := time.Now().Unix()
+		if i <= 4 {
+			sendAt = old
+		}
+		assert.NoError(t, s.StoreMessage(&messages.ChatMessage{Seq: i, From: "a:1", To: "a:2", SendAt: sendAt}))
+		assert.NoError(t, s.StoreMessage(&messages.ChatMessage{Seq: i, From: "b:1", To: "b:2", SendAt: sendAt}))
+		assert.NoError(t, s.StoreChannelMessage("a:room", &messages.ChatMessage{Seq: i, SendAt: sendAt}))
+		assert.NoError(t, s.StoreChannelMessage(subscription.ChanID("b:room"), &messages.ChatMessage{Seq: i, SendAt: sendAt}))
+	}
+
+	m, err := New(&Options{
+		Store:     s,
+		Default:   &Policy{Messages: 8},
+		Channels:  map[string]*Policy{"a:room": {Messages: 3}},
+		Tenants:   map[string]*Policy{"a": {Age: time.Hour * 24}},
+		Tenant:    metering.PrefixTenant(":"),
+		BatchSize: 1,
+		RateLimit: -1,
+	})
+	assert.NoError(t, err)
+
+	n, err := m.Sweep()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4+2+7+2), n)
+	// the tenant policy keeps messages of the last day.
+	assert.Equal(t, 6, countOf(t, s, conversation.NewP2P("a:1", "a:2").ID))
+	// the default policy keeps the latest 8 messages.
+	assert.Equal(t, 8, countOf(t, s, conversation.NewP2P("b:1", "b:2").ID))
+	// the channel policy takes precedence over the tenant policy.
+	assert.Equal(t, 3, countOf(t, s, conversation.NewChannel("a:room").ID))
+	// the tenant b has no policy, the default policy applies.
+	assert.Equal(t, 8, countOf(t, s, conversation.NewChannel("b:room").ID))
+
+	n, err = m.Sweep()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestManager_SweepStopped(t *testing.T) {
+	s, err := message_store_file.New("")
+	assert.NoError(t, err)
+	for i := int64(1); i <= 3; i++ {
+		assert.NoError(t, s.StoreChannelMessage(subscription.ChanID("1"), &messages.ChatMessage{Seq: i}))
+		assert.NoError(t, s.StoreChannelMessage(subscription.ChanID("2"), &messages.ChatMessage{Seq: i}))
+	}
+	m, err := New(&Options{Store: s, Default: &Policy{Messages: 1}, BatchSize: 1, RateLimit: -1})
+	assert.NoError(t, err)
+	m.Stop()
+
+	// the sweep returns after the first batch once stopped, and continues from the next conversation.
+	n, err := m.Sweep()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, conversation.NewChannel("1").ID, m.cursor)
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/smallnest/rpcx/protocol"
 )
 
+var log = logger.Named("rpc")
+
 type Cli interface {
 	Call(ctx context.Context, fn string, request, reply interface{}) error
 	Broadcast(fn string, request, reply interface{}) error
@@ -74,7 +76,7 @@ func (c *BaseClient) Broadcast(fn string, request, reply interface{}) error {
 }
 
 func (c *BaseClient) Call(ctx context.Context, fn string, arg interface{}, reply interface{}) error {
-	logger.D("rpc call %s, args=%v", fn, arg)
+	log.D("rpc call %s, args=%v", fn, arg)
 	err := c.cli.Call(ctx, fn, arg, reply)
 	return err
 }
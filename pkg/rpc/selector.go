@@ -2,7 +2,6 @@ package rpc
 
 import (
 	"context"
-	"github.com/glide-im/glide/pkg/logger"
 	"github.com/smallnest/rpcx/client"
 	"github.com/smallnest/rpcx/share"
 )
@@ -30,13 +29,13 @@ func (r *selector) Select(ctx context.Context, servicePath, serviceMethod string
 		if _, ok := r.services[target]; ok {
 			return target
 		}
-		logger.E("unknown service addr, ExtraTarget:", target)
+		log.E("unknown service addr, ExtraTarget:", target)
 	}
 
 	if tag, ok := m["ExtraTag"]; ok {
 		if path, ok := r.tags[tag]; ok {
 			if _, ok := r.services[path]; ok {
-				logger.D("route by tag: %s=%s", tag, path)
+				log.D("route by tag: %s=%s", tag, path)
 				return path
 			}
 		}
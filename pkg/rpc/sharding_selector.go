@@ -0,0 +1,47 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/glide-im/glide/pkg/sharding"
+	"github.com/smallnest/rpcx/client"
+	"github.com/smallnest/rpcx/share"
+)
+
+// ExtraShardKey the request extra key of the shard key, such as the uid, the call is routed to the server the key is
+// placed on by the ShardingSelector.
+const ExtraShardKey = "ExtraShardKey"
+
+// ShardingSelector selects the server the shard key of the call is placed on, see sharding.Router, calls without the
+// shard key are routed with round robin. Servers are set to the router as discovered, so rebalance hooks of the router
+// are called when servers join or leave.
+type ShardingSelector struct {
+	router *sharding.Router
+	round  client.Selector
+}
+
+func NewShardingSelector(router *sharding.Router) *ShardingSelector {
+	return &ShardingSelector{router: router, round: NewRoundRobinSelector()}
+}
+
+func (s *ShardingSelector) Select(ctx context.Context, servicePath, serviceMethod string, args interface{}) string {
+	if m, ok := ctx.Value(share.ReqMetaDataKey).(map[string]string); ok {
+		if key, ok := m[ExtraShardKey]; ok {
+			server, err := s.router.Locate(key)
+			if err == nil {
+				return server
+			}
+			log.E("locate server of shard key %s error: %v", key, err)
+		}
+	}
+	return s.round.Select(ctx, servicePath, serviceMethod, args)
+}
+
+func (s *ShardingSelector) UpdateServer(servers map[string]string) {
+	s.round.UpdateServer(servers)
+	nodes := make([]string, 0, len(servers))
+	for k := range servers {
+		nodes = append(nodes, k)
+	}
+	s.router.SetNodes(nodes)
+}
@@ -0,0 +1,184 @@
+package schedule
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/snowflake"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/timingwheel"
+	"sync"
+	"time"
+)
+
+var log = logger.Named("schedule")
+
+const (
+	defaultMaxDelay = time.Hour * 24 * 30
+
+	// horizon messages due in the horizon are loaded into the timing wheel, it must be less than the wheel covers.
+	horizon      = time.Hour
+	loadInterval = time.Minute * 10
+
+	errTooFar = "the deliver time is too far"
+)
+
+// tw the timing wheel of scheduled messages, it covers 8000 seconds in 1 second ticks.
+var tw = timingwheel.NewTimingWheel(time.Second, 3, 20)
+
+// DeliverFunc delivers the scheduled message when it's due.
+type DeliverFunc func(m *store.ScheduledMessage)
+
+type Options struct {
+	// Store persists scheduled messages, default store.MemScheduleStore.
+	Store store.ScheduleStore
+	// MaxDelay the max duration a message can be scheduled ahead, default 30 days.
+	MaxDelay time.Duration
+}
+
+// Scheduler delivers messages at the time scheduled. Messages are persisted to the store, and loaded into the timing
+// wheel when they are due in the horizon, so that messages scheduled are still delivered after restart. Schedulers
+// share the store deliver each message once, the message is claimed by removing from the store.
+type Scheduler struct {
+	store    store.ScheduleStore
+	deliver  DeliverFunc
+	maxDelay time.Duration
+
+	mu    sync.Mutex
+	tasks map[int64]*timingwheel.Task
+	quit  chan struct{}
+}
+
+func New(deliver DeliverFunc, opts *Options) *Scheduler {
+	s := &Scheduler{
+		store:    opts.Store,
+		deliver:  deliver,
+		maxDelay: opts.MaxDelay,
+		tasks:    map[int64]*timingwheel.Task{},
+		quit:     make(chan struct{}),
+	}
+	if s.store == nil {
+		s.store = store.NewMemScheduleStore()
+	}
+	if s.maxDelay <= 0 {
+		s.maxDelay = defaultMaxDelay
+	}
+	return s
+}
+
+// Start loads messages scheduled from the store, and keeps loading messages due periodically until Stop.
+func (s *Scheduler) Start() error {
+	if err := s.load(); err != nil {
+		return err
+	}
+	go s.run()
+	return nil
+}
+
+func (s *Scheduler) Stop() {
+	close(s.quit)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, t := range s.tasks {
+		t.Cancel()
+		delete(s.tasks, id)
+	}
+}
+
+// Schedule schedules the message sent by from to deliver at the time, returns the id of the scheduled message.
+func (s *Scheduler) Schedule(from string, at time.Time, m *messages.GlideMessage) (int64, error) {
+	if time.Until(at) > s.maxDelay {
+		return 0, errors.New(errTooFar)
+	}
+	sm := &store.ScheduledMessage{
+		ID:        snowflake.Generate(),
+		From:      from,
+		DeliverAt: at.Unix(),
+		Message:   m,
+	}
+	if err := s.store.StoreScheduled(sm); err != nil {
+		return 0, err
+	}
+	s.add(sm)
+	return sm.ID, nil
+}
+
+// Cancel cancels the scheduled message of id sent by from, empty from matches all senders, returns false if the
+// message does not exist or has been delivered.
+func (s *Scheduler) Cancel(id int64, from string) (bool, error) {
+	ok, err := s.store.RemoveScheduled(id, from)
+	if err != nil || !ok {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tasks[id]; ok {
+		t.Cancel()
+		delete(s.tasks, id)
+	}
+	return true, nil
+}
+
+func (s *Scheduler) run() {
+	ticker := time.NewTicker(loadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.load(); err != nil {
+				log.E("load scheduled messages error: %v", err)
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// load adds messages due in the horizon into the timing wheel.
+func (s *Scheduler) load() error {
+	ms, err := s.store.GetScheduled(time.Now().Add(horizon).Unix())
+	if err != nil {
+		return err
+	}
+	for _, sm := range ms {
+		s.add(sm)
+	}
+	return nil
+}
+
+// add adds the message into the timing wheel if it's due in the horizon, the message due is delivered immediately.
+func (s *Scheduler) add(sm *store.ScheduledMessage) {
+	d := time.Until(time.Unix(sm.DeliverAt, 0))
+	if d >= horizon {
+		return
+	}
+	if d < time.Second {
+		go s.fire(sm)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[sm.ID]; ok {
+		return
+	}
+	s.tasks[sm.ID] = tw.AfterFunc(d, func() {
+		s.fire(sm)
+	})
+}
+
+func (s *Scheduler) fire(sm *store.ScheduledMessage) {
+	s.mu.Lock()
+	delete(s.tasks, sm.ID)
+	s.mu.Unlock()
+
+	// the message is kept in the store and loaded again if it's failed to remove.
+	ok, err := s.store.RemoveScheduled(sm.ID, "")
+	if err != nil {
+		log.E("remove scheduled message %d error: %v", sm.ID, err)
+		return
+	}
+	if ok {
+		s.deliver(sm)
+	}
+}
@@ -0,0 +1,69 @@
+package schedule
+
+import (
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Schedule(t *testing.T) {
+	delivered := make(chan *store.ScheduledMessage, 4)
+	s := New(func(m *store.ScheduledMessage) {
+		delivered <- m
+	}, &Options{MaxDelay: time.Hour})
+	assert.NoError(t, s.Start())
+	defer s.Stop()
+
+	m := messages.NewMessage(0, messages.ActionChatMessage, nil)
+	id, err := s.Schedule("1", time.Now().Add(time.Second*2), m)
+	assert.NoError(t, err)
+	canceled, err := s.Schedule("1", time.Now().Add(time.Second*2), m)
+	assert.NoError(t, err)
+
+	_, err = s.Schedule("1", time.Now().Add(time.Hour*2), m)
+	assert.Error(t, err)
+
+	// only the sender cancels.
+	ok, _ := s.Cancel(canceled, "2")
+	assert.False(t, ok)
+	ok, _ = s.Cancel(canceled, "1")
+	assert.True(t, ok)
+
+	select {
+	case sm := <-delivered:
+		assert.Equal(t, id, sm.ID)
+		assert.Equal(t, "1", sm.From)
+	case <-time.After(time.Second * 5):
+		t.Fatal("scheduled message not delivered")
+	}
+	select {
+	case sm := <-delivered:
+		t.Fatalf("message %d delivered twice or canceled", sm.ID)
+	case <-time.After(time.Second * 2):
+	}
+}
+
+func TestScheduler_Load(t *testing.T) {
+	st := store.NewMemScheduleStore()
+	_ = st.StoreScheduled(&store.ScheduledMessage{ID: 1, From: "1", DeliverAt: time.Now().Add(-time.Minute).Unix()})
+	_ = st.StoreScheduled(&store.ScheduledMessage{ID: 2, From: "1", DeliverAt: time.Now().Add(time.Hour * 2).Unix()})
+
+	delivered := make(chan int64, 2)
+	s := New(func(m *store.ScheduledMessage) {
+		delivered <- m.ID
+	}, &Options{Store: st})
+	assert.NoError(t, s.Start())
+	defer s.Stop()
+
+	// the message due while the scheduler is not running is delivered on start.
+	select {
+	case id := <-delivered:
+		assert.Equal(t, int64(1), id)
+	case <-time.After(time.Second):
+		t.Fatal("due message not delivered")
+	}
+	ms, _ := st.GetScheduled(time.Now().Add(time.Hour * 3).Unix())
+	assert.Len(t, ms, 1)
+}
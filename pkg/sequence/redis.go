@@ -0,0 +1,22 @@
+package sequence
+
+import (
+	"github.com/go-redis/redis"
+)
+
+const redisKeyPrefix = "im:seq:"
+
+var _ Allocator = (*RedisAllocator)(nil)
+
+// RedisAllocator allocates gap-free sequence by redis INCR, the sequence is shared by all nodes.
+type RedisAllocator struct {
+	client *redis.Client
+}
+
+func NewRedisAllocator(client *redis.Client) *RedisAllocator {
+	return &RedisAllocator{client: client}
+}
+
+func (r *RedisAllocator) Next(conversation string) (int64, error) {
+	return r.client.Incr(redisKeyPrefix + conversation).Result()
+}
@@ -0,0 +1,145 @@
+package sequence
+
+import (
+	"sync"
+	"time"
+
+	"github.com/glide-im/glide/pkg/logger"
+)
+
+var log = logger.Named("sequence")
+
+const (
+	defaultSegmentLength = 100
+	// maxSegmentBuffers the count of buffered conversations before idle buffers are evicted.
+	maxSegmentBuffers = 100_000
+	// segmentBufferIdle the duration a buffer is not allocated from before it can be evicted.
+	segmentBufferIdle = time.Minute * 10
+)
+
+// SegmentStore persists the max allocated sequence of conversations.
+type SegmentStore interface {
+
+	// NextSegment increases the max sequence of conversation by length and returns the first sequence of the segment.
+	NextSegment(conversation string, length int64) (int64, error)
+}
+
+var _ Allocator = (*SegmentAllocator)(nil)
+
+// SegmentAllocator allocates sequence from segments loaded from SegmentStore, which reduces the store access. The next
+// segment of a conversation is loaded in background when half of the current segment is allocated, so allocations
+// rarely wait for the store, and conversations are locked separately.
+//
+// SegmentAllocator is for single node deployment only: nodes sharing the store load different segments, sequences are
+// unique but not monotonic across nodes. The remaining sequences of loaded segments are discarded when the process
+// exits or the buffer of an idle conversation is evicted, which leaves a gap in sequence. Use RedisAllocator in cluster or when gap-free is required.
+type SegmentAllocator struct {
+	store  SegmentStore
+	length int64
+
+	mu         sync.Mutex
+	buffers    map[string]*segmentBuffer
+	maxBuffers int
+	idle       time.Duration
+}
+
+type segment struct {
+	next int64
+	max  int64
+}
+
+// segmentBuffer the current segment of a conversation and the next segment loaded in advance.
+type segmentBuffer struct {
+	mu   sync.Mutex
+	cur  segment
+	next *segment
+	// loading is closed when the next segment loading in background is done, nil if not loading.
+	loading chan struct{}
+	// used the last time allocated from, guarded by the lock of SegmentAllocator.
+	used time.Time
+}
+
+// NewSegmentAllocator creates SegmentAllocator, length is the count of sequence loaded once, default 100.
+func NewSegmentAllocator(store SegmentStore, length int64) *SegmentAllocator {
+	if length <= 0 {
+		length = defaultSegmentLength
+	}
+	return &SegmentAllocator{
+		store:      store,
+		length:     length,
+		buffers:    map[string]*segmentBuffer{},
+		maxBuffers: maxSegmentBuffers,
+		idle:       segmentBufferIdle,
+	}
+}
+
+func (s *SegmentAllocator) Next(conversation string) (int64, error) {
+	now := time.Now()
+	s.mu.Lock()
+	b, ok := s.buffers[conversation]
+	if !ok {
+		if len(s.buffers) >= s.maxBuffers {
+			s.evict(now)
+		}
+		// the empty segment is loaded on the first allocation.
+		b = &segmentBuffer{cur: segment{next: 1, max: 0}}
+		s.buffers[conversation] = b
+	}
+	b.used = now
+	s.mu.Unlock()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.cur.next > b.cur.max {
+		if b.next != nil {
+			b.cur, b.next = *b.next, nil
+			break
+		}
+		if loading := b.loading; loading != nil {
+			b.mu.Unlock()
+			<-loading
+			b.mu.Lock()
+			continue
+		}
+		// the segment loaded in background failed or not started.
+		start, err := s.store.NextSegment(conversation, s.length)
+		if err != nil {
+			return 0, err
+		}
+		b.cur = segment{next: start, max: start + s.length - 1}
+	}
+	seq := b.cur.next
+	b.cur.next++
+	if b.next == nil && b.loading == nil && b.cur.max-b.cur.next+1 <= s.length/2 {
+		s.prefetch(conversation, b)
+	}
+	return seq, nil
+}
+
+// evict removes buffers not allocated from for the idle duration, the remaining sequences of them are discarded,
+// s.mu must be locked.
+func (s *SegmentAllocator) evict(now time.Time) {
+	for c, b := range s.buffers {
+		if now.Sub(b.used) >= s.idle {
+			delete(s.buffers, c)
+		}
+	}
+}
+
+// prefetch loads the next segment of the conversation in background, b must be locked.
+func (s *SegmentAllocator) prefetch(conversation string, b *segmentBuffer) {
+	loading := make(chan struct{})
+	b.loading = loading
+	go func() {
+		start, err := s.store.NextSegment(conversation, s.length)
+		b.mu.Lock()
+		if err != nil {
+			log.W("load sequence segment of %s error: %v", conversation, err)
+		} else {
+			b.next = &segment{next: start, max: start + s.length - 1}
+		}
+		b.loading = nil
+		b.mu.Unlock()
+		close(loading)
+	}()
+}
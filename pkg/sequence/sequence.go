@@ -0,0 +1,34 @@
+package sequence
+
+import (
+	"sync"
+)
+
+// Allocator allocates monotonically increasing sequence per conversation, the first sequence is 1.
+type Allocator interface {
+
+	// Next returns the next sequence of the conversation.
+	Next(conversation string) (int64, error)
+}
+
+var _ Allocator = (*MemAllocator)(nil)
+
+// MemAllocator allocates sequence in memory, it's gap-free but sequences will be reset after restart, used in single
+// node deployment without persistence or test.
+type MemAllocator struct {
+	mu  sync.Mutex
+	seq map[string]int64
+}
+
+func NewMemAllocator() *MemAllocator {
+	return &MemAllocator{
+		seq: map[string]int64{},
+	}
+}
+
+func (m *MemAllocator) Next(conversation string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq[conversation]++
+	return m.seq[conversation], nil
+}
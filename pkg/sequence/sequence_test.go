@@ -0,0 +1,151 @@
+package sequence
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockSegmentStore struct {
+	mu  sync.Mutex
+	max map[string]int64
+	// block blocks loading segments of the conversation until closed.
+	block map[string]chan struct{}
+	err   error
+}
+
+func (m *mockSegmentStore) NextSegment(conversation string, length int64) (int64, error) {
+	m.mu.Lock()
+	block := m.block[conversation]
+	m.mu.Unlock()
+	if block != nil {
+		<-block
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return 0, m.err
+	}
+	start := m.max[conversation] + 1
+	m.max[conversation] += length
+	return start, nil
+}
+
+func (m *mockSegmentStore) maxOf(conversation string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.max[conversation]
+}
+
+func TestSegmentAllocator_Next(t *testing.T) {
+	s := &mockSegmentStore{max: map[string]int64{}}
+	allocator := NewSegmentAllocator(s, 3)
+
+	for i := int64(1); i <= 7; i++ {
+		seq, err := allocator.Next("c1")
+		assert.NoError(t, err)
+		assert.Equal(t, i, seq)
+	}
+	// the segment of 7 is loaded in background when half of the last segment allocated.
+	assert.Equal(t, int64(9), s.maxOf("c1"))
+
+	seq, err := allocator.Next("c2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), seq)
+}
+
+func TestSegmentAllocator_Concurrent(t *testing.T) {
+	s := &mockSegmentStore{max: map[string]int64{}, block: map[string]chan struct{}{}}
+	allocator := NewSegmentAllocator(s, 10)
+
+	// loading the segment of a conversation does not block others.
+	block := make(chan struct{})
+	s.block["c1"] = block
+	done := make(chan int64)
+	go func() {
+		seq, _ := allocator.Next("c1")
+		done <- seq
+	}()
+	seq, err := allocator.Next("c2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), seq)
+	close(block)
+	assert.Equal(t, int64(1), <-done)
+
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	seen := map[int64]bool{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				seq, err := allocator.Next("c3")
+				assert.NoError(t, err)
+				mu.Lock()
+				seen[seq] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, seen, 1000)
+	for i := int64(1); i <= 1000; i++ {
+		assert.True(t, seen[i], i)
+	}
+}
+
+func TestSegmentAllocator_LoadFailed(t *testing.T) {
+	s := &mockSegmentStore{max: map[string]int64{}}
+	allocator := NewSegmentAllocator(s, 2)
+	seq, err := allocator.Next("c1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), seq)
+	// the segment loaded in background is available.
+	assert.Eventually(t, func() bool { return s.maxOf("c1") == 4 }, time.Second, time.Millisecond)
+
+	s.mu.Lock()
+	s.err = errors.New("unavailable")
+	s.mu.Unlock()
+	for i := int64(2); i <= 4; i++ {
+		seq, err = allocator.Next("c1")
+		assert.NoError(t, err)
+		assert.Equal(t, i, seq)
+	}
+	_, err = allocator.Next("c1")
+	assert.Error(t, err)
+}
+
+func TestSegmentAllocator_Evict(t *testing.T) {
+	s := &mockSegmentStore{max: map[string]int64{}}
+	allocator := NewSegmentAllocator(s, 3)
+	allocator.maxBuffers = 2
+	allocator.idle = time.Hour
+
+	for _, c := range []string{"c1", "c2", "c3"} {
+		_, err := allocator.Next(c)
+		assert.NoError(t, err)
+	}
+	// buffers in use are not evicted.
+	assert.Len(t, allocator.buffers, 3)
+
+	allocator.idle = 0
+	_, err := allocator.Next("c4")
+	assert.NoError(t, err)
+	assert.Len(t, allocator.buffers, 1)
+
+	// the remaining sequences of the evicted buffer are discarded.
+	seq, err := allocator.Next("c1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), seq)
+}
+
+func TestMemAllocator_Next(t *testing.T) {
+	allocator := NewMemAllocator()
+	seq, _ := allocator.Next("c1")
+	assert.Equal(t, int64(1), seq)
+	seq, _ = allocator.Next("c1")
+	assert.Equal(t, int64(2), seq)
+}
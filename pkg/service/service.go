@@ -0,0 +1,334 @@
+// Package service routes conversations of visitors with service accounts to a pool of agents, such as customer
+// support. A message sent to the service account opens a session of the visitor assigned to an agent by the pool
+// strategy, messages in the session are delivered to the agent, and replies of the agent are sent to the visitor as
+// the service account. The session is kept until it's transferred to another agent or closed.
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultStickyWindow = time.Hour * 24
+
+const (
+	errServiceExists = "service already exists"
+	errNoAgent       = "no agent available"
+	errNoSession     = "session does not exist"
+	errNotAgent      = "not an agent of the service"
+)
+
+var (
+	// ErrServiceExists returned by Manager.Register when the service id is registered.
+	ErrServiceExists = errors.New(errServiceExists)
+	// ErrNoAgent returned when no agent of the service is online or below the max sessions.
+	ErrNoAgent = errors.New(errNoAgent)
+	// ErrNoSession returned when the visitor has no open session of the service.
+	ErrNoSession = errors.New(errNoSession)
+	// ErrNotAgent returned when the session is transferred to a user not an agent of the service.
+	ErrNotAgent = errors.New(errNotAgent)
+)
+
+// Options of Pool.
+type Options struct {
+	// Strategy picks the agent of new sessions, default LeastActive.
+	Strategy Strategy
+	// MaxSessions the max count of open sessions of an agent, 0 is unlimited.
+	MaxSessions int
+	// Online returns true if the agent is online, only online agents are assigned new sessions, default the presence
+	// set by Manager.SetPresence.
+	Online func(uid string) bool
+	// StickyWindow the duration the agent served a visitor is remembered after the session closed, default 24 hours.
+	StickyWindow time.Duration
+}
+
+// Session the conversation of the visitor with the service served by the agent.
+type Session struct {
+	Service string `json:"service"`
+	Visitor string `json:"visitor"`
+	Agent   string `json:"agent"`
+	// StartAt the unix milliseconds the session opened or transferred.
+	StartAt int64 `json:"start_at"`
+}
+
+type served struct {
+	agent string
+	at    time.Time
+}
+
+// Pool the agents of a service account, it's safe for concurrent use.
+type Pool struct {
+	id   string
+	opts Options
+	m    *Manager
+
+	mu       sync.Mutex
+	agents   []string
+	active   map[string]int
+	sessions map[string]*Session
+	last     map[string]served
+}
+
+func newPool(id string, m *Manager, opts *Options) *Pool {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Strategy == nil {
+		o.Strategy = LeastActive()
+	}
+	if o.StickyWindow <= 0 {
+		o.StickyWindow = defaultStickyWindow
+	}
+	return &Pool{
+		id:       id,
+		opts:     o,
+		m:        m,
+		active:   map[string]int{},
+		sessions: map[string]*Session{},
+		last:     map[string]served{},
+	}
+}
+
+// ID returns the uid of the service account.
+func (p *Pool) ID() string {
+	return p.id
+}
+
+// AddAgent adds the agent to the pool, it's ignored if the agent exists.
+func (p *Pool) AddAgent(uid string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.isAgent(uid) {
+		return
+	}
+	p.agents = append(p.agents, uid)
+}
+
+// RemoveAgent removes the agent from the pool, the agent is not assigned new sessions, sessions of the agent are
+// kept until transferred or closed.
+func (p *Pool) RemoveAgent(uid string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, a := range p.agents {
+		if a == uid {
+			p.agents = append(p.agents[:i], p.agents[i+1:]...)
+			break
+		}
+	}
+}
+
+// Agents returns agents of the pool with the count of open sessions.
+func (p *Pool) Agents() []Agent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ret := make([]Agent, 0, len(p.agents))
+	for _, a := range p.agents {
+		ret = append(ret, Agent{ID: a, Sessions: p.active[a]})
+	}
+	return ret
+}
+
+// IsAgent returns true if the uid is an agent of the pool.
+func (p *Pool) IsAgent(uid string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.isAgent(uid)
+}
+
+// Session returns the open session of the visitor.
+func (p *Pool) Session(visitor string) (Session, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.sessions[visitor]
+	if !ok {
+		return Session{}, false
+	}
+	return *s, true
+}
+
+// SessionsOf returns open sessions served by the agent.
+func (p *Pool) SessionsOf(agent string) []Session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var ret []Session
+	for _, s := range p.sessions {
+		if s.Agent == agent {
+			ret = append(ret, *s)
+		}
+	}
+	return ret
+}
+
+// Assign returns the open session of the visitor, or opens a session assigned to the agent picked by the strategy,
+// created is true if the session is opened. ErrNoAgent is returned if no agent is available.
+func (p *Pool) Assign(visitor string) (s Session, created bool, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cur, ok := p.sessions[visitor]; ok {
+		return *cur, false, nil
+	}
+	agent, ok := p.pick(visitor, "")
+	if !ok {
+		return Session{}, false, ErrNoAgent
+	}
+	return p.open(visitor, agent), true, nil
+}
+
+// Transfer transfers the session of the visitor to the agent to, or to the agent picked by the strategy except the
+// current if to is empty, returns the session transferred and the previous agent.
+func (p *Pool) Transfer(visitor string, to string) (Session, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cur, ok := p.sessions[visitor]
+	if !ok {
+		return Session{}, "", ErrNoSession
+	}
+	if to == "" {
+		to, ok = p.pick(visitor, cur.Agent)
+		if !ok {
+			return Session{}, "", ErrNoAgent
+		}
+	} else if !p.isAgent(to) {
+		return Session{}, "", ErrNotAgent
+	}
+	from := cur.Agent
+	p.release(cur)
+	return p.open(visitor, to), from, nil
+}
+
+// Close closes the session of the visitor, returns the session closed.
+func (p *Pool) Close(visitor string) (Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cur, ok := p.sessions[visitor]
+	if !ok {
+		return Session{}, ErrNoSession
+	}
+	p.release(cur)
+	return *cur, nil
+}
+
+func (p *Pool) open(visitor string, agent string) Session {
+	s := &Session{Service: p.id, Visitor: visitor, Agent: agent, StartAt: time.Now().UnixMilli()}
+	p.sessions[visitor] = s
+	p.active[agent]++
+	return *s
+}
+
+// release removes the session and remembers the agent served the visitor.
+func (p *Pool) release(s *Session) {
+	delete(p.sessions, s.Visitor)
+	if p.active[s.Agent]--; p.active[s.Agent] <= 0 {
+		delete(p.active, s.Agent)
+	}
+	now := time.Now()
+	for v, l := range p.last {
+		if now.Sub(l.at) > p.opts.StickyWindow {
+			delete(p.last, v)
+		}
+	}
+	p.last[s.Visitor] = served{agent: s.Agent, at: now}
+}
+
+// pick returns the agent picked by the strategy from agents available except the agent of except.
+func (p *Pool) pick(visitor string, except string) (string, bool) {
+	online := p.opts.Online
+	if online == nil {
+		online = p.m.online()
+	}
+	prev, ok := p.last[visitor]
+	if ok && time.Since(prev.at) > p.opts.StickyWindow {
+		prev = served{}
+	}
+	var candidates []Agent
+	for _, a := range p.agents {
+		if a == except || (p.opts.MaxSessions > 0 && p.active[a] >= p.opts.MaxSessions) {
+			continue
+		}
+		if online != nil && !online(a) {
+			continue
+		}
+		candidates = append(candidates, Agent{ID: a, Sessions: p.active[a], Previous: a == prev.agent})
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	return p.opts.Strategy.Pick(visitor, candidates), true
+}
+
+func (p *Pool) isAgent(uid string) bool {
+	for _, a := range p.agents {
+		if a == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// Manager the service accounts, it's safe for concurrent use.
+type Manager struct {
+	mu       sync.RWMutex
+	pools    map[string]*Pool
+	presence func(uid string) bool
+}
+
+func NewManager() *Manager {
+	return &Manager{pools: map[string]*Pool{}}
+}
+
+// Register registers the service account of uid, messages to the uid are routed to the agents of the pool returned.
+func (m *Manager) Register(uid string, opts *Options) (*Pool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.pools[uid]; ok {
+		return nil, ErrServiceExists
+	}
+	p := newPool(uid, m, opts)
+	m.pools[uid] = p
+	return p, nil
+}
+
+// Unregister removes the service account, sessions of it are dropped.
+func (m *Manager) Unregister(uid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pools, uid)
+}
+
+// Get returns the pool of the service account uid, nil if uid is not a service account.
+func (m *Manager) Get(uid string) *Pool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.pools[uid]
+}
+
+// ServedBy returns the pool of the session of the visitor served by the agent, nil if no such session.
+func (m *Manager) ServedBy(agent string, visitor string) *Pool {
+	m.mu.RLock()
+	pools := make([]*Pool, 0, len(m.pools))
+	for _, p := range m.pools {
+		pools = append(pools, p)
+	}
+	m.mu.RUnlock()
+	for _, p := range pools {
+		if s, ok := p.Session(visitor); ok && s.Agent == agent {
+			return p
+		}
+	}
+	return nil
+}
+
+// SetPresence sets the function returns true if the agent is online, used by pools without Options.Online.
+func (m *Manager) SetPresence(online func(uid string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.presence = online
+}
+
+func (m *Manager) online() func(uid string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.presence
+}
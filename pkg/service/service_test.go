@@ -0,0 +1,101 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool_Assign(t *testing.T) {
+	m := NewManager()
+	p, err := m.Register("cs", &Options{Strategy: RoundRobin(), MaxSessions: 1})
+	assert.NoError(t, err)
+	_, err = m.Register("cs", nil)
+	assert.ErrorIs(t, err, ErrServiceExists)
+
+	_, _, err = p.Assign("v1")
+	assert.ErrorIs(t, err, ErrNoAgent)
+
+	p.AddAgent("a1")
+	p.AddAgent("a2")
+	s1, created, err := p.Assign("v1")
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "a1", s1.Agent)
+	s, created, err := p.Assign("v1")
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, s1, s)
+
+	s2, _, err := p.Assign("v2")
+	assert.NoError(t, err)
+	assert.Equal(t, "a2", s2.Agent)
+	// all agents reach the max sessions
+	_, _, err = p.Assign("v3")
+	assert.ErrorIs(t, err, ErrNoAgent)
+
+	assert.Equal(t, p, m.ServedBy("a1", "v1"))
+	assert.Nil(t, m.ServedBy("a2", "v1"))
+
+	_, err = p.Close("v1")
+	assert.NoError(t, err)
+	_, err = p.Close("v1")
+	assert.ErrorIs(t, err, ErrNoSession)
+	s3, _, err := p.Assign("v3")
+	assert.NoError(t, err)
+	assert.Equal(t, "a1", s3.Agent)
+}
+
+func TestPool_Transfer(t *testing.T) {
+	m := NewManager()
+	online := map[string]bool{"a1": true, "a2": true, "a3": false}
+	m.SetPresence(func(uid string) bool { return online[uid] })
+	p, _ := m.Register("cs", nil)
+	for _, a := range []string{"a1", "a2", "a3"} {
+		p.AddAgent(a)
+	}
+
+	s, _, err := p.Assign("v1")
+	assert.NoError(t, err)
+	assert.Equal(t, "a1", s.Agent)
+
+	s, from, err := p.Transfer("v1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "a1", from)
+	assert.Equal(t, "a2", s.Agent)
+
+	_, _, err = p.Transfer("v1", "b")
+	assert.ErrorIs(t, err, ErrNotAgent)
+	s, _, err = p.Transfer("v1", "a3")
+	assert.NoError(t, err)
+	assert.Equal(t, "a3", s.Agent)
+	assert.Equal(t, []Agent{{ID: "a1"}, {ID: "a2"}, {ID: "a3", Sessions: 1}}, p.Agents())
+
+	_, _, err = p.Transfer("v2", "")
+	assert.ErrorIs(t, err, ErrNoSession)
+}
+
+func TestSticky(t *testing.T) {
+	m := NewManager()
+	p, _ := m.Register("cs", &Options{Strategy: Sticky(nil)})
+	p.AddAgent("a1")
+	p.AddAgent("a2")
+
+	_, _, _ = p.Assign("other")
+	s, _, _ := p.Assign("v1")
+	assert.Equal(t, "a2", s.Agent)
+	_, _ = p.Close("other")
+	_, _ = p.Close("v1")
+
+	// v1 is served by a2 again, while the fallback picks a1 added earlier.
+	s, _, _ = p.Assign("v1")
+	assert.Equal(t, "a2", s.Agent)
+	s, _, _ = p.Assign("v2")
+	assert.Equal(t, "a1", s.Agent)
+}
+
+func TestLeastActive(t *testing.T) {
+	pick := LeastActive().Pick("v", []Agent{{ID: "a1", Sessions: 2}, {ID: "a2", Sessions: 1}, {ID: "a3", Sessions: 1}})
+	assert.Equal(t, "a2", pick)
+	assert.Nil(t, StrategyOf("unknown"))
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"sync/atomic"
+)
+
+// Names of strategies.
+const (
+	StrategyRoundRobin  = "round_robin"
+	StrategyLeastActive = "least_active"
+	StrategySticky      = "sticky"
+)
+
+// Agent the agent available to be assigned a session.
+type Agent struct {
+	ID string
+	// Sessions the count of open sessions of the agent.
+	Sessions int
+	// Previous true if the agent served the visitor last time.
+	Previous bool
+}
+
+// Strategy picks the agent of the new session of visitor, candidates is not empty and ordered by the time added.
+type Strategy interface {
+	Pick(visitor string, candidates []Agent) string
+}
+
+// StrategyFunc adapts the function to Strategy.
+type StrategyFunc func(visitor string, candidates []Agent) string
+
+func (f StrategyFunc) Pick(visitor string, candidates []Agent) string {
+	return f(visitor, candidates)
+}
+
+// RoundRobin returns the Strategy picks agents in turn.
+func RoundRobin() Strategy {
+	var next uint64
+	return StrategyFunc(func(visitor string, candidates []Agent) string {
+		n := atomic.AddUint64(&next, 1) - 1
+		return candidates[n%uint64(len(candidates))].ID
+	})
+}
+
+// LeastActive returns the Strategy picks the agent with the least open sessions, the agent added earlier is picked if
+// tie.
+func LeastActive() Strategy {
+	return StrategyFunc(func(visitor string, candidates []Agent) string {
+		least := candidates[0]
+		for _, a := range candidates[1:] {
+			if a.Sessions < least.Sessions {
+				least = a
+			}
+		}
+		return least.ID
+	})
+}
+
+// Sticky returns the Strategy picks the agent served the visitor last time if it's available, otherwise picks by the
+// fallback, default LeastActive.
+func Sticky(fallback Strategy) Strategy {
+	if fallback == nil {
+		fallback = LeastActive()
+	}
+	return StrategyFunc(func(visitor string, candidates []Agent) string {
+		for _, a := range candidates {
+			if a.Previous {
+				return a.ID
+			}
+		}
+		return fallback.Pick(visitor, candidates)
+	})
+}
+
+// StrategyOf returns the Strategy of name: round_robin, least_active or sticky, nil if unknown.
+func StrategyOf(name string) Strategy {
+	switch name {
+	case StrategyRoundRobin:
+		return RoundRobin()
+	case StrategyLeastActive:
+		return LeastActive()
+	case StrategySticky:
+		return Sticky(nil)
+	}
+	return nil
+}
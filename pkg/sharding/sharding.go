@@ -0,0 +1,164 @@
+package sharding
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/glide-im/glide/pkg/logger"
+)
+
+var log = logger.Named("sharding")
+
+var ErrNoNode = errors.New("no node to shard")
+
+const (
+	// StrategyConsistentHash see NewConsistentHash.
+	StrategyConsistentHash = "consistent_hash"
+	// StrategyRendezvous see NewRendezvous.
+	StrategyRendezvous = "rendezvous"
+)
+
+// Sharding maps keys, such as the uid, to nodes of the cluster, such as gateways or registry shards. Implementations
+// are immutable, so the placement before nodes changed is kept to find keys moved, see Rebalance.
+type Sharding interface {
+
+	// Locate returns the node the key is placed on, ErrNoNode if there is no node.
+	Locate(key string) (string, error)
+
+	// WithNodes returns the Sharding of the same strategy places keys on the nodes.
+	WithNodes(nodes []string) Sharding
+
+	// Nodes returns nodes sorted.
+	Nodes() []string
+}
+
+// New returns the Sharding of the strategy without nodes, consistent hash if the strategy is empty.
+func New(strategy string) (Sharding, error) {
+	switch strategy {
+	case "", StrategyConsistentHash:
+		return NewConsistentHash(0), nil
+	case StrategyRendezvous:
+		return NewRendezvous(), nil
+	}
+	return nil, errors.New("unknown sharding strategy: " + strategy)
+}
+
+// Rebalance the nodes changed, keys placed on other nodes should be migrated by hooks, see Moved.
+type Rebalance struct {
+	Added   []string
+	Removed []string
+	// Previous the placement before nodes changed.
+	Previous Sharding
+	// Current the placement after nodes changed.
+	Current Sharding
+}
+
+// Moved returns the node the key moved from and to, false if the key is not moved.
+func (r *Rebalance) Moved(key string) (from string, to string, moved bool) {
+	from, _ = r.Previous.Locate(key)
+	to, _ = r.Current.Locate(key)
+	return from, to, from != to
+}
+
+// RebalanceHook is called when nodes of the Router changed.
+type RebalanceHook func(r *Rebalance)
+
+// Router holds the current Sharding of the cluster, nodes are updated as nodes join or leave, such as watched by
+// discovery.Membership, and hooks are called to rebalance.
+type Router struct {
+	mu       sync.RWMutex
+	sharding Sharding
+	hooks    []RebalanceHook
+}
+
+func NewRouter(s Sharding) *Router {
+	return &Router{sharding: s}
+}
+
+// Locate returns the node the key is placed on.
+func (r *Router) Locate(key string) (string, error) {
+	return r.Sharding().Locate(key)
+}
+
+// Sharding returns the current placement.
+func (r *Router) Sharding() Sharding {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sharding
+}
+
+// OnRebalance registers the hook called when nodes or the strategy changed, hooks are called in the order registered.
+func (r *Router) OnRebalance(hook RebalanceHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+// SetNodes places keys on the nodes, hooks are called if nodes changed.
+func (r *Router) SetNodes(nodes []string) {
+	r.mu.Lock()
+	prev := r.sharding
+	added, removed := diffNodes(prev.Nodes(), nodes)
+	if len(added) == 0 && len(removed) == 0 {
+		r.mu.Unlock()
+		return
+	}
+	r.sharding = prev.WithNodes(nodes)
+	rebalance := &Rebalance{Added: added, Removed: removed, Previous: prev, Current: r.sharding}
+	hooks := r.hooks
+	r.mu.Unlock()
+
+	log.I("nodes changed, added: %v, removed: %v", added, removed)
+	for _, h := range hooks {
+		h(rebalance)
+	}
+}
+
+// SetSharding replaces the strategy, the nodes of the current strategy are kept, hooks are called as keys may move.
+func (r *Router) SetSharding(s Sharding) {
+	r.mu.Lock()
+	prev := r.sharding
+	r.sharding = s.WithNodes(prev.Nodes())
+	rebalance := &Rebalance{Previous: prev, Current: r.sharding}
+	hooks := r.hooks
+	r.mu.Unlock()
+
+	for _, h := range hooks {
+		h(rebalance)
+	}
+}
+
+// diffNodes returns nodes in b not in a, and nodes in a not in b.
+func diffNodes(a []string, b []string) (added []string, removed []string) {
+	in := map[string]bool{}
+	for _, n := range a {
+		in[n] = true
+	}
+	for _, n := range b {
+		if !in[n] {
+			added = append(added, n)
+		}
+		delete(in, n)
+	}
+	for n := range in {
+		removed = append(removed, n)
+	}
+	sort.Strings(removed)
+	return added, removed
+}
+
+// sortedNodes returns the copy of nodes sorted and deduplicated.
+func sortedNodes(nodes []string) []string {
+	ret := make([]string, 0, len(nodes))
+	seen := map[string]bool{}
+	for _, n := range nodes {
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		ret = append(ret, n)
+	}
+	sort.Strings(ret)
+	return ret
+}
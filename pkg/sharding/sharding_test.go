@@ -0,0 +1,86 @@
+package sharding
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrategies(t *testing.T) {
+	for _, strategy := range []string{StrategyConsistentHash, StrategyRendezvous} {
+		s, err := New(strategy)
+		assert.NoError(t, err)
+		_, err = s.Locate("1")
+		assert.Equal(t, ErrNoNode, err)
+
+		before := s.WithNodes([]string{"a", "b", "c"})
+		after := before.WithNodes([]string{"a", "b", "c", "d"})
+		assert.Equal(t, []string{"a", "b", "c", "d"}, after.Nodes())
+
+		counts := map[string]int{}
+		moved := 0
+		for i := 0; i < 10000; i++ {
+			key := strconv.Itoa(i)
+			from, err := before.Locate(key)
+			assert.NoError(t, err)
+			to, _ := after.Locate(key)
+			counts[to]++
+			if from != to {
+				// keys only move to the node joined.
+				assert.Equal(t, "d", to, strategy)
+				moved++
+			}
+			// the placement is stable.
+			again, _ := after.Locate(key)
+			assert.Equal(t, to, again)
+		}
+		assert.Len(t, counts, 4, strategy)
+		assert.Less(t, moved, 5000, strategy)
+	}
+	_, err := New("unknown")
+	assert.Error(t, err)
+}
+
+func TestStaticTable(t *testing.T) {
+	s := NewStaticTable(map[string]string{"vip": "d"}, NewRendezvous()).WithNodes([]string{"a", "b", "d"})
+	n, err := s.Locate("vip")
+	assert.NoError(t, err)
+	assert.Equal(t, "d", n)
+
+	// the key pinned to the node absent is placed by the fallback.
+	s = s.WithNodes([]string{"a", "b"})
+	n, err = s.Locate("vip")
+	assert.NoError(t, err)
+	assert.Contains(t, []string{"a", "b"}, n)
+}
+
+func TestRouter_Rebalance(t *testing.T) {
+	r := NewRouter(NewRendezvous())
+	var rebalances []*Rebalance
+	r.OnRebalance(func(rb *Rebalance) {
+		rebalances = append(rebalances, rb)
+	})
+
+	r.SetNodes([]string{"a", "b"})
+	r.SetNodes([]string{"b", "a"})
+	assert.Len(t, rebalances, 1)
+	assert.Equal(t, []string{"a", "b"}, rebalances[0].Added)
+
+	r.SetNodes([]string{"a"})
+	assert.Len(t, rebalances, 2)
+	assert.Equal(t, []string{"b"}, rebalances[1].Removed)
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		from, to, moved := rebalances[1].Moved(key)
+		assert.Equal(t, "a", to)
+		assert.Equal(t, from == "b", moved)
+	}
+
+	r.SetSharding(NewConsistentHash(0))
+	assert.Len(t, rebalances, 3)
+	assert.Equal(t, []string{"a"}, r.Sharding().Nodes())
+	n, err := r.Locate("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", n)
+}
@@ -0,0 +1,126 @@
+package sharding
+
+import (
+	"github.com/glide-im/glide/pkg/hash"
+)
+
+const (
+	defaultVirtualNodes = 100
+	rendezvousSeed      = 0x5bd1e995
+)
+
+var _ Sharding = (*ConsistentHash)(nil)
+var _ Sharding = (*Rendezvous)(nil)
+var _ Sharding = (*StaticTable)(nil)
+
+// ConsistentHash places keys on the hash ring with virtual nodes, about 1/n keys move when a node joins or leaves.
+type ConsistentHash struct {
+	virtual int
+	nodes   []string
+	ring    *hash.ConsistentHash
+}
+
+// NewConsistentHash returns the ConsistentHash without nodes, virtual is the count of virtual nodes of each node,
+// default 100.
+func NewConsistentHash(virtual int) *ConsistentHash {
+	if virtual <= 0 {
+		virtual = defaultVirtualNodes
+	}
+	return &ConsistentHash{virtual: virtual, ring: hash.NewConsistentHash2(virtual)}
+}
+
+func (c *ConsistentHash) Locate(key string) (string, error) {
+	if len(c.nodes) == 0 {
+		return "", ErrNoNode
+	}
+	n, err := c.ring.Get(key)
+	if err != nil {
+		return "", err
+	}
+	return n.Val, nil
+}
+
+func (c *ConsistentHash) WithNodes(nodes []string) Sharding {
+	ret := NewConsistentHash(c.virtual)
+	ret.nodes = sortedNodes(nodes)
+	for _, n := range ret.nodes {
+		_ = ret.ring.Add(n)
+	}
+	return ret
+}
+
+func (c *ConsistentHash) Nodes() []string {
+	return append([]string(nil), c.nodes...)
+}
+
+// Rendezvous places the key on the node of the highest hash of the key and the node, the highest random weight
+// hashing, only keys of the node leaving move, and keys are balanced without virtual nodes.
+type Rendezvous struct {
+	nodes []string
+}
+
+func NewRendezvous() *Rendezvous {
+	return &Rendezvous{}
+}
+
+func (r *Rendezvous) Locate(key string) (string, error) {
+	if len(r.nodes) == 0 {
+		return "", ErrNoNode
+	}
+	var best string
+	var max uint32
+	for i, n := range r.nodes {
+		w := hash.Hash([]byte(n+"\x00"+key), rendezvousSeed)
+		if i == 0 || w > max {
+			best, max = n, w
+		}
+	}
+	return best, nil
+}
+
+func (r *Rendezvous) WithNodes(nodes []string) Sharding {
+	return &Rendezvous{nodes: sortedNodes(nodes)}
+}
+
+func (r *Rendezvous) Nodes() []string {
+	return append([]string(nil), r.nodes...)
+}
+
+// StaticTable places keys by the table of key to node, such as pinning tenants to dedicated nodes, keys not in the
+// table or placed on nodes absent are placed by the fallback.
+type StaticTable struct {
+	table    map[string]string
+	fallback Sharding
+	alive    map[string]bool
+}
+
+// NewStaticTable returns the StaticTable, the fallback is the consistent hash if nil.
+func NewStaticTable(table map[string]string, fallback Sharding) *StaticTable {
+	if fallback == nil {
+		fallback = NewConsistentHash(0)
+	}
+	t := make(map[string]string, len(table))
+	for k, v := range table {
+		t[k] = v
+	}
+	return &StaticTable{table: t, fallback: fallback, alive: map[string]bool{}}
+}
+
+func (s *StaticTable) Locate(key string) (string, error) {
+	if n, ok := s.table[key]; ok && s.alive[n] {
+		return n, nil
+	}
+	return s.fallback.Locate(key)
+}
+
+func (s *StaticTable) WithNodes(nodes []string) Sharding {
+	ret := &StaticTable{table: s.table, fallback: s.fallback.WithNodes(nodes), alive: map[string]bool{}}
+	for _, n := range nodes {
+		ret.alive[n] = true
+	}
+	return ret
+}
+
+func (s *StaticTable) Nodes() []string {
+	return s.fallback.Nodes()
+}
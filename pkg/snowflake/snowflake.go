@@ -0,0 +1,123 @@
+package snowflake
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/logger"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var log = logger.Named("snowflake")
+
+// ID layout: 1 bit unused | 41 bits milliseconds since Epoch | 10 bits node id | 12 bits sequence.
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+
+	MaxNodeID   = -1 ^ (-1 << nodeBits)
+	maxSequence = -1 ^ (-1 << sequenceBits)
+
+	timeShift = nodeBits + sequenceBits
+	nodeShift = sequenceBits
+)
+
+// Epoch is the start time of ID timestamp, 2022-01-01 00:00:00 UTC in milliseconds.
+const Epoch int64 = 1640995200000
+
+var (
+	ErrInvalidNodeID = errors.New("snowflake node id must be between 0 and " + strconv.Itoa(MaxNodeID))
+)
+
+// Node generates unique ID, every node in the cluster must have a different node id.
+type Node struct {
+	mu       sync.Mutex
+	id       int64
+	lastTime int64
+	sequence int64
+	// suspended is true when the node id is not exclusive anymore, Generate waits until the node is resumed.
+	suspended bool
+	resumed   *sync.Cond
+}
+
+func NewNode(id int64) (*Node, error) {
+	if id < 0 || id > MaxNodeID {
+		return nil, ErrInvalidNodeID
+	}
+	n := &Node{id: id}
+	n.resumed = sync.NewCond(&n.mu)
+	return n, nil
+}
+
+// Suspend stops the node from generating ID until Resume, it is called when the node id is not exclusive anymore.
+func (n *Node) Suspend() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.suspended = true
+}
+
+// Resume continues generating ID with the new node id acquired.
+func (n *Node) Resume(id int64) error {
+	if id < 0 || id > MaxNodeID {
+		return ErrInvalidNodeID
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.id = id
+	n.suspended = false
+	n.resumed.Broadcast()
+	return nil
+}
+
+// Generate returns a new unique ID, the ID is increasing in a node, even if the clock moves backwards.
+// It blocks while the node is suspended, since the ID may be duplicated with other nodes.
+func (n *Node) Generate() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for n.suspended {
+		n.resumed.Wait()
+	}
+
+	now := time.Now().UnixMilli() - Epoch
+	if now < n.lastTime {
+		// clock moved backwards, keep using the last time.
+		now = n.lastTime
+	}
+	if now == n.lastTime {
+		n.sequence = (n.sequence + 1) & maxSequence
+		if n.sequence == 0 {
+			// sequence exhausted in this millisecond, wait for next.
+			for now <= n.lastTime {
+				time.Sleep(time.Microsecond * 100)
+				now = time.Now().UnixMilli() - Epoch
+			}
+		}
+	} else {
+		n.sequence = 0
+	}
+	n.lastTime = now
+	return now<<timeShift | n.id<<nodeShift | n.sequence
+}
+
+// NodeID returns the node id of the ID.
+func NodeID(id int64) int64 {
+	return id >> nodeShift & MaxNodeID
+}
+
+// Time returns the generated time of the ID.
+func Time(id int64) time.Time {
+	return time.UnixMilli(id>>timeShift + Epoch)
+}
+
+var defaultNode, _ = NewNode(0)
+
+// SetDefault sets the node used by package level Generate.
+func SetDefault(n *Node) {
+	defaultNode = n
+}
+
+// Generate returns a new unique ID from the default node.
+func Generate() int64 {
+	return defaultNode.Generate()
+}
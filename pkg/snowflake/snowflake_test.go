@@ -0,0 +1,46 @@
+package snowflake
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNode_Generate(t *testing.T) {
+	n, err := NewNode(5)
+	assert.NoError(t, err)
+
+	last := int64(0)
+	for i := 0; i < 10000; i++ {
+		id := n.Generate()
+		assert.Greater(t, id, last)
+		last = id
+	}
+	assert.Equal(t, int64(5), NodeID(last))
+	assert.WithinDuration(t, time.Now(), Time(last), time.Second)
+}
+
+func TestNewNode(t *testing.T) {
+	_, err := NewNode(MaxNodeID + 1)
+	assert.ErrorIs(t, err, ErrInvalidNodeID)
+}
+
+func TestNode_Suspend(t *testing.T) {
+	n, err := NewNode(1)
+	assert.NoError(t, err)
+	n.Suspend()
+
+	generated := make(chan int64)
+	go func() {
+		generated <- n.Generate()
+	}()
+	select {
+	case <-generated:
+		t.Fatal("generated while suspended")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	assert.ErrorIs(t, n.Resume(MaxNodeID+1), ErrInvalidNodeID)
+	assert.NoError(t, n.Resume(2))
+	assert.Equal(t, int64(2), NodeID(<-generated))
+}
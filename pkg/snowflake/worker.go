@@ -0,0 +1,160 @@
+package snowflake
+
+import (
+	"errors"
+	"github.com/go-redis/redis"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const workerKeyPrefix = "im:snowflake:worker:"
+
+const defaultWorkerTTL = time.Second * 30
+
+var (
+	ErrNoWorkerID = errors.New("no available snowflake worker id")
+)
+
+// renewScript renews the worker id only if it is still held by the node, the id may be expired and acquired by
+// another node when the renewal is delayed.
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// WorkerRegistry assigns unique node id to nodes of the cluster.
+type WorkerRegistry interface {
+
+	// Acquire returns an unused node id and holds it until Release.
+	Acquire(name string) (int64, error)
+
+	// Release releases the node id.
+	Release(id int64) error
+}
+
+var _ WorkerRegistry = (*RedisWorkerRegistry)(nil)
+
+// RedisWorkerRegistry assigns node id by redis SETNX, the acquired id is renewed periodically, it will be released
+// automatically after the node is down for ttl.
+//
+// The id is lost when it is found held by others or can not be renewed within ttl, the lost handler is called then
+// and the id must not be used anymore, see Bind.
+type RedisWorkerRegistry struct {
+	client *redis.Client
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	stop   map[int64]chan struct{}
+	onLost func(id int64)
+}
+
+func NewRedisWorkerRegistry(client *redis.Client, ttl time.Duration) *RedisWorkerRegistry {
+	if ttl <= 0 {
+		ttl = defaultWorkerTTL
+	}
+	return &RedisWorkerRegistry{
+		client: client,
+		ttl:    ttl,
+		stop:   map[int64]chan struct{}{},
+	}
+}
+
+func (r *RedisWorkerRegistry) Acquire(name string) (int64, error) {
+	for id := int64(0); id <= MaxNodeID; id++ {
+		ok, err := r.client.SetNX(r.key(id), name, r.ttl).Result()
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			stop := make(chan struct{})
+			r.mu.Lock()
+			r.stop[id] = stop
+			r.mu.Unlock()
+			go r.keepalive(id, name, stop)
+			return id, nil
+		}
+	}
+	return 0, ErrNoWorkerID
+}
+
+// SetLostHandler sets the function called when an acquired id is lost.
+func (r *RedisWorkerRegistry) SetLostHandler(fn func(id int64)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onLost = fn
+}
+
+// Bind suspends the node when the id acquired is lost, and resumes it after a new id is acquired.
+func (r *RedisWorkerRegistry) Bind(n *Node, name string) {
+	r.SetLostHandler(func(id int64) {
+		n.Suspend()
+		go func() {
+			for {
+				newID, err := r.Acquire(name)
+				if err == nil {
+					_ = n.Resume(newID)
+					log.D("snowflake worker id %d lost, acquired %d", id, newID)
+					return
+				}
+				log.E("acquire snowflake worker id error: %v", err)
+				time.Sleep(r.ttl / 3)
+			}
+		}()
+	})
+}
+
+func (r *RedisWorkerRegistry) Release(id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stop, ok := r.stop[id]; ok {
+		close(stop)
+		delete(r.stop, id)
+	}
+	return r.client.Del(r.key(id)).Err()
+}
+
+func (r *RedisWorkerRegistry) keepalive(id int64, name string, stop chan struct{}) {
+	ticker := time.NewTicker(r.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			n, err := renewScript.Run(r.client, []string{r.key(id)}, name, r.ttl.Milliseconds()).Int64()
+			if err == nil && n == 1 {
+				renewed = time.Now()
+				continue
+			}
+			if err != nil {
+				log.E("renew snowflake worker id %d error: %v", id, err)
+				if time.Since(renewed) < r.ttl {
+					continue
+				}
+			}
+			log.E("snowflake worker id %d lost", id)
+			r.lost(id, stop)
+			return
+		}
+	}
+}
+
+func (r *RedisWorkerRegistry) lost(id int64, stop chan struct{}) {
+	r.mu.Lock()
+	if r.stop[id] == stop {
+		delete(r.stop, id)
+	}
+	fn := r.onLost
+	r.mu.Unlock()
+	if fn != nil {
+		fn(id)
+	}
+}
+
+func (r *RedisWorkerRegistry) key(id int64) string {
+	return workerKeyPrefix + strconv.FormatInt(id, 10)
+}
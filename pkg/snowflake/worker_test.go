@@ -0,0 +1,32 @@
+package snowflake
+
+import (
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRedisWorkerRegistry_KeepaliveLost(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: 0, DialTimeout: time.Millisecond * 10})
+	defer client.Close()
+	r := NewRedisWorkerRegistry(client, time.Millisecond*60)
+
+	lost := make(chan int64, 1)
+	r.SetLostHandler(func(id int64) {
+		lost <- id
+	})
+	stop := make(chan struct{})
+	r.stop[3] = stop
+	go r.keepalive(3, "node", stop)
+
+	select {
+	case id := <-lost:
+		assert.Equal(t, int64(3), id)
+	case <-time.After(time.Second):
+		t.Fatal("worker id not lost after renewal failed for ttl")
+	}
+	r.mu.Lock()
+	assert.NotContains(t, r.stop, int64(3))
+	r.mu.Unlock()
+}
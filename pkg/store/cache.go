@@ -0,0 +1,410 @@
+package store
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/subscription"
+)
+
+const (
+	defaultCacheSize = 10000
+	defaultCacheTTL  = time.Minute
+	// purgeConversationLimit the max count of P2P conversations of the purged user invalidated.
+	purgeConversationLimit = 10000
+)
+
+const (
+	cacheKeyHistoryPrefix = "history:"
+	cacheKeyCursorsPrefix = "cursors:"
+)
+
+const (
+	errSequenceNotSupported  = "the cached store does not allocate channel sequences"
+	errRetentionNotSupported = "the cached store does not support retention"
+	errArchiveNotSupported   = "the cached store does not support archive"
+)
+
+// Cache is the key value cache of CacheStore, such as MemCache and RedisCache.
+type Cache interface {
+
+	// Get returns the value of key, nil if the key does not exist or expired.
+	Get(key string) ([]byte, error)
+
+	// Set sets the value of key expires after ttl.
+	Set(key string, value []byte, ttl time.Duration) error
+
+	// Delete deletes keys.
+	Delete(keys ...string) error
+}
+
+var _ Cache = (*MemCache)(nil)
+
+type memCacheEntry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+// MemCache is a LRU Cache in memory, the least recently used key is evicted when the cache is full.
+type MemCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// NewMemCache returns the MemCache holds size keys at most, default 10000 if size <= 0.
+func NewMemCache(size int) *MemCache {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	return &MemCache{
+		size:    size,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+func (m *MemCache) Get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := e.Value.(*memCacheEntry)
+	if time.Now().After(entry.expireAt) {
+		m.lru.Remove(e)
+		delete(m.entries, key)
+		return nil, nil
+	}
+	m.lru.MoveToFront(e)
+	return entry.value, nil
+}
+
+func (m *MemCache) Set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := &memCacheEntry{key: key, value: value, expireAt: time.Now().Add(ttl)}
+	if e, ok := m.entries[key]; ok {
+		e.Value = entry
+		m.lru.MoveToFront(e)
+		return nil
+	}
+	m.entries[key] = m.lru.PushFront(entry)
+	for m.lru.Len() > m.size {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memCacheEntry).key)
+	}
+	return nil
+}
+
+func (m *MemCache) Delete(keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if e, ok := m.entries[key]; ok {
+			m.lru.Remove(e)
+			delete(m.entries, key)
+		}
+	}
+	return nil
+}
+
+type CacheOptions struct {
+	// Cache the cache of recent history and read cursors, default NewMemCache(10000).
+	Cache Cache
+	// TTL the max duration a value is cached, it bounds the staleness if the invalidation is missed, such as the
+	// message stored by other nodes, default 1 minute.
+	TTL time.Duration
+}
+
+// historyEntry the cached messages of the conversation queried by GetBySeqRange in [Start, End].
+type historyEntry struct {
+	Start    int64                   `json:"start"`
+	End      int64                   `json:"end"`
+	Messages []*messages.ChatMessage `json:"messages"`
+}
+
+var _ MessageHistoryStore = (*CacheStore)(nil)
+var _ SubscriptionStore = (*CacheStore)(nil)
+var _ BatchMessageStore = (*CacheStore)(nil)
+var _ UserPurgeStore = (*CacheStore)(nil)
+
+// CacheStore is a read-through cache in front of the MessageHistoryStore for recent history of conversations and read
+// cursors of users, it offloads the database when many clients reconnect and backfill at once. The history of the
+// conversation is invalidated when a message is stored, recalled or edited, read cursors of the user are invalidated
+// when updated. Other optional interfaces of the store are available by Unwrap, use As to prefer the cache.
+type CacheStore struct {
+	store MessageHistoryStore
+	cache Cache
+	ttl   time.Duration
+}
+
+func NewCacheStore(store MessageHistoryStore, opts CacheOptions) *CacheStore {
+	if opts.Cache == nil {
+		opts.Cache = NewMemCache(defaultCacheSize)
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = defaultCacheTTL
+	}
+	return &CacheStore{
+		store: store,
+		cache: opts.Cache,
+		ttl:   opts.TTL,
+	}
+}
+
+func (c *CacheStore) Unwrap() MessageStore {
+	return c.store
+}
+
+func (c *CacheStore) StoreMessage(message *messages.ChatMessage) error {
+	err := c.store.StoreMessage(message)
+	if err == nil {
+		c.invalidate(cacheKeyHistoryPrefix + string(conversation.NewP2P(message.From, message.To).ID))
+	}
+	return err
+}
+
+func (c *CacheStore) StoreMessages(ms []*messages.ChatMessage) error {
+	var err error
+	if bs, ok := c.store.(BatchMessageStore); ok {
+		err = bs.StoreMessages(ms)
+	} else {
+		for _, m := range ms {
+			if err = c.store.StoreMessage(m); err != nil {
+				break
+			}
+		}
+	}
+	// messages stored before the error are invalidated as well.
+	keys := make([]string, 0, len(ms))
+	for _, m := range ms {
+		keys = append(keys, cacheKeyHistoryPrefix+string(conversation.NewP2P(m.From, m.To).ID))
+	}
+	c.invalidate(keys...)
+	return err
+}
+
+func (c *CacheStore) StoreOffline(message *messages.ChatMessage) error {
+	return c.store.StoreOffline(message)
+}
+
+func (c *CacheStore) StoreChannelMessage(ch subscription.ChanID, msg *messages.ChatMessage) error {
+	err := c.store.StoreChannelMessage(ch, msg)
+	if err == nil {
+		c.invalidate(cacheKeyHistoryPrefix + string(conversation.NewChannel(string(ch)).ID))
+	}
+	return err
+}
+
+func (c *CacheStore) NextSegmentSequence(id subscription.ChanID, info subscription.ChanInfo) (int64, int64, error) {
+	ss, ok := c.store.(SubscriptionStore)
+	if !ok {
+		return 0, 0, errors.New(errSequenceNotSupported)
+	}
+	return ss.NextSegmentSequence(id, info)
+}
+
+func (c *CacheStore) GetMessage(conv conversation.ID, mid int64) (*messages.ChatMessage, error) {
+	return c.store.GetMessage(conv, mid)
+}
+
+func (c *CacheStore) MarkRecalled(conv conversation.ID, mid int64) error {
+	err := c.store.MarkRecalled(conv, mid)
+	if err == nil {
+		c.invalidate(cacheKeyHistoryPrefix + string(conv))
+	}
+	return err
+}
+
+func (c *CacheStore) EditMessage(conv conversation.ID, mid int64, content string, editAt int64) error {
+	err := c.store.EditMessage(conv, mid, content, editAt)
+	if err == nil {
+		c.invalidate(cacheKeyHistoryPrefix + string(conv))
+	}
+	return err
+}
+
+func (c *CacheStore) GetBySeqRange(conv conversation.ID, start int64, end int64) ([]*messages.ChatMessage, error) {
+	key := cacheKeyHistoryPrefix + string(conv)
+	entry := historyEntry{}
+	if c.get(key, &entry) && entry.Start <= start && end <= entry.End {
+		ret := make([]*messages.ChatMessage, 0, len(entry.Messages))
+		for _, m := range entry.Messages {
+			if m.Seq >= start && m.Seq <= end {
+				ret = append(ret, m)
+			}
+		}
+		return ret, nil
+	}
+	ms, err := c.store.GetBySeqRange(conv, start, end)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, &historyEntry{Start: start, End: end, Messages: ms})
+	return ms, nil
+}
+
+func (c *CacheStore) UpdateReadCursor(uid string, conv conversation.ID, seq int64, readAt int64) (bool, error) {
+	updated, err := c.store.UpdateReadCursor(uid, conv, seq, readAt)
+	if err == nil && updated {
+		c.invalidate(cacheKeyCursorsPrefix + uid)
+	}
+	return updated, err
+}
+
+func (c *CacheStore) GetReadCursors(uid string) ([]*messages.ReadCursor, error) {
+	key := cacheKeyCursorsPrefix + uid
+	var cursors []*messages.ReadCursor
+	if c.get(key, &cursors) {
+		return cursors, nil
+	}
+	cursors, err := c.store.GetReadCursors(uid)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, cursors)
+	return cursors, nil
+}
+
+func (c *CacheStore) GetReadCount(conv conversation.ID, seq int64) (int64, error) {
+	return c.store.GetReadCount(conv, seq)
+}
+
+// PurgeUser purges uid from the store if it implements UserPurgeStore, and invalidates read cursors of uid and the
+// history of conversations uid has messages in. The conversations are listed before purged, they are P2P
+// conversations if the store implements ConversationStore, and conversations uid has read cursors in.
+func (c *CacheStore) PurgeUser(uid string) error {
+	keys := []string{cacheKeyCursorsPrefix + uid}
+	cursors, err := c.store.GetReadCursors(uid)
+	if err != nil {
+		return err
+	}
+	for _, cursor := range cursors {
+		keys = append(keys, cacheKeyHistoryPrefix+cursor.Conversation)
+	}
+	if cs, ok := As[ConversationStore](c.store); ok {
+		summaries, err := cs.GetRecentConversations(uid, nil, purgeConversationLimit)
+		if err != nil {
+			return err
+		}
+		for _, s := range summaries {
+			keys = append(keys, cacheKeyHistoryPrefix+string(s.Conversation))
+		}
+	}
+	if ps, ok := As[UserPurgeStore](c.store); ok {
+		err = ps.PurgeUser(uid)
+	}
+	// invalidated even if failed, some data may be deleted.
+	c.invalidate(keys...)
+	return err
+}
+
+func (c *CacheStore) GetConversations(after conversation.ID, limit int) ([]conversation.ID, error) {
+	rs, ok := As[RetentionStore](c.store)
+	if !ok {
+		return nil, errors.New(errRetentionNotSupported)
+	}
+	return rs.GetConversations(after, limit)
+}
+
+// PurgeMessages purges messages of the conversation and invalidates the history of it.
+func (c *CacheStore) PurgeMessages(conv conversation.ID, before int64, keep int64, limit int) (int64, error) {
+	rs, ok := As[RetentionStore](c.store)
+	if !ok {
+		return 0, errors.New(errRetentionNotSupported)
+	}
+	n, err := rs.PurgeMessages(conv, before, keep, limit)
+	if n > 0 || err != nil {
+		c.invalidate(cacheKeyHistoryPrefix + string(conv))
+	}
+	return n, err
+}
+
+func (c *CacheStore) GetArchivable(before int64, limit int) ([]*ArchivedMessage, error) {
+	as, ok := As[ArchiveStore](c.store)
+	if !ok {
+		return nil, errors.New(errArchiveNotSupported)
+	}
+	return as.GetArchivable(before, limit)
+}
+
+// RemoveArchived removes the archived messages and invalidates the history of conversations of them.
+func (c *CacheStore) RemoveArchived(ms []*ArchivedMessage) error {
+	as, ok := As[ArchiveStore](c.store)
+	if !ok {
+		return errors.New(errArchiveNotSupported)
+	}
+	err := as.RemoveArchived(ms)
+	// invalidated even if failed, some messages may be removed.
+	c.invalidate(archivedHistoryKeys(ms)...)
+	return err
+}
+
+// RestoreArchived restores the archived messages and invalidates the history of conversations of them.
+func (c *CacheStore) RestoreArchived(ms []*ArchivedMessage) error {
+	as, ok := As[ArchiveStore](c.store)
+	if !ok {
+		return errors.New(errArchiveNotSupported)
+	}
+	err := as.RestoreArchived(ms)
+	c.invalidate(archivedHistoryKeys(ms)...)
+	return err
+}
+
+func (c *CacheStore) Migrate() error {
+	return c.store.Migrate()
+}
+
+func archivedHistoryKeys(ms []*ArchivedMessage) []string {
+	var keys []string
+	seen := map[conversation.ID]bool{}
+	for _, m := range ms {
+		if !seen[m.Conversation] {
+			seen[m.Conversation] = true
+			keys = append(keys, cacheKeyHistoryPrefix+string(m.Conversation))
+		}
+	}
+	return keys
+}
+
+func (c *CacheStore) invalidate(keys ...string) {
+	if err := c.cache.Delete(keys...); err != nil {
+		log.W("invalidate cache %v error: %v", keys, err)
+	}
+}
+
+// get decodes the cached value of key to v, returns false if not cached.
+func (c *CacheStore) get(key string, v interface{}) bool {
+	b, err := c.cache.Get(key)
+	if err != nil {
+		log.W("get cache %s error: %v", key, err)
+		return false
+	}
+	if b == nil {
+		return false
+	}
+	return json.Unmarshal(b, v) == nil
+}
+
+func (c *CacheStore) set(key string, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if err = c.cache.Set(key, b, c.ttl); err != nil {
+		log.W("set cache %s error: %v", key, err)
+	}
+}
@@ -0,0 +1,350 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockHistoryStore counts queries of history and read cursors.
+type mockHistoryStore struct {
+	messages map[int64]*messages.ChatMessage
+	cursors  map[string]map[conversation.ID]int64
+	queries  int
+}
+
+func newMockHistoryStore() *mockHistoryStore {
+	return &mockHistoryStore{messages: map[int64]*messages.ChatMessage{}, cursors: map[string]map[conversation.ID]int64{}}
+}
+
+func (m *mockHistoryStore) StoreMessage(message *messages.ChatMessage) error {
+	m.messages[message.Mid] = message
+	return nil
+}
+
+func (m *mockHistoryStore) StoreOffline(message *messages.ChatMessage) error {
+	return nil
+}
+
+func (m *mockHistoryStore) StoreChannelMessage(ch subscription.ChanID, msg *messages.ChatMessage) error {
+	m.messages[msg.Mid] = msg
+	return nil
+}
+
+func (m *mockHistoryStore) GetMessage(conv conversation.ID, mid int64) (*messages.ChatMessage, error) {
+	return m.messages[mid], nil
+}
+
+func (m *mockHistoryStore) MarkRecalled(conv conversation.ID, mid int64) error {
+	m.messages[mid].Content = ""
+	return nil
+}
+
+func (m *mockHistoryStore) EditMessage(conv conversation.ID, mid int64, content string, editAt int64) error {
+	m.messages[mid].Content = content
+	return nil
+}
+
+func (m *mockHistoryStore) GetBySeqRange(conv conversation.ID, start int64, end int64) ([]*messages.ChatMessage, error) {
+	m.queries++
+	var ret []*messages.ChatMessage
+	for _, msg := range m.messages {
+		if msg.Seq >= start && msg.Seq <= end {
+			ret = append(ret, msg)
+		}
+	}
+	return ret, nil
+}
+
+func (m *mockHistoryStore) UpdateReadCursor(uid string, conv conversation.ID, seq int64, readAt int64) (bool, error) {
+	if m.cursors[uid] == nil {
+		m.cursors[uid] = map[conversation.ID]int64{}
+	}
+	if m.cursors[uid][conv] >= seq {
+		return false, nil
+	}
+	m.cursors[uid][conv] = seq
+	return true, nil
+}
+
+func (m *mockHistoryStore) GetReadCursors(uid string) ([]*messages.ReadCursor, error) {
+	m.queries++
+	var ret []*messages.ReadCursor
+	for conv, seq := range m.cursors[uid] {
+		ret = append(ret, &messages.ReadCursor{Conversation: string(conv), Seq: seq})
+	}
+	return ret, nil
+}
+
+func (m *mockHistoryStore) GetReadCount(conv conversation.ID, seq int64) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockHistoryStore) Migrate() error {
+	return nil
+}
+
+// purgeHistoryStore supports listing conversations and purging users.
+type purgeHistoryStore struct {
+	*mockHistoryStore
+}
+
+func (p *purgeHistoryStore) GetRecentConversations(uid string, channels []string, limit int) ([]*ConversationSummary, error) {
+	var ret []*ConversationSummary
+	for _, m := range p.messages {
+		if m.From == uid || m.To == uid {
+			ret = append(ret, &ConversationSummary{Conversation: conversation.NewP2P(m.From, m.To).ID, Last: m})
+		}
+	}
+	return ret, nil
+}
+
+func (p *purgeHistoryStore) PurgeUser(uid string) error {
+	for mid, m := range p.messages {
+		if m.From == uid || m.To == uid {
+			delete(p.messages, mid)
+		}
+	}
+	return nil
+}
+
+func TestMemCache(t *testing.T) {
+	c := NewMemCache(2)
+	assert.NoError(t, c.Set("a", []byte("1"), time.Minute))
+	assert.NoError(t, c.Set("b", []byte("2"), time.Minute))
+	v, _ := c.Get("a")
+	assert.Equal(t, []byte("1"), v)
+
+	// b is the least recently used.
+	assert.NoError(t, c.Set("c", []byte("3"), time.Minute))
+	v, _ = c.Get("b")
+	assert.Nil(t, v)
+	v, _ = c.Get("a")
+	assert.Equal(t, []byte("1"), v)
+
+	assert.NoError(t, c.Delete("a"))
+	v, _ = c.Get("a")
+	assert.Nil(t, v)
+
+	assert.NoError(t, c.Set("d", []byte("4"), -time.Second))
+	v, _ = c.Get("d")
+	assert.Nil(t, v)
+}
+
+func TestCacheStore_GetBySeqRange(t *testing.T) {
+	ms := newMockHistoryStore()
+	cs := NewCacheStore(ms, CacheOptions{})
+	conv := conversation.NewP2P("u1", "u2").ID
+	for i := int64(1); i <= 3; i++ {
+		assert.NoError(t, cs.StoreMessage(&messages.ChatMessage{Mid: i, Seq: i, From: "u1", To: "u2"}))
+	}
+
+	got, err := cs.GetBySeqRange(conv, 1, 3)
+	assert.NoError(t, err)
+	assert.Len(t, got, 3)
+	// the sub range is served by the cache.
+	got, err = cs.GetBySeqRange(conv, 2, 3)
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, 1, ms.queries)
+
+	// invalidated on write.
+	assert.NoError(t, cs.StoreMessage(&messages.ChatMessage{Mid: 4, Seq: 4, From: "u2", To: "u1"}))
+	got, _ = cs.GetBySeqRange(conv, 1, 4)
+	assert.Len(t, got, 4)
+	assert.Equal(t, 2, ms.queries)
+
+	assert.NoError(t, cs.EditMessage(conv, 4, "edited", 0))
+	got, _ = cs.GetBySeqRange(conv, 1, 4)
+	assert.Equal(t, 3, ms.queries)
+	for _, m := range got {
+		if m.Mid == 4 {
+			assert.Equal(t, "edited", m.Content)
+		}
+	}
+}
+
+func TestCacheStore_ChannelRecall(t *testing.T) {
+	ms := newMockHistoryStore()
+	cs := NewCacheStore(ms, CacheOptions{})
+	conv := conversation.NewChannel("g").ID
+	for i := int64(1); i <= 2; i++ {
+		assert.NoError(t, cs.StoreChannelMessage("g", &messages.ChatMessage{Mid: i, Seq: i, From: "u1", To: "g", Content: "hi"}))
+	}
+	_, _ = cs.GetBySeqRange(conv, 1, 2)
+	assert.Equal(t, 1, ms.queries)
+
+	// the history of the channel is invalidated by the conversation of the recall.
+	assert.NoError(t, cs.MarkRecalled(conv, 2))
+	got, _ := cs.GetBySeqRange(conv, 1, 2)
+	assert.Equal(t, 2, ms.queries)
+	for _, m := range got {
+		if m.Mid == 2 {
+			assert.Empty(t, m.Content)
+		}
+	}
+
+	assert.NoError(t, cs.EditMessage(conv, 1, "edited", 0))
+	_, _ = cs.GetBySeqRange(conv, 1, 2)
+	assert.Equal(t, 3, ms.queries)
+}
+
+func TestCacheStore_ReadCursors(t *testing.T) {
+	ms := newMockHistoryStore()
+	cs := NewCacheStore(ms, CacheOptions{})
+
+	c1 := conversation.NewChannel("c1").ID
+	_, _ = cs.UpdateReadCursor("u1", c1, 1, 0)
+	_, _ = cs.GetReadCursors("u1")
+	cursors, err := cs.GetReadCursors("u1")
+	assert.NoError(t, err)
+	assert.Len(t, cursors, 1)
+	assert.Equal(t, 1, ms.queries)
+
+	_, _ = cs.UpdateReadCursor("u1", c1, 2, 0)
+	cursors, _ = cs.GetReadCursors("u1")
+	assert.Equal(t, int64(2), cursors[0].Seq)
+	assert.Equal(t, 2, ms.queries)
+}
+
+func TestAs(t *testing.T) {
+	ms := newMockHistoryStore()
+	cs := NewCacheStore(ms, CacheOptions{})
+	wb, err := NewWriteBehindStore(cs, WriteBehindOptions{})
+	assert.NoError(t, err)
+	defer wb.Close()
+
+	hs, ok := As[MessageHistoryStore](wb)
+	assert.True(t, ok)
+	assert.Equal(t, cs, hs)
+	_, ok = As[ScheduleStore](wb)
+	assert.False(t, ok)
+}
+
+func TestCacheStore_PurgeUser(t *testing.T) {
+	h := &purgeHistoryStore{newMockHistoryStore()}
+	_ = h.StoreMessage(&messages.ChatMessage{Mid: 1, From: "1", To: "2", Seq: 1})
+	c := NewCacheStore(h, CacheOptions{})
+	w, err := NewWriteBehindStore(c, WriteBehindOptions{FlushInterval: time.Hour, WALDir: t.TempDir()})
+	assert.NoError(t, err)
+	conv := conversation.NewP2P("1", "2").ID
+	hs, ok := As[MessageHistoryStore](w)
+	assert.True(t, ok)
+
+	ms, err := hs.GetBySeqRange(conv, 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, ms, 1)
+	// queued and not written yet.
+	assert.NoError(t, w.StoreMessage(&messages.ChatMessage{Mid: 2, From: "2", To: "1", Seq: 2}))
+
+	assert.NoError(t, w.PurgeUser("1"))
+	ms, err = hs.GetBySeqRange(conv, 1, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, ms)
+	assert.NoError(t, w.Close())
+	assert.Empty(t, h.messages)
+}
+
+type retentionHistoryStore struct {
+	*mockHistoryStore
+}
+
+func (r *retentionHistoryStore) GetConversations(after conversation.ID, limit int) ([]conversation.ID, error) {
+	return nil, nil
+}
+
+func (r *retentionHistoryStore) PurgeMessages(c conversation.ID, before int64, keep int64, limit int) (int64, error) {
+	var n int64
+	for mid, m := range r.messages {
+		if m.SendAt < before {
+			delete(r.messages, mid)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func TestCacheStore_PurgeMessages(t *testing.T) {
+	h := &retentionHistoryStore{newMockHistoryStore()}
+	_ = h.StoreMessage(&messages.ChatMessage{Mid: 1, From: "1", To: "2", Seq: 1, SendAt: 1})
+	c := NewCacheStore(h, CacheOptions{})
+	conv := conversation.NewP2P("1", "2").ID
+
+	ms, err := c.GetBySeqRange(conv, 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, ms, 1)
+
+	rs, ok := As[RetentionStore](c)
+	assert.True(t, ok)
+	n, err := rs.PurgeMessages(conv, 2, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	ms, err = c.GetBySeqRange(conv, 1, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, ms)
+
+	_, err = NewCacheStore(newMockHistoryStore(), CacheOptions{}).PurgeMessages(conv, 2, 0, 10)
+	assert.EqualError(t, err, errRetentionNotSupported)
+}
+
+type archiveHistoryStore struct {
+	*mockHistoryStore
+}
+
+func (a *archiveHistoryStore) GetArchivable(before int64, limit int) ([]*ArchivedMessage, error) {
+	var ret []*ArchivedMessage
+	for _, m := range a.messages {
+		if m.SendAt < before {
+			ret = append(ret, &ArchivedMessage{Conversation: conversation.NewP2P(m.From, m.To).ID, Message: m})
+		}
+	}
+	return ret, nil
+}
+
+func (a *archiveHistoryStore) RemoveArchived(ms []*ArchivedMessage) error {
+	for _, m := range ms {
+		delete(a.messages, m.Message.Mid)
+	}
+	return nil
+}
+
+func (a *archiveHistoryStore) RestoreArchived(ms []*ArchivedMessage) error {
+	for _, m := range ms {
+		a.messages[m.Message.Mid] = m.Message
+	}
+	return nil
+}
+
+func TestCacheStore_Archive(t *testing.T) {
+	h := &archiveHistoryStore{newMockHistoryStore()}
+	_ = h.StoreMessage(&messages.ChatMessage{Mid: 1, From: "1", To: "2", Seq: 1, SendAt: 1})
+	c := NewCacheStore(h, CacheOptions{})
+	conv := conversation.NewP2P("1", "2").ID
+
+	ms, err := c.GetBySeqRange(conv, 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, ms, 1)
+
+	as, ok := As[ArchiveStore](c)
+	assert.True(t, ok)
+	archived, err := as.GetArchivable(2, 10)
+	assert.NoError(t, err)
+	assert.Len(t, archived, 1)
+
+	assert.NoError(t, as.RemoveArchived(archived))
+	ms, err = c.GetBySeqRange(conv, 1, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, ms)
+
+	assert.NoError(t, as.RestoreArchived(archived))
+	ms, err = c.GetBySeqRange(conv, 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, ms, 1)
+
+	err = NewCacheStore(newMockHistoryStore(), CacheOptions{}).RemoveArchived(archived)
+	assert.EqualError(t, err, errArchiveNotSupported)
+}
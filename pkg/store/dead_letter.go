@@ -0,0 +1,93 @@
+package store
+
+import (
+	"sort"
+	"sync"
+)
+
+const defaultDeadLetterCapacity = 10000
+
+var _ DeadLetterStore = (*MemDeadLetterStore)(nil)
+var _ UserPurgeStore = (*MemDeadLetterStore)(nil)
+
+// MemDeadLetterStore is a DeadLetterStore in memory, the oldest dead letter is dropped when full, dead letters will be
+// lost after restart.
+type MemDeadLetterStore struct {
+	mu       sync.Mutex
+	capacity int
+	letters  map[int64]*DeadLetter
+}
+
+// NewMemDeadLetterStore returns the MemDeadLetterStore keeps at most capacity dead letters, default 10000.
+func NewMemDeadLetterStore(capacity int) *MemDeadLetterStore {
+	if capacity <= 0 {
+		capacity = defaultDeadLetterCapacity
+	}
+	return &MemDeadLetterStore{
+		capacity: capacity,
+		letters:  map[int64]*DeadLetter{},
+	}
+}
+
+func (m *MemDeadLetterStore) StoreDeadLetter(l *DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.letters[l.ID]; !ok && len(m.letters) >= m.capacity {
+		var oldest int64
+		for id := range m.letters {
+			if oldest == 0 || id < oldest {
+				oldest = id
+			}
+		}
+		delete(m.letters, oldest)
+	}
+	c := *l
+	m.letters[l.ID] = &c
+	return nil
+}
+
+func (m *MemDeadLetterStore) GetDeadLetters(after int64, limit int) ([]*DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ret []*DeadLetter
+	for id, l := range m.letters {
+		if id > after {
+			c := *l
+			ret = append(ret, &c)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ID < ret[j].ID
+	})
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
+
+func (m *MemDeadLetterStore) RemoveDeadLetter(id int64) (*DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.letters[id]
+	if !ok {
+		return nil, nil
+	}
+	delete(m.letters, id)
+	return l, nil
+}
+
+// PurgeUser removes dead letters of messages sent or received by uid.
+func (m *MemDeadLetterStore) PurgeUser(uid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, l := range m.letters {
+		if l.Message != nil && (l.Message.From == uid || l.Message.To == uid) {
+			delete(m.letters, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+var _ JoinRequestStore = (*MemJoinRequestStore)(nil)
+
+// MemJoinRequestStore is a JoinRequestStore in memory, requests will be lost after restart.
+type MemJoinRequestStore struct {
+	mu sync.Mutex
+	// channel => uid => request
+	requests map[string]map[string]*messages.JoinRequest
+}
+
+func NewMemJoinRequestStore() *MemJoinRequestStore {
+	return &MemJoinRequestStore{
+		requests: map[string]map[string]*messages.JoinRequest{},
+	}
+}
+
+func (m *MemJoinRequestStore) AddJoinRequest(r *messages.JoinRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rs, ok := m.requests[r.Channel]
+	if !ok {
+		rs = map[string]*messages.JoinRequest{}
+		m.requests[r.Channel] = rs
+	}
+	c := *r
+	rs[r.Uid] = &c
+	return nil
+}
+
+func (m *MemJoinRequestStore) RemoveJoinRequest(channel string, uid string) (*messages.JoinRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.requests[channel][uid]
+	if !ok {
+		return nil, nil
+	}
+	delete(m.requests[channel], uid)
+	if len(m.requests[channel]) == 0 {
+		delete(m.requests, channel)
+	}
+	return r, nil
+}
+
+func (m *MemJoinRequestStore) GetJoinRequests(channel string) ([]*messages.JoinRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ret := make([]*messages.JoinRequest, 0, len(m.requests[channel]))
+	for _, r := range m.requests[channel] {
+		c := *r
+		ret = append(ret, &c)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].RequestAt < ret[j].RequestAt
+	})
+	return ret, nil
+}
+
+func (m *MemJoinRequestStore) RemoveJoinRequestsBefore(before int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for channel, rs := range m.requests {
+		for uid, r := range rs {
+			if r.RequestAt < before {
+				delete(rs, uid)
+				n++
+			}
+		}
+		if len(rs) == 0 {
+			delete(m.requests, channel)
+		}
+	}
+	return n, nil
+}
@@ -2,7 +2,6 @@ package store
 
 import (
 	"github.com/Shopify/sarama"
-	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
 )
 
@@ -49,7 +48,7 @@ func (c *KafkaConsumer) run() error {
 				var cm = messages.ChatMessage{}
 				err2 := messages.JsonCodec.Decode(m.Value, &cm)
 				if err2 != nil {
-					logger.E("message decode error %v", err2)
+					log.E("message decode error %v", err2)
 					continue
 				}
 				if c.cf != nil {
@@ -68,7 +67,7 @@ func (c *KafkaConsumer) run() error {
 				var cm = messages.ChatMessage{}
 				err2 := messages.JsonCodec.Decode(m.Value, &cm)
 				if err2 != nil {
-					logger.E("message decode error %v", err2)
+					log.E("message decode error %v", err2)
 					continue
 				}
 				if c.channelCf != nil {
@@ -87,7 +86,7 @@ func (c *KafkaConsumer) run() error {
 				var cm = messages.ChatMessage{}
 				err2 := messages.JsonCodec.Decode(m.Value, &cm)
 				if err2 != nil {
-					logger.E("message decode error %v", err2)
+					log.E("message decode error %v", err2)
 					continue
 				}
 				if c.offlineCf != nil {
@@ -12,10 +12,12 @@ const (
 	KafkaChatMessageTopic        = "getaway_chat_message"
 	KafkaChatOfflineMessageTopic = "getaway_chat_offline_message"
 	KafkaChannelMessageTopic     = "gateway_channel_message"
+	KafkaDeadLetterTopic         = "gateway_dead_letter"
 )
 
 var _ MessageStore = &KafkaMessageStore{}
 var _ SubscriptionStore = &KafkaMessageStore{}
+var _ DeadLetterSink = &KafkaMessageStore{}
 
 type KafkaMessageStore struct {
 	producer sarama.AsyncProducer
@@ -117,3 +119,20 @@ func (k *KafkaMessageStore) StoreMessage(message *messages.ChatMessage) error {
 	k.producer.Input() <- cm
 	return nil
 }
+
+// StoreDeadLetter produces the dead letter to KafkaDeadLetterTopic, dead letters are inspected and redelivered by the
+// consumer.
+func (k *KafkaMessageStore) StoreDeadLetter(l *DeadLetter) error {
+	msgBytes, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	cm := &sarama.ProducerMessage{
+		Topic:     KafkaDeadLetterTopic,
+		Value:     &msg{data: msgBytes},
+		Timestamp: time.Now(),
+	}
+	k.producer.Input() <- cm
+	return nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"sync"
+)
+
+var _ ReactionStore = (*MemReactionStore)(nil)
+
+// MemReactionStore is a ReactionStore in memory, reactions will be lost after restart.
+type MemReactionStore struct {
+	mu sync.RWMutex
+	// mid => emoji => uid
+	reactions map[int64]map[string]map[string]struct{}
+}
+
+func NewMemReactionStore() *MemReactionStore {
+	return &MemReactionStore{
+		reactions: map[int64]map[string]map[string]struct{}{},
+	}
+}
+
+func (m *MemReactionStore) AddReaction(mid int64, uid string, emoji string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rs, ok := m.reactions[mid]
+	if !ok {
+		rs = map[string]map[string]struct{}{}
+		m.reactions[mid] = rs
+	}
+	users, ok := rs[emoji]
+	if !ok {
+		users = map[string]struct{}{}
+		rs[emoji] = users
+	}
+	if _, ok = users[uid]; ok {
+		return false, nil
+	}
+	users[uid] = struct{}{}
+	return true, nil
+}
+
+func (m *MemReactionStore) RemoveReaction(mid int64, uid string, emoji string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users, ok := m.reactions[mid][emoji]
+	if !ok {
+		return false, nil
+	}
+	if _, ok = users[uid]; !ok {
+		return false, nil
+	}
+	delete(users, uid)
+	if len(users) == 0 {
+		delete(m.reactions[mid], emoji)
+	}
+	if len(m.reactions[mid]) == 0 {
+		delete(m.reactions, mid)
+	}
+	return true, nil
+}
+
+func (m *MemReactionStore) GetReactions(mid int64) (map[string]int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ret := map[string]int64{}
+	for emoji, users := range m.reactions[mid] {
+		ret[emoji] = int64(len(users))
+	}
+	return ret, nil
+}
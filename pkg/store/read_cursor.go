@@ -0,0 +1,87 @@
+package store
+
+import (
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"sync"
+)
+
+var _ ReadCursorStore = (*MemReadCursorStore)(nil)
+var _ UserPurgeStore = (*MemReadCursorStore)(nil)
+var _ ReaderStore = (*MemReadCursorStore)(nil)
+
+// MemReadCursorStore is a ReadCursorStore in memory, cursors will be lost after restart.
+type MemReadCursorStore struct {
+	mu sync.RWMutex
+	// uid => conversation => cursor
+	cursors map[string]map[conversation.ID]*messages.ReadCursor
+}
+
+func NewMemReadCursorStore() *MemReadCursorStore {
+	return &MemReadCursorStore{
+		cursors: map[string]map[conversation.ID]*messages.ReadCursor{},
+	}
+}
+
+func (m *MemReadCursorStore) UpdateReadCursor(uid string, conv conversation.ID, seq int64, readAt int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cs, ok := m.cursors[uid]
+	if !ok {
+		cs = map[conversation.ID]*messages.ReadCursor{}
+		m.cursors[uid] = cs
+	}
+	c, ok := cs[conv]
+	if ok && c.Seq >= seq {
+		return false, nil
+	}
+	cs[conv] = &messages.ReadCursor{Conversation: string(conv), Seq: seq, ReadAt: readAt}
+	return true, nil
+}
+
+func (m *MemReadCursorStore) GetReadCursors(uid string) ([]*messages.ReadCursor, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ret []*messages.ReadCursor
+	for _, c := range m.cursors[uid] {
+		cc := *c
+		ret = append(ret, &cc)
+	}
+	return ret, nil
+}
+
+func (m *MemReadCursorStore) GetReadCount(conv conversation.ID, seq int64) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, cs := range m.cursors {
+		if c, ok := cs[conv]; ok && c.Seq >= seq {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemReadCursorStore) GetReaders(conv conversation.ID, seq int64, uids []string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ret []string
+	for _, uid := range uids {
+		if c, ok := m.cursors[uid][conv]; ok && c.Seq >= seq {
+			ret = append(ret, uid)
+		}
+	}
+	return ret, nil
+}
+
+// PurgeUser removes all read cursors of uid.
+func (m *MemReadCursorStore) PurgeUser(uid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.cursors, uid)
+	return nil
+}
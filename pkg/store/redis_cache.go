@@ -0,0 +1,43 @@
+package store
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const redisKeyCachePrefix = "im:cache:"
+
+var _ Cache = (*RedisCache)(nil)
+
+// RedisCache is the Cache shared by all nodes in redis, keys are prefixed with `im:cache:`.
+type RedisCache struct {
+	client *redis.Client
+}
+
+func NewRedisCache(client *redis.Client) *RedisCache {
+	return &RedisCache{client: client}
+}
+
+func (r *RedisCache) Get(key string) ([]byte, error) {
+	b, err := r.client.Get(redisKeyCachePrefix + key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return b, err
+}
+
+func (r *RedisCache) Set(key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(redisKeyCachePrefix+key, value, ttl).Err()
+}
+
+func (r *RedisCache) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ks := make([]string, len(keys))
+	for i, k := range keys {
+		ks[i] = redisKeyCachePrefix + k
+	}
+	return r.client.Del(ks...).Err()
+}
@@ -0,0 +1,281 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
+	"github.com/glide-im/glide/pkg/subscription"
+)
+
+const (
+	defaultStoreRetries     = 2
+	defaultStoreBackoff     = time.Millisecond * 50
+	defaultStoreMaxBackoff  = time.Second
+	defaultStoreTimeout     = time.Second * 3
+	defaultBreakerFailures  = 5
+	defaultBreakerOpenDelay = time.Second * 10
+)
+
+var (
+	ErrStoreTimeout = errors.New("message store call timeout")
+	ErrCircuitOpen  = errors.New("message store circuit breaker is open")
+)
+
+type ResilientOptions struct {
+	// Retries the max count of retries after a call failed, default 2, -1 to disable.
+	Retries int
+	// Backoff the delay before the first retry, doubled for each retry up to MaxBackoff, default 50ms.
+	Backoff time.Duration
+	// MaxBackoff the max delay between retries, default 1s.
+	MaxBackoff time.Duration
+	// Timeout the max duration of a call, ErrStoreTimeout is returned after timeout while the call may still complete
+	// in background, default 3s.
+	Timeout time.Duration
+	// BreakerFailures the count of consecutive failed calls opens the circuit breaker, default 5.
+	BreakerFailures int
+	// BreakerOpen the duration the breaker keeps open, calls fail fast with ErrCircuitOpen until a trial call
+	// succeeds after the duration, default 10s.
+	BreakerOpen time.Duration
+}
+
+// breaker is a circuit breaker, it opens after consecutive failures and allows one trial call at a time when the
+// open duration elapsed, the breaker closes if the trial succeeds.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	openDelay time.Duration
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.trial || time.Since(b.openedAt) < b.openDelay {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (b *breaker) done(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if err == nil {
+		if b.failures >= b.threshold {
+			log.I("message store recovered, circuit breaker closed")
+			metrics.StoreCircuitOpen.Set(0)
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		if b.failures == b.threshold {
+			log.W("message store failed %d times, circuit breaker opened: %v", b.failures, err)
+			metrics.StoreCircuitOpen.Set(1)
+		}
+		b.openedAt = time.Now()
+	}
+}
+
+var _ MessageStore = (*ResilientStore)(nil)
+var _ BatchMessageStore = (*ResilientStore)(nil)
+
+// ResilientStore wraps the MessageStore with timeout, retry with exponential backoff and circuit breaking, a slow or
+// unavailable database fails calls fast instead of blocking the message loop. Wrapped by WriteBehindStore, messages
+// are kept in queue while the breaker is open. Only writes of MessageStore are wrapped, use ResilientHistoryStore to
+// wrap reads of MessageHistoryStore as well.
+type ResilientStore struct {
+	store   MessageStore
+	opts    ResilientOptions
+	breaker *breaker
+}
+
+func NewResilientStore(store MessageStore, opts ResilientOptions) *ResilientStore {
+	if opts.Retries == 0 {
+		opts.Retries = defaultStoreRetries
+	} else if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultStoreBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultStoreMaxBackoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultStoreTimeout
+	}
+	if opts.BreakerFailures <= 0 {
+		opts.BreakerFailures = defaultBreakerFailures
+	}
+	if opts.BreakerOpen <= 0 {
+		opts.BreakerOpen = defaultBreakerOpenDelay
+	}
+	return &ResilientStore{
+		store:   store,
+		opts:    opts,
+		breaker: &breaker{threshold: opts.BreakerFailures, openDelay: opts.BreakerOpen},
+	}
+}
+
+func (r *ResilientStore) Unwrap() MessageStore {
+	return r.store
+}
+
+func (r *ResilientStore) StoreMessage(message *messages.ChatMessage) error {
+	return r.call(func() error { return r.store.StoreMessage(message) })
+}
+
+func (r *ResilientStore) StoreOffline(message *messages.ChatMessage) error {
+	return r.call(func() error { return r.store.StoreOffline(message) })
+}
+
+func (r *ResilientStore) StoreMessages(ms []*messages.ChatMessage) error {
+	bs, ok := r.store.(BatchMessageStore)
+	if !ok {
+		for _, m := range ms {
+			if err := r.StoreMessage(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return r.call(func() error { return bs.StoreMessages(ms) })
+}
+
+// call calls fn with retries, it fails fast if the breaker is open.
+func (r *ResilientStore) call(fn func() error) error {
+	_, err := resilientCall(r, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// resilientCall calls fn of r with retries and returns the result of the succeeded call, the result of a timeout call
+// is dropped.
+func resilientCall[T any](r *ResilientStore, fn func() (T, error)) (T, error) {
+	var ret T
+	var err error
+	backoff := r.opts.Backoff
+	for i := 0; i <= r.opts.Retries; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > r.opts.MaxBackoff {
+				backoff = r.opts.MaxBackoff
+			}
+		}
+		if !r.breaker.allow() {
+			metrics.StoreFailures.WithLabelValues("circuit_open").Inc()
+			var zero T
+			return zero, ErrCircuitOpen
+		}
+		ret, err = withTimeout(r.opts.Timeout, fn)
+		r.breaker.done(err)
+		if err == nil {
+			return ret, nil
+		}
+	}
+	if err == ErrStoreTimeout {
+		metrics.StoreFailures.WithLabelValues("timeout").Inc()
+	} else {
+		metrics.StoreFailures.WithLabelValues("error").Inc()
+	}
+	return ret, err
+}
+
+type callResult[T any] struct {
+	v   T
+	err error
+}
+
+func withTimeout[T any](timeout time.Duration, fn func() (T, error)) (T, error) {
+	ch := make(chan callResult[T], 1)
+	go func() {
+		v, err := fn()
+		ch <- callResult[T]{v: v, err: err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		return r.v, r.err
+	case <-timer.C:
+		var zero T
+		return zero, ErrStoreTimeout
+	}
+}
+
+var _ MessageHistoryStore = (*ResilientHistoryStore)(nil)
+
+// ResilientHistoryStore is a ResilientStore of MessageHistoryStore, reads of history, messages and read cursors share
+// the timeout, retries and circuit breaker with writes. Migrate and optional interfaces available by Unwrap, such as
+// ConversationStore, are not wrapped.
+type ResilientHistoryStore struct {
+	*ResilientStore
+	history MessageHistoryStore
+}
+
+func NewResilientHistoryStore(store MessageHistoryStore, opts ResilientOptions) *ResilientHistoryStore {
+	return &ResilientHistoryStore{
+		ResilientStore: NewResilientStore(store, opts),
+		history:        store,
+	}
+}
+
+func (r *ResilientHistoryStore) StoreChannelMessage(ch subscription.ChanID, msg *messages.ChatMessage) error {
+	return r.call(func() error { return r.history.StoreChannelMessage(ch, msg) })
+}
+
+func (r *ResilientHistoryStore) GetMessage(c conversation.ID, mid int64) (*messages.ChatMessage, error) {
+	return resilientCall(r.ResilientStore, func() (*messages.ChatMessage, error) {
+		return r.history.GetMessage(c, mid)
+	})
+}
+
+func (r *ResilientHistoryStore) MarkRecalled(c conversation.ID, mid int64) error {
+	return r.call(func() error { return r.history.MarkRecalled(c, mid) })
+}
+
+func (r *ResilientHistoryStore) EditMessage(c conversation.ID, mid int64, content string, editAt int64) error {
+	return r.call(func() error { return r.history.EditMessage(c, mid, content, editAt) })
+}
+
+func (r *ResilientHistoryStore) GetBySeqRange(c conversation.ID, start int64, end int64) ([]*messages.ChatMessage, error) {
+	return resilientCall(r.ResilientStore, func() ([]*messages.ChatMessage, error) {
+		return r.history.GetBySeqRange(c, start, end)
+	})
+}
+
+func (r *ResilientHistoryStore) UpdateReadCursor(uid string, c conversation.ID, seq int64, readAt int64) (bool, error) {
+	return resilientCall(r.ResilientStore, func() (bool, error) {
+		return r.history.UpdateReadCursor(uid, c, seq, readAt)
+	})
+}
+
+func (r *ResilientHistoryStore) GetReadCursors(uid string) ([]*messages.ReadCursor, error) {
+	return resilientCall(r.ResilientStore, func() ([]*messages.ReadCursor, error) {
+		return r.history.GetReadCursors(uid)
+	})
+}
+
+func (r *ResilientHistoryStore) GetReadCount(c conversation.ID, seq int64) (int64, error) {
+	return resilientCall(r.ResilientStore, func() (int64, error) {
+		return r.history.GetReadCount(c, seq)
+	})
+}
+
+func (r *ResilientHistoryStore) Migrate() error {
+	return r.history.Migrate()
+}
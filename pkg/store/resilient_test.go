@@ -0,0 +1,120 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyStore fails calls while err is set.
+type flakyStore struct {
+	mu    sync.Mutex
+	err   error
+	delay time.Duration
+	calls int
+}
+
+func (f *flakyStore) setErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *flakyStore) StoreMessage(message *messages.ChatMessage) error {
+	f.mu.Lock()
+	f.calls++
+	err, delay := f.err, f.delay
+	f.mu.Unlock()
+	time.Sleep(delay)
+	return err
+}
+
+func (f *flakyStore) StoreOffline(message *messages.ChatMessage) error {
+	return f.StoreMessage(message)
+}
+
+func TestResilientStore_Retry(t *testing.T) {
+	fs := &flakyStore{err: errors.New("down")}
+	rs := NewResilientStore(fs, ResilientOptions{Retries: 2, Backoff: time.Millisecond, BreakerFailures: 100})
+
+	assert.EqualError(t, rs.StoreMessage(&messages.ChatMessage{}), "down")
+	assert.Equal(t, 3, fs.calls)
+
+	fs.setErr(nil)
+	assert.NoError(t, rs.StoreMessage(&messages.ChatMessage{}))
+	assert.Equal(t, 4, fs.calls)
+}
+
+func TestResilientStore_Timeout(t *testing.T) {
+	fs := &flakyStore{delay: time.Millisecond * 100}
+	rs := NewResilientStore(fs, ResilientOptions{Retries: -1, Timeout: time.Millisecond * 10})
+	assert.ErrorIs(t, rs.StoreMessage(&messages.ChatMessage{}), ErrStoreTimeout)
+}
+
+func TestResilientStore_Breaker(t *testing.T) {
+	fs := &flakyStore{err: errors.New("down")}
+	rs := NewResilientStore(fs, ResilientOptions{Retries: -1, BreakerFailures: 2, BreakerOpen: time.Millisecond * 50})
+
+	assert.Error(t, rs.StoreMessage(&messages.ChatMessage{}))
+	assert.Error(t, rs.StoreMessage(&messages.ChatMessage{}))
+	// fails fast while open.
+	assert.ErrorIs(t, rs.StoreMessage(&messages.ChatMessage{}), ErrCircuitOpen)
+	assert.Equal(t, 2, fs.calls)
+
+	// the trial call after open duration closes the breaker.
+	fs.setErr(nil)
+	time.Sleep(time.Millisecond * 60)
+	assert.NoError(t, rs.StoreMessage(&messages.ChatMessage{}))
+	assert.NoError(t, rs.StoreMessage(&messages.ChatMessage{}))
+	assert.Equal(t, 4, fs.calls)
+}
+
+// slowHistoryStore delays reads of history.
+type slowHistoryStore struct {
+	*mockHistoryStore
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+func (s *slowHistoryStore) setDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = delay
+}
+
+func (s *slowHistoryStore) GetBySeqRange(conv conversation.ID, start int64, end int64) ([]*messages.ChatMessage, error) {
+	s.mu.Lock()
+	delay := s.delay
+	s.mu.Unlock()
+	time.Sleep(delay)
+	return []*messages.ChatMessage{{Seq: start}}, nil
+}
+
+func TestResilientHistoryStore(t *testing.T) {
+	hs := &slowHistoryStore{mockHistoryStore: newMockHistoryStore()}
+	rs := NewResilientHistoryStore(hs, ResilientOptions{Retries: -1, Timeout: time.Millisecond * 20, BreakerFailures: 2})
+	conv := conversation.NewP2P("1", "2").ID
+
+	assert.NoError(t, rs.StoreMessage(&messages.ChatMessage{Mid: 1, From: "1", To: "2", Seq: 1}))
+	ms, err := rs.GetBySeqRange(conv, 1, 1)
+	assert.NoError(t, err)
+	assert.Len(t, ms, 1)
+	m, err := rs.GetMessage(conv, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), m.Mid)
+
+	hs.setDelay(time.Millisecond * 100)
+	_, err = rs.GetBySeqRange(conv, 1, 1)
+	assert.ErrorIs(t, err, ErrStoreTimeout)
+	_, err = rs.GetBySeqRange(conv, 1, 1)
+	assert.ErrorIs(t, err, ErrStoreTimeout)
+	// the breaker is shared by reads and writes.
+	_, err = rs.GetReadCursors("1")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorIs(t, rs.StoreMessage(&messages.ChatMessage{Mid: 2}), ErrCircuitOpen)
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"sort"
+	"sync"
+)
+
+var _ ScheduleStore = (*MemScheduleStore)(nil)
+
+// MemScheduleStore is a ScheduleStore in memory, scheduled messages will be lost after restart.
+type MemScheduleStore struct {
+	mu        sync.Mutex
+	scheduled map[int64]*ScheduledMessage
+}
+
+func NewMemScheduleStore() *MemScheduleStore {
+	return &MemScheduleStore{
+		scheduled: map[int64]*ScheduledMessage{},
+	}
+}
+
+func (m *MemScheduleStore) StoreScheduled(sm *ScheduledMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scheduled[sm.ID] = sm
+	return nil
+}
+
+func (m *MemScheduleStore) RemoveScheduled(id int64, from string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sm, ok := m.scheduled[id]
+	if !ok || (from != "" && sm.From != from) {
+		return false, nil
+	}
+	delete(m.scheduled, id)
+	return true, nil
+}
+
+func (m *MemScheduleStore) GetScheduled(before int64) ([]*ScheduledMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ret []*ScheduledMessage
+	for _, sm := range m.scheduled {
+		if sm.DeliverAt < before {
+			ret = append(ret, sm)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].DeliverAt < ret[j].DeliverAt
+	})
+	return ret, nil
+}
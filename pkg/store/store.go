@@ -1,10 +1,15 @@
 package store
 
 import (
+	"errors"
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
 	"github.com/glide-im/glide/pkg/subscription"
 )
 
+var log = logger.Named("store")
+
 // MessageStore is a store for messages, used to store chat messages in messaging.Interface, its many be called multiple times,
 // but only the last updates will be stored.
 type MessageStore interface {
@@ -23,3 +28,245 @@ type SubscriptionStore interface {
 	// StoreChannelMessage stores a published message.
 	StoreChannelMessage(ch subscription.ChanID, msg *messages.ChatMessage) error
 }
+
+// MessageRecallStore is implemented by MessageStore that supports message recalling.
+type MessageRecallStore interface {
+
+	// GetMessage returns the stored message of the conversation by server message id, messages of P2P and channel
+	// may be stored separately and have the same id.
+	GetMessage(c conversation.ID, mid int64) (*messages.ChatMessage, error)
+
+	// MarkRecalled marks the message of the conversation recalled.
+	MarkRecalled(c conversation.ID, mid int64) error
+}
+
+// ErrMessageRecalled is returned by MessageEditStore.EditMessage if the message is recalled.
+var ErrMessageRecalled = errors.New("message is recalled")
+
+// MessageEditStore is implemented by MessageStore that supports message editing.
+type MessageEditStore interface {
+
+	// GetMessage returns the stored message of the conversation by server message id.
+	GetMessage(c conversation.ID, mid int64) (*messages.ChatMessage, error)
+
+	// EditMessage updates the content of message of the conversation, the previous content is kept in the edit history.
+	// ErrMessageRecalled is returned if the message is recalled.
+	EditMessage(c conversation.ID, mid int64, content string, editAt int64) error
+}
+
+// OfflineRemoveStore is implemented by MessageStore that supports removing message from the offline queue.
+type OfflineRemoveStore interface {
+
+	// RemoveOffline removes the message of mid from the offline queue of uid.
+	RemoveOffline(uid string, mid int64) error
+}
+
+// UserPurgeStore is implemented by stores that support deleting all data of a user, such as MessageHistoryStore and
+// ReadCursorStore.
+type UserPurgeStore interface {
+
+	// PurgeUser deletes all data of uid in the store, such as messages sent by uid, P2P messages received by uid, the
+	// offline queue and read cursors of uid.
+	PurgeUser(uid string) error
+}
+
+// Pinger is implemented by stores that support checking the connection to the database, used by health checks.
+type Pinger interface {
+	Ping() error
+}
+
+// ChannelPurgeStore is implemented by SubscriptionStore that supports deleting all data of a channel, used to clean
+// up temporary channels expired.
+type ChannelPurgeStore interface {
+
+	// PurgeChannel deletes messages, read cursors, the sequence and join requests of the channel.
+	PurgeChannel(ch subscription.ChanID) error
+}
+
+// ArchivedMessage the message of conversation exported from the message history by the archiver.
+type ArchivedMessage struct {
+	Conversation conversation.ID       `json:"conversation"`
+	Recalled     bool                  `json:"recalled,omitempty"`
+	Message      *messages.ChatMessage `json:"message"`
+}
+
+// ArchiveStore is implemented by MessageStore that supports archiving message history.
+type ArchiveStore interface {
+
+	// GetArchivable returns at most limit messages of all conversations sent before the unix seconds, ordered by the
+	// time sent.
+	GetArchivable(before int64, limit int) ([]*ArchivedMessage, error)
+
+	// RemoveArchived removes the archived messages from message history.
+	RemoveArchived(ms []*ArchivedMessage) error
+
+	// RestoreArchived stores the archived messages back to message history, existing messages are skipped.
+	RestoreArchived(ms []*ArchivedMessage) error
+}
+
+// RetentionStore is implemented by MessageStore that supports purging messages by retention policies.
+type RetentionStore interface {
+
+	// GetConversations returns at most limit conversations which ID is greater than after, ordered by ID, used to
+	// sweep all conversations page by page.
+	GetConversations(after conversation.ID, limit int) ([]conversation.ID, error)
+
+	// PurgeMessages deletes at most limit messages of the conversation sent before the unix seconds, and messages
+	// except the latest keep messages if keep > 0, the oldest messages are deleted first. before <= 0 purges messages
+	// regardless of the time sent, returns the count deleted.
+	PurgeMessages(c conversation.ID, before int64, keep int64, limit int) (int64, error)
+}
+
+// ConversationSummary the latest message of a conversation.
+type ConversationSummary struct {
+	Conversation conversation.ID
+	Last         *messages.ChatMessage
+}
+
+// ConversationStore is implemented by MessageStore that supports listing recent conversations of a user.
+type ConversationStore interface {
+
+	// GetRecentConversations returns at most limit conversations ordered by the time of the latest message descending,
+	// the conversations are P2P conversations uid sent or received messages in, and conversations of channels.
+	GetRecentConversations(uid string, channels []string, limit int) ([]*ConversationSummary, error)
+}
+
+// ScheduledMessage the message waiting to be delivered at DeliverAt.
+type ScheduledMessage struct {
+	ID int64
+	// From the uid of sender.
+	From string
+	// DeliverAt the unix seconds to deliver the message at.
+	DeliverAt int64
+	Message   *messages.GlideMessage
+}
+
+// ScheduleStore persists scheduled messages until they are delivered or canceled.
+type ScheduleStore interface {
+
+	// StoreScheduled stores the scheduled message.
+	StoreScheduled(m *ScheduledMessage) error
+
+	// RemoveScheduled removes the scheduled message of id sent by from, empty from matches all senders, returns false
+	// if the message does not exist.
+	RemoveScheduled(id int64, from string) (bool, error)
+
+	// GetScheduled returns scheduled messages to deliver before the unix seconds, ordered by DeliverAt.
+	GetScheduled(before int64) ([]*ScheduledMessage, error)
+}
+
+const (
+	// DeadLetterExpired the message expired in the offline queue of the receiver before delivered.
+	DeadLetterExpired = "expired"
+	// DeadLetterRetriesExhausted the message failed to be delivered or queued after retries.
+	DeadLetterRetriesExhausted = "retries_exhausted"
+	// DeadLetterNoReceiver the receiver of the message does not exist.
+	DeadLetterNoReceiver = "no_receiver"
+)
+
+// DeadLetter the message could not be delivered, kept for inspection and redelivery.
+type DeadLetter struct {
+	ID int64 `json:"id"`
+	// Reason why the message is undeliverable, such as DeadLetterExpired.
+	Reason string `json:"reason"`
+	// Error the error message of the last failure, if any.
+	Error string `json:"error,omitempty"`
+	// FailedAt the unix seconds the message is dead lettered.
+	FailedAt int64                 `json:"failed_at"`
+	Message  *messages.ChatMessage `json:"message"`
+}
+
+// DeadLetterSink receives undeliverable messages, such as the Kafka topic consumed by the business service.
+type DeadLetterSink interface {
+
+	// StoreDeadLetter stores the dead letter.
+	StoreDeadLetter(l *DeadLetter) error
+}
+
+// DeadLetterStore is a DeadLetterSink supports inspecting and removing dead letters for redelivery.
+type DeadLetterStore interface {
+	DeadLetterSink
+
+	// GetDeadLetters returns at most limit dead letters of id greater than after, ordered by id.
+	GetDeadLetters(after int64, limit int) ([]*DeadLetter, error)
+
+	// RemoveDeadLetter removes and returns the dead letter of id, nil if not exist.
+	RemoveDeadLetter(id int64) (*DeadLetter, error)
+}
+
+// ReadCursorStore stores read cursors of users in conversations.
+type ReadCursorStore interface {
+
+	// UpdateReadCursor moves the read cursor of uid in conversation forward to seq, returns false if the cursor is
+	// already at or beyond seq.
+	UpdateReadCursor(uid string, c conversation.ID, seq int64, readAt int64) (bool, error)
+
+	// GetReadCursors returns all read cursors of uid.
+	GetReadCursors(uid string) ([]*messages.ReadCursor, error)
+
+	// GetReadCount returns the count of users whose read cursor in conversation is at or beyond seq.
+	GetReadCount(c conversation.ID, seq int64) (int64, error)
+}
+
+// ReaderStore lists readers of messages, implemented optionally by ReadCursorStore implementations.
+type ReaderStore interface {
+
+	// GetReaders returns users of uids whose read cursor in conversation is at or beyond seq.
+	GetReaders(c conversation.ID, seq int64, uids []string) ([]string, error)
+}
+
+// ReactionStore stores reactions of users to messages, aggregated per emoji.
+type ReactionStore interface {
+
+	// AddReaction adds the reaction of uid with emoji to the message, returns false if uid already reacted with emoji.
+	AddReaction(mid int64, uid string, emoji string) (bool, error)
+
+	// RemoveReaction removes the reaction of uid with emoji from the message, returns false if uid did not react with
+	// emoji.
+	RemoveReaction(mid int64, uid string, emoji string) (bool, error)
+
+	// GetReactions returns the count of users reacted with each emoji to the message.
+	GetReactions(mid int64) (map[string]int64, error)
+}
+
+// ThreadStore is implemented by MessageStore that supports querying threads of replies, see messages.ChatMessage
+// Thread.
+type ThreadStore interface {
+
+	// GetThread returns at most limit messages of the thread in the conversation, the root message of thread and the
+	// replies, which sequence is not less than start, ordered by sequence.
+	GetThread(c conversation.ID, thread int64, start int64, limit int) ([]*messages.ChatMessage, error)
+}
+
+// JoinRequestStore stores pending requests of users to join channels.
+type JoinRequestStore interface {
+
+	// AddJoinRequest adds the pending request, the pending request of the same user to the channel is replaced.
+	AddJoinRequest(r *messages.JoinRequest) error
+
+	// RemoveJoinRequest removes the pending request of uid to the channel, returns nil if there is no pending request.
+	RemoveJoinRequest(channel string, uid string) (*messages.JoinRequest, error)
+
+	// GetJoinRequests returns pending requests to the channel, ordered by the time requested.
+	GetJoinRequests(channel string) ([]*messages.JoinRequest, error)
+
+	// RemoveJoinRequestsBefore removes requests made before the unix seconds, returns the count removed.
+	RemoveJoinRequestsBefore(before int64) (int64, error)
+}
+
+// MessageHistoryStore is a MessageStore persists message history to database, implement it to support a new database.
+type MessageHistoryStore interface {
+	MessageStore
+	MessageRecallStore
+	MessageEditStore
+	ReadCursorStore
+
+	// StoreChannelMessage stores a message published to channel.
+	StoreChannelMessage(ch subscription.ChanID, msg *messages.ChatMessage) error
+
+	// GetBySeqRange returns messages of the conversation which sequence is in [start, end], ordered by sequence.
+	GetBySeqRange(conversation conversation.ID, start int64, end int64) ([]*messages.ChatMessage, error)
+
+	// Migrate creates or updates the schema of database.
+	Migrate() error
+}
@@ -0,0 +1,199 @@
+// Package storetest provides the in-memory fake of stores for applications embedding glide to unit test their message
+// handlers without database.
+package storetest
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/snowflake"
+	"github.com/glide-im/glide/pkg/store"
+)
+
+const errMessageNotFound = "message not found"
+
+// ErrMessageNotFound returned by GetMessage if the message of mid is not stored.
+var ErrMessageNotFound = errors.New(errMessageNotFound)
+
+var _ store.MessageStore = (*MessageStore)(nil)
+var _ store.MessageRecallStore = (*MessageStore)(nil)
+var _ store.MessageEditStore = (*MessageStore)(nil)
+var _ store.OfflineRemoveStore = (*MessageStore)(nil)
+var _ store.ReadCursorStore = (*MessageStore)(nil)
+
+// MessageStore is an in-memory fake of store.MessageStore, it supports recalling, editing, removing offline messages
+// and read cursors, it's safe for concurrent use.
+type MessageStore struct {
+	mu       sync.Mutex
+	err      error
+	messages []*messages.ChatMessage
+	byMid    map[int64]*messages.ChatMessage
+	recalled map[int64]bool
+	offline  map[string][]*messages.ChatMessage
+	cursors  map[string]map[conversation.ID]*messages.ReadCursor
+}
+
+func NewMessageStore() *MessageStore {
+	return &MessageStore{
+		byMid:    map[int64]*messages.ChatMessage{},
+		recalled: map[int64]bool{},
+		offline:  map[string][]*messages.ChatMessage{},
+		cursors:  map[string]map[conversation.ID]*messages.ReadCursor{},
+	}
+}
+
+// SetError sets the error returned by StoreMessage and StoreOffline to simulate the failure of database, nil to
+// recover.
+func (s *MessageStore) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *MessageStore) StoreMessage(message *messages.ChatMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if message.Mid == 0 {
+		message.Mid = snowflake.Generate()
+	}
+	if _, ok := s.byMid[message.Mid]; ok {
+		return nil
+	}
+	m := *message
+	s.messages = append(s.messages, &m)
+	s.byMid[m.Mid] = &m
+	return nil
+}
+
+func (s *MessageStore) StoreOffline(message *messages.ChatMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	m := *message
+	s.offline[m.To] = append(s.offline[m.To], &m)
+	return nil
+}
+
+// Messages returns copies of the messages stored in the order stored.
+func (s *MessageStore) Messages() []*messages.ChatMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms := make([]*messages.ChatMessage, 0, len(s.messages))
+	for _, m := range s.messages {
+		c := *m
+		ms = append(ms, &c)
+	}
+	return ms
+}
+
+// Offline returns copies of the offline messages of uid.
+func (s *MessageStore) Offline(uid string) []*messages.ChatMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ms []*messages.ChatMessage
+	for _, m := range s.offline[uid] {
+		c := *m
+		ms = append(ms, &c)
+	}
+	return ms
+}
+
+// IsRecalled returns true if the message of mid is marked recalled.
+func (s *MessageStore) IsRecalled(mid int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.recalled[mid]
+}
+
+func (s *MessageStore) GetMessage(c conversation.ID, mid int64) (*messages.ChatMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.byMid[mid]
+	if !ok || conversation.NewP2P(m.From, m.To).ID != c {
+		return nil, ErrMessageNotFound
+	}
+	cm := *m
+	return &cm, nil
+}
+
+func (s *MessageStore) MarkRecalled(c conversation.ID, mid int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, ok := s.byMid[mid]; !ok || conversation.NewP2P(m.From, m.To).ID != c {
+		return ErrMessageNotFound
+	}
+	s.recalled[mid] = true
+	return nil
+}
+
+func (s *MessageStore) EditMessage(c conversation.ID, mid int64, content string, _ int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.byMid[mid]
+	if !ok || conversation.NewP2P(m.From, m.To).ID != c {
+		return ErrMessageNotFound
+	}
+	if s.recalled[mid] {
+		return store.ErrMessageRecalled
+	}
+	m.Content = content
+	return nil
+}
+
+func (s *MessageStore) RemoveOffline(uid string, mid int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms := s.offline[uid]
+	for i, m := range ms {
+		if m.Mid == mid {
+			s.offline[uid] = append(ms[:i:i], ms[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MessageStore) UpdateReadCursor(uid string, conv conversation.ID, seq int64, readAt int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cs, ok := s.cursors[uid]
+	if !ok {
+		cs = map[conversation.ID]*messages.ReadCursor{}
+		s.cursors[uid] = cs
+	}
+	if c, ok := cs[conv]; ok && c.Seq >= seq {
+		return false, nil
+	}
+	cs[conv] = &messages.ReadCursor{Conversation: string(conv), Seq: seq, ReadAt: readAt}
+	return true, nil
+}
+
+func (s *MessageStore) GetReadCursors(uid string) ([]*messages.ReadCursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cs []*messages.ReadCursor
+	for _, c := range s.cursors[uid] {
+		cp := *c
+		cs = append(cs, &cp)
+	}
+	return cs, nil
+}
+
+func (s *MessageStore) GetReadCount(conv conversation.ID, seq int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, cs := range s.cursors {
+		if c, ok := cs[conv]; ok && c.Seq >= seq {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,50 @@
+package storetest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/glide-im/glide/pkg/conversation"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageStore(t *testing.T) {
+	s := NewMessageStore()
+	m := &messages.ChatMessage{From: "1", To: "2", Content: "hi"}
+	assert.NoError(t, s.StoreMessage(m))
+	assert.NotZero(t, m.Mid)
+	assert.NoError(t, s.StoreOffline(m))
+	assert.Len(t, s.Messages(), 1)
+	assert.Len(t, s.Offline("2"), 1)
+
+	c := conversation.NewP2P("1", "2").ID
+	assert.NoError(t, s.EditMessage(c, m.Mid, "hello", 1))
+	stored, err := s.GetMessage(c, m.Mid)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", stored.Content)
+	assert.NoError(t, s.MarkRecalled(c, m.Mid))
+	assert.True(t, s.IsRecalled(m.Mid))
+	// the recalled message can not be edited.
+	assert.ErrorIs(t, s.EditMessage(c, m.Mid, "hi", 2), store.ErrMessageRecalled)
+	_, err = s.GetMessage(c, 1)
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+	// the message is not in the conversation.
+	_, err = s.GetMessage(conversation.NewP2P("1", "3").ID, m.Mid)
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+
+	assert.NoError(t, s.RemoveOffline("2", m.Mid))
+	assert.Empty(t, s.Offline("2"))
+
+	ok, _ := s.UpdateReadCursor("2", c, 5, 1)
+	assert.True(t, ok)
+	ok, _ = s.UpdateReadCursor("2", c, 3, 1)
+	assert.False(t, ok)
+	n, _ := s.GetReadCount(c, 4)
+	assert.Equal(t, int64(1), n)
+
+	injected := errors.New("down")
+	s.SetError(injected)
+	assert.ErrorIs(t, s.StoreMessage(&messages.ChatMessage{}), injected)
+}
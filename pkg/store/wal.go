@@ -0,0 +1,230 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/glide-im/glide/pkg/messages"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const defaultWALSegmentSize = 64 << 20
+
+// walEntry is a message waiting to be written to store.
+type walEntry struct {
+	Index   uint64                `json:"i"`
+	Kind    int                   `json:"k"`
+	Channel string                `json:"c,omitempty"`
+	Message *messages.ChatMessage `json:"m"`
+}
+
+// wal is a write ahead log of messages, entries are appended to segment files and fsync-ed before the message is
+// acknowledged, the segment is removed after all entries in it are written to store.
+type wal struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+
+	f    *os.File
+	size int64
+	next uint64
+	// closed segments and the last entry index of them.
+	segments []walSegment
+}
+
+type walSegment struct {
+	path string
+	last uint64
+}
+
+func openWAL(dir string, maxSize int64) (*wal, []*walEntry, error) {
+	if maxSize <= 0 {
+		maxSize = defaultWALSegmentSize
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, nil, err
+	}
+	w := &wal{dir: dir, maxSize: maxSize, next: 1}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(paths)
+	var entries []*walEntry
+	for _, p := range paths {
+		es, err := readSegment(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, es...)
+		last := uint64(0)
+		if len(es) > 0 {
+			last = es[len(es)-1].Index
+			if last >= w.next {
+				w.next = last + 1
+			}
+		}
+		w.segments = append(w.segments, walSegment{path: p, last: last})
+	}
+	return w, entries, w.rotate()
+}
+
+func readSegment(path string) ([]*walEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*walEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		e := &walEntry{}
+		// the last line may be partially written when crashed.
+		if json.Unmarshal(scanner.Bytes(), e) != nil {
+			break
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// append writes the entry to log and fsync.
+func (w *wal) append(e *walEntry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	e.Index = w.next
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	n, err := w.f.Write(b)
+	if err != nil {
+		return err
+	}
+	err = w.f.Sync()
+	if err != nil {
+		return err
+	}
+	w.next++
+	w.size += int64(n)
+	if w.size >= w.maxSize {
+		w.segments = append(w.segments, walSegment{path: w.f.Name(), last: e.Index})
+		_ = w.f.Close()
+		return w.rotate()
+	}
+	return nil
+}
+
+func (w *wal) rotate() error {
+	f, err := os.OpenFile(filepath.Join(w.dir, fmt.Sprintf("%020d.wal", w.next)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.f = f
+	w.size = 0
+	return nil
+}
+
+// checkpoint removes the closed segments which all entries are written to store.
+func (w *wal) checkpoint(index uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i := 0
+	for ; i < len(w.segments) && w.segments[i].last <= index; i++ {
+		_ = os.Remove(w.segments[i].path)
+	}
+	w.segments = w.segments[i:]
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+// clear closes and removes all log files, called after all entries are written to store.
+func (w *wal) clear() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.f.Close()
+	for _, s := range w.segments {
+		_ = os.Remove(s.path)
+	}
+	w.segments = nil
+	_ = os.Remove(w.f.Name())
+	return err
+}
+
+// rewrite replaces all log files with a segment of entries, used to drop entries not to be written to store, such as
+// messages of the purged user. entries must be the only entries not written to store, and no entry is appended while
+// rewriting.
+func (w *wal) rewrite(entries []*walEntry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var segments []walSegment
+	path := ""
+	if len(entries) > 0 {
+		path = filepath.Join(w.dir, fmt.Sprintf("%020d.wal", entries[0].Index))
+		if err := writeSegment(path, entries); err != nil {
+			return err
+		}
+		segments = append(segments, walSegment{path: path, last: entries[len(entries)-1].Index})
+	}
+
+	_ = w.f.Close()
+	if w.f.Name() != path {
+		_ = os.Remove(w.f.Name())
+	}
+	for _, s := range w.segments {
+		if s.path != path {
+			_ = os.Remove(s.path)
+		}
+	}
+	w.segments = segments
+	return w.rotate()
+}
+
+// writeSegment writes entries to a temporary file and renames it to path after fsync-ed, the file of path is replaced.
+func writeSegment(path string, entries []*walEntry) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	for _, e := range entries {
+		var b []byte
+		if b, err = json.Marshal(e); err != nil {
+			break
+		}
+		if _, err = bw.Write(append(b, '\n')); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	_ = f.Close()
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,407 @@
+package store
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/snowflake"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchSize      = 100
+	defaultFlushInterval  = time.Millisecond * 100
+	defaultQueueSize      = 10000
+	defaultEnqueueTimeout = time.Second
+	flushRetry            = 3
+)
+
+const (
+	entryKindChat    = 1
+	entryKindOffline = 2
+)
+
+var (
+	ErrStoreBusy   = errors.New("message store queue is full")
+	ErrStoreClosed = errors.New("message store is closed")
+)
+
+// ErrStoreUnflushed is returned by WriteBehindStore.Close if some messages are not written to store, they are kept in
+// the write ahead log if enabled, and replayed after restart.
+var ErrStoreUnflushed = errors.New("message store closed with messages not written")
+
+// BatchMessageStore is implemented by MessageStore that supports storing chat messages in batch.
+type BatchMessageStore interface {
+	StoreMessages(ms []*messages.ChatMessage) error
+}
+
+// Unwrapper is implemented by MessageStore that wraps another MessageStore.
+type Unwrapper interface {
+	Unwrap() MessageStore
+}
+
+// Unwrap returns the innermost MessageStore, used to check optional interface of the wrapped store.
+func Unwrap(s MessageStore) MessageStore {
+	for {
+		u, ok := s.(Unwrapper)
+		if !ok {
+			return s
+		}
+		s = u.Unwrap()
+	}
+}
+
+// As returns the outermost store in the wrapping chain of s that implements T. Unlike Unwrap, a wrapper implementing T
+// itself, such as CacheStore, is preferred to the store it wraps.
+func As[T any](s interface{}) (T, bool) {
+	for {
+		if t, ok := s.(T); ok {
+			return t, true
+		}
+		u, ok := s.(Unwrapper)
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		s = u.Unwrap()
+	}
+}
+
+type WriteBehindOptions struct {
+	// BatchSize the max count of messages written in a batch, default 100.
+	BatchSize int
+	// FlushInterval the max duration messages wait in queue, default 100ms.
+	FlushInterval time.Duration
+	// QueueSize the max count of messages waiting to be written, default 10000.
+	QueueSize int
+	// EnqueueTimeout the max duration to block when queue is full, ErrStoreBusy is returned after timeout, default 1s.
+	EnqueueTimeout time.Duration
+	// WALDir the directory of write ahead log, messages are fsync-ed to the log before return if set, and replayed
+	// after restart. empty to disable.
+	WALDir string
+	// WALSegmentSize the max size of a log file, default 64MB.
+	WALSegmentSize int64
+}
+
+var _ MessageStore = (*WriteBehindStore)(nil)
+var _ UserPurgeStore = (*WriteBehindStore)(nil)
+
+// WriteBehindStore is a MessageStore writes messages to the underlying store asynchronously in batch, the message id
+// is assigned by snowflake before queued. Messages failed to write after retries are retried with the next batch, the
+// write ahead log is not checkpointed past them.
+type WriteBehindStore struct {
+	store MessageStore
+	opts  WriteBehindOptions
+	wal   *wal
+	// failed entries failed to write in the order of index, accessed by the flush goroutine only.
+	failed []*walEntry
+
+	queue   chan *walEntry
+	slots   chan struct{}
+	orderMu sync.Mutex
+	// purges the requests to drop messages of users, handled by the flush goroutine.
+	purges chan *purgeRequest
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+func NewWriteBehindStore(store MessageStore, opts WriteBehindOptions) (*WriteBehindStore, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+	if opts.EnqueueTimeout <= 0 {
+		opts.EnqueueTimeout = defaultEnqueueTimeout
+	}
+	w := &WriteBehindStore{
+		store:  store,
+		opts:   opts,
+		queue:  make(chan *walEntry, opts.QueueSize),
+		slots:  make(chan struct{}, opts.QueueSize),
+		purges: make(chan *purgeRequest),
+		done:   make(chan struct{}),
+	}
+	if opts.WALDir != "" {
+		l, entries, err := openWAL(opts.WALDir, opts.WALSegmentSize)
+		if err != nil {
+			return nil, err
+		}
+		w.wal = l
+		if len(entries) > 0 {
+			log.I("replay %d messages from write ahead log", len(entries))
+			for i := 0; i < len(entries); i += opts.BatchSize {
+				end := i + opts.BatchSize
+				if end > len(entries) {
+					end = len(entries)
+				}
+				w.flush(entries[i:end])
+			}
+		}
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *WriteBehindStore) Unwrap() MessageStore {
+	return w.store
+}
+
+func (w *WriteBehindStore) StoreMessage(message *messages.ChatMessage) error {
+	if message.Mid == 0 {
+		message.Mid = snowflake.Generate()
+	}
+	return w.enqueue(entryKindChat, message)
+}
+
+func (w *WriteBehindStore) StoreOffline(message *messages.ChatMessage) error {
+	return w.enqueue(entryKindOffline, message)
+}
+
+// Close stops accepting messages and waits for queued messages written.
+func (w *WriteBehindStore) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	<-w.done
+	if len(w.failed) > 0 {
+		log.E("write behind store closed with %d messages not written", len(w.failed))
+		if w.wal != nil {
+			_ = w.wal.close()
+		}
+		return ErrStoreUnflushed
+	}
+	if w.wal != nil {
+		return w.wal.clear()
+	}
+	return nil
+}
+
+func (w *WriteBehindStore) isClosed() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.closed
+}
+
+func (w *WriteBehindStore) enqueue(kind int, message *messages.ChatMessage) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrStoreClosed
+	}
+
+	timer := time.NewTimer(w.opts.EnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case w.slots <- struct{}{}:
+	case <-timer.C:
+		return ErrStoreBusy
+	}
+
+	m := *message
+	e := &walEntry{Kind: kind, Message: &m}
+
+	// keep the order of wal index same as the queue, otherwise checkpoint may remove unwritten entries.
+	w.orderMu.Lock()
+	defer w.orderMu.Unlock()
+	if w.wal != nil {
+		err := w.wal.append(e)
+		if err != nil {
+			<-w.slots
+			return err
+		}
+	}
+	w.queue <- e
+	return nil
+}
+
+func (w *WriteBehindStore) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*walEntry, 0, w.opts.BatchSize)
+	for {
+		// stop taking messages from queue until failed messages are written, the queue is full and blocks producers.
+		if len(w.failed) >= w.opts.QueueSize {
+			if w.isClosed() {
+				for e := range w.queue {
+					<-w.slots
+					w.failed = append(w.failed, e)
+				}
+				return
+			}
+			select {
+			case r := <-w.purges:
+				r.done <- w.drop(r.uid, nil)
+			default:
+				w.flush(nil)
+			}
+			continue
+		}
+		select {
+		case e, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			<-w.slots
+			batch = append(batch, e)
+			if len(batch) < w.opts.BatchSize {
+				continue
+			}
+		case r := <-w.purges:
+			r.done <- w.drop(r.uid, batch)
+			batch = make([]*walEntry, 0, w.opts.BatchSize)
+			continue
+		case <-ticker.C:
+			if len(batch) == 0 && len(w.failed) == 0 {
+				continue
+			}
+		}
+		w.flush(batch)
+		batch = make([]*walEntry, 0, w.opts.BatchSize)
+	}
+}
+
+// flush writes the batch after entries failed before, the log is checkpointed to the entry before the first failed.
+func (w *WriteBehindStore) flush(batch []*walEntry) {
+	entries := append(w.failed, batch...)
+	if len(entries) == 0 {
+		return
+	}
+	w.failed = w.write(entries)
+	if w.wal == nil {
+		return
+	}
+	if len(w.failed) == 0 {
+		w.wal.checkpoint(entries[len(entries)-1].Index)
+	} else if w.failed[0].Index > 1 {
+		w.wal.checkpoint(w.failed[0].Index - 1)
+	}
+}
+
+type purgeRequest struct {
+	uid  string
+	done chan error
+}
+
+// PurgeUser drops messages of uid not written to store yet from the queue and the write ahead log, then purges uid
+// from the underlying store if it implements UserPurgeStore, messages of uid are never written after purged.
+func (w *WriteBehindStore) PurgeUser(uid string) error {
+	r := &purgeRequest{uid: uid, done: make(chan error, 1)}
+	select {
+	case w.purges <- r:
+		if err := <-r.done; err != nil {
+			return err
+		}
+	case <-w.done:
+		return ErrStoreClosed
+	}
+	if ps, ok := As[UserPurgeStore](w.store); ok {
+		return ps.PurgeUser(uid)
+	}
+	return nil
+}
+
+// drop writes the batch and messages in queue, then removes messages sent or received by uid from failed entries, the
+// write ahead log is rewritten with the rest failed entries. Producers are blocked until dropped.
+func (w *WriteBehindStore) drop(uid string, batch []*walEntry) error {
+	w.orderMu.Lock()
+	defer w.orderMu.Unlock()
+
+	for drained := false; !drained; {
+		select {
+		case e, ok := <-w.queue:
+			if !ok {
+				drained = true
+				break
+			}
+			<-w.slots
+			batch = append(batch, e)
+		default:
+			drained = true
+		}
+	}
+	var rest []*walEntry
+	for _, e := range append(w.failed, batch...) {
+		if e.Message.From != uid && e.Message.To != uid {
+			rest = append(rest, e)
+		}
+	}
+	w.failed = nil
+	w.flush(rest)
+	if w.wal == nil {
+		return nil
+	}
+	return w.wal.rewrite(w.failed)
+}
+
+// write writes entries to store, returns entries failed after retries in the order of index.
+func (w *WriteBehindStore) write(entries []*walEntry) []*walEntry {
+	var failed, chat []*walEntry
+	var ms []*messages.ChatMessage
+	for _, e := range entries {
+		if e.Kind == entryKindChat {
+			chat = append(chat, e)
+			ms = append(ms, e.Message)
+		}
+	}
+	if len(ms) > 0 && w.retry(func() error { return w.storeMessages(ms) }) != nil {
+		failed = append(failed, chat...)
+	}
+	for _, e := range entries {
+		if e.Kind == entryKindOffline {
+			m := e.Message
+			if w.retry(func() error { return w.store.StoreOffline(m) }) != nil {
+				failed = append(failed, e)
+			}
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Index < failed[j].Index })
+	return failed
+}
+
+func (w *WriteBehindStore) storeMessages(ms []*messages.ChatMessage) error {
+	if bs, ok := w.store.(BatchMessageStore); ok {
+		return bs.StoreMessages(ms)
+	}
+	for _, m := range ms {
+		err := w.store.StoreMessage(m)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retry retries fn up to flushRetry times, the store is waited without counting retries while its circuit breaker is
+// open, messages are kept in queue until the store recovered or closed. The last error is returned if all failed.
+func (w *WriteBehindStore) retry(fn func() error) error {
+	var err error
+	for i := 0; i < flushRetry; i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrCircuitOpen) && !w.isClosed() {
+			i--
+		}
+		time.Sleep(w.opts.FlushInterval)
+	}
+	log.E("write behind store failed after %d retries: %v", flushRetry, err)
+	return err
+}
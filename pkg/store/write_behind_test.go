@@ -0,0 +1,240 @@
+package store
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockBatchStore struct {
+	mu      sync.Mutex
+	batches [][]*messages.ChatMessage
+	offline []*messages.ChatMessage
+}
+
+func (m *mockBatchStore) StoreMessage(message *messages.ChatMessage) error {
+	return m.StoreMessages([]*messages.ChatMessage{message})
+}
+
+func (m *mockBatchStore) StoreOffline(message *messages.ChatMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.offline = append(m.offline, message)
+	return nil
+}
+
+func (m *mockBatchStore) StoreMessages(ms []*messages.ChatMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batches = append(m.batches, ms)
+	return nil
+}
+
+func (m *mockBatchStore) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, b := range m.batches {
+		n += len(b)
+	}
+	return n
+}
+
+func TestWriteBehindStore_StoreMessage(t *testing.T) {
+	s := &mockBatchStore{}
+	w, err := NewWriteBehindStore(s, WriteBehindOptions{BatchSize: 10, FlushInterval: time.Millisecond * 10})
+	assert.NoError(t, err)
+
+	for i := 0; i < 25; i++ {
+		m := &messages.ChatMessage{From: "1", To: "2"}
+		assert.NoError(t, w.StoreMessage(m))
+		assert.NotZero(t, m.Mid)
+	}
+	assert.NoError(t, w.StoreOffline(&messages.ChatMessage{Mid: 1, To: "2"}))
+	assert.NoError(t, w.Close())
+
+	assert.Equal(t, 25, s.count())
+	assert.Len(t, s.offline, 1)
+	assert.Equal(t, ErrStoreClosed, w.StoreMessage(&messages.ChatMessage{}))
+}
+
+func TestWriteBehindStore_Busy(t *testing.T) {
+	block := make(chan struct{})
+	s := &blockingStore{block: block}
+	w, err := NewWriteBehindStore(s, WriteBehindOptions{BatchSize: 1, QueueSize: 1, EnqueueTimeout: time.Millisecond * 10})
+	assert.NoError(t, err)
+
+	// the first is being written, the second is queued.
+	assert.NoError(t, w.StoreMessage(&messages.ChatMessage{}))
+	time.Sleep(time.Millisecond * 10)
+	assert.NoError(t, w.StoreMessage(&messages.ChatMessage{}))
+	assert.Equal(t, ErrStoreBusy, w.StoreMessage(&messages.ChatMessage{}))
+	close(block)
+	assert.NoError(t, w.Close())
+}
+
+func TestWriteBehindStore_WAL(t *testing.T) {
+	dir := t.TempDir()
+
+	// simulate crash before written to store.
+	l, _, err := openWAL(dir, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, l.append(&walEntry{Kind: entryKindChat, Message: &messages.ChatMessage{Mid: 1}}))
+	assert.NoError(t, l.append(&walEntry{Kind: entryKindChat, Message: &messages.ChatMessage{Mid: 2}}))
+	assert.NoError(t, l.close())
+
+	s := &mockBatchStore{}
+	w, err := NewWriteBehindStore(s, WriteBehindOptions{WALDir: dir})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, s.count())
+
+	assert.NoError(t, w.StoreMessage(&messages.ChatMessage{Mid: 3}))
+	assert.NoError(t, w.Close())
+	assert.Equal(t, 3, s.count())
+
+	// all entries are written, nothing to replay.
+	s2 := &mockBatchStore{}
+	w2, err := NewWriteBehindStore(s2, WriteBehindOptions{WALDir: dir})
+	assert.NoError(t, err)
+	assert.NoError(t, w2.Close())
+	assert.Equal(t, 0, s2.count())
+}
+
+type blockingStore struct {
+	block chan struct{}
+}
+
+func (b *blockingStore) StoreMessage(message *messages.ChatMessage) error {
+	<-b.block
+	return nil
+}
+
+func (b *blockingStore) StoreOffline(message *messages.ChatMessage) error {
+	return nil
+}
+
+// failingStore fails to store messages until recovered.
+type failingStore struct {
+	mockBatchStore
+	failing bool
+}
+
+func (f *failingStore) setFailing(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = failing
+}
+
+func (f *failingStore) StoreMessages(ms []*messages.ChatMessage) error {
+	f.mu.Lock()
+	failing := f.failing
+	f.mu.Unlock()
+	if failing {
+		return errors.New("database is down")
+	}
+	return f.mockBatchStore.StoreMessages(ms)
+}
+
+func (f *failingStore) StoreMessage(message *messages.ChatMessage) error {
+	return f.StoreMessages([]*messages.ChatMessage{message})
+}
+
+func TestWriteBehindStore_Failed(t *testing.T) {
+	dir := t.TempDir()
+	s := &failingStore{failing: true}
+	w, err := NewWriteBehindStore(s, WriteBehindOptions{BatchSize: 1, FlushInterval: time.Millisecond, WALDir: dir})
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.StoreMessage(&messages.ChatMessage{Mid: 1}))
+	assert.NoError(t, w.StoreMessage(&messages.ChatMessage{Mid: 2}))
+	// the failed messages are kept in the log after closed.
+	assert.Equal(t, ErrStoreUnflushed, w.Close())
+	assert.Equal(t, 0, s.count())
+
+	l, entries, err := openWAL(dir, 0)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.NoError(t, l.close())
+
+	// the failed messages are retried with the next batch after the store recovered.
+	w, err = NewWriteBehindStore(s, WriteBehindOptions{BatchSize: 1, FlushInterval: time.Millisecond, WALDir: dir})
+	assert.NoError(t, err)
+	assert.NoError(t, w.StoreMessage(&messages.ChatMessage{Mid: 3}))
+	s.setFailing(false)
+	assert.Eventually(t, func() bool { return s.count() == 3 }, time.Second, time.Millisecond*10)
+	assert.NoError(t, w.Close())
+
+	s2 := &mockBatchStore{}
+	w2, err := NewWriteBehindStore(s2, WriteBehindOptions{WALDir: dir})
+	assert.NoError(t, err)
+	assert.NoError(t, w2.Close())
+	assert.Equal(t, 0, s2.count())
+}
+
+func TestWriteBehindStore_CheckpointFailed(t *testing.T) {
+	dir := t.TempDir()
+	// every entry is in a segment.
+	l, _, err := openWAL(dir, 1)
+	assert.NoError(t, err)
+	s := &failingStore{}
+	w := &WriteBehindStore{store: s, opts: WriteBehindOptions{FlushInterval: time.Millisecond}, wal: l}
+	entry := func(mid int64) []*walEntry {
+		e := &walEntry{Kind: entryKindChat, Message: &messages.ChatMessage{Mid: mid}}
+		assert.NoError(t, l.append(e))
+		return []*walEntry{e}
+	}
+	walMids := func() []int64 {
+		paths, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+		sort.Strings(paths)
+		var mids []int64
+		for _, p := range paths {
+			entries, err := readSegment(p)
+			assert.NoError(t, err)
+			for _, e := range entries {
+				mids = append(mids, e.Message.Mid)
+			}
+		}
+		return mids
+	}
+
+	w.flush(entry(1))
+	s.setFailing(true)
+	w.flush(entry(2))
+	w.flush(entry(3))
+	// the log is not checkpointed past the first failed message.
+	assert.Len(t, w.failed, 2)
+	assert.Equal(t, []int64{2, 3}, walMids())
+
+	s.setFailing(false)
+	w.flush(entry(4))
+	assert.Empty(t, w.failed)
+	assert.Equal(t, 4, s.count())
+	assert.Empty(t, walMids())
+	assert.NoError(t, l.close())
+}
+
+func TestWriteBehindStore_PurgeUser(t *testing.T) {
+	dir := t.TempDir()
+	s := &failingStore{failing: true}
+	w, err := NewWriteBehindStore(s, WriteBehindOptions{BatchSize: 1, FlushInterval: time.Millisecond, WALDir: dir})
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.StoreMessage(&messages.ChatMessage{Mid: 1, From: "1", To: "2"}))
+	assert.NoError(t, w.StoreMessage(&messages.ChatMessage{Mid: 2, From: "3", To: "4"}))
+	assert.NoError(t, w.StoreOffline(&messages.ChatMessage{Mid: 3, From: "3", To: "1"}))
+	assert.NoError(t, w.PurgeUser("1"))
+	assert.Equal(t, ErrStoreUnflushed, w.Close())
+	assert.Equal(t, ErrStoreClosed, w.PurgeUser("1"))
+
+	// messages of the user purged are dropped from the log.
+	l, entries, err := openWAL(dir, 0)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, int64(2), entries[0].Message.Mid)
+	assert.NoError(t, l.close())
+}
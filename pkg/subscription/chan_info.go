@@ -1,5 +1,7 @@
 package subscription
 
+import "time"
+
 type ChanType int32
 
 //goland:noinspection GoUnusedConst
@@ -17,6 +19,16 @@ type ChanInfo struct {
 
 	Secret string
 
+	// TTL the channel is removed when the duration elapsed since created, zero for permanent channels.
+	TTL time.Duration
+	// IdleTimeout the channel is removed when no message has been published for the duration, zero to disable.
+	IdleTimeout time.Duration
+
+	// Live the channel is a live room for massive transient audiences, messages are not stored, subscribers are
+	// notified the aggregated member count periodically instead of each join and leave, and messages are dropped when
+	// the channel is overloaded.
+	Live bool
+
 	Parent *ChanID
 	Child  []ChanID
 }
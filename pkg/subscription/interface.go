@@ -53,6 +53,39 @@ type Subscribe interface {
 	UpdateChannel(id ChanID, update ChannelUpdate) error
 }
 
+// Inspector provides runtime statistics of channels, implemented optionally by Subscribe implementations.
+type Inspector interface {
+
+	// ChannelMemberCounts returns the subscriber count of each channel.
+	ChannelMemberCounts() map[ChanID]int
+}
+
+// SubscriberRemover removes a subscriber from all channels, implemented optionally by Subscribe implementations.
+type SubscriberRemover interface {
+
+	// RemoveSubscriber unsubscribes the subscriber from all channels it subscribed, returns the channels.
+	RemoveSubscriber(id SubscriberID) ([]ChanID, error)
+}
+
+// SubscriberRenamer moves the subscriptions of a subscriber to another, implemented optionally by Subscribe
+// implementations.
+type SubscriberRenamer interface {
+
+	// RenameSubscriber moves the subscriber from all channels it subscribed to the subscriber to with the same
+	// permission, returns the channels.
+	RenameSubscriber(from SubscriberID, to SubscriberID) ([]ChanID, error)
+}
+
+// MemberInspector queries subscribers of channels, implemented optionally by Subscribe implementations.
+type MemberInspector interface {
+
+	// Subscribers returns subscribers of the channel.
+	Subscribers(ch ChanID) ([]SubscriberID, error)
+
+	// IsAdmin returns true if the subscriber subscribed the channel with the admin permission.
+	IsAdmin(ch ChanID, id SubscriberID) (bool, error)
+}
+
 type Server interface {
 	Subscribe
 
@@ -17,8 +17,19 @@ const (
 	NotifyTypeLeave   = 4
 
 	NotifyOnlineMembers = 5
+	// NotifyTypeExpired the temporary channel is expired and removed, see ChanInfo.TTL.
+	NotifyTypeExpired = 6
+	// NotifyTypeLiveStats the periodic member count of the live room, the body is LiveStats, see ChanInfo.Live.
+	NotifyTypeLiveStats = 7
 )
 
+// LiveStats the aggregated member events of the live room since the last report.
+type LiveStats struct {
+	Members int   `json:"members"`
+	Joined  int64 `json:"joined"`
+	Left    int64 `json:"left"`
+}
+
 type NotifyMessage struct {
 	From string      `json:"from"`
 	Type int         `json:"type"`
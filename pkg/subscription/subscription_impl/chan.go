@@ -7,6 +7,7 @@ import (
 	"github.com/glide-im/glide/pkg/gate"
 	"github.com/glide-im/glide/pkg/logger"
 	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/metrics"
 	"github.com/glide-im/glide/pkg/store"
 	"github.com/glide-im/glide/pkg/subscription"
 	"github.com/glide-im/glide/pkg/timingwheel"
@@ -17,6 +18,8 @@ import (
 	"time"
 )
 
+var log = logger.Named("subscription")
+
 const (
 	errNotMemberOfChannel    = "not member of channel"
 	errPermissionDeniedWrite = "permission denied: write"
@@ -40,6 +43,8 @@ type ChannelSequenceStore interface {
 type SubscriberOptions struct {
 	Perm   Permission
 	Ticket string
+	// Approved subscribes without the ticket, set when the join request is approved by an admin of the channel.
+	Approved bool
 }
 
 // getSubscriberOptions assertion type of `i` is *SubscribeOptions
@@ -71,11 +76,6 @@ func (i *SubscriberInfo) isAdmin() bool {
 	return i.Perm.allows(MaskPermAdmin)
 }
 
-func (i *SubscriberInfo) update(options *SubscriberOptions) error {
-	i.Perm = options.Perm
-	return nil
-}
-
 func NewSubscriberInfo(so *SubscriberOptions) *SubscriberInfo {
 	return &SubscriberInfo{
 		Perm: so.Perm,
@@ -95,10 +95,24 @@ type Channel struct {
 
 	sleepTimer *timingwheel.Task
 
+	// createdAt and publishAt the unix nanoseconds the channel created and the last message published, used to
+	// expire temporary channels.
+	createdAt int64
+	publishAt int64
+	expiry    *timingwheel.Task
+
+	// liveJoined and liveLeft count members joined and left the live room since the last report.
+	liveJoined  int64
+	liveLeft    int64
+	liveMembers int
+	liveTimer   *timingwheel.Task
+
 	activeAt    time.Time
 	mu          *sync.RWMutex
 	subscribers map[subscription.SubscriberID]*SubscriberInfo
-	info        *subscription.ChanInfo
+	// members the *memberSnapshot of subscribers, nil after subscribers changed until it's rebuilt by snapshot.
+	members atomic.Value
+	info    *subscription.ChanInfo
 
 	store    store.SubscriptionStore
 	seqStore ChannelSequenceStore
@@ -115,6 +129,7 @@ func NewChannel(chanID subscription.ChanID, gate gate.DefaultGateway,
 		mu:          &sync.RWMutex{},
 		subscribers: map[subscription.SubscriberID]*SubscriberInfo{},
 		info:        &subscription.ChanInfo{},
+		createdAt:   time.Now().UnixNano(),
 		store:       store,
 		seqStore:    seqStore,
 		gate:        gate,
@@ -130,17 +145,65 @@ func (g *Channel) Update(ci *subscription.ChanInfo) error {
 	g.info.Blocked = ci.Blocked
 	g.info.Muted = ci.Muted
 	g.info.Secret = ci.Secret
+	g.info.TTL = ci.TTL
+	g.info.IdleTimeout = ci.IdleTimeout
+	g.info.Live = ci.Live
+	g.updateLive()
 	return nil
 }
 
 func (g *Channel) GetSubscribers() []string {
+	return append([]string(nil), g.snapshot().ids...)
+}
+
+// memberSnapshot is the immutable copy of subscribers, the fan-out and listing read it without locking the channel,
+// so they don't block subscribers joining and leaving.
+type memberSnapshot struct {
+	ids     []string
+	members map[subscription.SubscriberID]*SubscriberInfo
+}
+
+// snapshot returns the snapshot of subscribers, it's rebuilt once after subscribers changed, so joins and leaves in
+// a burst copy subscribers once.
+func (g *Channel) snapshot() *memberSnapshot {
+	if s, _ := g.members.Load().(*memberSnapshot); s != nil {
+		return s
+	}
 	g.mu.RLock()
 	defer g.mu.RUnlock()
-	var result []string
-	for id := range g.subscribers {
-		result = append(result, string(id))
+	s := &memberSnapshot{
+		ids:     make([]string, 0, len(g.subscribers)),
+		members: make(map[subscription.SubscriberID]*SubscriberInfo, len(g.subscribers)),
+	}
+	for id, sb := range g.subscribers {
+		s.ids = append(s.ids, string(id))
+		s.members[id] = sb
 	}
-	return result
+	// stored with the read lock held, subscribers can't change before it's stored.
+	g.members.Store(s)
+	return s
+}
+
+// invalidateMembers drops the snapshot of subscribers, the caller must hold the write lock.
+func (g *Channel) invalidateMembers() {
+	g.members.Store((*memberSnapshot)(nil))
+}
+
+func (g *Channel) memberCount() int {
+	return len(g.snapshot().ids)
+}
+
+func (g *Channel) isSubscribed(id subscription.SubscriberID) bool {
+	_, ok := g.snapshot().members[id]
+	return ok
+}
+
+// IsAdmin returns true if the subscriber has the admin permission.
+func (g *Channel) IsAdmin(id subscription.SubscriberID) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	sb, ok := g.subscribers[id]
+	return ok && sb.Perm.allows(MaskPermAdmin)
 }
 
 func (g *Channel) Subscribe(id subscription.SubscriberID, extra interface{}) error {
@@ -156,15 +219,20 @@ func (g *Channel) Subscribe(id subscription.SubscriberID, extra interface{}) err
 		return errors.New("channel is blocked")
 	}
 
-	logger.I("subscriber %s subscribe channel %s", id, g.id)
+	log.I("subscriber %s subscribe channel %s", id, g.id)
 
 	g.mu.RLock()
-	sb, ok := g.subscribers[id]
+	_, ok := g.subscribers[id]
 	g.mu.RUnlock()
 	if ok {
-		return sb.update(so)
+		// replaced instead of updated in place, the snapshot being read by the fan-out is not changed.
+		g.mu.Lock()
+		g.subscribers[id] = NewSubscriberInfo(so)
+		g.invalidateMembers()
+		g.mu.Unlock()
+		return nil
 	} else {
-		if len(g.info.Secret) != 0 {
+		if len(g.info.Secret) != 0 && !so.Approved {
 			if len(so.Ticket) == 0 {
 				return errors.New("invalid ticket")
 			}
@@ -176,8 +244,14 @@ func (g *Channel) Subscribe(id subscription.SubscriberID, extra interface{}) err
 		}
 		g.mu.Lock()
 		g.subscribers[id] = NewSubscriberInfo(so)
+		g.invalidateMembers()
 		g.mu.Unlock()
-		logger.I("subscriber %s subscribe channel %s", id, g.id)
+		log.I("subscriber %s subscribe channel %s", id, g.id)
+	}
+
+	if g.info.Live {
+		atomic.AddInt64(&g.liveJoined, 1)
+		return nil
 	}
 
 	onlineNotify := PublishMessage{
@@ -214,12 +288,18 @@ func (g *Channel) Unsubscribe(id subscription.SubscriberID) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	logger.I("subscriber %s unsubscribe channel %s", id, g.id)
+	log.I("subscriber %s unsubscribe channel %s", id, g.id)
 	_, ok := g.subscribers[id]
 	if !ok {
 		return errors.New(subscription.ErrNotSubscribed)
 	}
 	delete(g.subscribers, id)
+	g.invalidateMembers()
+
+	if g.info.Live {
+		atomic.AddInt64(&g.liveLeft, 1)
+		return nil
+	}
 
 	onlineNotify := PublishMessage{
 		Message: messages.NewMessage(0, messages.ActionGroupNotify, subscription.NotifyMessage{
@@ -236,6 +316,44 @@ func (g *Channel) Unsubscribe(id subscription.SubscriberID) error {
 	return nil
 }
 
+// Rename moves the subscriber from to the subscriber to with the same permission, the permission of to is kept if
+// it's subscribed already, members are notified as from goes offline and to goes online.
+func (g *Channel) Rename(from subscription.SubscriberID, to subscription.SubscriberID) error {
+	g.mu.Lock()
+	sb, ok := g.subscribers[from]
+	if !ok {
+		g.mu.Unlock()
+		return errors.New(subscription.ErrNotSubscribed)
+	}
+	delete(g.subscribers, from)
+	if _, ok = g.subscribers[to]; !ok {
+		g.subscribers[to] = sb
+	}
+	g.invalidateMembers()
+	g.mu.Unlock()
+
+	log.I("subscriber %s renamed to %s in channel %s", from, to, g.id)
+
+	for _, n := range []struct {
+		t   int
+		uid subscription.SubscriberID
+	}{{subscription.NotifyTypeOffline, from}, {subscription.NotifyTypeOnline, to}} {
+		notify := PublishMessage{
+			Message: messages.NewMessage(0, messages.ActionGroupNotify, subscription.NotifyMessage{
+				From: "system",
+				Type: n.t,
+				Body: struct {
+					Uid string `json:"uid"`
+				}{
+					string(n.uid),
+				},
+			}),
+		}
+		_ = g.enqueueNotify(&notify)
+	}
+	return nil
+}
+
 func (g *Channel) Publish(msg subscription.Message) error {
 	if g.info.Closed {
 		return errors.New("channel closed")
@@ -274,7 +392,11 @@ func (g *Channel) Publish(msg subscription.Message) error {
 	case TypeNotify:
 		return g.enqueueNotify(message)
 	case TypeMessage:
-		return g.enqueue(message)
+		err := g.enqueue(message)
+		if err == nil {
+			atomic.StoreInt64(&g.publishAt, time.Now().UnixNano())
+		}
+		return err
 	default:
 		return errors.New(errUnknownMessageType)
 	}
@@ -285,12 +407,17 @@ func (g *Channel) Close() error {
 	defer g.mu.Unlock()
 
 	g.info.Closed = true
+	if g.liveTimer != nil {
+		g.liveTimer.Cancel()
+		g.liveTimer = nil
+	}
 
 	close(g.messages)
 	g.subscribers = map[subscription.SubscriberID]*SubscriberInfo{}
+	g.invalidateMembers()
 
 	if g.queued > 0 {
-		logger.D("chan %s closed, %d messages dropped", g.id, g.queued)
+		log.D("chan %s closed, %d messages dropped", g.id, g.queued)
 	}
 	return nil
 }
@@ -307,21 +434,25 @@ func (g *Channel) enqueueNotify(msg *PublishMessage) error {
 
 func (g *Channel) enqueue(m *PublishMessage) error {
 
-	cm, err := m.GetChatMessage()
-	if err != nil {
-		return errors2.Wrap(err, "enqueue message deserialize body error")
-	}
-	m.Seq, err = g.nextSeq()
-	if err != nil {
-		return err
-	}
-	cm.Seq = m.Seq
-	m.Message.Data = messages.NewData(&cm)
-
-	if m.Type == TypeMessage {
-		err = g.store.StoreChannelMessage(g.id, cm)
+	// the at-most-once message is neither sequenced nor stored, and dropped under load like messages of live rooms.
+	atMostOnce := m.Message.GetQoS() == messages.QoSAtMostOnce
+	if !atMostOnce {
+		cm, err := m.GetChatMessage()
 		if err != nil {
-			return errors2.Wrap(err, "store channel message error")
+			return errors2.Wrap(err, "enqueue message deserialize body error")
+		}
+		m.Seq, err = g.nextSeq()
+		if err != nil {
+			return err
+		}
+		cm.Seq = m.Seq
+		m.Message.Data = messages.NewData(&cm)
+
+		if m.Type == TypeMessage && !g.info.Live {
+			err = g.store.StoreChannelMessage(g.id, cm)
+			if err != nil {
+				return errors2.Wrap(err, "store channel message error")
+			}
 		}
 	}
 
@@ -329,12 +460,15 @@ func (g *Channel) enqueue(m *PublishMessage) error {
 	case g.messages <- m:
 		atomic.AddInt32(&g.queued, 1)
 	default:
+		if g.info.Live || atMostOnce {
+			// the live room and the at-most-once message tolerate dropping messages under load.
+			metrics.ChannelDrops.Inc()
+			log.D("chan %s message queue is full, message %d dropped", g.id, m.Seq)
+			return nil
+		}
 		return errors.New("too many messages,the group message queue is full")
 	}
-	if err = g.checkMsgQueue(); err != nil {
-		return err
-	}
-	return nil
+	return g.checkMsgQueue()
 }
 
 func (g *Channel) checkMsgQueue() error {
@@ -349,7 +483,7 @@ func (g *Channel) checkMsgQueue() error {
 			err := recover()
 			if err != nil {
 				atomic.StoreInt32(&g.queueRunning, 0)
-				logger.E("message queue panic: %v", err)
+				log.E("message queue panic: %v", err)
 			}
 		}()
 
@@ -385,9 +519,9 @@ func (g *Channel) checkMsgQueue() error {
 			}
 		}
 		if dropped > 0 {
-			logger.W("chan %s message queue stopped, %d message(s) have been dropped", g.id, dropped)
+			log.W("chan %s message queue stopped, %d message(s) have been dropped", g.id, dropped)
 		} else {
-			logger.D("chan %s message queue stopped", g.id)
+			log.D("chan %s message queue stopped", g.id)
 		}
 		atomic.StoreInt32(&g.queued, 0)
 		atomic.StoreInt32(&g.queueRunning, 0)
@@ -396,10 +530,12 @@ func (g *Channel) checkMsgQueue() error {
 }
 
 func (g *Channel) push(message *PublishMessage) {
-	logger.I("chan %s push message: %v", g.id, message.Message)
+	log.I("chan %s push message: %v", g.id, message.Message)
 
-	g.mu.RLock()
-	defer g.mu.RUnlock()
+	start := time.Now()
+	defer func() {
+		metrics.FanoutLatency.Observe(time.Since(start).Seconds())
+	}()
 
 	// TODO recycler use
 	var received = map[subscription.SubscriberID]interface{}{}
@@ -410,7 +546,9 @@ func (g *Channel) push(message *PublishMessage) {
 		}
 	}
 
-	for subscriberID, sInfo := range g.subscribers {
+	// the message is encoded once and shared by all subscribers.
+	m := messages.Serialize(message.Message)
+	for subscriberID, sInfo := range g.snapshot().members {
 		if received != nil && len(received) > 0 {
 			_, contained := received[subscriberID]
 			if !contained {
@@ -420,9 +558,11 @@ func (g *Channel) push(message *PublishMessage) {
 		if !sInfo.canRead() {
 			continue
 		}
-		err := g.gate.EnqueueMessage(gate.NewID2(string(subscriberID)), message.Message)
-		if err != nil {
-			logger.E("chan %s push message to subscribe %s error: %v", g.id, subscriberID, err)
+		err := g.gate.EnqueueMessage(gate.NewID2(string(subscriberID)), m)
+		if err != nil && g.info.Live {
+			metrics.ChannelDrops.Inc()
+		} else if err != nil {
+			log.E("chan %s push message to subscribe %s error: %v", g.id, subscriberID, err)
 		}
 	}
 }
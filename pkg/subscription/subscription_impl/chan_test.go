@@ -7,6 +7,8 @@ import (
 	"github.com/glide-im/glide/pkg/messages"
 	"github.com/glide-im/glide/pkg/subscription"
 	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -217,3 +219,65 @@ func TestWrap_Subscribe(t *testing.T) {
 	err = channel.Subscribe("sb_test", normalOpts)
 	assert.NoError(t, err)
 }
+
+type recordGate struct {
+	mockGate
+	mu       sync.Mutex
+	received []*messages.GlideMessage
+}
+
+func (r *recordGate) EnqueueMessage(_ gate.ID, m *messages.GlideMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received = append(r.received, m)
+	return nil
+}
+
+func (r *recordGate) messages() []*messages.GlideMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*messages.GlideMessage{}, r.received...)
+}
+
+type countingStore struct {
+	mockStore
+	stored int32
+}
+
+func (c *countingStore) StoreChannelMessage(subscription.ChanID, *messages.ChatMessage) error {
+	atomic.AddInt32(&c.stored, 1)
+	return nil
+}
+
+func TestChannel_Live(t *testing.T) {
+	g := &recordGate{}
+	st := &countingStore{}
+	channel, err := NewChannel("live", g, st, &mockSeqStore{segmentLen: 100})
+	assert.NoError(t, err)
+	assert.NoError(t, channel.Update(&subscription.ChanInfo{Live: true}))
+	defer channel.Close()
+	assert.NotNil(t, channel.liveTimer)
+
+	assert.NoError(t, channel.Subscribe("1", normalOpts))
+	assert.NoError(t, channel.Subscribe("2", normalOpts))
+	assert.NoError(t, channel.Unsubscribe("2"))
+
+	cm := &messages.ChatMessage{From: "1", Content: "hi"}
+	err = channel.Publish(&PublishMessage{From: "1", Type: TypeMessage, Message: messages.NewMessage(0, messages.ActionGroupMessage, cm)})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(g.messages()) == 1 }, time.Second, time.Millisecond*10)
+	// no per-member notifications and the message is not stored
+	assert.Equal(t, messages.ActionGroupMessage, g.messages()[0].GetAction())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&st.stored))
+
+	channel.reportLive()
+	assert.Eventually(t, func() bool { return len(g.messages()) == 2 }, time.Second, time.Millisecond*10)
+	n := g.messages()[1].Data.GetData().(subscription.NotifyMessage)
+	assert.Equal(t, subscription.NotifyTypeLiveStats, n.Type)
+	assert.Equal(t, &subscription.LiveStats{Members: 1, Joined: 2, Left: 1}, n.Body)
+
+	// nothing changed since the last report
+	channel.reportLive()
+	time.Sleep(time.Millisecond * 50)
+	assert.Len(t, g.messages(), 2)
+}
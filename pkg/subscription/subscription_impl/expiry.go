@@ -0,0 +1,95 @@
+package subscription_impl
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/store"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/glide-im/glide/pkg/timingwheel"
+)
+
+// expireAt returns the time the temporary channel expires, the earlier of TTL since created and IdleTimeout since
+// the last message published, false if the channel is permanent.
+func (g *Channel) expireAt() (time.Time, bool) {
+	var at time.Time
+	if g.info.TTL > 0 {
+		at = time.Unix(0, g.createdAt).Add(g.info.TTL)
+	}
+	if g.info.IdleTimeout > 0 {
+		active := atomic.LoadInt64(&g.publishAt)
+		if active == 0 {
+			active = g.createdAt
+		}
+		idle := time.Unix(0, active).Add(g.info.IdleTimeout)
+		if at.IsZero() || idle.Before(at) {
+			at = idle
+		}
+	}
+	return at, !at.IsZero()
+}
+
+// setExpiry replaces the expiry timer of the channel, nil to cancel.
+func (g *Channel) setExpiry(t *timingwheel.Task) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.expiry != nil {
+		g.expiry.Cancel()
+	}
+	g.expiry = t
+}
+
+// expire notifies subscribers the channel is expired and closes the channel.
+func (g *Channel) expire() {
+	g.push(&PublishMessage{
+		Message: messages.NewMessage(0, messages.ActionGroupNotify, subscription.NotifyMessage{
+			From: "system",
+			Type: subscription.NotifyTypeExpired,
+			Body: struct {
+				Channel string `json:"channel"`
+			}{
+				string(g.id),
+			},
+		}),
+	})
+	_ = g.Close()
+}
+
+// scheduleExpiry sets the timer to check the expiry of the temporary channel, the timer of permanent channel is
+// canceled.
+func (u *realSubscription) scheduleExpiry(chID subscription.ChanID, ch *Channel) {
+	at, ok := ch.expireAt()
+	if !ok {
+		ch.setExpiry(nil)
+		return
+	}
+	ch.setExpiry(tw.AfterFunc(time.Until(at), func() {
+		u.checkExpiry(chID, ch)
+	}))
+}
+
+// checkExpiry removes the channel if it's expired, otherwise reschedules the timer, the channel may be active since
+// the timer set.
+func (u *realSubscription) checkExpiry(chID subscription.ChanID, ch *Channel) {
+	at, ok := ch.expireAt()
+	if !ok {
+		return
+	}
+	if time.Until(at) > 0 {
+		u.scheduleExpiry(chID, ch)
+		return
+	}
+
+	if !u.channels.removeIf(chID, ch) {
+		return
+	}
+
+	log.I("temporary channel %s expired", chID)
+	ch.expire()
+	if ps, ok := store.As[store.ChannelPurgeStore](u.store); ok {
+		if err := ps.PurgeChannel(chID); err != nil {
+			log.E("purge expired channel %s error: %v", chID, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package subscription_impl
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/subscription"
+)
+
+// liveStatsInterval the interval the live room reports the member count, see subscription.LiveStats.
+const liveStatsInterval = time.Second * 5
+
+// updateLive starts reporting the member count periodically if the channel is a live room, stops otherwise.
+func (g *Channel) updateLive() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.info.Live && !g.info.Closed && g.liveTimer == nil {
+		g.liveTimer = tw.AfterFunc(liveStatsInterval, g.reportLive)
+	} else if !g.info.Live && g.liveTimer != nil {
+		g.liveTimer.Cancel()
+		g.liveTimer = nil
+	}
+}
+
+// reportLive notifies subscribers the member count and members joined and left since the last report, nothing is
+// sent if nothing changed.
+func (g *Channel) reportLive() {
+	g.mu.Lock()
+	if !g.info.Live || g.info.Closed {
+		g.mu.Unlock()
+		return
+	}
+	g.liveTimer = tw.AfterFunc(liveStatsInterval, g.reportLive)
+	stats := subscription.LiveStats{
+		Members: len(g.subscribers),
+		Joined:  atomic.SwapInt64(&g.liveJoined, 0),
+		Left:    atomic.SwapInt64(&g.liveLeft, 0),
+	}
+	changed := stats.Joined > 0 || stats.Left > 0 || stats.Members != g.liveMembers
+	g.liveMembers = stats.Members
+	g.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	notify := PublishMessage{
+		Message: messages.NewMessage(0, messages.ActionGroupNotify, subscription.NotifyMessage{
+			From: "system",
+			Type: subscription.NotifyTypeLiveStats,
+			Body: &stats,
+		}),
+	}
+	if err := g.enqueueNotify(&notify); err != nil {
+		log.D("live room %s report member count error: %v", g.id, err)
+	}
+}
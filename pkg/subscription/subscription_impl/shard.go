@@ -0,0 +1,113 @@
+package subscription_impl
+
+import (
+	"errors"
+	"github.com/glide-im/glide/pkg/subscription"
+	"hash/fnv"
+	"sync"
+)
+
+// channelShardCount the count of shards of channelMap, a power of 2.
+const channelShardCount = 64
+
+// channelMap is the map of channels sharded by channel id, creating and removing a channel locks only the shard of
+// it, so operations on channels of other shards do not wait.
+type channelMap struct {
+	shards [channelShardCount]channelShard
+}
+
+type channelShard struct {
+	mu       sync.RWMutex
+	channels map[subscription.ChanID]subscription.Channel
+}
+
+func newChannelMap() *channelMap {
+	m := &channelMap{}
+	for i := range m.shards {
+		m.shards[i].channels = map[subscription.ChanID]subscription.Channel{}
+	}
+	return m
+}
+
+func (m *channelMap) shard(id subscription.ChanID) *channelShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return &m.shards[h.Sum32()&(channelShardCount-1)]
+}
+
+// get returns the channel of id, the shard is not locked when the caller operates on the channel.
+func (m *channelMap) get(id subscription.ChanID) (subscription.Channel, bool) {
+	s := m.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ch, ok := s.channels[id]
+	return ch, ok
+}
+
+// create adds the channel created by fn if the channel of id does not exist.
+func (m *channelMap) create(id subscription.ChanID, fn func() (subscription.Channel, error)) (subscription.Channel, error) {
+	s := m.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.channels[id]; ok {
+		return nil, errors.New(subscription.ErrChanAlreadyExists)
+	}
+	ch, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	s.channels[id] = ch
+	return ch, nil
+}
+
+// remove removes and returns the channel of id.
+func (m *channelMap) remove(id subscription.ChanID) (subscription.Channel, bool) {
+	s := m.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.channels[id]
+	if ok {
+		delete(s.channels, id)
+	}
+	return ch, ok
+}
+
+// removeIf removes the channel of id if it's ch, returns false if the channel is removed or replaced already.
+func (m *channelMap) removeIf(id subscription.ChanID, ch subscription.Channel) bool {
+	s := m.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.channels[id] != ch {
+		return false
+	}
+	delete(s.channels, id)
+	return true
+}
+
+// each calls fn for all channels, shards are copied one by one, fn is called without locks.
+func (m *channelMap) each(fn func(id subscription.ChanID, ch subscription.Channel)) {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		chs := make(map[subscription.ChanID]subscription.Channel, len(s.channels))
+		for id, ch := range s.channels {
+			chs[id] = ch
+		}
+		s.mu.RUnlock()
+		for id, ch := range chs {
+			fn(id, ch)
+		}
+	}
+}
+
+// len returns the count of channels.
+func (m *channelMap) len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.channels)
+		s.mu.RUnlock()
+	}
+	return n
+}
@@ -0,0 +1,156 @@
+package subscription_impl
+
+import (
+	"fmt"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/glide-im/glide/pkg/subscription"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestChannelMap(t *testing.T) {
+	m := newChannelMap()
+	a := &Channel{id: "a"}
+	_, err := m.create("a", func() (subscription.Channel, error) { return a, nil })
+	assert.NoError(t, err)
+	_, err = m.create("a", func() (subscription.Channel, error) { return &Channel{}, nil })
+	assert.EqualError(t, err, subscription.ErrChanAlreadyExists)
+	_, err = m.create("b", func() (subscription.Channel, error) { return &Channel{id: "b"}, nil })
+	assert.NoError(t, err)
+
+	ch, ok := m.get("a")
+	assert.True(t, ok)
+	assert.Same(t, a, ch)
+	assert.Equal(t, 2, m.len())
+
+	var ids []subscription.ChanID
+	m.each(func(id subscription.ChanID, _ subscription.Channel) {
+		ids = append(ids, id)
+	})
+	assert.ElementsMatch(t, []subscription.ChanID{"a", "b"}, ids)
+
+	assert.False(t, m.removeIf("a", &Channel{}))
+	assert.True(t, m.removeIf("a", a))
+	_, ok = m.remove("b")
+	assert.True(t, ok)
+	assert.Equal(t, 0, m.len())
+}
+
+func TestChannel_MemberSnapshot(t *testing.T) {
+	channel := mockNewChannel("test")
+	assert.NoError(t, channel.Update(&subscription.ChanInfo{Live: true}))
+	assert.NoError(t, channel.Subscribe("1", &SubscriberOptions{Perm: PermRead}))
+
+	before := channel.snapshot()
+	assert.Same(t, before, channel.snapshot())
+	assert.True(t, channel.isSubscribed("1"))
+
+	assert.NoError(t, channel.Subscribe("2", &SubscriberOptions{Perm: PermRead}))
+	assert.NotSame(t, before, channel.snapshot())
+	assert.Equal(t, 2, channel.memberCount())
+	// the snapshot read before is not changed.
+	assert.Len(t, before.ids, 1)
+
+	assert.NoError(t, channel.Subscribe("1", &SubscriberOptions{Perm: PermAdmin}))
+	assert.True(t, channel.IsAdmin("1"))
+	assert.False(t, before.members["1"].isAdmin())
+}
+
+func TestRealSubscription_ConcurrentJoinLeave(t *testing.T) {
+	logger.SetLevel(logger.LevelWarn)
+	defer logger.SetLevel(logger.LevelDebug)
+
+	s := NewSubscription(&mockStore{}, &mockStore{})
+	s.SetGateInterface(&mockGate{})
+	sbp := NewSubscribeWrap(s)
+	for i := 0; i < 8; i++ {
+		assert.NoError(t, sbp.CreateChannel(subscription.ChanID(fmt.Sprint(i)), &subscription.ChanInfo{Live: true}))
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ch := subscription.ChanID(fmt.Sprint(j % 8))
+				sb := subscription.SubscriberID(fmt.Sprintf("%d_%d", i, j))
+				assert.NoError(t, sbp.Subscribe(ch, sb, &SubscriberOptions{Perm: PermRead | PermWrite}))
+				_ = sbp.Publish(ch, &PublishMessage{From: sb, Type: TypeNotify, Message: messages.NewEmptyMessage()})
+				if j%2 == 0 {
+					assert.NoError(t, sbp.UnSubscribe(ch, sb))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, n := range s.(subscription.Inspector).ChannelMemberCounts() {
+		total += n
+	}
+	assert.Equal(t, 8*50, total)
+}
+
+// BenchmarkRealSubscription_JoinLeave members join and leave channels in parallel, channels of different shards
+// don't contend.
+func BenchmarkRealSubscription_JoinLeave(b *testing.B) {
+	logger.SetLevel(logger.LevelWarn)
+	defer logger.SetLevel(logger.LevelDebug)
+
+	s := newRealSubscription(&mockStore{}, &mockStore{})
+	s.gate = &mockGate{}
+	const channels = 1024
+	for i := 0; i < channels; i++ {
+		_ = s.CreateChannel(subscription.ChanID(fmt.Sprint(i)), &subscription.ChanInfo{Live: true})
+	}
+	opts := &SubscriberOptions{Perm: PermRead}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			ch := subscription.ChanID(fmt.Sprint(i % channels))
+			_ = s.Subscribe(ch, "1", opts)
+			_ = s.UnSubscribe(ch, "1")
+			i++
+		}
+	})
+}
+
+// BenchmarkChannel_PushWhileJoining fan-out to a large channel while members join and leave it, the fan-out reads
+// the member snapshot without blocking joins.
+func BenchmarkChannel_PushWhileJoining(b *testing.B) {
+	logger.SetLevel(logger.LevelWarn)
+	defer logger.SetLevel(logger.LevelDebug)
+
+	channel := mockNewChannel("test")
+	_ = channel.Update(&subscription.ChanInfo{Live: true})
+	for i := 0; i < 1000; i++ {
+		_ = channel.Subscribe(subscription.SubscriberID(fmt.Sprint(i)), &SubscriberOptions{Perm: PermRead})
+	}
+	m := &PublishMessage{Message: messages.NewEmptyMessage()}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		opts := &SubscriberOptions{Perm: PermRead}
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			id := subscription.SubscriberID(fmt.Sprint("joining_", i%100))
+			_ = channel.Subscribe(id, opts)
+			_ = channel.Unsubscribe(id)
+		}
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		channel.push(m)
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"github.com/glide-im/glide/pkg/gate"
 	"github.com/glide-im/glide/pkg/store"
 	"github.com/glide-im/glide/pkg/subscription"
-	"sync"
+	"github.com/glide-im/glide/pkg/webhook"
 )
 
 var _ subscription.Subscribe = (*subscriptionImpl)(nil)
+var _ subscription.Inspector = (*subscriptionImpl)(nil)
+var _ subscription.SubscriberRemover = (*subscriptionImpl)(nil)
+var _ subscription.SubscriberRenamer = (*subscriptionImpl)(nil)
+var _ subscription.MemberInspector = (*subscriptionImpl)(nil)
 
 type subscriptionImpl struct {
 	unwrap *realSubscription
@@ -29,8 +33,14 @@ func (s *subscriptionImpl) UpdateSubscriber(id subscription.ChanID, updates []su
 		switch update.Flag {
 		case subscription.SubscriberSubscribe:
 			err = s.unwrap.Subscribe(id, update.ID, update.Extra)
+			if err == nil {
+				webhook.Emit(webhook.EventChannelJoined, &webhook.ChannelEventData{Channel: string(id), Uid: string(update.ID)})
+			}
 		case subscription.SubscriberUnsubscribe:
 			err = s.unwrap.UnSubscribe(id, update.ID)
+			if err == nil {
+				webhook.Emit(webhook.EventChannelLeft, &webhook.ChannelEventData{Channel: string(id), Uid: string(update.ID)})
+			}
 		case subscription.SubscriberUpdate:
 			err = s.unwrap.UpdateSubscriber(id, update.ID, update.Extra)
 		default:
@@ -78,6 +88,30 @@ func (s *subscriptionImpl) PublishMessage(id subscription.ChanID, message subscr
 	return s.unwrap.Publish(id, message)
 }
 
+func (s *subscriptionImpl) ChannelMemberCounts() map[subscription.ChanID]int {
+	return s.unwrap.ChannelMemberCounts()
+}
+
+func (s *subscriptionImpl) RemoveSubscriber(id subscription.SubscriberID) ([]subscription.ChanID, error) {
+	chs, err := s.unwrap.RemoveSubscriber(id)
+	for _, ch := range chs {
+		webhook.Emit(webhook.EventChannelLeft, &webhook.ChannelEventData{Channel: string(ch), Uid: string(id)})
+	}
+	return chs, err
+}
+
+func (s *subscriptionImpl) RenameSubscriber(from subscription.SubscriberID, to subscription.SubscriberID) ([]subscription.ChanID, error) {
+	return s.unwrap.RenameSubscriber(from, to)
+}
+
+func (s *subscriptionImpl) Subscribers(id subscription.ChanID) ([]subscription.SubscriberID, error) {
+	return s.unwrap.Subscribers(id)
+}
+
+func (s *subscriptionImpl) IsAdmin(id subscription.ChanID, sb subscription.SubscriberID) (bool, error) {
+	return s.unwrap.IsAdmin(id, sb)
+}
+
 func (s *subscriptionImpl) SetGateInterface(g gate.DefaultGateway) {
 	s.unwrap.gate = g
 }
@@ -85,8 +119,7 @@ func (s *subscriptionImpl) SetGateInterface(g gate.DefaultGateway) {
 var _ SubscribeWrap = (*realSubscription)(nil)
 
 type realSubscription struct {
-	mu       sync.RWMutex
-	channels map[subscription.ChanID]subscription.Channel
+	channels *channelMap
 	store    store.SubscriptionStore
 	seqStore ChannelSequenceStore
 	gate     gate.DefaultGateway
@@ -94,18 +127,14 @@ type realSubscription struct {
 
 func newRealSubscription(msgStore store.SubscriptionStore, seqStore ChannelSequenceStore) *realSubscription {
 	return &realSubscription{
-		mu:       sync.RWMutex{},
-		channels: make(map[subscription.ChanID]subscription.Channel),
+		channels: newChannelMap(),
 		store:    msgStore,
 		seqStore: seqStore,
 	}
 }
 
 func (u *realSubscription) Subscribe(chID subscription.ChanID, sbID subscription.SubscriberID, extra interface{}) error {
-	u.mu.RLock()
-	defer u.mu.RUnlock()
-
-	ch, ok := u.channels[chID]
+	ch, ok := u.channels.get(chID)
 	if !ok {
 		return errors.New(subscription.ErrChanNotExist)
 	}
@@ -113,22 +142,15 @@ func (u *realSubscription) Subscribe(chID subscription.ChanID, sbID subscription
 }
 
 func (u *realSubscription) UnSubscribe(chID subscription.ChanID, id subscription.SubscriberID) error {
-	u.mu.RLock()
-	defer u.mu.RUnlock()
-
-	ch, ok := u.channels[chID]
+	ch, ok := u.channels.get(chID)
 	if !ok {
 		return errors.New(subscription.ErrChanNotExist)
 	}
-
 	return ch.Unsubscribe(id)
 }
 
 func (u *realSubscription) UpdateSubscriber(chID subscription.ChanID, id subscription.SubscriberID, update interface{}) error {
-	u.mu.RLock()
-	defer u.mu.RUnlock()
-
-	ch, ok := u.channels[chID]
+	ch, ok := u.channels.get(chID)
 	if !ok {
 		return errors.New(subscription.ErrChanNotExist)
 	}
@@ -136,56 +158,163 @@ func (u *realSubscription) UpdateSubscriber(chID subscription.ChanID, id subscri
 }
 
 func (u *realSubscription) RemoveChannel(chID subscription.ChanID) error {
-	u.mu.Lock()
-	defer u.mu.Unlock()
-
-	_, ok := u.channels[chID]
+	ch, ok := u.channels.remove(chID)
 	if !ok {
 		return errors.New(subscription.ErrChanNotExist)
 	}
-	delete(u.channels, chID)
+	if c, ok := ch.(*Channel); ok {
+		c.setExpiry(nil)
+	}
 	return nil
 }
 
 func (u *realSubscription) CreateChannel(chID subscription.ChanID, update *subscription.ChanInfo) error {
-	u.mu.Lock()
-	defer u.mu.Unlock()
-
-	if _, ok := u.channels[chID]; ok {
-		return errors.New(subscription.ErrChanAlreadyExists)
-	}
-
-	channel, err := NewChannel(chID, u.gate, u.store, u.seqStore)
-	if err != nil {
-		return err
-	}
-	err = channel.Update(update)
+	ch, err := u.channels.create(chID, func() (subscription.Channel, error) {
+		channel, err := NewChannel(chID, u.gate, u.store, u.seqStore)
+		if err != nil {
+			return nil, err
+		}
+		return channel, channel.Update(update)
+	})
 	if err != nil {
 		return err
 	}
-	u.channels[chID] = channel
+	u.scheduleExpiry(chID, ch.(*Channel))
 	return nil
 }
 
 func (u *realSubscription) UpdateChannel(chID subscription.ChanID, update *subscription.ChanInfo) error {
-	u.mu.RLock()
-	defer u.mu.RUnlock()
-
-	ch, ok := u.channels[chID]
+	ch, ok := u.channels.get(chID)
 	if !ok {
 		return errors.New(subscription.ErrChanNotExist)
 	}
 
-	return ch.Update(update)
+	if err := ch.Update(update); err != nil {
+		return err
+	}
+	if c, ok := ch.(*Channel); ok {
+		u.scheduleExpiry(chID, c)
+	}
+	return nil
 }
 
 func (u *realSubscription) Publish(chID subscription.ChanID, msg subscription.Message) error {
-	u.mu.RLock()
-	defer u.mu.RUnlock()
-
-	ch, ok := u.channels[chID]
+	ch, ok := u.channels.get(chID)
 	if !ok {
 		return errors.New(subscription.ErrChanNotExist)
 	}
 	return ch.Publish(msg)
 }
+
+func (u *realSubscription) ChannelMemberCounts() map[subscription.ChanID]int {
+	result := make(map[subscription.ChanID]int, u.channels.len())
+	u.channels.each(func(id subscription.ChanID, ch subscription.Channel) {
+		if c, ok := ch.(*Channel); ok {
+			result[id] = c.memberCount()
+			return
+		}
+		result[id] = len(ch.GetSubscribers())
+	})
+	return result
+}
+
+// RemoveSubscriber unsubscribes the subscriber from all channels, returns channels unsubscribed successfully.
+func (u *realSubscription) RemoveSubscriber(id subscription.SubscriberID) ([]subscription.ChanID, error) {
+	var removed []subscription.ChanID
+	var errMsg string
+	u.channels.each(func(chID subscription.ChanID, ch subscription.Channel) {
+		if !isSubscribed(ch, id) {
+			return
+		}
+		err := ch.Unsubscribe(id)
+		if err != nil {
+			// unsubscribed concurrently
+			if err.Error() == subscription.ErrNotSubscribed {
+				return
+			}
+			errMsg += string(chID) + ": " + err.Error() + "\n"
+			return
+		}
+		removed = append(removed, chID)
+	})
+	if errMsg != "" {
+		return removed, errors.New(errMsg)
+	}
+	return removed, nil
+}
+
+// RenameSubscriber moves the subscriber from to the subscriber to in all channels, returns channels moved successfully.
+func (u *realSubscription) RenameSubscriber(from subscription.SubscriberID, to subscription.SubscriberID) ([]subscription.ChanID, error) {
+	var renamed []subscription.ChanID
+	var errMsg string
+	u.channels.each(func(chID subscription.ChanID, ch subscription.Channel) {
+		if !isSubscribed(ch, from) {
+			return
+		}
+		r, ok := ch.(interface {
+			Rename(from subscription.SubscriberID, to subscription.SubscriberID) error
+		})
+		if !ok {
+			errMsg += string(chID) + ": rename subscriber not supported\n"
+			return
+		}
+		err := r.Rename(from, to)
+		if err != nil {
+			// unsubscribed concurrently
+			if err.Error() == subscription.ErrNotSubscribed {
+				return
+			}
+			errMsg += string(chID) + ": " + err.Error() + "\n"
+			return
+		}
+		renamed = append(renamed, chID)
+	})
+	if errMsg != "" {
+		return renamed, errors.New(errMsg)
+	}
+	return renamed, nil
+}
+
+// Subscribers returns subscribers of the channel.
+func (u *realSubscription) Subscribers(chID subscription.ChanID) ([]subscription.SubscriberID, error) {
+	ch, ok := u.channels.get(chID)
+	if !ok {
+		return nil, errors.New(subscription.ErrChanNotExist)
+	}
+	subscribers := ch.GetSubscribers()
+	ret := make([]subscription.SubscriberID, 0, len(subscribers))
+	for _, sb := range subscribers {
+		ret = append(ret, subscription.SubscriberID(sb))
+	}
+	return ret, nil
+}
+
+// IsAdmin returns true if the subscriber is an admin of the channel.
+func (u *realSubscription) IsAdmin(chID subscription.ChanID, id subscription.SubscriberID) (bool, error) {
+	ch, ok := u.channels.get(chID)
+	if !ok {
+		return false, errors.New(subscription.ErrChanNotExist)
+	}
+	a, ok := ch.(interface {
+		IsAdmin(id subscription.SubscriberID) bool
+	})
+	return ok && a.IsAdmin(id), nil
+}
+
+// isSubscribed returns true if id subscribed the channel, the member snapshot of *Channel is used without copying
+// subscribers.
+func isSubscribed(ch subscription.Channel, id subscription.SubscriberID) bool {
+	if c, ok := ch.(*Channel); ok {
+		return c.isSubscribed(id)
+	}
+	return containsSubscriber(ch.GetSubscribers(), id)
+}
+
+func containsSubscriber(subscribers []string, id subscription.SubscriberID) bool {
+	for _, s := range subscribers {
+		if s == string(id) {
+			return true
+		}
+	}
+	return false
+}
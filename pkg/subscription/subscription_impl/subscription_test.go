@@ -5,6 +5,7 @@ import (
 	"github.com/glide-im/glide/pkg/subscription"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 type mockStore struct {
@@ -87,3 +88,88 @@ func TestRealSubscription_UnSubscribe(t *testing.T) {
 	err = sbp.UnSubscribe(id, "test")
 	assert.Nil(t, err)
 }
+
+func TestSubscriptionImpl_RemoveSubscriber(t *testing.T) {
+	s := NewSubscription(&mockStore{}, &mockStore{})
+	s.SetGateInterface(&mockGate{})
+	sbp := NewSubscribeWrap(s)
+	for _, ch := range []subscription.ChanID{"a", "b"} {
+		assert.NoError(t, sbp.CreateChannel(ch, &subscription.ChanInfo{}))
+	}
+	assert.NoError(t, sbp.Subscribe("a", "1", &SubscriberOptions{Perm: PermRead}))
+	assert.NoError(t, sbp.Subscribe("b", "2", &SubscriberOptions{Perm: PermRead}))
+
+	chs, err := s.(subscription.SubscriberRemover).RemoveSubscriber("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []subscription.ChanID{"a"}, chs)
+	assert.Equal(t, map[subscription.ChanID]int{"a": 0, "b": 1}, s.(subscription.Inspector).ChannelMemberCounts())
+}
+
+func TestSubscriptionImpl_RenameSubscriber(t *testing.T) {
+	s := NewSubscription(&mockStore{}, &mockStore{})
+	s.SetGateInterface(&mockGate{})
+	sbp := NewSubscribeWrap(s)
+	for _, ch := range []subscription.ChanID{"a", "b"} {
+		assert.NoError(t, sbp.CreateChannel(ch, &subscription.ChanInfo{}))
+	}
+	assert.NoError(t, sbp.Subscribe("a", "tmp@1", &SubscriberOptions{Perm: PermRead | PermWrite}))
+	assert.NoError(t, sbp.Subscribe("b", "2", &SubscriberOptions{Perm: PermRead}))
+
+	chs, err := s.(subscription.SubscriberRenamer).RenameSubscriber("tmp@1", "1")
+	assert.NoError(t, err)
+	assert.Equal(t, []subscription.ChanID{"a"}, chs)
+
+	ch := channelOf(s.(*subscriptionImpl).unwrap, "a")
+	assert.Equal(t, []string{"1"}, ch.GetSubscribers())
+	assert.Equal(t, PermRead|PermWrite, ch.subscribers["1"].Perm)
+}
+
+type mockPurgeStore struct {
+	mockStore
+	purged []subscription.ChanID
+}
+
+func (m *mockPurgeStore) PurgeChannel(ch subscription.ChanID) error {
+	m.purged = append(m.purged, ch)
+	return nil
+}
+
+func TestRealSubscription_Expiry(t *testing.T) {
+	st := &mockPurgeStore{}
+	s := NewSubscription(st, &mockStore{})
+	s.SetGateInterface(&mockGate{})
+	sbp := NewSubscribeWrap(s)
+	u := s.(*subscriptionImpl).unwrap
+
+	assert.NoError(t, sbp.CreateChannel("idle", &subscription.ChanInfo{IdleTimeout: time.Hour}))
+	assert.NoError(t, sbp.CreateChannel("permanent", &subscription.ChanInfo{}))
+	assert.NoError(t, sbp.Subscribe("idle", "1", &SubscriberOptions{Perm: PermRead | PermWrite}))
+	ch := channelOf(u, "idle")
+	assert.NotNil(t, ch.expiry)
+	assert.Nil(t, channelOf(u, "permanent").expiry)
+
+	// the channel active is not expired
+	u.checkExpiry("idle", ch)
+	assert.NotNil(t, channelOf(u, "idle"))
+
+	ch.publishAt = time.Now().Add(-time.Hour * 2).UnixNano()
+	u.checkExpiry("idle", ch)
+	assert.Nil(t, channelOf(u, "idle"))
+	assert.True(t, ch.info.Closed)
+	assert.Equal(t, []subscription.ChanID{"idle"}, st.purged)
+
+	// the ttl elapsed
+	assert.NoError(t, sbp.CreateChannel("ttl", &subscription.ChanInfo{TTL: time.Minute}))
+	ch = channelOf(u, "ttl")
+	ch.createdAt = time.Now().Add(-time.Minute * 2).UnixNano()
+	u.checkExpiry("ttl", ch)
+	assert.Nil(t, channelOf(u, "ttl"))
+}
+
+func channelOf(u *realSubscription, id subscription.ChanID) *Channel {
+	ch, ok := u.channels.get(id)
+	if !ok {
+		return nil
+	}
+	return ch.(*Channel)
+}
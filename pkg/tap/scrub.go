@@ -0,0 +1,59 @@
+package tap
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// RedactFields replaces values of fields with "[REDACTED]", paths are dot separated keys of the message, such as
+// "data.content", missing fields are ignored.
+func RedactFields(paths ...string) Scrubber {
+	return func(r *Record) bool {
+		for _, p := range paths {
+			replaceField(r.Message, p, func(interface{}) interface{} {
+				return redacted
+			})
+		}
+		return true
+	}
+}
+
+// HashFields replaces string values of fields and the uid of the record with the HMAC-SHA256 of the secret, so
+// messages of the same user can be correlated without exposing the identity, paths are the same as RedactFields.
+func HashFields(secret string, paths ...string) Scrubber {
+	hash := func(v interface{}) interface{} {
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return v
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(s))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	return func(r *Record) bool {
+		r.Uid = hash(r.Uid).(string)
+		for _, p := range paths {
+			replaceField(r.Message, p, hash)
+		}
+		return true
+	}
+}
+
+func replaceField(m map[string]interface{}, path string, fn func(v interface{}) interface{}) {
+	keys := strings.Split(path, ".")
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = next
+	}
+	last := keys[len(keys)-1]
+	if v, ok := m[last]; ok {
+		m[last] = fn(v)
+	}
+}
@@ -0,0 +1,226 @@
+// Package tap mirrors copies of messages delivered to clients to analytics sinks, such as Kafka, ClickHouse and files.
+// Messages are filtered by action and sampled on the delivery path, then scrubbed and written to sinks in batches in
+// the background, so the delivery is never blocked by sinks, copies are dropped when the queue is full.
+package tap
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/messages"
+)
+
+var log = logger.Named("tap")
+
+const (
+	defaultQueueSize     = 10000
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+)
+
+// Record the copy of the message delivered to the client.
+type Record struct {
+	// Time the unix milliseconds the message is delivered.
+	Time   int64  `json:"time"`
+	Uid    string `json:"uid"`
+	Device string `json:"device,omitempty"`
+	Action string `json:"action"`
+	// Message the message decoded from json, it's scrubbed in place by scrubbers.
+	Message map[string]interface{} `json:"message"`
+}
+
+// Scrubber removes or masks personal data of the record in place, such as RedactFields and HashFields, it returns
+// false to drop the record.
+type Scrubber func(r *Record) bool
+
+// Sink writes records in batch, such as KafkaSink, ClickHouseSink and FileSink.
+type Sink interface {
+	Write(rs []*Record) error
+}
+
+// Options of Tap.
+type Options struct {
+	// Actions the actions of messages mirrored, empty to mirror all actions.
+	Actions []messages.Action
+	// SampleRate the ratio of messages mirrored in (0, 1], default 1.
+	SampleRate float64
+	// Scrubbers are applied to each record in order before written to sinks.
+	Scrubbers []Scrubber
+	Sinks     []Sink
+	// QueueSize the max count of messages waiting to be written, default 10000.
+	QueueSize int
+	// BatchSize the max count of records written in a batch, default 100.
+	BatchSize int
+	// FlushInterval the max duration records wait in a batch, default 1 second.
+	FlushInterval time.Duration
+}
+
+type mirrored struct {
+	at     int64
+	uid    string
+	device string
+	m      *messages.GlideMessage
+}
+
+// Tap mirrors messages to sinks.
+type Tap struct {
+	opts    Options
+	actions map[messages.Action]bool
+	queue   chan mirrored
+	dropped int64
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New creates the tap, at least one sink is required.
+func New(opts *Options) (*Tap, error) {
+	if len(opts.Sinks) == 0 {
+		return nil, errors.New("no tap sink")
+	}
+	o := *opts
+	if o.SampleRate <= 0 || o.SampleRate > 1 {
+		o.SampleRate = 1
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = defaultQueueSize
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = defaultBatchSize
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = defaultFlushInterval
+	}
+	t := &Tap{
+		opts:  o,
+		queue: make(chan mirrored, o.QueueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if len(o.Actions) > 0 {
+		t.actions = map[messages.Action]bool{}
+		for _, a := range o.Actions {
+			t.actions[a] = true
+		}
+	}
+	return t, nil
+}
+
+// Mirror queues the copy of the message delivered to the device of the user if the action is mirrored and sampled,
+// it never blocks.
+func (t *Tap) Mirror(uid string, device string, m *messages.GlideMessage) {
+	if t.actions != nil && !t.actions[m.GetAction()] {
+		return
+	}
+	if t.opts.SampleRate < 1 && rand.Float64() >= t.opts.SampleRate {
+		return
+	}
+	// the message may be released to pool after written, the data is not modified after sent.
+	c := messages.CopyMessage(m)
+	select {
+	case t.queue <- mirrored{at: time.Now().UnixMilli(), uid: uid, device: device, m: c}:
+	default:
+		if atomic.AddInt64(&t.dropped, 1)%1000 == 1 {
+			log.W("tap queue is full, %d messages dropped", atomic.LoadInt64(&t.dropped))
+		}
+	}
+}
+
+// Start writes records to sinks in background.
+func (t *Tap) Start() {
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(t.opts.FlushInterval)
+		defer ticker.Stop()
+
+		batch := make([]*Record, 0, t.opts.BatchSize)
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			t.write(batch)
+			batch = make([]*Record, 0, t.opts.BatchSize)
+		}
+		for {
+			select {
+			case <-t.stop:
+				for {
+					select {
+					case m := <-t.queue:
+						if r := t.record(m); r != nil {
+							batch = append(batch, r)
+						}
+					default:
+						flush()
+						return
+					}
+				}
+			case m := <-t.queue:
+				if r := t.record(m); r != nil {
+					batch = append(batch, r)
+				}
+				if len(batch) >= t.opts.BatchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}()
+}
+
+// Stop writes the records left and stops the background writing.
+func (t *Tap) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+		<-t.done
+	})
+}
+
+// record encodes the message to the record and applies scrubbers, nil if the record is dropped.
+func (t *Tap) record(m mirrored) *Record {
+	b, err := json.Marshal(m.m)
+	if err != nil {
+		log.E("encode mirrored message %s error: %v", m.m.Action, err)
+		return nil
+	}
+	r := &Record{Time: m.at, Uid: m.uid, Device: m.device, Action: m.m.Action}
+	if err = json.Unmarshal(b, &r.Message); err != nil {
+		log.E("decode mirrored message %s error: %v", m.m.Action, err)
+		return nil
+	}
+	for _, s := range t.opts.Scrubbers {
+		if !s(r) {
+			return nil
+		}
+	}
+	return r
+}
+
+func (t *Tap) write(rs []*Record) {
+	for _, s := range t.opts.Sinks {
+		if err := s.Write(rs); err != nil {
+			log.E("write %d records error: %v", len(rs), err)
+		}
+	}
+}
+
+var defaultTap *Tap
+
+// SetDefault sets the tap used by Mirror, nil to disable mirroring.
+func SetDefault(t *Tap) {
+	defaultTap = t
+}
+
+// Mirror mirrors the message delivered to the default tap, it does nothing if not set.
+func Mirror(uid string, device string, m *messages.GlideMessage) {
+	if defaultTap != nil {
+		defaultTap.Mirror(uid, device, m)
+	}
+}
@@ -0,0 +1,71 @@
+package tap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+type memSink struct {
+	mu      sync.Mutex
+	records []*Record
+}
+
+func (m *memSink) Write(rs []*Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, rs...)
+	return nil
+}
+
+func TestTap_Mirror(t *testing.T) {
+	sink := &memSink{}
+	tp, err := New(&Options{
+		Actions: []messages.Action{messages.ActionChatMessage},
+		Scrubbers: []Scrubber{
+			RedactFields("data.content", "data.missing.field"),
+			HashFields("secret", "data.from"),
+			func(r *Record) bool {
+				return r.Device != "drop"
+			},
+		},
+		Sinks:     []Sink{sink},
+		BatchSize: 2,
+	})
+	assert.NoError(t, err)
+	tp.Start()
+
+	cm := &messages.ChatMessage{Mid: 1, From: "1", To: "2", Content: "secret content"}
+	m := messages.NewPooledMessage(0, messages.ActionChatMessage, cm)
+	tp.Mirror("2", "1", m)
+	messages.ReleaseMessage(m)
+	tp.Mirror("2", "1", messages.NewMessage(0, messages.ActionHeartbeat, nil))
+	tp.Mirror("2", "drop", messages.NewMessage(0, messages.ActionChatMessage, cm))
+	tp.Stop()
+
+	assert.Len(t, sink.records, 1)
+	r := sink.records[0]
+	assert.Equal(t, string(messages.ActionChatMessage), r.Action)
+	assert.NotEqual(t, "2", r.Uid)
+	data := r.Message["data"].(map[string]interface{})
+	assert.Equal(t, redacted, data["content"])
+	assert.Len(t, data["from"], 64)
+	assert.EqualValues(t, 1, data["mid"])
+	// the message delivered is not modified.
+	assert.Equal(t, "secret content", cm.Content)
+}
+
+func TestTap_Sample(t *testing.T) {
+	sink := &memSink{}
+	tp, err := New(&Options{SampleRate: 0.1, Sinks: []Sink{sink}, FlushInterval: time.Millisecond * 10})
+	assert.NoError(t, err)
+	tp.Start()
+	for i := 0; i < 1000; i++ {
+		tp.Mirror("1", "", messages.NewMessage(0, messages.ActionHeartbeat, nil))
+	}
+	tp.Stop()
+	assert.InDelta(t, 100, len(sink.records), 60)
+}
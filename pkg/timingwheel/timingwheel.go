@@ -1,25 +1,50 @@
+// Package timingwheel is a hierarchical timing wheel for a large number of timers with coarse precision, such as
+// heartbeats, TTLs and ACK timeouts. Adding and canceling a timer is O(1) and all timers share one ticker, timers
+// fire at the precision of the tick interval.
+//
+// Timers longer than the wheels cover are put at the end of the wheels, and put back for the remaining duration
+// when reached, so there is no limit of the duration.
 package timingwheel
 
 import (
-	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Executor runs the callback of fired tasks, default runs in a new goroutine, it must not run the callback
+// synchronously.
 var Executor = func(f func()) {
 	go f()
 }
 
+const (
+	taskPending int32 = iota
+	taskFired
+	taskCanceled
+)
+
+// Task is a timer in the TimingWheel, it's created by TimingWheel.After, TimingWheel.AfterFunc or
+// TimingWheel.Schedule.
 type Task struct {
 	offset int
-	s      *slot
 	at     time.Time
+	tw     *TimingWheel
+	state  int32
+
+	// s the slot the task is in, prev and next link tasks in the slot.
+	s    *slot
+	prev *Task
+	next *Task
 
 	fn func()
-	C  chan struct{}
+	// C receives when the task fired, the receive is dropped if no one is waiting, it's nil for tasks of AfterFunc
+	// and Schedule.
+	C chan struct{}
 }
 
+// TTL returns the milliseconds remaining until the task fires.
 func (s *Task) TTL() int64 {
 	now := float64(time.Now().UnixNano())
 	at := float64(s.at.UnixNano())
@@ -27,11 +52,21 @@ func (s *Task) TTL() int64 {
 }
 
 func (s *Task) call() {
-	if s.s == nil {
+	if atomic.LoadInt32(&s.state) != taskPending {
+		return
+	}
+	// the task longer than the wheels is reached early, put it back for the remaining.
+	if s.tw != nil && time.Until(s.at) >= s.tw.interval {
+		Executor(func() {
+			s.tw.put(s)
+		})
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&s.state, taskPending, taskFired) {
 		return
 	}
 	Executor(func() {
-		s.Cancel()
+		s.detach()
 		if s.fn != nil {
 			s.fn()
 		}
@@ -42,24 +77,45 @@ func (s *Task) call() {
 	})
 }
 
+// Callback sets the function called when the task fired, it must be called before the task fires, use
+// TimingWheel.AfterFunc instead.
 func (s *Task) Callback(f func()) {
 	s.fn = f
 }
 
-func (s *Task) Cancel() {
+// Cancel stops the task, returns false if the task has already fired or been canceled.
+func (s *Task) Cancel() bool {
+	if !atomic.CompareAndSwapInt32(&s.state, taskPending, taskCanceled) {
+		return false
+	}
+	s.detach()
+	return true
+}
+
+func (s *Task) pending() bool {
+	return atomic.LoadInt32(&s.state) == taskPending
+}
+
+// detach removes the task from the slot it's in.
+func (s *Task) detach() {
+	if s.tw == nil {
+		return
+	}
+	s.tw.mu.Lock()
+	defer s.tw.mu.Unlock()
 	if s.s != nil {
 		s.s.remove(s)
-		s.s = nil
 	}
 }
 
+// slot is a list of tasks, slots and tasks in it are guarded by TimingWheel.mu.
 type slot struct {
-	index  int
-	next   *slot
-	len    int
-	values map[*Task]interface{}
+	index int
+	next  *slot
+	len   int
+	// head the sentinel of the circular list of tasks.
+	head Task
 
-	m         sync.Mutex
 	circulate bool
 }
 
@@ -70,10 +126,10 @@ func newSlot(circulate bool, len int) *slot {
 		n := &slot{
 			index:     i,
 			len:       len,
-			values:    map[*Task]interface{}{},
 			circulate: circulate,
-			m:         sync.Mutex{},
 		}
+		n.head.prev = &n.head
+		n.head.next = &n.head
 		if i == 0 {
 			head = n
 		} else {
@@ -93,10 +149,11 @@ func (s *slot) put(offset int, v *Task) int {
 		return offset
 	}
 	if offset == 0 {
-		s.m.Lock()
-		s.values[v] = nil
+		v.prev = s.head.prev
+		v.next = &s.head
+		s.head.prev.next = v
+		s.head.prev = v
 		v.s = s
-		s.m.Unlock()
 		return 0
 	}
 	if offset >= s.len {
@@ -106,35 +163,33 @@ func (s *slot) put(offset int, v *Task) int {
 }
 
 func (s *slot) isEmpty() bool {
-	s.m.Lock()
-	defer s.m.Unlock()
-	return len(s.values) == 0
+	return s.head.next == &s.head
 }
 
 func (s *slot) callAndRm() {
 	if s.isEmpty() {
 		return
 	}
-	s.m.Lock()
-	for k := range s.values {
-		k.call()
+	for _, v := range s.valueArray() {
+		s.remove(v)
+		v.call()
 	}
-	s.m.Unlock()
 }
 
 func (s *slot) remove(v *Task) {
-	s.m.Lock()
-	delete(s.values, v)
-	s.m.Unlock()
+	if v.s != s {
+		return
+	}
+	v.prev.next = v.next
+	v.next.prev = v.prev
+	v.prev, v.next, v.s = nil, nil, nil
 }
 
 func (s *slot) valueArray() []*Task {
 	var r []*Task
-	s.m.Lock()
-	for k := range s.values {
-		r = append(r, k)
+	for v := s.head.next; v != &s.head; v = v.next {
+		r = append(r, v)
 	}
-	s.m.Unlock()
 	return r
 }
 
@@ -177,13 +232,17 @@ func (w *wheel) move() bool {
 	if w.child != nil {
 		for _, v := range w.slot.valueArray() {
 			w.slot.remove(v)
-			w.child.put(v)
+			if v.pending() {
+				w.child.put(v)
+			}
 		}
 		if w.child.move() {
 			w.slot = w.slot.next
 			for _, v := range w.slot.valueArray() {
 				w.slot.remove(v)
-				w.child.put(v)
+				if v.pending() {
+					w.child.put(v)
+				}
 			}
 			return w.slot.index == 0
 		} else {
@@ -242,24 +301,30 @@ func (w *wheel) put2(v *Task) {
 	}
 }
 
-// TimingWheel the timing wheel ticker implementation
+// TimingWheel is the hierarchical timing wheel, it has wheels of slots, a slot of the wheel covers all slots of the
+// child wheel, the lowest wheel moves a slot every interval, so the wheels cover interval * slots^wheels.
 type TimingWheel struct {
 	interval   time.Duration
 	ticker     *time.Ticker
 	quit       chan struct{}
 	maxTimeout time.Duration
 
+	// mu guards wheels, slots and tasks in slots.
+	mu    sync.Mutex
 	wheel *wheel
 }
 
+// NewTimingWheel returns a running TimingWheel ticks every interval, with wheels of slots, such as 3 wheels of 20 slots
+// ticking every 500ms cover 8000 ticks, about 66 minutes.
 func NewTimingWheel(interval time.Duration, wheels int, slots int) *TimingWheel {
 	tw := new(TimingWheel)
 
 	tw.interval = interval
 	tw.quit = make(chan struct{})
-	s := int64(math.Pow(float64(wheels), float64(slots)))
+	s := int64(math.Pow(float64(slots), float64(wheels)))
 
-	tw.maxTimeout = interval * time.Duration(s)
+	// the last slot of top wheel is not used, the task in the slot may wrap to current slot.
+	tw.maxTimeout = interval * time.Duration(s-s/int64(slots))
 	tw.ticker = time.NewTicker(interval)
 
 	var w *wheel
@@ -278,26 +343,53 @@ func NewTimingWheel(interval time.Duration, wheels int, slots int) *TimingWheel
 	return tw
 }
 
+// Stop stops the ticker, tasks in the wheels never fire.
 func (w *TimingWheel) Stop() {
 	close(w.quit)
 }
 
+// After returns a Task whose C receives after the timeout.
 func (w *TimingWheel) After(timeout time.Duration) *Task {
-	if timeout >= w.maxTimeout {
-		panic(fmt.Sprintf("maxTimeout=%d, current=%d", w.maxTimeout, timeout))
-	}
-	//offset := int(float64(TTL) / float64(w.interval))
-	offset := int(math.Floor(float64(timeout.Milliseconds())/float64(w.interval.Milliseconds()) + 1.0/2.0))
+	t := w.newTask(timeout, nil)
+	t.C = make(chan struct{})
+	w.put(t)
+	return t
+}
 
-	ch := make(chan struct{})
+// AfterFunc calls f in Executor after the timeout, the returned Task cancels the call.
+func (w *TimingWheel) AfterFunc(timeout time.Duration, f func()) *Task {
+	t := w.newTask(timeout, f)
+	w.put(t)
+	return t
+}
 
-	t := &Task{
-		offset: offset,
-		C:      ch,
-		at:     time.Now().Add(timeout),
+// Schedule calls f in Executor at the time, the returned Task cancels the call.
+func (w *TimingWheel) Schedule(at time.Time, f func()) *Task {
+	return w.AfterFunc(time.Until(at), f)
+}
+
+func (w *TimingWheel) newTask(timeout time.Duration, f func()) *Task {
+	return &Task{
+		at: time.Now().Add(timeout),
+		tw: w,
+		fn: f,
+	}
+}
+
+// put puts the task into wheels for the remaining duration, at most maxTimeout.
+func (w *TimingWheel) put(t *Task) {
+	timeout := time.Until(t.at)
+	if timeout >= w.maxTimeout {
+		timeout = w.maxTimeout - w.interval
+	}
+	if timeout < 0 {
+		timeout = 0
 	}
+	t.offset = int(math.Floor(float64(timeout.Milliseconds())/float64(w.interval.Milliseconds()) + 1.0/2.0))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	w.wheel.put2(t)
-	return t
 }
 
 func (w *TimingWheel) run() {
@@ -313,5 +405,7 @@ func (w *TimingWheel) run() {
 }
 
 func (w *TimingWheel) onTicker() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	w.wheel.move()
 }
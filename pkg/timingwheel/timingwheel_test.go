@@ -70,3 +70,73 @@ func sleepRndMilleSec(start int32, end int32) {
 	n = start + n
 	time.Sleep(time.Duration(n) * time.Millisecond)
 }
+
+func TestTimingWheel_AfterFunc(t *testing.T) {
+	tw := NewTimingWheel(time.Millisecond*10, 3, 10)
+	defer tw.Stop()
+
+	fired := make(chan time.Duration, 1)
+	start := time.Now()
+	tw.AfterFunc(time.Millisecond*100, func() {
+		fired <- time.Since(start)
+	})
+	canceled := tw.AfterFunc(time.Millisecond*50, func() {
+		t.Error("canceled task fired")
+	})
+	assert.True(t, canceled.Cancel())
+	assert.False(t, canceled.Cancel())
+
+	select {
+	case d := <-fired:
+		assert.InDelta(t, float64(time.Millisecond*100), float64(d), float64(time.Millisecond*30))
+	case <-time.After(time.Second):
+		t.Fatal("task not fired")
+	}
+}
+
+func TestTimingWheel_LongDelay(t *testing.T) {
+	// the wheels cover 16 ticks, 160ms.
+	tw := NewTimingWheel(time.Millisecond*10, 2, 4)
+	defer tw.Stop()
+
+	start := time.Now()
+	task := tw.After(time.Millisecond * 500)
+	select {
+	case <-task.C:
+		assert.InDelta(t, float64(time.Millisecond*500), float64(time.Since(start)), float64(time.Millisecond*40))
+	case <-time.After(time.Second * 2):
+		t.Fatal("task not fired")
+	}
+	assert.False(t, task.Cancel())
+}
+
+// benchmarkTimers adds and cancels timers in random duration of minutes, it's 1M concurrent timers.
+const benchmarkTimers = 1_000_000
+
+func BenchmarkTimingWheel_AfterFunc(b *testing.B) {
+	tw := NewTimingWheel(time.Millisecond*500, 3, 20)
+	defer tw.Stop()
+	tasks := make([]*Task, benchmarkTimers)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range tasks {
+			tasks[j] = tw.AfterFunc(time.Minute+time.Duration(rand.Int63n(int64(time.Minute))), func() {})
+		}
+		for _, task := range tasks {
+			task.Cancel()
+		}
+	}
+}
+
+func BenchmarkTimer_AfterFunc(b *testing.B) {
+	timers := make([]*time.Timer, benchmarkTimers)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range timers {
+			timers[j] = time.AfterFunc(time.Minute+time.Duration(rand.Int63n(int64(time.Minute))), func() {})
+		}
+		for _, timer := range timers {
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"github.com/glide-im/glide/pkg/messages"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/glide-im/glide"
+
+// propagator propagates trace context in GlideMessage.Extra with W3C trace context keys, `traceparent` and `tracestate`.
+var propagator = propagation.TraceContext{}
+
+// Tracer returns the tracer of glide from the global tracer provider, set by otel.SetTracerProvider, spans are not
+// recorded if the provider is not set.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span which parent is the trace context carried by message.
+func Start(m *messages.GlideMessage, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx := Extract(context.Background(), m)
+	attrs = append(attrs, attribute.String("glide.action", m.Action))
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Extract returns context with the trace context carried by message.
+func Extract(ctx context.Context, m *messages.GlideMessage) context.Context {
+	if m == nil || len(m.Extra) == 0 {
+		return ctx
+	}
+	return propagator.Extract(ctx, carrier(m.Extra))
+}
+
+// Inject sets the trace context of ctx to message, the message must not be shared with other goroutines.
+func Inject(ctx context.Context, m *messages.GlideMessage) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	if m.Extra == nil {
+		m.Extra = map[string]string{}
+	}
+	propagator.Inject(ctx, carrier(m.Extra))
+}
+
+// Propagate copies the trace context from one message to another.
+func Propagate(from, to *messages.GlideMessage) {
+	Inject(Extract(context.Background(), from), to)
+}
+
+// WithTrace returns a shallow copy of message carries the trace context of ctx, used to send a shared message.
+func WithTrace(ctx context.Context, m *messages.GlideMessage) *messages.GlideMessage {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return m
+	}
+	c := *m
+	c.Extra = make(map[string]string, len(m.Extra)+2)
+	for k, v := range m.Extra {
+		c.Extra[k] = v
+	}
+	Inject(ctx, &c)
+	return &c
+}
+
+// RecordError records err to span if err is not nil.
+func RecordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+}
+
+type carrier map[string]string
+
+func (c carrier) Get(key string) string {
+	return c[key]
+}
+
+func (c carrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c carrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,31 @@
+package tracing
+
+import (
+	"context"
+	"github.com/glide-im/glide/pkg/messages"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+func TestInjectExtract(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	m := messages.NewMessage(0, messages.ActionChatMessage, nil)
+	Inject(ctx, m)
+	assert.NotEmpty(t, m.Extra["traceparent"])
+
+	extracted := trace.SpanContextFromContext(Extract(context.Background(), m))
+	assert.Equal(t, sc.TraceID(), extracted.TraceID())
+	assert.Equal(t, sc.SpanID(), extracted.SpanID())
+
+	shared := messages.NewMessage(0, messages.ActionChatMessage, nil)
+	c := WithTrace(ctx, shared)
+	assert.Nil(t, shared.Extra)
+	assert.Equal(t, m.Extra["traceparent"], c.Extra["traceparent"])
+}
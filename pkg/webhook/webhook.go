@@ -0,0 +1,317 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/glide-im/glide/pkg/logger"
+	"github.com/glide-im/glide/pkg/snowflake"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var log = logger.Named("webhook")
+
+const (
+	EventClientConnected     = "client.connected"
+	EventClientAuthenticated = "client.authenticated"
+	EventClientDisconnected  = "client.disconnected"
+	EventMessageSent         = "message.sent"
+	EventChannelJoined       = "channel.joined"
+	EventChannelLeft         = "channel.left"
+	EventUserPurged          = "user.purged"
+	EventGuestUpgraded       = "guest.upgraded"
+	// EventUsageReported the usage report of users flushed by the meter, the data is metering.Report.
+	EventUsageReported = "usage.reported"
+	// EventHealthChanged the health status of a dependency changed, the data is HealthEventData.
+	EventHealthChanged = "health.changed"
+)
+
+const (
+	HeaderEvent     = "X-Glide-Event"
+	HeaderTimestamp = "X-Glide-Timestamp"
+	// HeaderSignature the hex encoded HMAC-SHA256 of "timestamp.body" with the secret, prefixed by "sha256=".
+	HeaderSignature = "X-Glide-Signature"
+)
+
+const (
+	defaultMaxRetries     = 5
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Minute
+	defaultTimeout        = time.Second * 5
+	defaultQueueSize      = 4096
+	defaultWorkers        = 8
+)
+
+// Event is the json body posted to webhook urls.
+type Event struct {
+	ID   int64       `json:"id"`
+	Type string      `json:"type"`
+	Time int64       `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// ClientEventData is the data of client events.
+type ClientEventData struct {
+	ID      string `json:"id"`
+	Uid     string `json:"uid"`
+	Gateway string `json:"gateway,omitempty"`
+	Addr    string `json:"addr,omitempty"`
+}
+
+// MessageEventData is the data of message events.
+type MessageEventData struct {
+	Conversation string      `json:"conversation"`
+	Message      interface{} `json:"message"`
+}
+
+// ChannelEventData is the data of channel subscriber events.
+type ChannelEventData struct {
+	Channel string `json:"channel"`
+	Uid     string `json:"uid"`
+}
+
+// HealthEventData is the data of the health changed event, Degraded is true if the service is in degraded mode.
+type HealthEventData struct {
+	Dependency string `json:"dependency"`
+	Healthy    bool   `json:"healthy"`
+	Error      string `json:"error,omitempty"`
+	Degraded   bool   `json:"degraded"`
+}
+
+// UserPurgedEventData is the data of the user purged event.
+type UserPurgedEventData struct {
+	Uid string `json:"uid"`
+	// Channels the channels the user is removed from.
+	Channels []string `json:"channels,omitempty"`
+}
+
+// GuestUpgradedEventData is the data of the guest upgraded event.
+type GuestUpgradedEventData struct {
+	GuestID string `json:"guest_id"`
+	Uid     string `json:"uid"`
+	// Channels the channels the subscriptions of guest moved to the user.
+	Channels []string `json:"channels,omitempty"`
+}
+
+type Options struct {
+	// URLs the urls events are posted to.
+	URLs []string
+	// Secret the key to sign events, empty to not sign.
+	Secret string
+	// Events the event types to deliver, empty to deliver all.
+	Events []string
+	// MaxRetries the max retry times of failed delivery, default 5.
+	MaxRetries int
+	// InitialBackoff the delay before first retry, doubled on each retry, default 1s.
+	InitialBackoff time.Duration
+	// MaxBackoff the max delay of retry, default 1 minute.
+	MaxBackoff time.Duration
+	// Timeout the http request timeout, default 5s.
+	Timeout time.Duration
+	// QueueSize the max count of pending deliveries, new event is dropped when the queue is full, default 4096.
+	QueueSize int
+	// Workers the count of goroutines to deliver events, default 8.
+	Workers int
+}
+
+type delivery struct {
+	url     string
+	event   string
+	body    []byte
+	attempt int
+}
+
+// Dispatcher posts signed events to webhook urls asynchronously, failed deliveries are retried with exponential
+// backoff.
+type Dispatcher struct {
+	urls           []string
+	secret         []byte
+	events         map[string]bool
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	client         *http.Client
+
+	queue   chan *delivery
+	closed  chan struct{}
+	wg      sync.WaitGroup
+	closeMu sync.RWMutex
+}
+
+func NewDispatcher(opts *Options) (*Dispatcher, error) {
+	if len(opts.URLs) == 0 {
+		return nil, errors.New("webhook urls is empty")
+	}
+	ret := &Dispatcher{
+		urls:           opts.URLs,
+		secret:         []byte(opts.Secret),
+		maxRetries:     opts.MaxRetries,
+		initialBackoff: opts.InitialBackoff,
+		maxBackoff:     opts.MaxBackoff,
+		closed:         make(chan struct{}),
+	}
+	if len(opts.Events) > 0 {
+		ret.events = map[string]bool{}
+		for _, e := range opts.Events {
+			ret.events[e] = true
+		}
+	}
+	if ret.maxRetries <= 0 {
+		ret.maxRetries = defaultMaxRetries
+	}
+	if ret.initialBackoff <= 0 {
+		ret.initialBackoff = defaultInitialBackoff
+	}
+	if ret.maxBackoff <= 0 {
+		ret.maxBackoff = defaultMaxBackoff
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ret.client = &http.Client{Timeout: timeout}
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	ret.queue = make(chan *delivery, queueSize)
+	for i := 0; i < workers; i++ {
+		ret.wg.Add(1)
+		go ret.run()
+	}
+	return ret, nil
+}
+
+// Emit posts the event to all webhook urls asynchronously.
+func (d *Dispatcher) Emit(eventType string, data interface{}) {
+	if d.events != nil && !d.events[eventType] {
+		return
+	}
+	body, err := json.Marshal(&Event{
+		ID:   snowflake.Generate(),
+		Type: eventType,
+		Time: time.Now().Unix(),
+		Data: data,
+	})
+	if err != nil {
+		log.E("marshal event %s error: %v", eventType, err)
+		return
+	}
+	for _, u := range d.urls {
+		d.enqueue(&delivery{url: u, event: eventType, body: body})
+	}
+}
+
+// Close stops the dispatcher, pending retries are dropped.
+func (d *Dispatcher) Close() {
+	d.closeMu.Lock()
+	close(d.closed)
+	close(d.queue)
+	d.closeMu.Unlock()
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) enqueue(dl *delivery) {
+	d.closeMu.RLock()
+	defer d.closeMu.RUnlock()
+
+	select {
+	case <-d.closed:
+		return
+	default:
+	}
+	select {
+	case d.queue <- dl:
+	default:
+		log.W("webhook queue is full, event %s to %s dropped", dl.event, dl.url)
+	}
+}
+
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+	for dl := range d.queue {
+		err := d.post(dl)
+		if err == nil {
+			continue
+		}
+		if dl.attempt >= d.maxRetries {
+			log.E("deliver event %s to %s failed after %d retries: %v", dl.event, dl.url, dl.attempt, err)
+			continue
+		}
+		backoff := d.backoff(dl.attempt)
+		dl.attempt++
+		log.D("deliver event %s to %s failed, retry in %v: %v", dl.event, dl.url, backoff, err)
+		time.AfterFunc(backoff, func() {
+			d.enqueue(dl)
+		})
+	}
+}
+
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	b := d.initialBackoff
+	for i := 0; i < attempt && b < d.maxBackoff; i++ {
+		b *= 2
+	}
+	if b > d.maxBackoff {
+		b = d.maxBackoff
+	}
+	return b
+}
+
+func (d *Dispatcher) post(dl *delivery) error {
+	req, err := http.NewRequest(http.MethodPost, dl.url, bytes.NewReader(dl.body))
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, dl.event)
+	req.Header.Set(HeaderTimestamp, ts)
+	if len(d.secret) > 0 {
+		req.Header.Set(HeaderSignature, "sha256="+Sign(d.secret, ts, dl.body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New(fmt.Sprintf("unexpected status %d", resp.StatusCode))
+	}
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of "timestamp.body", used by receivers to verify the event.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var defaultDispatcher *Dispatcher
+
+// SetDefault sets the dispatcher used by package level Emit, nil to disable.
+func SetDefault(d *Dispatcher) {
+	defaultDispatcher = d
+}
+
+// Emit posts the event with the default dispatcher, it's no-op if the default dispatcher is not set.
+func Emit(eventType string, data interface{}) {
+	if defaultDispatcher == nil {
+		return
+	}
+	defaultDispatcher.Emit(eventType, data)
+}
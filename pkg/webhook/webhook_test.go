@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatcher_Emit(t *testing.T) {
+	received := make(chan *Event, 1)
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fails twice, then succeeds.
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		ts := r.Header.Get(HeaderTimestamp)
+		assert.Equal(t, "sha256="+Sign([]byte("secret"), ts, body), r.Header.Get(HeaderSignature))
+		assert.Equal(t, EventClientConnected, r.Header.Get(HeaderEvent))
+
+		e := &Event{}
+		_ = json.Unmarshal(body, e)
+		received <- e
+	}))
+	defer srv.Close()
+
+	d, err := NewDispatcher(&Options{
+		URLs:           []string{srv.URL},
+		Secret:         "secret",
+		Events:         []string{EventClientConnected},
+		InitialBackoff: time.Millisecond * 10,
+	})
+	assert.NoError(t, err)
+	defer d.Close()
+
+	d.Emit(EventMessageSent, nil)
+	d.Emit(EventClientConnected, &ClientEventData{ID: "1_gw_1", Uid: "1"})
+
+	select {
+	case e := <-received:
+		assert.Equal(t, EventClientConnected, e.Type)
+		assert.Equal(t, "1", e.Data.(map[string]interface{})["uid"])
+	case <-time.After(time.Second):
+		t.Fatal("event not delivered")
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := &Dispatcher{initialBackoff: time.Second, maxBackoff: time.Second * 5}
+	assert.Equal(t, time.Second, d.backoff(0))
+	assert.Equal(t, time.Second*4, d.backoff(2))
+	assert.Equal(t, time.Second*5, d.backoff(10))
+}